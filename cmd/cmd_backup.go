@@ -0,0 +1,348 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"icicle/pkg/cache"
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/logging"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/dustin/go-humanize"
+)
+
+// backupManifestVersion is bumped when BackupManifest changes incompatibly
+const backupManifestVersion = 1
+
+// backupPollInterval is how often a running BACKUP or RESTORE is checked
+const backupPollInterval = 5 * time.Second
+
+// BackupConfig selects what backup writes and where
+type BackupConfig struct {
+	Name     string   // Backup name, the path under Disk or S3URL
+	Disk     string   // ClickHouse disk to write the backup to, e.g. backups
+	S3URL    string   // S3 prefix to write the backup to instead of Disk
+	Tables   []string // Tables to back up (default: every MergeTree table of the database)
+	CacheDir string   // Directory to export each chain's RPC cache to as an archive (default: caches aren't exported)
+	Manifest string   // Path of the manifest (default: <name>.backup.json)
+}
+
+// BackupManifest records what a backup holds, so restore needs nothing but the manifest
+type BackupManifest struct {
+	Version           int           `json:"version"`
+	Name              string        `json:"name"`
+	Disk              string        `json:"disk,omitempty"`
+	S3URL             string        `json:"s3URL,omitempty"`
+	Database          string        `json:"database"`
+	Tables            []string      `json:"tables"`
+	ClickHouseVersion string        `json:"clickhouseVersion"`
+	IcicleVersion     string        `json:"icicleVersion"`
+	CreatedAt         time.Time     `json:"createdAt"`
+	Chains            []BackupChain `json:"chains"`
+}
+
+// BackupChain is the sync state of a chain when it was backed up
+type BackupChain struct {
+	ChainID         uint32 `json:"chainID"`
+	Watermark       uint32 `json:"watermark"`                 // Sync watermark, read before the tables were
+	CacheCheckpoint int64  `json:"cacheCheckpoint,omitempty"` // RPC cache checkpoint, 0 for chains not in config.yaml
+	CacheArchive    string `json:"cacheArchive,omitempty"`    // Archive written by cache export, if exported
+}
+
+// RunBackup writes the tables of the database to a ClickHouse disk or S3 with BACKUP, along with a
+// manifest of the chains' sync watermarks and cache checkpoints and, optionally, their cache archives.
+// sync_watermark is kept in the manifest instead, since EmbeddedRocksDB tables can't always be backed up.
+func RunBackup(cfg BackupConfig) {
+	if (cfg.Disk == "") == (cfg.S3URL == "") {
+		logging.Fatal(slog.Default(), "Exactly one of --disk and --s3 is required")
+	}
+	if cfg.Name == "" {
+		cfg.Name = time.Now().UTC().Format("20060102-150405")
+	}
+	if cfg.Manifest == "" {
+		cfg.Manifest = cfg.Name + ".backup.json"
+	}
+
+	conn, err := chwrapper.Connect()
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to connect", "error", err)
+	}
+	defer conn.Close()
+	ctx := context.Background()
+
+	manifest := BackupManifest{
+		Version:       backupManifestVersion,
+		Name:          cfg.Name,
+		Disk:          cfg.Disk,
+		S3URL:         strings.TrimSuffix(cfg.S3URL, "/"),
+		Database:      chwrapper.Database(),
+		IcicleVersion: binaryVersion(),
+		CreatedAt:     time.Now().UTC(),
+	}
+	if version, err := conn.ServerVersion(); err == nil {
+		manifest.ClickHouseVersion = version.String()
+	}
+
+	// Watermarks are read first, so the backed up tables hold every block below them
+	if manifest.Chains, err = backupChains(ctx, conn); err != nil {
+		logging.Fatal(slog.Default(), "Failed to read sync watermarks", "error", err)
+	}
+
+	manifest.Tables = cfg.Tables
+	if len(manifest.Tables) == 0 {
+		if manifest.Tables, err = mergeTreeTables(ctx, conn); err != nil {
+			logging.Fatal(slog.Default(), "Failed to list tables", "error", err)
+		}
+	}
+
+	destination, args := backupDestination(manifest)
+	targets := make([]string, len(manifest.Tables))
+	for i, table := range manifest.Tables {
+		targets[i] = fmt.Sprintf("TABLE `%s`.`%s`", manifest.Database, table)
+	}
+	fmt.Printf("Backing up %d tables of %s to %s...\n", len(manifest.Tables), manifest.Database, backupLocation(manifest))
+	start := time.Now()
+	query := fmt.Sprintf("BACKUP %s TO %s ASYNC", strings.Join(targets, ", "), destination)
+	if err := runBackupOperation(ctx, conn, query, args, "BACKUP_CREATED"); err != nil {
+		logging.Fatal(slog.Default(), "Backup failed", "error", err)
+	}
+	fmt.Printf("Backed up tables in %s\n", time.Since(start).Round(time.Second))
+
+	if cfg.CacheDir != "" {
+		if err := exportBackupCaches(&manifest, cfg.CacheDir); err != nil {
+			logging.Fatal(slog.Default(), "Failed to export caches", "error", err)
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to marshal manifest", "error", err)
+	}
+	if err := os.WriteFile(cfg.Manifest, append(data, '\n'), 0644); err != nil {
+		logging.Fatal(slog.Default(), "Failed to write manifest", "path", cfg.Manifest, "error", err)
+	}
+	fmt.Printf("Wrote manifest to %s, restore with: restore --manifest %s\n", cfg.Manifest, cfg.Manifest)
+}
+
+// RunRestore restores the tables of the backup described by a manifest into the database, which
+// mustn't have them yet, then creates the remaining tables and sets the sync watermarks of the
+// backup. A non-empty tables restores only those, leaving the watermarks as they are. With caches,
+// the cache archives of the backup are imported too.
+func RunRestore(manifestPath string, tables []string, caches bool) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to read manifest", "path", manifestPath, "error", err)
+	}
+	var manifest BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		logging.Fatal(slog.Default(), "Failed to parse manifest", "path", manifestPath, "error", err)
+	}
+	if manifest.Version != backupManifestVersion {
+		logging.Fatal(slog.Default(), "Unsupported manifest version", "version", manifest.Version)
+	}
+	for _, table := range tables {
+		if !slices.Contains(manifest.Tables, table) {
+			logging.Fatal(slog.Default(), "Table isn't in the backup", "table", table)
+		}
+	}
+	full := len(tables) == 0
+	if full {
+		tables = manifest.Tables
+	}
+
+	conn, err := chwrapper.Connect()
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to connect", "error", err)
+	}
+	defer conn.Close()
+	ctx := context.Background()
+
+	database := chwrapper.Database()
+	destination, args := backupDestination(manifest)
+	targets := make([]string, len(tables))
+	for i, table := range tables {
+		targets[i] = fmt.Sprintf("TABLE `%s`.`%s` AS `%s`.`%s`", manifest.Database, table, database, table)
+	}
+	fmt.Printf("Restoring %d tables from %s into %s...\n", len(tables), backupLocation(manifest), database)
+	start := time.Now()
+	query := fmt.Sprintf("RESTORE %s FROM %s ASYNC", strings.Join(targets, ", "), destination)
+	if err := runBackupOperation(ctx, conn, query, args, "RESTORED"); err != nil {
+		logging.Fatal(slog.Default(), "Restore failed", "error", err)
+	}
+	fmt.Printf("Restored tables in %s\n", time.Since(start).Round(time.Second))
+
+	// Tables left out of the backup, such as sync_watermark, are created empty
+	if err := chwrapper.CreateTables(conn); err != nil {
+		logging.Fatal(slog.Default(), "Failed to create tables", "error", err)
+	}
+
+	if full {
+		for _, chain := range manifest.Chains {
+			if err := chwrapper.SetWatermark(conn, chain.ChainID, chain.Watermark); err != nil {
+				logging.Fatal(slog.Default(), "Failed to restore sync watermark", "chain_id", chain.ChainID, "error", err)
+			}
+			fmt.Printf("Chain %d resumes after block %d\n", chain.ChainID, chain.Watermark)
+		}
+	} else {
+		fmt.Println("Sync watermarks left as they are, since not every table was restored")
+	}
+
+	if caches {
+		for _, chain := range manifest.Chains {
+			if chain.CacheArchive == "" {
+				continue
+			}
+			RunCacheImport(chain.ChainID, chain.CacheArchive)
+		}
+	}
+}
+
+// backupChains returns every chain's sync watermark, with the cache checkpoints of the chains in
+// config.yaml whose caches open
+func backupChains(ctx context.Context, conn driver.Conn) ([]BackupChain, error) {
+	rows, err := conn.Query(ctx, "SELECT chain_id, block_number FROM sync_watermark ORDER BY chain_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sync_watermark: %w", err)
+	}
+	defer rows.Close()
+
+	var chains []BackupChain
+	for rows.Next() {
+		var chain BackupChain
+		if err := rows.Scan(&chain.ChainID, &chain.Watermark); err != nil {
+			return nil, fmt.Errorf("failed to scan watermark: %w", err)
+		}
+		chains = append(chains, chain)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	configs, err := LoadConfig(configPath)
+	if err != nil {
+		slog.Warn("Not recording cache checkpoints, failed to load config", "error", err)
+		return chains, nil
+	}
+	for i, chain := range chains {
+		for _, cfg := range configs {
+			if cfg.ChainID != chain.ChainID {
+				continue
+			}
+			// A cache held by a running ingest only opens with --cache-read-only
+			c, err := cache.New(cacheDir(cfg), cfg.ChainID)
+			if err != nil {
+				slog.Warn("Not recording cache checkpoint, failed to open cache", "chain_id", cfg.ChainID, "error", err)
+				continue
+			}
+			chains[i].CacheCheckpoint, err = c.GetCheckpoint()
+			c.Close()
+			if err != nil {
+				slog.Warn("Not recording cache checkpoint, failed to read it", "chain_id", cfg.ChainID, "error", err)
+			}
+		}
+	}
+	return chains, nil
+}
+
+// exportBackupCaches writes the cache of each chain with a checkpoint to an archive in dir, up to
+// the checkpoint recorded in the manifest
+func exportBackupCaches(manifest *BackupManifest, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	for i, chain := range manifest.Chains {
+		if chain.CacheCheckpoint == 0 {
+			continue
+		}
+		out, err := filepath.Abs(filepath.Join(dir, fmt.Sprintf("%s-chain-%d.tar.zst", manifest.Name, chain.ChainID)))
+		if err != nil {
+			return err
+		}
+		RunCacheExport(chain.ChainID, out, 0, chain.CacheCheckpoint)
+		manifest.Chains[i].CacheArchive = out
+	}
+	return nil
+}
+
+// mergeTreeTables returns the MergeTree tables of the current database, the ones BACKUP copies
+// data of. Views are recreated by ingest.
+func mergeTreeTables(ctx context.Context, conn driver.Conn) ([]string, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT name FROM system.tables
+		WHERE database = currentDatabase() AND engine LIKE '%MergeTree'
+		ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan table: %w", err)
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// backupDestination returns the Disk or S3 destination of a backup for BACKUP and RESTORE, and its
+// arguments. S3 credentials come from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, without them
+// ClickHouse uses its own S3 configuration.
+func backupDestination(manifest BackupManifest) (string, []any) {
+	if manifest.Disk != "" {
+		return "Disk(?, ?)", []any{manifest.Disk, manifest.Name}
+	}
+	url := manifest.S3URL + "/" + manifest.Name
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" {
+		return "S3(?)", []any{url}
+	}
+	return "S3(?, ?, ?)", []any{url, accessKey, secretKey}
+}
+
+// backupLocation describes where a backup is, without credentials
+func backupLocation(manifest BackupManifest) string {
+	if manifest.Disk != "" {
+		return fmt.Sprintf("disk %s at %s", manifest.Disk, manifest.Name)
+	}
+	return manifest.S3URL + "/" + manifest.Name
+}
+
+// runBackupOperation starts an ASYNC BACKUP or RESTORE and waits until it reaches done, so large
+// backups don't run into the connection's read timeout
+func runBackupOperation(ctx context.Context, conn driver.Conn, query string, args []any, done string) error {
+	var id, status string
+	if err := conn.QueryRow(ctx, query, args...).Scan(&id, &status); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(backupPollInterval)
+	defer ticker.Stop()
+	for {
+		var errorMessage string
+		var numFiles uint64
+		err := conn.QueryRow(ctx, "SELECT toString(status), error, num_files FROM system.backups WHERE id = ?", id).
+			Scan(&status, &errorMessage, &numFiles)
+		if err != nil {
+			return fmt.Errorf("failed to check status of %s: %w", id, err)
+		}
+		switch {
+		case status == done:
+			return nil
+		case strings.HasSuffix(status, "_FAILED") || strings.HasSuffix(status, "_CANCELLED"):
+			return fmt.Errorf("%s: %s", strings.ToLower(status), errorMessage)
+		}
+		fmt.Printf("  %s, %s files\n", strings.ToLower(status), humanize.Comma(int64(numFiles)))
+		<-ticker.C
+	}
+}
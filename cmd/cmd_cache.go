@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"icicle/pkg/cache"
 	"icicle/pkg/evmrpc"
+	"icicle/pkg/logging"
 	"icicle/pkg/pchainrpc"
-	"fmt"
-	"log"
+	"log/slog"
+	"path/filepath"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -14,16 +18,16 @@ import (
 )
 
 func RunCache() {
-	log.Println("Starting cache-only mode (no ClickHouse)...")
+	slog.Info("Starting cache-only mode (no ClickHouse)")
 
 	// Load configuration from YAML
-	configs, err := LoadConfig("config.yaml")
+	configs, err := LoadConfig(configPath)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		logging.Fatal(slog.Default(), "Failed to load config", "error", err)
 	}
 
 	if len(configs) == 0 {
-		log.Fatal("No chain configurations found in config.yaml")
+		logging.Fatal(slog.Default(), "No chain configurations found in the config", "path", configPath)
 	}
 
 	var wg sync.WaitGroup
@@ -41,12 +45,12 @@ func RunCache() {
 			case "p":
 				err = runPChainCache(chainCfg)
 			default:
-				log.Printf("[Chain %d] Unsupported VM type: %s", chainCfg.ChainID, chainCfg.VM)
+				slog.Error("Unsupported VM type", "chain_id", chainCfg.ChainID, "vm", chainCfg.VM)
 				return
 			}
 
 			if err != nil {
-				log.Printf("[Chain %d - %s] Cache failed: %v", chainCfg.ChainID, chainCfg.Name, err)
+				logging.Fatal(logging.Chain("cache", chainCfg.ChainID, chainCfg.Name), "Cache failed", "error", err)
 			}
 		}(cfg)
 	}
@@ -64,9 +68,10 @@ func runEVMCache(cfg ChainConfig) error {
 	if fetchBatchSize == 0 {
 		fetchBatchSize = 1000
 	}
+	logger := logging.Chain("cache", cfg.ChainID, cfg.Name)
 
-	log.Printf("[Chain %d - %s] Creating cache at ./rpc_cache/%d", cfg.ChainID, cfg.Name, cfg.ChainID)
-	cacheInstance, err := cache.New("./rpc_cache", cfg.ChainID)
+	logger.Info("Creating cache", "path", filepath.Join(cacheDir(cfg), fmt.Sprintf("%d", cfg.ChainID)))
+	cacheInstance, err := cache.New(cacheDir(cfg), cfg.ChainID)
 	if err != nil {
 		return fmt.Errorf("failed to create cache: %w", err)
 	}
@@ -79,13 +84,15 @@ func runEVMCache(cfg ChainConfig) error {
 	}
 
 	if checkpoint > 0 {
-		log.Printf("[Chain %d - %s] Found checkpoint at block %d, resuming from there", cfg.ChainID, cfg.Name, checkpoint)
+		logger.Info("Found checkpoint, resuming from there", "block", checkpoint)
 	}
 
-	log.Printf("[Chain %d - %s] Creating fetcher with concurrency=%d, batchSize=%d",
-		cfg.ChainID, cfg.Name, maxConcurrency, fetchBatchSize)
+	logger.Info("Creating fetcher", "concurrency", maxConcurrency, "batch_size", fetchBatchSize)
 	fetcher := evmrpc.NewFetcher(evmrpc.FetcherOptions{
 		RpcURL:         cfg.RpcURL,
+		TraceRpcURL:    cfg.TraceRpcURL,
+		RpcHeaders:     rpcHeaders(cfg),
+		NoCompression:  cfg.RpcCompression != nil && !*cfg.RpcCompression,
 		ChainID:        cfg.ChainID,
 		ChainName:      cfg.Name,
 		MaxConcurrency: maxConcurrency,
@@ -94,6 +101,7 @@ func runEVMCache(cfg ChainConfig) error {
 		BatchSize:      fetchBatchSize,
 		DebugBatchSize: 1,
 		Cache:          cacheInstance,
+		CacheTTLs:      cacheTTLs(cfg),
 	})
 	defer fetcher.Close()
 
@@ -118,19 +126,17 @@ func runEVMCache(cfg ChainConfig) error {
 	endBlock := latestBlock
 
 	if startBlock > endBlock {
-		log.Printf("[Chain %d - %s] Already caught up to block %d, nothing to do",
-			cfg.ChainID, cfg.Name, endBlock)
+		logger.Info("Already caught up, nothing to do", "block", endBlock)
 		select {} // Block forever
 	}
 
 	totalBlocks := endBlock - originalStartBlock + 1
 	remainingBlocks := endBlock - startBlock + 1
 	if checkpoint > 0 {
-		log.Printf("[Chain %d - %s] Resuming: caching blocks %d to %d (%s blocks remaining, %s total)",
-			cfg.ChainID, cfg.Name, startBlock, endBlock, humanize.Comma(remainingBlocks), humanize.Comma(totalBlocks))
+		logger.Info("Resuming caching", "from", startBlock, "to", endBlock,
+			"remaining", humanize.Comma(remainingBlocks), "total", humanize.Comma(totalBlocks))
 	} else {
-		log.Printf("[Chain %d - %s] Caching blocks %d to %d (%s total blocks)",
-			cfg.ChainID, cfg.Name, startBlock, endBlock, humanize.Comma(totalBlocks))
+		logger.Info("Caching blocks", "from", startBlock, "to", endBlock, "total", humanize.Comma(totalBlocks))
 	}
 
 	// Progress tracking
@@ -154,15 +160,16 @@ func runEVMCache(cfg ChainConfig) error {
 				progress := float64(totalCachedSoFar) / float64(totalBlocks) * 100
 
 				blocksRemaining := totalBlocks - totalCachedSoFar
-				var etaStr string
+				var eta time.Duration
 				if rate > 0 && blocksRemaining > 0 {
 					etaSeconds := float64(blocksRemaining) / rate
-					etaDuration := time.Duration(etaSeconds * float64(time.Second))
-					etaStr = fmt.Sprintf(" | ETA: %s", etaDuration.Round(time.Second))
+					eta = time.Duration(etaSeconds * float64(time.Second)).Round(time.Second)
 				}
 
-				log.Printf("[Chain %d - %s] Progress: %s/%s blocks (%.1f%%) | Rate: %.1f blocks/sec | Elapsed: %s%s",
-					cfg.ChainID, cfg.Name, humanize.Comma(totalCachedSoFar), humanize.Comma(totalBlocks), progress, rate, elapsed.Round(time.Second), etaStr)
+				logger.Info("Progress",
+					"cached", humanize.Comma(totalCachedSoFar), "total", humanize.Comma(totalBlocks),
+					"percent", fmt.Sprintf("%.1f", progress), "rate", fmt.Sprintf("%.1f blocks/sec", rate),
+					"elapsed", elapsed.Round(time.Second), "eta", eta)
 			case <-done:
 				return
 			}
@@ -182,6 +189,10 @@ func runEVMCache(cfg ChainConfig) error {
 	checkpointInterval := int64(1000) // Save checkpoint every 1k blocks
 	lastCheckpoint := highestBlock
 
+	// Heights of the blocks that failed to fetch, retried once all chunks are done
+	var failedMu sync.Mutex
+	var failed []int64
+
 	for current := startBlock; current <= endBlock; {
 		batchEnd := current + chunkSize - 1
 		if batchEnd > endBlock {
@@ -195,13 +206,29 @@ func runEVMCache(cfg ChainConfig) error {
 			defer fetchWg.Done()
 			defer func() { <-semaphore }()
 
-			blocks, err := fetcher.FetchBlockRange(from, to)
-			if err != nil {
-				log.Printf("[Chain %d - %s] Error fetching blocks %d-%d: %v", cfg.ChainID, cfg.Name, from, to, err)
+			blocks, err := fetcher.FetchBlockRangePartial(from, to)
+			var partial *evmrpc.PartialError
+			if errors.As(err, &partial) {
+				logger.Warn("Blocks failed to fetch, retrying them at the end", "from", from, "to", to,
+					"failed", len(partial.Failed), "error", err)
+				failedMu.Lock()
+				failed = append(failed, partial.Heights()...)
+				failedMu.Unlock()
+			} else if err != nil {
+				logger.Warn("Error fetching blocks, retrying them at the end", "from", from, "to", to, "error", err)
+				failedMu.Lock()
+				for height := from; height <= to; height++ {
+					failed = append(failed, height)
+				}
+				failedMu.Unlock()
 				return
 			}
 
-			blocksCached.Add(int64(len(blocks)))
+			for _, block := range blocks {
+				if block != nil {
+					blocksCached.Add(1)
+				}
+			}
 
 			// Update highest block and checkpoint if needed
 			highestBlockMu.Lock()
@@ -211,12 +238,8 @@ func runEVMCache(cfg ChainConfig) error {
 
 			// Save checkpoint every interval
 			if highestBlock-lastCheckpoint >= checkpointInterval {
-				if err := cacheInstance.SetCheckpoint(highestBlock); err != nil {
-					log.Printf("[Chain %d - %s] Failed to save checkpoint at block %d: %v", cfg.ChainID, cfg.Name, highestBlock, err)
-				} else {
-					log.Printf("[Chain %d - %s] Checkpoint saved at block %s", cfg.ChainID, cfg.Name, humanize.Comma(int64(highestBlock)))
-					lastCheckpoint = highestBlock
-				}
+				saveCacheCheckpoint(cacheInstance, originalStartBlock, logger)
+				lastCheckpoint = highestBlock
 			}
 			highestBlockMu.Unlock()
 		}(current, batchEnd)
@@ -225,27 +248,35 @@ func runEVMCache(cfg ChainConfig) error {
 	}
 
 	fetchWg.Wait()
-	close(done)
 
-	// Save checkpoint after initial sync
-	if err := cacheInstance.SetCheckpoint(endBlock); err != nil {
-		log.Printf("[Chain %d - %s] Failed to save checkpoint: %v", cfg.ChainID, cfg.Name, err)
-	} else {
-		log.Printf("[Chain %d - %s] Checkpoint saved at block %d", cfg.ChainID, cfg.Name, endBlock)
+	if len(failed) > 0 {
+		sort.Slice(failed, func(i, j int) bool { return failed[i] < failed[j] })
+		logger.Info("Retrying blocks that failed to fetch", "blocks", len(failed))
+		fetched, err := fetcher.FetchBlocks(failed)
+		blocksCached.Add(int64(len(fetched)))
+		if err != nil {
+			close(done)
+			saveCacheCheckpoint(cacheInstance, originalStartBlock, logger)
+			return fmt.Errorf("failed to fetch %d blocks, the checkpoint stops before the first: %w", len(failed)-len(fetched), err)
+		}
 	}
+	close(done)
+
+	// Save checkpoint after initial sync, up to the first block still missing
+	saveCacheCheckpoint(cacheInstance, originalStartBlock, logger)
 
 	elapsed := time.Since(startTime)
 	finalCount := blocksCached.Load()
 	avgRate := float64(finalCount) / elapsed.Seconds()
 
-	log.Printf("[Chain %d - %s] ✓ Initial sync complete: cached %d blocks in %s (avg %.1f blocks/sec)",
-		cfg.ChainID, cfg.Name, finalCount, elapsed.Round(time.Second), avgRate)
+	logger.Info("Initial sync complete", "blocks", finalCount, "elapsed", elapsed.Round(time.Second),
+		"avg_rate", fmt.Sprintf("%.1f blocks/sec", avgRate))
 
 	// Show cache metrics
-	log.Printf("[Chain %d - %s] Cache metrics:\n%s", cfg.ChainID, cfg.Name, cacheInstance.GetMetrics())
+	logger.Info("Cache metrics", "metrics", cacheInstance.GetMetrics())
 
 	// Done caching, block forever
-	log.Printf("[Chain %d - %s] ✓ Cache complete, nothing more to do", cfg.ChainID, cfg.Name)
+	logger.Info("Cache complete, nothing more to do")
 	select {} // Block forever
 }
 
@@ -259,9 +290,10 @@ func runPChainCache(cfg ChainConfig) error {
 	if fetchBatchSize == 0 {
 		fetchBatchSize = 1000
 	}
+	logger := logging.Chain("cache", cfg.ChainID, cfg.Name)
 
-	log.Printf("[Chain %d - %s] Creating cache at ./rpc_cache/%d", cfg.ChainID, cfg.Name, cfg.ChainID)
-	cacheInstance, err := cache.New("./rpc_cache", cfg.ChainID)
+	logger.Info("Creating cache", "path", filepath.Join(cacheDir(cfg), fmt.Sprintf("%d", cfg.ChainID)))
+	cacheInstance, err := cache.New(cacheDir(cfg), cfg.ChainID)
 	if err != nil {
 		return fmt.Errorf("failed to create cache: %w", err)
 	}
@@ -274,18 +306,23 @@ func runPChainCache(cfg ChainConfig) error {
 	}
 
 	if checkpoint > 0 {
-		log.Printf("[Chain %d - %s] Found checkpoint at block %d, resuming from there", cfg.ChainID, cfg.Name, checkpoint)
+		logger.Info("Found checkpoint, resuming from there", "block", checkpoint)
 	}
 
-	log.Printf("[Chain %d - %s] Creating fetcher with concurrency=%d, batchSize=%d",
-		cfg.ChainID, cfg.Name, maxConcurrency, fetchBatchSize)
+	logger.Info("Creating fetcher", "concurrency", maxConcurrency, "batch_size", fetchBatchSize)
 	fetcher := pchainrpc.NewFetcher(pchainrpc.FetcherOptions{
 		RpcURL:         cfg.RpcURL,
+		RpcHeaders:     rpcHeaders(cfg),
+		NoCompression:  cfg.RpcCompression != nil && !*cfg.RpcCompression,
+		ChainID:        cfg.ChainID,
+		ChainName:      cfg.Name,
 		MaxConcurrency: maxConcurrency,
 		MaxRetries:     100,
 		RetryDelay:     100 * time.Millisecond,
 		BatchSize:      fetchBatchSize,
+		RpcBatchSize:   cfg.RpcBatchSize,
 		Cache:          cacheInstance,
+		CacheTTLs:      cacheTTLs(cfg),
 	})
 	defer fetcher.Close()
 
@@ -310,19 +347,17 @@ func runPChainCache(cfg ChainConfig) error {
 	endBlock := latestBlock
 
 	if startBlock > endBlock {
-		log.Printf("[Chain %d - %s] Already caught up to block %d, nothing to do",
-			cfg.ChainID, cfg.Name, endBlock)
+		logger.Info("Already caught up, nothing to do", "block", endBlock)
 		select {} // Block forever
 	}
 
 	totalBlocks := endBlock - originalStartBlock + 1
 	remainingBlocks := endBlock - startBlock + 1
 	if checkpoint > 0 {
-		log.Printf("[Chain %d - %s] Resuming: caching blocks %d to %d (%s blocks remaining, %s total)",
-			cfg.ChainID, cfg.Name, startBlock, endBlock, humanize.Comma(remainingBlocks), humanize.Comma(totalBlocks))
+		logger.Info("Resuming caching", "from", startBlock, "to", endBlock,
+			"remaining", humanize.Comma(remainingBlocks), "total", humanize.Comma(totalBlocks))
 	} else {
-		log.Printf("[Chain %d - %s] Caching blocks %d to %d (%s total blocks)",
-			cfg.ChainID, cfg.Name, startBlock, endBlock, humanize.Comma(totalBlocks))
+		logger.Info("Caching blocks", "from", startBlock, "to", endBlock, "total", humanize.Comma(totalBlocks))
 	}
 
 	// Progress tracking
@@ -346,15 +381,16 @@ func runPChainCache(cfg ChainConfig) error {
 				progress := float64(totalCachedSoFar) / float64(totalBlocks) * 100
 
 				blocksRemaining := totalBlocks - totalCachedSoFar
-				var etaStr string
+				var eta time.Duration
 				if rate > 0 && blocksRemaining > 0 {
 					etaSeconds := float64(blocksRemaining) / rate
-					etaDuration := time.Duration(etaSeconds * float64(time.Second))
-					etaStr = fmt.Sprintf(" | ETA: %s", etaDuration.Round(time.Second))
+					eta = time.Duration(etaSeconds * float64(time.Second)).Round(time.Second)
 				}
 
-				log.Printf("[Chain %d - %s] Progress: %s/%s blocks (%.1f%%) | Rate: %.1f blocks/sec | Elapsed: %s%s",
-					cfg.ChainID, cfg.Name, humanize.Comma(totalCachedSoFar), humanize.Comma(totalBlocks), progress, rate, elapsed.Round(time.Second), etaStr)
+				logger.Info("Progress",
+					"cached", humanize.Comma(totalCachedSoFar), "total", humanize.Comma(totalBlocks),
+					"percent", fmt.Sprintf("%.1f", progress), "rate", fmt.Sprintf("%.1f blocks/sec", rate),
+					"elapsed", elapsed.Round(time.Second), "eta", eta)
 			case <-done:
 				return
 			}
@@ -374,6 +410,10 @@ func runPChainCache(cfg ChainConfig) error {
 	checkpointInterval := int64(100000) // Save checkpoint every 10k blocks
 	lastCheckpoint := highestBlock
 
+	// Ranges that failed to fetch, retried once all chunks are done
+	var failedMu sync.Mutex
+	var failedRanges [][2]int64
+
 	for current := startBlock; current <= endBlock; {
 		batchEnd := current + chunkSize - 1
 		if batchEnd > endBlock {
@@ -389,7 +429,10 @@ func runPChainCache(cfg ChainConfig) error {
 
 			blocks, err := fetcher.FetchBlockRange(from, to)
 			if err != nil {
-				log.Printf("[Chain %d - %s] Error fetching blocks %d-%d: %v", cfg.ChainID, cfg.Name, from, to, err)
+				logger.Warn("Error fetching blocks, retrying them at the end", "from", from, "to", to, "error", err)
+				failedMu.Lock()
+				failedRanges = append(failedRanges, [2]int64{from, to})
+				failedMu.Unlock()
 				return
 			}
 
@@ -403,12 +446,8 @@ func runPChainCache(cfg ChainConfig) error {
 
 			// Save checkpoint every interval
 			if highestBlock-lastCheckpoint >= checkpointInterval {
-				if err := cacheInstance.SetCheckpoint(highestBlock); err != nil {
-					log.Printf("[Chain %d - %s] Failed to save checkpoint at block %d: %v", cfg.ChainID, cfg.Name, highestBlock, err)
-				} else {
-					log.Printf("[Chain %d - %s] Checkpoint saved at block %s", cfg.ChainID, cfg.Name, humanize.Comma(int64(highestBlock)))
-					lastCheckpoint = highestBlock
-				}
+				saveCacheCheckpoint(cacheInstance, originalStartBlock, logger)
+				lastCheckpoint = highestBlock
 			}
 			highestBlockMu.Unlock()
 		}(current, batchEnd)
@@ -417,26 +456,47 @@ func runPChainCache(cfg ChainConfig) error {
 	}
 
 	fetchWg.Wait()
-	close(done)
 
-	// Save checkpoint after initial sync
-	if err := cacheInstance.SetCheckpoint(endBlock); err != nil {
-		log.Printf("[Chain %d - %s] Failed to save checkpoint: %v", cfg.ChainID, cfg.Name, err)
-	} else {
-		log.Printf("[Chain %d - %s] Checkpoint saved at block %d", cfg.ChainID, cfg.Name, endBlock)
+	if len(failedRanges) > 0 {
+		sort.Slice(failedRanges, func(i, j int) bool { return failedRanges[i][0] < failedRanges[j][0] })
+		logger.Info("Retrying ranges that failed to fetch", "ranges", len(failedRanges))
+		for _, r := range failedRanges {
+			blocks, err := fetcher.FetchBlockRange(r[0], r[1])
+			if err != nil {
+				close(done)
+				saveCacheCheckpoint(cacheInstance, originalStartBlock, logger)
+				return fmt.Errorf("failed to fetch blocks %d-%d, the checkpoint stops before them: %w", r[0], r[1], err)
+			}
+			blocksCached.Add(int64(len(blocks)))
+		}
 	}
+	close(done)
+
+	// Save checkpoint after initial sync, up to the first block still missing
+	saveCacheCheckpoint(cacheInstance, originalStartBlock, logger)
 
 	elapsed := time.Since(startTime)
 	finalCount := blocksCached.Load()
 	avgRate := float64(finalCount) / elapsed.Seconds()
 
-	log.Printf("[Chain %d - %s] ✓ Initial sync complete: cached %d blocks in %s (avg %.1f blocks/sec)",
-		cfg.ChainID, cfg.Name, finalCount, elapsed.Round(time.Second), avgRate)
+	logger.Info("Initial sync complete", "blocks", finalCount, "elapsed", elapsed.Round(time.Second),
+		"avg_rate", fmt.Sprintf("%.1f blocks/sec", avgRate))
 
 	// Show cache metrics
-	log.Printf("[Chain %d - %s] Cache metrics:\n%s", cfg.ChainID, cfg.Name, cacheInstance.GetMetrics())
+	logger.Info("Cache metrics", "metrics", cacheInstance.GetMetrics())
 
 	// Done caching, block forever
-	log.Printf("[Chain %d - %s] ✓ Cache complete, nothing more to do", cfg.ChainID, cfg.Name)
+	logger.Info("Cache complete, nothing more to do")
 	select {} // Block forever
 }
+
+// saveCacheCheckpoint advances the cache checkpoint over the blocks cached without a gap since
+// startBlock, so a restart resumes at the first block that failed or is still being fetched
+func saveCacheCheckpoint(c cache.Cache, startBlock int64, logger *slog.Logger) {
+	checkpoint, err := extendCheckpoint(c, startBlock)
+	if err != nil {
+		logger.Error("Failed to save checkpoint", "error", err)
+		return
+	}
+	logger.Info("Checkpoint saved", "block", checkpoint)
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"icicle/pkg/cache"
+	"icicle/pkg/logging"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// RunCacheExport writes a chain's cached blocks in [from, to] to a portable .tar.zst archive.
+// to defaults to the cache checkpoint.
+func RunCacheExport(chainID uint32, out string, from, to int64) {
+	if chainID == 0 || out == "" {
+		logging.Fatal(slog.Default(), "--chain and --out are required")
+	}
+
+	c, err := cache.New(chainCacheDir(chainID), chainID)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to open cache", "chain_id", chainID, "error", err)
+	}
+	defer c.Close()
+
+	if to == 0 {
+		if to, err = c.GetCheckpoint(); err != nil {
+			logging.Fatal(slog.Default(), "Failed to read checkpoint", "chain_id", chainID, "error", err)
+		}
+		if to == 0 {
+			logging.Fatal(slog.Default(), "Cache has no checkpoint, pass --to", "chain_id", chainID)
+		}
+	}
+
+	// Write next to the destination and rename, so a failed export never looks complete
+	tmp := out + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to create archive", "path", tmp, "error", err)
+	}
+
+	fmt.Printf("Exporting chain %d blocks %d-%d to %s...\n", chainID, from, to, out)
+	start := time.Now()
+	stats, err := cache.Export(c, chainID, file, from, to, cache.ArchiveChunkSize, printArchiveProgress(start))
+	if err == nil {
+		err = file.Close()
+	} else {
+		file.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp, out)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		logging.Fatal(slog.Default(), "Failed to export cache", "chain_id", chainID, "error", err)
+	}
+
+	size := int64(0)
+	if info, err := os.Stat(out); err == nil {
+		size = info.Size()
+	}
+	fmt.Printf("Exported %s blocks (%s, %s compressed) in %s\n", humanize.Comma(stats.Blocks),
+		humanize.Bytes(uint64(stats.Bytes)), humanize.Bytes(uint64(size)), time.Since(start).Round(time.Second))
+}
+
+// RunCacheImport loads an archive written by cache export into the chain's cache
+func RunCacheImport(chainID uint32, in string) {
+	if chainID == 0 || in == "" {
+		logging.Fatal(slog.Default(), "--chain and --in are required")
+	}
+
+	file, err := os.Open(in)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to open archive", "path", in, "error", err)
+	}
+	defer file.Close()
+
+	c, err := cache.New(chainCacheDir(chainID), chainID)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to open cache", "chain_id", chainID, "error", err)
+	}
+	defer c.Close()
+
+	fmt.Printf("Importing %s into chain %d cache...\n", in, chainID)
+	start := time.Now()
+	manifest, stats, err := cache.Import(c, chainID, file, printArchiveProgress(start))
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to import cache", "chain_id", chainID, "error", err)
+	}
+	fmt.Printf("Imported %s blocks (%s) of range %d-%d in %s\n", humanize.Comma(stats.Blocks),
+		humanize.Bytes(uint64(stats.Bytes)), manifest.From, manifest.To, time.Since(start).Round(time.Second))
+}
+
+// printArchiveProgress returns a progress callback printing at most every 5 seconds
+func printArchiveProgress(start time.Time) func(cache.ArchiveStats) {
+	last := start
+	return func(stats cache.ArchiveStats) {
+		if time.Since(last) < 5*time.Second {
+			return
+		}
+		last = time.Now()
+		fmt.Printf("  %s blocks, %s (%.0f blocks/sec)\n", humanize.Comma(stats.Blocks),
+			humanize.Bytes(uint64(stats.Bytes)), float64(stats.Blocks)/time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"icicle/pkg/cache"
+	"icicle/pkg/loadshed"
+	"icicle/pkg/logging"
+	"log/slog"
+	"time"
+)
+
+// RunCacheCompact compacts each chain's RPC cache now, or only chainID's if set
+func RunCacheCompact(chainID uint32, opts cache.CompactOptions) {
+	configs, err := LoadConfig(configPath)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to load config", "error", err)
+	}
+
+	found := false
+	for _, cfg := range configs {
+		if chainID != 0 && cfg.ChainID != chainID {
+			continue
+		}
+		found = true
+
+		if err := compactChainCache(cfg, opts); err != nil {
+			logging.Fatal(slog.Default(), "Failed to compact cache", "chain_id", cfg.ChainID, "error", err)
+		}
+	}
+
+	if chainID != 0 && !found {
+		logging.Fatal(slog.Default(), "Chain not found in the config", "path", configPath, "chain_id", chainID)
+	}
+}
+
+// compactChainCache opens a chain's cache and compacts it
+func compactChainCache(cfg ChainConfig, opts cache.CompactOptions) error {
+	chainID := cfg.ChainID
+	c, err := cache.New(cacheDir(cfg), chainID)
+	if err != nil {
+		return fmt.Errorf("failed to open cache: %w", err)
+	}
+	defer c.Close()
+
+	fmt.Printf("Compacting cache for chain %d...\n", chainID)
+	start := time.Now()
+	if err := c.Compact(opts); err != nil {
+		return err
+	}
+	fmt.Printf("Chain %d compacted in %s\n", chainID, time.Since(start).Round(time.Second))
+	return nil
+}
+
+// compactCachePeriodically compacts a cache on the schedule of cfg, postponing steps while
+// shedder is degraded
+func compactCachePeriodically(c cache.Cache, cfg CacheCompaction, shedder *loadshed.Monitor, logger *slog.Logger) {
+	opts := cfg.Options(shedder)
+	ticker := time.NewTicker(time.Duration(cfg.IntervalHours * float64(time.Hour)))
+	defer ticker.Stop()
+
+	for range ticker.C {
+		start := time.Now()
+		if err := c.Compact(opts); err != nil {
+			logger.Warn("Failed to compact cache", "error", err)
+			continue
+		}
+		logger.Info("Compacted cache", "duration", time.Since(start).Round(time.Second))
+	}
+}
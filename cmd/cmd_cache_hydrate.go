@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"icicle/pkg/cache"
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/logging"
+	"log/slog"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/dustin/go-humanize"
+)
+
+// hydrateChunkSize is the number of blocks read from raw_payloads per query
+const hydrateChunkSize = 1000
+
+// RunCacheHydrate rebuilds a chain's RPC cache from the payloads ingest stored in raw_payloads
+// (storePayloads), so a fresh machine doesn't re-download them from RPC. from defaults to the
+// first block after the cache checkpoint and to to the last stored payload.
+func RunCacheHydrate(chainID uint32, from, to int64) {
+	configs, err := LoadConfig(configPath)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to load config", "error", err)
+	}
+
+	var cfg *ChainConfig
+	for i := range configs {
+		if configs[i].ChainID == chainID {
+			cfg = &configs[i]
+			break
+		}
+	}
+	if cfg == nil {
+		logging.Fatal(slog.Default(), "Chain not found in the config", "path", configPath, "chain_id", chainID)
+	}
+	if cfg.VM != "evm" && cfg.VM != "p" {
+		logging.Fatal(slog.Default(), "cache hydrate only supports EVM chains and the P-chain", "chain_id", chainID, "vm", cfg.VM)
+	}
+
+	conn, err := chwrapper.Connect()
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to connect", "error", err)
+	}
+	defer conn.Close()
+
+	c, err := cache.New(cacheDir(*cfg), chainID)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to open cache", "chain_id", chainID, "error", err)
+	}
+	defer c.Close()
+
+	checkpoint, err := c.GetCheckpoint()
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to read checkpoint", "chain_id", chainID, "error", err)
+	}
+
+	// First block the cache is missing: fill resumes after the checkpoint, or at startBlock when empty
+	next := max(checkpoint, cfg.StartBlock-1, 0) + 1
+	if from == 0 {
+		from = next
+	}
+	if to == 0 {
+		latest, err := chwrapper.GetLatestBlockForChain(conn, "raw_payloads", chainID)
+		if err != nil {
+			logging.Fatal(slog.Default(), "Failed to read stored payloads", "chain_id", chainID, "error", err)
+		}
+		if latest == 0 {
+			logging.Fatal(slog.Default(), "No payloads stored for chain, enable storePayloads and ingest first", "chain_id", chainID)
+		}
+		to = int64(latest)
+	}
+	if from > to {
+		fmt.Printf("Cache of chain %d already has blocks up to %d, nothing to hydrate\n", chainID, to)
+		return
+	}
+
+	fmt.Printf("Hydrating chain %d cache with blocks %d-%d from ClickHouse...\n", chainID, from, to)
+	start := time.Now()
+	progress := printArchiveProgress(start)
+	var stats cache.ArchiveStats
+
+	for chunkFrom := from; chunkFrom <= to; chunkFrom += hydrateChunkSize {
+		chunkTo := min(chunkFrom+hydrateChunkSize-1, to)
+		if err := hydrateChunk(conn, c, chainID, chunkFrom, chunkTo, &next, &stats); err != nil {
+			logging.Fatal(slog.Default(), "Failed to hydrate cache", "chain_id", chainID, "from", chunkFrom, "error", err)
+		}
+
+		// Advance the checkpoint while hydrated blocks continue the cached prefix
+		if next-1 > checkpoint {
+			if err := c.SetCheckpoint(next - 1); err != nil {
+				logging.Fatal(slog.Default(), "Failed to save checkpoint", "chain_id", chainID, "error", err)
+			}
+			checkpoint = next - 1
+		}
+		progress(stats)
+	}
+
+	fmt.Printf("Hydrated %s blocks (%s) in %s, checkpoint %d\n", humanize.Comma(stats.Blocks),
+		humanize.Bytes(uint64(stats.Bytes)), time.Since(start).Round(time.Second), checkpoint)
+	if missing := to - from + 1 - stats.Blocks; missing > 0 {
+		fmt.Printf("%s blocks in range had no stored payload, cache fill or ingest fetches them from RPC\n", humanize.Comma(missing))
+	}
+}
+
+// hydrateChunk stores the payloads of blocks [from, to] in c, keeping blocks c already has.
+// next is advanced past each block that directly follows it.
+func hydrateChunk(conn driver.Conn, c cache.Cache, chainID uint32, from, to int64, next *int64, stats *cache.ArchiveStats) error {
+	rows, err := conn.Query(context.Background(), `
+		SELECT block_number, data FROM raw_payloads FINAL
+		WHERE chain_id = ? AND block_number BETWEEN ? AND ?
+		ORDER BY block_number`, chainID, uint32(from), uint32(to))
+	if err != nil {
+		return fmt.Errorf("failed to query payloads: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var blockNum uint32
+		var data string
+		if err := rows.Scan(&blockNum, &data); err != nil {
+			return fmt.Errorf("failed to scan payload: %w", err)
+		}
+
+		payload := []byte(data)
+		if _, err := c.GetCompleteBlock(int64(blockNum), func() ([]byte, error) {
+			return payload, nil
+		}); err != nil {
+			return fmt.Errorf("failed to store block %d: %w", blockNum, err)
+		}
+		stats.Blocks++
+		stats.Bytes += int64(len(payload))
+
+		if int64(blockNum) == *next {
+			*next++
+		}
+	}
+
+	return rows.Err()
+}
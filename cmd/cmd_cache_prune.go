@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"icicle/pkg/cache"
+	"icicle/pkg/logging"
+	"log/slog"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// cachePruneInterval is how often ingest applies cacheRetention in the background
+const cachePruneInterval = time.Hour
+
+// RunCachePrune applies each chain's cacheRetention to its RPC cache now. With chainID set only
+// that chain is pruned, and a non-zero override replaces the configured retention.
+func RunCachePrune(chainID uint32, override cache.Retention) {
+	configs, err := LoadConfig(configPath)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to load config", "error", err)
+	}
+
+	found := false
+	for _, cfg := range configs {
+		if chainID != 0 && cfg.ChainID != chainID {
+			continue
+		}
+		found = true
+
+		retention := cfg.CacheRetention.Retention()
+		if !override.IsZero() {
+			retention = override
+		}
+		if retention.IsZero() {
+			fmt.Printf("Chain %d: no cacheRetention configured, skipping\n", cfg.ChainID)
+			continue
+		}
+
+		if err := pruneChainCache(cfg, retention); err != nil {
+			logging.Fatal(slog.Default(), "Failed to prune cache", "chain_id", cfg.ChainID, "error", err)
+		}
+	}
+
+	if chainID != 0 && !found {
+		logging.Fatal(slog.Default(), "Chain not found in the config", "path", configPath, "chain_id", chainID)
+	}
+}
+
+// pruneChainCache opens a chain's cache, prunes it and prints what was freed
+func pruneChainCache(cfg ChainConfig, retention cache.Retention) error {
+	cacheInstance, err := cache.New(cacheDir(cfg), cfg.ChainID)
+	if err != nil {
+		return fmt.Errorf("failed to open cache: %w", err)
+	}
+	defer cacheInstance.Close()
+
+	fmt.Printf("Pruning cache for chain %d...\n", cfg.ChainID)
+	result, err := cacheInstance.Prune(retention)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Chain %d: deleted blocks below %d and %s expired blocks, freed %s\n",
+		cfg.ChainID, result.BelowBlock, humanize.Comma(int64(result.ExpiredBlocks)), humanize.Bytes(result.FreedBytes))
+	return nil
+}
+
+// pruneCachePeriodically applies the retention policy to a cache every cachePruneInterval
+func pruneCachePeriodically(c cache.Cache, retention cache.Retention, logger *slog.Logger) {
+	ticker := time.NewTicker(cachePruneInterval)
+	defer ticker.Stop()
+
+	for {
+		result, err := c.Prune(retention)
+		if err != nil {
+			logger.Warn("Failed to prune cache, retention disabled", "error", err)
+			return
+		}
+		logger.Info("Pruned cache", "below_block", result.BelowBlock, "expired_blocks", result.ExpiredBlocks,
+			"freed", humanize.Bytes(result.FreedBytes))
+		<-ticker.C
+	}
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"icicle/pkg/cache"
+	"icicle/pkg/logging"
+	"log/slog"
+	"net"
+	"net/http"
+)
+
+// RunCacheServe serves the RPC caches of all chains over HTTP until the process is stopped, for
+// ingest instances started with --cache-server
+func RunCacheServe(addr, token string) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to start cache server listener", "addr", addr, "error", err)
+	}
+
+	server := cache.NewServer(cache.Dir(), chainCacheDirs(), token)
+	defer server.Close()
+
+	slog.Info("Serving RPC cache", "addr", listener.Addr().String(), "auth", token != "")
+	if err := http.Serve(listener, server.Handler()); err != nil {
+		logging.Fatal(slog.Default(), "Cache server stopped", "error", err)
+	}
+}
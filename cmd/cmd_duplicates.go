@@ -3,133 +3,192 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"icicle/pkg/chwrapper"
+	"icicle/pkg/logging"
 
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/dustin/go-humanize"
 	"github.com/fatih/color"
 )
 
-func RunDuplicates() {
+// duplicateCheck is a table and the columns that identify one of its rows
+type duplicateCheck struct {
+	table       string
+	key         string // Unique key, always covers the sorting key (required by DEDUPLICATE BY)
+	chainColumn string // Empty when the table isn't per chain
+	collapsing  bool   // Replacing/SummingMergeTree: duplicates are rows FINAL collapses
+}
+
+// mergeTreeChecks are the plain MergeTree tables ingest and indexers append to.
+// Nothing merges their duplicates away, so each row must be written once.
+var mergeTreeChecks = []duplicateCheck{
+	{table: "raw_blocks", key: "chain_id, block_number"},
+	{table: "raw_uncles", key: "chain_id, block_number, uncle_index"},
+	{table: "raw_withdrawals", key: "chain_id, block_number, withdrawal_index"},
+	{table: "raw_txs", key: "chain_id, block_number, hash"},
+	{table: "raw_traces", key: "chain_id, block_number, transaction_index, trace_address"},
+	{table: "raw_logs", key: "chain_id, block_time, address, topic0, transaction_hash, log_index"},
+	{table: "erc20_transfers", key: "chain_id, token, block_time, log_index, transaction_hash"},
+	{table: "nft_transfers", key: "chain_id, collection, block_time, log_index, batch_index, transaction_hash"},
+	{table: "contracts", key: "chain_id, address, block_number"},
+	{table: "icm_messages", key: "chain_id, block_time, message_id, transaction_hash, log_index"},
+	{table: "internal_txs", key: "chain_id, block_number, transaction_index, trace_address"},
+}
+
+// RunDuplicates counts duplicate rows in the raw, P-Chain, validator and calculated tables, for one
+// chain or all chains (chainID 0). ReplacingMergeTree and SummingMergeTree tables are found in
+// system.tables: their duplicates are rows not yet collapsed by a merge. With fix set, tables with
+// duplicates are rewritten with OPTIMIZE ... FINAL (DEDUPLICATE BY for MergeTree tables), which
+// always applies to the whole table and can take long on big tables.
+func RunDuplicates(chainID uint32, fix bool) {
 	conn, err := chwrapper.Connect()
 	if err != nil {
-		log.Fatalf("Failed to connect: %v", err)
+		logging.Fatal(slog.Default(), "Failed to connect", "error", err)
 	}
 	defer conn.Close()
 
 	ctx := context.Background()
-	chainID := uint32(43114)
-	allClean := true
 
-	// Check raw_blocks - unique key: (chain_id, block_number)
-	fmt.Printf("\nBlocks (Chain %d):\n", chainID)
-	fmt.Printf("--------------------------------\n")
-	var totalBlocks, duplicateBlocks uint64
-	err = conn.QueryRow(ctx, `
-		SELECT count() as total, countIf(cnt > 1) as duplicates
-		FROM (
-			SELECT chain_id, block_number, count() as cnt
-			FROM raw_blocks
-			WHERE chain_id = ?
-			GROUP BY chain_id, block_number
-		)
-	`, chainID).Scan(&totalBlocks, &duplicateBlocks)
+	checks := make([]duplicateCheck, 0, len(mergeTreeChecks))
+	for _, check := range mergeTreeChecks {
+		check.chainColumn = "chain_id"
+		checks = append(checks, check)
+	}
+	collapsing, err := collapsingTables(ctx, conn)
 	if err != nil {
-		log.Printf("Error querying blocks: %v", err)
-	} else {
-		fmt.Printf("Total blocks:     %d\n", totalBlocks)
-		fmt.Printf("Duplicates:       %d\n", duplicateBlocks)
-		if duplicateBlocks == 0 {
-			fmt.Printf("%s No duplicates\n", color.GreenString("✓"))
-		} else {
-			allClean = false
-			fmt.Printf("%s Found duplicates!\n", color.RedString("✗"))
-		}
+		logging.Fatal(slog.Default(), "Failed to list tables", "error", err)
 	}
+	checks = append(checks, collapsing...)
 
-	// Check raw_txs - unique key: (chain_id, hash)
-	fmt.Printf("\nTransactions (Chain %d):\n", chainID)
-	fmt.Printf("--------------------------------\n")
-	var totalTxs, duplicateTxs uint64
-	err = conn.QueryRow(ctx, `
-		SELECT count() as total, countIf(cnt > 1) as duplicates
-		FROM (
-			SELECT chain_id, hash, count() as cnt
-			FROM raw_txs
-			WHERE chain_id = ?
-			GROUP BY chain_id, hash
-		)
-	`, chainID).Scan(&totalTxs, &duplicateTxs)
-	if err != nil {
-		log.Printf("Error querying txs: %v", err)
+	if chainID == 0 {
+		fmt.Printf("\nDuplicates (all chains):\n")
 	} else {
-		fmt.Printf("Total txs:        %d\n", totalTxs)
-		fmt.Printf("Duplicates:       %d\n", duplicateTxs)
-		if duplicateTxs == 0 {
-			fmt.Printf("%s No duplicates\n", color.GreenString("✓"))
-		} else {
-			allClean = false
-			fmt.Printf("%s Found duplicates!\n", color.RedString("✗"))
-		}
+		fmt.Printf("\nDuplicates (Chain %d):\n", chainID)
 	}
-
-	// Check raw_traces - unique key: (chain_id, block_number, transaction_index, trace_address)
-	fmt.Printf("\nTraces (Chain %d):\n", chainID)
 	fmt.Printf("--------------------------------\n")
-	var totalTraces, duplicateTraces uint64
-	err = conn.QueryRow(ctx, `
-		SELECT count() as total, countIf(cnt > 1) as duplicates
-		FROM (
-			SELECT chain_id, block_number, transaction_index, trace_address, count() as cnt
-			FROM raw_traces
-			WHERE chain_id = ?
-			GROUP BY chain_id, block_number, transaction_index, trace_address
-		)
-	`, chainID).Scan(&totalTraces, &duplicateTraces)
-	if err != nil {
-		log.Printf("Error querying traces: %v", err)
-	} else {
-		fmt.Printf("Total traces:     %d\n", totalTraces)
-		fmt.Printf("Duplicates:       %d\n", duplicateTraces)
-		if duplicateTraces == 0 {
-			fmt.Printf("%s No duplicates\n", color.GreenString("✓"))
-		} else {
-			allClean = false
-			fmt.Printf("%s Found duplicates!\n", color.RedString("✗"))
+
+	allClean := true
+	for _, check := range checks {
+		if chainID != 0 && check.chainColumn == "" {
+			continue
 		}
-	}
 
-	// Check raw_logs - unique key: (chain_id, transaction_hash, log_index)
-	fmt.Printf("\nLogs (Chain %d):\n", chainID)
-	fmt.Printf("--------------------------------\n")
-	var totalLogs, duplicateLogs uint64
-	err = conn.QueryRow(ctx, `
-		SELECT count() as total, countIf(cnt > 1) as duplicates
-		FROM (
-			SELECT chain_id, transaction_hash, log_index, count() as cnt
-			FROM raw_logs
-			WHERE chain_id = ?
-			GROUP BY chain_id, transaction_hash, log_index
-		)
-	`, chainID).Scan(&totalLogs, &duplicateLogs)
-	if err != nil {
-		log.Printf("Error querying logs: %v", err)
-	} else {
-		fmt.Printf("Total logs:       %d\n", totalLogs)
-		fmt.Printf("Duplicates:       %d\n", duplicateLogs)
-		if duplicateLogs == 0 {
-			fmt.Printf("%s No duplicates\n", color.GreenString("✓"))
-		} else {
+		total, duplicates, err := countDuplicates(ctx, conn, check, chainID)
+		if err != nil {
+			slog.Error("Error querying table", "table", check.table, "error", err)
+			continue
+		}
+		if duplicates == 0 {
+			fmt.Printf("%s %-34s %15s rows\n", color.GreenString("✓"), check.table, humanize.Comma(int64(total)))
+			continue
+		}
+		fmt.Printf("%s %-34s %15s rows, %s duplicates\n", color.RedString("✗"), check.table,
+			humanize.Comma(int64(total)), humanize.Comma(int64(duplicates)))
+
+		if !fix {
+			allClean = false
+			continue
+		}
+		if err := fixDuplicates(ctx, conn, check); err != nil {
+			slog.Error("Error deduplicating table", "table", check.table, "error", err)
+			allClean = false
+			continue
+		}
+		if _, duplicates, err = countDuplicates(ctx, conn, check, chainID); err != nil {
+			slog.Error("Error querying table", "table", check.table, "error", err)
 			allClean = false
-			fmt.Printf("%s Found duplicates!\n", color.RedString("✗"))
+		} else if duplicates > 0 {
+			fmt.Printf("  %s %s duplicates left after OPTIMIZE\n", color.RedString("✗"), humanize.Comma(int64(duplicates)))
+			allClean = false
+		} else {
+			fmt.Printf("  %s Deduplicated\n", color.GreenString("✓"))
 		}
 	}
 
 	if allClean {
 		fmt.Printf("\n%s All tables are clean - no duplicates found\n", color.GreenString("✓"))
+	} else if fix {
+		fmt.Printf("\n%s Duplicates remain - data integrity issue!\n", color.RedString("✗"))
 	} else {
-		fmt.Printf("\n%s Duplicates detected - data integrity issue!\n", color.RedString("✗"))
+		fmt.Printf("\n%s Duplicates detected - data integrity issue! Re-run with --fix to remove them\n", color.RedString("✗"))
 	}
 
 	fmt.Println()
 }
+
+// collapsingTables lists the ReplacingMergeTree and SummingMergeTree tables, keyed by their sorting key
+func collapsingTables(ctx context.Context, conn driver.Conn) ([]duplicateCheck, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT t.name, t.sorting_key, anyIf(c.name, c.name != '') AS chain_column
+		FROM system.tables t
+		LEFT JOIN (
+			SELECT table, name FROM system.columns
+			WHERE database = currentDatabase() AND name IN ('chain_id', 'p_chain_id')
+		) c ON c.table = t.name
+		WHERE t.database = currentDatabase()
+		  AND (t.engine LIKE '%ReplacingMergeTree' OR t.engine LIKE '%SummingMergeTree')
+		GROUP BY t.name, t.sorting_key
+		ORDER BY t.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query system.tables: %w", err)
+	}
+	defer rows.Close()
+
+	var checks []duplicateCheck
+	for rows.Next() {
+		var check duplicateCheck
+		if err := rows.Scan(&check.table, &check.key, &check.chainColumn); err != nil {
+			return nil, fmt.Errorf("failed to scan table: %w", err)
+		}
+		check.collapsing = true
+		checks = append(checks, check)
+	}
+	return checks, rows.Err()
+}
+
+// countDuplicates returns a table's row count and how many of those rows are duplicates
+func countDuplicates(ctx context.Context, conn driver.Conn, check duplicateCheck, chainID uint32) (total, duplicates uint64, err error) {
+	where := ""
+	var args []any
+	if chainID != 0 {
+		where = fmt.Sprintf("WHERE %s = ?", check.chainColumn)
+		args = append(args, chainID)
+	}
+
+	var query string
+	if check.collapsing {
+		query = fmt.Sprintf(`
+			SELECT (SELECT count() FROM %[1]s %[2]s) AS total,
+			       toUInt64(total - (SELECT count() FROM %[1]s FINAL %[2]s)) AS duplicates`, check.table, where)
+		args = append(args, args...)
+	} else {
+		query = fmt.Sprintf(`
+			SELECT sum(cnt) AS total, toUInt64(total - count()) AS duplicates
+			FROM (
+				SELECT count() AS cnt
+				FROM %s
+				%s
+				GROUP BY %s
+			)`, check.table, where, check.key)
+	}
+
+	if err := conn.QueryRow(ctx, query, args...).Scan(&total, &duplicates); err != nil {
+		return 0, 0, err
+	}
+	return total, duplicates, nil
+}
+
+// fixDuplicates merges a table's parts and drops its duplicate rows
+func fixDuplicates(ctx context.Context, conn driver.Conn, check duplicateCheck) error {
+	query := fmt.Sprintf("OPTIMIZE TABLE %s FINAL", check.table)
+	if !check.collapsing {
+		query += " DEDUPLICATE BY " + check.key
+	}
+	fmt.Printf("  %s\n", query)
+	if err := conn.Exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to optimize %s: %w", check.table, err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"icicle/pkg/cache"
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/evmrpc"
+	"icicle/pkg/logging"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/klauspost/compress/zstd"
+)
+
+// importPollInterval is how often import checks whether ingest has caught up with the imported blocks
+const importPollInterval = 2 * time.Second
+
+// RunImport loads EVM blocks from local files into a chain's RPC cache and, unless cacheOnly is
+// set, ingests them into ClickHouse from the cache without calling the RPC. Files are JSON block
+// dumps (.json or .jsonl, optionally .gz or .zst compressed) or archives written by cache export
+// (.tar.zst).
+func RunImport(chainID uint32, paths []string, cacheOnly, fast bool) {
+	if chainID == 0 || len(paths) == 0 {
+		logging.Fatal(slog.Default(), "--chain and at least one file are required")
+	}
+
+	configs, err := LoadConfig(configPath)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to load config", "error", err)
+	}
+	var cfg *ChainConfig
+	for i := range configs {
+		if configs[i].ChainID == chainID {
+			cfg = &configs[i]
+			break
+		}
+	}
+	if cfg == nil {
+		logging.Fatal(slog.Default(), "Chain not found in the config", "path", configPath, "chain_id", chainID)
+	}
+	if cfg.VM != "evm" {
+		logging.Fatal(slog.Default(), "import only supports EVM chains", "chain_id", chainID, "vm", cfg.VM)
+	}
+
+	c, err := cache.New(cacheDir(*cfg), chainID)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to open cache", "chain_id", chainID, "error", err)
+	}
+	defer c.Close()
+
+	start := time.Now()
+	progress := printArchiveProgress(start)
+	var stats cache.ArchiveStats
+	for _, path := range paths {
+		fmt.Printf("Importing %s into chain %d cache...\n", path, chainID)
+		if err := importFile(c, chainID, path, &stats, progress); err != nil {
+			logging.Fatal(slog.Default(), "Failed to import file", "path", path, "error", err)
+		}
+	}
+
+	checkpoint, err := extendCheckpoint(c, cfg.StartBlock)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to advance checkpoint", "chain_id", chainID, "error", err)
+	}
+	fmt.Printf("Imported %s blocks (%s) in %s, checkpoint %d\n", humanize.Comma(stats.Blocks),
+		humanize.Bytes(uint64(stats.Bytes)), time.Since(start).Round(time.Second), checkpoint)
+	if cacheOnly {
+		return
+	}
+
+	ingestImported(c, *cfg, checkpoint, fast)
+}
+
+// importFile stores the blocks of one dump or cache archive in c, keeping blocks c already has
+func importFile(c cache.Cache, chainID uint32, path string, stats *cache.ArchiveStats, progress func(cache.ArchiveStats)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch {
+	case strings.HasSuffix(path, ".tar.zst"):
+		_, archived, err := cache.Import(c, chainID, file, progress)
+		stats.Blocks += archived.Blocks
+		stats.Bytes += archived.Bytes
+		return err
+	case strings.HasSuffix(path, ".rlp"), strings.HasSuffix(path, ".parquet"):
+		return fmt.Errorf("unsupported format: RLP dumps and Parquet exports lack the receipts and traces of each block, dump blocks as JSON instead")
+	}
+
+	var r io.Reader = file
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to open gzip: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	case strings.HasSuffix(path, ".zst"):
+		zr, err := zstd.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to open zstd: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	return evmrpc.ReadBlockDump(r, func(height int64, block *evmrpc.NormalizedBlock) error {
+		data, err := json.Marshal(block)
+		if err != nil {
+			return fmt.Errorf("failed to encode block %d: %w", height, err)
+		}
+		if _, err := c.GetCompleteBlock(height, func() ([]byte, error) { return data, nil }); err != nil {
+			return fmt.Errorf("failed to store block %d: %w", height, err)
+		}
+		stats.Blocks++
+		stats.Bytes += int64(len(data))
+		progress(*stats)
+		return nil
+	})
+}
+
+// extendCheckpoint advances the cache checkpoint over the blocks that directly follow it, starting
+// at startBlock in an empty cache, and returns it
+func extendCheckpoint(c cache.Cache, startBlock int64) (int64, error) {
+	checkpoint, err := c.GetCheckpoint()
+	if err != nil {
+		return 0, err
+	}
+	next := max(checkpoint, startBlock-1, 0) + 1
+
+	for {
+		chunkTo := next + hydrateChunkSize - 1
+		blocks, err := c.GetBlockRange(next, chunkTo)
+		if err != nil {
+			return 0, err
+		}
+		for next <= chunkTo && blocks[next] != nil {
+			next++
+		}
+		if next-1 > checkpoint {
+			if err := c.SetCheckpoint(next - 1); err != nil {
+				return 0, err
+			}
+			checkpoint = next - 1
+		}
+		if next <= chunkTo {
+			return checkpoint, nil
+		}
+	}
+}
+
+// ingestImported runs the chain's syncer in offline mode until the sync watermark reaches
+// checkpoint, so blocks are read from the cache at disk speed
+func ingestImported(c cache.Cache, cfg ChainConfig, checkpoint int64, fast bool) {
+	conn, err := chwrapper.Connect()
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to connect to ClickHouse", "error", err)
+	}
+	defer conn.Close()
+
+	if err := chwrapper.CreateTables(conn); err != nil {
+		logging.Fatal(slog.Default(), "Failed to create tables", "error", err)
+	}
+
+	watermark, err := chwrapper.GetWatermark(conn, cfg.ChainID)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to read watermark", "chain_id", cfg.ChainID, "error", err)
+	}
+	if int64(watermark) >= checkpoint {
+		fmt.Printf("Chain %d is already ingested up to %d, nothing to ingest\n", cfg.ChainID, watermark)
+		return
+	}
+
+	cfg.Offline = true
+	cfg.FollowDistance = 0
+	cfg.FollowTag = ""
+	cfg.HeadTable = false
+	cfg.IndexURL = ""
+	syncer, err := CreateSyncer(cfg, conn, c, fast, nil, nil)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to create syncer", "chain_id", cfg.ChainID, "error", err)
+	}
+
+	fmt.Printf("Ingesting chain %d blocks %d-%d from the cache...\n", cfg.ChainID, watermark+1, checkpoint)
+	start := time.Now()
+	if err := syncer.Start(); err != nil {
+		logging.Fatal(slog.Default(), "Failed to start syncer", "chain_id", cfg.ChainID, "error", err)
+	}
+	for int64(watermark) < checkpoint {
+		time.Sleep(importPollInterval)
+		if watermark, err = chwrapper.GetWatermark(conn, cfg.ChainID); err != nil {
+			logging.Fatal(slog.Default(), "Failed to read watermark", "chain_id", cfg.ChainID, "error", err)
+		}
+	}
+	syncer.Stop()
+
+	fmt.Printf("Ingested chain %d up to block %d in %s\n", cfg.ChainID, watermark, time.Since(start).Round(time.Second))
+}
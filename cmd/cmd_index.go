@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/evmindexer"
+	"icicle/pkg/logging"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// periodLayouts are the accepted formats of --period, in the chain's time zone
+var periodLayouts = []string{time.DateOnly, "2006-01-02T15", "2006-01-02T15:04", time.RFC3339}
+
+// RunIndex runs the indexers of EVM chains in this process instead of ingest, following the sync
+// watermark ingest writes to ClickHouse every pollInterval, so metric computation scales apart from
+// ingestion. It runs the chain chainID, or every chain with standaloneIndexer set when it is 0.
+func RunIndex(chainID uint32, pollInterval time.Duration) {
+	var chains []ChainConfig
+	if chainID != 0 {
+		chains = append(chains, indexChain(chainID))
+	} else {
+		configs, err := LoadConfig(configPath)
+		if err != nil {
+			logging.Fatal(slog.Default(), "Failed to load config", "error", err)
+		}
+		for _, cfg := range configs {
+			if cfg.VM == "evm" && cfg.StandaloneIndexer {
+				chains = append(chains, cfg)
+			}
+		}
+		if len(chains) == 0 {
+			logging.Fatal(slog.Default(), "No chain sets standaloneIndexer in the config, pass --chain to index one anyway", "path", configPath)
+		}
+	}
+	for _, cfg := range chains {
+		if !cfg.StandaloneIndexer {
+			slog.Warn("Chain doesn't set standaloneIndexer, so ingest also runs its indexers unless started with --fast", "chain_id", cfg.ChainID)
+		}
+	}
+
+	conn, err := chwrapper.Connect()
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to connect to ClickHouse", "error", err)
+	}
+	defer conn.Close()
+	if err := chwrapper.CreateTables(conn); err != nil {
+		logging.Fatal(slog.Default(), "Failed to create tables", "error", err)
+	}
+	if err := evmindexer.WatchSQLDir(); err != nil {
+		logging.Fatal(slog.Default(), "Failed to watch SQL directory", "error", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, cfg := range chains {
+		runner, err := evmindexer.NewIndexRunner(cfg.ChainID, conn, evmindexer.Options{
+			StartBlock:    uint64(max(cfg.StartBlock, 1)),
+			FeeAsset:      feeAsset(cfg),
+			Workers:       cfg.IndexerWorkers,
+			Timezone:      cfg.Timezone,
+			Recompute:     cfg.RecomputeLastNPeriods,
+			QuerySettings: cfg.IndexerSettings,
+		})
+		if err != nil {
+			logging.Fatal(slog.Default(), "Failed to create indexer runner", "chain_id", cfg.ChainID, "error", err)
+		}
+		go runner.Start()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runner.Follow(context.Background(), pollInterval)
+		}()
+		slog.Info("Started indexers", "chain_id", cfg.ChainID, "chain_name", cfg.Name)
+	}
+	wg.Wait()
+}
+
+// RunIndexDryRun prints the SQL an indexer of an EVM chain runs, with its template placeholders
+// replaced and its bind parameters listed, without executing it. Granular metrics are rendered for
+// the period of granularity containing period, incremental indexers for blocks fromBlock-toBlock
+// (a default batch when toBlock is 0). With explain set, ClickHouse's query plan of every query
+// is printed after it.
+func RunIndexDryRun(chainID uint32, indexer, granularity, period string, fromBlock, toBlock uint64, explain bool) {
+	cfg := indexChain(chainID)
+	file, err := evmindexer.ResolveIndexer(indexer)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to find indexer", "error", err)
+	}
+
+	var rendered *evmindexer.Rendered
+	if evmindexer.IsGranular(file) {
+		loc := chainTimezone(chainID)
+		start, err := parsePeriod(period, loc)
+		if err != nil {
+			logging.Fatal(slog.Default(), "Invalid --period", "period", period, "error", err)
+		}
+		rendered, err = evmindexer.RenderGranular(chainID, feeAsset(cfg), file, granularity, start, loc)
+	} else {
+		if fromBlock == 0 {
+			logging.Fatal(slog.Default(), "--from is required for incremental indexers", "indexer", file)
+		}
+		if toBlock == 0 {
+			toBlock = fromBlock + evmindexer.IncrementalBatchSize - 1
+		}
+		rendered, err = evmindexer.RenderIncremental(chainID, file, fromBlock, toBlock)
+	}
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to render indexer", "indexer", file, "error", err)
+	}
+
+	fmt.Printf("-- %s\n", rendered.File)
+	names := make([]string, 0, len(rendered.Params))
+	for name := range rendered.Params {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		value := rendered.Params[name]
+		if t, ok := value.(time.Time); ok {
+			value = t.Format(time.DateTime)
+		}
+		fmt.Printf("-- @%s = %v\n", name, value)
+	}
+
+	var explainer func(i int) ([]string, error)
+	if explain {
+		conn, err := chwrapper.Connect()
+		if err != nil {
+			logging.Fatal(slog.Default(), "Failed to connect to ClickHouse", "error", err)
+		}
+		defer conn.Close()
+		explainer = func(i int) ([]string, error) { return rendered.Explain(conn, i) }
+	}
+
+	for i, stmt := range rendered.Statements {
+		fmt.Printf("\n%s;\n", strings.TrimSpace(stmt))
+		if explainer == nil || !evmindexer.IsQuery(stmt) {
+			continue
+		}
+		plan, err := explainer(i)
+		if err != nil {
+			fmt.Printf("-- EXPLAIN failed: %v\n", err)
+			continue
+		}
+		fmt.Println("-- EXPLAIN:")
+		for _, line := range plan {
+			fmt.Printf("--   %s\n", line)
+		}
+	}
+}
+
+// RunIndexBackfill reruns a granular metric of an EVM chain over the periods from from to to
+// (dates or times, inclusive) and reconciles its watermark, pausing the chain's running syncer
+// meanwhile like resync. With offline set it doesn't wait for a running syncer to acknowledge.
+func RunIndexBackfill(chainID uint32, indexer, granularity, from, to string, timeout time.Duration, offline bool) {
+	cfg := indexChain(chainID)
+	file, err := evmindexer.ResolveIndexer(indexer)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to find indexer", "error", err)
+	}
+	if !evmindexer.IsGranular(file) {
+		logging.Fatal(slog.Default(), "Only metrics can be backfilled, resync the chain to recompute incremental indexers", "indexer", file)
+	}
+	loc := chainTimezone(chainID)
+	start, err := parsePeriod(from, loc)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Invalid --from", "from", from, "error", err)
+	}
+	end, err := parsePeriod(to, loc)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Invalid --to", "to", to, "error", err)
+	}
+	if end.Before(start) {
+		logging.Fatal(slog.Default(), "--to is before --from", "from", from, "to", to)
+	}
+
+	conn, err := chwrapper.Connect()
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to connect", "error", err)
+	}
+	defer conn.Close()
+
+	pauseChain(conn, chainID, fmt.Sprintf("backfill %s %s", file, granularity), timeout, offline)
+
+	periods, err := evmindexer.BackfillMetric(conn, chainID, feeAsset(cfg), file, granularity, start, end, loc, cfg.IndexerSettings)
+	resumeChain(conn, chainID)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to backfill metric, re-run to retry", "indexer", file, "granularity", granularity, "error", err)
+	}
+	fmt.Printf("Backfilled %d %s periods of %s\n", periods, granularity, file)
+}
+
+// indexChain returns the config of an EVM chain, exiting if it isn't configured
+func indexChain(chainID uint32) ChainConfig {
+	if chainID == 0 {
+		logging.Fatal(slog.Default(), "--chain is required")
+	}
+	configs, err := LoadConfig(configPath)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to load config", "error", err)
+	}
+	for _, cfg := range configs {
+		if cfg.ChainID != chainID {
+			continue
+		}
+		if cfg.VM != "evm" {
+			logging.Fatal(slog.Default(), "Indexers only run on EVM chains", "chain_id", chainID, "vm", cfg.VM)
+		}
+		return cfg
+	}
+	logging.Fatal(slog.Default(), "Chain not found in the config", "path", configPath, "chain_id", chainID)
+	return ChainConfig{}
+}
+
+// feeAsset returns the token a chain's fee metrics are denominated in
+func feeAsset(cfg ChainConfig) string {
+	if cfg.FeeAsset == "" {
+		return "AVAX"
+	}
+	return cfg.FeeAsset
+}
+
+// chainTimezone returns the time zone a chain's metric periods start in, the deployment's when
+// config.yaml is unreadable or the chain doesn't set one
+func chainTimezone(chainID uint32) *time.Location {
+	name := ""
+	if configs, err := LoadConfig(configPath); err == nil {
+		for _, cfg := range configs {
+			if cfg.ChainID == chainID {
+				name = cfg.Timezone
+			}
+		}
+	}
+	loc, err := evmindexer.LoadTimezone(name)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Invalid timezone", "chain_id", chainID, "error", err)
+	}
+	return loc
+}
+
+// parsePeriod parses a date or time in loc, unless it has an offset
+func parsePeriod(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range periodLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected a date like 2024-05-01 or a time like 2024-05-01T15:00")
+}
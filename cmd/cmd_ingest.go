@@ -1,86 +1,272 @@
 package cmd
 
 import (
+	"context"
 	"icicle/pkg/cache"
 	"icicle/pkg/chwrapper"
+	"icicle/pkg/evmindexer"
+	"icicle/pkg/evmsyncer"
+	"icicle/pkg/firehose"
+	"icicle/pkg/lakeexport"
+	"icicle/pkg/loadshed"
+	"icicle/pkg/logging"
+	"icicle/pkg/maintenance"
+	"icicle/pkg/metrics"
+	"icicle/pkg/pgsink"
 	"icicle/pkg/registrysyncer"
-	"context"
-	"log"
+	"icicle/pkg/webhooks"
+	"log/slog"
+	"net"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func RunIngest(fast bool) {
+// RunIngest starts a syncer for every configured chain. A non-zero maxMemory (bytes) or maxCPU
+// (cores) enables load shedding: near the budget, syncers fetch with less concurrency, smaller
+// batches and no traces until usage drops. A non-empty grpcAddr streams ingested blocks over gRPC,
+// a non-empty webhooksPath sends the events of the webhook rules in that file, and a non-empty
+// exportURL writes Parquet files of the EVM chains to S3 every exportInterval. Non-zero
+// optimizeHours runs OPTIMIZE FINAL on optimizeTables during those hours. A non-empty sinkURL
+// writes EVM blocks to that database instead of ClickHouse, without indexers or any of the above
+// services, which all read ClickHouse.
+func RunIngest(fast bool, maxMemory uint64, maxCPU float64, grpcAddr, grpcToken, webhooksPath, exportURL string, exportInterval time.Duration, sinkURL string, skipIntegrityCheck, skipChainIDCheck bool, optimizeHours maintenance.QuietHours, optimizeTables []string) {
 	if fast {
-		log.Println("Starting ingest in FAST mode (indexers disabled)...")
+		slog.Info("Starting ingest in FAST mode (indexers disabled)")
 	} else {
-		log.Println("Starting ingest...")
+		slog.Info("Starting ingest")
+	}
+
+	// Let the GC work harder before the process outgrows its memory budget
+	if maxMemory > 0 {
+		debug.SetMemoryLimit(int64(maxMemory))
 	}
+	loadShedder := loadshed.NewMonitor(maxMemory, maxCPU)
+	loadShedder.Start(context.Background())
 
 	// Load configuration from YAML
-	configs, err := LoadConfig("config.yaml")
+	configs, err := LoadConfig(configPath)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		logging.Fatal(slog.Default(), "Failed to load config", "error", err)
 	}
 
 	if len(configs) == 0 {
-		log.Fatal("No chain configurations found in config.yaml")
+		logging.Fatal(slog.Default(), "No chain configurations found in the config", "path", configPath)
 	}
 
-	// Connect to ClickHouse
-	conn, err := chwrapper.Connect()
-	if err != nil {
-		log.Fatalf("Failed to connect to ClickHouse: %v", err)
-	}
-	defer conn.Close()
+	var conn driver.Conn
+	var pool *pgxpool.Pool
+	if sinkURL != "" {
+		if grpcAddr != "" || webhooksPath != "" || exportURL != "" || !optimizeHours.IsZero() {
+			logging.Fatal(slog.Default(), "--grpc, --webhooks, --export and --optimize-hours use ClickHouse and can't be used with --sink")
+		}
+		pool = connectSink(configs, sinkURL)
+		defer pool.Close()
+		fast = true
+	} else {
+		// Connect to ClickHouse
+		conn, err = chwrapper.Connect()
+		if err != nil {
+			logging.Fatal(slog.Default(), "Failed to connect to ClickHouse", "error", err)
+		}
+		defer conn.Close()
 
-	err = chwrapper.CreateTables(conn)
-	if err != nil {
-		log.Fatalf("Failed to create tables: %v", err)
-	}
+		err = chwrapper.CreateTables(conn)
+		if err != nil {
+			logging.Fatal(slog.Default(), "Failed to create tables", "error", err)
+		}
 
-	// Sync L1 Registry at startup (in background)
-	go func() {
-		if err := registrysyncer.SyncRegistry(context.Background(), conn); err != nil {
-			log.Printf("Failed to sync L1 registry: %v", err)
+		// Record schema, indexer SQL and binary changes since the last start
+		recordDeployment(conn)
+
+		if err := evmindexer.WatchSQLDir(); err != nil {
+			logging.Fatal(slog.Default(), "Failed to watch SQL directory", "error", err)
+		}
+
+		if grpcAddr != "" {
+			serveFirehose(conn, configs, grpcAddr, grpcToken)
 		}
-	}()
+
+		if webhooksPath != "" {
+			rules, err := webhooks.LoadRules(webhooksPath)
+			if err != nil {
+				logging.Fatal(slog.Default(), "Failed to load webhook rules", "path", webhooksPath, "error", err)
+			}
+			go webhooks.NewDispatcher(conn, rules).Run(context.Background())
+		}
+
+		if exportURL != "" {
+			var chainIDs []uint32
+			for _, cfg := range configs {
+				if cfg.VM == "evm" {
+					chainIDs = append(chainIDs, cfg.ChainID)
+				}
+			}
+			go lakeexport.NewExporter(conn, exportURL, chainIDs, exportInterval).Run(context.Background())
+		}
+
+		go metrics.LogInsertSummaries(context.Background())
+
+		if !optimizeHours.IsZero() {
+			go maintenance.NewOptimizer(conn, optimizeTables, optimizeHours).Run(context.Background())
+		}
+
+		// Sync L1 Registry at startup (in background)
+		go func() {
+			if err := registrysyncer.SyncRegistry(context.Background(), conn); err != nil {
+				slog.Error("Failed to sync L1 registry", "error", err)
+			}
+		}()
+	}
 
 	var wg sync.WaitGroup
 
 	// Start a syncer for each chain
 	for _, cfg := range configs {
+		cfg.SkipIntegrityCheck = cfg.SkipIntegrityCheck || skipIntegrityCheck
+		cfg.SkipChainIDCheck = cfg.SkipChainIDCheck || skipChainIDCheck
+
 		// Create cache
-		cacheInstance, err := cache.New("./rpc_cache", cfg.ChainID)
+		cacheInstance, err := cache.New(cacheDir(cfg), cfg.ChainID)
 		if err != nil {
-			log.Fatalf("Failed to create cache for chain %d: %v", cfg.ChainID, err)
+			logging.Fatal(slog.Default(), "Failed to create cache", "chain_id", cfg.ChainID, "error", err)
 		}
 		defer cacheInstance.Close()
+		if retention := cfg.CacheRetention.Retention(); !retention.IsZero() {
+			go pruneCachePeriodically(cacheInstance, retention, logging.Chain("cache", cfg.ChainID, cfg.Name))
+		}
+		if cfg.CacheCompaction.IntervalHours > 0 {
+			go compactCachePeriodically(cacheInstance, cfg.CacheCompaction, loadShedder, logging.Chain("cache", cfg.ChainID, cfg.Name))
+		}
 
 		// Create syncer based on VM type
-		syncer, err := CreateSyncer(cfg, conn, cacheInstance, fast)
+		var sink evmsyncer.Sink
+		if pool != nil {
+			sink = pgsink.New(pool, cfg.ChainID)
+		}
+		syncer, err := CreateSyncer(cfg, conn, cacheInstance, fast, loadShedder, sink)
 		if err != nil {
-			log.Fatalf("Failed to create syncer for chain %d (%s): %v", cfg.ChainID, cfg.VM, err)
+			logging.Fatal(slog.Default(), "Failed to create syncer", "chain_id", cfg.ChainID, "vm", cfg.VM, "error", err)
 		}
 
 		wg.Add(1)
 		go func(s Syncer, chainID uint32, chainName string) {
+			logger := logging.Chain("ingest", chainID, chainName)
 			defer func() {
 				if r := recover(); r != nil {
-					log.Printf("[Chain %d] PANIC RECOVERED: %v", chainID, r)
+					logger.Error("Panic recovered", "panic", r)
 				}
-				log.Printf("[Chain %d] Syncer goroutine exiting!", chainID)
+				logger.Info("Syncer goroutine exiting")
 				wg.Done()
 			}()
 			if err := s.Start(); err != nil {
-				log.Printf("Failed to start syncer for chain %d (%s): %v", chainID, chainName, err)
+				logger.Error("Failed to start syncer", "error", err)
 			}
 			s.Wait()
-			log.Printf("[Chain %d] Wait() returned - syncer stopped", chainID)
+			logger.Info("Wait() returned - syncer stopped")
 		}(syncer, cfg.ChainID, cfg.Name)
 
-		log.Printf("Started syncer for chain %d (%s - %s)", cfg.ChainID, cfg.Name, cfg.VM)
+		slog.Info("Started syncer", "chain_id", cfg.ChainID, "chain_name", cfg.Name, "vm", cfg.VM)
 	}
 
 	wg.Wait()
-	log.Println("All syncers stopped - RunIngest() returning")
+	slog.Info("All syncers stopped - RunIngest() returning")
+}
+
+// connectSink connects to the Postgres database at url and creates its tables. Only EVM chains
+// can be ingested into it.
+func connectSink(configs []ChainConfig, url string) *pgxpool.Pool {
+	if !strings.HasPrefix(url, "postgres://") && !strings.HasPrefix(url, "postgresql://") {
+		logging.Fatal(slog.Default(), "Unsupported --sink, expected a postgres:// URL", "sink", url)
+	}
+	for _, cfg := range configs {
+		if cfg.VM != "evm" {
+			logging.Fatal(slog.Default(), "--sink only supports EVM chains", "chain_id", cfg.ChainID, "vm", cfg.VM)
+		}
+		if cfg.HeadTable || cfg.GapCheckInterval > 0 {
+			logging.Fatal(slog.Default(), "headTable and gapCheckInterval use ClickHouse and can't be used with --sink", "chain_id", cfg.ChainID)
+		}
+	}
+
+	pool, err := pgsink.Connect(context.Background(), url)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to connect to sink", "error", err)
+	}
+	slog.Info("Writing EVM blocks to Postgres, indexers disabled", "chains", len(configs))
+	return pool
+}
+
+// serveFirehose streams the blocks of the configured EVM chains and P-Chain over gRPC in the background
+func serveFirehose(conn driver.Conn, configs []ChainConfig, addr, token string) {
+	vms := make(map[uint32]string)
+	for _, cfg := range configs {
+		if cfg.VM == "evm" || cfg.VM == "p" {
+			vms[cfg.ChainID] = cfg.VM
+		}
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to start gRPC listener", "addr", addr, "error", err)
+	}
+	slog.Info("Streaming blocks over gRPC", "addr", listener.Addr().String(), "chains", len(vms), "auth", token != "")
+	go func() {
+		if err := firehose.NewServer(conn, vms, token).Serve(listener); err != nil {
+			logging.Fatal(slog.Default(), "gRPC server stopped", "error", err)
+		}
+	}()
+}
+
+// recordDeployment writes changed schema, indexer SQL and binary versions to deployment_log
+func recordDeployment(conn driver.Conn) {
+	version := binaryVersion()
+
+	items := chwrapper.SchemaDeploymentItems()
+	items = append(items, chwrapper.DeploymentItem{EventType: chwrapper.DeploymentBinary, Subject: "icicle", Checksum: version})
+
+	indexerItems, err := evmindexer.DeploymentItems()
+	if err != nil {
+		slog.Warn("Failed to checksum indexer SQL files", "error", err)
+	}
+	items = append(items, indexerItems...)
+
+	changed, err := chwrapper.RecordDeployment(conn, version, items)
+	if err != nil {
+		slog.Warn("Failed to record deployment", "error", err)
+		return
+	}
+	if changed > 0 {
+		slog.Info("Recorded deployment changes", "count", changed, "version", version)
+	}
+}
+
+// binaryVersion returns the VCS revision the binary was built from, or the module version
+func binaryVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	var revision string
+	var modified bool
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+
+	if revision == "" {
+		return info.Main.Version
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
 }
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/logging"
+)
+
+// RunPause stops a chain's running syncer and indexers from fetching and writing, e.g. during
+// maintenance of its RPC, while the other chains keep ingesting. Blocks fetched but not written are
+// dropped and refetched from the watermark on resume. With offline set it doesn't wait for a running
+// syncer to acknowledge, and an ingest started later stays paused.
+func RunPause(chainID uint32, reason string, timeout time.Duration, offline bool) {
+	conn, err := chwrapper.Connect()
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to connect", "error", err)
+	}
+	defer conn.Close()
+
+	if err := chwrapper.CreateTables(conn); err != nil {
+		logging.Fatal(slog.Default(), "Failed to create tables", "error", err)
+	}
+
+	if reason == "" {
+		reason = "pause"
+	}
+	pauseChain(conn, chainID, reason, timeout, offline)
+	fmt.Printf("Resume with: resume --chain %d\n", chainID)
+}
+
+// RunResume lets a paused chain's syncer and indexers continue from the watermark
+func RunResume(chainID uint32) {
+	conn, err := chwrapper.Connect()
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to connect", "error", err)
+	}
+	defer conn.Close()
+
+	if err := chwrapper.CreateTables(conn); err != nil {
+		logging.Fatal(slog.Default(), "Failed to create tables", "error", err)
+	}
+
+	ctrl, err := chwrapper.GetChainControl(conn, chainID)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to read chain control", "chain_id", chainID, "error", err)
+	}
+	if !ctrl.Paused {
+		fmt.Printf("Chain %d is not paused\n", chainID)
+		return
+	}
+	resumeChain(conn, chainID)
+}
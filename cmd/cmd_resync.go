@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/evmindexer"
+	"icicle/pkg/logging"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// RunResync re-ingests an EVM chain from a given block: it pauses the chain's running syncer,
+// deletes raw and computed data from that block on, rewinds all watermarks and resumes the syncer.
+// With offline set it doesn't wait for a running syncer to acknowledge (use when ingest is stopped).
+func RunResync(chainID uint32, from uint64, timeout time.Duration, offline bool) {
+	if chainID == 0 {
+		logging.Fatal(slog.Default(), "--chain is required (P-chain resync is not supported, use wipe --pchain)")
+	}
+	if from == 0 {
+		logging.Fatal(slog.Default(), "--from must be at least 1")
+	}
+
+	conn, err := chwrapper.Connect()
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to connect", "error", err)
+	}
+	defer conn.Close()
+
+	if err := chwrapper.CreateTables(conn); err != nil {
+		logging.Fatal(slog.Default(), "Failed to create tables", "error", err)
+	}
+
+	// Step 1: Pause the chain and wait for the syncer to stop writing
+	pauseChain(conn, chainID, fmt.Sprintf("resync from block %d", from), timeout, offline)
+
+	// Step 2: Delete affected ranges. The chain stays paused on failure so a re-run can finish the job
+	if err := resyncChainData(conn, chainID, from, chainTimezone(chainID)); err != nil {
+		logging.Fatal(slog.Default(), "Failed to resync chain (chain left paused, re-run resync to retry)", "chain_id", chainID, "error", err)
+	}
+
+	// Step 3: Resume ingestion
+	resumeChain(conn, chainID)
+	fmt.Printf("Chain %d will re-ingest from block %d\n", chainID, from)
+}
+
+// pauseChain sets the pause flag for a chain and, unless offline is set, waits for its running
+// syncer to stop writing
+func pauseChain(conn driver.Conn, chainID uint32, reason string, timeout time.Duration, offline bool) {
+	fmt.Printf("Pausing chain %d...\n", chainID)
+	version, err := chwrapper.SetChainPaused(conn, chainID, true, reason)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to pause chain", "chain_id", chainID, "error", err)
+	}
+
+	if !offline {
+		if err := chwrapper.WaitForChainControlAck(conn, chainID, version, timeout); err != nil {
+			resumeChain(conn, chainID)
+			logging.Fatal(slog.Default(), "Failed to pause chain (if ingest is not running, re-run with --offline)", "chain_id", chainID, "error", err)
+		}
+		if standaloneIndexer(chainID) {
+			if err := chwrapper.WaitForIndexerControlAck(conn, chainID, version, timeout); err != nil {
+				resumeChain(conn, chainID)
+				logging.Fatal(slog.Default(), "Failed to pause chain indexers (if index is not running, re-run with --offline)", "chain_id", chainID, "error", err)
+			}
+		}
+		fmt.Printf("Chain %d paused\n", chainID)
+	}
+}
+
+// standaloneIndexer reports whether config.yaml runs a chain's indexers with the index command
+func standaloneIndexer(chainID uint32) bool {
+	configs, err := LoadConfig(configPath)
+	if err != nil {
+		return false
+	}
+	for _, cfg := range configs {
+		if cfg.ChainID == chainID {
+			return cfg.StandaloneIndexer
+		}
+	}
+	return false
+}
+
+// resumeChain clears the pause flag for a chain
+func resumeChain(conn driver.Conn, chainID uint32) {
+	if _, err := chwrapper.SetChainPaused(conn, chainID, false, ""); err != nil {
+		logging.Fatal(slog.Default(), "Failed to resume chain", "chain_id", chainID, "error", err)
+	}
+	fmt.Printf("Chain %d resumed\n", chainID)
+}
+
+// resyncChainData deletes raw and computed data for blocks >= from and rewinds watermarks to from-1,
+// with metric periods starting in loc
+func resyncChainData(conn driver.Conn, chainID uint32, from uint64, loc *time.Location) error {
+	// Wait for deletes to finish before watermarks are rewound
+	ctx := clickhouse.Context(context.Background(), clickhouse.WithSettings(clickhouse.Settings{
+		"mutations_sync": 2,
+	}))
+
+	// Block time of the first affected block, needed to find affected metric periods
+	var fromTime time.Time
+	var count uint64
+	err := conn.QueryRow(ctx, "SELECT count(), min(block_time) FROM raw_blocks WHERE chain_id = ? AND block_number >= ?",
+		chainID, from).Scan(&count, &fromTime)
+	if err != nil {
+		return fmt.Errorf("failed to query block time of block %d: %w", from, err)
+	}
+	if count == 0 {
+		fromTime = time.Time{}
+	}
+
+	tables := []string{
+		"raw_blocks",
+		"raw_uncles",
+		"raw_withdrawals",
+		"raw_txs",
+		"raw_traces",
+		"raw_logs",
+		"erc20_transfers",
+		"nft_transfers",
+		"contracts",
+		"icm_messages",
+		"internal_txs",
+		"raw_payloads",
+		"raw_head_blocks",
+	}
+
+	for _, table := range tables {
+		fmt.Printf("Deleting from %s where chain_id = %d and block_number >= %d...\n", table, chainID, from)
+		query := fmt.Sprintf("ALTER TABLE %s DELETE WHERE chain_id = ? AND block_number >= ?", table)
+		if err := conn.Exec(ctx, query, chainID, from); err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+	}
+
+	fmt.Println("Rewinding computed tables and indexer watermarks...")
+	if err := evmindexer.RewindChain(conn, chainID, from, fromTime, loc); err != nil {
+		return fmt.Errorf("failed to rewind indexers: %w", err)
+	}
+
+	watermark, err := chwrapper.GetWatermark(conn, chainID)
+	if err != nil {
+		return fmt.Errorf("failed to get watermark: %w", err)
+	}
+	if uint64(watermark) >= from {
+		fmt.Printf("Resetting sync watermark from %d to %d...\n", watermark, from-1)
+		if err := chwrapper.SetWatermark(conn, chainID, uint32(from-1)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"icicle/pkg/api"
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/logging"
+	"log/slog"
+	"net"
+	"net/http"
+)
+
+// RunServe serves the query API over ClickHouse until the process is stopped
+func RunServe(addr string, keys []string) {
+	conn, err := chwrapper.Connect()
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to connect", "error", err)
+	}
+	defer conn.Close()
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to start API listener", "addr", addr, "error", err)
+	}
+
+	if len(keys) == 0 {
+		slog.Warn("No API keys configured, the API is open to anyone who can reach it")
+	}
+	slog.Info("Serving query API", "addr", listener.Addr().String(), "keys", len(keys))
+	if err := http.Serve(listener, api.NewServer(conn, keys).Handler()); err != nil {
+		logging.Fatal(slog.Default(), "API server stopped", "error", err)
+	}
+}
@@ -3,13 +3,16 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 
+	"icicle/pkg/cache"
 	"icicle/pkg/chwrapper"
+	"icicle/pkg/logging"
 
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 )
@@ -47,25 +50,48 @@ func formatNumber(num float64) string {
 	return result.String()
 }
 
-func RunSize() {
+// RunSize prints the size of each table and of the RPC caches. With recommend it suggests column
+// codecs and types instead of showing the caches.
+func RunSize(recommend bool) {
 	fmt.Println("=== ClickHouse Table Size ===")
 	fmt.Println()
 
 	conn, err := chwrapper.Connect()
 	if err != nil {
-		log.Fatalf("Failed to connect: %v", err)
+		logging.Fatal(slog.Default(), "Failed to connect", "error", err)
 	}
 	defer conn.Close()
 
 	if err := showTableSize(conn); err != nil {
-		log.Fatalf("Failed to show table size: %v", err)
+		logging.Fatal(slog.Default(), "Failed to show table size", "error", err)
 	}
 
-	fmt.Println()
-	fmt.Println("=== Disk Usage: ./rpc_cache/ ===")
-	fmt.Println()
-	if err := showRpcCacheSize("./rpc_cache"); err != nil {
-		log.Fatalf("Failed to show rpc_cache size: %v", err)
+	if recommend {
+		fmt.Println()
+		fmt.Println("=== Codec Recommendations ===")
+		fmt.Println()
+		if err := showCodecRecommendations(conn); err != nil {
+			logging.Fatal(slog.Default(), "Failed to recommend codecs", "error", err)
+		}
+		return
+	}
+
+	// Chains with their own cacheDir are listed under that directory
+	dirs := []string{cache.Dir()}
+	for _, dir := range chainCacheDirs() {
+		if !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	slices.Sort(dirs[1:])
+
+	for _, dir := range dirs {
+		fmt.Println()
+		fmt.Printf("=== Disk Usage: %s/ ===\n", strings.TrimSuffix(dir, "/"))
+		fmt.Println()
+		if err := showRpcCacheSize(dir); err != nil {
+			logging.Fatal(slog.Default(), "Failed to show rpc_cache size", "path", dir, "error", err)
+		}
 	}
 }
 
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"icicle/pkg/chwrapper"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+const (
+	// recommendMinBytes skips columns too small for their codec to matter
+	recommendMinBytes = 1 << 20
+	// lowCardinalitySample is how many rows of a String column are sampled for distinct values
+	lowCardinalitySample = 1_000_000
+	// lowCardinalityMax is the most distinct values a String column should have as LowCardinality
+	lowCardinalityMax = 10_000
+)
+
+// steadyColumnPattern matches columns whose values grow steadily along the sorting key
+var steadyColumnPattern = regexp.MustCompile(`^(block_number|height|block_time|.*_number|.*_time)$`)
+
+// integerTypePattern matches the integer and time types delta codecs apply to
+var integerTypePattern = regexp.MustCompile(`^(U?Int\d+|DateTime(64)?(\(.*\))?)$`)
+
+// columnStats is a column of system.columns with its compressed size
+type columnStats struct {
+	table        string
+	name         string
+	columnType   string
+	codec        string
+	compressed   uint64
+	uncompressed uint64
+}
+
+// recommendation is a codec or type suggested for a column
+type recommendation struct {
+	column     columnStats
+	codec      string // Suggested codec, empty for a type change
+	columnType string // Suggested type, empty for a codec change
+	reason     string
+}
+
+// showCodecRecommendations suggests codecs and types for the columns of the current database from
+// their compression so far, and prints the --codecs value applying them
+func showCodecRecommendations(conn driver.Conn) error {
+	ctx := context.Background()
+	rows, err := conn.Query(ctx, `
+		SELECT table, name, type, compression_codec, data_compressed_bytes, data_uncompressed_bytes
+		FROM system.columns
+		WHERE database = currentDatabase() AND data_compressed_bytes >= ?
+		ORDER BY data_compressed_bytes DESC`, uint64(recommendMinBytes))
+	if err != nil {
+		return fmt.Errorf("failed to query columns: %w", err)
+	}
+	var columns []columnStats
+	for rows.Next() {
+		var c columnStats
+		if err := rows.Scan(&c.table, &c.name, &c.columnType, &c.codec, &c.compressed, &c.uncompressed); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan column: %w", err)
+		}
+		columns = append(columns, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("row iteration error: %w", err)
+	}
+
+	var recommendations []recommendation
+	for _, c := range columns {
+		r, ok, err := recommendColumn(ctx, conn, c)
+		if err != nil {
+			return err
+		}
+		if ok {
+			recommendations = append(recommendations, r)
+		}
+	}
+
+	if len(recommendations) == 0 {
+		fmt.Println("No recommendations, every column compresses as expected")
+		return nil
+	}
+
+	const maxNameLen = 40
+	fmt.Printf("%-*s %12s %7s  %-28s %s\n", maxNameLen, "Column", "Size (MB)", "Ratio", "Codec", "Suggestion")
+	fmt.Println(strings.Repeat("-", maxNameLen+100))
+
+	var codecPairs, typeChanges []string
+	for _, r := range recommendations {
+		name := r.column.table + "." + r.column.name
+		if len(name) > maxNameLen {
+			name = name[:maxNameLen-3] + "..."
+		}
+		codec := r.column.codec
+		if codec == "" {
+			codec = "(default)"
+		}
+		suggestion := "CODEC(" + r.codec + ")"
+		if r.columnType != "" {
+			suggestion = r.columnType
+			typeChanges = append(typeChanges, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", r.column.table, r.column.name, r.columnType))
+		} else {
+			codecPairs = append(codecPairs, fmt.Sprintf("%s.%s=%s", r.column.table, r.column.name, r.codec))
+		}
+		fmt.Printf("%-*s %12s %6.1fx  %-28s %s: %s\n", maxNameLen, name,
+			formatNumber(float64(r.column.compressed)/(1024.0*1024.0)), compressionRatio(r.column), codec, suggestion, r.reason)
+	}
+
+	if len(codecPairs) > 0 {
+		fmt.Println()
+		fmt.Println("Apply the codecs on the next start with (parts written or merged afterwards use them):")
+		fmt.Printf("  --codecs %q\n", strings.Join(codecPairs, ";"))
+	}
+	if len(typeChanges) > 0 {
+		fmt.Println()
+		fmt.Println("Type changes rewrite the whole column, run them in a quiet hour:")
+		for _, change := range typeChanges {
+			fmt.Printf("  %s\n", change)
+		}
+	}
+	return nil
+}
+
+// recommendColumn returns the suggestion for a column, false if it has none
+func recommendColumn(ctx context.Context, conn driver.Conn, c columnStats) (recommendation, bool, error) {
+	ratio := compressionRatio(c)
+
+	if codec, ok := chwrapper.DefaultCodecs[c.table][c.name]; ok {
+		if chwrapper.SameCodec(c.codec, codec) {
+			return recommendation{}, false, nil
+		}
+		return recommendation{column: c, codec: codec, reason: "default codec, not set yet or overridden with --codecs"}, true, nil
+	}
+
+	if steadyColumnPattern.MatchString(c.name) && integerTypePattern.MatchString(c.columnType) && !strings.Contains(c.codec, "Delta") {
+		return recommendation{column: c, codec: "Delta, ZSTD(1)", reason: "values grow steadily, their deltas compress to almost nothing"}, true, nil
+	}
+
+	if c.columnType == "String" {
+		distinct, err := sampleDistinct(ctx, conn, c)
+		if err != nil {
+			return recommendation{}, false, err
+		}
+		if distinct <= lowCardinalityMax {
+			reason := fmt.Sprintf("%d distinct values among the first %d rows", distinct, lowCardinalitySample)
+			return recommendation{column: c, columnType: "LowCardinality(String)", reason: reason}, true, nil
+		}
+		if ratio < 4 && !strings.Contains(c.codec, "ZSTD") {
+			reason := fmt.Sprintf("compresses only %.1fx with LZ4", ratio)
+			return recommendation{column: c, codec: "ZSTD(3)", reason: reason}, true, nil
+		}
+	}
+	return recommendation{}, false, nil
+}
+
+// sampleDistinct estimates the distinct values of a column among its first rows
+func sampleDistinct(ctx context.Context, conn driver.Conn, c columnStats) (uint64, error) {
+	query := fmt.Sprintf("SELECT uniq(`%s`) FROM (SELECT `%s` FROM `%s` LIMIT %d)", c.name, c.name, c.table, lowCardinalitySample)
+	var distinct uint64
+	if err := conn.QueryRow(ctx, query).Scan(&distinct); err != nil {
+		return 0, fmt.Errorf("failed to count distinct values of %s.%s: %w", c.table, c.name, err)
+	}
+	return distinct, nil
+}
+
+// compressionRatio returns how many times smaller a column is compressed
+func compressionRatio(c columnStats) float64 {
+	if c.compressed == 0 {
+		return 0
+	}
+	return float64(c.uncompressed) / float64(c.compressed)
+}
@@ -0,0 +1,458 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/evmindexer"
+	"icicle/pkg/evmrpc"
+	"icicle/pkg/evmsyncer"
+	"icicle/pkg/logging"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"golang.org/x/sync/errgroup"
+)
+
+// soakEpoch is the chain time of the first synthetic block
+var soakEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// erc20TransferTopic is keccak256("Transfer(address,address,uint256)")
+const erc20TransferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+// SoakConfig configures a soak run
+type SoakConfig struct {
+	Database      string        // Scratch database, dropped and recreated
+	ChainID       uint32        // Chain ID of the synthetic chain
+	TPS           int           // Target transactions per second
+	TxsPerBlock   int           // Transactions per synthetic block
+	LogsPerTx     int           // ERC-20 Transfer logs per transaction
+	BlockInterval time.Duration // Chain time between blocks, drives granular metric periods
+	BatchSize     int           // Blocks per insert
+	Duration      time.Duration // How long to generate load
+	Accounts      int           // Distinct sender/recipient addresses
+	Fast          bool          // Skip indexers
+	Keep          bool          // Keep the scratch database afterwards
+	MinTPS        float64       // Fail if sustained throughput is below this (0 disables)
+}
+
+// soakStats counts what made it through the pipeline
+type soakStats struct {
+	blocks    atomic.Int64
+	txs       atomic.Int64
+	logs      atomic.Int64
+	lastBlock atomic.Int64
+	peakHeap  atomic.Uint64
+}
+
+// RunSoak generates synthetic EVM blocks at a fixed rate and drives them through the same
+// normalize → insert → index path as ingest, against a scratch database, reporting sustained
+// throughput and memory. A pipeline that can't keep up holds the generator back and shows as
+// achieved throughput below the target.
+func RunSoak(cfg SoakConfig) {
+	if cfg.Database == "" || cfg.Database == "default" {
+		logging.Fatal(slog.Default(), "--database must name a scratch database other than default")
+	}
+	if cfg.TPS <= 0 || cfg.TxsPerBlock <= 0 || cfg.BatchSize <= 0 {
+		logging.Fatal(slog.Default(), "--tps, --txs-per-block and --batch-size must be positive")
+	}
+	if cfg.Accounts < 2 {
+		cfg.Accounts = 2
+	}
+
+	conn := connectScratch(cfg.Database)
+	defer conn.Close()
+
+	if err := chwrapper.CreateTables(conn); err != nil {
+		logging.Fatal(slog.Default(), "Failed to create tables", "error", err)
+	}
+
+	var runner *evmindexer.IndexRunner
+	if !cfg.Fast {
+		var err error
+		runner, err = evmindexer.NewIndexRunner(cfg.ChainID, conn, evmindexer.Options{StartBlock: 1, FeeAsset: "AVAX"})
+		if err != nil {
+			logging.Fatal(slog.Default(), "Failed to create indexer runner", "error", err)
+		}
+		go runner.Start()
+	}
+
+	blockRate := float64(cfg.TPS) / float64(cfg.TxsPerBlock)
+	fmt.Printf("Soaking %s: %d tx/s as %.1f blocks/s of %d txs for %s\n",
+		cfg.Database, cfg.TPS, blockRate, cfg.TxsPerBlock, cfg.Duration)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Duration)
+	defer cancel()
+
+	stats := &soakStats{}
+	raw := make(chan []byte, cfg.BatchSize*2)
+	normalized := make(chan *evmrpc.NormalizedBlock, cfg.BatchSize*2)
+
+	go generateSoakBlocks(ctx, cfg, time.Duration(float64(time.Second)/blockRate), raw)
+	go normalizeSoakBlocks(raw, normalized)
+
+	reportDone := make(chan struct{})
+	go func() {
+		reportSoak(ctx, conn, cfg.ChainID, stats, runner != nil)
+		close(reportDone)
+	}()
+
+	start := time.Now()
+	if err := writeSoakBlocks(conn, cfg, normalized, runner, stats); err != nil {
+		logging.Fatal(slog.Default(), "Soak pipeline failed", "error", err)
+	}
+	elapsed := time.Since(start)
+	cancel()
+	<-reportDone
+
+	if runner != nil {
+		runner.Pause()
+	}
+
+	achieved := float64(stats.txs.Load()) / elapsed.Seconds()
+	fmt.Println()
+	fmt.Println("=== Soak Summary ===")
+	fmt.Printf("Elapsed:        %s\n", elapsed.Round(time.Second))
+	fmt.Printf("Blocks:         %d\n", stats.blocks.Load())
+	fmt.Printf("Transactions:   %d (%.0f tx/s sustained, target %d)\n", stats.txs.Load(), achieved, cfg.TPS)
+	fmt.Printf("Logs:           %d\n", stats.logs.Load())
+	fmt.Printf("Peak heap:      %.1f MB\n", float64(stats.peakHeap.Load())/1024/1024)
+	if runner != nil {
+		if lag, err := soakIndexerLag(conn, cfg.ChainID, stats.lastBlock.Load()); err == nil {
+			fmt.Printf("Indexer lag:    %d blocks\n", lag)
+		}
+	}
+
+	if !cfg.Keep {
+		if err := conn.Exec(context.Background(), fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", cfg.Database)); err != nil {
+			slog.Warn("Failed to drop scratch database", "database", cfg.Database, "error", err)
+		}
+	}
+
+	if cfg.MinTPS > 0 && achieved < cfg.MinTPS {
+		logging.Fatal(slog.Default(), "Sustained throughput below --min-tps", "tps", achieved, "min_tps", cfg.MinTPS)
+	}
+}
+
+// connectScratch drops and recreates database and connects to it
+func connectScratch(database string) driver.Conn {
+	admin, err := chwrapper.Connect()
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to connect", "error", err)
+	}
+	defer admin.Close()
+
+	for _, stmt := range []string{
+		fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", database),
+		fmt.Sprintf("CREATE DATABASE `%s`", database),
+	} {
+		if err := admin.Exec(context.Background(), stmt); err != nil {
+			logging.Fatal(slog.Default(), "Failed to recreate scratch database", "database", database, "error", err)
+		}
+	}
+
+	conn, err := chwrapper.ConnectDatabase(database)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to connect to scratch database", "database", database, "error", err)
+	}
+	return conn
+}
+
+// generateSoakBlocks emits one RPC-shaped block JSON every interval until ctx is done. Ticks
+// missed while the pipeline is full are skipped, not caught up on.
+func generateSoakBlocks(ctx context.Context, cfg SoakConfig, interval time.Duration, out chan<- []byte) {
+	defer close(out)
+
+	rng := rand.New(rand.NewSource(1))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var txCounter uint64
+	for number := uint64(1); ; number++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		block := syntheticBlock(cfg, number, &txCounter, rng)
+		data, err := json.Marshal(block)
+		if err != nil {
+			slog.Error("Failed to marshal synthetic block", "block", number, "error", err)
+			return
+		}
+
+		select {
+		case out <- data:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// normalizeSoakBlocks decodes block JSON the way the fetcher decodes cached blocks
+func normalizeSoakBlocks(in <-chan []byte, out chan<- *evmrpc.NormalizedBlock) {
+	defer close(out)
+	for data := range in {
+		var block evmrpc.NormalizedBlock
+		if err := json.Unmarshal(data, &block); err != nil {
+			slog.Error("Failed to decode synthetic block", "error", err)
+			continue
+		}
+		out <- &block
+	}
+}
+
+// writeSoakBlocks inserts blocks in batches of cfg.BatchSize (or whatever arrived within a
+// second) into the raw and decoded tables in parallel, then advances the watermark and indexer
+func writeSoakBlocks(conn driver.Conn, cfg SoakConfig, in <-chan *evmrpc.NormalizedBlock, runner *evmindexer.IndexRunner, stats *soakStats) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	batch := make([]*evmrpc.NormalizedBlock, 0, cfg.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		blocks := batch
+		batch = make([]*evmrpc.NormalizedBlock, 0, cfg.BatchSize)
+
+		ctx := context.Background()
+		g, gctx := errgroup.WithContext(ctx)
+		g.Go(func() error { return evmsyncer.InsertBlocks(gctx, conn, cfg.ChainID, blocks, 0) })
+		g.Go(func() error { return evmsyncer.InsertTransactions(gctx, conn, cfg.ChainID, blocks, 0) })
+		g.Go(func() error { return evmsyncer.InsertTraces(gctx, conn, cfg.ChainID, blocks, 0) })
+		g.Go(func() error { return evmsyncer.InsertLogs(gctx, conn, cfg.ChainID, blocks, 0) })
+		g.Go(func() error { return evmsyncer.InsertERC20Transfers(gctx, conn, cfg.ChainID, blocks, 0) })
+		g.Go(func() error { return evmsyncer.InsertNFTTransfers(gctx, conn, cfg.ChainID, blocks, 0) })
+		g.Go(func() error { return evmsyncer.InsertContracts(gctx, conn, cfg.ChainID, blocks, 0) })
+		g.Go(func() error { return evmsyncer.InsertICMMessages(gctx, conn, cfg.ChainID, blocks, 0) })
+		g.Go(func() error { return evmsyncer.InsertInternalTxs(gctx, conn, cfg.ChainID, blocks, 0) })
+		g.Go(func() error { return evmsyncer.InsertUncles(gctx, conn, cfg.ChainID, blocks, 0) })
+		g.Go(func() error { return evmsyncer.InsertWithdrawals(gctx, conn, cfg.ChainID, blocks, 0) })
+		if err := g.Wait(); err != nil {
+			return fmt.Errorf("failed to insert blocks: %w", err)
+		}
+
+		lastNum := stats.lastBlock.Load() + int64(len(blocks))
+		if err := chwrapper.SetWatermark(conn, cfg.ChainID, uint32(lastNum)); err != nil {
+			return fmt.Errorf("failed to set watermark: %w", err)
+		}
+		if runner != nil {
+			runner.OnBlock(uint64(lastNum), soakBlockTime(cfg, uint64(lastNum)))
+		}
+
+		for _, b := range blocks {
+			stats.txs.Add(int64(len(b.Block.Transactions)))
+			for _, r := range b.Receipts {
+				stats.logs.Add(int64(len(r.Logs)))
+			}
+		}
+		stats.blocks.Add(int64(len(blocks)))
+		stats.lastBlock.Store(lastNum)
+		return nil
+	}
+
+	for {
+		select {
+		case block, ok := <-in:
+			if !ok {
+				return flush()
+			}
+			batch = append(batch, block)
+			if len(batch) >= cfg.BatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		case <-ticker.C:
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// reportSoak prints throughput, memory and indexer lag every 10 seconds until ctx is done
+func reportSoak(ctx context.Context, conn driver.Conn, chainID uint32, stats *soakStats, indexing bool) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	var lastTxs int64
+	lastTime := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			sampleSoakHeap(stats)
+			return
+		case now := <-ticker.C:
+			heap := sampleSoakHeap(stats)
+			txs := stats.txs.Load()
+			rate := float64(txs-lastTxs) / now.Sub(lastTime).Seconds()
+			lastTxs, lastTime = txs, now
+
+			line := fmt.Sprintf("[soak] block %d | %.0f tx/s | %d txs total | heap %.1f MB",
+				stats.lastBlock.Load(), rate, txs, float64(heap)/1024/1024)
+			if indexing {
+				if lag, err := soakIndexerLag(conn, chainID, stats.lastBlock.Load()); err == nil {
+					line += fmt.Sprintf(" | indexer lag %d blocks", lag)
+				}
+			}
+			fmt.Println(line)
+		}
+	}
+}
+
+// sampleSoakHeap records the current heap size as the peak if it is the largest seen
+func sampleSoakHeap(stats *soakStats) uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	for {
+		peak := stats.peakHeap.Load()
+		if m.HeapAlloc <= peak || stats.peakHeap.CompareAndSwap(peak, m.HeapAlloc) {
+			return m.HeapAlloc
+		}
+	}
+}
+
+// soakIndexerLag returns how far the slowest incremental indexer is behind lastBlock
+func soakIndexerLag(conn driver.Conn, chainID uint32, lastBlock int64) (int64, error) {
+	var minBlock uint64
+	err := conn.QueryRow(context.Background(), `
+		SELECT min(last_block_num)
+		FROM indexer_watermarks FINAL
+		WHERE chain_id = ? AND granularity = ''`, chainID).Scan(&minBlock)
+	if err != nil {
+		return 0, err
+	}
+	return lastBlock - int64(minBlock), nil
+}
+
+// soakBlockTime returns the chain time of a synthetic block
+func soakBlockTime(cfg SoakConfig, number uint64) time.Time {
+	return soakEpoch.Add(time.Duration(number) * cfg.BlockInterval)
+}
+
+// hexWord formats n as a 0x-prefixed hex string of width bytes
+func hexWord(n uint64, width int) string {
+	return fmt.Sprintf("0x%0*x", width*2, n)
+}
+
+// syntheticBlock builds a block of ERC-20 transfers with receipts, logs and call traces
+func syntheticBlock(cfg SoakConfig, number uint64, txCounter *uint64, rng *rand.Rand) *evmrpc.NormalizedBlock {
+	const baseFee = 25_000_000_000
+	const gasPerTx = 52_000
+
+	blockHash := hexWord(number, 32)
+	blockNumber := fmt.Sprintf("0x%x", number)
+	zero32 := hexWord(0, 32)
+
+	nb := &evmrpc.NormalizedBlock{
+		Block: evmrpc.Block{
+			Number:           blockNumber,
+			Hash:             blockHash,
+			ParentHash:       hexWord(number-1, 32),
+			Timestamp:        fmt.Sprintf("0x%x", soakBlockTime(cfg, number).Unix()),
+			Miner:            hexWord(0, 20),
+			Difficulty:       "0x1",
+			TotalDifficulty:  blockNumber,
+			Size:             fmt.Sprintf("0x%x", 600+cfg.TxsPerBlock*180),
+			GasLimit:         fmt.Sprintf("0x%x", 15_000_000),
+			GasUsed:          fmt.Sprintf("0x%x", min(gasPerTx*cfg.TxsPerBlock, 15_000_000)),
+			BaseFeePerGas:    fmt.Sprintf("0x%x", baseFee),
+			StateRoot:        zero32,
+			TransactionsRoot: zero32,
+			ReceiptsRoot:     zero32,
+			LogsBloom:        "0x",
+			MixHash:          zero32,
+			Nonce:            hexWord(0, 8),
+			Sha3Uncles:       zero32,
+			Uncles:           []string{},
+		},
+	}
+
+	for i := 0; i < cfg.TxsPerBlock; i++ {
+		*txCounter++
+		txHash := hexWord(*txCounter, 32)
+		txIndex := fmt.Sprintf("0x%x", i)
+		from := hexWord(uint64(rng.Intn(cfg.Accounts))+1, 20)
+		to := hexWord(uint64(rng.Intn(cfg.Accounts))+1, 20)
+		token := hexWord(1<<32+uint64(rng.Intn(100)), 20)
+		amount := hexWord(uint64(rng.Int63()), 32)
+		input := "0xa9059cbb" + to[2:] + amount[2:]
+		status := "0x1"
+		if rng.Intn(100) == 0 {
+			status = "0x0"
+		}
+
+		nb.Block.Transactions = append(nb.Block.Transactions, evmrpc.Transaction{
+			Hash:                 txHash,
+			Nonce:                fmt.Sprintf("0x%x", *txCounter),
+			BlockHash:            blockHash,
+			BlockNumber:          blockNumber,
+			TransactionIndex:     txIndex,
+			From:                 from,
+			To:                   token,
+			Value:                "0x0",
+			Gas:                  fmt.Sprintf("0x%x", 100_000),
+			GasPrice:             fmt.Sprintf("0x%x", baseFee+1_000_000_000),
+			Input:                input,
+			Type:                 "0x2",
+			MaxFeePerGas:         fmt.Sprintf("0x%x", 2*baseFee),
+			MaxPriorityFeePerGas: fmt.Sprintf("0x%x", 1_000_000_000),
+		})
+
+		receipt := evmrpc.Receipt{
+			BlockHash:         blockHash,
+			BlockNumber:       blockNumber,
+			CumulativeGasUsed: fmt.Sprintf("0x%x", gasPerTx*(i+1)),
+			EffectiveGasPrice: fmt.Sprintf("0x%x", baseFee+1_000_000_000),
+			From:              from,
+			GasUsed:           fmt.Sprintf("0x%x", gasPerTx),
+			LogsBloom:         "0x",
+			Status:            status,
+			To:                token,
+			TransactionHash:   txHash,
+			TransactionIndex:  txIndex,
+			Type:              "0x2",
+			Logs:              []evmrpc.Log{},
+		}
+		if status == "0x1" {
+			for l := 0; l < cfg.LogsPerTx; l++ {
+				receipt.Logs = append(receipt.Logs, evmrpc.Log{
+					Address:          token,
+					Topics:           []string{erc20TransferTopic, "0x" + zero32[2:26] + from[2:], "0x" + zero32[2:26] + to[2:]},
+					Data:             amount,
+					BlockNumber:      blockNumber,
+					TransactionHash:  txHash,
+					TransactionIndex: txIndex,
+					BlockHash:        blockHash,
+					LogIndex:         fmt.Sprintf("0x%x", i*cfg.LogsPerTx+l),
+				})
+			}
+		}
+		nb.Receipts = append(nb.Receipts, receipt)
+
+		nb.Traces = append(nb.Traces, evmrpc.TraceResultOptional{
+			TxHash: txHash,
+			Result: &evmrpc.CallTrace{
+				From:    from,
+				To:      token,
+				Gas:     fmt.Sprintf("0x%x", 100_000),
+				GasUsed: fmt.Sprintf("0x%x", gasPerTx),
+				Input:   input,
+				Output:  hexWord(1, 32),
+				Value:   "0x0",
+				Type:    "CALL",
+			},
+		})
+	}
+
+	return nb
+}
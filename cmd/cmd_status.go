@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/logging"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/dustin/go-humanize"
+)
+
+// statusTop is how many indexers each status list shows
+const statusTop = 10
+
+// RunStatus prints the slowest and most failing indexers over the runs recorded in indexer_runs
+// during the last since, for one chain or all chains (chainID 0)
+func RunStatus(chainID uint32, since time.Duration) {
+	conn, err := chwrapper.Connect()
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to connect", "error", err)
+	}
+	defer conn.Close()
+
+	if chainID == 0 {
+		fmt.Printf("Indexer runs in the last %s (all chains)\n", since)
+	} else {
+		fmt.Printf("Indexer runs in the last %s (Chain %d)\n", since, chainID)
+	}
+
+	if err := showSlowestIndexers(conn, chainID, since); err != nil {
+		logging.Fatal(slog.Default(), "Failed to query slowest indexers", "error", err)
+	}
+	if err := showFailingIndexers(conn, chainID, since); err != nil {
+		logging.Fatal(slog.Default(), "Failed to query failing indexers", "error", err)
+	}
+}
+
+// showSlowestIndexers lists the indexers with the highest average successful run time
+func showSlowestIndexers(conn driver.Conn, chainID uint32, since time.Duration) error {
+	query := `
+	SELECT chain_id, indexer_name, granularity, count(), avg(duration_ms), max(duration_ms), avg(read_rows)
+	FROM indexer_runs
+	WHERE started_at >= now64(3) - toIntervalSecond(?) AND (? = 0 OR chain_id = ?) AND error = ''
+	GROUP BY chain_id, indexer_name, granularity
+	ORDER BY avg(duration_ms) DESC
+	LIMIT ?`
+
+	rows, err := conn.Query(context.Background(), query, uint64(since.Seconds()), chainID, chainID, statusTop)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	fmt.Printf("\nSlowest indexers:\n")
+	fmt.Printf("--------------------------------\n")
+	fmt.Printf("%-8s %-40s %-8s %8s %10s %10s %14s\n", "CHAIN", "INDEXER", "GRAN", "RUNS", "AVG", "MAX", "AVG ROWS READ")
+	for rows.Next() {
+		var chain uint32
+		var name, granularity string
+		var runs uint64
+		var avgMs, avgRead float64
+		var maxMs uint64
+		if err := rows.Scan(&chain, &name, &granularity, &runs, &avgMs, &maxMs, &avgRead); err != nil {
+			return err
+		}
+		fmt.Printf("%-8d %-40s %-8s %8d %10s %10s %14s\n", chain, name, granularity, runs,
+			time.Duration(avgMs*float64(time.Millisecond)).Round(time.Millisecond),
+			(time.Duration(maxMs) * time.Millisecond).String(), humanize.Comma(int64(avgRead)))
+	}
+	return rows.Err()
+}
+
+// showFailingIndexers lists the indexers with the most failed runs and their last error
+func showFailingIndexers(conn driver.Conn, chainID uint32, since time.Duration) error {
+	query := `
+	SELECT chain_id, indexer_name, granularity, countIf(error != ''), count(), argMaxIf(error, started_at, error != '')
+	FROM indexer_runs
+	WHERE started_at >= now64(3) - toIntervalSecond(?) AND (? = 0 OR chain_id = ?)
+	GROUP BY chain_id, indexer_name, granularity
+	HAVING countIf(error != '') > 0
+	ORDER BY countIf(error != '') DESC
+	LIMIT ?`
+
+	rows, err := conn.Query(context.Background(), query, uint64(since.Seconds()), chainID, chainID, statusTop)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	fmt.Printf("\nMost failing indexers:\n")
+	fmt.Printf("--------------------------------\n")
+	failing := 0
+	for rows.Next() {
+		var chain uint32
+		var name, granularity, lastError string
+		var failures, runs uint64
+		if err := rows.Scan(&chain, &name, &granularity, &failures, &runs, &lastError); err != nil {
+			return err
+		}
+		fmt.Printf("%-8d %-40s %-8s %d of %d runs failed\n  last error: %s\n", chain, name, granularity, failures, runs, firstLine(lastError))
+		failing++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if failing == 0 {
+		fmt.Println("No failed runs")
+	}
+	return nil
+}
+
+// firstLine returns s up to its first newline
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
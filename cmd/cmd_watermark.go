@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/evmindexer"
+	"icicle/pkg/logging"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/dustin/go-humanize"
+)
+
+// RunWatermarkList prints the sync watermark and indexer watermarks of a chain
+func RunWatermarkList(chainID uint32) {
+	if chainID == 0 {
+		logging.Fatal(slog.Default(), "--chain is required")
+	}
+	conn := connectWatermarks()
+	defer conn.Close()
+
+	syncWatermark, err := chwrapper.GetWatermark(conn, chainID)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to read sync watermark", "chain_id", chainID, "error", err)
+	}
+	watermarks, err := evmindexer.ListWatermarks(conn, chainID)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to read indexer watermarks", "chain_id", chainID, "error", err)
+	}
+
+	fmt.Printf("Chain %d synced up to block %s\n\n", chainID, humanize.Comma(int64(syncWatermark)))
+	fmt.Printf("%-40s %-12s %-20s %s\n", "INDEXER", "GRANULARITY", "WATERMARK", "UPDATED")
+	for _, wm := range watermarks {
+		fmt.Printf("%-40s %-12s %-20s %s\n", wm.Indexer, wm.Granularity, formatWatermark(wm.Granularity, wm.Watermark),
+			wm.UpdatedAt.UTC().Format(time.DateTime))
+	}
+}
+
+// RunWatermarkSet sets the watermark of one indexer of a chain to value, a period start for
+// metrics or a block for incremental indexers, while the chain is paused. Moving it forward skips
+// data, so it needs force. Incremental watermarks can't be moved back, since their batches after it
+// would be counted twice: use RunWatermarkRewind.
+func RunWatermarkSet(chainID uint32, indexer, granularity, value string, force bool, timeout time.Duration, offline bool) {
+	file, name := resolveWatermark(chainID, indexer)
+	conn := connectWatermarks()
+	defer conn.Close()
+
+	current := indexerWatermark(conn, chainID, name, granularity)
+	var next evmindexer.Watermark
+	var forward bool
+	if evmindexer.IsGranular(file) {
+		if err := evmindexer.CheckGranularity(granularity); err != nil {
+			logging.Fatal(slog.Default(), "Invalid --granularity", "error", err)
+		}
+		period, err := parsePeriod(value, chainTimezone(chainID))
+		if err != nil {
+			logging.Fatal(slog.Default(), "Invalid --to", "to", value, "error", err)
+		}
+		next.LastPeriod = period
+		forward = period.After(current.LastPeriod)
+	} else {
+		if granularity != "" {
+			logging.Fatal(slog.Default(), "Incremental indexers have no granularity", "indexer", file)
+		}
+		block, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			logging.Fatal(slog.Default(), "Invalid --to, expected a block number", "to", value, "error", err)
+		}
+		if block < current.LastBlockNum {
+			logging.Fatal(slog.Default(), "Moving an incremental watermark back would count its batches twice, use watermark rewind",
+				"indexer", file, "watermark", current.LastBlockNum, "to", block)
+		}
+		next.LastBlockNum = block
+		forward = block > current.LastBlockNum
+	}
+	if forward && !force {
+		logging.Fatal(slog.Default(), "Moving a watermark forward skips data, re-run with --force to do it anyway",
+			"indexer", file, "watermark", formatWatermark(granularity, current), "to", value)
+	}
+
+	pauseChain(conn, chainID, "watermark set "+name, timeout, offline)
+	err := evmindexer.SaveWatermark(conn, chainID, name, granularity, next)
+	resumeChain(conn, chainID)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to set watermark", "error", err)
+	}
+	fmt.Printf("Set %s watermark from %s to %s\n", file, formatWatermark(granularity, current), formatWatermark(granularity, next))
+}
+
+// RunWatermarkRewind moves the watermark of one indexer of a chain back, while the chain is paused,
+// so it recomputes everything from from on: a date or time for metrics (all granularities when
+// granularity is empty), a block for incremental indexers, whose batches from there on are deleted.
+func RunWatermarkRewind(chainID uint32, indexer, granularity, from string, timeout time.Duration, offline bool) {
+	file, name := resolveWatermark(chainID, indexer)
+	conn := connectWatermarks()
+	defer conn.Close()
+
+	if evmindexer.IsGranular(file) {
+		if granularity != "" {
+			if err := evmindexer.CheckGranularity(granularity); err != nil {
+				logging.Fatal(slog.Default(), "Invalid --granularity", "error", err)
+			}
+		}
+		loc := chainTimezone(chainID)
+		start, err := parsePeriod(from, loc)
+		if err != nil {
+			logging.Fatal(slog.Default(), "Invalid --from", "from", from, "error", err)
+		}
+
+		pauseChain(conn, chainID, "watermark rewind "+name, timeout, offline)
+		rewound, err := evmindexer.RewindMetric(conn, chainID, file, granularity, start, loc)
+		resumeChain(conn, chainID)
+		if err != nil {
+			logging.Fatal(slog.Default(), "Failed to rewind watermark", "indexer", file, "error", err)
+		}
+		if len(rewound) == 0 {
+			fmt.Printf("No %s watermark reaches %s, nothing to rewind\n", file, from)
+		}
+		for _, wm := range rewound {
+			fmt.Printf("Rewound %s %s watermark to %s\n", file, wm.Granularity, formatWatermark(wm.Granularity, wm.Watermark))
+		}
+		return
+	}
+
+	if granularity != "" {
+		logging.Fatal(slog.Default(), "Incremental indexers have no granularity", "indexer", file)
+	}
+	block, err := strconv.ParseUint(from, 10, 64)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Invalid --from, expected a block number", "from", from, "error", err)
+	}
+
+	pauseChain(conn, chainID, "watermark rewind "+name, timeout, offline)
+	rewindBlock, err := evmindexer.RewindIncremental(conn, chainID, file, block)
+	resumeChain(conn, chainID)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to rewind watermark", "indexer", file, "error", err)
+	}
+	fmt.Printf("Rewound %s watermark to block %d, it recomputes from block %d\n", file, rewindBlock, rewindBlock+1)
+}
+
+// connectWatermarks connects to ClickHouse for the watermark commands
+func connectWatermarks() driver.Conn {
+	conn, err := chwrapper.Connect()
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to connect", "error", err)
+	}
+	return conn
+}
+
+// resolveWatermark returns the SQL file of an indexer and the name of its watermark
+func resolveWatermark(chainID uint32, indexer string) (string, string) {
+	if chainID == 0 {
+		logging.Fatal(slog.Default(), "--chain is required")
+	}
+	file, err := evmindexer.ResolveIndexer(indexer)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to find indexer", "error", err)
+	}
+	return file, evmindexer.WatermarkName(file)
+}
+
+// indexerWatermark returns the stored watermark of an indexer, zero if it never ran
+func indexerWatermark(conn driver.Conn, chainID uint32, name, granularity string) evmindexer.Watermark {
+	watermarks, err := evmindexer.ListWatermarks(conn, chainID)
+	if err != nil {
+		logging.Fatal(slog.Default(), "Failed to read indexer watermarks", "chain_id", chainID, "error", err)
+	}
+	for _, wm := range watermarks {
+		if wm.Indexer == name && wm.Granularity == granularity {
+			return wm.Watermark
+		}
+	}
+	return evmindexer.Watermark{}
+}
+
+// formatWatermark returns the period of a metric watermark or the block of an incremental one
+func formatWatermark(granularity string, wm evmindexer.Watermark) string {
+	if granularity == "" {
+		return "block " + humanize.Comma(int64(wm.LastBlockNum))
+	}
+	return wm.LastPeriod.UTC().Format(time.DateTime)
+}
@@ -3,9 +3,10 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"icicle/pkg/chwrapper"
+	"icicle/pkg/logging"
 
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 )
@@ -13,14 +14,14 @@ import (
 func RunWipe(all bool, chainID uint32, pchain bool) {
 	conn, err := chwrapper.Connect()
 	if err != nil {
-		log.Fatalf("Failed to connect: %v", err)
+		logging.Fatal(slog.Default(), "Failed to connect", "error", err)
 	}
 	defer conn.Close()
 
 	// If pchain flag is specified, wipe P-chain calculated tables
 	if pchain {
 		if err := wipePChainTables(conn, all); err != nil {
-			log.Fatalf("Failed to wipe P-chain tables: %v", err)
+			logging.Fatal(slog.Default(), "Failed to wipe P-chain tables", "error", err)
 		}
 		if all {
 			fmt.Println("All P-chain tables wiped successfully (including raw transactions)")
@@ -33,10 +34,10 @@ func RunWipe(all bool, chainID uint32, pchain bool) {
 	// If chainID is specified, wipe data for that specific chain
 	if chainID > 0 {
 		if !all {
-			log.Fatalf("--chain flag requires --all flag to be set. Use: wipe --all --chain=%d", chainID)
+			logging.Fatal(slog.Default(), "--chain flag requires --all flag to be set", "usage", fmt.Sprintf("wipe --all --chain=%d", chainID))
 		}
 		if err := wipeChainData(conn, chainID); err != nil {
-			log.Fatalf("Failed to wipe chain %d data: %v", chainID, err)
+			logging.Fatal(slog.Default(), "Failed to wipe chain data", "chain_id", chainID, "error", err)
 		}
 		fmt.Printf("All data for chain %d wiped successfully\n", chainID)
 		return
@@ -44,7 +45,7 @@ func RunWipe(all bool, chainID uint32, pchain bool) {
 
 	// Otherwise, wipe calculated tables as usual
 	if err := wipeCalculatedTables(conn, all); err != nil {
-		log.Fatalf("Failed to wipe tables: %v", err)
+		logging.Fatal(slog.Default(), "Failed to wipe tables", "error", err)
 	}
 
 	if all {
@@ -65,6 +66,11 @@ func wipePChainTables(conn driver.Conn, all bool) error {
 		"l1_validator_state",
 		"l1_validator_balance_txs",
 		"l1_validator_refunds",
+		"p_chain_rewards",
+		"p_chain_reward_sync",
+		"validator_set_snapshots",
+		"validator_set_snapshot_heights",
+		"l1_validator_weight_divergences",
 		"l1_fee_stats",
 		"l1_subnets",
 		"l1_registry",
@@ -91,6 +97,15 @@ func wipePChainTables(conn driver.Conn, all bool) error {
 		if err := conn.Exec(ctx, "TRUNCATE TABLE IF EXISTS p_chain_txs"); err != nil {
 			fmt.Printf("  Note: %s (may not exist)\n", err)
 		}
+		if err := conn.Exec(ctx, "TRUNCATE TABLE IF EXISTS p_chain_memos"); err != nil {
+			fmt.Printf("  Note: %s (may not exist)\n", err)
+		}
+		if err := conn.Exec(ctx, "TRUNCATE TABLE IF EXISTS p_chain_blocks"); err != nil {
+			fmt.Printf("  Note: %s (may not exist)\n", err)
+		}
+		if err := conn.Exec(ctx, "ALTER TABLE raw_payloads DELETE WHERE chain_id = 0"); err != nil {
+			fmt.Printf("  Note: %s (may not exist)\n", err)
+		}
 
 		// Reset P-chain sync watermark (p_chain_id = 0 for mainnet)
 		fmt.Println("Resetting P-chain sync watermark...")
@@ -113,9 +128,20 @@ func wipeChainData(conn driver.Conn, chainID uint32) error {
 
 	tables := []string{
 		"raw_blocks",
+		"raw_uncles",
+		"raw_withdrawals",
 		"raw_txs",
 		"raw_traces",
 		"raw_logs",
+		"erc20_transfers",
+		"nft_transfers",
+		"contracts",
+		"icm_messages",
+		"internal_txs",
+		"hypersdk_blocks",
+		"hypersdk_actions",
+		"raw_payloads",
+		"raw_head_blocks",
 	}
 
 	fmt.Printf("Wiping data for chain %d...\n", chainID)
@@ -167,11 +193,30 @@ func wipeCalculatedTables(conn driver.Conn, all bool) error {
 
 	if !all {
 		keepTables["raw_blocks"] = true
+		keepTables["raw_uncles"] = true
+		keepTables["raw_withdrawals"] = true
 		keepTables["raw_txs"] = true
 		keepTables["raw_traces"] = true
 		keepTables["raw_logs"] = true
+		keepTables["erc20_transfers"] = true
+		keepTables["nft_transfers"] = true
+		keepTables["contracts"] = true
+		keepTables["icm_messages"] = true
+		keepTables["internal_txs"] = true
 		keepTables["p_chain_txs"] = true
+		keepTables["p_chain_memos"] = true
+		keepTables["p_chain_blocks"] = true
+		keepTables["hypersdk_blocks"] = true
+		keepTables["hypersdk_actions"] = true
+		keepTables["raw_payloads"] = true
 		keepTables["sync_watermark"] = true
+		keepTables["chain_control"] = true
+		keepTables["chain_control_acks"] = true
+		keepTables["indexer_control_acks"] = true
+		keepTables["deployment_log"] = true
+		keepTables["dimension_history"] = true
+		keepTables["webhook_cursors"] = true
+		keepTables["export_watermarks"] = true
 	}
 
 	var tables []struct {
@@ -204,10 +249,11 @@ func wipeCalculatedTables(conn driver.Conn, all bool) error {
 	fmt.Printf("Found %d calculated tables to drop\n", len(tables))
 
 	for _, table := range tables {
-		dropQuery := fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s` SETTINGS max_table_size_to_drop=0", table.database, table.name)
+		// SYNC frees a replicated table's ZooKeeper path before the indexers create it again
+		dropQuery := fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s` SYNC SETTINGS max_table_size_to_drop=0", table.database, table.name)
 		fmt.Printf("Dropping %s.%s...\n", table.database, table.name)
 
-		if err := conn.Exec(ctx, dropQuery); err != nil {
+		if err := conn.Exec(ctx, chwrapper.ClusterDDL(dropQuery)); err != nil {
 			return fmt.Errorf("failed to drop %s.%s: %w", table.database, table.name, err)
 		}
 	}
@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"fmt"
 	"icicle/pkg/cache"
+	"icicle/pkg/evmindexer"
 	"icicle/pkg/evmsyncer"
+	"icicle/pkg/hypersdksyncer"
+	"icicle/pkg/loadshed"
 	"icicle/pkg/pchainsyncer"
-	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
@@ -15,20 +19,164 @@ import (
 // ChainConfig represents configuration for any blockchain VM type
 type ChainConfig struct {
 	ChainID        uint32 `yaml:"chainID"`
-	VM             string `yaml:"vm"` // "evm", "p", "x", etc.
+	VM             string `yaml:"vm"` // "evm", "p", "hypersdk", etc.
 	RpcURL         string `yaml:"rpcURL"`
 	StartBlock     int64  `yaml:"startBlock"`
 	FetchBatchSize int    `yaml:"fetchBatchSize"`
 	MaxConcurrency int    `yaml:"maxConcurrency"`
 	Name           string `yaml:"name"`
+	IndexURL       string `yaml:"indexURL"` // Index API endpoint for block proposer attribution, e.g. http://127.0.0.1:9650/ext/index/C/block (optional)
+
+	// RPC authentication, sent to rpcURL and traceRpcURL
+	RpcHeaders   map[string]string `yaml:"rpcHeaders"`   // Extra HTTP headers, e.g. x-api-key
+	RpcAuthToken string            `yaml:"rpcAuthToken"` // Sent as "Authorization: Bearer <token>"
+
+	// RPC response compression, for endpoints that mishandle it
+	RpcCompression *bool `yaml:"rpcCompression"` // Ask for gzip/deflate compressed responses (default: true)
+
+	// Local RPC cache location, e.g. to put chains on different disks
+	CacheDir string `yaml:"cacheDir"` // Directory holding this chain's cache (default: --cache-dir)
+
+	// RPC cache retention, to bound the local cache on nodes that only need recent blocks
+	CacheRetention CacheRetention `yaml:"cacheRetention"`
+
+	// Background RPC cache compaction, paced to leave disk bandwidth to ingestion
+	CacheCompaction CacheCompaction `yaml:"cacheCompaction"`
+
+	// RPC responses cached besides complete blocks, namespace to TTL ("0" never expires), e.g.
+	// {receipts: "0", traces: "0", validators: "4m", l1Validators: "1h"}
+	CacheTTLs map[string]string `yaml:"cacheTTLs"`
+
+	// Continuity check of the raw tables, re-ingesting blocks missing below the watermark (EVM and P-chain)
+	GapCheckInterval   int  `yaml:"gapCheckInterval"`   // Minutes between checks (default: 10, -1 disables)
+	SkipIntegrityCheck bool `yaml:"skipIntegrityCheck"` // Only check blocks written since startup, like ingest --skip-integrity-check
 
-	// EVM-specific config for RPC batching
+	// Startup check that rpcURL serves this chain, so a wrong URL can't write another chain's blocks
+	NetworkID        uint32 `yaml:"networkID"`        // P-chain only: network ID the node must report, 1 on Mainnet and 5 on Fuji (required unless skipChainIDCheck)
+	SkipChainIDCheck bool   `yaml:"skipChainIDCheck"` // Ingest even if the RPC reports another chain or network ID, like ingest --skip-chain-id-check
+
+	// EVM-specific confirmation depth, so raw tables only get blocks that are no longer reorged
+	FollowDistance int64  `yaml:"followDistance"` // Blocks to stay behind the RPC tip (default: 0)
+	FollowTag      string `yaml:"followTag"`      // Ingest up to the "finalized" or "safe" block instead of the tip (default: none)
+	HeadTable      bool   `yaml:"headTable"`      // Also write the blocks above the followed block to raw_head_blocks (default: false)
+
+	// Copy of each block's cache payload in raw_payloads, so "cache hydrate" can rebuild the cache from ClickHouse
+	StorePayloads bool `yaml:"storePayloads"` // EVM and P-chain only (default: false)
+
+	// ClickHouse settings of block inserts, EVM and P-chain only, e.g. {async_insert: 1, wait_for_async_insert: 1}
+	// so small batches at the tip are merged server-side instead of each creating a part
+	InsertSettings map[string]string `yaml:"insertSettings"` // Unknown settings fail at startup (default: none)
+
+	// EVM-specific endpoint for debug_trace* calls, when the main RPC is a full node without them
+	TraceRpcURL string `yaml:"traceRpcURL"` // Archival endpoint for traces (default: rpcURL)
+
+	// Streaming of fetched blocks to the writer, EVM and P-chain only
+	StreamBatchSize int `yaml:"streamBatchSize"` // Blocks of a fetch batch handed to the writer at once (default: 100)
+	MemoryBudgetMB  int `yaml:"memoryBudgetMB"`  // Megabytes of fetched blocks not yet written, fetching waits above it (default: 1024, -1 disables)
+
+	// Adaptive fetch batch sizing, EVM and P-chain only
+	MaxFetchBatchSize int `yaml:"maxFetchBatchSize"` // Most blocks per fetch once tuned to block sizes (default: 0, fetchBatchSize is fixed)
+	FetchBatchMB      int `yaml:"fetchBatchMB"`      // Megabytes of blocks a tuned fetch aims for (default: 64)
+
+	// EVM-specific outage spool, batches that can't be written to ClickHouse are queued on disk in
+	// a <chainID> subdirectory and replayed once it is back, instead of stopping ingestion
+	SpoolDir   string `yaml:"spoolDir"`   // Spool directory (default: none, ingest stops when ClickHouse stays unreachable)
+	SpoolMaxGB int    `yaml:"spoolMaxGB"` // Most gigabytes spooled, fetching waits above it (default: 10)
+
+	// RPC batching (debugBatchSize is EVM-specific)
 	RpcBatchSize   int `yaml:"rpcBatchSize"`   // RPC calls per HTTP request (default: 100)
 	DebugBatchSize int `yaml:"debugBatchSize"` // Debug/trace calls per HTTP request (default: 15)
 
+	// EVM-specific fee config
+	FeeAsset string `yaml:"feeAsset"` // Token fees are paid in, labels fee metrics (default: AVAX)
+
+	// EVM-specific indexer concurrency
+	IndexerWorkers int `yaml:"indexerWorkers"` // Indexers run at once (default: 4)
+
+	// EVM-specific ClickHouse settings of indexer queries, so a runaway metric can't exhaust a shared
+	// server, e.g. {max_memory_usage: 10000000000, max_execution_time: 600, max_threads: 4}
+	IndexerSettings map[string]string `yaml:"indexerSettings"` // Overridden by an indexer's clickhouse_settings (default: none)
+
+	// EVM-specific standalone indexing, to scale metric computation apart from ingestion
+	StandaloneIndexer bool `yaml:"standaloneIndexer"` // Run the chain's indexers with the index command instead of ingest (default: false)
+
+	// EVM-specific reporting time zone, an IANA name such as Europe/Berlin
+	Timezone string `yaml:"timezone"` // Time zone day, week, month and year metric periods start in (default: --timezone)
+
+	// EVM-specific late block handling, for chains whose blocks can be ingested out of order
+	RecomputeLastNPeriods int `yaml:"recomputeLastNPeriods"` // Closed periods every metric run recomputes (default: 0)
+
+	// EVM-specific uncle ingestion
+	FetchUncles bool `yaml:"fetchUncles"` // Fetch uncle headers into raw_uncles (default: false)
+
+	// EVM-specific offline mode, for air-gapped backfills from files loaded with "import"
+	Offline bool `yaml:"offline"` // Ingest only blocks in the RPC cache, up to its checkpoint, never calling rpcURL (default: false)
+
+	// EVM-specific data toggles, for RPCs without debug APIs or chains that don't need the data
+	FetchTraces *bool `yaml:"fetchTraces"` // Fetch traces with debug_trace* calls (default: true)
+	FetchLogs   *bool `yaml:"fetchLogs"`   // Write raw_logs and the tables decoded from logs (default: true)
+
 	// P-chain specific config
-	EnableValidatorSync   bool `yaml:"enableValidatorSync"`   // Enable L1 validator state syncing
-	ValidatorSyncInterval int  `yaml:"validatorSyncInterval"` // Validator sync interval in minutes (default: 5)
+	EnableValidatorSync       bool `yaml:"enableValidatorSync"`       // Enable L1 validator state syncing
+	ValidatorSyncInterval     int  `yaml:"validatorSyncInterval"`     // Validator sync interval in minutes (default: 5)
+	ValidatorSnapshotInterval int  `yaml:"validatorSnapshotInterval"` // Historical validator set snapshot interval in hours (0 disables)
+	TxBlobMinSize             int  `yaml:"txBlobMinSize"`             // Compress genesisData/validators tx fields of at least this many bytes (0 disables)
+
+	// P-chain tx storage, "json", "typed" or "both" (default: json)
+	TxStorage string `yaml:"txStorage"`
+
+	// P-chain block parsing
+	ParseWorkers    int  `yaml:"parseWorkers"`    // Workers parsing and normalizing blocks (default: GOMAXPROCS)
+	PinParseWorkers bool `yaml:"pinParseWorkers"` // Pin each parse worker to its own CPU (Linux only)
+
+	// P-chain validator sync subnet selection
+	ValidatorDiscoveryMode      string   `yaml:"validatorDiscoveryMode"`      // "auto", "manual" or "hybrid" (default: auto)
+	ValidatorSyncSubnets        []string `yaml:"validatorSyncSubnets"`        // L1 subnet IDs to sync validators for
+	ValidatorSyncExcludeSubnets []string `yaml:"validatorSyncExcludeSubnets"` // L1 subnet IDs never to sync validators for
+
+	// P-chain per-subnet validator sync intervals
+	ValidatorPrioritySubnets    []string `yaml:"validatorPrioritySubnets"`    // Subnet IDs to sync validators for every validatorPriorityInterval
+	ValidatorPriorityInterval   int      `yaml:"validatorPriorityInterval"`   // Priority subnet sync interval in minutes (default: 1)
+	ValidatorSubnetSyncInterval int      `yaml:"validatorSubnetSyncInterval"` // Other subnets' sync interval in minutes (default: validatorSyncInterval)
+}
+
+// CacheRetention limits what the chain's RPC cache keeps, zero fields don't limit anything
+type CacheRetention struct {
+	MaxSizeGB       float64 `yaml:"maxSizeGB"`       // Delete the lowest blocks until the cache is below this size
+	PruneBelowBlock int64   `yaml:"pruneBelowBlock"` // Delete blocks below this height
+	TTLHours        float64 `yaml:"ttlHours"`        // Delete blocks cached more than this many hours ago
+}
+
+// Retention converts the config to a cache retention policy
+func (r CacheRetention) Retention() cache.Retention {
+	return cache.Retention{
+		MaxSizeBytes: uint64(r.MaxSizeGB * (1 << 30)),
+		PruneBelow:   r.PruneBelowBlock,
+		TTL:          time.Duration(r.TTLHours * float64(time.Hour)),
+	}
+}
+
+// CacheCompaction schedules paced compactions of the chain's RPC cache during ingest
+type CacheCompaction struct {
+	IntervalHours float64 `yaml:"intervalHours"` // Hours between compactions (0 disables)
+	StepBlocks    int64   `yaml:"stepBlocks"`    // Blocks compacted per step (default: 100000)
+	PauseMs       int     `yaml:"pauseMs"`       // Pause between steps in milliseconds (default: 1000)
+}
+
+// Options converts the config to paced compaction options, postponing steps while shedder is degraded
+func (c CacheCompaction) Options(shedder *loadshed.Monitor) cache.CompactOptions {
+	opts := cache.CompactOptions{
+		Step:  c.StepBlocks,
+		Pause: time.Duration(c.PauseMs) * time.Millisecond,
+		Yield: shedder.Degraded,
+	}
+	if opts.Step == 0 {
+		opts.Step = 100000
+	}
+	if c.PauseMs == 0 {
+		opts.Pause = time.Second
+	}
+	return opts
 }
 
 // Syncer interface for all chain syncers
@@ -38,17 +186,40 @@ type Syncer interface {
 	Stop()
 }
 
-// LoadConfig loads and parses the YAML configuration file
+// DefaultConfigPath is the config file commands read unless --config names another
+const DefaultConfigPath = "config.yaml"
+
+// configPath is the config file every command reads
+var configPath = DefaultConfigPath
+
+// SetConfigPath sets the config file every command reads
+func SetConfigPath(path string) {
+	configPath = path
+}
+
+// configFile is the layout of a config file with settings shared by every chain. A file can also
+// be just the list of chains.
+type configFile struct {
+	Defaults yaml.Node   `yaml:"defaults"` // Fields every chain starts with
+	Chains   []yaml.Node `yaml:"chains"`
+}
+
+// LoadConfig loads and parses a YAML or JSON configuration file, JSON being read as YAML. The file
+// is either a list of chains or a mapping of chains and the defaults they start with. ${VAR}
+// references are replaced from the environment and ICICLE_CHAIN_<chainID>_<FIELD> environment
+// variables override fields.
 func LoadConfig(path string) ([]ChainConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
-
-	var configs []ChainConfig
-	if err := yaml.Unmarshal(data, &configs); err != nil {
+	configs, err := parseConfig(data)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
+	if err := applyEnvOverrides(configs); err != nil {
+		return nil, fmt.Errorf("failed to apply environment overrides: %w", err)
+	}
 
 	// Validate configurations
 	for i, cfg := range configs {
@@ -58,33 +229,248 @@ func LoadConfig(path string) ([]ChainConfig, error) {
 		if cfg.VM == "" {
 			return nil, fmt.Errorf("chain at index %d: VM type is required", i)
 		}
-		if cfg.RpcURL == "" {
+		if cfg.RpcURL == "" && !cfg.Offline {
 			return nil, fmt.Errorf("chain at index %d: rpcURL is required", i)
 		}
 		if cfg.Name == "" {
 			return nil, fmt.Errorf("chain at index %d: name is required", i)
 		}
+		if _, err := cache.ParseTTLs(cfg.CacheTTLs); err != nil {
+			return nil, fmt.Errorf("chain at index %d: cacheTTLs: %w", i, err)
+		}
+		if cfg.FollowDistance < 0 {
+			return nil, fmt.Errorf("chain at index %d: followDistance cannot be negative", i)
+		}
+		if cfg.FollowTag != "" && cfg.FollowTag != "finalized" && cfg.FollowTag != "safe" {
+			return nil, fmt.Errorf("chain at index %d: followTag must be \"finalized\" or \"safe\"", i)
+		}
+		if cfg.Offline && cfg.VM != "evm" {
+			return nil, fmt.Errorf("chain at index %d: offline is only supported for EVM chains", i)
+		}
+		if cfg.Offline && (cfg.FollowTag != "" || cfg.HeadTable || cfg.IndexURL != "") {
+			return nil, fmt.Errorf("chain at index %d: offline chains can't use followTag, headTable or indexURL, they need the RPC", i)
+		}
+		if cfg.StreamBatchSize < 0 {
+			return nil, fmt.Errorf("chain at index %d: streamBatchSize cannot be negative", i)
+		}
+		if cfg.MaxFetchBatchSize < 0 || cfg.FetchBatchMB < 0 {
+			return nil, fmt.Errorf("chain at index %d: maxFetchBatchSize and fetchBatchMB cannot be negative", i)
+		}
+		if len(cfg.InsertSettings) > 0 && cfg.VM != "evm" && cfg.VM != "p" {
+			return nil, fmt.Errorf("chain at index %d: insertSettings is only supported for EVM and P-Chain chains", i)
+		}
+		if cfg.SpoolDir != "" && cfg.VM != "evm" {
+			return nil, fmt.Errorf("chain at index %d: spoolDir is only supported for EVM chains", i)
+		}
+		if cfg.NetworkID != 0 && cfg.VM != "p" {
+			return nil, fmt.Errorf("chain at index %d: networkID is only supported for the P-chain, EVM chains are checked against chainID", i)
+		}
+		if cfg.ValidatorSyncInterval < 0 || cfg.ValidatorPriorityInterval < 0 || cfg.ValidatorSubnetSyncInterval < 0 {
+			return nil, fmt.Errorf("chain at index %d: validatorSyncInterval, validatorPriorityInterval and validatorSubnetSyncInterval cannot be negative", i)
+		}
+		if cfg.SpoolMaxGB < 0 {
+			return nil, fmt.Errorf("chain at index %d: spoolMaxGB cannot be negative", i)
+		}
+		if err := pchainsyncer.CheckTxStorage(cfg.TxStorage); err != nil {
+			return nil, fmt.Errorf("chain at index %d: txStorage: %w", i, err)
+		}
+		if cfg.RecomputeLastNPeriods < 0 {
+			return nil, fmt.Errorf("chain at index %d: recomputeLastNPeriods cannot be negative", i)
+		}
+		if cfg.FeeAsset != "" {
+			if err := evmindexer.CheckFeeAsset(cfg.FeeAsset); err != nil {
+				return nil, fmt.Errorf("chain at index %d: feeAsset: %w", i, err)
+			}
+		}
+		if _, err := evmindexer.LoadTimezone(cfg.Timezone); err != nil {
+			return nil, fmt.Errorf("chain at index %d: timezone: %w", i, err)
+		}
 	}
 
 	return configs, nil
 }
 
+// parseConfig decodes the chains of a config file, each over a fresh copy of the defaults so
+// chains never share the maps and pointers of the defaults
+func parseConfig(data []byte) ([]ChainConfig, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if err := interpolateEnv(&doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	var file configFile
+	switch root := doc.Content[0]; root.Kind {
+	case yaml.SequenceNode:
+		file.Chains = make([]yaml.Node, len(root.Content))
+		for i, chain := range root.Content {
+			file.Chains[i] = *chain
+		}
+	case yaml.MappingNode:
+		if err := root.Decode(&file); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("expected a list of chains or a mapping of defaults and chains")
+	}
+
+	configs := make([]ChainConfig, len(file.Chains))
+	for i := range file.Chains {
+		if !file.Defaults.IsZero() {
+			if err := file.Defaults.Decode(&configs[i]); err != nil {
+				return nil, fmt.Errorf("defaults: %w", err)
+			}
+		}
+		if err := file.Chains[i].Decode(&configs[i]); err != nil {
+			return nil, fmt.Errorf("chain at index %d: %w", i, err)
+		}
+	}
+	return configs, nil
+}
+
+// rpcHeaders returns the HTTP headers sent with every RPC request of a chain, rpcHeaders plus
+// the bearer token if rpcAuthToken is set
+func rpcHeaders(cfg ChainConfig) map[string]string {
+	headers := make(map[string]string, len(cfg.RpcHeaders)+1)
+	for key, value := range cfg.RpcHeaders {
+		headers[key] = value
+	}
+	if cfg.RpcAuthToken != "" {
+		headers["Authorization"] = "Bearer " + cfg.RpcAuthToken
+	}
+	return headers
+}
+
+// cacheTTLs returns the cache namespaces of a chain, validated by LoadConfig
+func cacheTTLs(cfg ChainConfig) cache.TTLs {
+	ttls, _ := cache.ParseTTLs(cfg.CacheTTLs)
+	return ttls
+}
+
+// gapCheckInterval returns how often a chain is checked for missing blocks, 0 if never
+func gapCheckInterval(cfg ChainConfig) time.Duration {
+	switch {
+	case cfg.GapCheckInterval < 0:
+		return 0
+	case cfg.GapCheckInterval == 0:
+		return 10 * time.Minute
+	}
+	return time.Duration(cfg.GapCheckInterval) * time.Minute
+}
+
+// memoryBudget returns the bytes of fetched blocks a chain may hold before they are written, 0
+// for the syncer's default and negative if unlimited
+func memoryBudget(cfg ChainConfig) int64 {
+	if cfg.MemoryBudgetMB < 0 {
+		return -1
+	}
+	return int64(cfg.MemoryBudgetMB) << 20
+}
+
+// spoolDir returns the directory of a chain's outage spool, empty if it has none
+func spoolDir(cfg ChainConfig) string {
+	if cfg.SpoolDir == "" {
+		return ""
+	}
+	return filepath.Join(cfg.SpoolDir, fmt.Sprintf("%d", cfg.ChainID))
+}
+
+// spoolMaxBytes returns the most bytes a chain may spool
+func spoolMaxBytes(cfg ChainConfig) int64 {
+	if cfg.SpoolMaxGB == 0 {
+		return 10 << 30
+	}
+	return int64(cfg.SpoolMaxGB) << 30
+}
+
+// cacheDir returns the directory of a chain's local RPC cache
+func cacheDir(cfg ChainConfig) string {
+	if cfg.CacheDir != "" {
+		return cfg.CacheDir
+	}
+	return cache.Dir()
+}
+
+// chainCacheDirs returns the chains of config.yaml that set cacheDir, empty without a readable config
+func chainCacheDirs() map[uint32]string {
+	dirs := make(map[uint32]string)
+	configs, err := LoadConfig(configPath)
+	if err != nil {
+		return dirs
+	}
+	for _, cfg := range configs {
+		if cfg.CacheDir != "" {
+			dirs[cfg.ChainID] = cfg.CacheDir
+		}
+	}
+	return dirs
+}
+
+// chainCacheDir returns the directory of a chain's local RPC cache, for commands that don't
+// otherwise need config.yaml
+func chainCacheDir(chainID uint32) string {
+	if dir, ok := chainCacheDirs()[chainID]; ok {
+		return dir
+	}
+	return cache.Dir()
+}
+
 // CreateSyncer creates the appropriate syncer based on VM type
-func CreateSyncer(cfg ChainConfig, conn driver.Conn, cacheInstance *cache.Cache, fast bool) (Syncer, error) {
+func CreateSyncer(cfg ChainConfig, conn driver.Conn, cacheInstance cache.Cache, fast bool, loadShedder *loadshed.Monitor, sink evmsyncer.Sink) (Syncer, error) {
 	switch cfg.VM {
 	case "evm":
 		return evmsyncer.NewChainSyncer(evmsyncer.Config{
-			ChainID:        cfg.ChainID,
-			RpcURL:         cfg.RpcURL,
-			StartBlock:     cfg.StartBlock,
-			MaxConcurrency: cfg.MaxConcurrency,
-			CHConn:         conn,
-			Cache:          cacheInstance,
-			FetchBatchSize: cfg.FetchBatchSize,
-			RpcBatchSize:   cfg.RpcBatchSize,
-			DebugBatchSize: cfg.DebugBatchSize,
-			Name:           cfg.Name,
-			Fast:           fast,
+			ChainID:         cfg.ChainID,
+			RpcURL:          cfg.RpcURL,
+			TraceRpcURL:     cfg.TraceRpcURL,
+			RpcHeaders:      rpcHeaders(cfg),
+			NoCompression:   cfg.RpcCompression != nil && !*cfg.RpcCompression,
+			StartBlock:      cfg.StartBlock,
+			MaxConcurrency:  cfg.MaxConcurrency,
+			CHConn:          conn,
+			Sink:            sink,
+			Cache:           cacheInstance,
+			CacheTTLs:       cacheTTLs(cfg),
+			FetchBatchSize:  cfg.FetchBatchSize,
+			StreamBatchSize: cfg.StreamBatchSize,
+			MemoryBudget:    memoryBudget(cfg),
+			RpcBatchSize:    cfg.RpcBatchSize,
+			DebugBatchSize:  cfg.DebugBatchSize,
+			Name:            cfg.Name,
+			Fast:            fast || cfg.StandaloneIndexer,
+			FeeAsset:        cfg.FeeAsset,
+			IndexerWorkers:  cfg.IndexerWorkers,
+			Timezone:        cfg.Timezone,
+			Recompute:       cfg.RecomputeLastNPeriods,
+			IndexerSettings: cfg.IndexerSettings,
+			InsertSettings:  cfg.InsertSettings,
+			IndexURL:        cfg.IndexURL,
+			FetchUncles:     cfg.FetchUncles,
+			SkipTraces:      cfg.FetchTraces != nil && !*cfg.FetchTraces,
+			SkipLogs:        cfg.FetchLogs != nil && !*cfg.FetchLogs,
+			StorePayloads:   cfg.StorePayloads,
+			Offline:         cfg.Offline,
+			LoadShedder:     loadShedder,
+
+			GapCheckInterval: gapCheckInterval(cfg),
+
+			SkipIntegrityCheck: cfg.SkipIntegrityCheck,
+			SkipChainIDCheck:   cfg.SkipChainIDCheck,
+
+			MaxFetchBatchSize: cfg.MaxFetchBatchSize,
+			FetchBatchBytes:   int64(cfg.FetchBatchMB) << 20,
+
+			SpoolDir:      spoolDir(cfg),
+			SpoolMaxBytes: spoolMaxBytes(cfg),
+
+			FollowDistance: cfg.FollowDistance,
+			FollowTag:      cfg.FollowTag,
+			HeadTable:      cfg.HeadTable,
 		})
 
 	case "p":
@@ -95,16 +481,62 @@ func CreateSyncer(cfg ChainConfig, conn driver.Conn, cacheInstance *cache.Cache,
 		}
 
 		return pchainsyncer.NewPChainSyncer(pchainsyncer.Config{
-			RpcURL:                cfg.RpcURL,
-			StartBlock:            cfg.StartBlock,
-			MaxConcurrency:        cfg.MaxConcurrency,
-			FetchBatchSize:        cfg.FetchBatchSize,
-			CHConn:                conn,
-			Cache:                 cacheInstance,
-			ChainID:               cfg.ChainID,
-			Name:                  cfg.Name,
-			EnableValidatorSync:   cfg.EnableValidatorSync,
-			ValidatorSyncInterval: validatorSyncInterval,
+			RpcURL:                    cfg.RpcURL,
+			RpcHeaders:                rpcHeaders(cfg),
+			NoCompression:             cfg.RpcCompression != nil && !*cfg.RpcCompression,
+			StartBlock:                cfg.StartBlock,
+			MaxConcurrency:            cfg.MaxConcurrency,
+			FetchBatchSize:            cfg.FetchBatchSize,
+			RpcBatchSize:              cfg.RpcBatchSize,
+			CHConn:                    conn,
+			Cache:                     cacheInstance,
+			CacheTTLs:                 cacheTTLs(cfg),
+			ChainID:                   cfg.ChainID,
+			Name:                      cfg.Name,
+			EnableValidatorSync:       cfg.EnableValidatorSync,
+			ValidatorSyncInterval:     validatorSyncInterval,
+			ValidatorSnapshotInterval: time.Duration(cfg.ValidatorSnapshotInterval) * time.Hour,
+			TxBlobMinSize:             cfg.TxBlobMinSize,
+			TxStorage:                 cfg.TxStorage,
+			ParseWorkers:              cfg.ParseWorkers,
+			PinParseWorkers:           cfg.PinParseWorkers,
+			IndexURL:                  cfg.IndexURL,
+			ValidatorDiscoveryMode:    cfg.ValidatorDiscoveryMode,
+			ValidatorSyncSubnets:      cfg.ValidatorSyncSubnets,
+			ValidatorSyncExclude:      cfg.ValidatorSyncExcludeSubnets,
+			ValidatorPrioritySubnets:  cfg.ValidatorPrioritySubnets,
+			ValidatorPriorityInterval: time.Duration(cfg.ValidatorPriorityInterval) * time.Minute,
+			ValidatorSubnetInterval:   time.Duration(cfg.ValidatorSubnetSyncInterval) * time.Minute,
+			StorePayloads:             cfg.StorePayloads,
+			InsertSettings:            cfg.InsertSettings,
+			LoadShedder:               loadShedder,
+
+			StreamBatchSize: cfg.StreamBatchSize,
+			MemoryBudget:    memoryBudget(cfg),
+
+			MaxFetchBatchSize: cfg.MaxFetchBatchSize,
+			FetchBatchBytes:   int64(cfg.FetchBatchMB) << 20,
+
+			GapCheckInterval: gapCheckInterval(cfg),
+
+			SkipIntegrityCheck: cfg.SkipIntegrityCheck,
+			NetworkID:          cfg.NetworkID,
+			SkipChainIDCheck:   cfg.SkipChainIDCheck || cfg.Offline, // Offline chains don't reach the node
+		})
+
+	case "hypersdk":
+		return hypersdksyncer.NewHyperSDKSyncer(hypersdksyncer.Config{
+			ChainID:        cfg.ChainID,
+			RpcURL:         cfg.RpcURL,
+			RpcHeaders:     rpcHeaders(cfg),
+			NoCompression:  cfg.RpcCompression != nil && !*cfg.RpcCompression,
+			StartBlock:     cfg.StartBlock,
+			MaxConcurrency: cfg.MaxConcurrency,
+			FetchBatchSize: cfg.FetchBatchSize,
+			CHConn:         conn,
+			Cache:          cacheInstance,
+			Name:           cfg.Name,
+			LoadShedder:    loadShedder,
 		})
 
 	default:
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		configs []ChainConfig
+		err     string
+	}{
+		{
+			name: "list of chains",
+			data: `
+- name: C-Chain
+  chainID: 43114
+  vm: evm
+  rpcURL: http://127.0.0.1:9650/ext/bc/C/rpc
+- name: P-Chain
+  vm: p
+  rpcURL: http://127.0.0.1:9650
+`,
+			configs: []ChainConfig{
+				{Name: "C-Chain", ChainID: 43114, VM: "evm", RpcURL: "http://127.0.0.1:9650/ext/bc/C/rpc"},
+				{Name: "P-Chain", VM: "p", RpcURL: "http://127.0.0.1:9650"},
+			},
+		},
+		{
+			name: "defaults overridden per chain",
+			data: `
+defaults:
+  vm: evm
+  maxConcurrency: 20
+  rpcHeaders: {x-api-key: secret}
+  cacheRetention: {maxSizeGB: 50, ttlHours: 24}
+chains:
+  - name: C-Chain
+    chainID: 43114
+  - name: Dispatch
+    chainID: 779672
+    maxConcurrency: 5
+    cacheRetention: {maxSizeGB: 10}
+`,
+			configs: []ChainConfig{
+				{Name: "C-Chain", ChainID: 43114, VM: "evm", MaxConcurrency: 20, RpcHeaders: map[string]string{"x-api-key": "secret"},
+					CacheRetention: CacheRetention{MaxSizeGB: 50, TTLHours: 24}},
+				{Name: "Dispatch", ChainID: 779672, VM: "evm", MaxConcurrency: 5, RpcHeaders: map[string]string{"x-api-key": "secret"},
+					CacheRetention: CacheRetention{MaxSizeGB: 10, TTLHours: 24}},
+			},
+		},
+		{
+			name:    "json",
+			data:    `{"chains": [{"name": "C-Chain", "chainID": 43114, "vm": "evm"}]}`,
+			configs: []ChainConfig{{Name: "C-Chain", ChainID: 43114, VM: "evm"}},
+		},
+		{
+			name:    "empty",
+			data:    "",
+			configs: nil,
+		},
+		{
+			name: "scalar root",
+			data: "chains",
+			err:  "expected a list of chains",
+		},
+		{
+			name: "invalid chain field",
+			data: "chains:\n  - name: C-Chain\n    chainID: forty\n",
+			err:  "chain at index 0",
+		},
+		{
+			name: "invalid default field",
+			data: "defaults:\n  maxConcurrency: many\nchains:\n  - name: C-Chain\n",
+			err:  "defaults",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs, err := parseConfig([]byte(tt.data))
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.configs, configs)
+		})
+	}
+}
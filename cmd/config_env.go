@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes the environment variables overriding flags and config fields
+const EnvPrefix = "ICICLE_"
+
+// chainEnvPrefix prefixes the environment variables overriding a chain's config fields, followed by
+// its chain ID and the field, e.g. ICICLE_CHAIN_43114_RPCURL
+const chainEnvPrefix = EnvPrefix + "CHAIN_"
+
+// envReferencePattern matches ${VAR} and ${VAR:-default} references in config files. $${VAR} is
+// kept as a literal ${VAR}.
+var envReferencePattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}`)
+
+// interpolateEnv replaces the ${VAR} references in the values of a parsed config file with the
+// environment. Keys and comments are left as they are, so a commented-out reference doesn't need
+// its variable. A variable that is unset without a default is an error, so a missing secret fails
+// at startup.
+func interpolateEnv(doc *yaml.Node) error {
+	var missing []string
+	interpolateNode(doc, &missing)
+	if len(missing) > 0 {
+		return fmt.Errorf("unset environment variables: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// interpolateNode replaces the references in the scalar values under node, appending unset
+// variables to missing. Aliases aren't followed, their anchor is replaced where it is defined.
+func interpolateNode(node *yaml.Node, missing *[]string) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			interpolateNode(child, missing)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			interpolateNode(node.Content[i], missing)
+		}
+	case yaml.ScalarNode:
+		value := expandEnv(node.Value, missing)
+		if value == node.Value {
+			return
+		}
+		node.Value = value
+		// A plain scalar's type follows its new value, so ${PORT} can fill an int field
+		if node.Style == 0 {
+			node.Tag = ""
+		}
+	}
+}
+
+// expandEnv replaces the references in s, appending unset variables to missing
+func expandEnv(s string, missing *[]string) string {
+	return envReferencePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref[1] == '$' {
+			return ref[1:]
+		}
+		m := envReferencePattern.FindStringSubmatch(ref)
+		if value, ok := os.LookupEnv(m[1]); ok {
+			return value
+		}
+		if len(m[2]) > 0 {
+			return m[2][len(":-"):]
+		}
+		*missing = append(*missing, m[1])
+		return ref
+	})
+}
+
+// applyEnvOverrides sets the config fields named by ICICLE_CHAIN_<chainID>_<FIELD> environment
+// variables. FIELD is the field's YAML key in upper case, with nested keys joined by underscores,
+// e.g. ICICLE_CHAIN_43114_CACHERETENTION_MAXSIZEGB. Values are YAML, so maps and lists are written
+// like {x-api-key: secret} and [a, b].
+func applyEnvOverrides(configs []ChainConfig) error {
+	fields := envFields(reflect.TypeOf(ChainConfig{}), "", nil)
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		rest, ok := strings.CutPrefix(name, chainEnvPrefix)
+		if !ok {
+			continue
+		}
+		idStr, key, ok := strings.Cut(rest, "_")
+		chainID, err := strconv.ParseUint(idStr, 10, 32)
+		if !ok || err != nil {
+			return fmt.Errorf("%s: expected %s<chainID>_<FIELD>", name, chainEnvPrefix)
+		}
+		index, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("%s: unknown config field %s", name, key)
+		}
+
+		var found bool
+		for i := range configs {
+			if configs[i].ChainID != uint32(chainID) {
+				continue
+			}
+			found = true
+			if err := setEnvField(reflect.ValueOf(&configs[i]).Elem().FieldByIndex(index), value); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		if !found {
+			slog.Warn("Ignoring environment override of a chain not in the config", "variable", name)
+		}
+	}
+	return nil
+}
+
+// envFields maps the environment keys of the fields of struct type t to their indexes, recursing
+// into nested structs
+func envFields(t reflect.Type, prefix string, index []int) map[string][]int {
+	fields := make(map[string][]int)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		key := prefix + strings.ToUpper(tag)
+		fieldIndex := append(append([]int(nil), index...), i)
+		if field.Type.Kind() == reflect.Struct {
+			for nested, nestedIndex := range envFields(field.Type, key+"_", fieldIndex) {
+				fields[nested] = nestedIndex
+			}
+			continue
+		}
+		fields[key] = fieldIndex
+	}
+	return fields
+}
+
+// setEnvField sets a config field from an environment variable. Strings are taken as they are,
+// anything else is parsed as YAML.
+func setEnvField(field reflect.Value, value string) error {
+	if field.Kind() == reflect.String {
+		field.SetString(value)
+		return nil
+	}
+	target := reflect.New(field.Type())
+	if err := yaml.Unmarshal([]byte(value), target.Interface()); err != nil {
+		return fmt.Errorf("invalid value %q: %w", value, err)
+	}
+	field.Set(target.Elem())
+	return nil
+}
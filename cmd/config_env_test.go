@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolateEnv(t *testing.T) {
+	t.Setenv("ICICLE_TEST_RPC", "http://node:9650/ext/bc/C/rpc")
+	t.Setenv("ICICLE_TEST_CONCURRENCY", "8")
+
+	tests := []struct {
+		name    string
+		data    string
+		configs []ChainConfig
+		err     string
+	}{
+		{
+			name:    "string and int values",
+			data:    "- name: C-Chain\n  rpcURL: ${ICICLE_TEST_RPC}\n  maxConcurrency: ${ICICLE_TEST_CONCURRENCY}\n",
+			configs: []ChainConfig{{Name: "C-Chain", RpcURL: "http://node:9650/ext/bc/C/rpc", MaxConcurrency: 8}},
+		},
+		{
+			name:    "default of an unset variable",
+			data:    "- name: ${ICICLE_TEST_UNSET:-C-Chain}\n  rpcURL: ${ICICLE_TEST_RPC:-http://localhost}\n",
+			configs: []ChainConfig{{Name: "C-Chain", RpcURL: "http://node:9650/ext/bc/C/rpc"}},
+		},
+		{
+			name:    "escaped reference",
+			data:    "- name: $${ICICLE_TEST_RPC}\n",
+			configs: []ChainConfig{{Name: "${ICICLE_TEST_RPC}"}},
+		},
+		{
+			name:    "quoted values stay strings",
+			data:    "- name: \"${ICICLE_TEST_CONCURRENCY}\"\n",
+			configs: []ChainConfig{{Name: "8"}},
+		},
+		{
+			name:    "comments and keys aren't interpolated",
+			data:    "# rpcURL: ${ICICLE_TEST_UNSET}\n- name: C-Chain\n",
+			configs: []ChainConfig{{Name: "C-Chain"}},
+		},
+		{
+			name: "unset variables",
+			data: "- name: ${ICICLE_TEST_UNSET}\n  rpcURL: ${ICICLE_TEST_OTHER}\n",
+			err:  "unset environment variables: ICICLE_TEST_UNSET, ICICLE_TEST_OTHER",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs, err := parseConfig([]byte(tt.data))
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.configs, configs)
+		})
+	}
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want ChainConfig
+		err  string
+	}{
+		{
+			name: "string field",
+			env:  map[string]string{"ICICLE_CHAIN_43114_RPCURL": "http://other:9650"},
+			want: ChainConfig{ChainID: 43114, RpcURL: "http://other:9650"},
+		},
+		{
+			name: "nested field",
+			env:  map[string]string{"ICICLE_CHAIN_43114_CACHERETENTION_MAXSIZEGB": "12.5"},
+			want: ChainConfig{ChainID: 43114, RpcURL: "http://node:9650", CacheRetention: CacheRetention{MaxSizeGB: 12.5}},
+		},
+		{
+			name: "yaml map",
+			env:  map[string]string{"ICICLE_CHAIN_43114_RPCHEADERS": "{x-api-key: secret}"},
+			want: ChainConfig{ChainID: 43114, RpcURL: "http://node:9650", RpcHeaders: map[string]string{"x-api-key": "secret"}},
+		},
+		{
+			name: "other chain ignored",
+			env:  map[string]string{"ICICLE_CHAIN_1_RPCURL": "http://other:9650"},
+			want: ChainConfig{ChainID: 43114, RpcURL: "http://node:9650"},
+		},
+		{
+			name: "unknown field",
+			env:  map[string]string{"ICICLE_CHAIN_43114_RPC": "http://other:9650"},
+			err:  "unknown config field RPC",
+		},
+		{
+			name: "missing chain ID",
+			env:  map[string]string{"ICICLE_CHAIN_RPCURL": "http://other:9650"},
+			err:  "expected ICICLE_CHAIN_<chainID>_<FIELD>",
+		},
+		{
+			name: "invalid value",
+			env:  map[string]string{"ICICLE_CHAIN_43114_MAXCONCURRENCY": "many"},
+			err:  "invalid value \"many\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			configs := []ChainConfig{{ChainID: 43114, RpcURL: "http://node:9650"}}
+			err := applyEnvOverrides(configs)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, configs[0])
+		})
+	}
+}
@@ -0,0 +1,400 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+const (
+	// DefaultLimit is the page size of list routes when the request has no limit
+	DefaultLimit = 100
+	// MaxLimit is the largest page size a request may ask for
+	MaxLimit = 1000
+	// QueryTimeout bounds the ClickHouse execution time of one request
+	QueryTimeout = 30 * time.Second
+)
+
+// granularities are the metric periods the indexers compute
+var granularities = map[string]bool{"5min": true, "hour": true, "day": true, "week": true, "month": true, "year": true}
+
+// Server answers read-only queries over the ingested data as JSON, for consumers without
+// ClickHouse access.
+//
+// Routes, list routes take limit (default 100, at most 1000) and offset:
+//
+//	GET /v1/chains                          ingested chains with their RPC tip and sync watermark
+//	GET /v1/chains/{chain}/metrics/{metric} metric values by period, e.g. tx_count, active_addresses, gas_used
+//	                                        (granularity=5min|hour|day|week|month|year, default day, from and to as dates)
+//	GET /v1/chains/{chain}/blocks/{number}  one block
+//	GET /v1/chains/{chain}/txs/{hash}       one transaction with its receipt fields
+//	GET /v1/validators                      L1 validator state (p_chain, subnet_id, active)
+//	GET /v1/l1s                             L1s with registry metadata and validator counts (p_chain)
+type Server struct {
+	conn   driver.Conn
+	keys   []string
+	logger *slog.Logger
+}
+
+// NewServer returns a server querying conn. Requests must carry one of keys in the X-API-Key
+// header or as a bearer token, unless keys is empty.
+func NewServer(conn driver.Conn, keys []string) *Server {
+	s := &Server{
+		conn:   conn,
+		logger: slog.With("component", "api"),
+	}
+	for _, key := range keys {
+		if key != "" {
+			s.keys = append(s.keys, key)
+		}
+	}
+	return s
+}
+
+// Handler returns the HTTP handler serving the routes of Server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/chains", s.handleChains)
+	mux.HandleFunc("GET /v1/chains/{chain}/metrics/{metric}", s.handleMetric)
+	mux.HandleFunc("GET /v1/chains/{chain}/blocks/{number}", s.handleBlock)
+	mux.HandleFunc("GET /v1/chains/{chain}/txs/{hash}", s.handleTx)
+	mux.HandleFunc("GET /v1/chains/{chain}/indexers/quarantined", s.handleQuarantined)
+	mux.HandleFunc("GET /v1/validators", s.handleValidators)
+	mux.HandleFunc("GET /v1/l1s", s.handleL1s)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			writeError(w, http.StatusUnauthorized, "missing or invalid API key")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// authorized reports whether the request carries a valid API key
+func (s *Server) authorized(r *http.Request) bool {
+	if len(s.keys) == 0 {
+		return true
+	}
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleChains(w http.ResponseWriter, r *http.Request) {
+	s.list(w, r, `
+		SELECT c.chain_id, c.name, c.last_block_on_chain, w.block_number AS synced_block,
+		       c.degraded_reason, c.last_updated
+		FROM chain_status AS c FINAL
+		LEFT JOIN sync_watermark AS w ON w.chain_id = c.chain_id
+		ORDER BY c.chain_id`)
+}
+
+func (s *Server) handleMetric(w http.ResponseWriter, r *http.Request) {
+	chainID, err := parseChain(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	granularity := r.URL.Query().Get("granularity")
+	if granularity == "" {
+		granularity = "day"
+	}
+	if !granularities[granularity] {
+		writeError(w, http.StatusBadRequest, "granularity must be 5min, hour, day, week, month or year")
+		return
+	}
+	from, err := parseTime(r.URL.Query().Get("from"), time.Time{})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+		return
+	}
+	to, err := parseTime(r.URL.Query().Get("to"), time.Now().UTC())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+		return
+	}
+
+	s.list(w, r, `
+		SELECT period, value, asset
+		FROM metrics FINAL
+		WHERE chain_id = ? AND metric_name = ? AND granularity = ? AND period >= ? AND period <= ?
+		ORDER BY period`, chainID, r.PathValue("metric"), granularity, from, to)
+}
+
+func (s *Server) handleBlock(w http.ResponseWriter, r *http.Request) {
+	chainID, err := parseChain(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	number, err := strconv.ParseUint(r.PathValue("number"), 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid block number %q", r.PathValue("number")))
+		return
+	}
+
+	s.one(w, r, `
+		SELECT chain_id, block_number, `+hexColumn("hash")+`, `+hexColumn("parent_hash")+`, block_time,
+		       `+hexColumn("miner")+`, size, gas_limit, gas_used, base_fee_per_gas, block_gas_cost, proposer,
+		       (SELECT count() FROM raw_txs WHERE chain_id = ? AND block_number = ?) AS transaction_count
+		FROM raw_blocks
+		WHERE chain_id = ? AND block_number = ?
+		LIMIT 1`, chainID, uint32(number), chainID, uint32(number))
+}
+
+func (s *Server) handleTx(w http.ResponseWriter, r *http.Request) {
+	chainID, err := parseChain(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	hash := strings.TrimPrefix(strings.ToLower(r.PathValue("hash")), "0x")
+	if b, err := hex.DecodeString(hash); err != nil || len(b) != 32 {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid transaction hash %q", r.PathValue("hash")))
+		return
+	}
+
+	s.one(w, r, `
+		SELECT chain_id, `+hexColumn("hash")+`, block_number, `+hexColumn("block_hash")+`, block_time,
+		       transaction_index, nonce, `+hexColumn("from")+`, `+hexColumn("to")+`, toString(value) AS value,
+		       gas_limit, gas_price, gas_used, effective_gas_price, success, type,
+		       `+hexColumn("contract_address")+`, `+hexColumn("input")+`
+		FROM raw_txs
+		WHERE chain_id = ? AND hash = unhex(?)
+		LIMIT 1`, chainID, hash)
+}
+
+func (s *Server) handleQuarantined(w http.ResponseWriter, r *http.Request) {
+	chainID, err := parseChain(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.list(w, r, `
+		SELECT indexer_name, granularity, attempts, error, updated_at AS quarantined_at
+		FROM indexer_quarantine FINAL
+		WHERE chain_id = ? AND quarantined
+		ORDER BY indexer_name, granularity`, chainID)
+}
+
+func (s *Server) handleValidators(w http.ResponseWriter, r *http.Request) {
+	query := `
+		SELECT subnet_id, validation_id, node_id, balance, weight, start_time, end_time,
+		       uptime_percentage, active, initial_deposit, total_topups, refund_amount, fees_paid, last_updated
+		FROM l1_validator_state FINAL
+		WHERE p_chain_id = ?`
+	pChainID, err := parsePChain(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	args := []any{pChainID}
+
+	if subnetID := r.URL.Query().Get("subnet_id"); subnetID != "" {
+		query += " AND subnet_id = ?"
+		args = append(args, subnetID)
+	}
+	if active := r.URL.Query().Get("active"); active != "" {
+		isActive, err := strconv.ParseBool(active)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid active %q", active))
+			return
+		}
+		query += " AND active = ?"
+		args = append(args, isActive)
+	}
+
+	s.list(w, r, query+" ORDER BY subnet_id, validation_id", args...)
+}
+
+func (s *Server) handleL1s(w http.ResponseWriter, r *http.Request) {
+	pChainID, err := parsePChain(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.list(w, r, `
+		SELECT s.subnet_id, s.chain_id AS blockchain_id, s.converted_block, s.converted_time,
+		       r.name, r.description, r.logo_url, r.website_url,
+		       v.active_validators, v.total_weight
+		FROM subnets AS s FINAL
+		LEFT JOIN (SELECT * FROM l1_registry FINAL) AS r ON r.subnet_id = s.subnet_id
+		LEFT JOIN (
+			SELECT subnet_id, countIf(active) AS active_validators, sumIf(weight, active) AS total_weight
+			FROM l1_validator_state FINAL
+			WHERE p_chain_id = ?
+			GROUP BY subnet_id
+		) AS v ON v.subnet_id = s.subnet_id
+		WHERE s.p_chain_id = ? AND s.subnet_type = 'l1'
+		ORDER BY s.converted_block, s.subnet_id`, pChainID, pChainID)
+}
+
+// list writes a page of the query's rows. The query must not have LIMIT or OFFSET.
+func (s *Server) list(w http.ResponseWriter, r *http.Request, query string, args ...any) {
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// One row past the page tells whether there is a next one
+	rows, err := s.query(r.Context(), query+" LIMIT ? OFFSET ?", append(args, limit+1, offset)...)
+	if err != nil {
+		s.logger.Error("Query failed", "path", r.URL.Path, "error", err)
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+
+	page := struct {
+		Data       []map[string]any `json:"data"`
+		Limit      int              `json:"limit"`
+		Offset     int              `json:"offset"`
+		NextOffset *int             `json:"next_offset"` // null on the last page
+	}{Data: rows, Limit: limit, Offset: offset}
+	if len(rows) > limit {
+		page.Data = rows[:limit]
+		next := offset + limit
+		page.NextOffset = &next
+	}
+	if page.Data == nil {
+		page.Data = []map[string]any{}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// one writes the query's first row, 404 if it has none
+func (s *Server) one(w http.ResponseWriter, r *http.Request, query string, args ...any) {
+	rows, err := s.query(r.Context(), query, args...)
+	if err != nil {
+		s.logger.Error("Query failed", "path", r.URL.Path, "error", err)
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	if len(rows) == 0 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, rows[0])
+}
+
+// query returns the rows of a query as column name to value maps
+func (s *Server) query(ctx context.Context, query string, args ...any) ([]map[string]any, error) {
+	ctx = clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{
+		"max_execution_time": int(QueryTimeout.Seconds()),
+		"readonly":           2,
+		// WHERE compares the stored column, not the hexColumn alias of the same name
+		"prefer_column_name_to_alias": 1,
+	}))
+	rows, err := s.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := rows.Columns()
+	types := rows.ColumnTypes()
+	var result []map[string]any
+	for rows.Next() {
+		values := make([]any, len(types))
+		for i, t := range types {
+			values[i] = reflect.New(t.ScanType()).Interface()
+		}
+		if err := rows.Scan(values...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]any, len(columns))
+		for i, column := range columns {
+			row[column] = reflect.ValueOf(values[i]).Elem().Interface()
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// hexColumn selects a binary column as 0x-prefixed lowercase hex, keeping its name (null stays null)
+func hexColumn(column string) string {
+	return fmt.Sprintf("concat('0x', lower(hex(`%s`))) AS `%s`", column, column)
+}
+
+// parsePage returns the limit and offset of a list request
+func parsePage(r *http.Request) (limit, offset int, err error) {
+	limit = DefaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+		}
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+	}
+	return limit, offset, nil
+}
+
+// parseChain returns the chain ID in a request's path
+func parseChain(r *http.Request) (uint32, error) {
+	id, err := strconv.ParseUint(r.PathValue("chain"), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid chain ID %q", r.PathValue("chain"))
+	}
+	return uint32(id), nil
+}
+
+// parsePChain returns the P-chain instance a request asks for, 0 (the only one ingested) by default
+func parsePChain(r *http.Request) (uint32, error) {
+	v := r.URL.Query().Get("p_chain")
+	if v == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid p_chain %q", v)
+	}
+	return uint32(id), nil
+}
+
+// parseTime parses a date or RFC 3339 time, def when empty
+func parseTime(v string, def time.Time) (time.Time, error) {
+	if v == "" {
+		return def, nil
+	}
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
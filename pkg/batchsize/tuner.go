@@ -0,0 +1,47 @@
+package batchsize
+
+// smoothing is the weight of the latest observation in the average block size
+const smoothing = 0.3
+
+// Tuner scales how many blocks a syncer fetches at once so a batch weighs about a target number
+// of bytes, going by the size of recently fetched blocks. Ranges of near-empty blocks are then
+// fetched thousands at a time while dense blocks are fetched a few at a time. A nil Tuner keeps
+// the configured sizes. Not safe for concurrent use.
+type Tuner struct {
+	target   int64   // Bytes per fetch batch
+	limit    int     // Most blocks per fetch batch
+	avgBlock float64 // Moving average of bytes per block, 0 until the first observation
+}
+
+// New creates a tuner aiming for target bytes per fetch batch of at most limit blocks. Returns
+// nil if either is not positive.
+func New(target int64, limit int) *Tuner {
+	if target <= 0 || limit <= 0 {
+		return nil
+	}
+	return &Tuner{target: target, limit: limit}
+}
+
+// Observe records that blocks fetched blocks take bytes in memory
+func (t *Tuner) Observe(blocks int, bytes int64) {
+	if t == nil || blocks == 0 {
+		return
+	}
+	avg := float64(bytes) / float64(blocks)
+	if t.avgBlock == 0 {
+		t.avgBlock = avg
+		return
+	}
+	t.avgBlock += smoothing * (avg - t.avgBlock)
+}
+
+// Sizes returns the fetch batch size and the stream sub-batch size to use in place of the
+// configured fetch and stream, keeping their ratio. Returns them as is before any observation.
+func (t *Tuner) Sizes(fetch, stream int) (int, int) {
+	if t == nil || t.avgBlock == 0 {
+		return fetch, stream
+	}
+	tuned := int(float64(t.target) / t.avgBlock)
+	tuned = max(1, min(tuned, t.limit))
+	return tuned, max(1, min(tuned, stream*tuned/fetch))
+}
@@ -0,0 +1,58 @@
+package batchsize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTunerSizes(t *testing.T) {
+	tests := []struct {
+		name         string
+		target       int64
+		limit        int
+		observations [][2]int64 // Blocks and their bytes
+		fetch        int
+		stream       int
+		wantFetch    int
+		wantStream   int
+	}{
+		{"configured before observing", 1 << 20, 10000, nil, 100, 10, 100, 10},
+		{"small blocks fetch more", 1 << 20, 10000, [][2]int64{{100, 100 << 10}}, 100, 10, 1024, 102},
+		{"large blocks fetch fewer", 1 << 20, 10000, [][2]int64{{10, 10 << 20}}, 100, 10, 1, 1},
+		{"capped at limit", 1 << 20, 500, [][2]int64{{100, 100}}, 100, 10, 500, 50},
+		{"stream never exceeds fetch", 1 << 20, 10000, [][2]int64{{100, 100 << 10}}, 100, 200, 1024, 1024},
+		{"empty observation ignored", 1 << 20, 10000, [][2]int64{{0, 0}}, 100, 10, 100, 10},
+		{"moving average", 1000, 10000, [][2]int64{{1, 100}, {1, 200}}, 100, 10, 7, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tuner := New(tt.target, tt.limit)
+			for _, o := range tt.observations {
+				tuner.Observe(int(o[0]), o[1])
+			}
+			fetch, stream := tuner.Sizes(tt.fetch, tt.stream)
+			require.Equal(t, tt.wantFetch, fetch)
+			require.Equal(t, tt.wantStream, stream)
+		})
+	}
+}
+
+func TestNewDisabled(t *testing.T) {
+	tests := []struct {
+		target int64
+		limit  int
+	}{
+		{0, 100},
+		{1 << 20, 0},
+		{-1, 100},
+	}
+	for _, tt := range tests {
+		tuner := New(tt.target, tt.limit)
+		require.Nil(t, tuner)
+		tuner.Observe(10, 1000)
+		fetch, stream := tuner.Sizes(100, 10)
+		require.Equal(t, 100, fetch)
+		require.Equal(t, 10, stream)
+	}
+}
@@ -0,0 +1,215 @@
+// Package breaker implements per-endpoint circuit breakers for the RPC fetchers. After a number
+// of consecutive failed requests an endpoint's breaker opens and holds back all traffic to it for
+// a cooldown, then lets a single probe request through: success closes the breaker, failure
+// opens it for another cooldown. Callers wait instead of burning their retry budget against a
+// dead node.
+package breaker
+
+import (
+	"context"
+	"icicle/pkg/metrics"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Breaker states, also the values of the state gauge
+const (
+	Closed   = 0
+	Open     = 1
+	HalfOpen = 2
+)
+
+var (
+	stateGauge = metrics.Factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Name:      "rpc_breaker_state",
+		Help:      "Circuit breaker state per RPC endpoint: 0 closed, 1 open, 2 half-open",
+	}, []string{"endpoint"})
+	opensCounter = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Name:      "rpc_breaker_opens_total",
+		Help:      "Times the circuit breaker of an RPC endpoint opened",
+	}, []string{"endpoint"})
+	failuresCounter = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Name:      "rpc_breaker_failures_total",
+		Help:      "Failed requests counted by the circuit breaker of an RPC endpoint",
+	}, []string{"endpoint"})
+)
+
+// Default settings, changed with Configure before any fetcher is created
+var (
+	threshold = 10
+	cooldown  = 30 * time.Second
+)
+
+// Configure sets the consecutive failures that open a breaker and how long it stays open.
+// A threshold of 0 disables circuit breaking.
+func Configure(failures int, openFor time.Duration) {
+	threshold = failures
+	cooldown = openFor
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = map[string]*Breaker{}
+)
+
+// For returns the breaker of the endpoint at rawURL, shared by every fetcher talking to it
+func For(rawURL string) *Breaker {
+	endpoint := Endpoint(rawURL)
+
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	if b, ok := breakers[endpoint]; ok {
+		return b
+	}
+	b := &Breaker{
+		endpoint:  endpoint,
+		threshold: threshold,
+		cooldown:  cooldown,
+		changed:   make(chan struct{}),
+		logger:    slog.With("component", "breaker", "endpoint", endpoint),
+	}
+	stateGauge.WithLabelValues(endpoint).Set(Closed)
+	breakers[endpoint] = b
+	return b
+}
+
+// Endpoint returns the scheme and host of rawURL, used to key breakers and label metrics
+// without leaking API keys that providers put in URL paths
+func Endpoint(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// Breaker tracks consecutive failures of one endpoint
+type Breaker struct {
+	endpoint  string
+	threshold int
+	cooldown  time.Duration
+	logger    *slog.Logger
+
+	mu       sync.Mutex
+	state    int
+	failures int           // Consecutive failures while closed
+	openedAt time.Time     // When the breaker last opened
+	probing  bool          // A half-open probe is in flight
+	changed  chan struct{} // Closed and replaced on every state change
+}
+
+// Endpoint returns the scheme and host the breaker guards, for labeling metrics
+func (b *Breaker) Endpoint() string {
+	return b.endpoint
+}
+
+// IsOpen reports whether the breaker is holding back traffic to its endpoint
+func (b *Breaker) IsOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == Open
+}
+
+// Wait blocks until a request may be sent: the breaker is closed, or it is this caller's turn
+// to probe a breaker whose cooldown is over. Returns the context's error if it ends first.
+func (b *Breaker) Wait(ctx context.Context) error {
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	for {
+		b.mu.Lock()
+		var wait <-chan time.Time
+		switch b.state {
+		case Closed:
+			b.mu.Unlock()
+			return nil
+		case Open:
+			remaining := b.cooldown - time.Since(b.openedAt)
+			if remaining <= 0 {
+				b.setState(HalfOpen)
+				b.probing = true
+				b.mu.Unlock()
+				b.logger.Info("Circuit breaker half-open, probing endpoint")
+				return nil
+			}
+			wait = time.After(remaining)
+		case HalfOpen:
+			if !b.probing {
+				b.probing = true
+				b.mu.Unlock()
+				return nil
+			}
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Success records a request that reached the endpoint and got a reply, closing the breaker
+func (b *Breaker) Success() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	if b.state != Closed {
+		b.probing = false
+		b.setState(Closed)
+		b.logger.Info("Circuit breaker closed, endpoint recovered")
+	}
+}
+
+// Failure records a request that didn't get a reply from the endpoint. Opens the breaker after
+// threshold consecutive failures, or right away when the half-open probe fails.
+func (b *Breaker) Failure(err error) {
+	if b.threshold <= 0 {
+		return
+	}
+	failuresCounter.WithLabelValues(b.endpoint).Inc()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Closed:
+		b.failures++
+		if b.failures < b.threshold {
+			return
+		}
+		b.logger.Warn("Circuit breaker open, pausing requests to endpoint",
+			"failures", b.failures, "cooldown", b.cooldown, "error", err)
+	case HalfOpen:
+		b.probing = false
+		b.logger.Warn("Circuit breaker probe failed, pausing requests to endpoint", "cooldown", b.cooldown, "error", err)
+	case Open:
+		return // Request sent before the breaker opened
+	}
+	b.failures = 0
+	b.openedAt = time.Now()
+	b.setState(Open)
+	opensCounter.WithLabelValues(b.endpoint).Inc()
+}
+
+// setState moves to state and wakes up waiters. Must hold mu.
+func (b *Breaker) setState(state int) {
+	b.state = state
+	stateGauge.WithLabelValues(b.endpoint).Set(float64(state))
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
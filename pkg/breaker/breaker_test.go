@@ -0,0 +1,106 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errTest = errors.New("connection refused")
+
+// newBreaker returns a breaker of its own endpoint with the given settings
+func newBreaker(t *testing.T, failures int, openFor time.Duration) *Breaker {
+	Configure(failures, openFor)
+	t.Cleanup(func() { Configure(10, 30*time.Second) })
+	return For(fmt.Sprintf("http://%s.test/ext/bc/C/rpc", strings.ReplaceAll(t.Name(), "/", ".")))
+}
+
+func TestBreakerStates(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		events    string // f for a failure, s for a success
+		state     int
+	}{
+		{"closed below threshold", 3, "ff", Closed},
+		{"opens at threshold", 3, "fff", Open},
+		{"success resets failures", 3, "ffsff", Closed},
+		{"failures must be consecutive", 2, "fsfsf", Closed},
+		{"disabled", 0, "ffffffffff", Closed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBreaker(t, tt.threshold, time.Hour)
+			for _, event := range tt.events {
+				if event == 'f' {
+					b.Failure(errTest)
+				} else {
+					b.Success()
+				}
+			}
+			require.Equal(t, tt.state, b.state)
+			require.Equal(t, tt.state == Open, b.IsOpen())
+		})
+	}
+}
+
+func TestBreakerWaitsWhileOpen(t *testing.T) {
+	b := newBreaker(t, 1, time.Hour)
+	require.NoError(t, b.Wait(context.Background()))
+	b.Failure(errTest)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, b.Wait(ctx), context.DeadlineExceeded)
+}
+
+func TestBreakerProbe(t *testing.T) {
+	tests := []struct {
+		name    string
+		success bool
+		state   int
+	}{
+		{"probe success closes", true, Closed},
+		{"probe failure reopens", false, Open},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBreaker(t, 1, 10*time.Millisecond)
+			b.Failure(errTest)
+			require.True(t, b.IsOpen())
+
+			// After the cooldown one caller probes, the others keep waiting
+			require.NoError(t, b.Wait(context.Background()))
+			require.Equal(t, HalfOpen, b.state)
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			require.ErrorIs(t, b.Wait(ctx), context.DeadlineExceeded)
+
+			if tt.success {
+				b.Success()
+			} else {
+				b.Failure(errTest)
+			}
+			require.Equal(t, tt.state, b.state)
+		})
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	tests := []struct {
+		url      string
+		endpoint string
+	}{
+		{"https://api.example.com/v1/secret-key/ext/bc/C/rpc", "https://api.example.com"},
+		{"http://127.0.0.1:9650", "http://127.0.0.1:9650"},
+		{"not a url", "not a url"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.endpoint, Endpoint(tt.url), tt.url)
+	}
+}
@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// archiveVersion is bumped when the archive layout changes incompatibly
+	archiveVersion = 1
+	// archiveManifest is the first entry of an archive
+	archiveManifest = "manifest.json"
+	// ArchiveChunkSize is the default number of blocks per archive entry
+	ArchiveChunkSize = 1000
+)
+
+// ArchiveManifest describes a cache archive, independent of the on-disk cache format
+type ArchiveManifest struct {
+	Version    int       `json:"version"`
+	ChainID    uint32    `json:"chainID"`
+	From       int64     `json:"from"`       // First block of the exported range
+	To         int64     `json:"to"`         // Last block of the exported range
+	Checkpoint int64     `json:"checkpoint"` // Source cache checkpoint, capped at To
+	ChunkSize  int64     `json:"chunkSize"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// ArchiveStats counts what an export or import moved
+type ArchiveStats struct {
+	Blocks int64 // Blocks exported or imported
+	Bytes  int64 // Uncompressed block bytes
+}
+
+// Export writes the cached blocks in [from, to] to w as a zstd-compressed tar: a manifest followed
+// by one entry per chunk of chunkSize blocks, each a sequence of block frames. Missing blocks are
+// skipped, so sparse caches export fine.
+func Export(c Cache, chainID uint32, w io.Writer, from, to, chunkSize int64, progress func(ArchiveStats)) (ArchiveStats, error) {
+	var stats ArchiveStats
+	if from > to {
+		return stats, fmt.Errorf("invalid range: from %d > to %d", from, to)
+	}
+	if chunkSize <= 0 {
+		chunkSize = ArchiveChunkSize
+	}
+
+	checkpoint, err := c.GetCheckpoint()
+	if err != nil {
+		return stats, err
+	}
+
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return stats, fmt.Errorf("failed to create zstd writer: %w", err)
+	}
+	tw := tar.NewWriter(zw)
+
+	manifest, err := json.Marshal(ArchiveManifest{
+		Version:    archiveVersion,
+		ChainID:    chainID,
+		From:       from,
+		To:         to,
+		Checkpoint: min(checkpoint, to),
+		ChunkSize:  chunkSize,
+		CreatedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := writeTarEntry(tw, archiveManifest, manifest); err != nil {
+		return stats, err
+	}
+
+	var chunk bytes.Buffer
+	for start := from; start <= to; start += chunkSize {
+		end := min(start+chunkSize-1, to)
+		blocks, err := c.GetBlockRange(start, end)
+		if err != nil {
+			return stats, fmt.Errorf("failed to read blocks %d-%d: %w", start, end, err)
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+
+		chunk.Reset()
+		for blockNum := start; blockNum <= end; blockNum++ {
+			data, ok := blocks[blockNum]
+			if !ok {
+				continue
+			}
+			if err := writeFrame(&chunk, blockNum, data); err != nil {
+				return stats, err
+			}
+			stats.Blocks++
+			stats.Bytes += int64(len(data))
+		}
+		if err := writeTarEntry(tw, chunkEntryName(start, end), chunk.Bytes()); err != nil {
+			return stats, err
+		}
+		if progress != nil {
+			progress(stats)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return stats, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return stats, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return stats, nil
+}
+
+// Import reads an archive written by Export into c, keeping blocks c already has. The checkpoint
+// is advanced to the archive's when the archive continues c's cached prefix.
+func Import(c Cache, chainID uint32, r io.Reader, progress func(ArchiveStats)) (ArchiveManifest, ArchiveStats, error) {
+	var manifest ArchiveManifest
+	var stats ArchiveStats
+
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return manifest, stats, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+
+	header, err := tr.Next()
+	if err != nil {
+		return manifest, stats, fmt.Errorf("failed to read archive: %w", err)
+	}
+	if header.Name != archiveManifest {
+		return manifest, stats, fmt.Errorf("not a cache archive: first entry is %q", header.Name)
+	}
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return manifest, stats, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	if manifest.Version != archiveVersion {
+		return manifest, stats, fmt.Errorf("unsupported archive version %d (expected %d)", manifest.Version, archiveVersion)
+	}
+	if manifest.ChainID != chainID {
+		return manifest, stats, fmt.Errorf("archive is for chain %d, not %d", manifest.ChainID, chainID)
+	}
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return manifest, stats, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if !strings.HasPrefix(header.Name, "blocks/") {
+			continue
+		}
+
+		blocks, err := readFrames(tr)
+		if err != nil {
+			return manifest, stats, fmt.Errorf("failed to read %s: %w", header.Name, err)
+		}
+		for blockNum, data := range blocks {
+			if blockNum < manifest.From || blockNum > manifest.To {
+				return manifest, stats, fmt.Errorf("block %d in %s is outside the archive range", blockNum, header.Name)
+			}
+			if _, err := c.GetCompleteBlock(blockNum, func() ([]byte, error) { return data, nil }); err != nil {
+				return manifest, stats, fmt.Errorf("failed to store block %d: %w", blockNum, err)
+			}
+			stats.Blocks++
+			stats.Bytes += int64(len(data))
+		}
+		if progress != nil {
+			progress(stats)
+		}
+	}
+
+	checkpoint, err := c.GetCheckpoint()
+	if err != nil {
+		return manifest, stats, err
+	}
+	if manifest.Checkpoint > checkpoint && manifest.From <= checkpoint+1 {
+		if err := c.SetCheckpoint(manifest.Checkpoint); err != nil {
+			return manifest, stats, err
+		}
+	}
+	return manifest, stats, nil
+}
+
+// chunkEntryName names the archive entry holding blocks [from, to]
+func chunkEntryName(from, to int64) string {
+	return fmt.Sprintf("blocks/%0*d-%0*d", blockKeyPadding, from, blockKeyPadding, to)
+}
+
+// writeTarEntry writes one regular file entry
+func writeTarEntry(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s header: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
@@ -3,14 +3,19 @@ package cache
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/cockroachdb/pebble/v2"
 	"github.com/cockroachdb/pebble/v2/sstable/block"
+	"github.com/cockroachdb/pebble/v2/vfs"
 )
 
 const (
+	// DefaultDir is the directory of local caches unless configured otherwise
+	DefaultDir = "./rpc_cache"
 	// blockKeyPrefix is the prefix for block keys
 	blockKeyPrefix = "block:"
 	// blockKeyPadding is the zero-padded length for block numbers (14 digits supports up to 100 trillion blocks)
@@ -19,16 +24,71 @@ const (
 	checkpointKey = "checkpoint:last_cached_block"
 )
 
-// Cache implements caching using PebbleDB
-type Cache struct {
-	db *pebble.DB
+// Cache stores complete blocks as raw bytes, keyed by height, plus a checkpoint of the last
+// cached block
+type Cache interface {
+	// GetCompleteBlock returns a cached block, or fetches and caches it on a miss
+	GetCompleteBlock(blockNum int64, fetch func() ([]byte, error)) ([]byte, error)
+	// GetBlockRange returns the cached blocks of [from, to], missing blocks are left out
+	GetBlockRange(from, to int64) (map[int64][]byte, error)
+	GetCheckpoint() (int64, error)
+	SetCheckpoint(blockNum int64) error
+	Compact(opts CompactOptions) error
+	GetMetrics() string
+	// GetEntry returns a namespaced RPC response, or nil if it isn't cached
+	GetEntry(ns Namespace, key string) (*Entry, error)
+	// SetEntry stores a namespaced RPC response
+	SetEntry(ns Namespace, key string, e Entry) error
+	// Prune deletes blocks the retention policy doesn't keep
+	Prune(r Retention) (PruneResult, error)
+	Close() error
 }
 
-// New creates a new PebbleDB cache at the specified path for the given chain ID
-func New(dbPath string, chainID uint32) (*Cache, error) {
+// New opens the cache of a chain: a local PebbleDB under dbPath, or the cache server or object
+// store set with Configure, optionally behind a local PebbleDB
+func New(dbPath string, chainID uint32) (Cache, error) {
+	var remote Cache
+	var err error
+	switch {
+	case storeOpts.ServerURL != "":
+		remote, err = NewClient(storeOpts.ServerURL, storeOpts.ServerToken, chainID)
+	case storeOpts.URL != "":
+		remote, err = NewStore(storeOpts, chainID)
+	default:
+		return NewLocal(dbPath, chainID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !storeOpts.Layered {
+		return remote, nil
+	}
+
+	local, err := NewLocal(dbPath, chainID)
+	if err != nil {
+		remote.Close()
+		return nil, err
+	}
+	return &Layered{local: local, remote: remote}, nil
+}
+
+// Local implements caching using PebbleDB
+type Local struct {
+	db       *pebble.DB
+	path     string
+	readOnly bool // Point-in-time view that never writes, see StoreOptions.ReadOnly
+}
+
+// NewLocal creates a new PebbleDB cache at the specified path for the given chain ID. Only one
+// process may open a cache for writing; read-only caches (see Configure) can be opened alongside it.
+func NewLocal(dbPath string, chainID uint32) (*Local, error) {
 	chainPath := filepath.Join(dbPath, fmt.Sprintf("%d", chainID))
 
 	opts := &pebble.Options{}
+	if storeOpts.ReadOnly {
+		opts.ReadOnly = true
+		opts.FS = unlockedFS{vfs.Default}
+	}
 
 	// Use zstd compression level 1 for all levels
 	opts.ApplyCompressionSettings(func() pebble.DBCompressionSettings {
@@ -60,10 +120,13 @@ func New(dbPath string, chainID uint32) (*Cache, error) {
 
 	db, err := pebble.Open(chainPath, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open pebble db: %w", err)
+		return nil, fmt.Errorf("failed to open pebble db: %w", inUseError(chainPath, err))
+	}
+	if !opts.ReadOnly {
+		writeOwner(chainPath)
 	}
 
-	return &Cache{db: db}, nil
+	return &Local{db: db, path: chainPath, readOnly: opts.ReadOnly}, nil
 }
 
 // formatBlockKey formats a block number as a zero-padded key
@@ -90,7 +153,7 @@ func parseBlockKey(key []byte) int64 {
 }
 
 // GetCompleteBlock retrieves or fetches a complete block as JSON bytes
-func (c *Cache) GetCompleteBlock(blockNum int64, fetch func() ([]byte, error)) ([]byte, error) {
+func (c *Local) GetCompleteBlock(blockNum int64, fetch func() ([]byte, error)) ([]byte, error) {
 	key := formatBlockKey(blockNum)
 
 	// Try to get from cache
@@ -115,16 +178,35 @@ func (c *Cache) GetCompleteBlock(blockNum int64, fetch func() ([]byte, error)) (
 	}
 
 	// Store in cache
-	if err := c.db.Set(key, data, pebble.NoSync); err != nil {
+	if c.readOnly {
+		return data, nil
+	}
+	if err := c.put(blockNum, data); err != nil {
 		// Log error but don't fail the request
-		fmt.Printf("Warning: failed to cache block %d: %v\n", blockNum, err)
+		slog.Warn("Failed to cache block", "component", "cache", "block", blockNum, "error", err)
 	}
 
 	return data, nil
 }
 
+// put stores a block without looking it up first, indexed by write time for age-based pruning
+func (c *Local) put(blockNum int64, data []byte) error {
+	if c.readOnly {
+		return nil
+	}
+	batch := c.db.NewBatch()
+	defer batch.Close()
+	if err := batch.Set(formatBlockKey(blockNum), data, nil); err != nil {
+		return err
+	}
+	if err := batch.Set(formatWrittenKey(time.Now(), blockNum), nil, nil); err != nil {
+		return err
+	}
+	return batch.Commit(pebble.NoSync)
+}
+
 // GetBlockRange retrieves a range of blocks from the cache [from, to] inclusive
-func (c *Cache) GetBlockRange(from, to int64) (map[int64][]byte, error) {
+func (c *Local) GetBlockRange(from, to int64) (map[int64][]byte, error) {
 	if from > to {
 		return nil, fmt.Errorf("invalid range: from %d > to %d", from, to)
 	}
@@ -158,8 +240,16 @@ func (c *Cache) GetBlockRange(from, to int64) (map[int64][]byte, error) {
 	return result, nil
 }
 
-// Compact triggers a manual compaction of the entire database
-func (c *Cache) Compact() error {
+// Compact triggers a manual compaction of the entire database, in paced steps of opts.Step
+// blocks if set
+func (c *Local) Compact(opts CompactOptions) error {
+	if c.readOnly {
+		return errReadOnly
+	}
+	if opts.Step > 0 {
+		return c.compactPaced(opts)
+	}
+
 	// Compact the entire key range
 	// Using nil for start means beginning of keyspace
 	// Using a high value for end means end of keyspace
@@ -168,18 +258,21 @@ func (c *Cache) Compact() error {
 }
 
 // GetMetrics returns database metrics for monitoring
-func (c *Cache) GetMetrics() string {
+func (c *Local) GetMetrics() string {
 	return c.db.Metrics().String()
 }
 
 // Close closes the PebbleDB database
-func (c *Cache) Close() error {
+func (c *Local) Close() error {
+	if !c.readOnly {
+		removeOwner(c.path)
+	}
 	return c.db.Close()
 }
 
 // GetCheckpoint retrieves the last cached block number checkpoint
 // Returns 0 if no checkpoint exists
-func (c *Cache) GetCheckpoint() (int64, error) {
+func (c *Local) GetCheckpoint() (int64, error) {
 	value, closer, err := c.db.Get([]byte(checkpointKey))
 	if err == pebble.ErrNotFound {
 		return 0, nil
@@ -198,7 +291,10 @@ func (c *Cache) GetCheckpoint() (int64, error) {
 }
 
 // SetCheckpoint saves the last cached block number checkpoint
-func (c *Cache) SetCheckpoint(blockNum int64) error {
+func (c *Local) SetCheckpoint(blockNum int64) error {
+	if c.readOnly {
+		return errReadOnly
+	}
 	value := []byte(strconv.FormatInt(blockNum, 10))
 	if err := c.db.Set([]byte(checkpointKey), value, pebble.Sync); err != nil {
 		return fmt.Errorf("failed to set checkpoint: %w", err)
@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// clientWriters is the number of workers uploading blocks to the cache server
+const clientWriters = 8
+
+// errNotCached is returned by Client.getBlock for blocks the server doesn't have
+var errNotCached = errors.New("block not cached")
+
+// Client uses the cache of a chain on a cache server. Like Store, uploads run in the background
+// and server errors count as misses.
+type Client struct {
+	baseURL    string // Server URL of the chain, without trailing slash
+	token      string
+	httpClient *http.Client
+	logger     *slog.Logger
+
+	writes   chan storeWrite
+	wg       sync.WaitGroup
+	readOnly bool
+
+	hits, misses, puts, failedPuts atomic.Int64
+}
+
+// NewClient connects to the cache of chainID on the cache server at serverURL
+func NewClient(serverURL, token string, chainID uint32) (*Client, error) {
+	if !strings.HasPrefix(serverURL, "http://") && !strings.HasPrefix(serverURL, "https://") {
+		return nil, fmt.Errorf("invalid cache server URL %q: expected http:// or https://", serverURL)
+	}
+
+	transport := &http.Transport{
+		MaxIdleConns:        1000,
+		MaxIdleConnsPerHost: 1000,
+		IdleConnTimeout:     90 * time.Second,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+	}
+	c := &Client{
+		baseURL:    fmt.Sprintf("%s/chains/%d", strings.TrimSuffix(serverURL, "/"), chainID),
+		token:      token,
+		httpClient: &http.Client{Timeout: 5 * time.Minute, Transport: transport},
+		logger:     slog.With("component", "cache", "server", serverURL),
+		writes:     make(chan storeWrite, 1000),
+		readOnly:   storeOpts.ReadOnly,
+	}
+	for i := 0; i < clientWriters; i++ {
+		c.wg.Add(1)
+		go c.writer()
+	}
+	return c, nil
+}
+
+// do sends a request to path under the chain's URL and fails on statuses other than 2xx and 404
+func (c *Client) do(method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(context.Background(), method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache server request: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cache server request failed: %w", err)
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("cache server returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// getBlock downloads one block, or returns errNotCached
+func (c *Client) getBlock(blockNum int64) ([]byte, error) {
+	resp, err := c.do(http.MethodGet, fmt.Sprintf("/blocks/%d", blockNum), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errNotCached
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// GetCompleteBlock retrieves a block from the server, or fetches it and uploads it
+func (c *Client) GetCompleteBlock(blockNum int64, fetch func() ([]byte, error)) ([]byte, error) {
+	data, err := c.getBlock(blockNum)
+	if err == nil {
+		c.hits.Add(1)
+		return data, nil
+	}
+	if !errors.Is(err, errNotCached) {
+		c.logger.Warn("Failed to read block from cache server", "block", blockNum, "error", err)
+	}
+	c.misses.Add(1)
+
+	data, err = fetch()
+	if err != nil {
+		return nil, err
+	}
+	if !c.readOnly {
+		c.writes <- storeWrite{blockNum: blockNum, data: data}
+	}
+	return data, nil
+}
+
+// GetBlockRange retrieves the cached blocks of [from, to], in requests of at most
+// maxServedRange blocks
+func (c *Client) GetBlockRange(from, to int64) (map[int64][]byte, error) {
+	if from > to {
+		return nil, fmt.Errorf("invalid range: from %d > to %d", from, to)
+	}
+
+	result := make(map[int64][]byte)
+	for start := from; start <= to; start += maxServedRange {
+		end := min(start+maxServedRange-1, to)
+		resp, err := c.do(http.MethodGet, fmt.Sprintf("/blocks?from=%d&to=%d", start, end), nil)
+		if err != nil {
+			return nil, err
+		}
+		blocks, err := readFrames(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read blocks %d-%d from cache server: %w", start, end, err)
+		}
+		for blockNum, data := range blocks {
+			result[blockNum] = data
+		}
+	}
+
+	c.hits.Add(int64(len(result)))
+	c.misses.Add(to - from + 1 - int64(len(result)))
+	return result, nil
+}
+
+// GetEntry downloads the entry stored under key in ns, or returns nil if there is none
+func (c *Client) GetEntry(ns Namespace, key string) (*Entry, error) {
+	resp, err := c.do(http.MethodGet, fmt.Sprintf("/entries/%s/%s", ns, key), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache entry: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache entry: %w", err)
+	}
+	return decodeEntry(body)
+}
+
+// SetEntry queues an entry for upload
+func (c *Client) SetEntry(ns Namespace, key string, e Entry) error {
+	if !validEntryKey(key) {
+		return errInvalidEntryKey
+	}
+	if c.readOnly {
+		return nil
+	}
+	c.writes <- storeWrite{key: fmt.Sprintf("/entries/%s/%s", ns, key), data: encodeEntry(e)}
+	return nil
+}
+
+// writer uploads queued blocks and entries until the queue is closed
+func (c *Client) writer() {
+	defer c.wg.Done()
+	for w := range c.writes {
+		path := w.key
+		if path == "" {
+			path = fmt.Sprintf("/blocks/%d", w.blockNum)
+		}
+		resp, err := c.do(http.MethodPut, path, w.data)
+		if err != nil {
+			c.failedPuts.Add(1)
+			c.logger.Warn("Failed to upload block to cache server", "path", path, "error", err)
+			continue
+		}
+		resp.Body.Close()
+		c.puts.Add(1)
+	}
+}
+
+// Compact is a no-op, the server owns its caches
+func (c *Client) Compact(opts CompactOptions) error {
+	return nil
+}
+
+// GetMetrics returns hit, miss and upload counters
+func (c *Client) GetMetrics() string {
+	return fmt.Sprintf("cache server %s: %d hits, %d misses, %d blocks uploaded, %d uploads failed, %d queued",
+		c.baseURL, c.hits.Load(), c.misses.Load(), c.puts.Load(), c.failedPuts.Load(), len(c.writes))
+}
+
+// Close waits for queued uploads to finish
+func (c *Client) Close() error {
+	close(c.writes)
+	c.wg.Wait()
+	return nil
+}
+
+// GetCheckpoint retrieves the last cached block number checkpoint
+// Returns 0 if no checkpoint exists
+func (c *Client) GetCheckpoint() (int64, error) {
+	resp, err := c.do(http.MethodGet, "/checkpoint", nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get checkpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get checkpoint: %w", err)
+	}
+	blockNum, err := strconv.ParseInt(string(body), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	return blockNum, nil
+}
+
+// SetCheckpoint saves the last cached block number checkpoint
+func (c *Client) SetCheckpoint(blockNum int64) error {
+	if c.readOnly {
+		return errReadOnly
+	}
+	resp, err := c.do(http.MethodPut, "/checkpoint", []byte(strconv.FormatInt(blockNum, 10)))
+	if err != nil {
+		return fmt.Errorf("failed to set checkpoint: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CompactOptions paces a compaction so it doesn't fight ingestion for IOPS. The zero value
+// compacts the whole cache at once.
+type CompactOptions struct {
+	Step  int64         // Blocks compacted per step (0: everything in one step)
+	Pause time.Duration // Wait between steps
+	Yield func() bool   // Checked before each step, true postpones the step by Pause, e.g. while load shedding
+}
+
+// compactPaced compacts the cached blocks opts.Step at a time from the lowest, then the keys
+// that aren't blocks (checkpoint, namespaced entries, write times)
+func (c *Local) compactPaced(opts CompactOptions) error {
+	ctx := context.Background()
+	lowest, highest, err := c.blockBounds()
+	if err != nil {
+		return err
+	}
+
+	for from := lowest; lowest >= 0 && from <= highest; from += opts.Step {
+		for opts.Yield != nil && opts.Yield() {
+			time.Sleep(max(opts.Pause, time.Second))
+		}
+		if err := c.db.Compact(ctx, formatBlockKey(from), formatBlockKey(from+opts.Step), false); err != nil {
+			return fmt.Errorf("failed to compact blocks %d-%d: %w", from, from+opts.Step-1, err)
+		}
+		time.Sleep(opts.Pause)
+	}
+
+	if err := c.db.Compact(ctx, blockKeysEnd, []byte("\xff\xff\xff\xff\xff"), false); err != nil {
+		return fmt.Errorf("failed to compact cache entries: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// Layered keeps a local PebbleDB as a hot cache in front of an object store or cache server:
+// reads try the local cache first and copy blocks found remotely into it, writes go to both
+type Layered struct {
+	local  *Local
+	remote Cache
+}
+
+// GetCompleteBlock retrieves a block from the local cache, then the remote one, then fetch
+func (c *Layered) GetCompleteBlock(blockNum int64, fetch func() ([]byte, error)) ([]byte, error) {
+	return c.local.GetCompleteBlock(blockNum, func() ([]byte, error) {
+		return c.remote.GetCompleteBlock(blockNum, fetch)
+	})
+}
+
+// GetBlockRange retrieves a range from the local cache and the blocks it lacks remotely
+func (c *Layered) GetBlockRange(from, to int64) (map[int64][]byte, error) {
+	result, err := c.local.GetBlockRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(result)) == to-from+1 {
+		return result, nil
+	}
+
+	remote, err := c.remote.GetBlockRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	for blockNum, data := range remote {
+		if _, ok := result[blockNum]; ok {
+			continue
+		}
+		result[blockNum] = data
+		if err := c.local.put(blockNum, data); err != nil {
+			slog.Warn("Failed to cache block", "component", "cache", "block", blockNum, "error", err)
+		}
+	}
+	return result, nil
+}
+
+// Compact compacts the local cache
+func (c *Layered) Compact(opts CompactOptions) error {
+	return c.local.Compact(opts)
+}
+
+// GetMetrics returns the metrics of both layers
+func (c *Layered) GetMetrics() string {
+	return c.remote.GetMetrics() + "\n" + c.local.GetMetrics()
+}
+
+// Close closes both layers, waiting for queued uploads
+func (c *Layered) Close() error {
+	remoteErr := c.remote.Close()
+	if err := c.local.Close(); err != nil {
+		return err
+	}
+	return remoteErr
+}
+
+// GetCheckpoint returns the remote checkpoint, which survives the local cache
+func (c *Layered) GetCheckpoint() (int64, error) {
+	return c.remote.GetCheckpoint()
+}
+
+// SetCheckpoint saves the checkpoint in both layers
+func (c *Layered) SetCheckpoint(blockNum int64) error {
+	if err := c.remote.SetCheckpoint(blockNum); err != nil {
+		return err
+	}
+	if err := c.local.SetCheckpoint(blockNum); err != nil {
+		return fmt.Errorf("failed to set local checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cockroachdb/pebble/v2/vfs"
+)
+
+// errReadOnly is returned by writes that can't be skipped on a read-only cache
+var errReadOnly = errors.New("cache is open read-only")
+
+// ownerFile returns the file recording which process has a chain's cache open for writing. It
+// sits next to the PebbleDB directory, since PebbleDB owns the files inside it.
+func ownerFile(chainPath string) string {
+	return chainPath + ".owner"
+}
+
+// writeOwner records this process as the writer of the cache at chainPath
+func writeOwner(chainPath string) {
+	command := strings.Join(append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...), " ")
+	owner := fmt.Sprintf("pid %d, %s, since %s", os.Getpid(), command, time.Now().UTC().Format(time.RFC3339))
+	_ = os.WriteFile(ownerFile(chainPath), []byte(owner), 0644)
+}
+
+// removeOwner clears the owner record written by writeOwner
+func removeOwner(chainPath string) {
+	_ = os.Remove(ownerFile(chainPath))
+}
+
+// inUseError explains a failed open of a cache another process holds the lock of
+func inUseError(chainPath string, err error) error {
+	if !errors.Is(err, syscall.EAGAIN) && !errors.Is(err, syscall.EACCES) && !strings.Contains(err.Error(), "lock held") {
+		return err
+	}
+	owner, _ := os.ReadFile(ownerFile(chainPath))
+	if len(owner) == 0 {
+		owner = []byte("unknown process")
+	}
+	return fmt.Errorf("cache %s is in use by %s: stop it, open the cache with --cache-read-only, or share it with \"cache serve\" and --cache-server: %w",
+		chainPath, owner, err)
+}
+
+// unlockedFS skips the PebbleDB directory lock, so read-only views can open a cache another
+// process is writing to
+type unlockedFS struct {
+	vfs.FS
+}
+
+type noopCloser struct{}
+
+func (noopCloser) Close() error { return nil }
+
+// Lock returns without locking
+func (unlockedFS) Lock(string) (io.Closer, error) {
+	return noopCloser{}, nil
+}
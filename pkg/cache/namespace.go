@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/pebble/v2"
+)
+
+// Namespace separates cached RPC responses other than complete blocks, each with its own TTL
+type Namespace string
+
+const (
+	NamespaceReceipts     Namespace = "receipts"     // EVM receipts of a block, keyed by BlockEntryKey
+	NamespaceTraces       Namespace = "traces"       // EVM traces of a block, keyed by BlockEntryKey
+	NamespaceValidators   Namespace = "validators"   // platform.getCurrentValidators, keyed by subnet ID
+	NamespaceL1Validators Namespace = "l1Validators" // platform.getL1Validator, keyed by validation ID
+)
+
+// namespaces lists every namespace, for config validation
+var namespaces = []Namespace{NamespaceReceipts, NamespaceTraces, NamespaceValidators, NamespaceL1Validators}
+
+// entryKeyPrefix prefixes namespaced entries in the local cache: entry:<namespace>:<key>
+const entryKeyPrefix = "entry:"
+
+// errInvalidEntryKey is returned for keys that don't fit in a URL path segment or object key
+var errInvalidEntryKey = errors.New("entry keys may only contain letters, digits, '-' and '_'")
+
+// Entry is a cached RPC response and the time it was cached
+type Entry struct {
+	Data    []byte
+	Written time.Time
+}
+
+// BlockEntryKey returns the key of a per-block entry, zero-padded so keys sort by height
+func BlockEntryKey(blockNum int64) string {
+	return fmt.Sprintf("%0*d", blockKeyPadding, blockNum)
+}
+
+// TTLs says which namespaces are cached and for how long. Namespaces missing from the map
+// aren't cached, a zero TTL never expires.
+type TTLs map[Namespace]time.Duration
+
+// ParseTTLs parses a namespace to duration map from config, e.g. {"validators": "4m"}
+func ParseTTLs(values map[string]string) (TTLs, error) {
+	ttls := make(TTLs, len(values))
+	for name, value := range values {
+		ns := Namespace(name)
+		if !ns.valid() {
+			names := make([]string, len(namespaces))
+			for i, n := range namespaces {
+				names[i] = string(n)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown cache namespace %q, expected one of %s", name, strings.Join(names, ", "))
+		}
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("invalid TTL %q for cache namespace %s, expected a duration like 5m or 0 for no expiry", value, name)
+		}
+		ttls[ns] = ttl
+	}
+	return ttls, nil
+}
+
+// valid reports whether ns is a known namespace
+func (ns Namespace) valid() bool {
+	for _, n := range namespaces {
+		if n == ns {
+			return true
+		}
+	}
+	return false
+}
+
+// Entries returns the entries of ns in c, or nil if c is nil or ns isn't cached
+func (t TTLs) Entries(c Cache, ns Namespace) *Entries {
+	ttl, ok := t[ns]
+	if c == nil || !ok {
+		return nil
+	}
+	return &Entries{cache: c, ns: ns, ttl: ttl}
+}
+
+// Entries reads and writes one namespace of a cache, dropping entries older than its TTL. All
+// methods work on a nil *Entries and cache nothing, and cache errors count as misses.
+type Entries struct {
+	cache Cache
+	ns    Namespace
+	ttl   time.Duration
+}
+
+// Get returns the fresh entry stored under key, or nil. Keys validEntryKey rejects are never cached.
+func (e *Entries) Get(key string) []byte {
+	if e == nil || !validEntryKey(key) {
+		return nil
+	}
+	entry, err := e.cache.GetEntry(e.ns, key)
+	if err != nil {
+		slog.Warn("Failed to read cache entry", "component", "cache", "namespace", e.ns, "key", key, "error", err)
+		return nil
+	}
+	if entry == nil || (e.ttl > 0 && time.Since(entry.Written) > e.ttl) {
+		return nil
+	}
+	return entry.Data
+}
+
+// Set stores data under key
+func (e *Entries) Set(key string, data []byte) {
+	if e == nil || !validEntryKey(key) {
+		return
+	}
+	if err := e.cache.SetEntry(e.ns, key, Entry{Data: data, Written: time.Now()}); err != nil {
+		slog.Warn("Failed to write cache entry", "component", "cache", "namespace", e.ns, "key", key, "error", err)
+	}
+}
+
+// validEntryKey reports whether key can be used in object keys and URL paths as is
+func validEntryKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeEntry prefixes the entry data with its write time as 8 big-endian bytes of Unix nanoseconds
+func encodeEntry(e Entry) []byte {
+	buf := make([]byte, 8+len(e.Data))
+	binary.BigEndian.PutUint64(buf, uint64(e.Written.UnixNano()))
+	copy(buf[8:], e.Data)
+	return buf
+}
+
+// decodeEntry reverses encodeEntry
+func decodeEntry(buf []byte) (*Entry, error) {
+	if len(buf) < 8 {
+		return nil, fmt.Errorf("cache entry too short: %d bytes", len(buf))
+	}
+	return &Entry{
+		Data:    buf[8:],
+		Written: time.Unix(0, int64(binary.BigEndian.Uint64(buf))),
+	}, nil
+}
+
+// formatEntryKey formats the local cache key of an entry
+func formatEntryKey(ns Namespace, key string) []byte {
+	return []byte(entryKeyPrefix + string(ns) + ":" + key)
+}
+
+// GetEntry returns the entry stored under key in ns, or nil if there is none
+func (c *Local) GetEntry(ns Namespace, key string) (*Entry, error) {
+	value, closer, err := c.db.Get(formatEntryKey(ns, key))
+	if err == pebble.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache entry: %w", err)
+	}
+	defer closer.Close()
+	return decodeEntry(append([]byte(nil), value...))
+}
+
+// SetEntry stores an entry under key in ns
+func (c *Local) SetEntry(ns Namespace, key string, e Entry) error {
+	if !validEntryKey(key) {
+		return errInvalidEntryKey
+	}
+	if c.readOnly {
+		return nil
+	}
+	return c.db.Set(formatEntryKey(ns, key), encodeEntry(e), pebble.NoSync)
+}
+
+// GetEntry reads the local cache first and copies entries found remotely into it
+func (c *Layered) GetEntry(ns Namespace, key string) (*Entry, error) {
+	entry, err := c.local.GetEntry(ns, key)
+	if err != nil || entry != nil {
+		return entry, err
+	}
+	entry, err = c.remote.GetEntry(ns, key)
+	if err != nil || entry == nil {
+		return entry, err
+	}
+	if err := c.local.SetEntry(ns, key, *entry); err != nil {
+		slog.Warn("Failed to cache entry", "component", "cache", "namespace", ns, "key", key, "error", err)
+	}
+	return entry, nil
+}
+
+// SetEntry stores an entry in both layers
+func (c *Layered) SetEntry(ns Namespace, key string, e Entry) error {
+	if err := c.local.SetEntry(ns, key, e); err != nil {
+		return err
+	}
+	return c.remote.SetEntry(ns, key, e)
+}
@@ -0,0 +1,209 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/pebble/v2"
+)
+
+// writtenKeyPrefix prefixes the write time index: written:<unix seconds>:<block>
+const writtenKeyPrefix = "written:"
+
+// blockKeysEnd sorts after every block key
+var blockKeysEnd = []byte("block;")
+
+// errPruneUnsupported is returned by caches that leave retention to their backend
+var errPruneUnsupported = errors.New("pruning is only supported for local caches, use bucket lifecycle rules or prune on the cache server")
+
+// Retention bounds what a cache keeps. Zero fields don't limit anything.
+type Retention struct {
+	MaxSizeBytes uint64        // Delete the lowest blocks until the cache is below this size
+	PruneBelow   int64         // Delete blocks below this height
+	TTL          time.Duration // Delete blocks cached longer ago than this
+}
+
+// IsZero reports whether r keeps everything
+func (r Retention) IsZero() bool {
+	return r.MaxSizeBytes == 0 && r.PruneBelow == 0 && r.TTL == 0
+}
+
+// PruneResult describes what a Prune call deleted
+type PruneResult struct {
+	BelowBlock    int64  // Blocks below this height were deleted, 0 if none
+	ExpiredBlocks int    // Blocks deleted for being older than the TTL
+	FreedBytes    uint64 // Estimated disk space freed
+}
+
+// formatWrittenKey formats a write time index key, sorted by time then block
+func formatWrittenKey(t time.Time, blockNum int64) []byte {
+	return []byte(fmt.Sprintf("%s%012d:%0*d", writtenKeyPrefix, t.Unix(), blockKeyPadding, blockNum))
+}
+
+// Prune deletes blocks (and their receipts and traces entries) below r.PruneBelow, blocks cached before now-r.TTL and then the lowest
+// blocks until the cache fits r.MaxSizeBytes, and compacts the deleted ranges to free the space.
+// Blocks cached before write times were tracked never expire by age.
+func (c *Local) Prune(r Retention) (PruneResult, error) {
+	var result PruneResult
+	if c.readOnly {
+		return result, errReadOnly
+	}
+	before, err := c.db.EstimateDiskUsage([]byte(blockKeyPrefix), blockKeysEnd)
+	if err != nil {
+		return result, fmt.Errorf("failed to estimate cache size: %w", err)
+	}
+
+	if r.TTL > 0 {
+		expired, err := c.pruneWrittenBefore(time.Now().Add(-r.TTL))
+		if err != nil {
+			return result, err
+		}
+		result.ExpiredBlocks = expired
+	}
+
+	below := r.PruneBelow
+	if r.MaxSizeBytes > 0 {
+		cut, err := c.sizeCut(r.MaxSizeBytes)
+		if err != nil {
+			return result, err
+		}
+		below = max(below, cut)
+	}
+	if below > 0 {
+		if err := c.db.DeleteRange(formatBlockKey(0), formatBlockKey(below), pebble.Sync); err != nil {
+			return result, fmt.Errorf("failed to delete blocks below %d: %w", below, err)
+		}
+		for _, ns := range []Namespace{NamespaceReceipts, NamespaceTraces} {
+			err := c.db.DeleteRange(formatEntryKey(ns, BlockEntryKey(0)), formatEntryKey(ns, BlockEntryKey(below)), pebble.Sync)
+			if err != nil {
+				return result, fmt.Errorf("failed to delete %s below %d: %w", ns, below, err)
+			}
+		}
+		result.BelowBlock = below
+	}
+
+	if result.BelowBlock > 0 || result.ExpiredBlocks > 0 {
+		if err := c.db.Compact(context.Background(), []byte(blockKeyPrefix), blockKeysEnd, true); err != nil {
+			return result, fmt.Errorf("failed to compact pruned blocks: %w", err)
+		}
+	}
+
+	after, err := c.db.EstimateDiskUsage([]byte(blockKeyPrefix), blockKeysEnd)
+	if err != nil {
+		return result, fmt.Errorf("failed to estimate cache size: %w", err)
+	}
+	if after < before {
+		result.FreedBytes = before - after
+	}
+	return result, nil
+}
+
+// pruneWrittenBefore deletes the blocks written before cutoff and their index entries
+func (c *Local) pruneWrittenBefore(cutoff time.Time) (int, error) {
+	upper := []byte(fmt.Sprintf("%s%012d", writtenKeyPrefix, cutoff.Unix()))
+	iter, err := c.db.NewIter(&pebble.IterOptions{
+		LowerBound: []byte(writtenKeyPrefix),
+		UpperBound: upper,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iter.Close()
+
+	batch := c.db.NewBatch()
+	defer batch.Close()
+	expired := 0
+	for iter.First(); iter.Valid(); iter.Next() {
+		key := iter.Key()
+		blockNum := parseBlockKey([]byte(blockKeyPrefix + string(key[len(key)-blockKeyPadding:])))
+		if blockNum >= 0 {
+			batch.Delete(formatBlockKey(blockNum), nil)
+			expired++
+		}
+		batch.Delete(key, nil)
+	}
+	if err := iter.Error(); err != nil {
+		return 0, fmt.Errorf("failed to scan write times: %w", err)
+	}
+	if err := batch.Commit(pebble.Sync); err != nil {
+		return 0, fmt.Errorf("failed to delete expired blocks: %w", err)
+	}
+	return expired, nil
+}
+
+// sizeCut returns the lowest height that keeps the blocks from it to the highest one within
+// maxBytes, 0 if all of them fit
+func (c *Local) sizeCut(maxBytes uint64) (int64, error) {
+	lowest, highest, err := c.blockBounds()
+	if err != nil || lowest < 0 {
+		return 0, err
+	}
+
+	size := func(from int64) (uint64, error) {
+		return c.db.EstimateDiskUsage(formatBlockKey(from), formatBlockKey(highest+1))
+	}
+	total, err := size(lowest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to estimate cache size: %w", err)
+	}
+	if total <= maxBytes {
+		return 0, nil
+	}
+
+	// Binary search the lowest cut whose tail fits, sizes shrink as the cut moves up
+	lo, hi := lowest, highest+1
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		tail, err := size(mid)
+		if err != nil {
+			return 0, fmt.Errorf("failed to estimate cache size: %w", err)
+		}
+		if tail <= maxBytes {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return lo, nil
+}
+
+// blockBounds returns the lowest and highest cached block, -1 for both if there are none
+func (c *Local) blockBounds() (int64, int64, error) {
+	iter, err := c.db.NewIter(&pebble.IterOptions{
+		LowerBound: formatBlockKey(0),
+		UpperBound: blockKeysEnd,
+	})
+	if err != nil {
+		return -1, -1, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iter.Close()
+
+	var lowest, highest int64 = -1, -1
+	if iter.First() {
+		lowest = parseBlockKey(iter.Key())
+	}
+	if iter.Last() {
+		highest = parseBlockKey(iter.Key())
+	}
+	if lowest < 0 || highest < 0 {
+		return -1, -1, nil
+	}
+	return lowest, highest, nil
+}
+
+// Prune prunes the local cache, object stores and cache servers keep their own retention
+func (c *Layered) Prune(r Retention) (PruneResult, error) {
+	return c.local.Prune(r)
+}
+
+// Prune is not supported, object stores expire blocks with bucket lifecycle rules
+func (s *Store) Prune(r Retention) (PruneResult, error) {
+	return PruneResult{}, errPruneUnsupported
+}
+
+// Prune is not supported, the cache server prunes its own caches
+func (c *Client) Prune(r Retention) (PruneResult, error) {
+	return PruneResult{}, errPruneUnsupported
+}
@@ -0,0 +1,309 @@
+package cache
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// maxServedRange is the most blocks one range request may ask for
+const maxServedRange = 10000
+
+// Server shares the caches of all chains over HTTP, so several ingest instances can use one
+// cache through NewClient instead of each downloading every block. Caches are opened with New
+// on first use, so the server itself can sit in front of an object store.
+//
+// Routes, all under /chains/{chain}:
+//
+//	GET /blocks?from=N&to=M  cached blocks of [N, M] as frames (see writeFrame)
+//	GET /blocks/{block}      one block, 404 if not cached
+//	PUT /blocks/{block}      store a block
+//	GET /checkpoint          last cached block checkpoint
+//	PUT /checkpoint          set the checkpoint
+//	GET /entries/{ns}/{key}  a namespaced entry as encoded by encodeEntry, 404 if not cached
+//	PUT /entries/{ns}/{key}  store a namespaced entry
+type Server struct {
+	dbPath string
+	dirs   map[uint32]string // Chains whose cache is outside dbPath
+	token  string
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	caches map[uint32]Cache
+}
+
+// NewServer returns a server for the caches under dbPath, or under dirs for the chains listed
+// there. Requests must carry token as a bearer token unless it is empty.
+func NewServer(dbPath string, dirs map[uint32]string, token string) *Server {
+	return &Server{
+		dbPath: dbPath,
+		dirs:   dirs,
+		token:  token,
+		logger: slog.With("component", "cache_server"),
+		caches: make(map[uint32]Cache),
+	}
+}
+
+// Handler returns the HTTP handler serving the routes of Server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /chains/{chain}/blocks", s.handleRange)
+	mux.HandleFunc("GET /chains/{chain}/blocks/{block}", s.handleGet)
+	mux.HandleFunc("PUT /chains/{chain}/blocks/{block}", s.handlePut)
+	mux.HandleFunc("GET /chains/{chain}/checkpoint", s.handleGetCheckpoint)
+	mux.HandleFunc("PUT /chains/{chain}/checkpoint", s.handleSetCheckpoint)
+	mux.HandleFunc("GET /chains/{chain}/entries/{ns}/{key}", s.handleGetEntry)
+	mux.HandleFunc("PUT /chains/{chain}/entries/{ns}/{key}", s.handleSetEntry)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// Close closes every opened cache
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	for chainID, c := range s.caches {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close cache of chain %d: %w", chainID, err)
+		}
+	}
+	return firstErr
+}
+
+// cache returns the cache of the request's chain, opening it on first use
+func (s *Server) cache(r *http.Request) (Cache, error) {
+	chainID, err := strconv.ParseUint(r.PathValue("chain"), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chain ID %q", r.PathValue("chain"))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.caches[uint32(chainID)]; ok {
+		return c, nil
+	}
+	dir := s.dbPath
+	if d, ok := s.dirs[uint32(chainID)]; ok {
+		dir = d
+	}
+	c, err := New(dir, uint32(chainID))
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("Opened cache", "chain_id", chainID)
+	s.caches[uint32(chainID)] = c
+	return c, nil
+}
+
+func (s *Server) handleRange(w http.ResponseWriter, r *http.Request) {
+	c, err := s.cache(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, errFrom := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+	to, errTo := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+	if errFrom != nil || errTo != nil || from > to || to-from >= maxServedRange {
+		http.Error(w, fmt.Sprintf("invalid range, expected from <= to and at most %d blocks", maxServedRange), http.StatusBadRequest)
+		return
+	}
+
+	blocks, err := c.GetBlockRange(from, to)
+	if err != nil {
+		s.logger.Error("Failed to read block range", "from", from, "to", to, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	bw := bufio.NewWriter(w)
+	for blockNum := from; blockNum <= to; blockNum++ {
+		if data, ok := blocks[blockNum]; ok {
+			if err := writeFrame(bw, blockNum, data); err != nil {
+				return
+			}
+		}
+	}
+	bw.Flush()
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	c, err := s.cache(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	blockNum, err := strconv.ParseInt(r.PathValue("block"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid block number", http.StatusBadRequest)
+		return
+	}
+
+	blocks, err := c.GetBlockRange(blockNum, blockNum)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data, ok := blocks[blockNum]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+func (s *Server) handlePut(w http.ResponseWriter, r *http.Request) {
+	c, err := s.cache(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	blockNum, err := strconv.ParseInt(r.PathValue("block"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid block number", http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := c.GetCompleteBlock(blockNum, func() ([]byte, error) { return data, nil }); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleGetCheckpoint(w http.ResponseWriter, r *http.Request) {
+	c, err := s.cache(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint, err := c.GetCheckpoint()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, checkpoint)
+}
+
+func (s *Server) handleSetCheckpoint(w http.ResponseWriter, r *http.Request) {
+	c, err := s.cache(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 32))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint, err := strconv.ParseInt(string(body), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid checkpoint", http.StatusBadRequest)
+		return
+	}
+	if err := c.SetCheckpoint(checkpoint); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleGetEntry(w http.ResponseWriter, r *http.Request) {
+	c, err := s.cache(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entry, err := c.GetEntry(Namespace(r.PathValue("ns")), r.PathValue("key"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if entry == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(encodeEntry(*entry))
+}
+
+func (s *Server) handleSetEntry(w http.ResponseWriter, r *http.Request) {
+	c, err := s.cache(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ns := Namespace(r.PathValue("ns"))
+	if !ns.valid() {
+		http.Error(w, "unknown namespace", http.StatusBadRequest)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entry, err := decodeEntry(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := c.SetEntry(ns, r.PathValue("key"), *entry); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeFrame writes a block of a range reply: its height as 8 bytes and its length as 4 bytes,
+// both big-endian, then the block itself
+func writeFrame(w io.Writer, blockNum int64, data []byte) error {
+	var header [12]byte
+	binary.BigEndian.PutUint64(header[:8], uint64(blockNum))
+	binary.BigEndian.PutUint32(header[8:], uint32(len(data)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// readFrames reads the blocks of a range reply until EOF
+func readFrames(r io.Reader) (map[int64][]byte, error) {
+	br := bufio.NewReader(r)
+	result := make(map[int64][]byte)
+	for {
+		var header [12]byte
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			if err == io.EOF {
+				return result, nil
+			}
+			return nil, fmt.Errorf("failed to read frame header: %w", err)
+		}
+		data := make([]byte, binary.BigEndian.Uint32(header[8:]))
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, fmt.Errorf("failed to read frame: %w", err)
+		}
+		result[int64(binary.BigEndian.Uint64(header[:8]))] = data
+	}
+}
@@ -0,0 +1,335 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// storeWriters is the number of workers uploading blocks to the object store
+	storeWriters = 16
+	// storeReaders is the number of concurrent downloads of a range read
+	storeReaders = 32
+	// storeListPage is the number of keys per listing request
+	storeListPage = 1000
+)
+
+// StoreOptions selects a remote home for the cache: a cache server or an object store
+type StoreOptions struct {
+	Dir         string // Directory of local caches, one subdirectory per chain (default: ./rpc_cache)
+	URL         string // s3://bucket/prefix or gs://bucket/prefix, empty keeps the cache local
+	Endpoint    string // S3-compatible endpoint for MinIO, R2 etc. (default: AWS S3 or the GCS XML API)
+	ServerURL   string // Cache server started with "cache serve", takes precedence over URL
+	ServerToken string // Bearer token of the cache server
+	Layered     bool   // Keep a local PebbleDB in front of the object store or cache server
+	ReadOnly    bool   // Never write, local caches open as a point-in-time view next to their writer
+}
+
+// storeOpts is set with Configure before any cache is opened
+var storeOpts StoreOptions
+
+// Configure sets the object store New opens caches in
+func Configure(opts StoreOptions) {
+	storeOpts = opts
+}
+
+// Dir returns the directory of local caches set with Configure
+func Dir() string {
+	if storeOpts.Dir == "" {
+		return DefaultDir
+	}
+	return storeOpts.Dir
+}
+
+// Store caches blocks in an S3-compatible object store, one gzip-compressed object per block,
+// so the cache outlives the machine. Writes are uploaded in the background.
+type Store struct {
+	store  *objectStore
+	prefix string // Key prefix of the chain, ends with a slash
+	logger *slog.Logger
+
+	writes   chan storeWrite
+	wg       sync.WaitGroup
+	readOnly bool
+
+	hits, misses, puts, failedPuts atomic.Int64
+}
+
+type storeWrite struct {
+	blockNum int64
+	key      string // Object key or server path of a namespaced entry, empty for blocks
+	data     []byte
+}
+
+// NewStore opens the cache of a chain in the object store of opts
+func NewStore(opts StoreOptions, chainID uint32) (*Store, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache store URL %q: %w", opts.URL, err)
+	}
+	if u.Scheme != "s3" && u.Scheme != "gs" {
+		return nil, fmt.Errorf("unsupported cache store URL %q: expected s3:// or gs://", opts.URL)
+	}
+
+	store, err := newObjectStore(u, opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	s := &Store{
+		store:    store,
+		prefix:   fmt.Sprintf("%s%d/", prefix, chainID),
+		logger:   slog.With("component", "cache", "store", u.Scheme+"://"+u.Host),
+		writes:   make(chan storeWrite, 1000),
+		readOnly: opts.ReadOnly,
+	}
+	for i := 0; i < storeWriters; i++ {
+		s.wg.Add(1)
+		go s.writer()
+	}
+	return s, nil
+}
+
+// blockKey returns the object key of a block, zero-padded so keys sort by height
+func (s *Store) blockKey(blockNum int64) string {
+	return fmt.Sprintf("%sblocks/%0*d", s.prefix, blockKeyPadding, blockNum)
+}
+
+// parseBlockKey extracts the block number from an object key, returns -1 if invalid
+func (s *Store) parseBlockKey(key string) int64 {
+	blockNum, err := strconv.ParseInt(strings.TrimPrefix(key, s.prefix+"blocks/"), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return blockNum
+}
+
+func (s *Store) checkpointKey() string {
+	return s.prefix + "checkpoint"
+}
+
+// entryKey returns the object key of a namespaced entry
+func (s *Store) entryKey(ns Namespace, key string) string {
+	return s.prefix + string(ns) + "/" + key
+}
+
+// GetCompleteBlock retrieves or fetches a complete block. Object store errors count as misses,
+// so an unreachable store slows ingestion down instead of stopping it.
+func (s *Store) GetCompleteBlock(blockNum int64, fetch func() ([]byte, error)) ([]byte, error) {
+	data, err := s.getBlock(context.Background(), blockNum)
+	if err == nil {
+		s.hits.Add(1)
+		return data, nil
+	}
+	if !errors.Is(err, errObjectNotFound) {
+		s.logger.Warn("Failed to read block from cache store", "block", blockNum, "error", err)
+	}
+	s.misses.Add(1)
+
+	data, err = fetch()
+	if err != nil {
+		return nil, err
+	}
+	if !s.readOnly {
+		s.writes <- storeWrite{blockNum: blockNum, data: data}
+	}
+	return data, nil
+}
+
+// GetBlockRange retrieves the cached blocks of [from, to], listing which exist first
+func (s *Store) GetBlockRange(from, to int64) (map[int64][]byte, error) {
+	if from > to {
+		return nil, fmt.Errorf("invalid range: from %d > to %d", from, to)
+	}
+	ctx := context.Background()
+
+	var heights []int64
+	marker := ""
+	if from > 0 {
+		marker = s.blockKey(from - 1)
+	}
+	for {
+		keys, truncated, err := s.store.list(ctx, s.prefix+"blocks/", marker, storeListPage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list cached blocks: %w", err)
+		}
+		done := !truncated || len(keys) == 0
+		for _, key := range keys {
+			blockNum := s.parseBlockKey(key)
+			if blockNum > to {
+				done = true
+				break
+			}
+			if blockNum >= from {
+				heights = append(heights, blockNum)
+			}
+		}
+		if done {
+			break
+		}
+		marker = keys[len(keys)-1]
+	}
+
+	result := make(map[int64][]byte, len(heights))
+	var mu sync.Mutex
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(storeReaders)
+	for _, blockNum := range heights {
+		g.Go(func() error {
+			data, err := s.getBlock(ctx, blockNum)
+			if errors.Is(err, errObjectNotFound) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read cached block %d: %w", blockNum, err)
+			}
+			mu.Lock()
+			result[blockNum] = data
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	s.hits.Add(int64(len(result)))
+	s.misses.Add(to - from + 1 - int64(len(result)))
+	return result, nil
+}
+
+// getBlock downloads and decompresses a block
+func (s *Store) getBlock(ctx context.Context, blockNum int64) ([]byte, error) {
+	compressed, err := s.store.get(ctx, s.blockKey(blockNum))
+	if err != nil {
+		return nil, err
+	}
+	data, err := gunzip(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress block %d: %w", blockNum, err)
+	}
+	return data, nil
+}
+
+// gunzip decompresses an object
+func gunzip(compressed []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+// GetEntry downloads the entry stored under key in ns, or returns nil if there is none
+func (s *Store) GetEntry(ns Namespace, key string) (*Entry, error) {
+	compressed, err := s.store.get(context.Background(), s.entryKey(ns, key))
+	if errors.Is(err, errObjectNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache entry: %w", err)
+	}
+	data, err := gunzip(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress cache entry: %w", err)
+	}
+	return decodeEntry(data)
+}
+
+// SetEntry queues an entry for upload
+func (s *Store) SetEntry(ns Namespace, key string, e Entry) error {
+	if !validEntryKey(key) {
+		return errInvalidEntryKey
+	}
+	if s.readOnly {
+		return nil
+	}
+	s.writes <- storeWrite{key: s.entryKey(ns, key), data: encodeEntry(e)}
+	return nil
+}
+
+// writer uploads queued blocks and entries until the queue is closed
+func (s *Store) writer() {
+	defer s.wg.Done()
+	for w := range s.writes {
+		var buf bytes.Buffer
+		gz, _ := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+		gz.Write(w.data)
+		gz.Close()
+
+		key := w.key
+		if key == "" {
+			key = s.blockKey(w.blockNum)
+		}
+		if err := s.store.put(context.Background(), key, buf.Bytes()); err != nil {
+			s.failedPuts.Add(1)
+			s.logger.Warn("Failed to cache block in store", "key", key, "error", err)
+			continue
+		}
+		s.puts.Add(1)
+	}
+}
+
+// Compact is a no-op, object stores don't need compaction
+func (s *Store) Compact(opts CompactOptions) error {
+	return nil
+}
+
+// GetMetrics returns hit, miss and upload counters
+func (s *Store) GetMetrics() string {
+	return fmt.Sprintf("object store %s: %d hits, %d misses, %d blocks uploaded, %d uploads failed, %d queued",
+		s.prefix, s.hits.Load(), s.misses.Load(), s.puts.Load(), s.failedPuts.Load(), len(s.writes))
+}
+
+// Close waits for queued uploads to finish
+func (s *Store) Close() error {
+	close(s.writes)
+	s.wg.Wait()
+	return nil
+}
+
+// GetCheckpoint retrieves the last cached block number checkpoint
+// Returns 0 if no checkpoint exists
+func (s *Store) GetCheckpoint() (int64, error) {
+	value, err := s.store.get(context.Background(), s.checkpointKey())
+	if errors.Is(err, errObjectNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get checkpoint: %w", err)
+	}
+
+	blockNum, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	return blockNum, nil
+}
+
+// SetCheckpoint saves the last cached block number checkpoint
+func (s *Store) SetCheckpoint(blockNum int64) error {
+	if s.readOnly {
+		return errReadOnly
+	}
+	value := []byte(strconv.FormatInt(blockNum, 10))
+	if err := s.store.put(context.Background(), s.checkpointKey(), value); err != nil {
+		return fmt.Errorf("failed to set checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package chwrapper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// ChainControl is the latest operator request for a chain
+type ChainControl struct {
+	Paused  bool
+	Version uint64
+}
+
+// SetChainPaused records a pause/resume request for a chain and returns its version
+func SetChainPaused(conn driver.Conn, chainID uint32, paused bool, reason string) (uint64, error) {
+	ctx := Idempotent(context.Background())
+
+	query := `
+	INSERT INTO chain_control (chain_id, paused, reason, version, updated_at)
+	VALUES (?, ?, ?, ?, ?)`
+
+	now := time.Now().UTC()
+	version := uint64(now.UnixNano())
+	if err := conn.Exec(ctx, query, chainID, paused, reason, version, now); err != nil {
+		return 0, fmt.Errorf("failed to set chain control: %w", err)
+	}
+
+	return version, nil
+}
+
+// GetChainControl returns the latest request for a chain, or an unpaused zero value if none exists
+func GetChainControl(conn driver.Conn, chainID uint32) (ChainControl, error) {
+	ctx := context.Background()
+
+	query := "SELECT paused, version FROM chain_control FINAL WHERE chain_id = ?"
+
+	rows, err := conn.Query(ctx, query, chainID)
+	if err != nil {
+		return ChainControl{}, fmt.Errorf("failed to query chain control: %w", err)
+	}
+	defer rows.Close()
+
+	var ctrl ChainControl
+	if rows.Next() {
+		if err := rows.Scan(&ctrl.Paused, &ctrl.Version); err != nil {
+			return ChainControl{}, fmt.Errorf("failed to scan chain control: %w", err)
+		}
+	}
+
+	return ctrl, rows.Err()
+}
+
+// AckChainControl records that a syncer has applied the request with the given version
+func AckChainControl(conn driver.Conn, chainID uint32, version uint64, paused bool) error {
+	return ack(conn, "chain_control_acks", chainID, version, paused)
+}
+
+// AckIndexerControl records that a standalone indexer has applied the request with the given version
+func AckIndexerControl(conn driver.Conn, chainID uint32, version uint64, paused bool) error {
+	return ack(conn, "indexer_control_acks", chainID, version, paused)
+}
+
+// ack records an acknowledgement in table
+func ack(conn driver.Conn, table string, chainID uint32, version uint64, paused bool) error {
+	ctx := Idempotent(context.Background())
+
+	query := fmt.Sprintf(`
+	INSERT INTO %s (chain_id, paused, version, acked_at)
+	VALUES (?, ?, ?, ?)`, table)
+
+	if err := conn.Exec(ctx, query, chainID, paused, version, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to ack chain control: %w", err)
+	}
+
+	return nil
+}
+
+// WaitForChainControlAck polls until a syncer acknowledges the request with the given version
+func WaitForChainControlAck(conn driver.Conn, chainID uint32, version uint64, timeout time.Duration) error {
+	return waitForAck(conn, "chain_control_acks", "syncer", chainID, version, timeout)
+}
+
+// WaitForIndexerControlAck polls until a standalone indexer acknowledges the request with the given version
+func WaitForIndexerControlAck(conn driver.Conn, chainID uint32, version uint64, timeout time.Duration) error {
+	return waitForAck(conn, "indexer_control_acks", "indexer", chainID, version, timeout)
+}
+
+// waitForAck polls table until the request with the given version is acknowledged
+func waitForAck(conn driver.Conn, table, acker string, chainID uint32, version uint64, timeout time.Duration) error {
+	ctx := context.Background()
+
+	query := fmt.Sprintf("SELECT count() FROM %s WHERE chain_id = ? AND version = ?", table)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var count uint64
+		if err := conn.QueryRow(ctx, query, chainID, version).Scan(&count); err != nil {
+			return fmt.Errorf("failed to query chain control ack: %w", err)
+		}
+		if count > 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("no %s acknowledged chain %d control request within %v", acker, chainID, timeout)
+		}
+		time.Sleep(time.Second)
+	}
+}
@@ -9,33 +9,78 @@ import (
 )
 
 // UpsertChainStatus inserts or updates chain status with name and initial metadata
-func UpsertChainStatus(conn driver.Conn, chainID uint32, name string, lastBlockOnChain uint64) error {
-	ctx := context.Background()
+func UpsertChainStatus(conn driver.Conn, chainID uint32, name string, lastBlockOnChain uint64, degradedReason string) error {
+	ctx := Idempotent(context.Background())
 
 	query := `
-	INSERT INTO chain_status (chain_id, name, last_updated, last_block_on_chain) 
-	VALUES (?, ?, ?, ?)`
+	INSERT INTO chain_status (chain_id, name, last_updated, last_block_on_chain, degraded_reason) 
+	VALUES (?, ?, ?, ?, ?)`
 
 	now := time.Now().UTC()
-	if err := conn.Exec(ctx, query, chainID, name, now, lastBlockOnChain); err != nil {
+	if err := conn.Exec(ctx, query, chainID, name, now, lastBlockOnChain, degradedReason); err != nil {
 		return fmt.Errorf("failed to upsert chain status: %w", err)
 	}
 
 	return nil
 }
 
-// UpdateLatestBlock updates the last_block_on_chain and last_updated fields
-func UpdateLatestBlock(conn driver.Conn, chainID uint32, name string, lastBlockOnChain uint64) error {
-	ctx := context.Background()
+// UpdateLatestBlock updates the last_block_on_chain, degraded_reason and last_updated fields
+func UpdateLatestBlock(conn driver.Conn, chainID uint32, name string, lastBlockOnChain uint64, degradedReason string) error {
+	ctx := Idempotent(context.Background())
 
 	query := `
-	INSERT INTO chain_status (chain_id, name, last_updated, last_block_on_chain) 
-	VALUES (?, ?, ?, ?)`
+	INSERT INTO chain_status (chain_id, name, last_updated, last_block_on_chain, degraded_reason) 
+	VALUES (?, ?, ?, ?, ?)`
 
 	now := time.Now().UTC()
-	if err := conn.Exec(ctx, query, chainID, name, now, lastBlockOnChain); err != nil {
+	if err := conn.Exec(ctx, query, chainID, name, now, lastBlockOnChain, degradedReason); err != nil {
 		return fmt.Errorf("failed to update latest block: %w", err)
 	}
 
 	return nil
 }
+
+// ChainTip is a chain's tip on the RPC and how far ingestion trails it
+type ChainTip struct {
+	ChainID          uint32
+	Name             string
+	LastBlockOnChain uint64
+	Watermark        uint64 // Highest ingested block
+	Lag              uint64 // Blocks between the watermark and the chain tip
+	DegradedReason   string
+	LastUpdated      time.Time // When the syncer last saw the chain tip
+}
+
+// GetChainTips returns the tip and ingestion lag of every chain in chain_status
+func GetChainTips(conn driver.Conn) ([]ChainTip, error) {
+	ctx := context.Background()
+
+	query := `
+	SELECT s.chain_id, s.name, s.last_block_on_chain, toUInt64(w.block_number), s.degraded_reason, s.last_updated
+	FROM chain_status AS s FINAL
+	LEFT JOIN sync_watermark AS w ON w.chain_id = s.chain_id
+	ORDER BY s.chain_id`
+
+	rows, err := conn.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chain tips: %w", err)
+	}
+	defer rows.Close()
+
+	var tips []ChainTip
+	for rows.Next() {
+		var tip ChainTip
+		if err := rows.Scan(&tip.ChainID, &tip.Name, &tip.LastBlockOnChain, &tip.Watermark, &tip.DegradedReason, &tip.LastUpdated); err != nil {
+			return nil, fmt.Errorf("failed to scan chain tip: %w", err)
+		}
+		if tip.LastBlockOnChain > tip.Watermark {
+			tip.Lag = tip.LastBlockOnChain - tip.Watermark
+		}
+		tips = append(tips, tip)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating chain tips: %w", err)
+	}
+
+	return tips, nil
+}
@@ -0,0 +1,81 @@
+package chwrapper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultZooKeeperPath is the replication path of each table in cluster mode. ClickHouse expands
+// the macros, {shard} and {replica} from the server's macros config.
+const DefaultZooKeeperPath = "/clickhouse/tables/{shard}/{database}/{table}"
+
+// DefaultReplica is the replica name of each table in cluster mode
+const DefaultReplica = "{replica}"
+
+// Cluster is the ClickHouse cluster tables are created on. Tables are created ON CLUSTER with
+// Replicated engines, so every replica holds every row. An empty Name targets a single server.
+type Cluster struct {
+	Name          string // Cluster from the server's remote_servers config
+	ZooKeeperPath string // Replication path of each table (default: DefaultZooKeeperPath)
+	Replica       string // Replica name (default: DefaultReplica)
+}
+
+var cluster Cluster
+
+// SetCluster sets the cluster DDL is run on, an empty Name keeps a single server
+func SetCluster(c Cluster) error {
+	if c.Name != "" && !identifierPattern.MatchString(c.Name) {
+		return fmt.Errorf("invalid cluster name %q", c.Name)
+	}
+	if c.ZooKeeperPath == "" {
+		c.ZooKeeperPath = DefaultZooKeeperPath
+	}
+	if c.Replica == "" {
+		c.Replica = DefaultReplica
+	}
+	if strings.Contains(c.ZooKeeperPath, "'") || strings.Contains(c.Replica, "'") {
+		return fmt.Errorf("cluster ZooKeeper path and replica can't contain quotes")
+	}
+	cluster = c
+	return nil
+}
+
+var (
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// ddlTargetPattern matches up to the object name of the statements run ON CLUSTER
+	ddlTargetPattern = regexp.MustCompile("(?is)^\\s*((?:CREATE\\s+(?:OR\\s+REPLACE\\s+)?(?:TABLE|VIEW|MATERIALIZED\\s+VIEW|DATABASE)(?:\\s+IF\\s+NOT\\s+EXISTS)?" +
+		"|ALTER\\s+TABLE|DROP\\s+(?:TABLE|VIEW)(?:\\s+IF\\s+EXISTS)?)\\s+[\\w.`]+)")
+	// mutationPattern matches ALTER TABLE DELETE and UPDATE, replicated to the other replicas by themselves
+	mutationPattern = regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\s+[\w.` + "`" + `]+\s+(?:DELETE|UPDATE)\b`)
+	// enginePattern matches the MergeTree engines and their arguments
+	enginePattern = regexp.MustCompile(`(?i)ENGINE\s*=\s*(\w*?)MergeTree\b(?:\s*\(([^)]*)\))?`)
+)
+
+// ClusterDDL rewrites a DDL statement for the cluster set with SetCluster: CREATE, ALTER and DROP
+// run ON CLUSTER, MergeTree engines become Replicated ones and the deduplication window of inserts
+// is the replicated one. Other statements, and all of them without a cluster, are returned as is.
+func ClusterDDL(stmt string) string {
+	if cluster.Name == "" || mutationPattern.MatchString(stmt) {
+		return stmt
+	}
+	loc := ddlTargetPattern.FindStringSubmatchIndex(stmt)
+	if loc == nil {
+		return stmt
+	}
+	stmt = stmt[:loc[3]] + " ON CLUSTER " + cluster.Name + stmt[loc[3]:]
+
+	stmt = enginePattern.ReplaceAllStringFunc(stmt, func(engine string) string {
+		m := enginePattern.FindStringSubmatch(engine)
+		if strings.HasPrefix(strings.ToLower(m[1]), "replicated") {
+			return engine
+		}
+		args := fmt.Sprintf("'%s', '%s'", cluster.ZooKeeperPath, cluster.Replica)
+		if strings.TrimSpace(m[2]) != "" {
+			args += ", " + m[2]
+		}
+		return fmt.Sprintf("ENGINE = Replicated%sMergeTree(%s)", m[1], args)
+	})
+	return strings.ReplaceAll(stmt, "non_replicated_deduplication_window", "replicated_deduplication_window")
+}
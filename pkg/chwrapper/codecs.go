@@ -0,0 +1,177 @@
+package chwrapper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// DefaultCodecs are the compression codecs of raw table columns, by table and column. Block
+// numbers and times grow steadily, so their deltas compress to almost nothing, and calldata,
+// trace output and log data compress several times better with ZSTD than the default LZ4.
+var DefaultCodecs = map[string]map[string]string{
+	"raw_blocks": {
+		"block_number": "Delta, ZSTD(1)",
+		"block_time":   "DoubleDelta, ZSTD(1)",
+		"extra_data":   "ZSTD(3)",
+	},
+	"raw_txs": {
+		"block_number": "Delta, ZSTD(1)",
+		"block_time":   "Delta, ZSTD(1)",
+		"input":        "ZSTD(3)",
+	},
+	"raw_traces": {
+		"block_number": "Delta, ZSTD(1)",
+		"block_time":   "Delta, ZSTD(1)",
+		"input":        "ZSTD(3)",
+		"output":       "ZSTD(3)",
+	},
+	"raw_logs": {
+		"block_number": "Delta, ZSTD(1)",
+		"block_time":   "Delta, ZSTD(1)",
+		"data":         "ZSTD(3)",
+	},
+	"p_chain_blocks": {
+		"height":     "Delta, ZSTD(1)",
+		"block_time": "DoubleDelta, ZSTD(1)",
+	},
+	"p_chain_txs": {
+		"block_number": "Delta, ZSTD(1)",
+		"block_time":   "Delta, ZSTD(1)",
+	},
+}
+
+// codecs are the codecs CreateTables applies, nil when codecs are left as they are
+var codecs = DefaultCodecs
+
+// codecParamPattern matches the type width ClickHouse adds to delta codecs, e.g. Delta(4)
+var codecParamPattern = regexp.MustCompile(`\b(Delta|DoubleDelta|Gorilla)\(\d+\)`)
+
+// ParseCodecs parses semicolon-separated table.column=codec pairs over DefaultCodecs, e.g.
+// "raw_traces.input=ZSTD(6);raw_txs.input=ZSTD(6)". "none" leaves every codec as it is, and a
+// column set to "default" keeps the codec it has.
+func ParseCodecs(s string) (map[string]map[string]string, error) {
+	if strings.TrimSpace(s) == "none" {
+		return nil, nil
+	}
+	parsed := make(map[string]map[string]string, len(DefaultCodecs))
+	for table, columns := range DefaultCodecs {
+		parsed[table] = make(map[string]string, len(columns))
+		for column, codec := range columns {
+			parsed[table][column] = codec
+		}
+	}
+
+	for _, pair := range strings.Split(s, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, codec, ok := strings.Cut(pair, "=")
+		table, column, dotted := strings.Cut(strings.TrimSpace(name), ".")
+		codec = strings.TrimSpace(codec)
+		if !ok || !dotted || !identifierPattern.MatchString(table) || !identifierPattern.MatchString(column) || codec == "" {
+			return nil, fmt.Errorf("invalid codec %q, expected table.column=codec", pair)
+		}
+		if strings.ContainsAny(codec, ";'`") {
+			return nil, fmt.Errorf("invalid codec of %s.%s: %q", table, column, codec)
+		}
+		if parsed[table] == nil {
+			parsed[table] = make(map[string]string)
+		}
+		if codec == "default" {
+			delete(parsed[table], column)
+			continue
+		}
+		parsed[table][column] = codec
+	}
+	return parsed, nil
+}
+
+// SetCodecs sets the codecs CreateTables applies, see ParseCodecs
+func SetCodecs(tableCodecs map[string]map[string]string) {
+	codecs = tableCodecs
+}
+
+// SameCodec reports whether a column's compression_codec from system.columns is codec, ignoring
+// spacing and the type widths ClickHouse adds
+func SameCodec(current, codec string) bool {
+	normalize := func(s string) string {
+		s = strings.TrimSpace(s)
+		if strings.HasPrefix(s, "CODEC(") && strings.HasSuffix(s, ")") {
+			s = s[len("CODEC(") : len(s)-1]
+		}
+		s = codecParamPattern.ReplaceAllString(s, "$1")
+		return strings.ReplaceAll(s, " ", "")
+	}
+	return normalize(current) == normalize(codec)
+}
+
+// applyCodecs sets the codec of every column with one that has another. Only parts written or
+// merged afterwards use it. A codec that can't be set is logged and skipped, since compression
+// never decides whether ingestion works.
+func applyCodecs(conn driver.Conn) error {
+	tables := make([]string, 0, len(codecs))
+	for table := range codecs {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	ctx := Idempotent(context.Background())
+	for _, table := range tables {
+		current, err := columnCodecs(ctx, conn, table)
+		if err != nil {
+			return err
+		}
+		columns := make([]string, 0, len(codecs[table]))
+		for column := range codecs[table] {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+
+		for _, column := range columns {
+			codec := codecs[table][column]
+			have, ok := current[column]
+			if !ok {
+				slog.Warn("Skipping codec of unknown column", "table", table, "column", column)
+				continue
+			}
+			if SameCodec(have, codec) {
+				continue
+			}
+			query := fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s CODEC(%s)", table, column, codec)
+			if err := conn.Exec(ctx, ClusterDDL(query)); err != nil {
+				slog.Warn("Failed to set column codec", "table", table, "column", column, "codec", codec, "error", err)
+				continue
+			}
+			slog.Info("Set column codec", "table", table, "column", column, "codec", codec)
+		}
+	}
+	return nil
+}
+
+// columnCodecs returns the compression_codec of each column of table, empty if the table doesn't exist
+func columnCodecs(ctx context.Context, conn driver.Conn, table string) (map[string]string, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT name, compression_codec FROM system.columns
+		WHERE database = currentDatabase() AND table = ?`, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	current := make(map[string]string)
+	for rows.Next() {
+		var name, codec string
+		if err := rows.Scan(&name, &codec); err != nil {
+			return nil, fmt.Errorf("failed to scan column of %s: %w", table, err)
+		}
+		current[name] = codec
+	}
+	return current, rows.Err()
+}
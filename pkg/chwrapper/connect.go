@@ -3,6 +3,7 @@ package chwrapper
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"time"
 
@@ -10,13 +11,58 @@ import (
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 )
 
+// DefaultDatabase is the database Icicle's tables are in unless SetDatabase picks another
+const DefaultDatabase = "default"
+
+var database = DefaultDatabase
+
+// SetDatabase sets the database Connect uses, so several deployments can share a ClickHouse
+// server, each in its own database
+func SetDatabase(name string) error {
+	if !identifierPattern.MatchString(name) {
+		return fmt.Errorf("invalid database name %q", name)
+	}
+	database = name
+	return nil
+}
+
+// Database returns the database Connect uses
+func Database() string {
+	return database
+}
+
+// Connect connects to the database set with SetDatabase, creating it if it doesn't exist
 func Connect() (driver.Conn, error) {
+	if database != DefaultDatabase {
+		if err := createDatabase(database); err != nil {
+			return nil, err
+		}
+	}
+	return ConnectDatabase(database)
+}
+
+// createDatabase creates database through a connection to the default one
+func createDatabase(name string) error {
+	conn, err := ConnectDatabase(DefaultDatabase)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.Exec(context.Background(), ClusterDDL("CREATE DATABASE IF NOT EXISTS "+name)); err != nil {
+		return fmt.Errorf("failed to create database %s: %w", name, err)
+	}
+	return nil
+}
+
+// ConnectDatabase connects like Connect, with unqualified table names resolving to database.
+// Queries of the connection time out and are retried as set with ConfigureQueries.
+func ConnectDatabase(database string) (driver.Conn, error) {
 	var (
 		ctx       = context.Background()
 		conn, err = clickhouse.Open(&clickhouse.Options{
 			Addr: []string{"127.0.0.1:9000"},
 			Auth: clickhouse.Auth{
-				Database: "default",
+				Database: database,
 				Username: "default",
 				Password: os.Getenv("CLICKHOUSE_PASSWORD"),
 			},
@@ -35,7 +81,7 @@ func Connect() (driver.Conn, error) {
 			DialTimeout:     30 * time.Second, // Wait longer for connection
 			ConnMaxLifetime: 1 * time.Hour,    // Recycle connections periodically
 			Debugf: func(format string, v ...interface{}) {
-				fmt.Printf(format, v)
+				slog.Debug(fmt.Sprintf(format, v...), "component", "clickhouse")
 			},
 		})
 	)
@@ -46,9 +92,9 @@ func Connect() (driver.Conn, error) {
 
 	if err := conn.Ping(ctx); err != nil {
 		if exception, ok := err.(*clickhouse.Exception); ok {
-			fmt.Printf("Exception [%d] %s \n%s\n", exception.Code, exception.Message, exception.StackTrace)
+			slog.Error("ClickHouse exception", "code", exception.Code, "message", exception.Message, "stack_trace", exception.StackTrace)
 		}
 		return nil, err
 	}
-	return conn, nil
+	return retryConn{Conn: conn}, nil
 }
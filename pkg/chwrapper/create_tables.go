@@ -17,11 +17,14 @@ func CreateTables(conn driver.Conn) error {
 	if err != nil {
 		return fmt.Errorf("failed to create tables: %w", err)
 	}
-	return nil
+	if err := applyCodecs(conn); err != nil {
+		return err
+	}
+	return applyRetention(conn)
 }
 
 func ExecuteSql(conn driver.Conn, sql string) error {
-	ctx := context.Background()
+	ctx := Idempotent(context.Background())
 
 	statements := strings.Split(sql, ";")
 
@@ -40,7 +43,7 @@ func ExecuteSql(conn driver.Conn, sql string) error {
 			continue
 		}
 
-		if err := conn.Exec(ctx, cleanStmt); err != nil {
+		if err := conn.Exec(ctx, ClusterDDL(cleanStmt)); err != nil {
 			return fmt.Errorf("failed to execute statement: %w", err)
 		}
 	}
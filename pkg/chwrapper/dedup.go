@@ -0,0 +1,24 @@
+package chwrapper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// DedupToken identifies one write of a block range to a table. epoch tells apart writes of the
+// same range after the data was deleted, e.g. by resync, which must not be deduplicated.
+func DedupToken(chainID uint32, table string, epoch int64, from, to uint32) string {
+	return fmt.Sprintf("%d:%s:%d:%d-%d", chainID, table, epoch, from, to)
+}
+
+// WithDedupToken returns a context whose inserts carry token as insert_deduplication_token.
+// ClickHouse drops an insert whose token it has already seen, so retrying an insert that may
+// have landed before failing never duplicates rows. Tables need a deduplication window, see
+// raw_tables.sql. Settings of WithInsertSettings in ctx are kept.
+func WithDedupToken(ctx context.Context, token string) context.Context {
+	settings := insertSettings(ctx)
+	settings["insert_deduplication_token"] = token
+	return clickhouse.Context(ctx, clickhouse.WithSettings(settings))
+}
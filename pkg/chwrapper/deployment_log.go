@@ -0,0 +1,106 @@
+package chwrapper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// Deployment event types recorded in deployment_log
+const (
+	DeploymentBinary     = "binary"
+	DeploymentSchema     = "schema"
+	DeploymentIndexerSQL = "indexer_sql"
+)
+
+// DeploymentItem is something whose changes are recorded in deployment_log
+type DeploymentItem struct {
+	EventType string
+	Subject   string // e.g. "raw_tables.sql", "evm_metrics/tx_count.sql", "icicle"
+	Checksum  string // Content hash, or version string for binaries
+}
+
+// Checksum returns the hex SHA-256 of content
+func Checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// SchemaDeploymentItems returns deployment items for the schema files embedded in this package
+func SchemaDeploymentItems() []DeploymentItem {
+	return []DeploymentItem{
+		{EventType: DeploymentSchema, Subject: "raw_tables.sql", Checksum: Checksum([]byte(rawTablesSQL))},
+	}
+}
+
+// RecordDeployment logs every item whose checksum differs from the last one recorded for it
+// and returns the number of changes recorded
+func RecordDeployment(conn driver.Conn, version string, items []DeploymentItem) (int, error) {
+	ctx := context.Background()
+
+	rows, err := conn.Query(ctx, `
+	SELECT event_type, subject, argMax(checksum, event_time)
+	FROM deployment_log
+	GROUP BY event_type, subject`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query deployment log: %w", err)
+	}
+	defer rows.Close()
+
+	previous := make(map[[2]string]string)
+	for rows.Next() {
+		var eventType, subject, checksum string
+		if err := rows.Scan(&eventType, &subject, &checksum); err != nil {
+			return 0, fmt.Errorf("failed to scan deployment log: %w", err)
+		}
+		previous[[2]string{eventType, subject}] = checksum
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating deployment log: %w", err)
+	}
+
+	var changed []DeploymentItem
+	for _, item := range items {
+		if previous[[2]string{item.EventType, item.Subject}] != item.Checksum {
+			changed = append(changed, item)
+		}
+	}
+	if len(changed) == 0 {
+		return 0, nil
+	}
+
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO deployment_log (
+		event_time, event_type, subject, checksum, previous_checksum, version, hostname
+	)`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare deployment log batch: %w", err)
+	}
+
+	now := time.Now().UTC()
+	hostname, _ := os.Hostname()
+	for _, item := range changed {
+		err := batch.Append(
+			now,
+			item.EventType,
+			item.Subject,
+			item.Checksum,
+			previous[[2]string{item.EventType, item.Subject}],
+			version,
+			hostname,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to append deployment event for %s: %w", item.Subject, err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return 0, fmt.Errorf("failed to send deployment log batch: %w", err)
+	}
+
+	return len(changed), nil
+}
@@ -0,0 +1,154 @@
+package chwrapper
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// Dimensions versioned in dimension_history
+const (
+	DimensionL1Registry  = "l1_registry"  // Off-chain L1 metadata (chain ID 0, versioned by time only)
+	DimensionSubnetOwner = "subnet_owner" // Subnet owner, or L1 manager after conversion (P-Chain heights)
+)
+
+// OpenBlock is the valid_to_block of a key's current version
+const OpenBlock = math.MaxUint64
+
+// OpenTime is the valid_to_time of a key's current version (DateTime64's upper bound)
+var OpenTime = time.Date(2299, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// DimensionVersion is a key's value from a block and time until the key's next version
+type DimensionVersion struct {
+	Key       string
+	Value     string // JSON attributes
+	FromBlock uint64
+	FromTime  time.Time
+}
+
+// RecordDimensionVersions closes the current version of every key whose value changed and opens
+// the new one. Versions that aren't newer than a key's current version or don't change its value
+// are skipped, so history can be replayed safely. Returns the number of versions opened.
+func RecordDimensionVersions(conn driver.Conn, chainID uint32, dimension string, versions []DimensionVersion) (int, error) {
+	if len(versions) == 0 {
+		return 0, nil
+	}
+	ctx := context.Background()
+
+	rows, err := conn.Query(ctx, `
+	SELECT dim_key, value, valid_from_block, valid_from_time
+	FROM dimension_history FINAL
+	WHERE chain_id = ? AND dimension = ? AND valid_to_time = ?`, chainID, dimension, OpenTime)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query current %s versions: %w", dimension, err)
+	}
+	defer rows.Close()
+
+	current := make(map[string]DimensionVersion)
+	for rows.Next() {
+		var v DimensionVersion
+		if err := rows.Scan(&v.Key, &v.Value, &v.FromBlock, &v.FromTime); err != nil {
+			return 0, fmt.Errorf("failed to scan %s version: %w", dimension, err)
+		}
+		current[v.Key] = v
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating %s versions: %w", dimension, err)
+	}
+
+	sorted := append([]DimensionVersion(nil), versions...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].FromBlock != sorted[j].FromBlock {
+			return sorted[i].FromBlock < sorted[j].FromBlock
+		}
+		return sorted[i].FromTime.Before(sorted[j].FromTime)
+	})
+
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO dimension_history (
+		chain_id, dimension, dim_key, value,
+		valid_from_block, valid_to_block, valid_from_time, valid_to_time, recorded_at
+	)`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare dimension history batch: %w", err)
+	}
+
+	now := time.Now().UTC()
+	opened := 0
+	for _, v := range sorted {
+		prev, ok := current[v.Key]
+		if ok {
+			if v.FromBlock < prev.FromBlock || (v.FromBlock == prev.FromBlock && !v.FromTime.After(prev.FromTime)) {
+				continue
+			}
+			if v.Value == prev.Value {
+				continue
+			}
+
+			// Close the previous version by re-inserting it with its end set
+			err := batch.Append(chainID, dimension, prev.Key, prev.Value,
+				prev.FromBlock, v.FromBlock, prev.FromTime, v.FromTime, now)
+			if err != nil {
+				return 0, fmt.Errorf("failed to append closed %s version for %s: %w", dimension, prev.Key, err)
+			}
+		}
+
+		err := batch.Append(chainID, dimension, v.Key, v.Value,
+			v.FromBlock, uint64(OpenBlock), v.FromTime, OpenTime, now)
+		if err != nil {
+			return 0, fmt.Errorf("failed to append %s version for %s: %w", dimension, v.Key, err)
+		}
+		current[v.Key] = v
+		opened++
+	}
+
+	if opened == 0 {
+		return 0, batch.Abort()
+	}
+	if err := batch.Send(); err != nil {
+		return 0, fmt.Errorf("failed to send dimension history batch: %w", err)
+	}
+	return opened, nil
+}
+
+// GetDimensionAsOfBlock returns a key's value at a block, or false if the key had no value yet
+func GetDimensionAsOfBlock(conn driver.Conn, chainID uint32, dimension, key string, block uint64) (string, bool, error) {
+	return getDimensionAsOf(conn, `
+	SELECT value
+	FROM dimension_history FINAL
+	WHERE chain_id = ? AND dimension = ? AND dim_key = ?
+	  AND valid_from_block <= ? AND ? < valid_to_block
+	ORDER BY valid_from_block DESC, valid_from_time DESC
+	LIMIT 1`, chainID, dimension, key, block, block)
+}
+
+// GetDimensionAsOfTime returns a key's value at a time, or false if the key had no value yet
+func GetDimensionAsOfTime(conn driver.Conn, chainID uint32, dimension, key string, t time.Time) (string, bool, error) {
+	return getDimensionAsOf(conn, `
+	SELECT value
+	FROM dimension_history FINAL
+	WHERE chain_id = ? AND dimension = ? AND dim_key = ?
+	  AND valid_from_time <= ? AND ? < valid_to_time
+	ORDER BY valid_from_time DESC
+	LIMIT 1`, chainID, dimension, key, t, t)
+}
+
+func getDimensionAsOf(conn driver.Conn, query string, args ...any) (string, bool, error) {
+	rows, err := conn.Query(context.Background(), query, args...)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to query dimension history: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return "", false, rows.Err()
+	}
+	var value string
+	if err := rows.Scan(&value); err != nil {
+		return "", false, fmt.Errorf("failed to scan dimension value: %w", err)
+	}
+	return value, true, nil
+}
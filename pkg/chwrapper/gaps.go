@@ -0,0 +1,62 @@
+package chwrapper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// gapScanPriority is the ClickHouse priority of gap scans, lower than the default 0 of inserts
+// and other queries so a scan never slows ingestion down
+const gapScanPriority = 10
+
+// Gap is a range of missing block heights, both ends included
+type Gap struct {
+	From uint64
+	To   uint64
+}
+
+// Size returns the number of missing blocks
+func (g Gap) Size() uint64 {
+	return g.To - g.From + 1
+}
+
+// FindBlockGaps returns the heights missing from table for a chain between the lowest height
+// it has in [from, to] and to. chainColumn and heightColumn name the table's chain ID and block
+// height columns.
+func FindBlockGaps(conn driver.Conn, table, chainColumn, heightColumn string, chainID uint32, from, to uint64) ([]Gap, error) {
+	// to+1 is appended so that blocks missing at the end of the range show up as a gap too.
+	// The first height has no predecessor (prev = 0) and never starts a gap.
+	query := fmt.Sprintf(`
+		SELECT prev + 1, height - 1 FROM (
+			SELECT height, lagInFrame(height) OVER (ORDER BY height ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS prev
+			FROM (
+				SELECT DISTINCT toUInt64(%s) AS height FROM %s WHERE %s = ? AND %s BETWEEN ? AND ?
+				UNION ALL
+				SELECT toUInt64(?) AS height
+			)
+		)
+		WHERE prev > 0 AND height > prev + 1
+		ORDER BY height`, heightColumn, table, chainColumn, heightColumn)
+
+	ctx := clickhouse.Context(context.Background(), clickhouse.WithSettings(clickhouse.Settings{
+		"priority": gapScanPriority,
+	}))
+	rows, err := conn.Query(ctx, query, chainID, from, to, to+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query gaps in %s for chain %d: %w", table, chainID, err)
+	}
+	defer rows.Close()
+
+	var gaps []Gap
+	for rows.Next() {
+		var gap Gap
+		if err := rows.Scan(&gap.From, &gap.To); err != nil {
+			return nil, fmt.Errorf("failed to scan gap: %w", err)
+		}
+		gaps = append(gaps, gap)
+	}
+	return gaps, rows.Err()
+}
@@ -0,0 +1,49 @@
+package chwrapper
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"icicle/pkg/metrics"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// insertTablePattern matches the table of an INSERT statement
+var insertTablePattern = regexp.MustCompile("(?is)^\\s*INSERT\\s+INTO\\s+([\\w.`]+)")
+
+// insertTable returns the table an INSERT writes to, "unknown" if query isn't one
+func insertTable(query string) string {
+	m := insertTablePattern.FindStringSubmatch(query)
+	if m == nil {
+		return "unknown"
+	}
+	return strings.ReplaceAll(m[1], "`", "")
+}
+
+// meteredBatch records the rows, bytes and latency of a batch with metrics.ObserveInsert when it
+// is sent. Bytes are summed from the progress packets ClickHouse sends while writing the batch.
+type meteredBatch struct {
+	driver.Batch
+	table string
+	bytes *atomic.Uint64
+}
+
+// withInsertProgress returns a context counting the bytes ClickHouse reports writing into bytes
+func withInsertProgress(ctx context.Context, bytes *atomic.Uint64) context.Context {
+	return clickhouse.Context(ctx, clickhouse.WithProgress(func(p *clickhouse.Progress) {
+		bytes.Add(p.WroteBytes)
+	}))
+}
+
+func (b meteredBatch) Send() error {
+	start := time.Now()
+	rows := b.Batch.Rows()
+	err := b.Batch.Send()
+	metrics.ObserveInsert(b.table, rows, b.bytes.Load(), start, err)
+	return err
+}
@@ -0,0 +1,60 @@
+package chwrapper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// insertSettingsKey holds the settings of WithInsertSettings in a context
+type insertSettingsKey struct{}
+
+// WithInsertSettings returns a context whose inserts carry settings, e.g. async_insert or
+// max_insert_block_size. WithDedupToken keeps them. Returns ctx unchanged if settings is empty.
+func WithInsertSettings(ctx context.Context, settings map[string]string) context.Context {
+	if len(settings) == 0 {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, insertSettingsKey{}, settings)
+	return clickhouse.Context(ctx, clickhouse.WithSettings(insertSettings(ctx)))
+}
+
+// CheckInsertSettings returns an error if ClickHouse rejects settings, e.g. an unknown name, so
+// bad settings fail on start instead of on the first insert
+func CheckInsertSettings(conn driver.Conn, settings map[string]string) error {
+	if len(settings) == 0 {
+		return nil
+	}
+	if err := conn.Exec(WithInsertSettings(context.Background(), settings), "SELECT 1"); err != nil {
+		return fmt.Errorf("invalid insert settings: %w", err)
+	}
+	return nil
+}
+
+// insertSettings returns a copy of the settings of WithInsertSettings in ctx, empty if none
+func insertSettings(ctx context.Context) clickhouse.Settings {
+	settings, _ := ctx.Value(insertSettingsKey{}).(map[string]string)
+	merged := make(clickhouse.Settings, len(settings)+1)
+	for name, value := range settings {
+		merged[name] = value
+	}
+	return merged
+}
+
+// UnconfirmedAsyncInserts reports whether settings make inserts return before their data is
+// written, async_insert without wait_for_async_insert. A failed flush then loses rows that the
+// watermark already counts as written.
+func UnconfirmedAsyncInserts(settings map[string]string) bool {
+	return isTrue(settings["async_insert"]) && isFalse(settings["wait_for_async_insert"])
+}
+
+func isTrue(value string) bool {
+	return value == "1" || strings.EqualFold(value, "true")
+}
+
+func isFalse(value string) bool {
+	return value == "0" || strings.EqualFold(value, "false")
+}
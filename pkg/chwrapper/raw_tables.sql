@@ -27,9 +27,40 @@ CREATE TABLE IF NOT EXISTS raw_blocks (
     blob_gas_used UInt32,  -- Always 0 if no blob txs
     excess_blob_gas UInt64,  -- Always 0 if no blob txs
     parent_beacon_block_root LowCardinality(FixedString(32)),  -- Often all zeros
-    min_delay_excess UInt64
+    min_delay_excess UInt64,
+    proposer String  -- ProposerVM NodeID of the block producer, empty when unknown or not collected
 ) ENGINE = MergeTree()
 ORDER BY (chain_id, block_number);
+ALTER TABLE raw_blocks ADD COLUMN IF NOT EXISTS proposer String AFTER min_delay_excess;
+
+-- Uncles table - headers of the uncles (ommers) referenced by raw_blocks.uncles, only filled for chains with fetchUncles
+CREATE TABLE IF NOT EXISTS raw_uncles (
+    chain_id UInt32,
+    block_number UInt32,  -- Block that included the uncle
+    block_time DateTime64(3, 'UTC'),
+    uncle_index UInt8,  -- Position in the including block's uncles
+    hash FixedString(32),
+    uncle_number UInt32,  -- Height the uncle was mined at
+    uncle_time DateTime64(3, 'UTC'),
+    parent_hash FixedString(32),
+    miner FixedString(20),
+    difficulty UInt256,
+    gas_limit UInt32,
+    gas_used UInt32
+) ENGINE = MergeTree()
+ORDER BY (chain_id, block_number, uncle_index);
+
+-- Withdrawals table - EIP-4895 validator withdrawals of post-Shanghai blocks
+CREATE TABLE IF NOT EXISTS raw_withdrawals (
+    chain_id UInt32,
+    block_number UInt32,
+    block_time DateTime64(3, 'UTC'),
+    withdrawal_index UInt64,  -- Global withdrawal counter, unique per chain
+    validator_index UInt64,
+    address FixedString(20),  -- Recipient
+    amount UInt64  -- Gwei
+) ENGINE = MergeTree()
+ORDER BY (chain_id, block_number, withdrawal_index);
 
 -- Transactions table - merged with receipts for analytics performance
 CREATE TABLE IF NOT EXISTS raw_txs (
@@ -48,7 +79,7 @@ CREATE TABLE IF NOT EXISTS raw_txs (
     gas_used UInt32,  -- From receipt
     success Bool,  -- From receipt status
     input String,  -- Calldata
-    type UInt8,  -- 0,1,2,3 (legacy, EIP-2930, EIP-1559, EIP-4844)
+    type UInt8,  -- 0,1,2,3 (legacy, EIP-2930, EIP-1559, EIP-4844), 126 (0x7e, OP Stack deposit), 100-106 and 120 (Arbitrum)
     max_fee_per_gas Nullable(UInt64),  -- Only for EIP-1559
     max_priority_fee_per_gas Nullable(UInt64),  -- Only for EIP-1559
     priority_fee_per_gas Nullable(UInt64),  -- Computed: min(gas_price - base_fee, max_priority_fee)
@@ -57,9 +88,23 @@ CREATE TABLE IF NOT EXISTS raw_txs (
     access_list Array(Tuple(
         address FixedString(20),
         storage_keys Array(FixedString(32))
-    ))  -- Properly structured, not JSON
+    )),  -- Properly structured, not JSON
+    effective_gas_price UInt64,  -- From receipt, the price actually paid per gas
+    blob_versioned_hashes Array(FixedString(32)),  -- EIP-4844 only, empty otherwise
+    max_fee_per_blob_gas Nullable(UInt64),  -- EIP-4844 only
+    blob_gas_used UInt32,  -- From receipt, 0 for non-blob txs
+    blob_gas_price Nullable(UInt64),  -- From receipt, EIP-4844 only
+    source_hash Nullable(FixedString(32)),  -- OP Stack deposit txs (type 0x7e) only
+    mint Nullable(UInt256)  -- OP Stack deposit txs only, ETH minted on L2
 ) ENGINE = MergeTree()
 ORDER BY (chain_id, block_number);
+ALTER TABLE raw_txs ADD COLUMN IF NOT EXISTS effective_gas_price UInt64 AFTER access_list;
+ALTER TABLE raw_txs ADD COLUMN IF NOT EXISTS blob_versioned_hashes Array(FixedString(32)) AFTER effective_gas_price;
+ALTER TABLE raw_txs ADD COLUMN IF NOT EXISTS max_fee_per_blob_gas Nullable(UInt64) AFTER blob_versioned_hashes;
+ALTER TABLE raw_txs ADD COLUMN IF NOT EXISTS blob_gas_used UInt32 AFTER max_fee_per_blob_gas;
+ALTER TABLE raw_txs ADD COLUMN IF NOT EXISTS blob_gas_price Nullable(UInt64) AFTER blob_gas_used;
+ALTER TABLE raw_txs ADD COLUMN IF NOT EXISTS source_hash Nullable(FixedString(32)) AFTER blob_gas_price;
+ALTER TABLE raw_txs ADD COLUMN IF NOT EXISTS mint Nullable(UInt256) AFTER source_hash;
 
 -- Traces table - flattened trace calls
 CREATE TABLE IF NOT EXISTS raw_traces (
@@ -104,6 +149,112 @@ CREATE TABLE IF NOT EXISTS raw_logs (
 ) ENGINE = MergeTree()
 ORDER BY (chain_id, block_time, address, topic0);
 
+-- ERC-20 transfers - Transfer(address,address,uint256) logs decoded at ingest time
+CREATE TABLE IF NOT EXISTS erc20_transfers (
+    chain_id UInt32,
+    token FixedString(20),  -- Emitting contract
+    block_number UInt32,
+    block_time DateTime64(3, 'UTC'),
+    transaction_hash FixedString(32),
+    transaction_index UInt16,
+    log_index UInt32,
+    from FixedString(20),  -- Zero address for mints
+    to FixedString(20),  -- Zero address for burns
+    amount UInt256
+) ENGINE = MergeTree()
+ORDER BY (chain_id, token, block_time, log_index);
+
+-- NFT transfers - ERC-721 Transfer and ERC-1155 TransferSingle/TransferBatch logs decoded at ingest
+-- time, one row per token ID
+CREATE TABLE IF NOT EXISTS nft_transfers (
+    chain_id UInt32,
+    standard LowCardinality(String),  -- erc721 or erc1155
+    collection FixedString(20),  -- Emitting contract
+    block_number UInt32,
+    block_time DateTime64(3, 'UTC'),
+    transaction_hash FixedString(32),
+    transaction_index UInt16,
+    log_index UInt32,
+    batch_index UInt16,  -- Position in a TransferBatch, 0 otherwise
+    operator Nullable(FixedString(20)),  -- NULL for ERC-721
+    from FixedString(20),  -- Zero address for mints
+    to FixedString(20),  -- Zero address for burns
+    token_id UInt256,
+    amount UInt256  -- Always 1 for ERC-721
+) ENGINE = MergeTree()
+ORDER BY (chain_id, collection, block_time, log_index, batch_index);
+
+-- Contracts - deployed by successful CREATE/CREATE2 trace frames, or from receipts when traces are missing
+CREATE TABLE IF NOT EXISTS contracts (
+    chain_id UInt32,
+    address FixedString(20),
+    creator FixedString(20),  -- Account executing the CREATE: the deployer EOA, or the factory contract
+    tx_from FixedString(20),  -- Transaction sender (denormalized)
+    block_number UInt32,
+    block_time DateTime64(3, 'UTC'),
+    transaction_hash FixedString(32),
+    transaction_index UInt16,
+    trace_address Array(UInt16),  -- Position of the CREATE frame in the call tree, empty for top-level deployments
+    create_type LowCardinality(String),  -- CREATE or CREATE2
+    init_code_hash FixedString(32),  -- keccak256 of the init code
+    runtime_code_hash Nullable(FixedString(32))  -- keccak256 of the deployed code, NULL when traces have no output
+) ENGINE = MergeTree()
+ORDER BY (chain_id, address, block_number);
+
+-- ICM messages - Teleporter send/receive/execution events and Warp precompile messages, one row per
+-- event. A message's delivery status is its latest event across the source and destination chains
+CREATE TABLE IF NOT EXISTS icm_messages (
+    chain_id UInt32,  -- Chain the event was emitted on
+    event LowCardinality(String),  -- warp_sent, sent, received, executed or execution_failed
+    message_id FixedString(32),  -- Teleporter message ID, or the unsigned message ID for warp_sent
+    source_blockchain_id FixedString(32),  -- Zero when the event doesn't carry it
+    destination_blockchain_id FixedString(32),  -- Zero when the event doesn't carry it
+    message_nonce UInt256,
+    origin_sender FixedString(20),  -- Teleporter message sender, or the Warp message source address
+    destination_address FixedString(20),
+    deliverer Nullable(FixedString(20)),  -- Relayer, for received events
+    contract FixedString(20),  -- TeleporterMessenger or the Warp precompile
+    block_number UInt32,
+    block_time DateTime64(3, 'UTC'),
+    transaction_hash FixedString(32),
+    transaction_index UInt16,
+    log_index UInt32
+) ENGINE = MergeTree()
+ORDER BY (chain_id, block_time, message_id);
+
+-- Internal transactions - trace frames below the top-level call, flattened at ingest time
+CREATE TABLE IF NOT EXISTS internal_txs (
+    chain_id UInt32,
+    block_number UInt32,
+    block_time DateTime64(3, 'UTC'),
+    transaction_hash FixedString(32),
+    transaction_index UInt16,
+    trace_address Array(UInt16),  -- Path in call tree, never empty
+    type LowCardinality(String),  -- CALL, DELEGATECALL, STATICCALL, CREATE, CREATE2, etc.
+    from FixedString(20),
+    to FixedString(20),  -- Zero address for failed CREATEs
+    value UInt256,
+    gas UInt32,
+    gas_used UInt32,
+    error String,  -- Why the frame failed, empty if it succeeded
+    reverted Bool  -- The frame or one of its ancestors failed, so its value transfer didn't happen
+) ENGINE = MergeTree()
+ORDER BY (chain_id, block_number, transaction_index, trace_address);
+
+-- Remember the insert_deduplication_token of recent inserts into the EVM tables, so the syncer can
+-- retry a failed write without duplicating the rows that already landed
+ALTER TABLE raw_blocks MODIFY SETTING non_replicated_deduplication_window = 1000;
+ALTER TABLE raw_uncles MODIFY SETTING non_replicated_deduplication_window = 1000;
+ALTER TABLE raw_withdrawals MODIFY SETTING non_replicated_deduplication_window = 1000;
+ALTER TABLE raw_txs MODIFY SETTING non_replicated_deduplication_window = 1000;
+ALTER TABLE raw_traces MODIFY SETTING non_replicated_deduplication_window = 1000;
+ALTER TABLE raw_logs MODIFY SETTING non_replicated_deduplication_window = 1000;
+ALTER TABLE erc20_transfers MODIFY SETTING non_replicated_deduplication_window = 1000;
+ALTER TABLE nft_transfers MODIFY SETTING non_replicated_deduplication_window = 1000;
+ALTER TABLE contracts MODIFY SETTING non_replicated_deduplication_window = 1000;
+ALTER TABLE icm_messages MODIFY SETTING non_replicated_deduplication_window = 1000;
+ALTER TABLE internal_txs MODIFY SETTING non_replicated_deduplication_window = 1000;
+
 -- Watermark table - tracks guaranteed sync progress per chain
 CREATE TABLE IF NOT EXISTS sync_watermark (
     chain_id UInt32,
@@ -116,10 +267,79 @@ CREATE TABLE IF NOT EXISTS chain_status (
     chain_id UInt32,
     name String,
     last_updated DateTime64(3, 'UTC'),
-    last_block_on_chain UInt64
+    last_block_on_chain UInt64,
+    degraded_reason String  -- Why load shedding is active (e.g. memory over budget), empty when it isn't
 ) ENGINE = ReplacingMergeTree(last_updated)
 PRIMARY KEY chain_id;
 
+ALTER TABLE chain_status ADD COLUMN IF NOT EXISTS degraded_reason String AFTER last_block_on_chain;
+
+-- Deployment log - schema, indexer SQL and binary version changes seen at startup
+-- Lets analysts correlate metric shifts with deployments directly in the warehouse
+CREATE TABLE IF NOT EXISTS deployment_log (
+    event_time DateTime64(3, 'UTC'),
+    event_type LowCardinality(String),  -- 'binary', 'schema' or 'indexer_sql'
+    subject String,  -- Binary name or SQL file path
+    checksum String,  -- SHA-256 of the file, or version string for the binary
+    previous_checksum String,  -- Empty the first time a subject is seen
+    version String,  -- Binary version that recorded the event
+    hostname String
+) ENGINE = MergeTree()
+ORDER BY (event_time, event_type, subject);
+
+-- Chain control table - operator requests to running syncers (e.g. pause during resync)
+-- version identifies each request so syncers can acknowledge it in chain_control_acks
+CREATE TABLE IF NOT EXISTS chain_control (
+    chain_id UInt32,
+    paused Bool,
+    reason String,
+    version UInt64,
+    updated_at DateTime64(3, 'UTC')
+) ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY chain_id;
+
+-- Chain control acknowledgements - written by syncers once a request has taken effect. Every version
+-- keeps its row, so a later ack can't replace one a command is still waiting for
+CREATE TABLE IF NOT EXISTS chain_control_acks (
+    chain_id UInt32,
+    paused Bool,
+    version UInt64,
+    acked_at DateTime64(3, 'UTC')
+) ENGINE = ReplacingMergeTree(acked_at)
+ORDER BY (chain_id, version);
+
+-- Chain control acknowledgements of standalone indexers (the index command), which pause separately from the syncer
+CREATE TABLE IF NOT EXISTS indexer_control_acks (
+    chain_id UInt32,
+    paused Bool,
+    version UInt64,
+    acked_at DateTime64(3, 'UTC')
+) ENGINE = ReplacingMergeTree(acked_at)
+ORDER BY (chain_id, version);
+
+-- Replaced by the tables above, which keep the acks of every version
+DROP TABLE IF EXISTS chain_control_ack;
+DROP TABLE IF EXISTS indexer_control_ack;
+
+-- Dimension history - versions of mutable dimensions (registry metadata, subnet owners) with their validity ranges
+-- A version is valid for blocks [valid_from_block, valid_to_block) and times [valid_from_time, valid_to_time)
+-- so historical metrics can join the value "as of" their block or period instead of today's value.
+-- The current version ends at valid_to_block = 18446744073709551615 and valid_to_time = 2299-12-31.
+-- Closing a version re-inserts it with its end set, so query with FINAL.
+-- Off-chain dimensions (chain_id 0, e.g. l1_registry) have no block heights and are versioned by time only
+CREATE TABLE IF NOT EXISTS dimension_history (
+    chain_id UInt32,  -- Chain the block range refers to (P-chain ID for subnet dimensions, 0 for off-chain)
+    dimension LowCardinality(String),  -- 'l1_registry', 'subnet_owner'
+    dim_key String,  -- e.g. subnet ID
+    value String,  -- JSON attributes of this version
+    valid_from_block UInt64,
+    valid_to_block UInt64,
+    valid_from_time DateTime64(3, 'UTC'),
+    valid_to_time DateTime64(3, 'UTC'),
+    recorded_at DateTime64(3, 'UTC')
+) ENGINE = ReplacingMergeTree(recorded_at)
+ORDER BY (chain_id, dimension, dim_key, valid_from_block, valid_from_time);
+
 -- P-chain transactions table - simplified schema using ClickHouse JSON type
 CREATE TABLE IF NOT EXISTS p_chain_txs (
     -- Core indexed columns for efficient queries
@@ -128,6 +348,8 @@ CREATE TABLE IF NOT EXISTS p_chain_txs (
     block_number UInt64,
     block_time DateTime64(3, 'UTC'),
     p_chain_id UInt32,  -- Identifies which P-chain instance (e.g., mainnet vs testnet)
+    memo String,  -- Raw BaseTx memo bytes (empty for txs without a memo)
+    memo_text String,  -- Memo as text if it is valid UTF-8, empty otherwise
     
     -- Main JSON column storing the complete transaction data
     -- Type hints optimize storage and query performance for frequently accessed fields
@@ -161,13 +383,65 @@ CREATE TABLE IF NOT EXISTS p_chain_txs (
         -- VMID String,
         -- SourceChain String,
         -- DestinationChain String
-    )
+    ),
+
+    -- zstd-compressed JSON object of large tx_data fields (genesisData, validators) moved out at insert
+    -- time when txBlobMinSize is configured, empty otherwise. Readers of whole txs merge it back with txblob.Expand
+    tx_blobs String CODEC(NONE)
 ) ENGINE = ReplacingMergeTree(block_time)
 ORDER BY (p_chain_id, tx_id);
 -- Note: Using ReplacingMergeTree to deduplicate transactions that may be inserted multiple times
 -- during syncer restarts. ORDER BY tx_id ensures uniqueness per transaction.
 -- IMPORTANT: For existing tables, use FINAL or DISTINCT in queries to get deduplicated results.
 -- Migration note: If migrating from MergeTree, recreate table and re-sync data.
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS memo String AFTER p_chain_id;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS memo_text String AFTER memo;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS tx_blobs String CODEC(NONE) AFTER tx_data;
+-- Typed columns of the fields extracted from each tx, filled when the chain's txStorage is typed or both,
+-- NULL otherwise and for tx types without the field. IDs are stored as raw bytes, not CB58 strings
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS node_id Nullable(FixedString(20)) AFTER tx_blobs;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS start_time Nullable(UInt64) AFTER node_id;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS end_time Nullable(UInt64) AFTER start_time;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS weight Nullable(UInt64) AFTER end_time;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS subnet_id Nullable(FixedString(32)) AFTER weight;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS blockchain_id Nullable(FixedString(32)) AFTER subnet_id;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS chain_name Nullable(String) AFTER blockchain_id;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS vm_id Nullable(FixedString(32)) AFTER chain_name;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS source_chain Nullable(FixedString(32)) AFTER vm_id;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS destination_chain Nullable(FixedString(32)) AFTER source_chain;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS reward_tx_id Nullable(FixedString(32)) AFTER destination_chain;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS asset_id Nullable(FixedString(32)) AFTER reward_tx_id;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS delegation_shares Nullable(UInt32) AFTER asset_id;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS validation_id Nullable(FixedString(32)) AFTER delegation_shares;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS balance Nullable(UInt64) AFTER validation_id;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS manager_address Nullable(String) AFTER balance;
+ALTER TABLE p_chain_txs ADD COLUMN IF NOT EXISTS advance_time Nullable(UInt64) AFTER manager_address;
+
+-- P-Chain blocks table - one row per block for block-time/production analytics and gap detection
+CREATE TABLE IF NOT EXISTS p_chain_blocks (
+    block_id String,  -- CB58-encoded block ID
+    height UInt64,
+    parent_id String,
+    block_time DateTime64(3, 'UTC'),  -- Banff timestamp, or chain time for Apricot blocks
+    tx_count UInt32,
+    block_type LowCardinality(String),  -- e.g. "BanffStandard", "ApricotProposal"
+    proposer String,  -- NodeID of the block proposer, empty when unknown
+    p_chain_id UInt32
+) ENGINE = ReplacingMergeTree(block_time)
+ORDER BY (p_chain_id, height);
+
+-- P-Chain Memos table - index of transactions carrying a non-empty memo
+CREATE TABLE IF NOT EXISTS p_chain_memos (
+    tx_id String,
+    tx_type LowCardinality(String),
+    block_number UInt64,
+    block_time DateTime64(3, 'UTC'),
+    memo String,  -- Raw memo bytes
+    memo_text String,  -- Memo as text if it is valid UTF-8, empty otherwise
+    is_utf8 Bool,
+    p_chain_id UInt32
+) ENGINE = ReplacingMergeTree(block_time)
+ORDER BY (p_chain_id, tx_id);
 
 -- L1 Validator State table - tracks current state of L1 validators
 CREATE TABLE IF NOT EXISTS l1_validator_state (
@@ -352,3 +626,166 @@ CREATE TABLE IF NOT EXISTS l1_validator_refunds (
     p_chain_id UInt32
 ) ENGINE = ReplacingMergeTree(block_time)
 ORDER BY (p_chain_id, validation_id, tx_id);
+
+-- P-Chain Rewards table - reward UTXOs paid out by RewardValidator transactions
+CREATE TABLE IF NOT EXISTS p_chain_rewards (
+    -- Identifiers
+    tx_id String,  -- RewardValidator transaction ID
+    staker_tx_id String,  -- The staker tx being rewarded (AddValidator, AddDelegator, ...)
+    utxo_id String,  -- The reward UTXO ID (CB58)
+    output_index UInt32,
+
+    -- Reward details
+    asset_id String,  -- Asset of the reward (AVAX on the Primary Network)
+    amount UInt64,  -- Reward amount in the smallest denomination of asset_id
+    addresses Array(String),  -- Recipient addresses (CB58 short IDs)
+    threshold UInt32,
+    locktime UInt64,  -- Spend locktime of the secp256k1 output
+    stakeable_locktime UInt64,  -- Stakeable lock locktime (0 if not stakeable-locked)
+
+    -- Transaction details
+    block_number UInt64,
+    block_time DateTime64(3, 'UTC'),
+
+    -- Metadata
+    p_chain_id UInt32
+) ENGINE = ReplacingMergeTree(block_time)
+ORDER BY (p_chain_id, tx_id, utxo_id);
+
+-- P-Chain Reward Sync table - per RewardValidator tx backfill progress for p_chain_rewards
+CREATE TABLE IF NOT EXISTS p_chain_reward_sync (
+    tx_id String,  -- RewardValidator transaction ID
+    staker_tx_id String,
+    block_number UInt64,
+    status LowCardinality(String),  -- 'rewarded', 'not_rewarded' (no reward UTXOs) or 'failed'
+    attempts UInt32,  -- Number of failed fetch attempts
+    last_error String,
+
+    -- Metadata
+    p_chain_id UInt32,
+    updated_at DateTime64(3, 'UTC') DEFAULT now64(3)
+) ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY (p_chain_id, tx_id);
+
+-- L1 Validator Weight Divergences table - data-quality findings from comparing getCurrentValidators
+-- with the weights implied by ConvertSubnetToL1/RegisterL1Validator/SetL1ValidatorWeight txs
+-- Appended on every check, a divergence showing up in consecutive checks is persistent
+CREATE TABLE IF NOT EXISTS l1_validator_weight_divergences (
+    check_time DateTime64(3, 'UTC'),
+    subnet_id String,  -- The L1 subnet ID (CB58)
+    validation_id String,  -- Validation ID (CB58)
+    node_id String,  -- NodeID-... format
+    issue LowCardinality(String),  -- 'weight_mismatch', 'missing_live' or 'missing_history'
+    expected_weight UInt64,  -- Weight implied by the tx history (0 if missing_history)
+    live_weight UInt64,  -- Weight returned by getCurrentValidators (0 if missing_live)
+    p_chain_height UInt64,  -- Ingested P-Chain height the tx history was read at
+
+    -- Metadata
+    p_chain_id UInt32
+) ENGINE = MergeTree()
+ORDER BY (p_chain_id, subnet_id, check_time);
+
+-- Validator Set Snapshots table - validator sets reconstructed at historical heights via getValidatorsAt
+CREATE TABLE IF NOT EXISTS validator_set_snapshots (
+    subnet_id String,  -- Subnet ID (CB58), Primary Network included
+    height UInt64,  -- P-Chain height of the snapshot
+    snapshot_time DateTime64(3, 'UTC'),  -- Period boundary the snapshot represents
+    node_id String,  -- NodeID-... format
+    weight UInt64,
+    public_key String,  -- Compressed BLS public key (hex), empty if none
+
+    -- Metadata
+    p_chain_id UInt32
+) ENGINE = ReplacingMergeTree()
+ORDER BY (p_chain_id, subnet_id, height, node_id);
+
+-- Validator Set Snapshot Heights table - one row per captured (subnet, height), also marks backfill progress
+CREATE TABLE IF NOT EXISTS validator_set_snapshot_heights (
+    subnet_id String,
+    height UInt64,
+    snapshot_time DateTime64(3, 'UTC'),
+    validator_count UInt32,
+    total_weight UInt64,
+
+    -- Metadata
+    p_chain_id UInt32,
+    inserted_at DateTime64(3, 'UTC') DEFAULT now64(3)
+) ENGINE = ReplacingMergeTree(inserted_at)
+ORDER BY (p_chain_id, subnet_id, height);
+
+-- HyperSDK blocks table - one row per block of a hypersdk-based L1 (vm: hypersdk)
+CREATE TABLE IF NOT EXISTS hypersdk_blocks (
+    chain_id UInt32,
+    block_number UInt64,
+    block_id String,  -- CB58 hash of the block bytes
+    parent_id String,
+    block_time DateTime64(3, 'UTC'),
+    state_root String,
+    unit_prices String,  -- JSON array of fee dimension prices (bandwidth, compute, storage reads, allocates, writes)
+    tx_count UInt32
+) ENGINE = ReplacingMergeTree(block_time)
+ORDER BY (chain_id, block_number);
+
+-- HyperSDK actions table - one row per tx action with the tx result
+-- VMs whose actions don't marshal to JSON get one 'packed' row per tx holding the packed action bytes
+CREATE TABLE IF NOT EXISTS hypersdk_actions (
+    chain_id UInt32,
+    block_number UInt64,
+    block_time DateTime64(3, 'UTC'),
+    tx_id String,
+    tx_index UInt32,
+    action_index UInt16,
+    action_type LowCardinality(String),  -- Type declared in the action JSON, empty if none
+    action_data String,  -- Action JSON, or 0x-prefixed packed action bytes
+    output String,  -- 0x-prefixed action output, empty when the tx failed
+    success Bool,  -- Tx-level, shared by all its actions
+    error String,  -- Tx error message, empty on success
+    fee UInt64,  -- Tx fee
+    units String  -- JSON array of fee dimensions consumed by the tx
+) ENGINE = ReplacingMergeTree(block_time)
+ORDER BY (chain_id, block_number, tx_index, action_index);
+
+-- Raw payloads table - the block payload exactly as the RPC cache stores it, so "cache hydrate" can
+-- rebuild a cache without RPC. Only written for chains with storePayloads, the P-chain uses chain_id 0
+CREATE TABLE IF NOT EXISTS raw_payloads (
+    chain_id UInt32,
+    block_number UInt32,
+    data String CODEC(ZSTD(3)),  -- JSON-encoded EVM block with receipts and traces, or P-chain block bytes
+    inserted_at DateTime DEFAULT now()
+) ENGINE = ReplacingMergeTree(inserted_at)
+ORDER BY (chain_id, block_number);
+
+-- Head blocks table - blocks above the followed block of chains with followDistance or followTag, which
+-- can still be reorged. Only written for chains with headTable. A reorged height is replaced by the
+-- newer row, and rows expire once the raw tables have long caught up
+CREATE TABLE IF NOT EXISTS raw_head_blocks (
+    chain_id UInt32,
+    block_number UInt32,
+    hash FixedString(32),
+    parent_hash FixedString(32),
+    block_time DateTime64(3, 'UTC'),
+    miner FixedString(20),
+    gas_used UInt32,
+    transaction_count UInt32,
+    inserted_at DateTime DEFAULT now()
+) ENGINE = ReplacingMergeTree(inserted_at)
+ORDER BY (chain_id, block_number)
+TTL inserted_at + INTERVAL 1 DAY;
+
+-- Webhook cursors - highest P-Chain block whose transactions each txTypes webhook rule has been checked for
+CREATE TABLE IF NOT EXISTS webhook_cursors (
+    rule String,
+    block_number UInt64,
+    updated_at DateTime64(3, 'UTC') DEFAULT now64(3)
+) ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY rule;
+
+-- Export watermarks - last block (or metrics computed_at in ms) of each table and chain written to a data-lake destination
+CREATE TABLE IF NOT EXISTS export_watermarks (
+    destination String,  -- Export URL prefix
+    table_name LowCardinality(String),
+    chain_id UInt32,
+    position UInt64,
+    updated_at DateTime64(3, 'UTC') DEFAULT now64(3)
+) ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY (destination, table_name, chain_id);
@@ -0,0 +1,97 @@
+package chwrapper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// retentionTimeColumn is the column row age is measured by, tables without it can't have retention
+const retentionTimeColumn = "block_time"
+
+// gapCheckedTables are checked for missing blocks below the watermark, expiring their rows would
+// have the gap check ingest them again
+var gapCheckedTables = map[string]bool{"raw_blocks": true, "p_chain_blocks": true}
+
+// retention maps tables to the days their rows are kept, 0 keeping them forever
+var retention map[string]int
+
+// ParseRetention parses comma-separated table=days pairs, e.g. "raw_traces=90,raw_logs=365".
+// 0 days keeps a table's rows forever, removing an earlier retention.
+func ParseRetention(s string) (map[string]int, error) {
+	tables := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		table, value, ok := strings.Cut(pair, "=")
+		table = strings.TrimSpace(table)
+		if !ok || !identifierPattern.MatchString(table) {
+			return nil, fmt.Errorf("invalid retention %q, expected table=days", pair)
+		}
+		days, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(value), "d"))
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("invalid retention days of %s: %q", table, value)
+		}
+		if gapCheckedTables[table] && days > 0 {
+			return nil, fmt.Errorf("%s can't expire rows, the gap check would ingest them again", table)
+		}
+		tables[table] = days
+	}
+	return tables, nil
+}
+
+// SetRetention sets the retention CreateTables applies, see ParseRetention
+func SetRetention(tables map[string]int) {
+	retention = tables
+}
+
+// applyRetention sets the TTL of each table with a retention, skipping tables whose TTL already
+// matches since every change rewrites the table's parts
+func applyRetention(conn driver.Conn) error {
+	tables := make([]string, 0, len(retention))
+	for table := range retention {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	ctx := Idempotent(context.Background())
+	for _, table := range tables {
+		var engineFull string
+		var hasTimeColumn uint64
+		err := conn.QueryRow(ctx, `
+			SELECT engine_full, (SELECT count() FROM system.columns WHERE database = currentDatabase() AND table = ? AND name = ?)
+			FROM system.tables WHERE database = currentDatabase() AND name = ?`,
+			table, retentionTimeColumn, table).Scan(&engineFull, &hasTimeColumn)
+		if err != nil {
+			return fmt.Errorf("failed to look up retention table %s: %w", table, err)
+		}
+		if hasTimeColumn == 0 {
+			return fmt.Errorf("retention table %s has no %s column", table, retentionTimeColumn)
+		}
+
+		// Written as ClickHouse shows it in engine_full, so an applied TTL is recognized
+		var query string
+		if days := retention[table]; days > 0 {
+			ttl := fmt.Sprintf("TTL toDateTime(%s) + toIntervalDay(%d)", retentionTimeColumn, days)
+			if strings.Contains(engineFull, ttl) {
+				continue
+			}
+			query = fmt.Sprintf("ALTER TABLE %s MODIFY %s", table, ttl)
+		} else {
+			if !strings.Contains(engineFull, " TTL ") {
+				continue
+			}
+			query = fmt.Sprintf("ALTER TABLE %s REMOVE TTL", table)
+		}
+		if err := conn.Exec(ctx, ClusterDDL(query)); err != nil {
+			return fmt.Errorf("failed to set retention of %s: %w", table, err)
+		}
+	}
+	return nil
+}
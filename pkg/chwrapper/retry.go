@@ -0,0 +1,238 @@
+package chwrapper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+const (
+	// QueryBackoff is the wait before the first retry of a query, doubled on every further retry
+	QueryBackoff = 500 * time.Millisecond
+	// QueryMaxBackoff caps the wait between retries of a query
+	QueryMaxBackoff = 30 * time.Second
+)
+
+var (
+	queryTimeout time.Duration
+	queryRetries = 3
+)
+
+// ConfigureQueries sets how long a query may run and how often one failing with a transient error
+// is retried. A timeout of 0 lets queries run until their context ends, 0 retries disables retrying.
+func ConfigureQueries(timeout time.Duration, retries int) {
+	queryTimeout = timeout
+	queryRetries = retries
+}
+
+// transientCodes are the ClickHouse errors a query may succeed after when retried
+var transientCodes = map[int32]bool{
+	159: true, // TIMEOUT_EXCEEDED
+	202: true, // TOO_MANY_SIMULTANEOUS_QUERIES
+	203: true, // NO_FREE_CONNECTION
+	209: true, // SOCKET_TIMEOUT
+	210: true, // NETWORK_ERROR
+	242: true, // TABLE_IS_READ_ONLY, a replica lost its ZooKeeper session
+	252: true, // TOO_MANY_PARTS
+	999: true, // KEEPER_EXCEPTION
+}
+
+// IsTransient reports whether err is a ClickHouse or network error that a retry may not run into
+func IsTransient(err error) bool {
+	var exception *clickhouse.Exception
+	if errors.As(err, &exception) {
+		return transientCodes[exception.Code]
+	}
+	var netErr net.Error
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) ||
+		(errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// Retry calls fn until it succeeds, fails with an error that isn't transient, ctx ends or the
+// configured retries are used up, waiting a jittered, doubling backoff between calls. Each call gets
+// a context limited to the configured query timeout, whose expiry counts as transient.
+func Retry(ctx context.Context, fn func(ctx context.Context) error) error {
+	cancel, err := retry(ctx, true, fn)
+	cancel()
+	return err
+}
+
+// retry is Retry returning the cancel func of the context of the successful call instead of
+// calling it, for results read after fn returns. Without timeout, calls get no query timeout.
+func retry(ctx context.Context, timeout bool, fn func(ctx context.Context) error) (context.CancelFunc, error) {
+	backoff := QueryBackoff
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		if timeout && queryTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, queryTimeout)
+		}
+		err := fn(attemptCtx)
+		if err == nil {
+			return cancel, nil
+		}
+		timedOut := errors.Is(attemptCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+		cancel()
+		if attempt >= queryRetries || ctx.Err() != nil || !(timedOut || IsTransient(err)) {
+			return func() {}, err
+		}
+
+		// Jitter spreads the retries of indexers that failed together
+		wait := rand.N(backoff) + backoff/2
+		slog.Warn("Retrying ClickHouse query after transient error", "attempt", attempt+1, "wait", wait, "error", err)
+		select {
+		case <-ctx.Done():
+			return func() {}, err
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, QueryMaxBackoff)
+	}
+}
+
+type idempotentKey struct{}
+
+// Idempotent marks the statements run with ctx as safe to run twice, so Exec and QueryRow retry
+// them like reads. Mark writes whose second run can't change the result, e.g. DDL with IF NOT
+// EXISTS or an upsert into a ReplacingMergeTree, never INSERT ... SELECT or ASYNC commands.
+func Idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// readOnlyKeywords start the statements that only read
+var readOnlyKeywords = map[string]bool{
+	"SELECT":   true,
+	"WITH":     true,
+	"SHOW":     true,
+	"DESCRIBE": true,
+	"DESC":     true,
+	"EXISTS":   true,
+	"EXPLAIN":  true,
+}
+
+// retryable reports whether query may be retried: it only reads, or ctx is marked Idempotent
+func retryable(ctx context.Context, query string) bool {
+	if idempotent, _ := ctx.Value(idempotentKey{}).(bool); idempotent {
+		return true
+	}
+	fields := strings.Fields(strings.TrimLeft(strings.TrimSpace(query), "("))
+	return len(fields) > 0 && readOnlyKeywords[strings.ToUpper(fields[0])]
+}
+
+// retryConn retries the queries of a connection with Retry. Writes are passed through unchanged
+// unless their context is marked Idempotent, a retry could apply them twice. Only starting a query
+// is retried: errors reading its rows or sending a batch are returned to the caller. The query
+// timeout covers reading the rows of a query, but not batches, which the writers retry and time
+// out themselves. Sent batches are recorded in the insert metrics.
+type retryConn struct {
+	driver.Conn
+}
+
+func (c retryConn) Exec(ctx context.Context, query string, args ...any) error {
+	if !retryable(ctx, query) {
+		return c.Conn.Exec(ctx, query, args...)
+	}
+	return Retry(ctx, func(ctx context.Context) error {
+		return c.Conn.Exec(ctx, query, args...)
+	})
+}
+
+func (c retryConn) Select(ctx context.Context, dest any, query string, args ...any) error {
+	return Retry(ctx, func(ctx context.Context) error {
+		return c.Conn.Select(ctx, dest, query, args...)
+	})
+}
+
+func (c retryConn) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	if !retryable(ctx, query) {
+		return c.Conn.Query(ctx, query, args...)
+	}
+	var rows driver.Rows
+	cancel, err := retry(ctx, true, func(attemptCtx context.Context) error {
+		var err error
+		rows, err = c.Conn.Query(attemptCtx, query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cancelRows{Rows: rows, cancel: cancel}, nil
+}
+
+func (c retryConn) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	if !retryable(ctx, query) {
+		return c.Conn.QueryRow(ctx, query, args...)
+	}
+	var row driver.Row
+	cancel, _ := retry(ctx, true, func(attemptCtx context.Context) error {
+		row = c.Conn.QueryRow(attemptCtx, query, args...)
+		return row.Err()
+	})
+	return cancelRow{Row: row, cancel: cancel}
+}
+
+func (c retryConn) PrepareBatch(ctx context.Context, query string, opts ...driver.PrepareBatchOption) (driver.Batch, error) {
+	var batch driver.Batch
+	bytes := new(atomic.Uint64)
+	cancel, err := retry(withInsertProgress(ctx, bytes), false, func(attemptCtx context.Context) error {
+		var err error
+		batch, err = c.Conn.PrepareBatch(attemptCtx, query, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return meteredBatch{Batch: cancelBatch{Batch: batch, cancel: cancel}, table: insertTable(query), bytes: bytes}, nil
+}
+
+// cancelRows releases the context of a query once its rows are closed
+type cancelRows struct {
+	driver.Rows
+	cancel context.CancelFunc
+}
+
+func (r cancelRows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+// cancelRow releases the context of a query once its row is scanned
+type cancelRow struct {
+	driver.Row
+	cancel context.CancelFunc
+}
+
+func (r cancelRow) Scan(dest ...any) error {
+	defer r.cancel()
+	return r.Row.Scan(dest...)
+}
+
+func (r cancelRow) ScanStruct(dest any) error {
+	defer r.cancel()
+	return r.Row.ScanStruct(dest)
+}
+
+// cancelBatch releases the context of a batch once it is sent or aborted
+type cancelBatch struct {
+	driver.Batch
+	cancel context.CancelFunc
+}
+
+func (b cancelBatch) Send() error {
+	defer b.cancel()
+	return b.Batch.Send()
+}
+
+func (b cancelBatch) Abort() error {
+	defer b.cancel()
+	return b.Batch.Abort()
+}
@@ -0,0 +1,141 @@
+package chwrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/stretchr/testify/require"
+)
+
+// timeoutError is a net.Error that timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"network error exception", &clickhouse.Exception{Code: 210}, true},
+		{"too many parts", fmt.Errorf("insert failed: %w", &clickhouse.Exception{Code: 252}), true},
+		{"unknown table", &clickhouse.Exception{Code: 60}, false},
+		{"eof", io.EOF, true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"timeout", timeoutError{}, true},
+		{"canceled", context.Canceled, false},
+		{"other", errors.New("syntax error"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.transient, IsTransient(tt.err))
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		query      string
+		idempotent bool
+		retryable  bool
+	}{
+		{"SELECT 1", false, true},
+		{"  select count() FROM raw_blocks", false, true},
+		{"WITH x AS (SELECT 1) SELECT * FROM x", false, true},
+		{"(SELECT 1) UNION ALL (SELECT 2)", false, true},
+		{"SHOW TABLES", false, true},
+		{"EXISTS TABLE raw_blocks", false, true},
+		{"INSERT INTO raw_blocks SELECT * FROM raw_blocks_tmp", false, false},
+		{"ALTER TABLE raw_blocks DELETE WHERE chain_id = 1", false, false},
+		{"CREATE TABLE IF NOT EXISTS t (x UInt8) ENGINE = Memory", false, false},
+		{"CREATE TABLE IF NOT EXISTS t (x UInt8) ENGINE = Memory", true, true},
+		{"SELECTED", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.idempotent {
+			ctx = Idempotent(ctx)
+		}
+		require.Equal(t, tt.retryable, retryable(ctx, tt.query), tt.query)
+	}
+}
+
+// failingConn fails its first Exec and Select calls with err
+type failingConn struct {
+	driver.Conn
+	err   error
+	fails int
+	calls int
+}
+
+func (c *failingConn) call() error {
+	c.calls++
+	if c.calls <= c.fails {
+		return c.err
+	}
+	return nil
+}
+
+func (c *failingConn) Exec(ctx context.Context, query string, args ...any) error { return c.call() }
+
+func (c *failingConn) Select(ctx context.Context, dest any, query string, args ...any) error {
+	return c.call()
+}
+
+func TestRetryConn(t *testing.T) {
+	ConfigureQueries(0, 1)
+	t.Cleanup(func() { ConfigureQueries(0, 3) })
+
+	transient := &clickhouse.Exception{Code: 209}
+	tests := []struct {
+		name       string
+		query      string
+		idempotent bool
+		selects    bool
+		err        error
+		fails      int
+		calls      int
+		ok         bool
+	}{
+		{name: "read retried", query: "SELECT 1", err: transient, fails: 1, calls: 2, ok: true},
+		{name: "retries used up", query: "SELECT 1", err: transient, fails: 2, calls: 2},
+		{name: "permanent error not retried", query: "SELECT 1", err: &clickhouse.Exception{Code: 62}, fails: 1, calls: 1},
+		{name: "write passed through", query: "INSERT INTO t SELECT 1", err: transient, fails: 1, calls: 1},
+		{name: "idempotent write retried", query: "INSERT INTO t VALUES (1)", idempotent: true, err: transient, fails: 1, calls: 2, ok: true},
+		{name: "select always retried", query: "INSERT INTO t SELECT 1", selects: true, err: transient, fails: 1, calls: 2, ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &failingConn{err: tt.err, fails: tt.fails}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if tt.idempotent {
+				ctx = Idempotent(ctx)
+			}
+
+			var err error
+			if tt.selects {
+				err = retryConn{conn}.Select(ctx, nil, tt.query)
+			} else {
+				err = retryConn{conn}.Exec(ctx, tt.query)
+			}
+			require.Equal(t, tt.calls, conn.calls)
+			if tt.ok {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package chwrapper
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"golang.org/x/sync/singleflight"
+)
+
+// Default tip cache freshness
+const (
+	DefaultTipCacheTTL      = 2 * time.Second
+	DefaultTipCacheMaxStale = 30 * time.Second
+)
+
+// TipCache serves chain tips and lag for status reads with stale-while-revalidate caching.
+// Tips younger than the TTL are served as is. Older ones, up to MaxStale, are served while a
+// background refresh runs, so frequent polling costs at most one query per TTL. Beyond MaxStale,
+// or before the first load, reads wait for a refresh.
+type TipCache struct {
+	conn     driver.Conn
+	ttl      time.Duration
+	maxStale time.Duration
+	logger   *slog.Logger
+
+	mu        sync.Mutex
+	tips      []ChainTip
+	fetchedAt time.Time
+	loads     singleflight.Group
+}
+
+// NewTipCache creates a tip cache. Zero durations use the defaults.
+func NewTipCache(conn driver.Conn, ttl, maxStale time.Duration) *TipCache {
+	if ttl == 0 {
+		ttl = DefaultTipCacheTTL
+	}
+	if maxStale < ttl {
+		maxStale = max(DefaultTipCacheMaxStale, ttl)
+	}
+	return &TipCache{
+		conn:     conn,
+		ttl:      ttl,
+		maxStale: maxStale,
+		logger:   slog.With("component", "tipcache"),
+	}
+}
+
+// Get returns every chain's tip, refreshing in the background when the cached tips are stale
+func (c *TipCache) Get() ([]ChainTip, error) {
+	c.mu.Lock()
+	tips, age := c.tips, time.Since(c.fetchedAt)
+	loaded := !c.fetchedAt.IsZero()
+	c.mu.Unlock()
+
+	switch {
+	case loaded && age < c.ttl:
+		return tips, nil
+	case loaded && age < c.maxStale:
+		go func() {
+			if _, err := c.refresh(); err != nil {
+				c.logger.Warn("Background tip refresh failed, serving stale tips", "age", age, "error", err)
+			}
+		}()
+		return tips, nil
+	default:
+		return c.refresh()
+	}
+}
+
+// refresh reloads the tips, sharing one query between concurrent callers
+func (c *TipCache) refresh() ([]ChainTip, error) {
+	tips, err, _ := c.loads.Do("tips", func() (any, error) {
+		tips, err := GetChainTips(c.conn)
+		if err != nil {
+			return nil, err
+		}
+
+		c.mu.Lock()
+		c.tips, c.fetchedAt = tips, time.Now()
+		c.mu.Unlock()
+		return tips, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tips.([]ChainTip), nil
+}
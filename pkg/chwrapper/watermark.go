@@ -25,7 +25,7 @@ func GetWatermark(conn driver.Conn, chainId uint32) (uint32, error) {
 
 // SetWatermark updates the watermark to the given block number for a specific chain
 func SetWatermark(conn driver.Conn, chainId uint32, blockNumber uint32) error {
-	ctx := context.Background()
+	ctx := Idempotent(context.Background())
 
 	query := "INSERT INTO sync_watermark (chain_id, block_number) VALUES (?, ?)"
 
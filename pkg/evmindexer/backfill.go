@@ -0,0 +1,102 @@
+package evmindexer
+
+import (
+	"context"
+	"fmt"
+	"icicle/pkg/logging"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// BackfillMetric reruns a granular metric (a file returned by ResolveIndexer) over the complete
+// periods of granularity in loc from the one containing from to the one containing to. Its rows in the
+// window are deleted first, so periods the metric no longer produces disappear. The watermark
+// moves up to the last period when the window reaches past it without leaving a gap, and is
+// otherwise left alone. Its queries run with the chain's ClickHouse settings chainSettings under
+// the metric's own. Returns the number of periods computed. The chain's indexers must be paused
+// while this runs.
+func BackfillMetric(conn driver.Conn, chainId uint32, feeAsset, file, granularity string, from, to time.Time, loc *time.Location, chainSettings map[string]string) (int, error) {
+	if !IsGranular(file) {
+		return 0, fmt.Errorf("%s is an incremental indexer, whose batches can't be recomputed over a range; resync the chain instead", file)
+	}
+	if err := CheckGranularity(granularity); err != nil {
+		return 0, err
+	}
+	settings, err := readSettings(sqlFiles, file+".sql")
+	if err != nil {
+		return 0, err
+	}
+	if !slices.Contains(settings.Granularities, granularity) {
+		return 0, fmt.Errorf("%s is not computed for %s periods, only %s", file, granularity, strings.Join(settings.Granularities, ", "))
+	}
+
+	// Only complete periods are computed, like the indexer loop does
+	var latestBlockTime time.Time
+	query := "SELECT max(block_time) FROM raw_blocks WHERE chain_id = ?"
+	if err := conn.QueryRow(context.Background(), query, chainId).Scan(&latestBlockTime); err != nil {
+		return 0, fmt.Errorf("failed to query latest block time: %w", err)
+	}
+	var periods []time.Time
+	last := toStartOfPeriod(to, granularity, loc)
+	for p := toStartOfPeriod(from, granularity, loc); !p.After(last) && isPeriodComplete(p, latestBlockTime, granularity, loc); p = nextPeriod(p, granularity, loc) {
+		periods = append(periods, p)
+	}
+	if len(periods) == 0 {
+		return 0, fmt.Errorf("no complete %s periods between %s and %s, chain data ends at %s",
+			granularity, from.Format(time.DateOnly), to.Format(time.DateOnly), latestBlockTime.Format(time.DateTime))
+	}
+
+	logger := logging.Chain("evmindexer", chainId, "")
+	templateParams, bindParams := granularParams(chainId, feeAsset, granularity, periods, loc)
+
+	// Wait for the delete to finish so the rerun's rows aren't deleted with the old ones
+	ctx := clickhouse.Context(context.Background(), clickhouse.WithSettings(clickhouse.Settings{
+		"mutations_sync": 2,
+	}))
+	query = "ALTER TABLE metrics DELETE WHERE chain_id = ? AND metric_name = ? AND granularity = ? AND period >= ? AND period < ?"
+	if err := conn.Exec(ctx, query, chainId, path.Base(file), granularity, bindParams["first_period"], bindParams["last_period"]); err != nil {
+		return 0, fmt.Errorf("failed to delete %s metrics: %w", file, err)
+	}
+
+	logger.Info("Backfilling metric", "indexer", file, "granularity", granularity,
+		"from", periods[0], "to", periods[len(periods)-1], "periods", len(periods))
+	if err := executeSQLFile(conn, sqlFiles, file+".sql", templateParams, bindParams, querySettings(chainSettings, settings), nil); err != nil {
+		return 0, fmt.Errorf("failed to run metric: %w", err)
+	}
+
+	return len(periods), reconcileWatermark(conn, chainId, file, granularity, periods[0], periods[len(periods)-1], loc)
+}
+
+// reconcileWatermark moves the watermark of a granular metric to last if the backfilled periods
+// first-last continue it
+func reconcileWatermark(conn driver.Conn, chainId uint32, indexerName, granularity string, first, last time.Time, loc *time.Location) error {
+	// The epoch when the metric never ran, like the indexer loop starts from
+	var lastPeriod time.Time
+	query := `
+	SELECT max(last_period)
+	FROM indexer_watermarks FINAL
+	WHERE chain_id = ? AND indexer_name = ? AND granularity = ?`
+	if err := conn.QueryRow(context.Background(), query, chainId, indexerName, granularity).Scan(&lastPeriod); err != nil {
+		return fmt.Errorf("failed to query watermark: %w", err)
+	}
+
+	logger := logging.Chain("evmindexer", chainId, "")
+	if !last.After(lastPeriod) || first.After(nextPeriod(lastPeriod, granularity, loc)) {
+		logger.Info("Watermark unchanged", "indexer", indexerName, "granularity", granularity, "last_period", lastPeriod)
+		return nil
+	}
+
+	query = `
+	INSERT INTO indexer_watermarks (chain_id, indexer_name, granularity, last_period, last_block_num)
+	VALUES (?, ?, ?, ?, ?)`
+	if err := conn.Exec(context.Background(), query, chainId, indexerName, granularity, last, uint64(0)); err != nil {
+		return fmt.Errorf("failed to save watermark: %w", err)
+	}
+	logger.Info("Advanced watermark", "indexer", indexerName, "granularity", granularity, "from", lastPeriod, "to", last)
+	return nil
+}
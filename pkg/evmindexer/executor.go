@@ -2,54 +2,177 @@ package evmindexer
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"regexp"
 	"strings"
+	"text/template"
+
+	"icicle/pkg/chwrapper"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 )
 
-// executeSQLFile reads and executes a SQL file with parameter substitution and binding
-func executeSQLFile(conn driver.Conn, sqlDir string, filename string, templateParams []struct{ key, value string }, bindParams map[string]interface{}) error {
-	sqlPath := filepath.Join(sqlDir, filename)
-	sqlBytes, err := os.ReadFile(sqlPath)
+// executeSQLFile reads and executes a SQL file with parameter substitution and binding under the
+// given ClickHouse settings, adding the rows it reads and writes to stats unless it is nil
+func executeSQLFile(conn driver.Conn, sqlFS fs.FS, filename string, templateParams []struct{ key, value string }, bindParams map[string]interface{}, settings clickhouse.Settings, stats *runStats) error {
+	statements, err := renderSQLFile(sqlFS, filename, templateParams)
 	if err != nil {
-		return fmt.Errorf("failed to read SQL file %s: %w", filename, err)
+		return err
 	}
+	named := namedParams(bindParams)
 
-	// Split by semicolon and execute each statement
-	statements := splitSQL(string(sqlBytes))
+	ctx := context.Background()
+	if settings != nil {
+		ctx = clickhouse.Context(ctx, clickhouse.WithSettings(settings))
+	}
+	if stats != nil {
+		ctx = clickhouse.Context(ctx, clickhouse.WithProgress(stats.add))
+	}
 
-	for _, stmt := range statements {
+	for _, sql := range statements {
+		// Execute statement with parameter binding
+		if err := conn.Exec(ctx, chwrapper.ClusterDDL(sql), named...); err != nil {
+			// Check if it's a CREATE TABLE that already exists (not an error)
+			if !strings.Contains(err.Error(), "already exists") {
+				return fmt.Errorf("failed to execute SQL: %w\nStatement: %s", err, sql)
+			}
+		}
+	}
+
+	return nil
+}
+
+// commonDir holds the SQL files indexers include and the macros they use
+const commonDir = "common"
+
+// placeholderPattern matches template placeholders such as {granularity}, but not ClickHouse
+// query parameters such as {chain_id:UInt32}
+var placeholderPattern = regexp.MustCompile(`\{[A-Za-z_][A-Za-z0-9_]*\}`)
+
+// renderSQLFile reads a SQL file and returns its statements with includes and macros expanded and
+// template placeholders replaced, failing if any placeholder is left
+func renderSQLFile(sqlFS fs.FS, filename string, templateParams []struct{ key, value string }) ([]string, error) {
+	sqlBytes, err := fs.ReadFile(sqlFS, filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SQL file %s: %w", filename, err)
+	}
+	content, err := expandSQL(sqlFS, filename, string(sqlBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	// Split by semicolon
+	var statements []string
+	for _, stmt := range splitSQL(content) {
 		// Skip empty statements
 		if strings.TrimSpace(stmt) == "" {
 			continue
 		}
 
 		// Replace template placeholders (things like {granularity})
-		sql := stmt
 		for _, param := range templateParams {
-			sql = strings.ReplaceAll(sql, param.key, param.value)
+			stmt = strings.ReplaceAll(stmt, param.key, param.value)
+		}
+		if placeholder := placeholderPattern.FindString(stmt); placeholder != "" {
+			return nil, fmt.Errorf("%s: unknown placeholder %s", filename, placeholder)
 		}
+		statements = append(statements, stmt)
+	}
+
+	return statements, nil
+}
+
+// expandSQL expands the template actions of a SQL file: {{ include "common/file.sql" }} inserts a
+// file of commonDir, and the macros defined there with {{ define "name" }} are expanded with
+// {{ template "name" }}. Both take an optional argument, available as {{ . }}.
+func expandSQL(sqlFS fs.FS, filename, content string) (string, error) {
+	if !strings.Contains(content, "{{") {
+		return content, nil
+	}
 
-		// Convert bindParams map to clickhouse.Named parameters
-		var namedParams []interface{}
-		for key, value := range bindParams {
-			namedParams = append(namedParams, clickhouse.Named(key, value))
+	tmpl := template.New(filename).Option("missingkey=error")
+	tmpl.Funcs(template.FuncMap{
+		"include": func(path string, arg ...interface{}) (string, error) {
+			included := tmpl.Lookup(path)
+			if included == nil || path == filename {
+				return "", fmt.Errorf("unknown include %s, included files must be in %s/", path, commonDir)
+			}
+			var data interface{}
+			if len(arg) > 0 {
+				data = arg[0]
+			}
+			var b strings.Builder
+			if err := included.Execute(&b, data); err != nil {
+				return "", err
+			}
+			return b.String(), nil
+		},
+	})
+
+	common, err := discoverSQLFiles(sqlFS, commonDir)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range common {
+		path := commonDir + "/" + name + ".sql"
+		text, err := fs.ReadFile(sqlFS, path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read SQL file %s: %w", path, err)
+		}
+		if _, err := tmpl.New(path).Parse(string(text)); err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", path, err)
 		}
+	}
+	if _, err := tmpl.Parse(content); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
 
-		// Execute statement with parameter binding
-		if err := conn.Exec(context.Background(), sql, namedParams...); err != nil {
-			// Check if it's a CREATE TABLE that already exists (not an error)
-			if !strings.Contains(err.Error(), "already exists") {
-				return fmt.Errorf("failed to execute SQL: %w\nStatement: %s", err, sql)
+	var b strings.Builder
+	if err := tmpl.Execute(&b, nil); err != nil {
+		return "", fmt.Errorf("failed to expand %s: %w", filename, err)
+	}
+	return b.String(), nil
+}
+
+// namedParams converts bind parameters to clickhouse.Named parameters
+func namedParams(bindParams map[string]interface{}) []interface{} {
+	var named []interface{}
+	for key, value := range bindParams {
+		named = append(named, clickhouse.Named(key, value))
+	}
+	return named
+}
+
+// DeploymentItems returns deployment items for the indexer schema and every indexer SQL file
+func DeploymentItems() ([]chwrapper.DeploymentItem, error) {
+	items := []chwrapper.DeploymentItem{
+		{EventType: chwrapper.DeploymentSchema, Subject: "indexer_tables.sql", Checksum: chwrapper.Checksum([]byte(indexerTablesSQL))},
+	}
+
+	for _, dir := range []string{"evm_metrics", "evm_incremental", commonDir} {
+		files, err := discoverSQLFiles(sqlFiles, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range files {
+			subject := fmt.Sprintf("%s/%s.sql", dir, name)
+			content, err := fs.ReadFile(sqlFiles, subject)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read SQL file %s: %w", subject, err)
 			}
+			items = append(items, chwrapper.DeploymentItem{
+				EventType: chwrapper.DeploymentIndexerSQL,
+				Subject:   subject,
+				Checksum:  chwrapper.Checksum(content),
+			})
 		}
 	}
 
-	return nil
+	return items, nil
 }
 
 // splitSQL splits SQL content by semicolons, removing comments
@@ -80,20 +203,12 @@ func splitSQL(content string) []string {
 	return result
 }
 
-// capitalize returns string with first letter capitalized
-func capitalize(s string) string {
-	if len(s) == 0 {
-		return s
-	}
-	return strings.ToUpper(s[:1]) + s[1:]
-}
-
-// discoverSQLFiles finds all .sql files in a directory
-func discoverSQLFiles(dir string) ([]string, error) {
-	files, err := os.ReadDir(dir)
+// discoverSQLFiles finds all .sql files in a directory of sqlFS
+func discoverSQLFiles(sqlFS fs.FS, dir string) ([]string, error) {
+	files, err := fs.ReadDir(sqlFS, dir)
 	if err != nil {
 		// Directory might not exist yet (e.g., no immediate indexers)
-		if os.IsNotExist(err) {
+		if errors.Is(err, fs.ErrNotExist) {
 			return []string{}, nil
 		}
 		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
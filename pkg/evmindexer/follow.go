@@ -0,0 +1,77 @@
+package evmindexer
+
+import (
+	"context"
+	"fmt"
+	"icicle/pkg/chwrapper"
+	"time"
+)
+
+// Follow feeds the runner from ClickHouse instead of OnBlock calls of a syncer, for indexers run
+// in their own process: every interval it reads the chain's sync watermark and the time of that
+// block, and applies pause and resume requests, acknowledging them in indexer_control_acks. Runs
+// until ctx is done.
+func (r *IndexRunner) Follow(ctx context.Context, interval time.Duration) {
+	r.logger.Info("Following sync watermark", "interval", interval)
+
+	var paused bool
+	var version uint64
+	for {
+		if err := r.follow(&paused, &version); err != nil {
+			r.logger.Error("Failed to follow sync watermark", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// follow applies the latest chain control request, then passes the sync watermark to OnBlock
+// unless the chain is paused
+func (r *IndexRunner) follow(paused *bool, version *uint64) error {
+	ctrl, err := chwrapper.GetChainControl(r.conn, r.chainId)
+	if err != nil {
+		return err
+	}
+	if ctrl.Version != *version {
+		switch {
+		case ctrl.Paused && !*paused:
+			r.Pause()
+			r.logger.Info("Paused")
+		case !ctrl.Paused && *paused:
+			if err := r.Resume(); err != nil {
+				return fmt.Errorf("failed to resume: %w", err)
+			}
+			r.logger.Info("Resumed")
+		}
+		if err := chwrapper.AckIndexerControl(r.conn, r.chainId, ctrl.Version, ctrl.Paused); err != nil {
+			return err
+		}
+		*paused = ctrl.Paused
+		*version = ctrl.Version
+	}
+	if *paused {
+		return nil
+	}
+
+	watermark, err := chwrapper.GetWatermark(r.conn, r.chainId)
+	if err != nil {
+		return err
+	}
+	if watermark == 0 {
+		return nil
+	}
+	var blockTime time.Time
+	query := "SELECT block_time FROM raw_blocks WHERE chain_id = ? AND block_number = ?"
+	if err := r.conn.QueryRow(context.Background(), query, r.chainId, watermark).Scan(&blockTime); err != nil {
+		return fmt.Errorf("failed to query time of block %d: %w", watermark, err)
+	}
+
+	r.mu.Lock()
+	r.OnBlock(uint64(watermark), blockTime)
+	r.mu.Unlock()
+	return nil
+}
@@ -1,19 +1,48 @@
 package evmindexer
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"icicle/pkg/tracing"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var tracer = tracing.Tracer("evmindexer")
+
 var epoch = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
 
-// processGranularMetrics checks and runs all granular metrics
-func (r *IndexRunner) processGranularMetrics() {
+// feeMetrics are the metrics denominated in the chain's fee asset
+var feeMetrics = []string{"fees_paid", "avg_gas_price", "max_gas_price"}
+
+// granularities lists the periods granular metrics can be computed for
+var granularities = []string{"5min", "hour", "day", "week", "month", "year"}
+
+// defaultGranularities are the periods a granular metric is computed for unless its settings
+// list others
+var defaultGranularities = []string{"hour", "day", "week", "month"}
+
+// granularityFunctions are the ClickHouse toStartOf functions of granularities without the prefix,
+// and intervals the length of one period
+var (
+	granularityFunctions = map[string]string{"5min": "FiveMinutes", "hour": "Hour", "day": "Day", "week": "Week", "month": "Month", "year": "Year"}
+	granularityIntervals = map[string]string{"5min": "5 MINUTE", "hour": "1 HOUR", "day": "1 DAY", "week": "1 WEEK", "month": "1 MONTH", "year": "1 YEAR"}
+)
+
+// granularJobs returns a job for every due granular metric and granularity with complete periods to
+// process, and marks the metrics that are quarantined, backing off or not due as skipped
+func (r *IndexRunner) granularJobs(skipped map[string]bool) []*job {
+	var jobs []*job
+
 	for _, metricFile := range r.granularMetrics {
-		for _, granularity := range []string{"hour", "day", "week", "month"} {
-			// Use just the metric filename for indexer name, granularity tracked separately
-			indexerName := fmt.Sprintf("evm_metrics/%s", metricFile)
+		// Use just the metric filename for indexer name, granularity tracked separately
+		indexerName := fmt.Sprintf("evm_metrics/%s", metricFile)
+		for _, granularity := range r.settings[indexerName].Granularities {
+			if r.skip(indexerName, granularity) || !r.due(indexerName, indexerName, granularity) {
+				skipped[indexerName] = true
+				continue
+			}
 
 			watermark := r.getWatermarkWithGranularity(indexerName, granularity)
 
@@ -23,48 +52,84 @@ func (r *IndexRunner) processGranularMetrics() {
 			}
 
 			// Calculate periods to process
-			periods := getPeriodsToProcess(watermark.LastPeriod, r.latestBlockTime, granularity)
+			periods := getPeriodsToProcess(watermark.LastPeriod, r.latestBlockTime, granularity, r.loc)
 			if len(periods) == 0 {
 				continue
 			}
 
-			// Run metric
-			start := time.Now()
-			if err := r.runGranularMetric(metricFile, granularity, periods); err != nil {
-				log.Fatalf("[Chain %d] FATAL: Failed to run %s (%s): %v", r.chainId, indexerName, granularity, err)
-			}
-			elapsed := time.Since(start)
-			fmt.Printf("[Chain %d] %s (%s) - processed %d periods - time taken: %s\n",
-				r.chainId, indexerName, granularity, len(periods), elapsed)
-
-			// Update watermark
-			watermark.LastPeriod = periods[len(periods)-1]
-			if err := r.saveWatermarkWithGranularity(indexerName, granularity, watermark); err != nil {
-				log.Fatalf("[Chain %d] FATAL: Failed to save watermark for %s (%s): %v", r.chainId, indexerName, granularity, err)
+			next := *watermark
+			next.LastPeriod = periods[len(periods)-1]
+
+			// Rerun the last closed periods too, in case blocks of them were ingested late
+			recomputed := 0
+			if r.recompute > 0 && watermark.LastPeriod.After(epoch) {
+				recomputed = r.recompute
+				periods = append(trailingPeriods(watermark.LastPeriod, granularity, recomputed, r.loc), periods...)
 			}
+
+			jobs = append(jobs, &job{
+				file:        indexerName,
+				name:        indexerName,
+				granularity: granularity,
+				firstPeriod: periods[0],
+				lastPeriod:  next.LastPeriod,
+				run: func(stats *runStats) error {
+					if err := r.runGranularMetric(metricFile, granularity, periods, stats); err != nil {
+						return fmt.Errorf("failed to run metric: %w", err)
+					}
+					if err := r.saveWatermarkWithGranularity(indexerName, granularity, &next); err != nil {
+						return fmt.Errorf("failed to save watermark: %w", err)
+					}
+					return nil
+				},
+				done: func(elapsed time.Duration) {
+					*watermark = next
+					r.logger.Info("Processed periods", "indexer", indexerName, "granularity", granularity,
+						"periods", len(periods)-recomputed, "recomputed", recomputed, "elapsed", elapsed)
+				},
+			})
 		}
 	}
+
+	return jobs
 }
 
 // runGranularMetric executes a single granular metric for given periods
-func (r *IndexRunner) runGranularMetric(metricFile string, granularity string, periods []time.Time) error {
+func (r *IndexRunner) runGranularMetric(metricFile string, granularity string, periods []time.Time, stats *runStats) error {
+	templateParams, bindParams := granularParams(r.chainId, r.feeAsset, granularity, periods, r.loc)
+	filename := fmt.Sprintf("evm_metrics/%s.sql", metricFile)
+	return tracing.Run(context.Background(), tracer, "evmindexer.granular", func(context.Context) error {
+		settings := querySettings(r.querySettings, r.settings["evm_metrics/"+metricFile])
+		return executeSQLFile(r.conn, r.sqlFS, filename, templateParams, bindParams, settings, stats)
+	},
+		attribute.Int64("chain.id", int64(r.chainId)),
+		attribute.String("indexer", metricFile),
+		attribute.String("granularity", granularity),
+		attribute.Int("periods", len(periods)))
+}
+
+// granularParams returns the template and bind parameters of a granular metric run over periods
+// in loc
+func granularParams(chainId uint32, feeAsset, granularity string, periods []time.Time, loc *time.Location) ([]struct{ key, value string }, map[string]interface{}) {
 	firstPeriod := periods[0]
-	lastPeriod := nextPeriod(periods[len(periods)-1], granularity) // exclusive end
+	lastPeriod := nextPeriod(periods[len(periods)-1], granularity, loc) // exclusive end
 
 	// Template parameters (string replacement)
 	templateParams := []struct{ key, value string }{
-		{"{chain_id}", fmt.Sprintf("%d", r.chainId)},
+		{"{chain_id}", fmt.Sprintf("%d", chainId)},
 		{"{granularity}", granularity},
-		{"{granularityCamelCase}", capitalize(granularity)},
+		{"{granularityCamelCase}", granularityFunctions[granularity]},
+		{"{interval}", granularityIntervals[granularity]},
+		{"{fee_asset}", feeAsset},
+		{"{timezone}", loc.String()},
 	}
 
 	// Bind parameters (native ClickHouse parameter binding for WHERE clauses)
 	bindParams := map[string]interface{}{
-		"chain_id":     r.chainId,
+		"chain_id":     chainId,
 		"first_period": firstPeriod,
 		"last_period":  lastPeriod,
 	}
 
-	filename := fmt.Sprintf("evm_metrics/%s.sql", metricFile)
-	return executeSQLFile(r.conn, r.sqlDir, filename, templateParams, bindParams)
+	return templateParams, bindParams
 }
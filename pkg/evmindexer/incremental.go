@@ -1,24 +1,30 @@
 package evmindexer
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"icicle/pkg/tracing"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// IncrementalBatchSize is the maximum number of blocks to process per batch
+// IncrementalBatchSize is the default maximum number of blocks to process per batch
 // This prevents memory exhaustion when processing large block ranges with lots of events
 const IncrementalBatchSize = 2000
 
-// processIncrementalBatch processes pending blocks for all incremental indexers in batches
-// Processes up to IncrementalBatchSize blocks per indexer per call
-// Returns true if any work was done
-func (r *IndexRunner) processIncrementalBatch() bool {
-	hasWork := false
+// incrementalJobs returns a job for every due incremental indexer behind the latest block, processing
+// up to its batch size, and marks the indexers that are quarantined, backing off or not due as skipped
+func (r *IndexRunner) incrementalJobs(skipped map[string]bool) []*job {
+	var jobs []*job
 
-	// Process each indexer independently
 	for _, indexerFile := range r.incrementalIndexers {
 		indexerName := fmt.Sprintf("incremental/%s", indexerFile)
+		file := "evm_incremental/" + indexerFile
+		if r.skip(indexerName, "") || !r.due(file, indexerName, "") {
+			skipped[file] = true
+			continue
+		}
 		watermark := r.getWatermark(indexerName)
 
 		// Initialize watermark to startBlock-1 if never run (so first processed block is startBlock)
@@ -27,57 +33,70 @@ func (r *IndexRunner) processIncrementalBatch() bool {
 		}
 
 		// Check if there are blocks to process
-		if watermark.LastBlockNum < r.latestBlockNum {
-			fromBlock := watermark.LastBlockNum + 1
-			toBlock := r.latestBlockNum
-
-			// Limit batch size to prevent memory exhaustion
-			if toBlock-fromBlock+1 > IncrementalBatchSize {
-				toBlock = fromBlock + IncrementalBatchSize - 1
-			}
-
-			// Run indexer for the batch
-			start := time.Now()
-			if err := r.runIncrementalIndexer(indexerFile, fromBlock, toBlock); err != nil {
-				log.Fatalf("[Chain %d] FATAL: Failed to run %s: %v", r.chainId, indexerName, err)
-			}
-			elapsed := time.Since(start)
-
-			// Update watermark to the last processed block
-			watermark.LastBlockNum = toBlock
-
-			// Save watermark to DB
-			if err := r.saveWatermark(indexerName, watermark); err != nil {
-				log.Fatalf("[Chain %d] FATAL: Failed to save watermark for %s: %v", r.chainId, indexerName, err)
-			}
-
-			// Log the batch processing
-			blockCount := toBlock - fromBlock + 1
-			remainingBlocks := r.latestBlockNum - toBlock
-			fmt.Printf("[Chain %d] %s - processed blocks %d to %d (%d blocks, %d remaining) - %s\n",
-				r.chainId, indexerName, fromBlock, toBlock, blockCount, remainingBlocks, elapsed)
+		if watermark.LastBlockNum >= r.latestBlockNum {
+			continue
+		}
+		fromBlock := watermark.LastBlockNum + 1
+		toBlock := r.latestBlockNum
 
-			hasWork = true
+		// Limit batch size to prevent memory exhaustion
+		if batchSize := r.settings[file].BatchSize; toBlock-fromBlock+1 > batchSize {
+			toBlock = fromBlock + batchSize - 1
 		}
+
+		next := *watermark
+		next.LastBlockNum = toBlock
+		latestBlockNum := r.latestBlockNum
+		jobs = append(jobs, &job{
+			file:      file,
+			name:      indexerName,
+			fromBlock: fromBlock,
+			toBlock:   toBlock,
+			run: func(stats *runStats) error {
+				if err := r.runIncrementalIndexer(indexerFile, fromBlock, toBlock, stats); err != nil {
+					return fmt.Errorf("failed to run indexer: %w", err)
+				}
+				if err := r.saveWatermark(indexerName, &next); err != nil {
+					return fmt.Errorf("failed to save watermark: %w", err)
+				}
+				return nil
+			},
+			done: func(elapsed time.Duration) {
+				*watermark = next
+				r.logger.Info("Processed blocks", "indexer", indexerName, "from", fromBlock, "to", toBlock,
+					"blocks", toBlock-fromBlock+1, "remaining", latestBlockNum-toBlock, "elapsed", elapsed)
+			},
+		})
 	}
 
-	return hasWork
+	return jobs
 }
 
 // runIncrementalIndexer executes an incremental indexer for a block range
-func (r *IndexRunner) runIncrementalIndexer(indexerFile string, fromBlock, toBlock uint64) error {
+func (r *IndexRunner) runIncrementalIndexer(indexerFile string, fromBlock, toBlock uint64, stats *runStats) error {
+	templateParams, bindParams := incrementalParams(r.chainId, fromBlock, toBlock)
+	filename := fmt.Sprintf("evm_incremental/%s.sql", indexerFile)
+	attrs := append(tracing.Range(r.chainId, int64(fromBlock), int64(toBlock)), attribute.String("indexer", indexerFile))
+	return tracing.Run(context.Background(), tracer, "evmindexer.incremental", func(context.Context) error {
+		settings := querySettings(r.querySettings, r.settings["evm_incremental/"+indexerFile])
+		return executeSQLFile(r.conn, r.sqlFS, filename, templateParams, bindParams, settings, stats)
+	}, attrs...)
+}
+
+// incrementalParams returns the template and bind parameters of an incremental indexer run over a
+// block range
+func incrementalParams(chainId uint32, fromBlock, toBlock uint64) ([]struct{ key, value string }, map[string]interface{}) {
 	// Template parameters (string replacement for SELECT clauses)
 	templateParams := []struct{ key, value string }{
-		{"{chain_id}", fmt.Sprintf("%d", r.chainId)},
+		{"{chain_id}", fmt.Sprintf("%d", chainId)},
 	}
 
 	// Bind parameters (native ClickHouse parameter binding for WHERE clauses)
 	bindParams := map[string]interface{}{
-		"chain_id":   r.chainId,
+		"chain_id":   chainId,
 		"from_block": fromBlock,
 		"to_block":   toBlock,
 	}
 
-	filename := fmt.Sprintf("evm_incremental/%s.sql", indexerFile)
-	return executeSQLFile(r.conn, r.sqlDir, filename, templateParams, bindParams)
+	return templateParams, bindParams
 }
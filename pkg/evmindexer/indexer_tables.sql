@@ -5,10 +5,12 @@ CREATE TABLE IF NOT EXISTS metrics (
     granularity LowCardinality(String),  -- e.g., "hour", "day", "week", "month"
     period DateTime64(3, 'UTC'),         -- Period start time
     value UInt64,
+    asset LowCardinality(String) DEFAULT '',  -- Token the value is denominated in (fee metrics only, e.g. "AVAX"), empty otherwise
     computed_at DateTime64(3, 'UTC') DEFAULT now64(3)
 ) ENGINE = ReplacingMergeTree(computed_at)
 ORDER BY (chain_id, metric_name, granularity, period)
 PARTITION BY (chain_id, toYYYYMM(period));
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS asset LowCardinality(String) DEFAULT '' AFTER value;
 
 -- Unified watermark table for tracking indexer progress
 CREATE TABLE IF NOT EXISTS indexer_watermarks (
@@ -25,3 +27,35 @@ CREATE TABLE IF NOT EXISTS indexer_watermarks (
     updated_at DateTime64(3, 'UTC') DEFAULT now64(3)
 ) ENGINE = ReplacingMergeTree(updated_at)
 ORDER BY (chain_id, indexer_name, granularity);
+
+-- Indexers that kept failing and were stopped, until they succeed again after a restart
+CREATE TABLE IF NOT EXISTS indexer_quarantine (
+    chain_id UInt32,
+    indexer_name String,
+    granularity LowCardinality(String),  -- Empty for incrementals
+    quarantined Bool,                    -- false once the indexer succeeded again
+    attempts UInt32,                     -- Failed runs before it was quarantined
+    error String,
+    updated_at DateTime64(3, 'UTC') DEFAULT now64(3)
+) ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY (chain_id, indexer_name, granularity);
+
+-- One row per indexer run, kept 90 days, to spot slow and failing SQL files
+CREATE TABLE IF NOT EXISTS indexer_runs (
+    chain_id UInt32,
+    indexer_name String,
+    granularity LowCardinality(String),  -- Empty for incrementals
+    from_block UInt64,                   -- Block range of incrementals, 0 for metrics
+    to_block UInt64,
+    first_period DateTime64(3, 'UTC'),   -- Period range of metrics, epoch for incrementals
+    last_period DateTime64(3, 'UTC'),
+    started_at DateTime64(3, 'UTC'),
+    duration_ms UInt64,
+    read_rows UInt64,
+    read_bytes UInt64,
+    written_rows UInt64,
+    error String                         -- Empty when the run succeeded
+) ENGINE = MergeTree
+ORDER BY (chain_id, indexer_name, granularity, started_at)
+PARTITION BY toYYYYMM(started_at)
+TTL toDateTime(started_at) + INTERVAL 90 DAY;
@@ -5,22 +5,29 @@ import (
 	"time"
 )
 
-// toStartOfPeriod returns the start of the period for given granularity
-func toStartOfPeriod(t time.Time, granularity string) time.Time {
-	t = t.UTC()
+// toStartOfPeriod returns the start of the period for given granularity, with period boundaries
+// in loc like ClickHouse's toStartOf functions in that time zone. Periods are returned in UTC.
+func toStartOfPeriod(t time.Time, granularity string, loc *time.Location) time.Time {
+	t = t.In(loc)
 	switch granularity {
-	case "hour":
-		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.UTC)
+	case "5min", "hour":
+		// Truncate the wall clock, so zones with a 30 or 45 minute offset get local hours and a
+		// repeated hour at the end of DST is two periods
+		_, offset := t.Zone()
+		shift := time.Duration(offset) * time.Second
+		return t.Add(shift).Truncate(getPeriodDuration(granularity)).Add(-shift).UTC()
 	case "day":
-		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc).UTC()
 	case "week":
 		// Start of week (Sunday)
 		for t.Weekday() != time.Sunday {
 			t = t.AddDate(0, 0, -1)
 		}
-		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc).UTC()
 	case "month":
-		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc).UTC()
+	case "year":
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, loc).UTC()
 	default:
 		panic(fmt.Sprintf("unknown granularity: %s", granularity))
 	}
@@ -29,6 +36,8 @@ func toStartOfPeriod(t time.Time, granularity string) time.Time {
 // getPeriodDuration returns the duration of one period
 func getPeriodDuration(granularity string) time.Duration {
 	switch granularity {
+	case "5min":
+		return 5 * time.Minute
 	case "hour":
 		return time.Hour
 	case "day":
@@ -37,39 +46,65 @@ func getPeriodDuration(granularity string) time.Duration {
 		return 7 * 24 * time.Hour
 	case "month":
 		return 30 * 24 * time.Hour // approximation
+	case "year":
+		return 365 * 24 * time.Hour // approximation
 	default:
 		panic(fmt.Sprintf("unknown granularity: %s", granularity))
 	}
 }
 
 // nextPeriod returns the start of the next period
-func nextPeriod(t time.Time, granularity string) time.Time {
-	// First, round down to start of current period
-	currentPeriod := toStartOfPeriod(t, granularity)
+func nextPeriod(t time.Time, granularity string, loc *time.Location) time.Time {
+	return addPeriods(toStartOfPeriod(t, granularity, loc), granularity, 1, loc)
+}
 
-	// Then add one period
+// previousPeriod returns the start of the period before the one containing t
+func previousPeriod(t time.Time, granularity string, loc *time.Location) time.Time {
+	return addPeriods(toStartOfPeriod(t, granularity, loc), granularity, -1, loc)
+}
+
+// addPeriods moves a period start n periods, adding calendar days in loc so days stay days
+// across DST changes
+func addPeriods(period time.Time, granularity string, n int, loc *time.Location) time.Time {
+	period = period.In(loc)
 	switch granularity {
-	case "hour":
-		return currentPeriod.Add(time.Hour)
+	case "5min", "hour":
+		return period.Add(time.Duration(n) * getPeriodDuration(granularity)).UTC()
 	case "day":
-		return currentPeriod.AddDate(0, 0, 1)
+		return period.AddDate(0, 0, n).UTC()
 	case "week":
-		return currentPeriod.AddDate(0, 0, 7)
+		return period.AddDate(0, 0, 7*n).UTC()
 	case "month":
-		return currentPeriod.AddDate(0, 1, 0)
+		return period.AddDate(0, n, 0).UTC()
+	case "year":
+		return period.AddDate(n, 0, 0).UTC()
 	default:
 		panic(fmt.Sprintf("unknown granularity: %s", granularity))
 	}
 }
 
+// trailingPeriods returns the n periods up to and including last, oldest first
+func trailingPeriods(last time.Time, granularity string, n int, loc *time.Location) []time.Time {
+	periods := make([]time.Time, n)
+	for i := n - 1; i >= 0; i-- {
+		periods[i] = last
+		last = previousPeriod(last, granularity, loc)
+	}
+	return periods
+}
+
 // isPeriodComplete checks if a period is complete (we have data from next period)
-func isPeriodComplete(periodStart, latestBlockTime time.Time, granularity string) bool {
-	periodEnd := nextPeriod(periodStart, granularity)
+func isPeriodComplete(periodStart, latestBlockTime time.Time, granularity string, loc *time.Location) bool {
+	periodEnd := nextPeriod(periodStart, granularity, loc)
 	return latestBlockTime.After(periodEnd) || latestBlockTime.Equal(periodEnd)
 }
 
-// getPeriodsToProcess returns all complete periods to process
-func getPeriodsToProcess(lastProcessed, latestBlockTime time.Time, granularity string) []time.Time {
+// maxPeriodsPerRun caps the periods of one granular run, so the first run of a 5-minute metric
+// doesn't list every period since the epoch at once
+const maxPeriodsPerRun = 100000
+
+// getPeriodsToProcess returns the complete periods to process, at most maxPeriodsPerRun
+func getPeriodsToProcess(lastProcessed, latestBlockTime time.Time, granularity string, loc *time.Location) []time.Time {
 	var periods []time.Time
 
 	// Start point
@@ -79,13 +114,13 @@ func getPeriodsToProcess(lastProcessed, latestBlockTime time.Time, granularity s
 		return periods
 	} else {
 		// Start from next period after last processed
-		currentPeriod = nextPeriod(lastProcessed, granularity)
+		currentPeriod = nextPeriod(lastProcessed, granularity, loc)
 	}
 
 	// Collect all complete periods
-	for isPeriodComplete(currentPeriod, latestBlockTime, granularity) {
+	for len(periods) < maxPeriodsPerRun && isPeriodComplete(currentPeriod, latestBlockTime, granularity, loc) {
 		periods = append(periods, currentPeriod)
-		currentPeriod = nextPeriod(currentPeriod, granularity)
+		currentPeriod = nextPeriod(currentPeriod, granularity, loc)
 	}
 
 	return periods
@@ -0,0 +1,117 @@
+package evmindexer
+
+import (
+	"context"
+	"fmt"
+	"icicle/pkg/metrics"
+	"time"
+)
+
+const (
+	// IndexerRetries is how many times in a row an indexer may fail before it is quarantined
+	IndexerRetries = 5
+	// IndexerRetryDelay is the wait before retrying a failed indexer, doubled after every failure
+	IndexerRetryDelay = 10 * time.Second
+)
+
+// failure tracks the consecutive failed runs of an indexer
+type failure struct {
+	attempts int
+	retryAt  time.Time
+	recorded bool // Quarantined in indexer_quarantine
+}
+
+// loadQuarantine reads the indexers quarantined before the last restart. They are retried right
+// away, and their quarantine is lifted once they succeed.
+func (r *IndexRunner) loadQuarantine() error {
+	query := `
+	SELECT indexer_name, granularity
+	FROM indexer_quarantine FINAL
+	WHERE chain_id = ? AND quarantined`
+
+	rows, err := r.conn.Query(context.Background(), query, r.chainId)
+	if err != nil {
+		return fmt.Errorf("failed to query quarantined indexers: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, granularity string
+		if err := rows.Scan(&name, &granularity); err != nil {
+			return fmt.Errorf("failed to scan quarantined indexer: %w", err)
+		}
+		key := watermarkKey(name, granularity)
+		r.failures[key] = &failure{recorded: true}
+		metrics.SetIndexerQuarantined(r.chainId, key, true)
+		r.logger.Warn("Retrying indexer quarantined before restart", "indexer", key)
+	}
+
+	return rows.Err()
+}
+
+// skip reports whether an indexer is quarantined or waiting to be retried
+func (r *IndexRunner) skip(indexerName, granularity string) bool {
+	key := watermarkKey(indexerName, granularity)
+	if r.quarantined[key] {
+		return true
+	}
+	f := r.failures[key]
+	return f != nil && time.Now().Before(f.retryAt)
+}
+
+// fail records a failed run of an indexer and schedules its retry with backoff, or quarantines it
+// after IndexerRetries failures in a row. The other indexers and ingestion keep running.
+func (r *IndexRunner) fail(indexerName, granularity string, err error) {
+	key := watermarkKey(indexerName, granularity)
+	metrics.IndexerFailed(r.chainId, key)
+
+	f := r.failures[key]
+	if f == nil {
+		f = &failure{}
+		r.failures[key] = f
+	}
+	f.attempts++
+
+	if f.attempts < IndexerRetries {
+		delay := IndexerRetryDelay << (f.attempts - 1)
+		f.retryAt = time.Now().Add(delay)
+		r.logger.Warn("Indexer failed, retrying", "indexer", key, "attempt", f.attempts, "retry_in", delay, "error", err)
+		return
+	}
+
+	r.quarantined[key] = true
+	metrics.SetIndexerQuarantined(r.chainId, key, true)
+	r.logger.Error("Indexer quarantined, fix it and restart ingest to retry", "indexer", key, "attempts", f.attempts, "error", err)
+	if err := r.saveQuarantine(indexerName, granularity, true, f.attempts, err.Error()); err != nil {
+		r.logger.Error("Failed to record quarantined indexer", "indexer", key, "error", err)
+		return
+	}
+	f.recorded = true
+}
+
+// succeed clears the failures of an indexer after a successful run, lifting its quarantine
+func (r *IndexRunner) succeed(indexerName, granularity string) {
+	key := watermarkKey(indexerName, granularity)
+	f := r.failures[key]
+	if f == nil {
+		return
+	}
+	delete(r.failures, key)
+	r.logger.Info("Indexer recovered", "indexer", key, "failed_attempts", f.attempts)
+	if !f.recorded {
+		return
+	}
+	metrics.SetIndexerQuarantined(r.chainId, key, false)
+	if err := r.saveQuarantine(indexerName, granularity, false, 0, ""); err != nil {
+		r.logger.Error("Failed to clear quarantined indexer", "indexer", key, "error", err)
+	}
+}
+
+// saveQuarantine records the quarantine state of an indexer
+func (r *IndexRunner) saveQuarantine(indexerName, granularity string, quarantined bool, attempts int, reason string) error {
+	query := `
+	INSERT INTO indexer_quarantine (chain_id, indexer_name, granularity, quarantined, attempts, error)
+	VALUES (?, ?, ?, ?, ?, ?)`
+
+	return r.conn.Exec(context.Background(), query, r.chainId, indexerName, granularity, quarantined, uint32(attempts), reason)
+}
@@ -0,0 +1,102 @@
+package evmindexer
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay is how long the SQL directory must be quiet after a change before runners reload it,
+// so saving several files at once reloads them together
+const reloadDelay = time.Second
+
+// sqlVersion counts the changes to the SQL directory seen by WatchSQLDir. Runners reload their
+// indexers when it moves.
+var sqlVersion atomic.Uint64
+
+// WatchSQLDir watches the directory set with SetSQLDir, so running indexer runners pick up new,
+// changed and removed indexer files without a restart. It does nothing for the embedded files.
+func WatchSQLDir() error {
+	if sqlDir == "" {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch SQL directory: %w", err)
+	}
+	for _, dir := range []string{"evm_metrics", "evm_incremental", commonDir} {
+		path := filepath.Join(sqlDir, dir)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if err := watcher.Add(path); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+		var reload <-chan time.Time
+		for {
+			select {
+			case event := <-watcher.Events:
+				if filepath.Ext(event.Name) == ".sql" {
+					reload = time.After(reloadDelay)
+				}
+			case err := <-watcher.Errors:
+				slog.Error("Failed to watch SQL directory", "dir", sqlDir, "error", err)
+			case <-reload:
+				reload = nil
+				sqlVersion.Add(1)
+				slog.Info("SQL directory changed, reloading indexers", "dir", sqlDir)
+			}
+		}
+	}()
+	return nil
+}
+
+// reload rediscovers the indexers and rereads their settings after the SQL directory changed,
+// keeping the previous ones if the new files are invalid. New indexers start from their stored
+// watermark, or from scratch like at startup, and quarantined indexers are retried since their SQL
+// may be fixed.
+func (r *IndexRunner) reload() {
+	granularMetrics, incrementalIndexers := r.granularMetrics, r.incrementalIndexers
+	settings, levels := r.settings, r.levels
+
+	err := r.discoverIndexers()
+	if err == nil {
+		err = r.loadSettings()
+	}
+	if err == nil {
+		err = r.validateIndexers()
+	}
+	if err != nil {
+		r.granularMetrics, r.incrementalIndexers = granularMetrics, incrementalIndexers
+		r.settings, r.levels = settings, levels
+		r.logger.Error("Failed to reload indexers, keeping the previous ones", "error", err)
+		return
+	}
+
+	for file := range r.settings {
+		if settings[file] == nil {
+			r.logger.Info("Indexer added", "indexer", file)
+		}
+	}
+	for file := range settings {
+		if r.settings[file] == nil {
+			r.logger.Info("Indexer removed", "indexer", file)
+		}
+	}
+	for key := range r.quarantined {
+		delete(r.quarantined, key)
+		r.failures[key] = &failure{recorded: true}
+	}
+	r.logger.Info("Reloaded indexers", "granular_metrics", len(r.granularMetrics),
+		"incremental_indexers", len(r.incrementalIndexers), "enabled", len(r.settings))
+}
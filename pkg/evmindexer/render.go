@@ -0,0 +1,126 @@
+package evmindexer
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// Rendered is the SQL an indexer runs for one period or block range, with its template
+// placeholders replaced and its bind parameters alongside
+type Rendered struct {
+	File       string // SQL file, relative to the SQL directory
+	Statements []string
+	Params     map[string]interface{} // Bound to @name in the statements
+}
+
+// ResolveIndexer returns the SQL file of an indexer, without extension, e.g. evm_metrics/tx_count.
+// name is a file with or without its directory (evm_metrics/, evm_incremental/, or metrics/ and
+// incremental/ for short).
+func ResolveIndexer(name string) (string, error) {
+	name = strings.TrimSuffix(name, ".sql")
+	if rest, ok := strings.CutPrefix(name, "incremental/"); ok {
+		name = "evm_incremental/" + rest
+	} else if rest, ok := strings.CutPrefix(name, "metrics/"); ok {
+		name = "evm_metrics/" + rest
+	}
+
+	var candidates []string
+	if strings.Contains(name, "/") {
+		candidates = []string{name}
+	} else {
+		candidates = []string{"evm_metrics/" + name, "evm_incremental/" + name}
+	}
+	for _, file := range candidates {
+		if _, err := fs.Stat(sqlFiles, file+".sql"); err == nil {
+			return file, nil
+		}
+	}
+	return "", fmt.Errorf("unknown indexer %s", name)
+}
+
+// IsGranular reports whether an indexer file returned by ResolveIndexer is a granular metric
+func IsGranular(file string) bool {
+	return strings.HasPrefix(file, "evm_metrics/")
+}
+
+// CheckGranularity returns an error unless granular metrics are computed for granularity
+func CheckGranularity(granularity string) error {
+	if !slices.Contains(granularities, granularity) {
+		return fmt.Errorf("invalid granularity %q, expected one of %s", granularity, strings.Join(granularities, ", "))
+	}
+	return nil
+}
+
+// RenderGranular renders a granular metric for the period of granularity in loc that contains period
+func RenderGranular(chainId uint32, feeAsset, file, granularity string, period time.Time, loc *time.Location) (*Rendered, error) {
+	if err := CheckGranularity(granularity); err != nil {
+		return nil, err
+	}
+	templateParams, bindParams := granularParams(chainId, feeAsset, granularity, []time.Time{toStartOfPeriod(period, granularity, loc)}, loc)
+	return render(file, templateParams, bindParams)
+}
+
+// RenderIncremental renders an incremental indexer for a block range
+func RenderIncremental(chainId uint32, file string, fromBlock, toBlock uint64) (*Rendered, error) {
+	if fromBlock > toBlock {
+		return nil, fmt.Errorf("invalid block range %d-%d", fromBlock, toBlock)
+	}
+	templateParams, bindParams := incrementalParams(chainId, fromBlock, toBlock)
+	return render(file, templateParams, bindParams)
+}
+
+// render reads and renders an indexer file
+func render(file string, templateParams []struct{ key, value string }, bindParams map[string]interface{}) (*Rendered, error) {
+	statements, err := renderSQLFile(sqlFiles, file+".sql", templateParams)
+	if err != nil {
+		return nil, err
+	}
+	return &Rendered{File: file + ".sql", Statements: statements, Params: bindParams}, nil
+}
+
+// Explain returns the query plan of statement i from ClickHouse's EXPLAIN
+func (r *Rendered) Explain(conn driver.Conn, i int) ([]string, error) {
+	plan, err := explain(context.Background(), conn, r.Statements[i], r.Params)
+	if err != nil {
+		return nil, fmt.Errorf("statement %d: %w", i+1, err)
+	}
+	return plan, nil
+}
+
+// IsQuery reports whether a statement reads data, so it can be explained
+func IsQuery(stmt string) bool {
+	fields := strings.Fields(stmt)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "INSERT", "WITH":
+		return true
+	}
+	return false
+}
+
+// explain returns ClickHouse's query plan of a statement with bindParams bound
+func explain(ctx context.Context, conn driver.Conn, stmt string, bindParams map[string]interface{}) ([]string, error) {
+	rows, err := conn.Query(ctx, "EXPLAIN "+stmt, namedParams(bindParams)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain: %w", err)
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("failed to scan query plan: %w", err)
+		}
+		plan = append(plan, line)
+	}
+	return plan, rows.Err()
+}
@@ -0,0 +1,259 @@
+package evmindexer
+
+import (
+	"context"
+	"fmt"
+	"icicle/pkg/logging"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// RewindChain deletes computed data derived from blocks >= fromBlock and rewinds indexer
+// watermarks so the running indexers recompute it. fromTime is the block time of fromBlock,
+// or zero if the chain has no blocks at or after it (metrics are then left untouched), and loc
+// the time zone of the chain's metric periods. The chain's indexers must be paused while this runs.
+func RewindChain(conn driver.Conn, chainId uint32, fromBlock uint64, fromTime time.Time, loc *time.Location) error {
+	// Wait for deletes to finish so resumed indexers don't read stale rows
+	ctx := clickhouse.Context(context.Background(), clickhouse.WithSettings(clickhouse.Settings{
+		"mutations_sync": 2,
+	}))
+
+	rewindBlock, err := rewindIncrementalTables(ctx, conn, chainId, fromBlock)
+	if err != nil {
+		return err
+	}
+
+	if !fromTime.IsZero() {
+		for _, granularity := range granularities {
+			query := "ALTER TABLE metrics DELETE WHERE chain_id = ? AND granularity = ? AND period >= ?"
+			if err := conn.Exec(ctx, query, chainId, granularity, toStartOfPeriod(fromTime, granularity, loc)); err != nil {
+				return fmt.Errorf("failed to delete %s metrics: %w", granularity, err)
+			}
+		}
+	}
+
+	return rewindWatermarks(ctx, conn, chainId, rewindBlock, fromTime, loc)
+}
+
+// createTablePattern matches the tables an indexer SQL file creates
+var createTablePattern = regexp.MustCompile(`(?i)CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?(\w+)`)
+
+// RewindMetric moves the watermarks of a granular metric (a file returned by ResolveIndexer) back
+// so the periods from the one containing from on are recomputed, for one granularity or all of
+// them when granularity is empty. Recomputed periods replace the existing rows. Returns the
+// rewound watermarks. Periods start in loc. The chain's indexers must be paused while this runs.
+func RewindMetric(conn driver.Conn, chainId uint32, file, granularity string, from time.Time, loc *time.Location) ([]IndexerWatermark, error) {
+	watermarks, err := ListWatermarks(conn, chainId)
+	if err != nil {
+		return nil, err
+	}
+
+	var rewound []IndexerWatermark
+	for _, wm := range watermarks {
+		if wm.Indexer != file || (granularity != "" && wm.Granularity != granularity) {
+			continue
+		}
+		if wm.LastPeriod.Before(toStartOfPeriod(from, wm.Granularity, loc)) {
+			continue
+		}
+		wm.LastPeriod = previousPeriod(from, wm.Granularity, loc)
+		if err := SaveWatermark(conn, chainId, wm.Indexer, wm.Granularity, wm.Watermark); err != nil {
+			return nil, err
+		}
+		rewound = append(rewound, wm)
+	}
+	return rewound, nil
+}
+
+// RewindIncremental deletes the batches of an incremental indexer (a file returned by
+// ResolveIndexer) from fromBlock onwards in the tables its SQL file creates, and moves its
+// watermark back before them. Returns the new watermark block, which is earlier than fromBlock-1
+// when a batch straddles fromBlock. The chain's indexers must be paused while this runs.
+func RewindIncremental(conn driver.Conn, chainId uint32, file string, fromBlock uint64) (uint64, error) {
+	if fromBlock == 0 {
+		return 0, fmt.Errorf("the first block to recompute must be at least 1")
+	}
+	watermarks, err := ListWatermarks(conn, chainId)
+	if err != nil {
+		return 0, err
+	}
+	var current uint64
+	for _, wm := range watermarks {
+		if wm.Indexer == WatermarkName(file) && wm.Granularity == "" {
+			current = wm.LastBlockNum
+		}
+	}
+	if current < fromBlock {
+		return 0, fmt.Errorf("%s has only indexed up to block %d, nothing to rewind", file, current)
+	}
+
+	statements, err := renderSQLFile(sqlFiles, file+".sql", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	// Wait for deletes to finish so the resumed indexer doesn't read stale rows
+	ctx := clickhouse.Context(context.Background(), clickhouse.WithSettings(clickhouse.Settings{
+		"mutations_sync": 2,
+	}))
+	incremental, err := incrementalTables(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	var tables []string
+	for _, stmt := range statements {
+		for _, match := range createTablePattern.FindAllStringSubmatch(stmt, -1) {
+			if slices.Contains(incremental, match[1]) {
+				tables = append(tables, match[1])
+			}
+		}
+	}
+
+	rewindBlock, err := rewindBatches(ctx, conn, chainId, tables, fromBlock)
+	if err != nil {
+		return 0, err
+	}
+	if err := SaveWatermark(conn, chainId, WatermarkName(file), "", Watermark{LastBlockNum: rewindBlock}); err != nil {
+		return 0, err
+	}
+	return rewindBlock, nil
+}
+
+// rewindIncrementalTables deletes incremental indexer output from fromBlock onwards and returns
+// the block the incremental watermarks must be rewound to
+func rewindIncrementalTables(ctx context.Context, conn driver.Conn, chainId uint32, fromBlock uint64) (uint64, error) {
+	tables, err := incrementalTables(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	return rewindBatches(ctx, conn, chainId, tables, fromBlock)
+}
+
+// incrementalTables returns the incremental indexer tables, recognized by their (chain_id,
+// from_block, to_block) columns
+func incrementalTables(ctx context.Context, conn driver.Conn) ([]string, error) {
+	query := `
+	SELECT table
+	FROM system.columns
+	WHERE database = currentDatabase()
+	AND name IN ('chain_id', 'from_block', 'to_block')
+	AND table IN (
+		SELECT name FROM system.tables
+		WHERE database = currentDatabase() AND engine NOT IN ('View', 'MaterializedView')
+	)
+	GROUP BY table
+	HAVING count() = 3`
+
+	rows, err := conn.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query incremental tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, fmt.Errorf("failed to scan incremental table: %w", err)
+		}
+		tables = append(tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating incremental tables: %w", err)
+	}
+	return tables, nil
+}
+
+// rewindBatches deletes the batches of tables from fromBlock onwards and returns the block the
+// watermarks of their indexers must be rewound to. A batch that started before fromBlock is
+// deleted whole, so the rewind point moves back until no remaining batch straddles it.
+func rewindBatches(ctx context.Context, conn driver.Conn, chainId uint32, tables []string, fromBlock uint64) (uint64, error) {
+	rewindBlock := fromBlock - 1
+	for changed := true; changed; {
+		changed = false
+		for _, table := range tables {
+			var count uint64
+			var minFrom uint32
+			query := fmt.Sprintf("SELECT count(), min(from_block) FROM %s WHERE chain_id = ? AND to_block > ?", table)
+			if err := conn.QueryRow(ctx, query, chainId, rewindBlock).Scan(&count, &minFrom); err != nil {
+				return 0, fmt.Errorf("failed to query %s batches: %w", table, err)
+			}
+			if count > 0 && uint64(minFrom) <= rewindBlock {
+				rewindBlock = uint64(minFrom) - 1
+				changed = true
+			}
+		}
+	}
+
+	for _, table := range tables {
+		logging.Chain("evmindexer", chainId, "").Info("Deleting indexer rows", "table", table, "after_block", rewindBlock)
+		query := fmt.Sprintf("ALTER TABLE %s DELETE WHERE chain_id = ? AND to_block > ?", table)
+		if err := conn.Exec(ctx, query, chainId, rewindBlock); err != nil {
+			return 0, fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+	}
+
+	return rewindBlock, nil
+}
+
+// rewindWatermarks moves incremental watermarks back to rewindBlock and granular watermarks
+// back to the period before the one containing fromTime
+func rewindWatermarks(ctx context.Context, conn driver.Conn, chainId uint32, rewindBlock uint64, fromTime time.Time, loc *time.Location) error {
+	query := `
+	SELECT indexer_name, granularity, last_period, last_block_num
+	FROM indexer_watermarks FINAL
+	WHERE chain_id = ?`
+
+	rows, err := conn.Query(ctx, query, chainId)
+	if err != nil {
+		return fmt.Errorf("failed to query watermarks: %w", err)
+	}
+	defer rows.Close()
+
+	type watermarkRow struct {
+		name        string
+		granularity string
+		wm          Watermark
+	}
+
+	var rewound []watermarkRow
+	for rows.Next() {
+		var row watermarkRow
+		if err := rows.Scan(&row.name, &row.granularity, &row.wm.LastPeriod, &row.wm.LastBlockNum); err != nil {
+			return fmt.Errorf("failed to scan watermark: %w", err)
+		}
+
+		if row.granularity == "" {
+			if row.wm.LastBlockNum <= rewindBlock {
+				continue
+			}
+			row.wm.LastBlockNum = rewindBlock
+		} else {
+			if fromTime.IsZero() || row.wm.LastPeriod.Before(toStartOfPeriod(fromTime, row.granularity, loc)) {
+				continue
+			}
+			row.wm.LastPeriod = previousPeriod(fromTime, row.granularity, loc)
+		}
+		rewound = append(rewound, row)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating watermarks: %w", err)
+	}
+
+	insert := `
+	INSERT INTO indexer_watermarks (chain_id, indexer_name, granularity, last_period, last_block_num)
+	VALUES (?, ?, ?, ?, ?)`
+
+	for _, row := range rewound {
+		if err := conn.Exec(ctx, insert, chainId, row.name, row.granularity, row.wm.LastPeriod, row.wm.LastBlockNum); err != nil {
+			return fmt.Errorf("failed to rewind watermark %s: %w", watermarkKey(row.name, row.granularity), err)
+		}
+	}
+
+	logging.Chain("evmindexer", chainId, "").Info("Rewound indexer watermarks", "count", len(rewound))
+	return nil
+}
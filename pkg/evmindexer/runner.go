@@ -4,8 +4,15 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
-	"path/filepath"
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/logging"
+	"icicle/sql"
+	"io/fs"
+	"log/slog"
+	"os"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
@@ -14,12 +21,77 @@ import (
 //go:embed indexer_tables.sql
 var indexerTablesSQL string
 
+// sqlFiles holds the indexer SQL files, embedded in the binary unless SetSQLDir is called
+var sqlFiles fs.FS = sql.Files
+
+// sqlDir is the directory set with SetSQLDir, empty for the embedded files
+var sqlDir string
+
+// SetSQLDir reads the indexer SQL files from dir instead of the embedded ones, to try changes
+// without rebuilding. An empty dir keeps the embedded files.
+func SetSQLDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open SQL directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("SQL directory %s is not a directory", dir)
+	}
+	sqlFiles = os.DirFS(dir)
+	sqlDir = dir
+	return nil
+}
+
+// defaultTimezone is where metric periods start for chains that don't set their own time zone
+var defaultTimezone = time.UTC
+
+// SetTimezone sets the reporting time zone of metric periods, an IANA name such as Europe/Berlin.
+// An empty name keeps UTC.
+func SetTimezone(name string) error {
+	if name == "" {
+		return nil
+	}
+	loc, err := LoadTimezone(name)
+	if err != nil {
+		return err
+	}
+	defaultTimezone = loc
+	return nil
+}
+
+// LoadTimezone returns the IANA time zone name, or the one set with SetTimezone if name is empty.
+// Local is refused since it depends on the host and ClickHouse doesn't know it.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return defaultTimezone, nil
+	}
+	if name == "Local" {
+		return nil, fmt.Errorf("invalid time zone %q: use an IANA name such as Europe/Berlin", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", name, err)
+	}
+	return loc, nil
+}
+
 // IndexRunner processes indexers for a single chain
 type IndexRunner struct {
 	chainId    uint32
 	conn       driver.Conn
-	sqlDir     string
-	startBlock uint64 // First block to index (from config)
+	sqlFS      fs.FS
+	startBlock uint64         // First block to index (from config)
+	feeAsset   string         // Token fees are paid in, labels fee metrics
+	workers    int            // Indexers run at once
+	loc        *time.Location // Time zone metric periods start in
+	recompute  int            // Closed periods of a metric rerun with its new ones, for late blocks
+	logger     *slog.Logger
+
+	// ClickHouse settings of every indexer query, below the indexer's own
+	querySettings map[string]string
 
 	// Block state (updated by OnBlock)
 	latestBlockNum  uint64
@@ -31,18 +103,57 @@ type IndexRunner struct {
 	// Discovered indexers (loaded once at startup)
 	granularMetrics     []string
 	incrementalIndexers []string
+
+	// Settings of each SQL file, and its level: files only depend on lower levels
+	settings  map[string]*Settings
+	levels    map[string]int
+	loadedSQL uint64               // sqlVersion the indexers were loaded at
+	lastRun   map[string]time.Time // Last successful run, keyed like watermarks
+
+	// Failing indexers, keyed like watermarks. Quarantined ones are skipped until restart.
+	failures    map[string]*failure
+	quarantined map[string]bool
+
+	// Pause state (held by the indexer loop while a batch is running)
+	mu     sync.Mutex
+	paused bool
+}
+
+// Options configure an IndexRunner
+type Options struct {
+	StartBlock uint64 // First block to index
+	FeeAsset   string // Token fees are paid in, labels fee metrics
+	Workers    int    // Indexers run at once (default: DefaultWorkers)
+	Timezone   string // Time zone metric periods start in (default: the one set with SetTimezone)
+
+	// Closed periods every run of a metric also reruns, so blocks ingested after a period closed
+	// are counted (default: 0)
+	Recompute int
+
+	// ClickHouse settings of every indexer query, e.g. max_memory_usage, overridden by an
+	// indexer's clickhouse_settings
+	QuerySettings map[string]string
 }
 
 // NewIndexRunner creates a new indexer runner for a single chain
-func NewIndexRunner(chainId uint32, conn driver.Conn, sqlDir string, startBlock uint64) (*IndexRunner, error) {
-	// Create tables from indexer_tables.sql (metrics and indexer_watermarks)
+func NewIndexRunner(chainId uint32, conn driver.Conn, opts Options) (*IndexRunner, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	loc, err := LoadTimezone(opts.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create tables from indexer_tables.sql (metrics, indexer_watermarks, indexer_quarantine and indexer_runs)
 	// Execute each CREATE TABLE statement
 	statements := splitSQL(indexerTablesSQL)
 	for _, stmt := range statements {
 		if strings.TrimSpace(stmt) == "" {
 			continue
 		}
-		if err := conn.Exec(context.Background(), stmt); err != nil {
+		if err := conn.Exec(chwrapper.Idempotent(context.Background()), chwrapper.ClusterDDL(stmt)); err != nil {
 			// Ignore "already exists" errors
 			if !strings.Contains(err.Error(), "already exists") {
 				return nil, fmt.Errorf("failed to create table from indexer_tables.sql: %w", err)
@@ -51,41 +162,138 @@ func NewIndexRunner(chainId uint32, conn driver.Conn, sqlDir string, startBlock
 	}
 
 	runner := &IndexRunner{
-		chainId:    chainId,
-		conn:       conn,
-		sqlDir:     sqlDir,
-		startBlock: startBlock,
-		watermarks: make(map[string]*Watermark),
+		chainId:       chainId,
+		conn:          conn,
+		sqlFS:         sqlFiles,
+		startBlock:    opts.StartBlock,
+		feeAsset:      opts.FeeAsset,
+		workers:       workers,
+		loc:           loc,
+		recompute:     opts.Recompute,
+		querySettings: opts.QuerySettings,
+		logger:        logging.Chain("evmindexer", chainId, ""),
+		watermarks:    make(map[string]*Watermark),
+
+		failures:    make(map[string]*failure),
+		quarantined: make(map[string]bool),
+		lastRun:     make(map[string]time.Time),
+		loadedSQL:   sqlVersion.Load(),
+	}
+
+	// Refuse to mix fee metrics denominated in different tokens
+	if err := CheckFeeAsset(runner.feeAsset); err != nil {
+		return nil, err
+	}
+	if err := runner.checkFeeAsset(); err != nil {
+		return nil, err
+	}
+
+	// Refuse to mix periods starting in different time zones
+	if err := runner.checkTimezone(); err != nil {
+		return nil, err
 	}
 
 	// Discover indexers
 	if err := runner.discoverIndexers(); err != nil {
 		return nil, fmt.Errorf("failed to discover indexers: %w", err)
 	}
+	if err := runner.loadSettings(); err != nil {
+		return nil, fmt.Errorf("failed to load indexer settings: %w", err)
+	}
+	if err := runner.validateIndexers(); err != nil {
+		return nil, fmt.Errorf("invalid indexer SQL: %w", err)
+	}
 
 	// Load watermarks from DB
 	if err := runner.loadWatermarks(); err != nil {
 		return nil, fmt.Errorf("failed to load watermarks: %w", err)
 	}
 
-	fmt.Printf("[Chain %d] IndexRunner initialized - %d granular metrics, %d incremental indexers\n",
-		chainId, len(runner.granularMetrics), len(runner.incrementalIndexers))
+	if err := runner.loadQuarantine(); err != nil {
+		return nil, err
+	}
+
+	runner.logger.Info("IndexRunner initialized",
+		"granular_metrics", len(runner.granularMetrics), "incremental_indexers", len(runner.incrementalIndexers), "workers", workers)
 
 	return runner, nil
 }
 
-// discoverIndexers scans filesystem for SQL files
+// feeAssetPattern matches the fee assets that can be put into the fee metrics' SQL as they are
+var feeAssetPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// CheckFeeAsset fails unless asset is a token symbol of letters, digits, dots, underscores and dashes
+func CheckFeeAsset(asset string) error {
+	if !feeAssetPattern.MatchString(asset) {
+		return fmt.Errorf("invalid fee asset %q, expected letters, digits, '.', '_' or '-'", asset)
+	}
+	return nil
+}
+
+// checkFeeAsset fails if fee metrics for this chain were already computed in a different asset
+func (r *IndexRunner) checkFeeAsset() error {
+	query := `
+	SELECT DISTINCT asset
+	FROM metrics
+	WHERE chain_id = ? AND metric_name IN (?) AND asset != '' AND asset != ?`
+
+	rows, err := r.conn.Query(context.Background(), query, r.chainId, feeMetrics, r.feeAsset)
+	if err != nil {
+		return fmt.Errorf("failed to query fee metric assets: %w", err)
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var asset string
+		if err := rows.Scan(&asset); err != nil {
+			return fmt.Errorf("failed to scan fee metric asset: %w", err)
+		}
+		return fmt.Errorf("fee metrics for chain %d are denominated in %s but feeAsset is %s; resync or wipe the chain's metrics before changing it",
+			r.chainId, asset, r.feeAsset)
+	}
+
+	return rows.Err()
+}
+
+// checkTimezone fails if this chain's daily metrics were already computed for days starting in a
+// different time zone
+func (r *IndexRunner) checkTimezone() error {
+	query := `
+	SELECT period
+	FROM metrics
+	WHERE chain_id = ? AND granularity = 'day' AND toStartOfDay(toTimeZone(period, ?)) != period
+	LIMIT 1`
+
+	rows, err := r.conn.Query(context.Background(), query, r.chainId, r.loc.String())
+	if err != nil {
+		return fmt.Errorf("failed to query metric periods: %w", err)
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var period time.Time
+		if err := rows.Scan(&period); err != nil {
+			return fmt.Errorf("failed to scan metric period: %w", err)
+		}
+		return fmt.Errorf("daily metrics for chain %d start at %s, not midnight in %s; resync or wipe the chain's metrics before changing its timezone",
+			r.chainId, period.Format(time.RFC3339), r.loc)
+	}
+
+	return rows.Err()
+}
+
+// discoverIndexers scans the SQL files for indexers
 func (r *IndexRunner) discoverIndexers() error {
 	var err error
 
 	// Discover granular metrics
-	r.granularMetrics, err = discoverSQLFiles(filepath.Join(r.sqlDir, "evm_metrics"))
+	r.granularMetrics, err = discoverSQLFiles(r.sqlFS, "evm_metrics")
 	if err != nil {
 		return err
 	}
 
 	// Discover incremental indexers
-	r.incrementalIndexers, err = discoverSQLFiles(filepath.Join(r.sqlDir, "evm_incremental"))
+	r.incrementalIndexers, err = discoverSQLFiles(r.sqlFS, "evm_incremental")
 	if err != nil {
 		return err
 	}
@@ -101,24 +309,58 @@ func (r *IndexRunner) OnBlock(blockNum uint64, blockTime time.Time) {
 
 // Start begins the indexer loop (runs forever)
 func (r *IndexRunner) Start() {
-	fmt.Printf("[Chain %d] Starting indexer loop\n", r.chainId)
+	r.logger.Info("Starting indexer loop")
 
 	for {
-		// Only process if we have block data
-		if r.latestBlockNum == 0 {
+		r.mu.Lock()
+
+		// Only process if we have block data and are not paused
+		if r.paused || r.latestBlockNum == 0 {
+			r.mu.Unlock()
 			time.Sleep(100 * time.Millisecond)
 			continue
 		}
 
-		// Process all pending blocks for incremental indexers
-		hasWork := r.processIncrementalBatch()
+		// Pick up changes to the SQL directory
+		if version := sqlVersion.Load(); version != r.loadedSQL {
+			r.loadedSQL = version
+			r.reload()
+		}
 
-		// Process granular metrics (time-based)
-		r.processGranularMetrics()
+		// Run pending incremental batches and granular metric periods, independent ones concurrently
+		skipped := make(map[string]bool)
+		jobs := append(r.incrementalJobs(skipped), r.granularJobs(skipped)...)
+		hasWork := r.runJobs(jobs, skipped)
 
-		// Sleep only if no incremental work was done
+		r.mu.Unlock()
+
+		// Sleep only if no work was done
 		if !hasWork {
 			time.Sleep(100 * time.Millisecond)
 		}
 	}
 }
+
+// Pause stops the indexer loop, waiting for any running batch to finish
+func (r *IndexRunner) Pause() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused = true
+}
+
+// Resume reloads watermarks from DB (they may have been rewound while paused) and restarts the indexer loop.
+// Block state is cleared until the next OnBlock call.
+func (r *IndexRunner) Resume() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.watermarks = make(map[string]*Watermark)
+	if err := r.loadWatermarks(); err != nil {
+		return fmt.Errorf("failed to reload watermarks: %w", err)
+	}
+
+	r.latestBlockNum = 0
+	r.latestBlockTime = time.Time{}
+	r.paused = false
+	return nil
+}
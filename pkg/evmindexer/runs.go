@@ -0,0 +1,41 @@
+package evmindexer
+
+import (
+	"context"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// runStats are the rows an indexer run read and wrote, summed from ClickHouse progress packets
+type runStats struct {
+	readRows    uint64
+	readBytes   uint64
+	writtenRows uint64
+}
+
+// add counts one progress packet
+func (s *runStats) add(p *clickhouse.Progress) {
+	s.readRows += p.Rows
+	s.readBytes += p.Bytes
+	s.writtenRows += p.WroteRows
+}
+
+// recordRun writes a finished job to indexer_runs. A failure to record is only logged.
+func (r *IndexRunner) recordRun(res result) {
+	query := `
+	INSERT INTO indexer_runs (chain_id, indexer_name, granularity, from_block, to_block, first_period, last_period,
+		started_at, duration_ms, read_rows, read_bytes, written_rows, error)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	var errText string
+	if res.err != nil {
+		errText = res.err.Error()
+	}
+	j := res.job
+	err := r.conn.Exec(context.Background(), query, r.chainId, j.name, j.granularity, j.fromBlock, j.toBlock,
+		j.firstPeriod, j.lastPeriod, res.started, uint64(res.elapsed.Milliseconds()),
+		res.stats.readRows, res.stats.readBytes, res.stats.writtenRows, errText)
+	if err != nil {
+		r.logger.Warn("Failed to record indexer run", "indexer", watermarkKey(j.name, j.granularity), "error", err)
+	}
+}
@@ -0,0 +1,188 @@
+package evmindexer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultWorkers is how many indexers of a chain run at once when no worker count is configured
+const DefaultWorkers = 4
+
+// job is one pending indexer run of a round. run executes SQL and saves the watermark, done then
+// updates the in-memory watermark on the runner goroutine.
+type job struct {
+	file        string // SQL file without extension, relative to sqlFS
+	name        string // Watermark name
+	granularity string
+	fromBlock   uint64 // Range of an incremental run
+	toBlock     uint64
+	firstPeriod time.Time // Range of a granular run
+	lastPeriod  time.Time
+	run         func(stats *runStats) error
+	done        func(elapsed time.Duration)
+}
+
+// result is the outcome of a job
+type result struct {
+	job     *job
+	err     error
+	started time.Time
+	elapsed time.Duration
+	stats   runStats
+}
+
+// loadSettings reads the settings of every discovered indexer, drops the disabled ones and orders
+// the rest in levels, each depending only on lower ones
+func (r *IndexRunner) loadSettings() error {
+	r.settings = make(map[string]*Settings)
+	var err error
+	if r.incrementalIndexers, err = r.enabledIndexers("evm_incremental", r.incrementalIndexers); err != nil {
+		return err
+	}
+	if r.granularMetrics, err = r.enabledIndexers("evm_metrics", r.granularMetrics); err != nil {
+		return err
+	}
+
+	for file, settings := range r.settings {
+		for _, dep := range settings.Depends {
+			if r.settings[dep] == nil {
+				return fmt.Errorf("%s depends on unknown or disabled indexer %s", file, dep)
+			}
+		}
+	}
+
+	r.levels = make(map[string]int)
+	visiting := make(map[string]bool)
+	var level func(file string) (int, error)
+	level = func(file string) (int, error) {
+		if l, ok := r.levels[file]; ok {
+			return l, nil
+		}
+		if visiting[file] {
+			return 0, fmt.Errorf("dependency cycle at %s", file)
+		}
+		visiting[file] = true
+		l := 0
+		for _, dep := range r.settings[file].Depends {
+			depLevel, err := level(dep)
+			if err != nil {
+				return 0, err
+			}
+			l = max(l, depLevel+1)
+		}
+		r.levels[file] = l
+		return l, nil
+	}
+	for file := range r.settings {
+		if _, err := level(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enabledIndexers reads the settings of the indexers in dir and returns the enabled ones
+func (r *IndexRunner) enabledIndexers(dir string, names []string) ([]string, error) {
+	var enabled []string
+	for _, name := range names {
+		file := dir + "/" + name
+		settings, err := readSettings(r.sqlFS, file+".sql")
+		if err != nil {
+			return nil, err
+		}
+		if !settings.Enabled {
+			r.logger.Info("Indexer disabled", "indexer", file)
+			continue
+		}
+		r.settings[file] = settings
+		enabled = append(enabled, name)
+	}
+	return enabled, nil
+}
+
+// runJobs runs jobs level by level, with up to r.workers at once and higher priorities first. A job
+// is held back when an indexer it depends on is skipped or failed in this round, since it would
+// read stale data. Returns whether any job succeeded.
+func (r *IndexRunner) runJobs(jobs []*job, skipped map[string]bool) bool {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		a, b := jobs[i].file, jobs[j].file
+		if r.levels[a] != r.levels[b] {
+			return r.levels[a] < r.levels[b]
+		}
+		return r.settings[a].Priority > r.settings[b].Priority
+	})
+
+	progressed := false
+	for start := 0; start < len(jobs); {
+		end := start
+		for end < len(jobs) && r.levels[jobs[end].file] == r.levels[jobs[start].file] {
+			end++
+		}
+
+		var ready []*job
+		for _, j := range jobs[start:end] {
+			if r.blocked(j.file, skipped) {
+				skipped[j.file] = true
+				continue
+			}
+			ready = append(ready, j)
+		}
+
+		for _, res := range r.runLevel(ready) {
+			r.recordRun(res)
+			if res.err != nil {
+				skipped[res.job.file] = true
+				r.fail(res.job.name, res.job.granularity, res.err)
+				continue
+			}
+			res.job.done(res.elapsed)
+			r.lastRun[watermarkKey(res.job.name, res.job.granularity)] = time.Now()
+			r.succeed(res.job.name, res.job.granularity)
+			progressed = true
+		}
+		start = end
+	}
+	return progressed
+}
+
+// due reports whether the interval of an indexer has passed since its last successful run
+func (r *IndexRunner) due(file, indexerName, granularity string) bool {
+	interval := r.settings[file].Interval
+	return interval == 0 || time.Since(r.lastRun[watermarkKey(indexerName, granularity)]) >= interval
+}
+
+// blocked reports whether an indexer depends on one that is skipped in this round, including
+// dependencies waiting for their interval
+func (r *IndexRunner) blocked(file string, skipped map[string]bool) bool {
+	for _, dep := range r.settings[file].Depends {
+		if skipped[dep] {
+			return true
+		}
+	}
+	return false
+}
+
+// runLevel runs independent jobs on a pool of r.workers goroutines
+func (r *IndexRunner) runLevel(jobs []*job) []result {
+	results := make([]result, len(jobs))
+	sem := make(chan struct{}, r.workers)
+	var wg sync.WaitGroup
+	for i, j := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			res := result{job: j, started: time.Now()}
+			res.err = j.run(&res.stats)
+			res.elapsed = time.Since(res.started)
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,198 @@
+package evmindexer
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/stretchr/testify/require"
+)
+
+// nopConn accepts the run records and quarantine updates of the scheduler
+type nopConn struct {
+	driver.Conn
+}
+
+func (nopConn) Exec(ctx context.Context, query string, args ...any) error { return nil }
+
+// newTestRunner returns a runner of the incremental indexers in files, keyed by name, before
+// their settings are loaded
+func newTestRunner(files map[string]string, workers int) *IndexRunner {
+	sqlFS := fstest.MapFS{}
+	var names []string
+	for name, header := range files {
+		sqlFS["evm_incremental/"+name+".sql"] = &fstest.MapFile{Data: []byte(header + "\nSELECT 1\n")}
+		names = append(names, name)
+	}
+	return &IndexRunner{
+		chainId:             1,
+		conn:                nopConn{},
+		sqlFS:               sqlFS,
+		workers:             workers,
+		logger:              slog.New(slog.DiscardHandler),
+		incrementalIndexers: names,
+		lastRun:             make(map[string]time.Time),
+		failures:            make(map[string]*failure),
+		quarantined:         make(map[string]bool),
+	}
+}
+
+func TestLoadSettingsLevels(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		levels map[string]int
+		err    string
+	}{
+		{
+			name:   "independent",
+			files:  map[string]string{"a": "", "b": ""},
+			levels: map[string]int{"a": 0, "b": 0},
+		},
+		{
+			name: "chain and diamond",
+			files: map[string]string{
+				"a": "",
+				"b": "-- depends: evm_incremental/a",
+				"c": "-- depends: evm_incremental/a",
+				"d": "-- depends: evm_incremental/b, evm_incremental/c",
+			},
+			levels: map[string]int{"a": 0, "b": 1, "c": 1, "d": 2},
+		},
+		{
+			name:   "level above the highest dependency",
+			files:  map[string]string{"a": "", "b": "-- depends: evm_incremental/a", "c": "-- depends: evm_incremental/a, evm_incremental/b"},
+			levels: map[string]int{"a": 0, "b": 1, "c": 2},
+		},
+		{
+			name:  "cycle",
+			files: map[string]string{"a": "-- depends: evm_incremental/b", "b": "-- depends: evm_incremental/a"},
+			err:   "dependency cycle",
+		},
+		{
+			name:  "unknown dependency",
+			files: map[string]string{"a": "-- depends: evm_incremental/missing"},
+			err:   "unknown or disabled indexer",
+		},
+		{
+			name:  "disabled dependency",
+			files: map[string]string{"a": "-- enabled: false", "b": "-- depends: evm_incremental/a"},
+			err:   "unknown or disabled indexer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRunner(tt.files, 1)
+			err := r.loadSettings()
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			for name, level := range tt.levels {
+				require.Equal(t, level, r.levels["evm_incremental/"+name], name)
+			}
+		})
+	}
+}
+
+func TestRunJobsOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		failed  string   // Indexer whose run fails
+		order   []string // Indexers in the order they run
+		skipped []string // Indexers failed or held back
+	}{
+		{
+			name: "levels before priorities",
+			files: map[string]string{
+				"base":   "-- priority: 1",
+				"urgent": "-- priority: 10\n-- depends: evm_incremental/base",
+				"other":  "-- priority: 5",
+			},
+			order: []string{"other", "base", "urgent"},
+		},
+		{
+			name: "failed dependency holds back dependents",
+			files: map[string]string{
+				"base":    "",
+				"derived": "-- depends: evm_incremental/base",
+				"deeper":  "-- depends: evm_incremental/derived",
+				"other":   "-- priority: -1",
+			},
+			failed:  "base",
+			order:   []string{"base", "other"},
+			skipped: []string{"base", "derived", "deeper"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRunner(tt.files, 1)
+			require.NoError(t, r.loadSettings())
+			var mu sync.Mutex
+			var order []string
+			var jobs []*job
+			for name := range tt.files {
+				jobs = append(jobs, &job{
+					file: "evm_incremental/" + name,
+					name: name,
+					run: func(stats *runStats) error {
+						mu.Lock()
+						order = append(order, name)
+						mu.Unlock()
+						if name == tt.failed {
+							return errors.New("query failed")
+						}
+						return nil
+					},
+					done: func(elapsed time.Duration) {},
+				})
+			}
+
+			skipped := make(map[string]bool)
+			require.True(t, r.runJobs(jobs, skipped))
+			require.Equal(t, tt.order, order)
+			for name := range tt.files {
+				require.Equal(t, slices.Contains(tt.skipped, name), skipped["evm_incremental/"+name], name)
+			}
+		})
+	}
+}
+
+func TestRunLevelWorkers(t *testing.T) {
+	files := map[string]string{"a": "", "b": "", "c": "", "d": "", "e": ""}
+	r := newTestRunner(files, 2)
+	require.NoError(t, r.loadSettings())
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	var jobs []*job
+	for name := range files {
+		jobs = append(jobs, &job{file: "evm_incremental/" + name, name: name, run: func(stats *runStats) error {
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		}})
+	}
+
+	results := r.runLevel(jobs)
+	require.Len(t, results, len(jobs))
+	for i, res := range results {
+		require.Same(t, jobs[i], res.job)
+		require.NoError(t, res.err)
+	}
+	require.Equal(t, 2, peak)
+}
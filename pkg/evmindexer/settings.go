@@ -0,0 +1,133 @@
+package evmindexer
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// Settings are the options of an indexer, read from "-- key: value" lines in the comments at the
+// top of its SQL file, e.g.
+//
+//	-- depends: evm_metrics/tx_count
+//	-- interval: 5m
+//	-- batch_size: 500
+//	-- enabled: false
+//	-- priority: 10
+//	-- granularities: 5min, hour, day
+//	-- clickhouse_settings: max_memory_usage=20000000000, max_threads=8
+//
+// Other comment lines, such as descriptions, are ignored.
+type Settings struct {
+	Depends   []string      // Indexers it reads from, run before it in every round
+	Interval  time.Duration // Minimum time between runs (default: every round)
+	BatchSize uint64        // Blocks per run, incremental indexers only (default: IncrementalBatchSize)
+	Enabled   bool          // Disabled indexers are never run (default: true)
+	Priority  int           // Higher runs first among indexers of the same level (default: 0)
+
+	// Periods a granular metric is computed for (default: hour, day, week and month)
+	Granularities []string
+
+	// ClickHouse settings of its queries, over the chain's indexerSettings (default: none)
+	ClickHouse map[string]string
+}
+
+// readSettings parses the settings of an SQL file in sqlFS
+func readSettings(sqlFS fs.FS, path string) (*Settings, error) {
+	f, err := sqlFS.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	settings := &Settings{Enabled: true, BatchSize: IncrementalBatchSize, Granularities: defaultGranularities}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		comment, ok := strings.CutPrefix(line, "--")
+		if !ok {
+			break // Settings are only read before the first statement
+		}
+		key, value, ok := strings.Cut(strings.TrimSpace(comment), ":")
+		if !ok {
+			continue
+		}
+		if err := settings.set(key, strings.TrimSpace(value)); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return settings, nil
+}
+
+// set applies one setting, ignoring unknown keys
+func (s *Settings) set(key, value string) error {
+	var err error
+	switch key {
+	case "depends":
+		for _, dep := range strings.Split(value, ",") {
+			if dep = strings.TrimSpace(dep); dep != "" {
+				s.Depends = append(s.Depends, dep)
+			}
+		}
+	case "interval":
+		s.Interval, err = time.ParseDuration(value)
+	case "batch_size":
+		s.BatchSize, err = strconv.ParseUint(value, 10, 64)
+		if err == nil && s.BatchSize == 0 {
+			err = fmt.Errorf("must be positive")
+		}
+	case "enabled":
+		s.Enabled, err = strconv.ParseBool(value)
+	case "priority":
+		s.Priority, err = strconv.Atoi(value)
+	case "granularities":
+		s.Granularities = nil
+		for _, granularity := range strings.Split(value, ",") {
+			granularity = strings.TrimSpace(granularity)
+			if err = CheckGranularity(granularity); err != nil {
+				break
+			}
+			s.Granularities = append(s.Granularities, granularity)
+		}
+	case "clickhouse_settings":
+		s.ClickHouse = make(map[string]string)
+		for _, setting := range strings.Split(value, ",") {
+			name, val, ok := strings.Cut(strings.TrimSpace(setting), "=")
+			if !ok || strings.TrimSpace(name) == "" {
+				err = fmt.Errorf("expected name=value pairs")
+				break
+			}
+			s.ClickHouse[strings.TrimSpace(name)] = strings.TrimSpace(val)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return nil
+}
+
+// querySettings merges the ClickHouse settings of a chain's indexers with an indexer's own
+func querySettings(chain map[string]string, s *Settings) clickhouse.Settings {
+	if len(chain) == 0 && len(s.ClickHouse) == 0 {
+		return nil
+	}
+	settings := make(clickhouse.Settings, len(chain)+len(s.ClickHouse))
+	for name, value := range chain {
+		settings[name] = value
+	}
+	for name, value := range s.ClickHouse {
+		settings[name] = value
+	}
+	return settings
+}
@@ -0,0 +1,59 @@
+package evmindexer
+
+import (
+	"context"
+	"fmt"
+	"icicle/pkg/chwrapper"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// validateIndexers renders every enabled indexer for each of its granularities and has ClickHouse
+// plan its queries under its ClickHouse settings, so a broken SQL file or unknown setting fails
+// startup instead of a run hours into a backfill. Statements that aren't queries, such as the
+// CREATE TABLE IF NOT EXISTS of the tables an indexer writes, are executed first like every run does.
+func (r *IndexRunner) validateIndexers() error {
+	for _, metricFile := range r.granularMetrics {
+		file := "evm_metrics/" + metricFile
+		for _, granularity := range r.settings[file].Granularities {
+			templateParams, bindParams := granularParams(r.chainId, r.feeAsset, granularity, []time.Time{epoch}, r.loc)
+			if err := r.validateSQLFile(file+".sql", templateParams, bindParams); err != nil {
+				return fmt.Errorf("%s (%s): %w", file, granularity, err)
+			}
+		}
+	}
+	for _, indexerFile := range r.incrementalIndexers {
+		file := "evm_incremental/" + indexerFile
+		templateParams, bindParams := incrementalParams(r.chainId, r.startBlock, r.startBlock+r.settings[file].BatchSize-1)
+		if err := r.validateSQLFile(file+".sql", templateParams, bindParams); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// validateSQLFile renders a SQL file and explains its queries
+func (r *IndexRunner) validateSQLFile(filename string, templateParams []struct{ key, value string }, bindParams map[string]interface{}) error {
+	statements, err := renderSQLFile(r.sqlFS, filename, templateParams)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if settings := querySettings(r.querySettings, r.settings[strings.TrimSuffix(filename, ".sql")]); settings != nil {
+		ctx = clickhouse.Context(ctx, clickhouse.WithSettings(settings))
+	}
+	for i, stmt := range statements {
+		if !IsQuery(stmt) {
+			if err := r.conn.Exec(ctx, chwrapper.ClusterDDL(stmt), namedParams(bindParams)...); err != nil && !strings.Contains(err.Error(), "already exists") {
+				return fmt.Errorf("statement %d failed: %w", i+1, err)
+			}
+			continue
+		}
+		if _, err := explain(ctx, r.conn, stmt, bindParams); err != nil {
+			return fmt.Errorf("statement %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
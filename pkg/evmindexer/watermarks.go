@@ -3,7 +3,12 @@ package evmindexer
 import (
 	"context"
 	"fmt"
+	"path"
 	"time"
+
+	"icicle/pkg/chwrapper"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 )
 
 // Watermark holds progress for an indexer
@@ -15,6 +20,61 @@ type Watermark struct {
 	LastBlockNum uint64
 }
 
+// IndexerWatermark is the stored progress of one indexer and granularity
+type IndexerWatermark struct {
+	Indexer     string
+	Granularity string // Empty for incremental indexers
+	Watermark
+	UpdatedAt time.Time
+}
+
+// WatermarkName returns the name the watermark of an indexer file returned by ResolveIndexer is
+// stored under
+func WatermarkName(file string) string {
+	if IsGranular(file) {
+		return file
+	}
+	return "incremental/" + path.Base(file)
+}
+
+// ListWatermarks returns the indexer watermarks of a chain, ordered by indexer and granularity
+func ListWatermarks(conn driver.Conn, chainId uint32) ([]IndexerWatermark, error) {
+	query := `
+	SELECT indexer_name, granularity, last_period, last_block_num, updated_at
+	FROM indexer_watermarks FINAL
+	WHERE chain_id = ?
+	ORDER BY indexer_name, granularity`
+
+	rows, err := conn.Query(context.Background(), query, chainId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query watermarks: %w", err)
+	}
+	defer rows.Close()
+
+	var watermarks []IndexerWatermark
+	for rows.Next() {
+		var wm IndexerWatermark
+		if err := rows.Scan(&wm.Indexer, &wm.Granularity, &wm.LastPeriod, &wm.LastBlockNum, &wm.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan watermark: %w", err)
+		}
+		watermarks = append(watermarks, wm)
+	}
+	return watermarks, rows.Err()
+}
+
+// SaveWatermark writes the watermark of an indexer. The chain's indexers must be paused while
+// this runs, and pick it up when they resume.
+func SaveWatermark(conn driver.Conn, chainId uint32, indexerName, granularity string, wm Watermark) error {
+	query := `
+	INSERT INTO indexer_watermarks (chain_id, indexer_name, granularity, last_period, last_block_num)
+	VALUES (?, ?, ?, ?, ?)`
+
+	if err := conn.Exec(chwrapper.Idempotent(context.Background()), query, chainId, indexerName, granularity, wm.LastPeriod, wm.LastBlockNum); err != nil {
+		return fmt.Errorf("failed to save watermark %s: %w", watermarkKey(indexerName, granularity), err)
+	}
+	return nil
+}
+
 // watermarkKey creates a key for watermark storage
 func watermarkKey(indexerName, granularity string) string {
 	if granularity == "" {
@@ -60,7 +120,7 @@ func (r *IndexRunner) loadWatermarks() error {
 		return fmt.Errorf("error iterating watermarks: %w", rows.Err())
 	}
 
-	fmt.Printf("[Chain %d] Loaded %d watermarks from DB\n", r.chainId, count)
+	r.logger.Info("Loaded watermarks from DB", "count", count)
 	return nil
 }
 
@@ -91,7 +151,7 @@ func (r *IndexRunner) getWatermarkWithGranularity(indexerName string, granularit
 
 // saveWatermark saves watermark to DB (for incrementals)
 func (r *IndexRunner) saveWatermark(indexerName string, wm *Watermark) error {
-	ctx := context.Background()
+	ctx := chwrapper.Idempotent(context.Background())
 	query := `
 	INSERT INTO indexer_watermarks (chain_id, indexer_name, granularity, last_period, last_block_num)
 	VALUES (?, ?, ?, ?, ?)`
@@ -101,7 +161,7 @@ func (r *IndexRunner) saveWatermark(indexerName string, wm *Watermark) error {
 
 // saveWatermarkWithGranularity saves watermark to DB for granular metrics
 func (r *IndexRunner) saveWatermarkWithGranularity(indexerName string, granularity string, wm *Watermark) error {
-	ctx := context.Background()
+	ctx := chwrapper.Idempotent(context.Background())
 	query := `
 	INSERT INTO indexer_watermarks (chain_id, indexer_name, granularity, last_period, last_block_num)
 	VALUES (?, ?, ?, ?, ?)`
@@ -0,0 +1,195 @@
+package evmrpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// arbTrace is a flat Parity-style trace frame as returned by arbtrace_block
+type arbTrace struct {
+	Action struct {
+		CallType       string `json:"callType"`
+		CreationMethod string `json:"creationMethod"`
+		From           string `json:"from"`
+		To             string `json:"to"`
+		Gas            string `json:"gas"`
+		Input          string `json:"input"`
+		Init           string `json:"init"`
+		Value          string `json:"value"`
+		Address        string `json:"address"`       // selfdestruct only
+		RefundAddress  string `json:"refundAddress"` // selfdestruct only
+		Balance        string `json:"balance"`       // selfdestruct only
+	} `json:"action"`
+	Result *struct {
+		GasUsed string `json:"gasUsed"`
+		Output  string `json:"output"`
+		Address string `json:"address"` // create only
+		Code    string `json:"code"`    // create only
+	} `json:"result"`
+	Error           string  `json:"error"`
+	TraceAddress    []int   `json:"traceAddress"`
+	TransactionHash *string `json:"transactionHash"`
+	Type            string  `json:"type"`
+}
+
+// callFrame converts a flat frame to a callTracer frame without children
+func (t *arbTrace) callFrame() CallTrace {
+	frame := CallTrace{
+		From:  t.Action.From,
+		To:    t.Action.To,
+		Gas:   t.Action.Gas,
+		Input: t.Action.Input,
+		Value: t.Action.Value,
+		Error: t.Error,
+		Type:  strings.ToUpper(t.Action.CallType),
+	}
+	if t.Result != nil {
+		frame.GasUsed = t.Result.GasUsed
+		frame.Output = t.Result.Output
+	}
+
+	switch t.Type {
+	case "create":
+		frame.Type = "CREATE"
+		if t.Action.CreationMethod != "" {
+			frame.Type = strings.ToUpper(t.Action.CreationMethod)
+		}
+		frame.Input = t.Action.Init
+		if t.Result != nil {
+			frame.To = t.Result.Address
+			frame.Output = t.Result.Code
+		}
+	case "suicide", "selfdestruct":
+		frame.Type = "SELFDESTRUCT"
+		frame.From = t.Action.Address
+		frame.To = t.Action.RefundAddress
+		frame.Value = t.Action.Balance
+	}
+	if frame.Type == "" {
+		frame.Type = "CALL"
+	}
+	return frame
+}
+
+// arbTraceNode is a frame being assembled into a call tree
+type arbTraceNode struct {
+	frame    CallTrace
+	children []*arbTraceNode
+}
+
+func (n *arbTraceNode) build() CallTrace {
+	frame := n.frame
+	for _, child := range n.children {
+		frame.Calls = append(frame.Calls, child.build())
+	}
+	return frame
+}
+
+// nestArbTraces rebuilds a callTracer tree per transaction from the flat frames of a block.
+// Frames come in depth-first order, so a parent always precedes its children.
+func nestArbTraces(frames []arbTrace) (map[string]*CallTrace, error) {
+	roots := make(map[string]*arbTraceNode)
+	var order []string
+	nodes := make(map[string]*arbTraceNode)
+
+	for i := range frames {
+		frame := &frames[i]
+		if frame.TransactionHash == nil {
+			continue // Block and uncle rewards
+		}
+		txHash := *frame.TransactionHash
+
+		node := &arbTraceNode{frame: frame.callFrame()}
+		nodes[fmt.Sprint(txHash, frame.TraceAddress)] = node
+		if len(frame.TraceAddress) == 0 {
+			roots[txHash] = node
+			order = append(order, txHash)
+			continue
+		}
+
+		parent, ok := nodes[fmt.Sprint(txHash, frame.TraceAddress[:len(frame.TraceAddress)-1])]
+		if !ok {
+			return nil, fmt.Errorf("trace frame %v of tx %s has no parent", frame.TraceAddress, txHash)
+		}
+		parent.children = append(parent.children, node)
+	}
+
+	traces := make(map[string]*CallTrace, len(roots))
+	for _, txHash := range order {
+		trace := roots[txHash].build()
+		traces[txHash] = &trace
+	}
+	return traces, nil
+}
+
+// fetchArbTracesBatch traces blocks with arbtrace_block, which Arbitrum nodes serve (for pre-Nitro
+// blocks in particular) where debug_traceBlockByNumber is unavailable
+func (f *Fetcher) fetchArbTracesBatch(from, to int64, txInfos []txInfo) (map[string]*TraceResultOptional, error) {
+	var requests []jsonRpcRequest
+	for i := 0; i < int(to-from+1); i++ {
+		requests = append(requests, jsonRpcRequest{
+			Jsonrpc: "2.0",
+			Method:  "arbtrace_block",
+			Params:  []interface{}{fmt.Sprintf("0x%x", from+int64(i))},
+			ID:      i,
+		})
+	}
+
+	blockTraces := make(map[int64]map[string]*CallTrace)
+	var mu sync.Mutex
+	var batchErr error
+
+	batches := chunksOf(requests, f.debugBatchSize)
+	runPool(f.maxConcurrency, len(batches), func(idx int) {
+		requests := batches[idx]
+
+		f.debugLimit <- struct{}{}
+		responses, err := f.batchRpcCallDebug(requests)
+		<-f.debugLimit
+
+		if err != nil {
+			mu.Lock()
+			if batchErr == nil {
+				batchErr = fmt.Errorf("arbtrace batch %d failed: %w", idx, err)
+			}
+			mu.Unlock()
+			return
+		}
+
+		for _, resp := range responses {
+			blockNum := from + int64(resp.ID)
+			var traces map[string]*CallTrace
+			var err error
+			if resp.Error != nil {
+				err = fmt.Errorf("%s", resp.Error.Message)
+			} else {
+				var frames []arbTrace
+				if err = json.Unmarshal(resp.Result, &frames); err == nil {
+					traces, err = nestArbTraces(frames)
+				}
+			}
+
+			mu.Lock()
+			if err != nil && batchErr == nil {
+				batchErr = fmt.Errorf("arbtrace_block for block %d failed: %w", blockNum, err)
+			}
+			blockTraces[blockNum] = traces
+			mu.Unlock()
+		}
+	})
+
+	if batchErr != nil {
+		return nil, batchErr
+	}
+
+	tracesMap := make(map[string]*TraceResultOptional)
+	for _, txInfo := range txInfos {
+		tracesMap[txInfo.hash] = &TraceResultOptional{
+			TxHash: txInfo.hash,
+			Result: blockTraces[txInfo.blockNum][txInfo.hash],
+		}
+	}
+	return tracesMap, nil
+}
@@ -0,0 +1,36 @@
+package evmrpc
+
+import (
+	"fmt"
+
+	"github.com/ava-labs/libevm/rlp"
+)
+
+// headerNumberIndex is the position of the block number in an RLP-encoded Ethereum header
+const headerNumberIndex = 8
+
+// RLPBlockHeight returns the block number of an RLP-encoded block without decoding chain-specific fields
+func RLPBlockHeight(blockRLP []byte) (uint64, error) {
+	blockContent, _, err := rlp.SplitList(blockRLP)
+	if err != nil {
+		return 0, fmt.Errorf("failed to split block: %w", err)
+	}
+
+	header, _, err := rlp.SplitList(blockContent)
+	if err != nil {
+		return 0, fmt.Errorf("failed to split header: %w", err)
+	}
+
+	// Skip parentHash, uncleHash, coinbase, root, txHash, receiptHash, bloom and difficulty
+	for i := 0; i < headerNumberIndex; i++ {
+		if _, _, header, err = rlp.Split(header); err != nil {
+			return 0, fmt.Errorf("failed to skip header field %d: %w", i, err)
+		}
+	}
+
+	number, _, err := rlp.SplitUint64(header)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read header number: %w", err)
+	}
+	return number, nil
+}
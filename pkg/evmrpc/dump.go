@@ -0,0 +1,65 @@
+package evmrpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadBlockDump calls fn with every block of a JSON dump in r: normalized blocks as the cache and
+// raw_payloads store them ({"block", "receipts", "traces"}), one per line, concatenated or in
+// JSON arrays. Blocks without a receipt per transaction are rejected, since they can't be ingested.
+func ReadBlockDump(r io.Reader, fn func(height int64, block *NormalizedBlock) error) error {
+	decoder := json.NewDecoder(bufio.NewReaderSize(r, 1<<20))
+	for {
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to decode dump: %w", err)
+		}
+
+		var blocks []*NormalizedBlock
+		if strings.HasPrefix(string(value), "[") {
+			if err := json.Unmarshal(value, &blocks); err != nil {
+				return fmt.Errorf("failed to decode block array: %w", err)
+			}
+		} else {
+			var block NormalizedBlock
+			if err := json.Unmarshal(value, &block); err != nil {
+				return fmt.Errorf("failed to decode block: %w", err)
+			}
+			blocks = append(blocks, &block)
+		}
+
+		for _, block := range blocks {
+			height, err := dumpBlockHeight(block)
+			if err != nil {
+				return err
+			}
+			if err := fn(height, block); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// dumpBlockHeight validates a dumped block and returns its height
+func dumpBlockHeight(block *NormalizedBlock) (int64, error) {
+	if block.Block.Number == "" {
+		return 0, fmt.Errorf("not a normalized block, expected {\"block\", \"receipts\", \"traces\"} objects")
+	}
+	height, err := strconv.ParseInt(strings.TrimPrefix(block.Block.Number, "0x"), 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid block number %q: %w", block.Block.Number, err)
+	}
+	if len(block.Receipts) != len(block.Block.Transactions) {
+		return 0, fmt.Errorf("block %d has %d receipts for %d transactions", height, len(block.Receipts), len(block.Block.Transactions))
+	}
+	return height, nil
+}
@@ -2,45 +2,84 @@ package evmrpc
 
 import (
 	"bytes"
+	"context"
+	"icicle/pkg/breaker"
 	"icicle/pkg/cache"
+	"icicle/pkg/httpcompress"
+	"icicle/pkg/logging"
+	"icicle/pkg/metrics"
+	"icicle/pkg/rpcerr"
+	"icicle/pkg/tracing"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("evmrpc")
+
 type FetcherOptions struct {
 	RpcURL           string
-	ChainID          uint32           // Chain ID for logging
-	ChainName        string           // Chain name for logging
-	MaxConcurrency   int              // Maximum concurrent RPC and debug requests
-	BatchSize        int              // Number of requests per batch
-	DebugBatchSize   int              // Number of debug requests per batch
-	MaxRetries       int              // Maximum number of retries per request
-	RetryDelay       time.Duration    // Initial retry delay
-	ProgressCallback ProgressCallback // Optional progress callback
-	Cache            *cache.Cache     // Optional cache for complete blocks
+	TraceRpcURL      string            // Endpoint for debug_trace* and arbtrace_* calls (default: RpcURL)
+	RpcHeaders       map[string]string // Extra HTTP headers sent with every request, e.g. API keys
+	NoCompression    bool              // Don't ask for gzip/deflate compressed responses
+	ChainID          uint32            // Chain ID for logging and tracing
+	ChainName        string            // Chain name for logging
+	MaxConcurrency   int               // Maximum concurrent RPC and debug requests
+	BatchSize        int               // Number of requests per batch
+	DebugBatchSize   int               // Number of debug requests per batch
+	MaxRetries       int               // Maximum number of retries per request
+	RetryDelay       time.Duration     // Initial retry delay
+	ProgressCallback ProgressCallback  // Optional progress callback
+	Cache            cache.Cache       // Optional cache for complete blocks
+	CacheTTLs        cache.TTLs        // Cache receipts and traces per block in their own namespaces
+	FetchUncles      bool              // Fetch the uncle headers of blocks that have uncles
+	Offline          bool              // Serve blocks only from Cache and report its checkpoint as the tip, never calling the RPC
 }
 
 type Fetcher struct {
 	rpcURL         string
+	traceURL       string // Endpoint for debug and trace calls, often an archival node
+	headers        map[string]string
+	breaker        *breaker.Breaker
+	traceBreaker   *breaker.Breaker
 	chainID        uint32
-	chainName      string
+	logger         *slog.Logger
 	batchSize      int
 	debugBatchSize int
 	maxRetries     int
 	retryDelay     time.Duration
 	progressCb     ProgressCallback
-	cache          *cache.Cache
+	cache          cache.Cache
+	receiptsCache  *cache.Entries // nil unless the receipts namespace is cached
+	tracesCache    *cache.Entries // nil unless the traces namespace is cached
+	fetchUncles    bool
+	offline        bool // Blocks come only from the cache, e.g. after an import
 
 	// Concurrency control
-	rpcLimit   chan struct{}
-	debugLimit chan struct{}
+	rpcLimit       chan struct{}
+	debugLimit     chan struct{}
+	maxConcurrency int
+	limitMu        sync.Mutex
+	reserved       int // Slots of rpcLimit and debugLimit held back by SetConcurrency
+
+	// skipTraces disables debug_trace* calls, blocks fetched without traces aren't cached
+	skipTraces atomic.Bool
+
+	// noBlockReceipts is set once the node turns out not to serve eth_getBlockReceipts
+	noBlockReceipts atomic.Bool
+
+	// useArbTrace is set once debug_traceBlockByNumber turns out unavailable and arbtrace_block works
+	useArbTrace atomic.Bool
 
 	// Cache writer
 	cacheWriteCh chan cacheWrite
@@ -80,6 +119,9 @@ func NewFetcher(opts FetcherOptions) *Fetcher {
 	if opts.RetryDelay == 0 {
 		opts.RetryDelay = 500 * time.Millisecond
 	}
+	if opts.TraceRpcURL == "" {
+		opts.TraceRpcURL = opts.RpcURL
+	}
 
 	// Create HTTP client with proper connection pooling
 	// Node.js reuses connections aggressively, so we do the same
@@ -96,21 +138,30 @@ func NewFetcher(opts FetcherOptions) *Fetcher {
 
 	f := &Fetcher{
 		rpcURL:         opts.RpcURL,
+		traceURL:       opts.TraceRpcURL,
+		headers:        opts.RpcHeaders,
+		breaker:        breaker.For(opts.RpcURL),
+		traceBreaker:   breaker.For(opts.TraceRpcURL),
 		chainID:        opts.ChainID,
-		chainName:      opts.ChainName,
+		logger:         logging.Chain("evmrpc", opts.ChainID, opts.ChainName),
 		batchSize:      opts.BatchSize,
 		debugBatchSize: opts.DebugBatchSize,
 		maxRetries:     opts.MaxRetries,
 		retryDelay:     opts.RetryDelay,
 		progressCb:     opts.ProgressCallback,
 		cache:          opts.Cache,
+		receiptsCache:  opts.CacheTTLs.Entries(opts.Cache, cache.NamespaceReceipts),
+		tracesCache:    opts.CacheTTLs.Entries(opts.Cache, cache.NamespaceTraces),
+		fetchUncles:    opts.FetchUncles,
+		offline:        opts.Offline,
 		rpcLimit:       make(chan struct{}, opts.MaxConcurrency),
 		debugLimit:     make(chan struct{}, opts.MaxConcurrency),
+		maxConcurrency: opts.MaxConcurrency,
 		cacheWriteCh:   make(chan cacheWrite, 1000), // Buffered channel
 		done:           make(chan struct{}),
 		httpClient: &http.Client{
 			Timeout:   5 * time.Minute,
-			Transport: transport,
+			Transport: httpcompress.Wrap(transport, !opts.NoCompression),
 		},
 	}
 
@@ -126,6 +177,30 @@ func NewFetcher(opts FetcherOptions) *Fetcher {
 	return f
 }
 
+// SetConcurrency lowers the number of concurrent RPC and debug requests below MaxConcurrency
+// by holding back slots. Waits for in-flight requests to free the slots it takes.
+func (f *Fetcher) SetConcurrency(n int) {
+	n = max(1, min(n, f.maxConcurrency))
+
+	f.limitMu.Lock()
+	defer f.limitMu.Unlock()
+
+	for target := f.maxConcurrency - n; f.reserved < target; f.reserved++ {
+		f.rpcLimit <- struct{}{}
+		f.debugLimit <- struct{}{}
+	}
+	for target := f.maxConcurrency - n; f.reserved > target; f.reserved-- {
+		<-f.rpcLimit
+		<-f.debugLimit
+	}
+}
+
+// SetTracesEnabled turns trace fetching on or off. Blocks fetched with traces off have
+// empty traces and are not cached, so a later fetch can still get them.
+func (f *Fetcher) SetTracesEnabled(enabled bool) {
+	f.skipTraces.Store(!enabled)
+}
+
 // cacheWriter runs in background goroutines to write blocks to cache
 func (f *Fetcher) cacheWriter() {
 	defer f.cacheWg.Done()
@@ -148,6 +223,16 @@ func (f *Fetcher) cacheWriter() {
 	}
 }
 
+// exhaustedError is a request that failed on every attempt without a usable reply, so the endpoint
+// rather than the requested blocks is at fault
+type exhaustedError struct {
+	err error
+}
+
+func (e *exhaustedError) Error() string { return e.err.Error() }
+
+func (e *exhaustedError) Unwrap() error { return e.err }
+
 // batchRpcCall sends a batch of JSON-RPC requests with retry logic
 func (f *Fetcher) batchRpcCall(requests []jsonRpcRequest) ([]jsonRpcResponse, error) {
 	if len(requests) == 0 {
@@ -158,6 +243,7 @@ func (f *Fetcher) batchRpcCall(requests []jsonRpcRequest) ([]jsonRpcResponse, er
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal batch request: %w", err)
 	}
+	method := requests[0].Method
 
 	var responses []jsonRpcResponse
 	var lastErr error
@@ -168,7 +254,7 @@ func (f *Fetcher) batchRpcCall(requests []jsonRpcRequest) ([]jsonRpcResponse, er
 			if delay > 10*time.Second {
 				delay = 10 * time.Second
 			}
-			log.Printf("[Chain %d - %s] WARNING: Batch request failed: %v. Retrying (attempt %d/%d) after %v", f.chainID, f.chainName, lastErr, attempt, f.maxRetries, delay)
+			f.logger.Warn("Batch request failed, retrying", "error", lastErr, "attempt", attempt, "max_retries", f.maxRetries, "delay", delay)
 			time.Sleep(delay)
 		}
 
@@ -178,10 +264,28 @@ func (f *Fetcher) batchRpcCall(requests []jsonRpcRequest) ([]jsonRpcResponse, er
 		}
 
 		req.Header.Set("Content-Type", "application/json")
+		for key, value := range f.headers {
+			req.Header.Set(key, value)
+		}
 
+		f.breaker.Wait(context.Background())
+		start := time.Now()
 		resp, err := f.httpClient.Do(req)
 		if err != nil {
+			metrics.ObserveRPC(f.breaker.Endpoint(), method, start, metrics.ErrTransport)
 			lastErr = fmt.Errorf("failed to make batch request: %w", err)
+			f.breaker.Failure(lastErr)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			metrics.ObserveRPC(f.breaker.Endpoint(), method, start, metrics.ErrStatus)
+			lastErr = rpcerr.FromStatus(resp.StatusCode)
+			if rpcerr.IsPermanent(lastErr) {
+				f.breaker.Success() // The endpoint answered, the request is wrong
+				return nil, fmt.Errorf("batch request rejected: %w", lastErr)
+			}
+			f.breaker.Failure(lastErr)
 			continue
 		}
 
@@ -191,9 +295,13 @@ func (f *Fetcher) batchRpcCall(requests []jsonRpcRequest) ([]jsonRpcResponse, er
 		resp.Body.Close()
 
 		if err != nil {
+			metrics.ObserveRPC(f.breaker.Endpoint(), method, start, metrics.ErrDecode)
 			lastErr = fmt.Errorf("failed to unmarshal batch response: %w", err)
+			f.breaker.Failure(lastErr)
 			continue
 		}
+		metrics.ObserveRPC(f.breaker.Endpoint(), method, start, "")
+		f.breaker.Success()
 
 		// Validate responses
 		if len(responses) != len(requests) {
@@ -215,7 +323,8 @@ func (f *Fetcher) batchRpcCall(requests []jsonRpcRequest) ([]jsonRpcResponse, er
 				break
 			}
 			if resp.Error != nil {
-				return nil, fmt.Errorf("RPC error in batch at index %d (ID %d): %s", i, resp.ID, resp.Error.Message)
+				metrics.RPCError(f.breaker.Endpoint(), method, metrics.ErrReply)
+				return nil, fmt.Errorf("RPC error in batch at index %d (ID %d): %w", i, resp.ID, rpcerr.FromCode(resp.Error.Code, resp.Error.Message))
 			}
 			if len(resp.Result) == 0 {
 				return nil, fmt.Errorf("empty result in batch response at index %d (ID %d)", i, resp.ID)
@@ -229,10 +338,11 @@ func (f *Fetcher) batchRpcCall(requests []jsonRpcRequest) ([]jsonRpcResponse, er
 		return responses, nil
 	}
 
-	return nil, fmt.Errorf("batch request failed after %d retries: %w", f.maxRetries, lastErr)
+	return nil, &exhaustedError{fmt.Errorf("batch request failed after %d retries: %w", f.maxRetries, lastErr)}
 }
 
-// batchRpcCallDebug is like batchRpcCall but uses debug concurrency limit
+// batchRpcCallDebug is like batchRpcCall but sends to the trace endpoint and leaves RPC errors
+// in the responses for the caller to handle
 func (f *Fetcher) batchRpcCallDebug(requests []jsonRpcRequest) ([]jsonRpcResponse, error) {
 	if len(requests) == 0 {
 		return []jsonRpcResponse{}, nil
@@ -242,6 +352,7 @@ func (f *Fetcher) batchRpcCallDebug(requests []jsonRpcRequest) ([]jsonRpcRespons
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal debug batch request: %w", err)
 	}
+	method := requests[0].Method
 
 	var responses []jsonRpcResponse
 	var lastErr error
@@ -252,20 +363,38 @@ func (f *Fetcher) batchRpcCallDebug(requests []jsonRpcRequest) ([]jsonRpcRespons
 			if delay > 10*time.Second {
 				delay = 10 * time.Second
 			}
-			log.Printf("[Chain %d - %s] WARNING: Debug batch request failed: %v. Retrying (attempt %d/%d) after %v", f.chainID, f.chainName, lastErr, attempt, f.maxRetries, delay)
+			f.logger.Warn("Debug batch request failed, retrying", "error", lastErr, "attempt", attempt, "max_retries", f.maxRetries, "delay", delay)
 			time.Sleep(delay)
 		}
 
-		req, err := http.NewRequest("POST", f.rpcURL, bytes.NewBuffer(jsonData))
+		req, err := http.NewRequest("POST", f.traceURL, bytes.NewBuffer(jsonData))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create debug request: %w", err)
 		}
 
 		req.Header.Set("Content-Type", "application/json")
+		for key, value := range f.headers {
+			req.Header.Set(key, value)
+		}
 
+		f.traceBreaker.Wait(context.Background())
+		start := time.Now()
 		resp, err := f.httpClient.Do(req)
 		if err != nil {
+			metrics.ObserveRPC(f.traceBreaker.Endpoint(), method, start, metrics.ErrTransport)
 			lastErr = fmt.Errorf("failed to make debug batch request: %w", err)
+			f.traceBreaker.Failure(lastErr)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			metrics.ObserveRPC(f.traceBreaker.Endpoint(), method, start, metrics.ErrStatus)
+			lastErr = rpcerr.FromStatus(resp.StatusCode)
+			if rpcerr.IsPermanent(lastErr) {
+				f.traceBreaker.Success() // The endpoint answered, the request is wrong
+				return nil, fmt.Errorf("debug batch request rejected: %w", lastErr)
+			}
+			f.traceBreaker.Failure(lastErr)
 			continue
 		}
 
@@ -275,9 +404,13 @@ func (f *Fetcher) batchRpcCallDebug(requests []jsonRpcRequest) ([]jsonRpcRespons
 		resp.Body.Close()
 
 		if err != nil {
+			metrics.ObserveRPC(f.traceBreaker.Endpoint(), method, start, metrics.ErrDecode)
 			lastErr = fmt.Errorf("failed to unmarshal debug batch response: %w", err)
+			f.traceBreaker.Failure(lastErr)
 			continue
 		}
+		metrics.ObserveRPC(f.traceBreaker.Endpoint(), method, start, "")
+		f.traceBreaker.Success()
 
 		// Sort responses by ID to match request order
 		sort.Slice(responses, func(i, j int) bool {
@@ -297,6 +430,9 @@ func (f *Fetcher) batchRpcCallDebug(requests []jsonRpcRequest) ([]jsonRpcRespons
 				validationErr = true
 				break
 			}
+			if resp.Error != nil {
+				metrics.RPCError(f.traceBreaker.Endpoint(), method, metrics.ErrReply)
+			}
 		}
 
 		if validationErr {
@@ -306,10 +442,14 @@ func (f *Fetcher) batchRpcCallDebug(requests []jsonRpcRequest) ([]jsonRpcRespons
 		return responses, nil
 	}
 
-	return nil, fmt.Errorf("debug batch request failed after %d retries: %w", f.maxRetries, lastErr)
+	return nil, &exhaustedError{fmt.Errorf("debug batch request failed after %d retries: %w", f.maxRetries, lastErr)}
 }
 
 func (f *Fetcher) GetLatestBlock() (int64, error) {
+	if f.offline {
+		return f.cache.GetCheckpoint()
+	}
+
 	requests := []jsonRpcRequest{
 		{
 			Jsonrpc: "2.0",
@@ -342,6 +482,85 @@ func (f *Fetcher) GetLatestBlock() (int64, error) {
 	return blockNum, nil
 }
 
+// GetChainID returns the chain ID the RPC reports with eth_chainId
+func (f *Fetcher) GetChainID() (uint64, error) {
+	requests := []jsonRpcRequest{
+		{
+			Jsonrpc: "2.0",
+			Method:  "eth_chainId",
+			Params:  []interface{}{},
+			ID:      1,
+		},
+	}
+
+	f.rpcLimit <- struct{}{}
+	responses, err := f.batchRpcCall(requests)
+	<-f.rpcLimit
+
+	if err != nil {
+		return 0, err
+	}
+
+	var chainIDHex string
+	if err := json.Unmarshal(responses[0].Result, &chainIDHex); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal chain ID: %w", err)
+	}
+
+	var chainID uint64
+	if _, err := fmt.Sscanf(chainIDHex, "0x%x", &chainID); err != nil {
+		return 0, fmt.Errorf("failed to parse chain ID: %w", err)
+	}
+
+	return chainID, nil
+}
+
+// GetTaggedBlock returns the number of the block a block tag such as "finalized" or "safe" points to
+func (f *Fetcher) GetTaggedBlock(tag string) (int64, error) {
+	if f.offline {
+		return f.cache.GetCheckpoint()
+	}
+
+	requests := []jsonRpcRequest{
+		{
+			Jsonrpc: "2.0",
+			Method:  "eth_getBlockByNumber",
+			Params:  []interface{}{tag, false},
+			ID:      1,
+		},
+	}
+
+	f.rpcLimit <- struct{}{}
+	responses, err := f.batchRpcCall(requests)
+	<-f.rpcLimit
+
+	if err != nil {
+		return 0, err
+	}
+	if string(responses[0].Result) == "null" {
+		return 0, fmt.Errorf("no %s block, the node may not support the tag", tag)
+	}
+
+	var header struct {
+		Number string `json:"number"`
+	}
+	if err := json.Unmarshal(responses[0].Result, &header); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal %s block: %w", tag, err)
+	}
+
+	var blockNum int64
+	if _, err := fmt.Sscanf(header.Number, "0x%x", &blockNum); err != nil {
+		return 0, fmt.Errorf("failed to parse block number: %w", err)
+	}
+
+	return blockNum, nil
+}
+
+// FetchHeadBlocks fetches blocks [from, to] with their transactions but without receipts or traces,
+// bypassing the cache, for blocks near the tip that can still be reorged
+func (f *Fetcher) FetchHeadBlocks(from, to int64) ([]Block, error) {
+	return f.fetchBlocksBatch(from, to)
+}
+
 // chunksOf splits a slice into chunks of specified size
 func chunksOf[T any](items []T, size int) [][]T {
 	if size <= 0 {
@@ -361,16 +580,101 @@ func chunksOf[T any](items []T, size int) [][]T {
 
 // FetchBlockRange fetches all blocks in the range [from, to] inclusive using batch operations
 func (f *Fetcher) FetchBlockRange(from, to int64) ([]*NormalizedBlock, error) {
+	ctx, span := tracer.Start(context.Background(), "evmrpc.FetchBlockRange", trace.WithAttributes(tracing.Range(f.chainID, from, to)...))
+	defer span.End()
+
+	blocks, err := f.fetchBlockRange(ctx, from, to)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	for i, block := range blocks {
+		if logging.DebugBlock(uint64(from) + uint64(i)) {
+			f.logDebugBlock(uint64(from)+uint64(i), block)
+		}
+	}
+	return blocks, nil
+}
+
+// StreamBlockRange fetches the blocks in [from, to] chunkSize blocks at a time and hands each
+// chunk [chunkFrom, chunkTo] to send in order, so a large range is never held in memory at once.
+// Blocks of a chunk that fail are refetched on their own, see fetchChunk. The next chunk is
+// fetched while send runs, so RPC keeps working while the writer inserts and send waits on it.
+// Stops at the first error of a fetch or of send, the chunks before it have been sent.
+func (f *Fetcher) StreamBlockRange(from, to int64, chunkSize int, send func(chunkFrom, chunkTo int64, blocks []*NormalizedBlock) error) error {
+	if chunkSize <= 0 {
+		return fmt.Errorf("chunk size must be positive")
+	}
+	type chunk struct {
+		blocks []*NormalizedBlock
+		err    error
+	}
+	prefetch := func(chunkFrom int64) chan chunk {
+		ch := make(chan chunk, 1)
+		go func() {
+			blocks, err := f.fetchChunk(chunkFrom, min(chunkFrom+int64(chunkSize)-1, to))
+			ch <- chunk{blocks, err}
+		}()
+		return ch
+	}
+
+	next := prefetch(from)
+	// A prefetch left running on error must finish before the fetcher can be closed
+	defer func() {
+		if next != nil {
+			<-next
+		}
+	}()
+	for chunkFrom := from; chunkFrom <= to; chunkFrom += int64(chunkSize) {
+		chunkTo := min(chunkFrom+int64(chunkSize)-1, to)
+		fetched := <-next
+		next = nil
+		if fetched.err != nil {
+			return fetched.err
+		}
+		if chunkTo < to {
+			next = prefetch(chunkTo + 1)
+		}
+		if err := send(chunkFrom, chunkTo, fetched.blocks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logDebugBlock logs a normalized block and its txs, for --debug-blocks heights
+func (f *Fetcher) logDebugBlock(height uint64, block *NormalizedBlock) {
+	f.logger.Info("Normalized debug block",
+		"block", height,
+		"hash", block.Block.Hash,
+		"parent_hash", block.Block.ParentHash,
+		"timestamp", block.Block.Timestamp,
+		"gas_used", block.Block.GasUsed,
+		"txs", len(block.Block.Transactions),
+		"receipts", len(block.Receipts),
+		"traces", len(block.Traces))
+	for i, tx := range block.Block.Transactions {
+		args := []any{"block", height, "index", i, "tx", tx.Hash, "from", tx.From, "to", tx.To}
+		if i < len(block.Receipts) {
+			args = append(args, "status", block.Receipts[i].Status, "logs", len(block.Receipts[i].Logs))
+		}
+		f.logger.Info("Debug block tx", args...)
+	}
+}
+
+// fetchBlockRange fetches blocks in [from, to], from the cache where possible
+func (f *Fetcher) fetchBlockRange(ctx context.Context, from, to int64) ([]*NormalizedBlock, error) {
 	if from > to {
 		return nil, fmt.Errorf("invalid range: from %d > to %d", from, to)
 	}
 
 	numBlocks := int(to - from + 1)
 	result := make([]*NormalizedBlock, numBlocks)
+	withTraces := !f.skipTraces.Load()
 
 	// If no cache, fetch everything as before
 	if f.cache == nil {
-		return f.fetchBlockRangeUncached(from, to)
+		return f.fetchBlockRangeUncached(ctx, from, to, withTraces)
 	}
 
 	// Step 1: Check cache for all blocks using efficient range query
@@ -387,7 +691,7 @@ func (f *Fetcher) FetchBlockRange(from, to int64) ([]*NormalizedBlock, error) {
 			// Cache hit - deserialize
 			var block NormalizedBlock
 			if err := json.Unmarshal(data, &block); err != nil {
-				log.Printf("[Chain %d - %s] Warning: failed to deserialize cached block %d: %v", f.chainID, f.chainName, blockNum, err)
+				f.logger.Warn("Failed to deserialize cached block", "block", blockNum, "error", err)
 				missingBlocks = append(missingBlocks, blockNum)
 			} else {
 				result[int(i)] = &block
@@ -398,10 +702,18 @@ func (f *Fetcher) FetchBlockRange(from, to int64) ([]*NormalizedBlock, error) {
 		}
 	}
 
+	// Blocks cached before uncle fetching was enabled don't carry uncle headers yet
+	if err := f.fillUncles(result); err != nil {
+		return nil, err
+	}
+
 	// Step 3: If all cached, return
 	if len(missingBlocks) == 0 {
 		return result, nil
 	}
+	if f.offline {
+		return nil, fmt.Errorf("block %d is not in the cache, import it first (offline mode)", missingBlocks[0])
+	}
 
 	// Step 4: Fetch missing blocks
 	sort.Slice(missingBlocks, func(i, j int) bool {
@@ -409,7 +721,7 @@ func (f *Fetcher) FetchBlockRange(from, to int64) ([]*NormalizedBlock, error) {
 	})
 
 	// Fetch each missing block range
-	fetchedBlocks, err := f.fetchAndCacheMissingBlocks(missingBlocks)
+	fetchedBlocks, err := f.fetchAndCacheMissingBlocks(ctx, missingBlocks, withTraces)
 	if err != nil {
 		return nil, err
 	}
@@ -428,9 +740,14 @@ func (f *Fetcher) FetchBlockRange(from, to int64) ([]*NormalizedBlock, error) {
 }
 
 // fetchBlockRangeUncached is the original implementation without caching
-func (f *Fetcher) fetchBlockRangeUncached(from, to int64) ([]*NormalizedBlock, error) {
+func (f *Fetcher) fetchBlockRangeUncached(ctx context.Context, from, to int64, withTraces bool) ([]*NormalizedBlock, error) {
 	// Batch fetch all blocks
-	blocks, err := f.fetchBlocksBatch(from, to)
+	var blocks []Block
+	err := tracing.Run(ctx, tracer, "evmrpc.fetchBlocks", func(context.Context) error {
+		var err error
+		blocks, err = f.fetchBlocksBatch(from, to)
+		return err
+	}, tracing.Range(f.chainID, from, to)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch blocks: %w", err)
 	}
@@ -450,26 +767,35 @@ func (f *Fetcher) fetchBlockRangeUncached(from, to int64) ([]*NormalizedBlock, e
 		}
 	}
 
+	// Receipts and traces cached in their own namespaces skip the RPC, but only when every block
+	// of the range has them, since they are fetched per range
+	receiptsMap, receiptsCached := cachedByTx[Receipt](f.receiptsCache, from, blocks)
+	tracesMap, tracesCached := cachedByTx[*TraceResultOptional](f.tracesCache, from, blocks)
+
 	// Batch fetch all receipts
-	var receiptsMap map[string]Receipt
-	if len(allTxs) > 0 {
-		receiptsMap, err = f.fetchReceiptsBatch(allTxs)
+	if !receiptsCached && len(allTxs) > 0 {
+		err = tracing.Run(ctx, tracer, "evmrpc.fetchReceipts", func(context.Context) error {
+			var err error
+			receiptsMap, err = f.fetchReceipts(from, blocks, allTxs)
+			return err
+		}, attribute.Int("tx.count", len(allTxs)))
 		if err != nil {
 			return nil, fmt.Errorf("failed to fetch receipts: %w", err)
 		}
-	} else {
-		receiptsMap = make(map[string]Receipt)
 	}
 
 	// Batch fetch all traces
-	var tracesMap map[string]*TraceResultOptional
-	if len(allTxs) > 0 {
-		tracesMap, err = f.fetchTracesBatch(from, to, allTxs)
+	if !withTraces {
+		tracesMap = make(map[string]*TraceResultOptional)
+	} else if !tracesCached && len(allTxs) > 0 {
+		err = tracing.Run(ctx, tracer, "evmrpc.fetchTraces", func(context.Context) error {
+			var err error
+			tracesMap, err = f.fetchTracesBatch(from, to, allTxs)
+			return err
+		}, attribute.Int("tx.count", len(allTxs)))
 		if err != nil {
 			return nil, fmt.Errorf("failed to fetch traces: %w", err)
 		}
-	} else {
-		tracesMap = make(map[string]*TraceResultOptional)
 	}
 
 	// Assemble normalized blocks
@@ -480,6 +806,7 @@ func (f *Fetcher) fetchBlockRangeUncached(from, to int64) ([]*NormalizedBlock, e
 		// Collect receipts for this block
 		receipts := make([]Receipt, len(blocks[i].Transactions))
 		traces := make([]TraceResultOptional, len(blocks[i].Transactions))
+		complete := true // Every transaction has a trace, so the traces can be cached
 
 		for j, tx := range blocks[i].Transactions {
 			receipt, ok := receiptsMap[tx.Hash]
@@ -492,6 +819,7 @@ func (f *Fetcher) fetchBlockRangeUncached(from, to int64) ([]*NormalizedBlock, e
 			if ok && trace != nil {
 				traces[j] = *trace
 			} else {
+				complete = false
 				traces[j] = TraceResultOptional{
 					TxHash: tx.Hash,
 					Result: nil,
@@ -504,13 +832,110 @@ func (f *Fetcher) fetchBlockRangeUncached(from, to int64) ([]*NormalizedBlock, e
 			Receipts: receipts,
 			Traces:   traces,
 		}
+
+		if len(blocks[i].Transactions) > 0 && f.receiptsCache != nil && !receiptsCached {
+			if data, err := json.Marshal(receipts); err == nil {
+				f.receiptsCache.Set(cache.BlockEntryKey(blockNum), data)
+			}
+		}
+		if len(blocks[i].Transactions) > 0 && f.tracesCache != nil && withTraces && !tracesCached && complete {
+			if data, err := json.Marshal(traces); err == nil {
+				f.tracesCache.Set(cache.BlockEntryKey(blockNum), data)
+			}
+		}
+	}
+
+	if err := f.fillUncles(result); err != nil {
+		return nil, err
 	}
 
 	return result, nil
 }
 
-// fetchAndCacheMissingBlocks fetches missing blocks in batch and caches them
-func (f *Fetcher) fetchAndCacheMissingBlocks(missingBlocks []int64) (map[int64]*NormalizedBlock, error) {
+// cachedByTx looks up the per-block cache entries of blocks (numbered from from) that have
+// transactions and indexes them by transaction hash. Reports false unless every such block had one.
+func cachedByTx[T any](entries *cache.Entries, from int64, blocks []Block) (map[string]T, bool) {
+	result := make(map[string]T)
+	if entries == nil {
+		return result, false
+	}
+	for i, block := range blocks {
+		if len(block.Transactions) == 0 {
+			continue
+		}
+		data := entries.Get(cache.BlockEntryKey(from + int64(i)))
+		if data == nil {
+			return result, false
+		}
+		var items []T
+		if err := json.Unmarshal(data, &items); err != nil || len(items) != len(block.Transactions) {
+			return result, false
+		}
+		for j, tx := range block.Transactions {
+			result[tx.Hash] = items[j]
+		}
+	}
+	return result, true
+}
+
+// fillUncles fetches the uncle headers of blocks that reference uncles but don't carry them yet.
+// Does nothing unless FetchUncles is set. Nil blocks are skipped.
+func (f *Fetcher) fillUncles(blocks []*NormalizedBlock) error {
+	if !f.fetchUncles || f.offline {
+		return nil
+	}
+
+	type uncleRef struct {
+		block *NormalizedBlock
+		index int
+	}
+	var requests []jsonRpcRequest
+	var refs []uncleRef
+	for _, block := range blocks {
+		if block == nil || len(block.Uncles) == len(block.Block.Uncles) {
+			continue
+		}
+		block.Uncles = make([]Block, len(block.Block.Uncles))
+		for i := range block.Block.Uncles {
+			requests = append(requests, jsonRpcRequest{
+				Jsonrpc: "2.0",
+				Method:  "eth_getUncleByBlockHashAndIndex",
+				Params:  []interface{}{block.Block.Hash, fmt.Sprintf("0x%x", i)},
+				ID:      len(refs),
+			})
+			refs = append(refs, uncleRef{block: block, index: i})
+		}
+	}
+
+	// Uncles are rare, so batches go out one at a time
+	for _, batch := range chunksOf(requests, f.batchSize) {
+		f.rpcLimit <- struct{}{}
+		responses, err := f.batchRpcCall(batch)
+		<-f.rpcLimit
+		if err != nil {
+			return fmt.Errorf("failed to fetch uncles: %w", err)
+		}
+
+		for _, resp := range responses {
+			ref := refs[resp.ID]
+			var uncle Block
+			decoder := json.NewDecoder(bytes.NewReader(resp.Result))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&uncle); err != nil {
+				return fmt.Errorf("failed to unmarshal uncle %d of block %s: %w", ref.index, ref.block.Block.Number, err)
+			}
+			if uncle.Hash == "" {
+				return fmt.Errorf("uncle %d of block %s not found", ref.index, ref.block.Block.Number)
+			}
+			ref.block.Uncles[ref.index] = uncle
+		}
+	}
+
+	return nil
+}
+
+// fetchAndCacheMissingBlocks fetches missing blocks in batch and caches them (only if fetched with traces)
+func (f *Fetcher) fetchAndCacheMissingBlocks(ctx context.Context, missingBlocks []int64, withTraces bool) (map[int64]*NormalizedBlock, error) {
 	if len(missingBlocks) == 0 {
 		return make(map[int64]*NormalizedBlock), nil
 	}
@@ -520,44 +945,42 @@ func (f *Fetcher) fetchAndCacheMissingBlocks(missingBlocks []int64) (map[int64]*
 
 	result := make(map[int64]*NormalizedBlock)
 	var mu sync.Mutex
-	var wg sync.WaitGroup
 	var fetchErr error
 
-	for _, r := range ranges {
-		wg.Add(1)
-		go func(from, to int64) {
-			defer wg.Done()
-
-			blocks, err := f.fetchBlockRangeUncached(from, to)
-			if err != nil {
-				mu.Lock()
-				if fetchErr == nil {
-					fetchErr = err
-				}
-				mu.Unlock()
-				return
+	// Scattered cache misses make many small ranges, fetched by a fixed pool of workers
+	runPool(f.maxConcurrency, len(ranges), func(idx int) {
+		from, to := ranges[idx][0], ranges[idx][1]
+		blocks, err := f.fetchBlockRangeUncached(ctx, from, to, withTraces)
+		if err != nil {
+			mu.Lock()
+			if fetchErr == nil {
+				fetchErr = err
 			}
+			mu.Unlock()
+			return
+		}
 
-			// Cache each block
-			for i, block := range blocks {
-				blockNum := from + int64(i)
+		// Cache each block
+		for i, block := range blocks {
+			blockNum := from + int64(i)
 
-				mu.Lock()
-				result[blockNum] = block
-				mu.Unlock()
+			mu.Lock()
+			result[blockNum] = block
+			mu.Unlock()
 
-				// Fire-and-forget cache write via channel
-				select {
-				case f.cacheWriteCh <- cacheWrite{blockNum: blockNum, block: block}:
-					// Sent to cache writer
-				default:
-					// Channel full, skip caching this block (non-blocking)
-				}
+			if !withTraces {
+				continue
 			}
-		}(r[0], r[1])
-	}
 
-	wg.Wait()
+			// Fire-and-forget cache write via channel
+			select {
+			case f.cacheWriteCh <- cacheWrite{blockNum: blockNum, block: block}:
+				// Sent to cache writer
+			default:
+				// Channel full, skip caching this block (non-blocking)
+			}
+		}
+	})
 
 	if fetchErr != nil {
 		return nil, fetchErr
@@ -566,6 +989,25 @@ func (f *Fetcher) fetchAndCacheMissingBlocks(missingBlocks []int64) (map[int64]*
 	return result, nil
 }
 
+// runPool calls fn(i) for every i in [0, count) on at most workers goroutines, each taking the
+// next index from a shared queue, and returns once every call is done. Large ranges then cost a
+// fixed number of goroutines instead of one per batch or block.
+func runPool(workers, count int, fn func(i int)) {
+	workers = max(1, min(workers, count))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < count; i = int(next.Add(1) - 1) {
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 // findContiguousRanges finds contiguous block ranges from a sorted list
 func (f *Fetcher) findContiguousRanges(blocks []int64) [][2]int64 {
 	if len(blocks) == 0 {
@@ -608,61 +1050,64 @@ func (f *Fetcher) fetchBlocksBatch(from, to int64) ([]Block, error) {
 
 	// Split into batches and execute concurrently
 	batches := chunksOf(allRequests, f.batchSize)
-	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var batchErr error
 	var completedBlocks int64
 
-	for batchIdx, batch := range batches {
-		wg.Add(1)
-		go func(idx int, requests []jsonRpcRequest) {
-			defer wg.Done()
+	runPool(f.maxConcurrency, len(batches), func(idx int) {
+		requests := batches[idx]
 
-			f.rpcLimit <- struct{}{}
-			responses, err := f.batchRpcCall(requests)
-			<-f.rpcLimit
+		f.rpcLimit <- struct{}{}
+		responses, err := f.batchRpcCall(requests)
+		<-f.rpcLimit
 
-			if err != nil {
+		if err != nil {
+			mu.Lock()
+			if batchErr == nil {
+				batchErr = fmt.Errorf("batch %d failed: %w", idx, err)
+			}
+			mu.Unlock()
+			return
+		}
+
+		// Parse block responses
+		batchTxCount := 0
+		for _, resp := range responses {
+			if string(resp.Result) == "null" {
 				mu.Lock()
 				if batchErr == nil {
-					batchErr = fmt.Errorf("batch %d failed: %w", idx, err)
+					batchErr = rpcerr.Permanent(fmt.Errorf("block %d not found, beyond the chain tip", from+int64(resp.ID)))
 				}
 				mu.Unlock()
 				return
 			}
 
-			// Parse block responses
-			batchTxCount := 0
-			for _, resp := range responses {
-				var block Block
-				decoder := json.NewDecoder(bytes.NewReader(resp.Result))
-				decoder.DisallowUnknownFields()
-				if err := decoder.Decode(&block); err != nil {
-					mu.Lock()
-					if batchErr == nil {
-						batchErr = fmt.Errorf("failed to unmarshal block at index %d: %w", resp.ID, err)
-					}
-					mu.Unlock()
-					return
-				}
-
-				batchTxCount += len(block.Transactions)
+			var block Block
+			decoder := json.NewDecoder(bytes.NewReader(resp.Result))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&block); err != nil {
 				mu.Lock()
-				blocks[resp.ID] = block
+				if batchErr == nil {
+					batchErr = fmt.Errorf("failed to unmarshal block at index %d: %w", resp.ID, err)
+				}
 				mu.Unlock()
+				return
 			}
 
-			// Report progress
+			batchTxCount += len(block.Transactions)
 			mu.Lock()
-			completedBlocks += int64(len(responses))
-			if f.progressCb != nil {
-				f.progressCb("blocks", completedBlocks, int64(numBlocks), batchTxCount)
-			}
+			blocks[resp.ID] = block
 			mu.Unlock()
-		}(batchIdx, batch)
-	}
+		}
 
-	wg.Wait()
+		// Report progress
+		mu.Lock()
+		completedBlocks += int64(len(responses))
+		if f.progressCb != nil {
+			f.progressCb("blocks", completedBlocks, int64(numBlocks), batchTxCount)
+		}
+		mu.Unlock()
+	})
 
 	if batchErr != nil {
 		return nil, batchErr
@@ -671,6 +1116,106 @@ func (f *Fetcher) fetchBlocksBatch(from, to int64) ([]Block, error) {
 	return blocks, nil
 }
 
+// fetchReceipts fetches the receipts of blocks with eth_getBlockReceipts, one call per block, and
+// falls back to eth_getTransactionReceipt per transaction on nodes that don't support it
+func (f *Fetcher) fetchReceipts(from int64, blocks []Block, txInfos []txInfo) (map[string]Receipt, error) {
+	if !f.noBlockReceipts.Load() {
+		receiptsMap, err := f.fetchBlockReceiptsBatch(from, blocks)
+		if err == nil {
+			return receiptsMap, nil
+		}
+		if isMethodNotFound(err) {
+			f.logger.Info("eth_getBlockReceipts not supported, fetching receipts per transaction", "error", err)
+			f.noBlockReceipts.Store(true)
+		} else {
+			f.logger.Warn("eth_getBlockReceipts failed, fetching receipts per transaction", "error", err)
+		}
+	}
+	return f.fetchReceiptsBatch(txInfos)
+}
+
+// isMethodNotFound reports whether err is a node rejecting an RPC method it doesn't implement
+func isMethodNotFound(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "does not exist") || strings.Contains(msg, "method not found") ||
+		strings.Contains(msg, "not available") || strings.Contains(msg, "not supported")
+}
+
+// fetchBlockReceiptsBatch fetches every receipt of blocks (numbered from from) with eth_getBlockReceipts
+func (f *Fetcher) fetchBlockReceiptsBatch(from int64, blocks []Block) (map[string]Receipt, error) {
+	receiptsMap := make(map[string]Receipt)
+	var mu sync.Mutex
+
+	var allRequests []jsonRpcRequest
+	for i, block := range blocks {
+		if len(block.Transactions) == 0 {
+			continue
+		}
+		allRequests = append(allRequests, jsonRpcRequest{
+			Jsonrpc: "2.0",
+			Method:  "eth_getBlockReceipts",
+			Params:  []interface{}{fmt.Sprintf("0x%x", from+int64(i))},
+			ID:      i,
+		})
+	}
+
+	batches := chunksOf(allRequests, f.batchSize)
+	var batchErr error
+
+	runPool(f.maxConcurrency, len(batches), func(idx int) {
+		requests := batches[idx]
+
+		f.rpcLimit <- struct{}{}
+		responses, err := f.batchRpcCall(requests)
+		<-f.rpcLimit
+
+		if err != nil {
+			mu.Lock()
+			if batchErr == nil {
+				batchErr = fmt.Errorf("block receipt batch %d failed: %w", idx, err)
+			}
+			mu.Unlock()
+			return
+		}
+
+		for _, resp := range responses {
+			block := blocks[resp.ID]
+
+			var receipts []Receipt
+			decoder := json.NewDecoder(bytes.NewReader(resp.Result))
+			decoder.DisallowUnknownFields()
+			err := decoder.Decode(&receipts)
+			if err == nil && len(receipts) != len(block.Transactions) {
+				err = fmt.Errorf("got %d receipts for %d transactions", len(receipts), len(block.Transactions))
+			}
+			for j := 0; err == nil && j < len(receipts); j++ {
+				if !strings.EqualFold(receipts[j].TransactionHash, block.Transactions[j].Hash) {
+					err = fmt.Errorf("receipt %d is for tx %s, expected %s", j, receipts[j].TransactionHash, block.Transactions[j].Hash)
+				}
+			}
+			if err != nil {
+				mu.Lock()
+				if batchErr == nil {
+					batchErr = fmt.Errorf("failed to unmarshal receipts of block %d: %w", from+int64(resp.ID), err)
+				}
+				mu.Unlock()
+				return
+			}
+
+			mu.Lock()
+			for j, tx := range block.Transactions {
+				receiptsMap[tx.Hash] = receipts[j]
+			}
+			mu.Unlock()
+		}
+	})
+
+	if batchErr != nil {
+		return nil, batchErr
+	}
+	return receiptsMap, nil
+}
+
 func (f *Fetcher) fetchReceiptsBatch(txInfos []txInfo) (map[string]Receipt, error) {
 	receiptsMap := make(map[string]Receipt)
 	var mu sync.Mutex
@@ -691,61 +1236,55 @@ func (f *Fetcher) fetchReceiptsBatch(txInfos []txInfo) (map[string]Receipt, erro
 
 	// Split into batches and execute concurrently
 	batches := chunksOf(allRequests, f.batchSize)
-	var wg sync.WaitGroup
 	var batchErr error
 	var completedReceipts int64
 	totalReceipts := int64(len(txInfos))
 
-	for batchIdx, batch := range batches {
-		wg.Add(1)
-		go func(idx int, requests []jsonRpcRequest) {
-			defer wg.Done()
+	runPool(f.maxConcurrency, len(batches), func(idx int) {
+		requests := batches[idx]
 
-			f.rpcLimit <- struct{}{}
-			responses, err := f.batchRpcCall(requests)
-			<-f.rpcLimit
+		f.rpcLimit <- struct{}{}
+		responses, err := f.batchRpcCall(requests)
+		<-f.rpcLimit
 
-			if err != nil {
-				mu.Lock()
-				if batchErr == nil {
-					batchErr = fmt.Errorf("receipt batch %d failed: %w", idx, err)
-				}
-				mu.Unlock()
-				return
+		if err != nil {
+			mu.Lock()
+			if batchErr == nil {
+				batchErr = fmt.Errorf("receipt batch %d failed: %w", idx, err)
 			}
+			mu.Unlock()
+			return
+		}
 
-			// Parse and store receipt responses
-			for _, resp := range responses {
-				txHash := txHashToIdx[resp.ID]
-
-				var receipt Receipt
-				decoder := json.NewDecoder(bytes.NewReader(resp.Result))
-				decoder.DisallowUnknownFields()
-				if err := decoder.Decode(&receipt); err != nil {
-					mu.Lock()
-					if batchErr == nil {
-						batchErr = fmt.Errorf("failed to unmarshal receipt for tx %s: %w", txHash, err)
-					}
-					mu.Unlock()
-					return
-				}
+		// Parse and store receipt responses
+		for _, resp := range responses {
+			txHash := txHashToIdx[resp.ID]
 
+			var receipt Receipt
+			decoder := json.NewDecoder(bytes.NewReader(resp.Result))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&receipt); err != nil {
 				mu.Lock()
-				receiptsMap[txHash] = receipt
+				if batchErr == nil {
+					batchErr = fmt.Errorf("failed to unmarshal receipt for tx %s: %w", txHash, err)
+				}
 				mu.Unlock()
+				return
 			}
 
-			// Report progress
 			mu.Lock()
-			completedReceipts += int64(len(responses))
-			if f.progressCb != nil {
-				f.progressCb("receipts", completedReceipts, totalReceipts, len(responses))
-			}
+			receiptsMap[txHash] = receipt
 			mu.Unlock()
-		}(batchIdx, batch)
-	}
+		}
 
-	wg.Wait()
+		// Report progress
+		mu.Lock()
+		completedReceipts += int64(len(responses))
+		if f.progressCb != nil {
+			f.progressCb("receipts", completedReceipts, totalReceipts, len(responses))
+		}
+		mu.Unlock()
+	})
 
 	if batchErr != nil {
 		return nil, batchErr
@@ -755,6 +1294,10 @@ func (f *Fetcher) fetchReceiptsBatch(txInfos []txInfo) (map[string]Receipt, erro
 }
 
 func (f *Fetcher) fetchTracesBatch(from, to int64, txInfos []txInfo) (map[string]*TraceResultOptional, error) {
+	if f.useArbTrace.Load() {
+		return f.fetchArbTracesBatch(from, to, txInfos)
+	}
+
 	tracesMap := make(map[string]*TraceResultOptional)
 	var mu sync.Mutex
 
@@ -776,58 +1319,55 @@ func (f *Fetcher) fetchTracesBatch(from, to int64, txInfos []txInfo) (map[string
 	// Try block traces in batches
 	blockBatches := chunksOf(blockRequests, f.debugBatchSize)
 	var blockTraceSuccess = true
+	var blockTraceUnsupported bool
 	blockTraces := make(map[int64][]TraceResultOptional)
 
-	var wg sync.WaitGroup
 	var blockErr error
 
-	for batchIdx, batch := range blockBatches {
-		wg.Add(1)
-		go func(idx int, requests []jsonRpcRequest) {
-			defer wg.Done()
+	runPool(f.maxConcurrency, len(blockBatches), func(idx int) {
+		requests := blockBatches[idx]
 
-			f.debugLimit <- struct{}{}
-			responses, err := f.batchRpcCallDebug(requests)
-			<-f.debugLimit
+		f.debugLimit <- struct{}{}
+		responses, err := f.batchRpcCallDebug(requests)
+		<-f.debugLimit
 
-			if err != nil {
+		if err != nil {
+			mu.Lock()
+			blockTraceSuccess = false
+			blockTraceUnsupported = blockTraceUnsupported || isMethodNotFound(err)
+			if blockErr == nil {
+				blockErr = fmt.Errorf("debug batch %d failed: %w", idx, err)
+			}
+			mu.Unlock()
+			return
+		}
+
+		// Parse block trace responses
+		for _, resp := range responses {
+			if resp.Error != nil {
 				mu.Lock()
 				blockTraceSuccess = false
-				if blockErr == nil {
-					blockErr = fmt.Errorf("debug batch %d failed: %w", idx, err)
-				}
+				blockTraceUnsupported = blockTraceUnsupported || isMethodNotFound(fmt.Errorf("%s", resp.Error.Message))
 				mu.Unlock()
 				return
 			}
 
-			// Parse block trace responses
-			for _, resp := range responses {
-				if resp.Error != nil {
-					mu.Lock()
-					blockTraceSuccess = false
-					mu.Unlock()
-					return
-				}
-
-				var traces []TraceResultOptional
-				decoder := json.NewDecoder(bytes.NewReader(resp.Result))
-				decoder.DisallowUnknownFields()
-				if err := decoder.Decode(&traces); err != nil {
-					mu.Lock()
-					blockTraceSuccess = false
-					mu.Unlock()
-					return
-				}
-
-				blockNum := from + int64(resp.ID)
+			var traces []TraceResultOptional
+			decoder := json.NewDecoder(bytes.NewReader(resp.Result))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&traces); err != nil {
 				mu.Lock()
-				blockTraces[blockNum] = traces
+				blockTraceSuccess = false
 				mu.Unlock()
+				return
 			}
-		}(batchIdx, batch)
-	}
 
-	wg.Wait()
+			blockNum := from + int64(resp.ID)
+			mu.Lock()
+			blockTraces[blockNum] = traces
+			mu.Unlock()
+		}
+	})
 
 	if blockTraceSuccess && blockErr == nil {
 		// Map block traces to transaction hashes
@@ -839,6 +1379,17 @@ func (f *Fetcher) fetchTracesBatch(from, to int64, txInfos []txInfo) (map[string
 		return tracesMap, nil
 	}
 
+	// Arbitrum nodes serve arbtrace_block where debug block tracing is unavailable
+	if blockTraceUnsupported {
+		arbTraces, err := f.fetchArbTracesBatch(from, to, txInfos)
+		if err == nil {
+			f.logger.Info("debug_traceBlockByNumber not supported, tracing with arbtrace_block")
+			f.useArbTrace.Store(true)
+			return arbTraces, nil
+		}
+		f.logger.Debug("arbtrace_block failed, tracing per transaction", "error", err)
+	}
+
 	// Fall back to per-transaction tracing
 
 	var txRequests []jsonRpcRequest
@@ -856,111 +1407,108 @@ func (f *Fetcher) fetchTracesBatch(from, to int64, txInfos []txInfo) (map[string
 
 	// Execute transaction traces in batches
 	txBatches := chunksOf(txRequests, f.debugBatchSize)
-	wg = sync.WaitGroup{}
 	var txBatchErr error
 
-	for batchIdx, batch := range txBatches {
-		wg.Add(1)
-		go func(idx int, requests []jsonRpcRequest) {
-			defer wg.Done()
+	runPool(f.maxConcurrency, len(txBatches), func(idx int) {
+		requests := txBatches[idx]
 
-			var responses []jsonRpcResponse
-			var err error
+		var responses []jsonRpcResponse
+		var err error
 
-			// Retry logic for this batch
-			for attempt := 0; attempt <= f.maxRetries; attempt++ {
-				if attempt > 0 {
-					delay := f.retryDelay * time.Duration(1<<uint(attempt-1))
-					if delay > 10*time.Second {
-						delay = 10 * time.Second
-					}
-					log.Printf("[Chain %d - %s] Retrying trace batch %d (attempt %d/%d) after %v\n", f.chainID, f.chainName, idx, attempt, f.maxRetries, delay)
-					time.Sleep(delay)
+		// Retry logic for this batch
+		for attempt := 0; attempt <= f.maxRetries; attempt++ {
+			if attempt > 0 {
+				delay := f.retryDelay * time.Duration(1<<uint(attempt-1))
+				if delay > 10*time.Second {
+					delay = 10 * time.Second
 				}
+				f.logger.Warn("Retrying trace batch", "batch", idx, "attempt", attempt, "max_retries", f.maxRetries, "delay", delay)
+				time.Sleep(delay)
+			}
 
-				f.debugLimit <- struct{}{}
-				responses, err = f.batchRpcCallDebug(requests)
-				<-f.debugLimit
+			f.debugLimit <- struct{}{}
+			responses, err = f.batchRpcCallDebug(requests)
+			<-f.debugLimit
 
-				if err != nil {
-					continue // Network/batch error, retry
+			if err != nil {
+				if rpcerr.IsPermanent(err) {
+					break
 				}
+				continue // Network/batch error, retry
+			}
 
-				// Check if any non-precompile errors exist
-				hasRetryableError := false
-				for _, resp := range responses {
-					if resp.Error != nil {
-						if !isPrecompileError(fmt.Errorf("%s", resp.Error.Message)) {
-							hasRetryableError = true
-							break
-						}
+			// Check if any non-precompile errors exist, permanent ones aren't worth retrying
+			hasRetryableError := false
+			for _, resp := range responses {
+				if resp.Error != nil {
+					if !isPrecompileError(fmt.Errorf("%s", resp.Error.Message)) && !rpcerr.IsPermanentCode(resp.Error.Code, resp.Error.Message) {
+						hasRetryableError = true
+						break
 					}
 				}
+			}
 
-				if !hasRetryableError {
-					break // Success or only precompile errors
-				}
+			if !hasRetryableError {
+				break // Success or only precompile errors
 			}
+		}
 
-			if err != nil {
-				// Batch failed after retries
-				mu.Lock()
-				if txBatchErr == nil {
-					txBatchErr = fmt.Errorf("trace batch %d failed after retries: %w", idx, err)
-				}
-				mu.Unlock()
-				return
+		if err != nil {
+			// Batch failed after retries
+			mu.Lock()
+			if txBatchErr == nil {
+				txBatchErr = fmt.Errorf("trace batch %d failed: %w", idx, err)
 			}
+			mu.Unlock()
+			return
+		}
 
-			// Parse transaction trace responses
-			for _, resp := range responses {
-				txHash := txHashToIdx[resp.ID]
+		// Parse transaction trace responses
+		for _, resp := range responses {
+			txHash := txHashToIdx[resp.ID]
 
-				if resp.Error != nil {
-					// ONLY precompile errors are acceptable as nil traces
-					if isPrecompileError(fmt.Errorf("%s", resp.Error.Message)) {
-						log.Printf("[Chain %d - %s] Trace failed for tx %s (precompile), treating as nil trace", f.chainID, f.chainName, txHash)
-						mu.Lock()
-						tracesMap[txHash] = &TraceResultOptional{
-							TxHash: txHash,
-							Result: nil,
-						}
-						mu.Unlock()
-						continue
-					} else {
-						// Non-precompile error after retries - fail the batch
-						mu.Lock()
-						if txBatchErr == nil {
-							txBatchErr = fmt.Errorf("trace for tx %s failed after retries: %s", txHash, resp.Error.Message)
-						}
-						mu.Unlock()
-						return
+			if resp.Error != nil {
+				// ONLY precompile errors are acceptable as nil traces
+				if isPrecompileError(fmt.Errorf("%s", resp.Error.Message)) {
+					f.logger.Debug("Trace failed for precompile tx, treating as nil trace", "tx", txHash)
+					mu.Lock()
+					tracesMap[txHash] = &TraceResultOptional{
+						TxHash: txHash,
+						Result: nil,
 					}
-				}
-
-				var trace CallTrace
-				decoder := json.NewDecoder(bytes.NewReader(resp.Result))
-				decoder.DisallowUnknownFields()
-				if err := decoder.Decode(&trace); err != nil {
+					mu.Unlock()
+					continue
+				} else {
+					// Non-precompile error after retries - fail the batch
 					mu.Lock()
 					if txBatchErr == nil {
-						txBatchErr = fmt.Errorf("failed to parse trace for tx %s: %w", txHash, err)
+						txBatchErr = fmt.Errorf("trace for tx %s failed: %w", txHash, rpcerr.FromCode(resp.Error.Code, resp.Error.Message))
 					}
 					mu.Unlock()
 					return
 				}
+			}
 
+			var trace CallTrace
+			decoder := json.NewDecoder(bytes.NewReader(resp.Result))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&trace); err != nil {
 				mu.Lock()
-				tracesMap[txHash] = &TraceResultOptional{
-					TxHash: txHash,
-					Result: &trace,
+				if txBatchErr == nil {
+					txBatchErr = fmt.Errorf("failed to parse trace for tx %s: %w", txHash, err)
 				}
 				mu.Unlock()
+				return
 			}
-		}(batchIdx, batch)
-	}
 
-	wg.Wait()
+			mu.Lock()
+			tracesMap[txHash] = &TraceResultOptional{
+				TxHash: txHash,
+				Result: &trace,
+			}
+			mu.Unlock()
+		}
+	})
 
 	if txBatchErr != nil {
 		return nil, txBatchErr
@@ -0,0 +1,147 @@
+package evmrpc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"icicle/pkg/rpcerr"
+)
+
+// PartialRetries is how many times StreamBlockRange refetches the failed blocks of a chunk before
+// giving up on it
+const PartialRetries = 3
+
+// FailedBlock is a block of a range that could not be fetched
+type FailedBlock struct {
+	Height int64
+	Err    error
+}
+
+// PartialError lists the blocks of a range that could not be fetched while the others were
+type PartialError struct {
+	Failed []FailedBlock // In ascending height order
+}
+
+func (e *PartialError) Error() string {
+	first := e.Failed[0]
+	if len(e.Failed) == 1 {
+		return fmt.Sprintf("failed to fetch block %d: %v", first.Height, first.Err)
+	}
+	return fmt.Sprintf("failed to fetch %d blocks, first %d: %v", len(e.Failed), first.Height, first.Err)
+}
+
+func (e *PartialError) Unwrap() error { return e.Failed[0].Err }
+
+// Heights returns the heights of the failed blocks in ascending order
+func (e *PartialError) Heights() []int64 {
+	heights := make([]int64, len(e.Failed))
+	for i, failed := range e.Failed {
+		heights[i] = failed.Height
+	}
+	return heights
+}
+
+// FetchBlockRangePartial fetches the blocks in [from, to] like FetchBlockRange, but an
+// unrecoverable block error doesn't abort the range: a failed range is split in halves down to
+// single blocks, so every block that can be fetched is. Returns the blocks in order with nil at
+// failed heights, and a *PartialError listing them when any failed. An error no block of the range
+// can escape, see isBlockError, is returned as is.
+func (f *Fetcher) FetchBlockRangePartial(from, to int64) ([]*NormalizedBlock, error) {
+	if from > to {
+		return nil, fmt.Errorf("invalid range: from %d > to %d", from, to)
+	}
+	blocks := make([]*NormalizedBlock, to-from+1)
+	var failed []FailedBlock
+	if err := f.fetchPartial(from, to, blocks, &failed); err != nil {
+		return nil, err
+	}
+	if len(failed) > 0 {
+		return blocks, &PartialError{Failed: failed}
+	}
+	return blocks, nil
+}
+
+// FetchBlocks fetches the blocks at heights, in ascending order, contiguous heights in one range.
+// Returns the fetched blocks by height, and a *PartialError listing the others when any failed.
+// An error no block can escape, see isBlockError, stops the fetch and is returned as is.
+func (f *Fetcher) FetchBlocks(heights []int64) (map[int64]*NormalizedBlock, error) {
+	fetched := make(map[int64]*NormalizedBlock, len(heights))
+	var failed []FailedBlock
+	for _, r := range f.findContiguousRanges(heights) {
+		blocks := make([]*NormalizedBlock, r[1]-r[0]+1)
+		err := f.fetchPartial(r[0], r[1], blocks, &failed)
+		for i, block := range blocks {
+			if block != nil {
+				fetched[r[0]+int64(i)] = block
+			}
+		}
+		if err != nil {
+			return fetched, err
+		}
+	}
+	if len(failed) > 0 {
+		return fetched, &PartialError{Failed: failed}
+	}
+	return fetched, nil
+}
+
+// fetchPartial fetches [from, to] into blocks, splitting the range in halves when a block error
+// fails it and recording the single blocks that still fail. Returns any other error at once.
+func (f *Fetcher) fetchPartial(from, to int64, blocks []*NormalizedBlock, failed *[]FailedBlock) error {
+	fetched, err := f.FetchBlockRange(from, to)
+	if err == nil {
+		copy(blocks, fetched)
+		return nil
+	}
+	if !f.isBlockError(err) {
+		return err
+	}
+	if from == to {
+		*failed = append(*failed, FailedBlock{Height: from, Err: err})
+		return nil
+	}
+	mid := from + (to-from)/2
+	f.logger.Warn("Failed to fetch range, splitting it", "from", from, "to", to, "error", err)
+	if err := f.fetchPartial(from, mid, blocks[:mid-from+1], failed); err != nil {
+		return err
+	}
+	return f.fetchPartial(mid+1, to, blocks[mid-from+1:], failed)
+}
+
+// isBlockError reports whether err may come from some blocks of a range only, e.g. a node error
+// replying to one block's call, so splitting the range fetches the others. Transport failures, an
+// open breaker and permanent errors fail every block alike, splitting would only multiply them.
+func (f *Fetcher) isBlockError(err error) bool {
+	var exhausted *exhaustedError
+	if errors.As(err, &exhausted) || rpcerr.IsPermanent(err) {
+		return false
+	}
+	return !f.breaker.IsOpen() && !f.traceBreaker.IsOpen()
+}
+
+// fetchChunk fetches the blocks in [from, to] and refetches only the failed ones, up to
+// PartialRetries times with growing delays
+func (f *Fetcher) fetchChunk(from, to int64) ([]*NormalizedBlock, error) {
+	blocks, err := f.FetchBlockRangePartial(from, to)
+	for attempt := 0; attempt < PartialRetries; attempt++ {
+		partial, ok := err.(*PartialError)
+		if !ok {
+			break
+		}
+		delay := f.retryDelay << attempt
+		f.logger.Warn("Blocks failed to fetch, retrying them", "blocks", len(partial.Failed),
+			"first", partial.Failed[0].Height, "attempt", attempt+1, "delay", delay, "error", partial.Failed[0].Err)
+		time.Sleep(delay)
+
+		var fetched map[int64]*NormalizedBlock
+		fetched, err = f.FetchBlocks(partial.Heights())
+		for height, block := range fetched {
+			blocks[height-from] = block
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
@@ -0,0 +1,168 @@
+package evmrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"icicle/pkg/breaker"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testNode serves eth_getBlockByNumber for empty blocks. Heights in failing reply with an error
+// of that code for their first fails calls, or always when fails is 0.
+type testNode struct {
+	status   int // Non-zero answers every request with this HTTP status
+	failing  map[int64]int
+	code     int
+	fails    int
+	requests atomic.Int64
+
+	mu    sync.Mutex
+	calls map[int64]int
+}
+
+func (n *testNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.requests.Add(1)
+	if n.status != 0 {
+		w.WriteHeader(n.status)
+		return
+	}
+	var requests []jsonRpcRequest
+	if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	responses := make([]jsonRpcResponse, len(requests))
+	for i, req := range requests {
+		height, _ := strconv.ParseInt(strings.TrimPrefix(req.Params[0].(string), "0x"), 16, 64)
+		n.calls[height]++
+		responses[i] = jsonRpcResponse{Jsonrpc: "2.0", ID: req.ID}
+		if _, ok := n.failing[height]; ok && (n.fails == 0 || n.calls[height] <= n.fails) {
+			responses[i].Error = &jsonRpcError{Code: n.code, Message: "missing trie node"}
+			continue
+		}
+		responses[i].Result = json.RawMessage(fmt.Sprintf(`{"number":"0x%x","transactions":[]}`, height))
+	}
+	json.NewEncoder(w).Encode(responses)
+}
+
+// newTestFetcher returns a fetcher of node without circuit breaking
+func newTestFetcher(t *testing.T, node *testNode) *Fetcher {
+	node.calls = make(map[int64]int)
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+
+	breaker.Configure(0, 0)
+	t.Cleanup(func() { breaker.Configure(10, 30*time.Second) })
+	fetcher := NewFetcher(FetcherOptions{RpcURL: server.URL, MaxRetries: 1, RetryDelay: time.Millisecond})
+	t.Cleanup(fetcher.Close)
+	return fetcher
+}
+
+func TestFetchBlockRangePartial(t *testing.T) {
+	tests := []struct {
+		name     string
+		node     *testNode
+		failed   []int64 // Heights of a *PartialError
+		fatal    bool    // Fails the whole range without a *PartialError
+		requests int64
+	}{
+		{
+			name:     "all fetched",
+			node:     &testNode{},
+			requests: 1,
+		},
+		{
+			name:     "block errors split down to the failed blocks",
+			node:     &testNode{failing: map[int64]int{3: 0, 6: 0}, code: -32000},
+			failed:   []int64{3, 6},
+			requests: 11,
+		},
+		{
+			name:     "transport errors aren't split",
+			node:     &testNode{status: http.StatusServiceUnavailable},
+			fatal:    true,
+			requests: 2,
+		},
+		{
+			name:     "permanent errors aren't split",
+			node:     &testNode{failing: map[int64]int{3: 0}, code: -32602},
+			fatal:    true,
+			requests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := newTestFetcher(t, tt.node)
+			blocks, err := fetcher.FetchBlockRangePartial(1, 8)
+			require.Equal(t, tt.requests, tt.node.requests.Load())
+
+			var partial *PartialError
+			switch {
+			case tt.fatal:
+				require.Error(t, err)
+				require.False(t, errors.As(err, &partial))
+				require.Nil(t, blocks)
+			case len(tt.failed) > 0:
+				require.ErrorAs(t, err, &partial)
+				require.Equal(t, tt.failed, partial.Heights())
+			default:
+				require.NoError(t, err)
+			}
+
+			if !tt.fatal {
+				require.Len(t, blocks, 8)
+				for i, block := range blocks {
+					height := int64(i) + 1
+					_, failed := tt.node.failing[height]
+					require.Equal(t, failed, block == nil, "block %d", height)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchChunkRetriesFailedBlocks(t *testing.T) {
+	tests := []struct {
+		name  string
+		fails int // Splitting the chunk calls the failed block 4 times before the first retry
+		ok    bool
+		calls int
+	}{
+		{"recovers on retry", 5, true, 6},
+		{"gives up after PartialRetries", 0, false, 4 + PartialRetries},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &testNode{failing: map[int64]int{5: 0}, code: -32000, fails: tt.fails}
+			fetcher := newTestFetcher(t, node)
+
+			blocks, err := fetcher.fetchChunk(1, 8)
+			require.Equal(t, tt.calls, node.calls[5])
+			if !tt.ok {
+				var partial *PartialError
+				require.ErrorAs(t, err, &partial)
+				require.Equal(t, []int64{5}, partial.Heights())
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, blocks, 8)
+			for _, block := range blocks {
+				require.NotNil(t, block)
+			}
+		})
+	}
+}
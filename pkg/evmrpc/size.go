@@ -0,0 +1,44 @@
+package evmrpc
+
+// Rough in-memory bytes of the fixed-size fields of each part of a block, on top of its
+// variable-length data
+const (
+	blockOverhead   = 2048
+	txOverhead      = 1024
+	receiptOverhead = 1024
+	logOverhead     = 512
+	traceOverhead   = 256
+)
+
+// MemorySize estimates the bytes a block takes in memory, dominated on busy chains by tx input,
+// log data and the input and output of traces
+func (b *NormalizedBlock) MemorySize() int64 {
+	size := int64(blockOverhead)
+	for _, tx := range b.Block.Transactions {
+		size += txOverhead + int64(len(tx.Input)+len(tx.AccessList))
+	}
+	for _, receipt := range b.Receipts {
+		size += receiptOverhead
+		for _, log := range receipt.Logs {
+			size += logOverhead + int64(len(log.Data))
+		}
+	}
+	for _, trace := range b.Traces {
+		if trace.Result != nil {
+			size += traceSize(trace.Result)
+		}
+	}
+	for _, uncle := range b.Uncles {
+		size += blockOverhead + int64(len(uncle.ExtraData))
+	}
+	return size + int64(len(b.Block.ExtraData)+len(b.Block.BlockExtraData)+len(b.Block.LogsBloom))
+}
+
+// traceSize estimates the bytes of a call trace and its subcalls
+func traceSize(call *CallTrace) int64 {
+	size := traceOverhead + int64(len(call.Input)+len(call.Output))
+	for i := range call.Calls {
+		size += traceSize(&call.Calls[i])
+	}
+	return size
+}
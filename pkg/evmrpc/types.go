@@ -24,6 +24,27 @@ type Transaction struct {
 	MaxFeePerGas         string          `json:"maxFeePerGas,omitempty"`
 	MaxPriorityFeePerGas string          `json:"maxPriorityFeePerGas,omitempty"`
 	AccessList           json.RawMessage `json:"accessList,omitempty"`
+	MaxFeePerBlobGas     string          `json:"maxFeePerBlobGas,omitempty"`    // EIP-4844 only
+	BlobVersionedHashes  []string        `json:"blobVersionedHashes,omitempty"` // EIP-4844 only
+	SourceHash           string          `json:"sourceHash,omitempty"`          // OP Stack deposit (0x7e) only
+	Mint                 string          `json:"mint,omitempty"`                // OP Stack deposit only, ETH minted on L2
+	IsSystemTx           bool            `json:"isSystemTx,omitempty"`          // OP Stack deposit only
+
+	// Arbitrum retryable, deposit and internal tx fields (types 0x64-0x6a), decoded so strict
+	// parsing accepts them but not stored
+	RequestId           string `json:"requestId,omitempty"`
+	RefundTo            string `json:"refundTo,omitempty"`
+	L1BaseFee           string `json:"l1BaseFee,omitempty"`
+	DepositValue        string `json:"depositValue,omitempty"`
+	RetryTo             string `json:"retryTo,omitempty"`
+	RetryValue          string `json:"retryValue,omitempty"`
+	RetryData           string `json:"retryData,omitempty"`
+	Beneficiary         string `json:"beneficiary,omitempty"`
+	MaxSubmissionFee    string `json:"maxSubmissionFee,omitempty"`
+	TicketId            string `json:"ticketId,omitempty"`
+	MaxRefund           string `json:"maxRefund,omitempty"`
+	SubmissionFeeRefund string `json:"submissionFeeRefund,omitempty"`
+	Timeboosted         *bool  `json:"timeboosted,omitempty"`
 }
 
 type Block struct {
@@ -57,6 +78,19 @@ type Block struct {
 	ParentBeaconBlockRoot string        `json:"parentBeaconBlockRoot,omitempty"`
 	MinDelayExcess        string        `json:"minDelayExcess,omitempty"`
 	TimestampMilliseconds string        `json:"timestampMilliseconds,omitempty"`
+	L1BlockNumber         string        `json:"l1BlockNumber,omitempty"` // Arbitrum only
+	SendCount             string        `json:"sendCount,omitempty"`     // Arbitrum only
+	SendRoot              string        `json:"sendRoot,omitempty"`      // Arbitrum only
+	Withdrawals           []Withdrawal  `json:"withdrawals,omitempty"`   // EIP-4895, post-Shanghai only
+	WithdrawalsRoot       string        `json:"withdrawalsRoot,omitempty"`
+}
+
+// Withdrawal is an EIP-4895 validator withdrawal pushed to the execution layer
+type Withdrawal struct {
+	Index          string `json:"index"`
+	ValidatorIndex string `json:"validatorIndex"`
+	Address        string `json:"address"`
+	Amount         string `json:"amount"` // Gwei
 }
 
 type CallTrace struct {
@@ -71,6 +105,10 @@ type CallTrace struct {
 	Calls        []CallTrace `json:"calls,omitempty"`
 	Value        string      `json:"value,omitempty"`
 	Type         string      `json:"type"`
+
+	// Arbitrum callTracer extras on the top-level frame, not stored
+	BeforeEVMTransfers json.RawMessage `json:"beforeEVMTransfers,omitempty"`
+	AfterEVMTransfers  json.RawMessage `json:"afterEVMTransfers,omitempty"`
 }
 
 type TraceResultOptional struct {
@@ -93,6 +131,26 @@ type Receipt struct {
 	TransactionHash   string  `json:"transactionHash"`
 	TransactionIndex  string  `json:"transactionIndex"`
 	Type              string  `json:"type"`
+	BlobGasUsed       string  `json:"blobGasUsed,omitempty"`  // EIP-4844 only
+	BlobGasPrice      string  `json:"blobGasPrice,omitempty"` // EIP-4844 only
+
+	// OP Stack fields, decoded so strict parsing accepts OP receipts but not stored
+	DepositNonce          string `json:"depositNonce,omitempty"`
+	DepositReceiptVersion string `json:"depositReceiptVersion,omitempty"`
+	L1Fee                 string `json:"l1Fee,omitempty"`
+	L1GasPrice            string `json:"l1GasPrice,omitempty"`
+	L1GasUsed             string `json:"l1GasUsed,omitempty"`
+	L1FeeScalar           string `json:"l1FeeScalar,omitempty"`
+	L1BaseFeeScalar       string `json:"l1BaseFeeScalar,omitempty"`
+	L1BlobBaseFee         string `json:"l1BlobBaseFee,omitempty"`
+	L1BlobBaseFeeScalar   string `json:"l1BlobBaseFeeScalar,omitempty"`
+	OperatorFeeScalar     string `json:"operatorFeeScalar,omitempty"`
+	OperatorFeeConstant   string `json:"operatorFeeConstant,omitempty"`
+
+	// Arbitrum fields, not stored
+	GasUsedForL1  string `json:"gasUsedForL1,omitempty"`
+	L1BlockNumber string `json:"l1BlockNumber,omitempty"`
+	Timeboosted   *bool  `json:"timeboosted,omitempty"`
 }
 
 type Log struct {
@@ -111,6 +169,8 @@ type NormalizedBlock struct {
 	Block    Block                 `json:"block"`
 	Traces   []TraceResultOptional `json:"traces"`
 	Receipts []Receipt             `json:"receipts"`
+	Uncles   []Block               `json:"uncles,omitempty"`   // Uncle headers, only fetched with FetchUncles
+	Proposer string                `json:"proposer,omitempty"` // ProposerVM NodeID, set by the syncer when an index URL is configured
 }
 
 type jsonRpcRequest struct {
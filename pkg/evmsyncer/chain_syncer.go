@@ -1,40 +1,106 @@
 package evmsyncer
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"icicle/pkg/batchsize"
 	"icicle/pkg/cache"
 	"icicle/pkg/chwrapper"
 	"icicle/pkg/evmindexer"
 	"icicle/pkg/evmrpc"
-	"context"
-	"fmt"
-	"log"
+	"icicle/pkg/firehose"
+	"icicle/pkg/loadshed"
+	"icicle/pkg/logging"
+	"icicle/pkg/membudget"
+	"icicle/pkg/proposervm"
+	"icicle/pkg/spool"
+	"icicle/pkg/tracing"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
-	"golang.org/x/sync/errgroup"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("evmsyncer")
+
 const (
 	// BufferSize is the maximum number of batches that can be buffered in the channel
 	BufferSize = 200_000
 	// FlushInterval is how often to flush blocks to ClickHouse
 	FlushInterval = 1 * time.Second
+	// ControlPollInterval is how often to check chain_control for pause/resume requests
+	ControlPollInterval = 2 * time.Second
+	// DegradedDivisor divides fetch batch size and RPC concurrency in degraded mode
+	DegradedDivisor = 4
+	// WriteRetries is how often a failed batch write is retried before the syncer gives up
+	WriteRetries = 5
+	// DefaultStreamBatchSize is how many blocks are handed to the writer at once
+	DefaultStreamBatchSize = 100
+	// DefaultMemoryBudget is how many bytes of fetched blocks may wait to be written
+	DefaultMemoryBudget = 1 << 30
+	// DefaultFetchBatchBytes is how many bytes of blocks a tuned fetch batch aims for
+	DefaultFetchBatchBytes = 64 << 20
 )
 
 // Config holds configuration for ChainSyncer
 type Config struct {
-	ChainID        uint32
-	RpcURL         string
-	StartBlock     int64        // Starting block number when no watermark exists, default 68000000
-	MaxConcurrency int          // Maximum concurrent RPC and debug requests, default 20
-	FetchBatchSize int          // Blocks per fetch, default 100
-	RpcBatchSize   int          // RPC calls per HTTP request, default 100
-	DebugBatchSize int          // Debug/trace calls per HTTP request, default 15
-	CHConn         driver.Conn  // ClickHouse connection
-	Cache          *cache.Cache // Cache for RPC calls
-	Name           string       // Chain name for display and tracking
-	Fast           bool         // Fast mode - skip all indexers
+	ChainID         uint32
+	RpcURL          string
+	TraceRpcURL     string            // Endpoint for debug/trace calls, e.g. an archival node (default: RpcURL)
+	RpcHeaders      map[string]string // Extra HTTP headers sent with every RPC request, e.g. API keys
+	NoCompression   bool              // Don't ask for compressed RPC responses
+	StartBlock      int64             // Starting block number when no watermark exists, default 68000000
+	MaxConcurrency  int               // Maximum concurrent RPC and debug requests, default 20
+	FetchBatchSize  int               // Blocks per fetch, default 100
+	StreamBatchSize int               // Blocks of a fetch handed to the writer at once, default DefaultStreamBatchSize
+	MemoryBudget    int64             // Bytes of fetched blocks not yet written, default DefaultMemoryBudget (negative disables)
+	RpcBatchSize    int               // RPC calls per HTTP request, default 100
+	DebugBatchSize  int               // Debug/trace calls per HTTP request, default 15
+	CHConn          driver.Conn       // ClickHouse connection, unused with Sink
+	Sink            Sink              // Storage other than ClickHouse, forces Fast mode (nil writes to CHConn)
+	Cache           cache.Cache       // Cache for RPC calls
+	CacheTTLs       cache.TTLs        // Cache namespaces to use besides complete blocks
+	Name            string            // Chain name for display and tracking
+	Fast            bool              // Fast mode - skip all indexers
+	FeeAsset        string            // Token fees are paid in, default "AVAX"
+	IndexerWorkers  int               // Indexers run at once, default evmindexer.DefaultWorkers
+	Timezone        string            // Time zone metric periods start in, default the deployment's
+	Recompute       int               // Closed metric periods rerun with every new one, for late blocks
+	IndexerSettings map[string]string // ClickHouse settings of indexer queries, e.g. max_memory_usage
+	InsertSettings  map[string]string // ClickHouse settings of block inserts, e.g. async_insert
+	IndexURL        string            // Index API endpoint for block proposer attribution (empty disables)
+	FetchUncles     bool              // Fetch uncle headers into raw_uncles
+	SkipTraces      bool              // Never fetch traces, for RPCs without debug APIs
+	SkipLogs        bool              // Don't write raw_logs or the tables decoded from logs
+	StorePayloads   bool              // Also write each block's cache payload to raw_payloads
+	Offline         bool              // Ingest only blocks imported into Cache, up to its checkpoint, without RPC
+
+	// Gap healing
+	GapCheckInterval time.Duration // How often to look for and re-ingest blocks missing below the watermark (0 disables)
+
+	// Startup
+	SkipIntegrityCheck bool // Only check blocks written after startup for gaps, not the history below the watermark
+	SkipChainIDCheck   bool // Ingest even if the RPC reports another chain ID than ChainID
+
+	// Confirmation depth
+	FollowDistance int64  // Blocks to stay behind the RPC tip
+	FollowTag      string // Block tag to ingest up to instead of the tip, e.g. "finalized" (empty follows the tip)
+	HeadTable      bool   // Write the blocks above the followed block to raw_head_blocks
+
+	// Adaptive batch sizing, from the size of the blocks fetched so far
+	MaxFetchBatchSize int   // Most blocks per fetch, 0 keeps FetchBatchSize fixed
+	FetchBatchBytes   int64 // Bytes of blocks a fetch aims for (default: DefaultFetchBatchBytes)
+
+	// Outage spool, batches that can't be written are queued on disk and replayed in order
+	SpoolDir      string // Directory of the chain's spool (empty disables, writes failing for good stop the syncer)
+	SpoolMaxBytes int64  // Most compressed bytes spooled, fetching waits above it (0 is unlimited)
+
+	// Load shedding
+	LoadShedder *loadshed.Monitor // Resource budget monitor, nil disables load shedding
 }
 
 // ChainSyncer manages blockchain sync for a single chain
@@ -44,16 +110,52 @@ type ChainSyncer struct {
 	fetcher        *evmrpc.Fetcher
 	conn           driver.Conn
 	blockChan      chan []*evmrpc.NormalizedBlock // Bounded channel for backpressure
+	pauseChan      chan chan struct{}             // Asks the writer to drop buffered blocks and go idle
 	watermark      uint32                         // Current sync position
 	startBlock     int64                          // Starting block when no watermark
 	fetchBatchSize int
 	flushInterval  time.Duration
+	proposers      *proposervm.Client // nil when proposer attribution is disabled
+	maxConcurrency int
+	logger         *slog.Logger
+
+	// Streaming, fetches go to the writer in sub-batches and wait while budget is used up
+	streamBatchSize int
+	budget          *membudget.Budget // nil when unlimited
+	batchTuner      *batchsize.Tuner  // nil when batch sizes are fixed
+
+	// Load shedding
+	loadShedder     *loadshed.Monitor
+	degradedReason  string // Current degraded mode reason, only used by the fetcher goroutine
+	tracesSkippedAt int64  // First block fetched without traces in the current degraded period
+
+	skipTraces bool // Traces are off for this chain regardless of load shedding
+	skipLogs   bool // Log-derived tables are not written for this chain
+
+	storePayloads bool // Block payloads are written to raw_payloads for cache hydrate
+
+	// Gap healing, only used by the writer goroutine
+	gapCheckInterval time.Duration
+	gapsCheckedTo    uint32 // raw_blocks has no gaps up to this block
+	skipHistory      bool   // Gap checks start at the watermark loaded on startup
+
+	checkChainID bool // Start fails if the RPC reports another chain ID
+
+	// Outage spool, only used by the writer goroutine
+	spool        *spool.Spool // nil when disabled
+	spoolRetryAt time.Time    // Replays of spooled batches wait until then after a failure
+	spoolFull    bool         // The last batch didn't fit in the spool
+
+	// Confirmation depth, only used by the fetcher goroutine
+	followDistance int64
+	followTag      string
+	headTable      bool
+	rpcLatest      int64 // RPC tip at the last poll
+	headWrittenTo  int64 // Highest RPC tip written to raw_head_blocks
 
-	// Max block numbers in each table (queried once at startup)
-	maxBlockBlocks       uint32
-	maxBlockTransactions uint32
-	maxBlockTraces       uint32
-	maxBlockLogs         uint32
+	// Storage, clickhouse is nil when blocks go to another sink
+	sink       Sink
+	clickhouse *clickhouseSink
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -88,18 +190,49 @@ func NewChainSyncer(cfg Config) (*ChainSyncer, error) {
 	if cfg.DebugBatchSize == 0 {
 		cfg.DebugBatchSize = 15 // Default: batch 15 trace calls per HTTP request
 	}
+	if cfg.FeeAsset == "" {
+		cfg.FeeAsset = "AVAX"
+	}
+	if cfg.StreamBatchSize == 0 {
+		cfg.StreamBatchSize = DefaultStreamBatchSize
+	}
+	if cfg.MemoryBudget == 0 {
+		cfg.MemoryBudget = DefaultMemoryBudget
+	}
+	if cfg.FetchBatchBytes == 0 {
+		cfg.FetchBatchBytes = DefaultFetchBatchBytes
+	}
+	if cfg.Sink != nil {
+		// Indexers, gap healing and the head table query ClickHouse
+		cfg.Fast = true
+		cfg.GapCheckInterval = 0
+		cfg.HeadTable = false
+	}
 
 	// Create fetcher
 	fetcher := evmrpc.NewFetcher(evmrpc.FetcherOptions{
 		RpcURL:         cfg.RpcURL,
+		TraceRpcURL:    cfg.TraceRpcURL,
+		RpcHeaders:     cfg.RpcHeaders,
+		NoCompression:  cfg.NoCompression,
+		ChainID:        cfg.ChainID,
+		ChainName:      cfg.Name,
 		MaxConcurrency: cfg.MaxConcurrency,
 		MaxRetries:     100,
 		RetryDelay:     100 * time.Millisecond,
 		BatchSize:      cfg.RpcBatchSize,
 		DebugBatchSize: cfg.DebugBatchSize,
 		Cache:          cfg.Cache,
+		CacheTTLs:      cfg.CacheTTLs,
+		FetchUncles:    cfg.FetchUncles,
+		Offline:        cfg.Offline,
 	})
 
+	batchSpool, err := spool.Open(cfg.SpoolDir, cfg.SpoolMaxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool: %w", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	cs := &ChainSyncer{
@@ -108,83 +241,151 @@ func NewChainSyncer(cfg Config) (*ChainSyncer, error) {
 		fetcher:        fetcher,
 		conn:           cfg.CHConn,
 		blockChan:      make(chan []*evmrpc.NormalizedBlock, BufferSize),
+		pauseChan:      make(chan chan struct{}),
 		startBlock:     cfg.StartBlock,
 		fetchBatchSize: cfg.FetchBatchSize,
 		flushInterval:  FlushInterval,
+		maxConcurrency: cfg.MaxConcurrency,
+		logger:         logging.Chain("evmsyncer", cfg.ChainID, cfg.Name),
+		loadShedder:    cfg.LoadShedder,
 		ctx:            ctx,
 		cancel:         cancel,
 		lastPrintTime:  time.Now(),
 		startTime:      time.Now(),
 		fast:           cfg.Fast,
+		skipTraces:     cfg.SkipTraces,
+		skipLogs:       cfg.SkipLogs,
+		storePayloads:  cfg.StorePayloads,
+
+		streamBatchSize: cfg.StreamBatchSize,
+		budget:          membudget.New(cfg.MemoryBudget),
+		batchTuner:      batchsize.New(cfg.FetchBatchBytes, cfg.MaxFetchBatchSize),
+
+		gapCheckInterval: cfg.GapCheckInterval,
+		skipHistory:      cfg.SkipIntegrityCheck,
+
+		checkChainID: !cfg.SkipChainIDCheck && !cfg.Offline,
+
+		spool: batchSpool,
+
+		followDistance: cfg.FollowDistance,
+		followTag:      cfg.FollowTag,
+		headTable:      cfg.HeadTable,
+
+		sink: cfg.Sink,
+	}
+	if cs.sink == nil {
+		cs.clickhouse = &clickhouseSink{
+			conn:          cfg.CHConn,
+			chainID:       cfg.ChainID,
+			logger:        cs.logger,
+			skipLogs:      cfg.SkipLogs,
+			storePayloads: cfg.StorePayloads,
+
+			insertSettings: cfg.InsertSettings,
+		}
+		cs.sink = cs.clickhouse
+	}
+	fetcher.SetTracesEnabled(!cfg.SkipTraces)
+
+	if cfg.IndexURL != "" {
+		cs.proposers = proposervm.NewClient(cfg.IndexURL, evmrpc.RLPBlockHeight)
 	}
 
 	// Initialize indexer runner - one per chain (skip in fast mode)
 	if !cfg.Fast {
-		indexerRunner, err := evmindexer.NewIndexRunner(cfg.ChainID, cfg.CHConn, "sql", uint64(cfg.StartBlock))
+		indexerRunner, err := evmindexer.NewIndexRunner(cfg.ChainID, cfg.CHConn, evmindexer.Options{
+			StartBlock:    uint64(cfg.StartBlock),
+			FeeAsset:      cfg.FeeAsset,
+			Workers:       cfg.IndexerWorkers,
+			Timezone:      cfg.Timezone,
+			Recompute:     cfg.Recompute,
+			QuerySettings: cfg.IndexerSettings,
+		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to create indexer runner: %w", err)
 		}
 		cs.indexerRunner = indexerRunner
-		log.Printf("[Chain %d] Indexer runner initialized", cfg.ChainID)
+		cs.logger.Info("Indexer runner initialized")
 	} else {
-		log.Printf("[Chain %d] Fast mode - indexers disabled", cfg.ChainID)
+		cs.logger.Info("Fast mode - indexers disabled")
 	}
 
 	return cs, nil
 }
 
+// verifyChainID returns an error if the RPC reports another chain ID than the configured one
+func (cs *ChainSyncer) verifyChainID() error {
+	chainID, err := cs.fetcher.GetChainID()
+	if err != nil {
+		return fmt.Errorf("failed to get chain ID: %w", err)
+	}
+	if chainID != uint64(cs.chainId) {
+		return fmt.Errorf("RPC reports chain ID %d, not %d: check rpcURL, or set skipChainIDCheck to ingest anyway", chainID, cs.chainId)
+	}
+	return nil
+}
+
 // Start begins syncing
 func (cs *ChainSyncer) Start() error {
-	log.Printf("[Chain %d] Starting syncer...", cs.chainId)
+	cs.logger.Info("Starting syncer")
 
-	// Get starting position
-	startBlock, err := cs.getStartingBlock()
-	if err != nil {
-		return fmt.Errorf("failed to determine starting block: %w", err)
+	if cs.clickhouse != nil {
+		if err := chwrapper.CheckInsertSettings(cs.conn, cs.clickhouse.insertSettings); err != nil {
+			return err
+		}
+		if chwrapper.UnconfirmedAsyncInserts(cs.clickhouse.insertSettings) {
+			cs.logger.Warn("Async inserts aren't waited for, blocks lost in a failed flush stay missing until the gap check")
+		}
 	}
 
-	// Query max block for each table once at startup
-	// This is critical for preventing duplicates - we only insert blocks > maxBlock
-	cs.maxBlockBlocks, err = chwrapper.GetLatestBlockForChain(cs.conn, "raw_blocks", cs.chainId)
-	if err != nil {
-		return fmt.Errorf("failed to get max block from blocks table: %w", err)
+	// A config pointing at the wrong RPC would write another chain's blocks under this chain's ID
+	if cs.checkChainID {
+		if err := cs.verifyChainID(); err != nil {
+			return err
+		}
 	}
 
-	cs.maxBlockTransactions, err = chwrapper.GetLatestBlockForChain(cs.conn, "raw_txs", cs.chainId)
+	startBlock, err := cs.loadSyncState()
 	if err != nil {
-		return fmt.Errorf("failed to get max block from transactions table: %w", err)
+		return err
 	}
-
-	cs.maxBlockTraces, err = chwrapper.GetLatestBlockForChain(cs.conn, "raw_traces", cs.chainId)
-	if err != nil {
-		return fmt.Errorf("failed to get max block from traces table: %w", err)
+	if cs.skipHistory {
+		cs.gapsCheckedTo = cs.watermark
+		cs.logger.Info("Skipping gap checks below the watermark", "watermark", cs.watermark)
 	}
 
-	cs.maxBlockLogs, err = chwrapper.GetLatestBlockForChain(cs.conn, "raw_logs", cs.chainId)
-	if err != nil {
-		return fmt.Errorf("failed to get max block from logs table: %w", err)
+	// Batches spooled before the last shutdown go first, fetching resumes after them
+	if n := cs.spool.Len(); n > 0 {
+		cs.logger.Info("Replaying spooled batches", "batches", n, "bytes", cs.spool.Size())
+		if !cs.drainSpool() {
+			return fmt.Errorf("failed to replay %d spooled batches", cs.spool.Len())
+		}
+		startBlock = int64(cs.watermark) + 1
 	}
 
-	log.Printf("[Chain %d] Max blocks in tables - blocks: %d, txs: %d, traces: %d, logs: %d",
-		cs.chainId, cs.maxBlockBlocks, cs.maxBlockTransactions, cs.maxBlockTraces, cs.maxBlockLogs)
-	log.Printf("[Chain %d] Starting from block %d (watermark: %d)", cs.chainId, startBlock, cs.watermark)
-
 	// Get latest block from RPC
-	latestBlock, err := cs.fetcher.GetLatestBlock()
+	followedBlock, latestBlock, err := cs.getFollowedBlock()
 	if err != nil {
 		return fmt.Errorf("failed to get latest block: %w", err)
 	}
 
-	log.Printf("[Chain %d] Latest block on chain: %d", cs.chainId, latestBlock)
+	if followedBlock < latestBlock {
+		cs.logger.Info("Latest block on chain", "block", latestBlock, "followed_block", followedBlock)
+	} else {
+		cs.logger.Info("Latest block on chain", "block", latestBlock)
+	}
 
 	// Initialize chain status in database
-	if err := chwrapper.UpsertChainStatus(cs.conn, cs.chainId, cs.chainName, uint64(latestBlock)); err != nil {
-		return fmt.Errorf("failed to upsert chain status: %w", err)
+	if cs.clickhouse != nil {
+		if err := chwrapper.UpsertChainStatus(cs.conn, cs.chainId, cs.chainName, uint64(latestBlock), ""); err != nil {
+			return fmt.Errorf("failed to upsert chain status: %w", err)
+		}
 	}
 
 	// Start producer (fetcher) goroutine
 	cs.wg.Add(1)
-	go cs.fetcherLoop(startBlock, latestBlock)
+	go cs.fetcherLoop(startBlock, followedBlock)
 
 	// Start consumer (writer) goroutine
 	cs.wg.Add(1)
@@ -196,18 +397,7 @@ func (cs *ChainSyncer) Start() error {
 
 	// Start indexer loop (skip in fast mode)
 	if !cs.fast {
-		// Initialize indexer with latest known block if we have one
-		if cs.maxBlockBlocks > 0 {
-			// Query block time for the latest block
-			blockTime, err := cs.getBlockTime(cs.maxBlockBlocks)
-			if err != nil {
-				log.Printf("[Chain %d] Warning: failed to get block time for block %d: %v", cs.chainId, cs.maxBlockBlocks, err)
-				// Use current time as fallback
-				blockTime = time.Now().UTC()
-			}
-			cs.indexerRunner.OnBlock(uint64(cs.maxBlockBlocks), blockTime)
-			log.Printf("[Chain %d] Initialized indexer with block %d", cs.chainId, cs.maxBlockBlocks)
-		}
+		cs.initIndexerBlock()
 
 		cs.wg.Add(1)
 		go func() {
@@ -219,13 +409,50 @@ func (cs *ChainSyncer) Start() error {
 	return nil
 }
 
+// loadSyncState reads the watermark from the sink and returns the block to sync from
+func (cs *ChainSyncer) loadSyncState() (int64, error) {
+	watermark, err := cs.sink.Load(cs.ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to determine starting block: %w", err)
+	}
+	cs.watermark = watermark
+
+	// If no watermark, start from configured start block, else from watermark+1
+	startBlock := cs.startBlock
+	if watermark > 0 {
+		startBlock = int64(watermark + 1)
+	}
+
+	cs.logger.Info("Starting from block", "block", startBlock, "watermark", cs.watermark)
+
+	return startBlock, nil
+}
+
+// initIndexerBlock seeds the indexer runner with the latest block already in the database
+func (cs *ChainSyncer) initIndexerBlock() {
+	maxBlock := cs.clickhouse.maxBlockBlocks
+	if maxBlock == 0 {
+		return
+	}
+
+	// Query block time for the latest block
+	blockTime, err := cs.getBlockTime(maxBlock)
+	if err != nil {
+		cs.logger.Warn("Failed to get block time", "block", maxBlock, "error", err)
+		// Use current time as fallback
+		blockTime = time.Now().UTC()
+	}
+	cs.indexerRunner.OnBlock(uint64(maxBlock), blockTime)
+	cs.logger.Info("Initialized indexer", "block", maxBlock)
+}
+
 // Stop gracefully shuts down the syncer
 func (cs *ChainSyncer) Stop() {
-	log.Printf("[Chain %d] Stopping syncer...", cs.chainId)
+	cs.logger.Info("Stopping syncer")
 	cs.cancel()
 	close(cs.blockChan)
 	cs.wg.Wait()
-	log.Printf("[Chain %d] Syncer stopped", cs.chainId)
+	cs.logger.Info("Syncer stopped")
 }
 
 // Wait blocks until syncer completes
@@ -247,49 +474,54 @@ func (cs *ChainSyncer) getBlockTime(blockNum uint32) (time.Time, error) {
 	return blockTime, nil
 }
 
-// getStartingBlock determines where to start syncing from
-func (cs *ChainSyncer) getStartingBlock() (int64, error) {
-	// Get watermark
-	watermark, err := chwrapper.GetWatermark(cs.conn, cs.chainId)
-	if err != nil {
-		return 0, fmt.Errorf("failed to get watermark: %w", err)
-	}
-	cs.watermark = watermark
-
-	// If no watermark, start from configured start block
-	if watermark == 0 {
-		return cs.startBlock, nil
-	}
-
-	// Start from watermark+1
-	return int64(watermark + 1), nil
-}
-
 // fetcherLoop is the producer goroutine that fetches blocks
 func (cs *ChainSyncer) fetcherLoop(startBlock, latestBlock int64) {
 	defer cs.wg.Done()
 
 	currentBlock := startBlock
+	var lastControlCheck time.Time
 
 	for {
 		select {
 		case <-cs.ctx.Done():
 			return
 		default:
+			// Check for pause requests (e.g. from the resync command)
+			if cs.clickhouse != nil && time.Since(lastControlCheck) >= ControlPollInterval {
+				lastControlCheck = time.Now()
+				ctrl, err := chwrapper.GetChainControl(cs.conn, cs.chainId)
+				if err != nil {
+					cs.logger.Error("Error checking chain control", "error", err)
+				} else if ctrl.Paused {
+					resumeBlock, ok := cs.pauseUntilResumed(ctrl.Version)
+					if !ok {
+						return
+					}
+					currentBlock = resumeBlock
+					continue
+				}
+			}
+
 			// Check if we're caught up
 			if currentBlock > latestBlock {
 				// Poll for new blocks
 				time.Sleep(2 * time.Second)
 
-				newLatest, err := cs.fetcher.GetLatestBlock()
+				newLatest, rpcLatest, err := cs.getFollowedBlock()
 				if err != nil {
-					log.Printf("[Chain %d] Error getting latest block: %v", cs.chainId, err)
+					cs.logger.Error("Error getting latest block", "error", err)
 					continue
 				}
 
 				// Update chain status with latest block from RPC
-				if err := chwrapper.UpdateLatestBlock(cs.conn, cs.chainId, cs.chainName, uint64(newLatest)); err != nil {
-					log.Printf("[Chain %d] Error updating chain status: %v", cs.chainId, err)
+				if cs.clickhouse != nil {
+					if err := chwrapper.UpdateLatestBlock(cs.conn, cs.chainId, cs.chainName, uint64(rpcLatest), cs.degradedReason); err != nil {
+						cs.logger.Error("Error updating chain status", "error", err)
+					}
+				}
+
+				if cs.headTable {
+					cs.writeHeadBlocks(newLatest, rpcLatest)
 				}
 
 				if newLatest > latestBlock {
@@ -300,34 +532,203 @@ func (cs *ChainSyncer) fetcherLoop(startBlock, latestBlock int64) {
 			}
 
 			// Calculate batch range
-			endBlock := currentBlock + int64(cs.fetchBatchSize) - 1
+			batchSize, streamBatchSize := cs.batchSizes(cs.applyLoadShedding(currentBlock, cs.rpcLatest))
+			endBlock := currentBlock + int64(batchSize) - 1
 			if endBlock > latestBlock {
 				endBlock = latestBlock
 			}
 
-			// Fetch blocks
-			blocks, err := cs.fetcher.FetchBlockRange(currentBlock, endBlock)
+			// Fetch blocks, handing each sub-batch to the writer as soon as it arrives
+			// Sub-batches sent before an error are not fetched again
+			err := cs.fetcher.StreamBlockRange(currentBlock, endBlock, streamBatchSize, func(from, to int64, blocks []*evmrpc.NormalizedBlock) error {
+				if err := cs.sendBlocks(from, to, blocks); err != nil {
+					return err
+				}
+				currentBlock = to + 1
+				return nil
+			})
+			if cs.ctx.Err() != nil {
+				return
+			}
 			if err != nil {
-				log.Printf("[Chain %d] Error fetching blocks %d-%d: %v",
-					cs.chainId, currentBlock, endBlock, err)
+				cs.logger.Error("Error fetching blocks", "from", currentBlock, "to", endBlock, "error", err)
 				time.Sleep(1 * time.Second)
-				continue
 			}
+		}
+	}
+}
 
-			// Update fetched counter
-			cs.mu.Lock()
-			cs.blocksFetched += int64(len(blocks))
-			cs.mu.Unlock()
+// sendBlocks hands a fetched sub-batch [from, to] to the writer, then waits while the memory
+// budget is used up so fetching never runs far ahead of writing
+func (cs *ChainSyncer) sendBlocks(from, to int64, blocks []*evmrpc.NormalizedBlock) error {
+	cs.setProposers(blocks, from, to)
+
+	// Update fetched counter
+	cs.mu.Lock()
+	cs.blocksFetched += int64(len(blocks))
+	cs.mu.Unlock()
+
+	// Send to channel (will block if buffer is full - backpressure)
+	size := blocksSize(blocks)
+	cs.batchTuner.Observe(len(blocks), size)
+	cs.budget.Add(size)
+	select {
+	case cs.blockChan <- blocks:
+	case <-cs.ctx.Done():
+		return cs.ctx.Err()
+	}
 
-			// Send to channel (will block if buffer is full - backpressure)
-			select {
-			case cs.blockChan <- blocks:
-				currentBlock = endBlock + 1
-			case <-cs.ctx.Done():
-				return
+	if !cs.budget.Wait(cs.ctx) {
+		return cs.ctx.Err()
+	}
+	return nil
+}
+
+// blocksSize estimates the bytes blocks take in memory
+func blocksSize(blocks []*evmrpc.NormalizedBlock) int64 {
+	var size int64
+	for _, b := range blocks {
+		size += b.MemorySize()
+	}
+	return size
+}
+
+// batchSizes returns the fetch and stream batch sizes to use, tuned to the size of recent blocks
+// unless load shedding cut the fetch batch size
+func (cs *ChainSyncer) batchSizes(fetchBatchSize int) (int, int) {
+	if fetchBatchSize != cs.fetchBatchSize {
+		return fetchBatchSize, min(cs.streamBatchSize, fetchBatchSize)
+	}
+	return cs.batchTuner.Sizes(cs.fetchBatchSize, cs.streamBatchSize)
+}
+
+// applyLoadShedding switches the fetcher between normal and degraded mode when the load
+// shedder's state changes, and returns the fetch batch size to use. Degraded mode cuts
+// concurrency and batch size and stops fetching traces.
+func (cs *ChainSyncer) applyLoadShedding(currentBlock, latestBlock int64) int {
+	reason := cs.loadShedder.Reason()
+	if reason != cs.degradedReason {
+		if reason != "" {
+			cs.logger.Warn("Degraded mode: reducing concurrency and batch size, traces disabled",
+				"reason", reason, "from_block", currentBlock)
+			cs.fetcher.SetConcurrency(cs.maxConcurrency / DegradedDivisor)
+			cs.fetcher.SetTracesEnabled(false)
+			cs.tracesSkippedAt = currentBlock
+		} else {
+			if cs.skipTraces {
+				cs.logger.Info("Leaving degraded mode")
+			} else {
+				cs.logger.Info("Leaving degraded mode, blocks were written without traces, use resync to backfill them",
+					"from", cs.tracesSkippedAt, "to", currentBlock-1)
+			}
+			cs.fetcher.SetConcurrency(cs.maxConcurrency)
+			cs.fetcher.SetTracesEnabled(!cs.skipTraces)
+		}
+		cs.degradedReason = reason
+
+		if cs.clickhouse != nil {
+			if err := chwrapper.UpdateLatestBlock(cs.conn, cs.chainId, cs.chainName, uint64(latestBlock), reason); err != nil {
+				cs.logger.Error("Error updating chain status", "error", err)
 			}
 		}
 	}
+
+	if reason != "" {
+		return max(1, cs.fetchBatchSize/DegradedDivisor)
+	}
+	return cs.fetchBatchSize
+}
+
+// setProposers attributes blocks to their proposers. Failures only leave proposers empty.
+func (cs *ChainSyncer) setProposers(blocks []*evmrpc.NormalizedBlock, from, to int64) {
+	if cs.proposers == nil {
+		return
+	}
+
+	proposers, err := cs.proposers.GetProposers(cs.ctx, uint64(from), uint64(to))
+	if err != nil {
+		cs.logger.Warn("Failed to get proposers", "from", from, "to", to, "error", err)
+		return
+	}
+
+	for _, b := range blocks {
+		blockNum, err := hexToUint32(b.Block.Number)
+		if err != nil {
+			continue
+		}
+		b.Proposer = proposers[uint64(blockNum)]
+	}
+}
+
+// pauseUntilResumed stops writing and indexing, acknowledges the pause, and blocks until the chain
+// is resumed. Sync state is then reloaded from the database since it may have been rewound while
+// paused. Returns the block to continue fetching from, or false if the syncer is shutting down.
+func (cs *ChainSyncer) pauseUntilResumed(version uint64) (int64, bool) {
+	cs.logger.Info("Pause requested, stopping writer and indexers")
+
+	// Drop everything fetched but not yet written - it is refetched from the watermark on resume
+	done := make(chan struct{})
+	select {
+	case cs.pauseChan <- done:
+		<-done
+	case <-cs.ctx.Done():
+		return 0, false
+	}
+
+	if !cs.fast {
+		cs.indexerRunner.Pause()
+	}
+
+	if err := chwrapper.AckChainControl(cs.conn, cs.chainId, version, true); err != nil {
+		cs.logger.Error("Error acknowledging pause", "error", err)
+	}
+	cs.logger.Info("Paused")
+
+	for {
+		select {
+		case <-cs.ctx.Done():
+			return 0, false
+		case <-time.After(ControlPollInterval):
+		}
+
+		ctrl, err := chwrapper.GetChainControl(cs.conn, cs.chainId)
+		if err != nil {
+			cs.logger.Error("Error checking chain control", "error", err)
+			continue
+		}
+
+		if ctrl.Version == version {
+			continue
+		}
+		version = ctrl.Version
+
+		if ctrl.Paused {
+			// A newer pause request while already paused
+			if err := chwrapper.AckChainControl(cs.conn, cs.chainId, version, true); err != nil {
+				cs.logger.Error("Error acknowledging pause", "error", err)
+			}
+			continue
+		}
+
+		startBlock, err := cs.loadSyncState()
+		if err != nil {
+			cs.logger.Error("Error reloading sync state, staying paused", "error", err)
+			continue
+		}
+
+		if !cs.fast {
+			if err := cs.indexerRunner.Resume(); err != nil {
+				logging.Fatal(cs.logger, "Failed to resume indexers", "error", err)
+			}
+			cs.initIndexerBlock()
+		}
+
+		if err := chwrapper.AckChainControl(cs.conn, cs.chainId, version, false); err != nil {
+			cs.logger.Error("Error acknowledging resume", "error", err)
+		}
+		cs.logger.Info("Resumed", "block", startBlock)
+		return startBlock, true
+	}
 }
 
 // writerLoop is the consumer goroutine that writes to ClickHouse
@@ -335,10 +736,20 @@ func (cs *ChainSyncer) writerLoop() {
 	defer cs.wg.Done()
 
 	var buffer []*evmrpc.NormalizedBlock
+	var bufferSize int64 // Bytes of buffer counted against the memory budget
 	var lastFlushTime time.Time
 	flushTimer := time.NewTimer(cs.flushInterval)
 	defer flushTimer.Stop()
 
+	// Gaps are healed here so they never race the writes of the blocks around them
+	var gapTicker <-chan time.Time
+	if cs.gapCheckInterval > 0 {
+		ticker := time.NewTicker(cs.gapCheckInterval)
+		defer ticker.Stop()
+		gapTicker = ticker.C
+	}
+	paused := false
+
 	// flush writes buffered blocks and ensures minimum interval between writes
 	flush := func() time.Duration {
 		if len(buffer) == 0 {
@@ -346,23 +757,36 @@ func (cs *ChainSyncer) writerLoop() {
 		}
 
 		start := time.Now()
-		if err := cs.writeBlocks(buffer); err != nil {
+		spooled, err := cs.writeOrSpool(buffer)
+		if errors.Is(err, spool.ErrFull) {
+			// Held in memory until the spool drains, fetching waits on the memory budget
+			if !cs.spoolFull {
+				cs.logger.Warn("Spool is full, holding batches in memory", "bytes", cs.spool.Size())
+				cs.spoolFull = true
+			}
+			return cs.flushInterval
+		}
+		cs.spoolFull = false
+		if err != nil {
 			// Panic on database write failure to ensure consistency
 			// We cannot afford partial writes or inconsistent state
-			log.Fatalf("[Chain %d] FATAL: Database write failed, cannot continue: %v", cs.chainId, err)
+			logging.Fatal(cs.logger, "Database write failed, cannot continue", "error", err)
 		}
 
 		elapsed := time.Since(start)
 		if elapsed > 10*time.Second {
-			log.Printf("[Chain %d] WARNING: Write took %v, exceeds 10 second threshold",
-				cs.chainId, elapsed)
+			cs.logger.Warn("Write exceeded 10 second threshold", "elapsed", elapsed)
 		}
 
-		// Update counters and clear buffer
-		cs.mu.Lock()
-		cs.blocksWritten += int64(len(buffer))
-		cs.mu.Unlock()
+		// Update counters and clear buffer, spooled blocks are counted when replayed
+		if !spooled {
+			cs.mu.Lock()
+			cs.blocksWritten += int64(len(buffer))
+			cs.mu.Unlock()
+		}
 		buffer = nil
+		cs.budget.Release(bufferSize)
+		bufferSize = 0
 
 		// Calculate next flush time to maintain minimum interval
 		lastFlushTime = start
@@ -386,6 +810,8 @@ func (cs *ChainSyncer) writerLoop() {
 			}
 
 			buffer = append(buffer, blocks...)
+			bufferSize += blocksSize(blocks)
+			paused = false
 
 			// Flush immediately if interval has passed
 			if !lastFlushTime.IsZero() && time.Since(lastFlushTime) >= cs.flushInterval {
@@ -397,77 +823,111 @@ func (cs *ChainSyncer) writerLoop() {
 		case <-flushTimer.C:
 			nextInterval := flush()
 			flushTimer.Reset(nextInterval)
+
+		case <-gapTicker:
+			// A paused chain may be being resynced, its data is in flux
+			if !paused {
+				cs.healGaps()
+			}
+
+		case done := <-cs.pauseChan:
+			// The fetcher has stopped sending, so draining the channel empties it
+			for drained := false; !drained; {
+				select {
+				case blocks, ok := <-cs.blockChan:
+					bufferSize += blocksSize(blocks)
+					drained = !ok
+				default:
+					drained = true
+				}
+			}
+			buffer = nil
+			cs.budget.Release(bufferSize)
+			bufferSize = 0
+			cs.clearSpool()
+			paused = true
+			close(done)
 		}
 	}
 }
 
-// writeBlocks writes blocks to all tables in parallel and updates watermark
+// writeBlocksWithRetry writes blocks, retrying failed writes with backoff. Retries reuse the
+// deduplication tokens of the failed write, so inserts that landed before the failure are skipped.
+func (cs *ChainSyncer) writeBlocksWithRetry(blocks []*evmrpc.NormalizedBlock) error {
+	for attempt := 0; ; attempt++ {
+		err := cs.writeBlocks(blocks)
+		if err == nil || attempt == WriteRetries {
+			return err
+		}
+
+		delay := min(time.Second<<attempt, 30*time.Second)
+		cs.logger.Warn("Database write failed, retrying", "attempt", attempt+1, "delay", delay, "error", err)
+		select {
+		case <-cs.ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// blockRange returns the lowest and highest block number of blocks
+func blockRange(blocks []*evmrpc.NormalizedBlock) (from, to uint32) {
+	found := false
+	for _, b := range blocks {
+		blockNum, err := hexToUint32(b.Block.Number)
+		if err != nil {
+			continue
+		}
+		if !found || blockNum < from {
+			from = blockNum
+		}
+		to = max(to, blockNum)
+		found = true
+	}
+	return from, to
+}
+
+// writeBlocks writes blocks to the sink and updates watermark
 // Duplicate prevention strategy:
 // 1. Start from watermark (guaranteed safe position where all tables have data)
 // 2. Filter blocks by maxBlock for each table (only insert blocks > maxBlock)
-// 3. Insert to all 4 tables in parallel - any failure causes panic
+// 3. Insert to all tables with deduplication tokens (or upserts) - retries never duplicate rows
 // 4. Update watermark only after ALL tables succeed - failure causes panic
 // This ensures consistency: either all operations succeed or the app crashes
-func (cs *ChainSyncer) writeBlocks(blocks []*evmrpc.NormalizedBlock) error {
+func (cs *ChainSyncer) writeBlocks(blocks []*evmrpc.NormalizedBlock) (err error) {
 	if len(blocks) == 0 {
 		return nil
 	}
 
-	ctx := context.Background()
-	g, ctx := errgroup.WithContext(ctx)
-	start := time.Now()
-
-	// Insert to blocks table
-	g.Go(func() error {
-		return InsertBlocks(ctx, cs.conn, cs.chainId, blocks, cs.maxBlockBlocks)
-	})
+	ctx, span := tracer.Start(context.Background(), "evmsyncer.writeBlocks",
+		trace.WithAttributes(attribute.Int64("chain.id", int64(cs.chainId)), attribute.Int("block.count", len(blocks))))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
 
-	// Insert to transactions table
-	g.Go(func() error {
-		return InsertTransactions(ctx, cs.conn, cs.chainId, blocks, cs.maxBlockTransactions)
-	})
-
-	// Insert to traces table
-	g.Go(func() error {
-		return InsertTraces(ctx, cs.conn, cs.chainId, blocks, cs.maxBlockTraces)
-	})
-
-	// Insert to logs table
-	g.Go(func() error {
-		return InsertLogs(ctx, cs.conn, cs.chainId, blocks, cs.maxBlockLogs)
-	})
-
-	// Wait for all inserts to complete
-	if err := g.Wait(); err != nil {
+	start := time.Now()
+	if err := cs.sink.Write(ctx, blocks); err != nil {
 		return fmt.Errorf("failed to insert blocks: %w", err)
 	}
+	_, maxBlock := blockRange(blocks)
 
 	elapsed := time.Since(start)
 	txCount := 0
 	for _, b := range blocks {
 		txCount += len(b.Block.Transactions)
 	}
-	log.Printf("[Chain %d] Inserted %d blocks and %d txs in %v", cs.chainId, len(blocks), txCount, elapsed)
+	cs.logger.Info("Inserted blocks", "blocks", len(blocks), "txs", txCount, "elapsed", elapsed)
 
 	// Update watermark to the highest block number in this batch
-	maxBlock := uint32(0)
-	for _, b := range blocks {
-		blockNum, err := hexToUint32(b.Block.Number)
-		if err != nil {
-			continue
-		}
-		if blockNum > maxBlock {
-			maxBlock = blockNum
-		}
-	}
-
 	if maxBlock > cs.watermark {
-		if err := chwrapper.SetWatermark(cs.conn, cs.chainId, maxBlock); err != nil {
+		if err := cs.sink.SetWatermark(ctx, maxBlock); err != nil {
 			// Panic on watermark update failure - this is critical for preventing duplicates
 			// If we can't update watermark after successful inserts, we risk data duplication on restart
-			log.Fatalf("[Chain %d] FATAL: Failed to update watermark after successful inserts: %v", cs.chainId, err)
+			logging.Fatal(cs.logger, "Failed to update watermark after successful inserts", "error", err)
 		}
 		cs.watermark = maxBlock
+		firehose.Notify(cs.chainId)
 	}
 
 	// Update indexer runner with latest block info (only once per batch)
@@ -526,8 +986,10 @@ func (cs *ChainSyncer) printProgress() {
 			writeRate := float64(written) / elapsed.Seconds()
 			lag := fetched - written
 
-			log.Printf("[Chain %d] Fetched: %d (%.1f/s) | Written: %d (%.1f/s) | Lag: %d | Watermark: %d",
-				cs.chainId, fetched, fetchRate, written, writeRate, lag, cs.watermark)
+			cs.logger.Info("Progress",
+				"fetched", fetched, "fetch_rate", fmt.Sprintf("%.1f/s", fetchRate),
+				"written", written, "write_rate", fmt.Sprintf("%.1f/s", writeRate),
+				"lag", lag, "watermark", cs.watermark)
 		}
 	}
 }
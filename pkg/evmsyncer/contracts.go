@@ -0,0 +1,166 @@
+package evmsyncer
+
+import (
+	"icicle/pkg/evmrpc"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ava-labs/libevm/crypto"
+)
+
+// ContractCreation is a contract deployed by a CREATE or CREATE2 frame
+type ContractCreation struct {
+	Address         []byte
+	Creator         []byte // Account executing the CREATE: the EOA for top-level deployments, else the factory
+	CreateType      string // CREATE or CREATE2
+	TraceAddress    []uint16
+	InitCodeHash    []byte
+	RuntimeCodeHash []byte // nil when the trace carries no output (or there is no trace)
+}
+
+// ContractCreations returns the contracts a transaction deployed. Frames that reverted, or sit
+// under a reverted frame, deployed nothing. Without a trace, a top-level deployment is still
+// recovered from the receipt's contractAddress, with no runtime code hash.
+func ContractCreations(tx evmrpc.Transaction, receipt evmrpc.Receipt, trace *evmrpc.CallTrace) []ContractCreation {
+	if receipt.Status != "0x1" {
+		return nil
+	}
+
+	if trace == nil {
+		if receipt.ContractAddress == nil || *receipt.ContractAddress == "" || *receipt.ContractAddress == "0x" {
+			return nil
+		}
+		address, err := hexToFixedBytes(*receipt.ContractAddress, 20)
+		if err != nil {
+			return nil
+		}
+		creator, err := hexToFixedBytes(tx.From, 20)
+		if err != nil {
+			return nil
+		}
+		input, _ := hexToBytes(tx.Input)
+		return []ContractCreation{{
+			Address:      address,
+			Creator:      creator,
+			CreateType:   "CREATE",
+			TraceAddress: []uint16{},
+			InitCodeHash: crypto.Keccak256(input),
+		}}
+	}
+
+	var creations []ContractCreation
+	collectCreations(trace, []uint16{}, &creations)
+	return creations
+}
+
+// collectCreations appends the creations of frame and its successful children
+func collectCreations(frame *evmrpc.CallTrace, address []uint16, creations *[]ContractCreation) {
+	if frame.Error != "" {
+		return // Reverted, along with everything below it
+	}
+
+	callType := strings.ToUpper(frame.Type)
+	if (callType == "CREATE" || callType == "CREATE2") && frame.To != "" && frame.To != "0x" {
+		contract, errTo := hexToFixedBytes(frame.To, 20)
+		creator, errFrom := hexToFixedBytes(frame.From, 20)
+		if errTo == nil && errFrom == nil {
+			input, _ := hexToBytes(frame.Input)
+			creation := ContractCreation{
+				Address:      contract,
+				Creator:      creator,
+				CreateType:   callType,
+				TraceAddress: append([]uint16{}, address...),
+				InitCodeHash: crypto.Keccak256(input),
+			}
+			if output, err := hexToBytes(frame.Output); err == nil && len(output) > 0 {
+				creation.RuntimeCodeHash = crypto.Keccak256(output)
+			}
+			*creations = append(*creations, creation)
+		}
+	}
+
+	for i := range frame.Calls {
+		collectCreations(&frame.Calls[i], append(append([]uint16{}, address...), uint16(i)), creations)
+	}
+}
+
+// InsertContracts inserts the contracts deployed in blocks into the contracts table
+func InsertContracts(ctx context.Context, conn clickhouse.Conn, chainID uint32, blocks []*evmrpc.NormalizedBlock, maxBlock uint32) error {
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO contracts (
+		chain_id, address, creator, tx_from, block_number, block_time, transaction_hash,
+		transaction_index, trace_address, create_type, init_code_hash, runtime_code_hash
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	for _, normalizedBlock := range blocks {
+		block := normalizedBlock.Block
+
+		blockNumber, err := hexToUint32(block.Number)
+		if err != nil {
+			return fmt.Errorf("failed to parse block number: %w", err)
+		}
+		if blockNumber <= maxBlock {
+			continue // Already in the table
+		}
+
+		blockTime, err := parseBlockTime(block)
+		if err != nil {
+			return err
+		}
+
+		for i, tx := range block.Transactions {
+			if i >= len(normalizedBlock.Receipts) {
+				break
+			}
+			var trace *evmrpc.CallTrace
+			if i < len(normalizedBlock.Traces) {
+				trace = normalizedBlock.Traces[i].Result
+			}
+
+			creations := ContractCreations(tx, normalizedBlock.Receipts[i], trace)
+			if len(creations) == 0 {
+				continue
+			}
+
+			txHash, err := hexToFixedBytes(tx.Hash, 32)
+			if err != nil {
+				return fmt.Errorf("failed to parse tx hash: %w", err)
+			}
+			txFrom, err := hexToFixedBytes(tx.From, 20)
+			if err != nil {
+				return fmt.Errorf("failed to parse tx from: %w", err)
+			}
+
+			for _, creation := range creations {
+				var runtimeCodeHash any
+				if creation.RuntimeCodeHash != nil {
+					runtimeCodeHash = creation.RuntimeCodeHash
+				}
+
+				err = batch.Append(
+					chainID,
+					creation.Address,
+					creation.Creator,
+					txFrom,
+					blockNumber,
+					blockTime,
+					txHash,
+					uint16(i),
+					creation.TraceAddress,
+					creation.CreateType,
+					creation.InitCodeHash,
+					runtimeCodeHash,
+				)
+				if err != nil {
+					return fmt.Errorf("failed to append contract: %w", err)
+				}
+			}
+		}
+	}
+
+	return batch.Send()
+}
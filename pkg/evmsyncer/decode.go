@@ -0,0 +1,352 @@
+package evmsyncer
+
+import (
+	"icicle/pkg/evmrpc"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// Event signatures (topic0) recognized by the log decoder
+const (
+	// Transfer(address,address,uint256), shared by ERC-20 and ERC-721
+	transferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	// TransferSingle(address,address,address,uint256,uint256), ERC-1155
+	transferSingleTopic = "0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62"
+	// TransferBatch(address,address,address,uint256[],uint256[]), ERC-1155
+	transferBatchTopic = "0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb"
+)
+
+// Token standards of NFT transfers
+const (
+	StandardERC721  = "erc721"
+	StandardERC1155 = "erc1155"
+)
+
+// ERC20Transfer is a decoded ERC-20 Transfer event
+type ERC20Transfer struct {
+	Token  []byte
+	From   []byte
+	To     []byte
+	Amount *big.Int
+}
+
+// DecodeERC20Transfer decodes an ERC-20 Transfer log. ERC-721 uses the same signature with the
+// token ID as a fourth topic, so only logs with three topics and a 32-byte amount match.
+func DecodeERC20Transfer(log evmrpc.Log) (ERC20Transfer, bool) {
+	if log.Removed || len(log.Topics) != 3 || strings.ToLower(log.Topics[0]) != transferTopic {
+		return ERC20Transfer{}, false
+	}
+
+	data, err := hexToBytes(log.Data)
+	if err != nil || len(data) != 32 {
+		return ERC20Transfer{}, false
+	}
+
+	token, err := hexToFixedBytes(log.Address, 20)
+	if err != nil {
+		return ERC20Transfer{}, false
+	}
+	from, ok := topicAddress(log.Topics[1])
+	if !ok {
+		return ERC20Transfer{}, false
+	}
+	to, ok := topicAddress(log.Topics[2])
+	if !ok {
+		return ERC20Transfer{}, false
+	}
+
+	return ERC20Transfer{Token: token, From: from, To: to, Amount: new(big.Int).SetBytes(data)}, true
+}
+
+// NFTTransfer is a decoded ERC-721 or ERC-1155 transfer of one token ID
+type NFTTransfer struct {
+	Standard   string
+	Collection []byte
+	Operator   []byte // nil for ERC-721, which has no operator
+	From       []byte
+	To         []byte
+	TokenID    *big.Int
+	Amount     *big.Int // Always 1 for ERC-721
+	BatchIndex uint16   // Position in a TransferBatch, 0 otherwise
+}
+
+// DecodeNFTTransfers decodes an ERC-721 Transfer (token ID as the fourth topic) or an ERC-1155
+// TransferSingle/TransferBatch log into one transfer per token ID
+func DecodeNFTTransfers(log evmrpc.Log) ([]NFTTransfer, bool) {
+	if log.Removed || len(log.Topics) != 4 {
+		return nil, false
+	}
+
+	collection, err := hexToFixedBytes(log.Address, 20)
+	if err != nil {
+		return nil, false
+	}
+	topics := make([][]byte, 3)
+	for i := range topics {
+		if topics[i], err = hexToFixedBytes(log.Topics[i+1], 32); err != nil {
+			return nil, false
+		}
+	}
+	data, err := hexToBytes(log.Data)
+	if err != nil {
+		return nil, false
+	}
+
+	switch strings.ToLower(log.Topics[0]) {
+	case transferTopic:
+		if len(data) != 0 {
+			return nil, false
+		}
+		return []NFTTransfer{{
+			Standard:   StandardERC721,
+			Collection: collection,
+			From:       topics[0][12:],
+			To:         topics[1][12:],
+			TokenID:    new(big.Int).SetBytes(topics[2]),
+			Amount:     big.NewInt(1),
+		}}, true
+
+	case transferSingleTopic:
+		if len(data) != 64 {
+			return nil, false
+		}
+		return []NFTTransfer{{
+			Standard:   StandardERC1155,
+			Collection: collection,
+			Operator:   topics[0][12:],
+			From:       topics[1][12:],
+			To:         topics[2][12:],
+			TokenID:    new(big.Int).SetBytes(data[:32]),
+			Amount:     new(big.Int).SetBytes(data[32:64]),
+		}}, true
+
+	case transferBatchTopic:
+		ids, ok := abiUint256Array(data, 0)
+		if !ok {
+			return nil, false
+		}
+		amounts, ok := abiUint256Array(data, 1)
+		if !ok || len(amounts) != len(ids) || len(ids) > 1<<16 {
+			return nil, false
+		}
+		transfers := make([]NFTTransfer, len(ids))
+		for i := range ids {
+			transfers[i] = NFTTransfer{
+				Standard:   StandardERC1155,
+				Collection: collection,
+				Operator:   topics[0][12:],
+				From:       topics[1][12:],
+				To:         topics[2][12:],
+				TokenID:    ids[i],
+				Amount:     amounts[i],
+				BatchIndex: uint16(i),
+			}
+		}
+		return transfers, true
+	}
+	return nil, false
+}
+
+// abiUint256Array decodes the uint256[] that is the arg-th argument of ABI-encoded data
+func abiUint256Array(data []byte, arg int) ([]*big.Int, bool) {
+	offset, ok := abiWord(data, uint64(arg)*32)
+	if !ok {
+		return nil, false
+	}
+	length, ok := abiWord(data, offset)
+	if !ok || length > uint64(len(data))/32 {
+		return nil, false
+	}
+
+	values := make([]*big.Int, length)
+	for i := range values {
+		start := offset + 32 + uint64(i)*32
+		if start+32 > uint64(len(data)) {
+			return nil, false
+		}
+		values[i] = new(big.Int).SetBytes(data[start : start+32])
+	}
+	return values, true
+}
+
+// abiWord reads the 32-byte word at pos as a uint64, failing if it is out of range
+func abiWord(data []byte, pos uint64) (uint64, bool) {
+	if pos+32 > uint64(len(data)) {
+		return 0, false
+	}
+	word := new(big.Int).SetBytes(data[pos : pos+32])
+	if !word.IsUint64() {
+		return 0, false
+	}
+	return word.Uint64(), true
+}
+
+// topicAddress returns the address in an indexed address topic (left-padded to 32 bytes)
+func topicAddress(topic string) ([]byte, bool) {
+	word, err := hexToFixedBytes(topic, 32)
+	if err != nil {
+		return nil, false
+	}
+	return word[12:], true
+}
+
+// logPosition returns the tx hash, tx index and log index of a log
+func logPosition(log evmrpc.Log) ([]byte, uint16, uint32, error) {
+	txHash, err := hexToFixedBytes(log.TransactionHash, 32)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to parse tx hash: %w", err)
+	}
+	txIndex, err := hexToUint16(log.TransactionIndex)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to parse tx index: %w", err)
+	}
+	logIndex, err := hexToUint32(log.LogIndex)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to parse log index: %w", err)
+	}
+	return txHash, txIndex, logIndex, nil
+}
+
+// parseBlockTime returns a block's time, preferring millisecond timestamps where the chain has them
+func parseBlockTime(block evmrpc.Block) (time.Time, error) {
+	if timestampMs, err := hexToUint64(block.TimestampMilliseconds); err == nil && timestampMs > 0 {
+		return time.UnixMilli(int64(timestampMs)).UTC(), nil
+	}
+	timestamp, err := hexToUint64(block.Timestamp)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse timestamp: %w", err)
+	}
+	return time.Unix(int64(timestamp), 0).UTC(), nil
+}
+
+// InsertERC20Transfers decodes ERC-20 Transfer logs and inserts them into erc20_transfers
+func InsertERC20Transfers(ctx context.Context, conn clickhouse.Conn, chainID uint32, blocks []*evmrpc.NormalizedBlock, maxBlock uint32) error {
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO erc20_transfers (
+		chain_id, token, block_number, block_time, transaction_hash,
+		transaction_index, log_index, from, to, amount
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	for _, normalizedBlock := range blocks {
+		blockNumber, err := hexToUint32(normalizedBlock.Block.Number)
+		if err != nil {
+			return fmt.Errorf("failed to parse block number: %w", err)
+		}
+		if blockNumber <= maxBlock {
+			continue // Already in the table
+		}
+
+		blockTime, err := parseBlockTime(normalizedBlock.Block)
+		if err != nil {
+			return err
+		}
+
+		for _, receipt := range normalizedBlock.Receipts {
+			for _, log := range receipt.Logs {
+				transfer, ok := DecodeERC20Transfer(log)
+				if !ok {
+					continue
+				}
+
+				txHash, txIndex, logIndex, err := logPosition(log)
+				if err != nil {
+					return err
+				}
+
+				err = batch.Append(
+					chainID,
+					transfer.Token,
+					blockNumber,
+					blockTime,
+					txHash,
+					txIndex,
+					logIndex,
+					transfer.From,
+					transfer.To,
+					transfer.Amount,
+				)
+				if err != nil {
+					return fmt.Errorf("failed to append erc20 transfer: %w", err)
+				}
+			}
+		}
+	}
+
+	return batch.Send()
+}
+
+// InsertNFTTransfers decodes ERC-721 and ERC-1155 transfer logs and inserts them into nft_transfers
+func InsertNFTTransfers(ctx context.Context, conn clickhouse.Conn, chainID uint32, blocks []*evmrpc.NormalizedBlock, maxBlock uint32) error {
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO nft_transfers (
+		chain_id, standard, collection, block_number, block_time, transaction_hash,
+		transaction_index, log_index, batch_index, operator, from, to, token_id, amount
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	for _, normalizedBlock := range blocks {
+		blockNumber, err := hexToUint32(normalizedBlock.Block.Number)
+		if err != nil {
+			return fmt.Errorf("failed to parse block number: %w", err)
+		}
+		if blockNumber <= maxBlock {
+			continue // Already in the table
+		}
+
+		blockTime, err := parseBlockTime(normalizedBlock.Block)
+		if err != nil {
+			return err
+		}
+
+		for _, receipt := range normalizedBlock.Receipts {
+			for _, log := range receipt.Logs {
+				transfers, ok := DecodeNFTTransfers(log)
+				if !ok {
+					continue
+				}
+
+				txHash, txIndex, logIndex, err := logPosition(log)
+				if err != nil {
+					return err
+				}
+
+				for _, transfer := range transfers {
+					var operator any
+					if transfer.Operator != nil {
+						operator = transfer.Operator
+					}
+
+					err = batch.Append(
+						chainID,
+						transfer.Standard,
+						transfer.Collection,
+						blockNumber,
+						blockTime,
+						txHash,
+						txIndex,
+						logIndex,
+						transfer.BatchIndex,
+						operator,
+						transfer.From,
+						transfer.To,
+						transfer.TokenID,
+						transfer.Amount,
+					)
+					if err != nil {
+						return fmt.Errorf("failed to append nft transfer: %w", err)
+					}
+				}
+			}
+		}
+	}
+
+	return batch.Send()
+}
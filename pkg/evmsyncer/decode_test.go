@@ -0,0 +1,183 @@
+package evmsyncer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"icicle/pkg/evmrpc"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testToken    = "0xb97ef9ef8734c71904d8002f8b6bc66dd9c48a6e"
+	testFrom     = "0x1111111111111111111111111111111111111111"
+	testTo       = "0x2222222222222222222222222222222222222222"
+	testOperator = "0x3333333333333333333333333333333333333333"
+)
+
+// word returns n as a 32-byte ABI word in hex, without 0x
+func word(n int64) string {
+	return fmt.Sprintf("%064x", n)
+}
+
+// addressTopic returns an address left-padded to a 32-byte topic
+func addressTopic(address string) string {
+	return "0x" + strings.Repeat("0", 24) + strings.TrimPrefix(address, "0x")
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	require.NoError(t, err)
+	return b
+}
+
+func TestDecodeERC20Transfer(t *testing.T) {
+	tests := []struct {
+		name   string
+		log    evmrpc.Log
+		ok     bool
+		amount int64
+	}{
+		{
+			name:   "transfer",
+			log:    evmrpc.Log{Address: testToken, Topics: []string{transferTopic, addressTopic(testFrom), addressTopic(testTo)}, Data: "0x" + word(1500)},
+			ok:     true,
+			amount: 1500,
+		},
+		{
+			name:   "upper case topic",
+			log:    evmrpc.Log{Address: testToken, Topics: []string{strings.ToUpper(transferTopic), addressTopic(testFrom), addressTopic(testTo)}, Data: "0x" + word(1)},
+			ok:     true,
+			amount: 1,
+		},
+		{
+			name: "erc721 has four topics",
+			log:  evmrpc.Log{Address: testToken, Topics: []string{transferTopic, addressTopic(testFrom), addressTopic(testTo), "0x" + word(7)}, Data: "0x"},
+		},
+		{
+			name: "removed",
+			log:  evmrpc.Log{Address: testToken, Topics: []string{transferTopic, addressTopic(testFrom), addressTopic(testTo)}, Data: "0x" + word(1), Removed: true},
+		},
+		{
+			name: "other event",
+			log:  evmrpc.Log{Address: testToken, Topics: []string{transferSingleTopic, addressTopic(testFrom), addressTopic(testTo)}, Data: "0x" + word(1)},
+		},
+		{
+			name: "short data",
+			log:  evmrpc.Log{Address: testToken, Topics: []string{transferTopic, addressTopic(testFrom), addressTopic(testTo)}, Data: "0x01"},
+		},
+		{
+			name: "topic too long",
+			log:  evmrpc.Log{Address: testToken, Topics: []string{transferTopic, "0x01" + word(1), addressTopic(testTo)}, Data: "0x" + word(1)},
+		},
+		{
+			name: "malformed topic",
+			log:  evmrpc.Log{Address: testToken, Topics: []string{transferTopic, "0xzz", addressTopic(testTo)}, Data: "0x" + word(1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transfer, ok := DecodeERC20Transfer(tt.log)
+			require.Equal(t, tt.ok, ok)
+			if !ok {
+				return
+			}
+			require.Equal(t, mustHex(t, testToken), transfer.Token)
+			require.Equal(t, mustHex(t, testFrom), transfer.From)
+			require.Equal(t, mustHex(t, testTo), transfer.To)
+			require.Equal(t, big.NewInt(tt.amount), transfer.Amount)
+		})
+	}
+}
+
+func TestDecodeNFTTransfers(t *testing.T) {
+	operatorTopics := []string{addressTopic(testOperator), addressTopic(testFrom), addressTopic(testTo)}
+	tests := []struct {
+		name     string
+		log      evmrpc.Log
+		ok       bool
+		standard string
+		operator bool
+		ids      []int64
+		amounts  []int64
+	}{
+		{
+			name:     "erc721",
+			log:      evmrpc.Log{Address: testToken, Topics: []string{transferTopic, addressTopic(testFrom), addressTopic(testTo), "0x" + word(42)}, Data: "0x"},
+			ok:       true,
+			standard: StandardERC721,
+			ids:      []int64{42},
+			amounts:  []int64{1},
+		},
+		{
+			name:     "erc1155 single",
+			log:      evmrpc.Log{Address: testToken, Topics: append([]string{transferSingleTopic}, operatorTopics...), Data: "0x" + word(5) + word(100)},
+			ok:       true,
+			standard: StandardERC1155,
+			operator: true,
+			ids:      []int64{5},
+			amounts:  []int64{100},
+		},
+		{
+			name: "erc1155 batch",
+			log: evmrpc.Log{Address: testToken, Topics: append([]string{transferBatchTopic}, operatorTopics...),
+				Data: "0x" + word(64) + word(160) + word(2) + word(7) + word(8) + word(2) + word(10) + word(20)},
+			ok:       true,
+			standard: StandardERC1155,
+			operator: true,
+			ids:      []int64{7, 8},
+			amounts:  []int64{10, 20},
+		},
+		{
+			name: "erc1155 batch length mismatch",
+			log: evmrpc.Log{Address: testToken, Topics: append([]string{transferBatchTopic}, operatorTopics...),
+				Data: "0x" + word(64) + word(160) + word(2) + word(7) + word(8) + word(1) + word(10)},
+		},
+		{
+			name: "erc1155 batch offset out of range",
+			log: evmrpc.Log{Address: testToken, Topics: append([]string{transferBatchTopic}, operatorTopics...),
+				Data: "0x" + word(4096) + word(64) + word(0)},
+		},
+		{
+			name: "erc1155 single short data",
+			log:  evmrpc.Log{Address: testToken, Topics: append([]string{transferSingleTopic}, operatorTopics...), Data: "0x" + word(5)},
+		},
+		{
+			name: "erc721 with data",
+			log:  evmrpc.Log{Address: testToken, Topics: []string{transferTopic, addressTopic(testFrom), addressTopic(testTo), "0x" + word(42)}, Data: "0x" + word(1)},
+		},
+		{
+			name: "erc20 has three topics",
+			log:  evmrpc.Log{Address: testToken, Topics: []string{transferTopic, addressTopic(testFrom), addressTopic(testTo)}, Data: "0x" + word(1)},
+		},
+		{
+			name: "removed",
+			log:  evmrpc.Log{Address: testToken, Topics: []string{transferTopic, addressTopic(testFrom), addressTopic(testTo), "0x" + word(42)}, Data: "0x", Removed: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transfers, ok := DecodeNFTTransfers(tt.log)
+			require.Equal(t, tt.ok, ok)
+			require.Len(t, transfers, len(tt.ids))
+			for i, transfer := range transfers {
+				require.Equal(t, tt.standard, transfer.Standard)
+				require.Equal(t, mustHex(t, testToken), transfer.Collection)
+				require.Equal(t, mustHex(t, testFrom), transfer.From)
+				require.Equal(t, mustHex(t, testTo), transfer.To)
+				if tt.operator {
+					require.Equal(t, mustHex(t, testOperator), transfer.Operator)
+				} else {
+					require.Nil(t, transfer.Operator)
+				}
+				require.Equal(t, big.NewInt(tt.ids[i]), transfer.TokenID)
+				require.Equal(t, big.NewInt(tt.amounts[i]), transfer.Amount)
+				require.Equal(t, uint16(i), transfer.BatchIndex)
+			}
+		})
+	}
+}
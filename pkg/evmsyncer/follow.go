@@ -0,0 +1,96 @@
+package evmsyncer
+
+import (
+	"context"
+	"fmt"
+	"icicle/pkg/evmrpc"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// HeadTableMaxBlocks caps the unconfirmed blocks written to raw_head_blocks per poll
+const HeadTableMaxBlocks = 256
+
+// getFollowedBlock returns the highest block the raw tables may get and the RPC tip. The followed
+// block is the tip minus followDistance, or the followTag block when that is lower.
+func (cs *ChainSyncer) getFollowedBlock() (followed, latest int64, err error) {
+	latest, err = cs.fetcher.GetLatestBlock()
+	if err != nil {
+		return 0, 0, err
+	}
+	cs.rpcLatest = latest
+
+	followed = latest - cs.followDistance
+	if cs.followTag != "" {
+		tagged, err := cs.fetcher.GetTaggedBlock(cs.followTag)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to get %s block: %w", cs.followTag, err)
+		}
+		followed = min(followed, tagged)
+	}
+	return max(followed, 0), latest, nil
+}
+
+// writeHeadBlocks writes the blocks above the followed block to raw_head_blocks whenever the tip
+// moves. The whole window is written again, so rows of reorged blocks are replaced.
+func (cs *ChainSyncer) writeHeadBlocks(followed, latest int64) {
+	if cs.clickhouse == nil || latest <= followed || latest <= cs.headWrittenTo {
+		return
+	}
+	from := max(followed+1, latest-HeadTableMaxBlocks+1)
+
+	blocks, err := cs.fetcher.FetchHeadBlocks(from, latest)
+	if err != nil {
+		cs.logger.Error("Error fetching head blocks", "from", from, "to", latest, "error", err)
+		return
+	}
+	if err := InsertHeadBlocks(cs.ctx, cs.conn, cs.chainId, blocks); err != nil {
+		cs.logger.Error("Error writing head blocks", "from", from, "to", latest, "error", err)
+		return
+	}
+	cs.headWrittenTo = latest
+}
+
+// InsertHeadBlocks inserts unconfirmed block headers into raw_head_blocks
+func InsertHeadBlocks(ctx context.Context, conn clickhouse.Conn, chainID uint32, blocks []evmrpc.Block) error {
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO raw_head_blocks (
+		chain_id, block_number, hash, parent_hash, block_time, miner, gas_used, transaction_count
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	for _, block := range blocks {
+		blockNumber, err := hexToUint32(block.Number)
+		if err != nil {
+			return fmt.Errorf("failed to parse block number: %w", err)
+		}
+		blockTime, err := parseBlockTime(block)
+		if err != nil {
+			return fmt.Errorf("block %d: %w", blockNumber, err)
+		}
+		hash, err := hexToFixedBytes(block.Hash, 32)
+		if err != nil {
+			return fmt.Errorf("failed to parse block hash: %w", err)
+		}
+		parentHash, err := hexToFixedBytes(block.ParentHash, 32)
+		if err != nil {
+			return fmt.Errorf("failed to parse parent hash: %w", err)
+		}
+		miner, err := hexToFixedBytes(block.Miner, 20)
+		if err != nil {
+			return fmt.Errorf("failed to parse miner: %w", err)
+		}
+		gasUsed, err := hexToUint32(block.GasUsed)
+		if err != nil {
+			return fmt.Errorf("failed to parse gas used: %w", err)
+		}
+
+		if err := batch.Append(chainID, blockNumber, hash, parentHash, blockTime,
+			miner, gasUsed, uint32(len(block.Transactions))); err != nil {
+			return fmt.Errorf("failed to append head block: %w", err)
+		}
+	}
+
+	return batch.Send()
+}
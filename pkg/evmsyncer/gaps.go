@@ -0,0 +1,114 @@
+package evmsyncer
+
+import (
+	"fmt"
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/metrics"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// GapHealMaxBlocks caps the missing blocks re-ingested per gap check, so healing never holds up
+// the writer for long
+const GapHealMaxBlocks = 1000
+
+// GapScanMaxBlocks caps the heights scanned per gap check, so the first check after a restart
+// works through the chain's history over many checks instead of holding up the writer
+const GapScanMaxBlocks = 1_000_000
+
+// blockTables are the tables written per block, cleared over a gap before it is re-ingested
+var blockTables = []string{
+	"raw_blocks",
+	"raw_uncles",
+	"raw_withdrawals",
+	"raw_txs",
+	"raw_traces",
+	"raw_logs",
+	"erc20_transfers",
+	"nft_transfers",
+	"contracts",
+	"icm_messages",
+	"internal_txs",
+	"raw_payloads",
+}
+
+// healGaps looks for blocks missing from raw_blocks below the watermark and ingests them again,
+// at most GapHealMaxBlocks per call. Only ranges checked since the last call are scanned, at most
+// GapScanMaxBlocks of them. Chains writing to a sink other than ClickHouse aren't checked.
+func (cs *ChainSyncer) healGaps() {
+	if cs.clickhouse == nil {
+		return
+	}
+	cs.gapsCheckedTo = min(cs.gapsCheckedTo, cs.watermark) // The watermark moves back on resync
+	from := max(uint64(cs.gapsCheckedTo), 1)
+	to := min(uint64(cs.watermark), from+GapScanMaxBlocks-1)
+	if to <= from {
+		return
+	}
+
+	gaps, err := chwrapper.FindBlockGaps(cs.conn, "raw_blocks", "chain_id", "block_number", cs.chainId, from, to)
+	if err != nil {
+		cs.logger.Error("Failed to check for missing blocks", "error", err)
+		return
+	}
+
+	var missing uint64
+	for _, gap := range gaps {
+		missing += gap.Size()
+	}
+	metrics.SetMissingBlocks(cs.chainId, missing)
+	if len(gaps) == 0 {
+		cs.gapsCheckedTo = uint32(to)
+		return
+	}
+	cs.logger.Warn("Found missing blocks", "gaps", len(gaps), "blocks", missing, "first", gaps[0].From, "last", gaps[len(gaps)-1].To)
+
+	budget := uint64(GapHealMaxBlocks)
+	for _, gap := range gaps {
+		end := min(gap.To, gap.From+budget-1)
+		if err := cs.healRange(uint32(gap.From), uint32(end)); err != nil {
+			cs.logger.Error("Failed to re-ingest missing blocks", "from", gap.From, "to", end, "error", err)
+			return
+		}
+		healed := end - gap.From + 1
+		metrics.BlocksHealed(cs.chainId, healed)
+		budget -= healed
+		if end < gap.To || budget == 0 {
+			return // The rest is healed on the next checks
+		}
+	}
+	cs.gapsCheckedTo = uint32(to)
+}
+
+// healRange re-ingests blocks [from, to]: it fetches them, clears what the tables have of them
+// and inserts them again
+func (cs *ChainSyncer) healRange(from, to uint32) error {
+	blocks, err := cs.fetcher.FetchBlockRange(int64(from), int64(to))
+	if err != nil {
+		return fmt.Errorf("failed to fetch blocks: %w", err)
+	}
+	cs.setProposers(blocks, int64(from), int64(to))
+
+	// Other tables may have some of the blocks, and must not get them twice
+	ctx := clickhouse.Context(cs.ctx, clickhouse.WithSettings(clickhouse.Settings{
+		"mutations_sync": 2,
+	}))
+	for _, table := range blockTables {
+		query := fmt.Sprintf("ALTER TABLE %s DELETE WHERE chain_id = ? AND block_number BETWEEN ? AND ?", table)
+		if err := cs.conn.Exec(ctx, query, cs.chainId, from, to); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", table, err)
+		}
+	}
+
+	// A fresh epoch, so the inserts aren't mistaken for retries of the original write
+	if err := cs.clickhouse.insert(cs.ctx, blocks, time.Now().UnixNano(), true); err != nil {
+		return fmt.Errorf("failed to insert blocks: %w", err)
+	}
+
+	cs.logger.Info("Re-ingested missing blocks", "from", from, "to", to)
+	if !cs.fast {
+		cs.logger.Warn("Computed tables don't include re-ingested blocks, resync the chain to recompute them", "from", from)
+	}
+	return nil
+}
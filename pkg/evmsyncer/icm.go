@@ -0,0 +1,270 @@
+package evmsyncer
+
+import (
+	"icicle/pkg/evmrpc"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// Teleporter (ICM) and Warp precompile event signatures
+const (
+	// SendCrossChainMessage(bytes32,bytes32,TeleporterMessage,TeleporterFeeInfo)
+	icmSendTopic = "0x2a211ad4a59ab9d003852404f9c57c690704ee755f3c79d2c2812ad32da99df8"
+	// ReceiveCrossChainMessage(bytes32,bytes32,address,address,TeleporterMessage)
+	icmReceiveTopic = "0x292ee90bbaf70b5d4936025e09d56ba08f3e421156b6a568cf3c2840d9343e34"
+	// MessageExecuted(bytes32,bytes32)
+	icmExecutedTopic = "0x34795cc6b122b9a0ae684946319f1e14a577b4e8f9b3dda9ac94c21a54d3188c"
+	// MessageExecutionFailed(bytes32,bytes32,TeleporterMessage)
+	icmExecutionFailedTopic = "0x4619adc1017b82e02eaefac01a43d50d6d8de4460774bc370c3ff0210d40c985"
+	// SendWarpMessage(address,bytes32,bytes)
+	warpSendTopic = "0x56600c567728a800c0aa927500f831cb451df66a7af570eb4df4dfbf4674887d"
+)
+
+// warpPrecompile is the address of the Warp precompile, the only legitimate emitter of SendWarpMessage
+const warpPrecompile = "0x0200000000000000000000000000000000000005"
+
+// ICM message events, in delivery order
+const (
+	ICMWarpSent        = "warp_sent"
+	ICMSent            = "sent"
+	ICMReceived        = "received"
+	ICMExecuted        = "executed"
+	ICMExecutionFailed = "execution_failed"
+)
+
+// ICMEvent is a decoded Teleporter or Warp event. Blockchain IDs the log doesn't carry are zero.
+type ICMEvent struct {
+	Event                   string
+	Contract                []byte
+	MessageID               []byte
+	SourceBlockchainID      []byte
+	DestinationBlockchainID []byte
+	Nonce                   *big.Int
+	OriginSender            []byte
+	DestinationAddress      []byte
+	Deliverer               []byte // Relayer that delivered a received message, nil otherwise
+
+	log evmrpc.Log // Log the event was decoded from
+}
+
+// DecodeICMEvents decodes the Teleporter and Warp events of a receipt. A Teleporter send emits a
+// Warp message in the same transaction, which is where its source blockchain ID comes from.
+func DecodeICMEvents(receipt evmrpc.Receipt) []ICMEvent {
+	var events []ICMEvent
+	var warpSource []byte
+	for _, log := range receipt.Logs {
+		event, ok := decodeICMEvent(log)
+		if !ok {
+			continue
+		}
+		if event.Event == ICMWarpSent {
+			warpSource = event.SourceBlockchainID
+		}
+		events = append(events, event)
+	}
+
+	if warpSource != nil {
+		for i := range events {
+			if events[i].Event == ICMSent {
+				events[i].SourceBlockchainID = warpSource
+			}
+		}
+	}
+	return events
+}
+
+// decodeICMEvent decodes a single Teleporter or Warp log
+func decodeICMEvent(log evmrpc.Log) (ICMEvent, bool) {
+	if log.Removed || len(log.Topics) < 3 {
+		return ICMEvent{}, false
+	}
+
+	contract, err := hexToFixedBytes(log.Address, 20)
+	if err != nil {
+		return ICMEvent{}, false
+	}
+	topic1, err := hexToFixedBytes(log.Topics[1], 32)
+	if err != nil {
+		return ICMEvent{}, false
+	}
+	topic2, err := hexToFixedBytes(log.Topics[2], 32)
+	if err != nil {
+		return ICMEvent{}, false
+	}
+	data, err := hexToBytes(log.Data)
+	if err != nil {
+		return ICMEvent{}, false
+	}
+
+	zero := make([]byte, 32)
+	event := ICMEvent{
+		log:                     log,
+		Contract:                contract,
+		SourceBlockchainID:      zero,
+		DestinationBlockchainID: zero,
+		Nonce:                   big.NewInt(0),
+		OriginSender:            zero[:20],
+		DestinationAddress:      zero[:20],
+	}
+
+	switch strings.ToLower(log.Topics[0]) {
+	case warpSendTopic:
+		// Topics: sourceAddress, unsignedMessageID. The unsigned message is codec version (2 bytes),
+		// network ID (4 bytes), source chain ID (32 bytes), payload
+		if !strings.EqualFold(log.Address, warpPrecompile) {
+			return ICMEvent{}, false
+		}
+		message, ok := abiBytes(data, 0)
+		if !ok || len(message) < 38 {
+			return ICMEvent{}, false
+		}
+		event.Event = ICMWarpSent
+		event.MessageID = topic2
+		event.SourceBlockchainID = message[6:38]
+		event.OriginSender = topic1[12:]
+		return event, true
+
+	case icmSendTopic:
+		// Topics: messageID, destinationBlockchainID. Data: message, feeInfo
+		event.Event = ICMSent
+		event.MessageID = topic1
+		event.DestinationBlockchainID = topic2
+		if !decodeTeleporterMessage(data, 0, &event) {
+			return ICMEvent{}, false
+		}
+		return event, true
+
+	case icmReceiveTopic:
+		// Topics: messageID, sourceBlockchainID, deliverer. Data: rewardRedeemer, message
+		if len(log.Topics) < 4 {
+			return ICMEvent{}, false
+		}
+		deliverer, ok := topicAddress(log.Topics[3])
+		if !ok {
+			return ICMEvent{}, false
+		}
+		event.Event = ICMReceived
+		event.MessageID = topic1
+		event.SourceBlockchainID = topic2
+		event.Deliverer = deliverer
+		if !decodeTeleporterMessage(data, 1, &event) {
+			return ICMEvent{}, false
+		}
+		return event, true
+
+	case icmExecutedTopic:
+		// Topics: messageID, sourceBlockchainID
+		event.Event = ICMExecuted
+		event.MessageID = topic1
+		event.SourceBlockchainID = topic2
+		return event, true
+
+	case icmExecutionFailedTopic:
+		// Topics: messageID, sourceBlockchainID. Data: message
+		event.Event = ICMExecutionFailed
+		event.MessageID = topic1
+		event.SourceBlockchainID = topic2
+		if !decodeTeleporterMessage(data, 0, &event) {
+			return ICMEvent{}, false
+		}
+		return event, true
+	}
+	return ICMEvent{}, false
+}
+
+// decodeTeleporterMessage fills event from the TeleporterMessage tuple that is the arg-th argument
+// of data: (messageNonce, originSenderAddress, destinationBlockchainID, destinationAddress, ...)
+func decodeTeleporterMessage(data []byte, arg int, event *ICMEvent) bool {
+	offset, ok := abiWord(data, uint64(arg)*32)
+	if !ok || offset+4*32 > uint64(len(data)) {
+		return false
+	}
+	message := data[offset:]
+	event.Nonce = new(big.Int).SetBytes(message[:32])
+	event.OriginSender = message[32+12 : 64]
+	event.DestinationBlockchainID = message[64:96]
+	event.DestinationAddress = message[96+12 : 128]
+	return true
+}
+
+// abiBytes decodes the bytes that is the arg-th argument of ABI-encoded data
+func abiBytes(data []byte, arg int) ([]byte, bool) {
+	offset, ok := abiWord(data, uint64(arg)*32)
+	if !ok {
+		return nil, false
+	}
+	length, ok := abiWord(data, offset)
+	if !ok || length > uint64(len(data)) || offset+32+length > uint64(len(data)) {
+		return nil, false
+	}
+	return data[offset+32 : offset+32+length], true
+}
+
+// InsertICMMessages decodes Teleporter and Warp events and inserts them into icm_messages
+func InsertICMMessages(ctx context.Context, conn clickhouse.Conn, chainID uint32, blocks []*evmrpc.NormalizedBlock, maxBlock uint32) error {
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO icm_messages (
+		chain_id, event, message_id, source_blockchain_id, destination_blockchain_id,
+		message_nonce, origin_sender, destination_address, deliverer, contract,
+		block_number, block_time, transaction_hash, transaction_index, log_index
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	for _, normalizedBlock := range blocks {
+		blockNumber, err := hexToUint32(normalizedBlock.Block.Number)
+		if err != nil {
+			return fmt.Errorf("failed to parse block number: %w", err)
+		}
+		if blockNumber <= maxBlock {
+			continue // Already in the table
+		}
+
+		blockTime, err := parseBlockTime(normalizedBlock.Block)
+		if err != nil {
+			return err
+		}
+
+		for _, receipt := range normalizedBlock.Receipts {
+			events := DecodeICMEvents(receipt)
+			for _, event := range events {
+				txHash, txIndex, logIndex, err := logPosition(event.log)
+				if err != nil {
+					return err
+				}
+
+				var deliverer any
+				if event.Deliverer != nil {
+					deliverer = event.Deliverer
+				}
+
+				err = batch.Append(
+					chainID,
+					event.Event,
+					event.MessageID,
+					event.SourceBlockchainID,
+					event.DestinationBlockchainID,
+					event.Nonce,
+					event.OriginSender,
+					event.DestinationAddress,
+					deliverer,
+					event.Contract,
+					blockNumber,
+					blockTime,
+					txHash,
+					txIndex,
+					logIndex,
+				)
+				if err != nil {
+					return fmt.Errorf("failed to append icm message: %w", err)
+				}
+			}
+		}
+	}
+
+	return batch.Send()
+}
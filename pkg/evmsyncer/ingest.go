@@ -1,10 +1,11 @@
 package evmsyncer
 
 import (
-	"icicle/pkg/evmrpc"
 	"context"
 	"encoding/json"
 	"fmt"
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/evmrpc"
 	"math/big"
 	"strconv"
 	"strings"
@@ -169,7 +170,7 @@ func InsertBlocks(ctx context.Context, conn clickhouse.Conn, chainID uint32, blo
 		block_gas_cost, state_root, transactions_root, receipts_root, extra_data,
 		block_extra_data, ext_data_hash, ext_data_gas_used, mix_hash, nonce,
 		sha3_uncles, uncles, blob_gas_used, excess_blob_gas, parent_beacon_block_root,
-		min_delay_excess
+		min_delay_excess, proposer
 	)`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare batch: %w", err)
@@ -355,6 +356,7 @@ func InsertBlocks(ctx context.Context, conn clickhouse.Conn, chainID uint32, blo
 			excessBlobGas,
 			parentBeaconRoot,
 			minDelayExcess,
+			normalizedBlock.Proposer,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to append block %d: %w", blockNumber, err)
@@ -390,12 +392,20 @@ func InsertTransactions(ctx context.Context, conn clickhouse.Conn, chainID uint3
 		chain_id, hash, block_number, block_hash, block_time,
 		transaction_index, nonce, from, to, value, gas_limit, gas_price,
 		gas_used, success, input, type, max_fee_per_gas, max_priority_fee_per_gas,
-		priority_fee_per_gas, base_fee_per_gas, contract_address, access_list
+		priority_fee_per_gas, base_fee_per_gas, contract_address, access_list,
+		effective_gas_price, blob_versioned_hashes, max_fee_per_blob_gas, blob_gas_used, blob_gas_price,
+		source_hash, mint
 	)`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare batch: %w", err)
 	}
 
+	rows := 0
+	for _, b := range filteredBlocks {
+		rows += len(b.Block.Transactions)
+	}
+	cols := newTxColumns(rows)
+
 	for _, normalizedBlock := range filteredBlocks {
 		block := normalizedBlock.Block
 		receipts := normalizedBlock.Receipts
@@ -466,7 +476,7 @@ func InsertTransactions(ctx context.Context, conn clickhouse.Conn, chainID uint3
 			}
 
 			// To address (nullable for contract creation)
-			var to any = nil
+			var to []byte
 			if tx.To != "" && tx.To != "0x" {
 				toBytes, err := hexToFixedBytes(tx.To, 20)
 				if err != nil {
@@ -499,6 +509,15 @@ func InsertTransactions(ctx context.Context, conn clickhouse.Conn, chainID uint3
 				return fmt.Errorf("failed to parse gas used: %w", err)
 			}
 
+			// Price actually paid per gas (from receipt), the gas price on chains that don't report it
+			effectiveGasPrice := gasPrice
+			if receipt.EffectiveGasPrice != "" {
+				effectiveGasPrice, err = hexToUint64(receipt.EffectiveGasPrice)
+				if err != nil {
+					return fmt.Errorf("failed to parse effective gas price: %w", err)
+				}
+			}
+
 			// Success status (from receipt)
 			success := receipt.Status == "0x1"
 
@@ -541,7 +560,7 @@ func InsertTransactions(ctx context.Context, conn clickhouse.Conn, chainID uint3
 			}
 
 			// Contract address (from receipt, for contract creation)
-			var contractAddr any = nil
+			var contractAddr []byte
 			if receipt.ContractAddress != nil && *receipt.ContractAddress != "" && *receipt.ContractAddress != "0x" {
 				contractAddrBytes, err := hexToFixedBytes(*receipt.ContractAddress, 20)
 				if err == nil {
@@ -578,40 +597,178 @@ func InsertTransactions(ctx context.Context, conn clickhouse.Conn, chainID uint3
 				}
 			}
 
-			// Append to batch
-			err = batch.Append(
-				chainID,
-				txHash,
-				blockNumber,
-				blockHash,
-				blockTime,
-				txIndex,
-				nonce,
-				from,
-				to,
-				value,
-				gasLimit,
-				gasPrice,
-				gasUsed,
-				success,
-				string(input),
-				txType,
-				maxFeePerGas,
-				maxPriorityFeePerGas,
-				priorityFeePerGas,
-				baseFeePerGas,
-				contractAddr,
-				accessList,
-			)
+			// Blob fields (EIP-4844)
+			blobVersionedHashes := make([][]byte, 0, len(tx.BlobVersionedHashes))
+			for _, hash := range tx.BlobVersionedHashes {
+				hashBytes, err := hexToFixedBytes(hash, 32)
+				if err != nil {
+					return fmt.Errorf("failed to parse blob versioned hash: %w", err)
+				}
+				blobVersionedHashes = append(blobVersionedHashes, hashBytes)
+			}
+
+			var maxFeePerBlobGas *uint64
+			if tx.MaxFeePerBlobGas != "" {
+				val, err := hexToUint64(tx.MaxFeePerBlobGas)
+				if err != nil {
+					return fmt.Errorf("failed to parse max fee per blob gas: %w", err)
+				}
+				maxFeePerBlobGas = &val
+			}
+
+			blobGasUsed, err := hexToUint32(receipt.BlobGasUsed)
 			if err != nil {
-				return fmt.Errorf("failed to append tx %s: %w", tx.Hash, err)
+				return fmt.Errorf("failed to parse blob gas used: %w", err)
+			}
+
+			var blobGasPrice *uint64
+			if receipt.BlobGasPrice != "" {
+				val, err := hexToUint64(receipt.BlobGasPrice)
+				if err != nil {
+					return fmt.Errorf("failed to parse blob gas price: %w", err)
+				}
+				blobGasPrice = &val
 			}
+
+			// Deposit fields (OP Stack type 0x7e, which has no signature)
+			var sourceHash []byte
+			if tx.SourceHash != "" {
+				val, err := hexToFixedBytes(tx.SourceHash, 32)
+				if err != nil {
+					return fmt.Errorf("failed to parse source hash: %w", err)
+				}
+				sourceHash = val
+			}
+
+			var mint *big.Int
+			if tx.Mint != "" {
+				val, err := hexToBigInt(tx.Mint)
+				if err != nil {
+					return fmt.Errorf("failed to parse mint: %w", err)
+				}
+				mint = val
+			}
+
+			// Append to columns
+			cols.chainID = append(cols.chainID, chainID)
+			cols.hash = append(cols.hash, txHash)
+			cols.blockNumber = append(cols.blockNumber, blockNumber)
+			cols.blockHash = append(cols.blockHash, blockHash)
+			cols.blockTime = append(cols.blockTime, blockTime)
+			cols.transactionIndex = append(cols.transactionIndex, txIndex)
+			cols.nonce = append(cols.nonce, nonce)
+			cols.from = append(cols.from, from)
+			cols.to = append(cols.to, to)
+			cols.value = append(cols.value, value)
+			cols.gasLimit = append(cols.gasLimit, gasLimit)
+			cols.gasPrice = append(cols.gasPrice, gasPrice)
+			cols.gasUsed = append(cols.gasUsed, gasUsed)
+			cols.success = append(cols.success, success)
+			cols.input = append(cols.input, string(input))
+			cols.txType = append(cols.txType, txType)
+			cols.maxFeePerGas = append(cols.maxFeePerGas, maxFeePerGas)
+			cols.maxPriorityFeePerGas = append(cols.maxPriorityFeePerGas, maxPriorityFeePerGas)
+			cols.priorityFeePerGas = append(cols.priorityFeePerGas, priorityFeePerGas)
+			cols.baseFeePerGas = append(cols.baseFeePerGas, baseFeePerGas)
+			cols.contractAddress = append(cols.contractAddress, contractAddr)
+			cols.accessList = append(cols.accessList, accessList)
+			cols.effectiveGasPrice = append(cols.effectiveGasPrice, effectiveGasPrice)
+			cols.blobVersionedHashes = append(cols.blobVersionedHashes, blobVersionedHashes)
+			cols.maxFeePerBlobGas = append(cols.maxFeePerBlobGas, maxFeePerBlobGas)
+			cols.blobGasUsed = append(cols.blobGasUsed, blobGasUsed)
+			cols.blobGasPrice = append(cols.blobGasPrice, blobGasPrice)
+			cols.sourceHash = append(cols.sourceHash, sourceHash)
+			cols.mint = append(cols.mint, mint)
 		}
 	}
 
+	if err := chwrapper.AppendColumns(batch, cols.columns()...); err != nil {
+		return fmt.Errorf("failed to append txs: %w", err)
+	}
 	return batch.Send()
 }
 
+// txColumns holds the rows of a raw_txs insert column by column. Nullable columns use nil slices
+// and pointers for NULL.
+type txColumns struct {
+	chainID              []uint32
+	hash                 [][]byte
+	blockNumber          []uint32
+	blockHash            [][]byte
+	blockTime            []time.Time
+	transactionIndex     []uint16
+	nonce                []uint64
+	from                 [][]byte
+	to                   [][]byte
+	value                []*big.Int
+	gasLimit             []uint32
+	gasPrice             []uint64
+	gasUsed              []uint32
+	success              []bool
+	input                []string
+	txType               []uint8
+	maxFeePerGas         []*uint64
+	maxPriorityFeePerGas []*uint64
+	priorityFeePerGas    []*uint64
+	baseFeePerGas        []uint64
+	contractAddress      [][]byte
+	accessList           [][]map[string]interface{}
+	effectiveGasPrice    []uint64
+	blobVersionedHashes  [][][]byte
+	maxFeePerBlobGas     []*uint64
+	blobGasUsed          []uint32
+	blobGasPrice         []*uint64
+	sourceHash           [][]byte
+	mint                 []*big.Int
+}
+
+// newTxColumns preallocates the columns of rows transactions
+func newTxColumns(rows int) *txColumns {
+	return &txColumns{
+		chainID:              make([]uint32, 0, rows),
+		hash:                 make([][]byte, 0, rows),
+		blockNumber:          make([]uint32, 0, rows),
+		blockHash:            make([][]byte, 0, rows),
+		blockTime:            make([]time.Time, 0, rows),
+		transactionIndex:     make([]uint16, 0, rows),
+		nonce:                make([]uint64, 0, rows),
+		from:                 make([][]byte, 0, rows),
+		to:                   make([][]byte, 0, rows),
+		value:                make([]*big.Int, 0, rows),
+		gasLimit:             make([]uint32, 0, rows),
+		gasPrice:             make([]uint64, 0, rows),
+		gasUsed:              make([]uint32, 0, rows),
+		success:              make([]bool, 0, rows),
+		input:                make([]string, 0, rows),
+		txType:               make([]uint8, 0, rows),
+		maxFeePerGas:         make([]*uint64, 0, rows),
+		maxPriorityFeePerGas: make([]*uint64, 0, rows),
+		priorityFeePerGas:    make([]*uint64, 0, rows),
+		baseFeePerGas:        make([]uint64, 0, rows),
+		contractAddress:      make([][]byte, 0, rows),
+		accessList:           make([][]map[string]interface{}, 0, rows),
+		effectiveGasPrice:    make([]uint64, 0, rows),
+		blobVersionedHashes:  make([][][]byte, 0, rows),
+		maxFeePerBlobGas:     make([]*uint64, 0, rows),
+		blobGasUsed:          make([]uint32, 0, rows),
+		blobGasPrice:         make([]*uint64, 0, rows),
+		sourceHash:           make([][]byte, 0, rows),
+		mint:                 make([]*big.Int, 0, rows),
+	}
+}
+
+// columns returns the columns in the order of the raw_txs INSERT
+func (c *txColumns) columns() []any {
+	return []any{
+		c.chainID, c.hash, c.blockNumber, c.blockHash, c.blockTime,
+		c.transactionIndex, c.nonce, c.from, c.to, c.value, c.gasLimit, c.gasPrice,
+		c.gasUsed, c.success, c.input, c.txType, c.maxFeePerGas, c.maxPriorityFeePerGas,
+		c.priorityFeePerGas, c.baseFeePerGas, c.contractAddress, c.accessList,
+		c.effectiveGasPrice, c.blobVersionedHashes, c.maxFeePerBlobGas, c.blobGasUsed, c.blobGasPrice,
+		c.sourceHash, c.mint,
+	}
+}
+
 // FlattenedTrace represents a flattened trace with its address path
 type FlattenedTrace struct {
 	TxHash           string
@@ -627,6 +784,8 @@ type FlattenedTrace struct {
 	Input            []byte
 	Output           []byte
 	CallType         string
+	Error            string // Why this frame failed, empty if it succeeded
+	Reverted         bool   // This frame or one of its ancestors failed, undoing its effects
 	TxSuccess        bool
 	TxFrom           []byte
 	TxTo             any
@@ -703,6 +862,8 @@ func flattenTrace(trace *evmrpc.CallTrace, txHash string, blockNum uint32, block
 			Input:            input,
 			Output:           output,
 			CallType:         callType,
+			Error:            trace.Error,
+			Reverted:         trace.Error != "",
 			TxSuccess:        txSuccess,
 			TxFrom:           txFrom,
 			TxTo:             txTo,
@@ -716,6 +877,11 @@ func flattenTrace(trace *evmrpc.CallTrace, txHash string, blockNum uint32, block
 		if err != nil {
 			return nil, fmt.Errorf("failed to flatten child trace: %w", err)
 		}
+		if trace.Error != "" {
+			for j := range childTraces {
+				childTraces[j].Reverted = true
+			}
+		}
 		flattened = append(flattened, childTraces...)
 	}
 
@@ -893,6 +1059,14 @@ func InsertLogs(ctx context.Context, conn clickhouse.Conn, chainID uint32, block
 		return fmt.Errorf("failed to prepare batch: %w", err)
 	}
 
+	rows := 0
+	for _, b := range filteredBlocks {
+		for _, receipt := range b.Receipts {
+			rows += len(receipt.Logs)
+		}
+	}
+	cols := newLogColumns(rows)
+
 	for _, normalizedBlock := range filteredBlocks {
 		block := normalizedBlock.Block
 		receipts := normalizedBlock.Receipts
@@ -940,7 +1114,7 @@ func InsertLogs(ctx context.Context, conn clickhouse.Conn, chainID uint32, block
 				return fmt.Errorf("failed to parse tx from: %w", err)
 			}
 
-			var txTo any = nil
+			var txTo []byte
 			if tx.To != "" && tx.To != "0x" {
 				txToBytes, err := hexToFixedBytes(tx.To, 20)
 				if err == nil {
@@ -976,7 +1150,7 @@ func InsertLogs(ctx context.Context, conn clickhouse.Conn, chainID uint32, block
 
 				// Topics: topic0 is non-nullable (empty for anonymous events), others nullable
 				var topic0 []byte
-				var topic1, topic2, topic3 any = nil, nil, nil
+				var topic1, topic2, topic3 []byte
 
 				if len(log.Topics) > 0 && log.Topics[0] != "" {
 					topic0, err = hexToFixedBytes(log.Topics[0], 32)
@@ -1014,31 +1188,80 @@ func InsertLogs(ctx context.Context, conn clickhouse.Conn, chainID uint32, block
 					data = []byte{} // Empty data if parsing fails
 				}
 
-				// Append to batch
-				err = batch.Append(
-					chainID,
-					address,
-					blockNumber,
-					blockHash,
-					blockTime,
-					txHash,
-					txIndex,
-					logIndex,
-					txFrom,
-					txTo,
-					topic0,
-					topic1,
-					topic2,
-					topic3,
-					string(data),
-					log.Removed,
-				)
-				if err != nil {
-					return fmt.Errorf("failed to append log: %w", err)
-				}
+				// Append to columns
+				cols.chainID = append(cols.chainID, chainID)
+				cols.address = append(cols.address, address)
+				cols.blockNumber = append(cols.blockNumber, blockNumber)
+				cols.blockHash = append(cols.blockHash, blockHash)
+				cols.blockTime = append(cols.blockTime, blockTime)
+				cols.transactionHash = append(cols.transactionHash, txHash)
+				cols.transactionIndex = append(cols.transactionIndex, txIndex)
+				cols.logIndex = append(cols.logIndex, logIndex)
+				cols.txFrom = append(cols.txFrom, txFrom)
+				cols.txTo = append(cols.txTo, txTo)
+				cols.topic0 = append(cols.topic0, topic0)
+				cols.topic1 = append(cols.topic1, topic1)
+				cols.topic2 = append(cols.topic2, topic2)
+				cols.topic3 = append(cols.topic3, topic3)
+				cols.data = append(cols.data, string(data))
+				cols.removed = append(cols.removed, log.Removed)
 			}
 		}
 	}
 
+	if err := chwrapper.AppendColumns(batch, cols.columns()...); err != nil {
+		return fmt.Errorf("failed to append logs: %w", err)
+	}
 	return batch.Send()
 }
+
+// logColumns holds the rows of a raw_logs insert column by column, with nil for NULL
+type logColumns struct {
+	chainID          []uint32
+	address          [][]byte
+	blockNumber      []uint32
+	blockHash        [][]byte
+	blockTime        []time.Time
+	transactionHash  [][]byte
+	transactionIndex []uint16
+	logIndex         []uint32
+	txFrom           [][]byte
+	txTo             [][]byte
+	topic0           [][]byte
+	topic1           [][]byte
+	topic2           [][]byte
+	topic3           [][]byte
+	data             []string
+	removed          []bool
+}
+
+// newLogColumns preallocates the columns of rows logs
+func newLogColumns(rows int) *logColumns {
+	return &logColumns{
+		chainID:          make([]uint32, 0, rows),
+		address:          make([][]byte, 0, rows),
+		blockNumber:      make([]uint32, 0, rows),
+		blockHash:        make([][]byte, 0, rows),
+		blockTime:        make([]time.Time, 0, rows),
+		transactionHash:  make([][]byte, 0, rows),
+		transactionIndex: make([]uint16, 0, rows),
+		logIndex:         make([]uint32, 0, rows),
+		txFrom:           make([][]byte, 0, rows),
+		txTo:             make([][]byte, 0, rows),
+		topic0:           make([][]byte, 0, rows),
+		topic1:           make([][]byte, 0, rows),
+		topic2:           make([][]byte, 0, rows),
+		topic3:           make([][]byte, 0, rows),
+		data:             make([]string, 0, rows),
+		removed:          make([]bool, 0, rows),
+	}
+}
+
+// columns returns the columns in the order of the raw_logs INSERT
+func (c *logColumns) columns() []any {
+	return []any{
+		c.chainID, c.address, c.blockNumber, c.blockHash, c.blockTime,
+		c.transactionHash, c.transactionIndex, c.logIndex, c.txFrom, c.txTo,
+		c.topic0, c.topic1, c.topic2, c.topic3, c.data, c.removed,
+	}
+}
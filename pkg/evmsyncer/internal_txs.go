@@ -0,0 +1,82 @@
+package evmsyncer
+
+import (
+	"icicle/pkg/evmrpc"
+	"context"
+	"fmt"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// InsertInternalTxs inserts the internal calls of each transaction (trace frames below the
+// top-level call) into internal_txs, with the error and reverted status raw_traces doesn't keep
+func InsertInternalTxs(ctx context.Context, conn clickhouse.Conn, chainID uint32, blocks []*evmrpc.NormalizedBlock, maxBlock uint32) error {
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO internal_txs (
+		chain_id, block_number, block_time, transaction_hash, transaction_index,
+		trace_address, type, from, to, value, gas, gas_used, error, reverted
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	for _, normalizedBlock := range blocks {
+		block := normalizedBlock.Block
+
+		blockNumber, err := hexToUint32(block.Number)
+		if err != nil {
+			return fmt.Errorf("failed to parse block number: %w", err)
+		}
+		if blockNumber <= maxBlock {
+			continue // Already in the table
+		}
+
+		blockTime, err := parseBlockTime(block)
+		if err != nil {
+			return err
+		}
+
+		for i, tx := range block.Transactions {
+			if i >= len(normalizedBlock.Traces) || normalizedBlock.Traces[i].Result == nil {
+				continue // No trace for this transaction
+			}
+			root := normalizedBlock.Traces[i].Result
+			if len(root.Calls) == 0 {
+				continue // No internal calls
+			}
+
+			txHash, err := hexToFixedBytes(tx.Hash, 32)
+			if err != nil {
+				return fmt.Errorf("failed to parse tx hash: %w", err)
+			}
+
+			frames, err := flattenTrace(root, tx.Hash, blockNumber, blockTime, uint16(i), []uint16{}, false, nil, nil)
+			if err != nil {
+				return fmt.Errorf("failed to flatten trace for tx %s: %w", tx.Hash, err)
+			}
+
+			for _, frame := range frames[1:] { // frames[0] is the transaction itself
+				err = batch.Append(
+					chainID,
+					blockNumber,
+					blockTime,
+					txHash,
+					uint16(i),
+					frame.TraceAddress,
+					frame.CallType,
+					frame.From,
+					frame.To,
+					frame.Value,
+					frame.Gas,
+					frame.GasUsed,
+					frame.Error,
+					frame.Reverted,
+				)
+				if err != nil {
+					return fmt.Errorf("failed to append internal tx: %w", err)
+				}
+			}
+		}
+	}
+
+	return batch.Send()
+}
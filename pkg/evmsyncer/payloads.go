@@ -0,0 +1,47 @@
+package evmsyncer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"icicle/pkg/evmrpc"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// InsertPayloads inserts each block as the fetcher caches it into raw_payloads, for cache hydrate.
+// Blocks fetched without traces are skipped, like the fetcher skips caching them.
+func InsertPayloads(ctx context.Context, conn clickhouse.Conn, chainID uint32, blocks []*evmrpc.NormalizedBlock, maxBlock uint32) error {
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO raw_payloads (chain_id, block_number, data)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	for _, normalizedBlock := range blocks {
+		if len(normalizedBlock.Traces) != len(normalizedBlock.Block.Transactions) {
+			continue
+		}
+
+		blockNumber, err := hexToUint32(normalizedBlock.Block.Number)
+		if err != nil {
+			return fmt.Errorf("failed to parse block number: %w", err)
+		}
+		if blockNumber <= maxBlock {
+			continue // Already in the table
+		}
+
+		// The proposer is set by the syncer after fetching, the cache never holds it
+		payload := *normalizedBlock
+		payload.Proposer = ""
+		data, err := json.Marshal(&payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal block %d: %w", blockNumber, err)
+		}
+
+		if err := batch.Append(chainID, blockNumber, data); err != nil {
+			return fmt.Errorf("failed to append payload: %w", err)
+		}
+	}
+
+	return batch.Send()
+}
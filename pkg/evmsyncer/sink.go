@@ -0,0 +1,267 @@
+package evmsyncer
+
+import (
+	"context"
+	"fmt"
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/evmrpc"
+	"icicle/pkg/tracing"
+	"log/slog"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"golang.org/x/sync/errgroup"
+)
+
+// Sink is the storage a syncer writes blocks and its sync watermark to. ClickHouse is the default,
+// other sinks only get blocks, so indexers, gap healing, the head table and chain control (pause
+// and resync) are off with them.
+type Sink interface {
+	// Load returns the chain's sync watermark, 0 if nothing is stored yet. It is called on start
+	// and on every resume, and prepares the writes that follow.
+	Load(ctx context.Context) (uint32, error)
+	// Write stores blocks in every table. A retried Write of the same blocks must not duplicate rows.
+	Write(ctx context.Context, blocks []*evmrpc.NormalizedBlock) error
+	// SetWatermark records that every table has the blocks up to block
+	SetWatermark(ctx context.Context, block uint32) error
+}
+
+// clickhouseSink writes blocks to the raw and decoded ClickHouse tables
+type clickhouseSink struct {
+	conn          driver.Conn
+	chainID       uint32
+	logger        *slog.Logger
+	skipLogs      bool // Log-derived tables are not written
+	storePayloads bool // Block payloads are written to raw_payloads for cache hydrate
+
+	insertSettings map[string]string // ClickHouse settings of every insert, e.g. async_insert
+
+	// Max block numbers in each table (queried on every Load)
+	maxBlockBlocks       uint32
+	maxBlockTransactions uint32
+	maxBlockTraces       uint32
+	maxBlockLogs         uint32
+	maxBlockTransfers    uint32
+	maxBlockNFTTransfers uint32
+	maxBlockContracts    uint32
+	maxBlockICMMessages  uint32
+	maxBlockInternalTxs  uint32
+	maxBlockUncles       uint32
+	maxBlockWithdrawals  uint32
+	maxBlockPayloads     uint32
+
+	// Set on every Load, part of insert deduplication tokens
+	epoch int64
+}
+
+// Load reads the watermark and the highest block of each table
+func (s *clickhouseSink) Load(ctx context.Context) (uint32, error) {
+	s.epoch = time.Now().UnixNano()
+
+	watermark, err := chwrapper.GetWatermark(s.conn, s.chainID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get watermark: %w", err)
+	}
+	if err := s.loadMaxBlocks(); err != nil {
+		return 0, err
+	}
+	return watermark, nil
+}
+
+// loadMaxBlocks queries the highest block of each table
+func (s *clickhouseSink) loadMaxBlocks() error {
+	var err error
+
+	// Query max block for each table on every load
+	// This is critical for preventing duplicates - we only insert blocks > maxBlock
+	s.maxBlockBlocks, err = chwrapper.GetLatestBlockForChain(s.conn, "raw_blocks", s.chainID)
+	if err != nil {
+		return fmt.Errorf("failed to get max block from blocks table: %w", err)
+	}
+
+	s.maxBlockTransactions, err = chwrapper.GetLatestBlockForChain(s.conn, "raw_txs", s.chainID)
+	if err != nil {
+		return fmt.Errorf("failed to get max block from transactions table: %w", err)
+	}
+
+	s.maxBlockTraces, err = chwrapper.GetLatestBlockForChain(s.conn, "raw_traces", s.chainID)
+	if err != nil {
+		return fmt.Errorf("failed to get max block from traces table: %w", err)
+	}
+
+	s.maxBlockLogs, err = chwrapper.GetLatestBlockForChain(s.conn, "raw_logs", s.chainID)
+	if err != nil {
+		return fmt.Errorf("failed to get max block from logs table: %w", err)
+	}
+
+	s.maxBlockTransfers, err = chwrapper.GetLatestBlockForChain(s.conn, "erc20_transfers", s.chainID)
+	if err != nil {
+		return fmt.Errorf("failed to get max block from erc20 transfers table: %w", err)
+	}
+
+	s.maxBlockNFTTransfers, err = chwrapper.GetLatestBlockForChain(s.conn, "nft_transfers", s.chainID)
+	if err != nil {
+		return fmt.Errorf("failed to get max block from nft transfers table: %w", err)
+	}
+
+	s.maxBlockContracts, err = chwrapper.GetLatestBlockForChain(s.conn, "contracts", s.chainID)
+	if err != nil {
+		return fmt.Errorf("failed to get max block from contracts table: %w", err)
+	}
+
+	s.maxBlockICMMessages, err = chwrapper.GetLatestBlockForChain(s.conn, "icm_messages", s.chainID)
+	if err != nil {
+		return fmt.Errorf("failed to get max block from icm messages table: %w", err)
+	}
+
+	s.maxBlockInternalTxs, err = chwrapper.GetLatestBlockForChain(s.conn, "internal_txs", s.chainID)
+	if err != nil {
+		return fmt.Errorf("failed to get max block from internal txs table: %w", err)
+	}
+
+	s.maxBlockUncles, err = chwrapper.GetLatestBlockForChain(s.conn, "raw_uncles", s.chainID)
+	if err != nil {
+		return fmt.Errorf("failed to get max block from uncles table: %w", err)
+	}
+
+	s.maxBlockWithdrawals, err = chwrapper.GetLatestBlockForChain(s.conn, "raw_withdrawals", s.chainID)
+	if err != nil {
+		return fmt.Errorf("failed to get max block from withdrawals table: %w", err)
+	}
+
+	if s.storePayloads {
+		s.maxBlockPayloads, err = chwrapper.GetLatestBlockForChain(s.conn, "raw_payloads", s.chainID)
+		if err != nil {
+			return fmt.Errorf("failed to get max block from payloads table: %w", err)
+		}
+	}
+
+	s.logger.Info("Max blocks in tables",
+		"blocks", s.maxBlockBlocks, "txs", s.maxBlockTransactions, "traces", s.maxBlockTraces, "logs", s.maxBlockLogs,
+		"erc20_transfers", s.maxBlockTransfers, "nft_transfers", s.maxBlockNFTTransfers, "contracts", s.maxBlockContracts, "icm_messages", s.maxBlockICMMessages,
+		"internal_txs", s.maxBlockInternalTxs, "uncles", s.maxBlockUncles, "withdrawals", s.maxBlockWithdrawals)
+
+	return nil
+}
+
+// Write inserts blocks above each table's highest block, retries reuse the deduplication tokens
+// of the failed write so inserts that landed before the failure are skipped
+func (s *clickhouseSink) Write(ctx context.Context, blocks []*evmrpc.NormalizedBlock) error {
+	return s.insert(ctx, blocks, s.epoch, false)
+}
+
+// SetWatermark updates sync_watermark
+func (s *clickhouseSink) SetWatermark(ctx context.Context, block uint32) error {
+	return chwrapper.SetWatermark(s.conn, s.chainID, block)
+}
+
+// insert inserts blocks into all tables in parallel, with deduplication tokens of epoch.
+// Each table only gets the blocks above its highest block at the last Load, unless heal is set.
+func (s *clickhouseSink) insert(ctx context.Context, blocks []*evmrpc.NormalizedBlock, epoch int64, heal bool) error {
+	ctx = chwrapper.WithInsertSettings(ctx, s.insertSettings)
+
+	// Tokens make retries of a write in the same epoch skip inserts that already landed
+	from, to := blockRange(blocks)
+	dedup := func(ctx context.Context, table string) context.Context {
+		return chwrapper.WithDedupToken(ctx, chwrapper.DedupToken(s.chainID, table, epoch, from, to))
+	}
+	above := func(maxBlock uint32) uint32 {
+		if heal {
+			return 0
+		}
+		return maxBlock
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	// Insert to blocks table
+	g.Go(func() error {
+		return tracing.Run(ctx, tracer, "evmsyncer.InsertBlocks", func(ctx context.Context) error {
+			return InsertBlocks(dedup(ctx, "raw_blocks"), s.conn, s.chainID, blocks, above(s.maxBlockBlocks))
+		})
+	})
+
+	// Insert to transactions table
+	g.Go(func() error {
+		return tracing.Run(ctx, tracer, "evmsyncer.InsertTransactions", func(ctx context.Context) error {
+			return InsertTransactions(dedup(ctx, "raw_txs"), s.conn, s.chainID, blocks, above(s.maxBlockTransactions))
+		})
+	})
+
+	// Insert to traces table
+	g.Go(func() error {
+		return tracing.Run(ctx, tracer, "evmsyncer.InsertTraces", func(ctx context.Context) error {
+			return InsertTraces(dedup(ctx, "raw_traces"), s.conn, s.chainID, blocks, above(s.maxBlockTraces))
+		})
+	})
+
+	// Log-derived tables, skipped for chains ingested without logs
+	if !s.skipLogs {
+		// Insert to logs table
+		g.Go(func() error {
+			return tracing.Run(ctx, tracer, "evmsyncer.InsertLogs", func(ctx context.Context) error {
+				return InsertLogs(dedup(ctx, "raw_logs"), s.conn, s.chainID, blocks, above(s.maxBlockLogs))
+			})
+		})
+
+		// Insert decoded ERC-20 transfers
+		g.Go(func() error {
+			return tracing.Run(ctx, tracer, "evmsyncer.InsertERC20Transfers", func(ctx context.Context) error {
+				return InsertERC20Transfers(dedup(ctx, "erc20_transfers"), s.conn, s.chainID, blocks, above(s.maxBlockTransfers))
+			})
+		})
+
+		// Insert decoded NFT transfers
+		g.Go(func() error {
+			return tracing.Run(ctx, tracer, "evmsyncer.InsertNFTTransfers", func(ctx context.Context) error {
+				return InsertNFTTransfers(dedup(ctx, "nft_transfers"), s.conn, s.chainID, blocks, above(s.maxBlockNFTTransfers))
+			})
+		})
+
+		// Insert decoded ICM messages
+		g.Go(func() error {
+			return tracing.Run(ctx, tracer, "evmsyncer.InsertICMMessages", func(ctx context.Context) error {
+				return InsertICMMessages(dedup(ctx, "icm_messages"), s.conn, s.chainID, blocks, above(s.maxBlockICMMessages))
+			})
+		})
+	}
+
+	// Insert contracts deployed by these blocks
+	g.Go(func() error {
+		return tracing.Run(ctx, tracer, "evmsyncer.InsertContracts", func(ctx context.Context) error {
+			return InsertContracts(dedup(ctx, "contracts"), s.conn, s.chainID, blocks, above(s.maxBlockContracts))
+		})
+	})
+
+	// Insert internal transactions flattened from traces
+	g.Go(func() error {
+		return tracing.Run(ctx, tracer, "evmsyncer.InsertInternalTxs", func(ctx context.Context) error {
+			return InsertInternalTxs(dedup(ctx, "internal_txs"), s.conn, s.chainID, blocks, above(s.maxBlockInternalTxs))
+		})
+	})
+
+	// Insert uncle headers
+	g.Go(func() error {
+		return tracing.Run(ctx, tracer, "evmsyncer.InsertUncles", func(ctx context.Context) error {
+			return InsertUncles(dedup(ctx, "raw_uncles"), s.conn, s.chainID, blocks, above(s.maxBlockUncles))
+		})
+	})
+
+	// Insert withdrawals
+	g.Go(func() error {
+		return tracing.Run(ctx, tracer, "evmsyncer.InsertWithdrawals", func(ctx context.Context) error {
+			return InsertWithdrawals(dedup(ctx, "raw_withdrawals"), s.conn, s.chainID, blocks, above(s.maxBlockWithdrawals))
+		})
+	})
+
+	// Insert cache payloads for cache hydrate
+	if s.storePayloads {
+		g.Go(func() error {
+			return tracing.Run(ctx, tracer, "evmsyncer.InsertPayloads", func(ctx context.Context) error {
+				return InsertPayloads(dedup(ctx, "raw_payloads"), s.conn, s.chainID, blocks, above(s.maxBlockPayloads))
+			})
+		})
+	}
+
+	return g.Wait()
+}
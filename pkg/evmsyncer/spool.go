@@ -0,0 +1,100 @@
+package evmsyncer
+
+import (
+	"encoding/json"
+	"fmt"
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/evmrpc"
+	"icicle/pkg/logging"
+	"icicle/pkg/metrics"
+	"time"
+)
+
+// SpoolRetryInterval is how long the writer waits after a failed replay of spooled batches
+// before trying the database again
+const SpoolRetryInterval = 5 * time.Second
+
+// writeOrSpool writes blocks, or appends them to the spool when the database is unreachable or
+// earlier batches are still spooled, so batches always reach the database in order. Returns
+// whether the blocks were spooled, and spool.ErrFull if they fit neither. Errors that aren't
+// transient, e.g. a schema mismatch, are returned without spooling, since replays would fail too.
+func (cs *ChainSyncer) writeOrSpool(blocks []*evmrpc.NormalizedBlock) (bool, error) {
+	if cs.spool == nil {
+		return false, cs.writeBlocksWithRetry(blocks)
+	}
+
+	if cs.drainSpool() {
+		err := cs.writeBlocksWithRetry(blocks)
+		if err == nil || !chwrapper.IsTransient(err) {
+			return false, err
+		}
+		cs.logger.Warn("Database unreachable, spooling batches to disk until it is back", "error", err)
+		cs.spoolRetryAt = time.Now().Add(SpoolRetryInterval)
+	}
+
+	data, err := json.Marshal(blocks)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode blocks for the spool: %w", err)
+	}
+	if err := cs.spool.Push(data); err != nil {
+		return false, err
+	}
+	metrics.SetSpooled(cs.chainId, cs.spool.Len(), cs.spool.Size())
+	return true, nil
+}
+
+// drainSpool replays spooled batches oldest first and returns whether the spool is empty. A
+// failed replay leaves the batch spooled and holds off retries for SpoolRetryInterval.
+func (cs *ChainSyncer) drainSpool() bool {
+	replayed := 0
+	for cs.spool.Len() > 0 {
+		if time.Now().Before(cs.spoolRetryAt) || cs.ctx.Err() != nil {
+			return false
+		}
+
+		data, err := cs.spool.Peek()
+		if err != nil {
+			logging.Fatal(cs.logger, "Failed to read spooled batch", "error", err)
+		}
+		var blocks []*evmrpc.NormalizedBlock
+		if err := json.Unmarshal(data, &blocks); err != nil {
+			logging.Fatal(cs.logger, "Failed to decode spooled batch", "error", err)
+		}
+
+		if err := cs.writeBlocks(blocks); err != nil {
+			if !chwrapper.IsTransient(err) {
+				logging.Fatal(cs.logger, "Failed to replay spooled batch", "error", err)
+			}
+			cs.logger.Warn("Database still unreachable, keeping batches spooled",
+				"batches", cs.spool.Len(), "bytes", cs.spool.Size(), "error", err)
+			cs.spoolRetryAt = time.Now().Add(SpoolRetryInterval)
+			return false
+		}
+		// A batch replayed again after a failed Pop is skipped by the deduplication tokens
+		if err := cs.spool.Pop(); err != nil {
+			logging.Fatal(cs.logger, "Failed to remove replayed batch from the spool", "error", err)
+		}
+		metrics.SetSpooled(cs.chainId, cs.spool.Len(), cs.spool.Size())
+
+		cs.mu.Lock()
+		cs.blocksWritten += int64(len(blocks))
+		cs.mu.Unlock()
+		replayed++
+	}
+
+	if replayed > 0 {
+		cs.logger.Info("Replayed spooled batches", "batches", replayed, "watermark", cs.watermark)
+	}
+	return true
+}
+
+// clearSpool drops the spooled batches, when the writer drops what it buffered for a pause
+func (cs *ChainSyncer) clearSpool() {
+	if cs.spool == nil {
+		return
+	}
+	if err := cs.spool.Clear(); err != nil {
+		logging.Fatal(cs.logger, "Failed to clear spool", "error", err)
+	}
+	metrics.SetSpooled(cs.chainId, 0, 0)
+}
@@ -0,0 +1,95 @@
+package evmsyncer
+
+import (
+	"icicle/pkg/evmrpc"
+	"context"
+	"fmt"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// InsertUncles inserts the uncle headers carried by blocks into raw_uncles. Blocks only carry
+// them when the fetcher was created with FetchUncles.
+func InsertUncles(ctx context.Context, conn clickhouse.Conn, chainID uint32, blocks []*evmrpc.NormalizedBlock, maxBlock uint32) error {
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO raw_uncles (
+		chain_id, block_number, block_time, uncle_index, hash, uncle_number,
+		uncle_time, parent_hash, miner, difficulty, gas_limit, gas_used
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	for _, normalizedBlock := range blocks {
+		if len(normalizedBlock.Uncles) == 0 {
+			continue
+		}
+
+		blockNumber, err := hexToUint32(normalizedBlock.Block.Number)
+		if err != nil {
+			return fmt.Errorf("failed to parse block number: %w", err)
+		}
+		if blockNumber <= maxBlock {
+			continue // Already in the table
+		}
+
+		blockTime, err := parseBlockTime(normalizedBlock.Block)
+		if err != nil {
+			return err
+		}
+
+		for i, uncle := range normalizedBlock.Uncles {
+			hash, err := hexToFixedBytes(uncle.Hash, 32)
+			if err != nil {
+				return fmt.Errorf("failed to parse uncle hash: %w", err)
+			}
+			uncleNumber, err := hexToUint32(uncle.Number)
+			if err != nil {
+				return fmt.Errorf("failed to parse uncle number: %w", err)
+			}
+			uncleTime, err := parseBlockTime(uncle)
+			if err != nil {
+				return err
+			}
+			parentHash, err := hexToFixedBytes(uncle.ParentHash, 32)
+			if err != nil {
+				return fmt.Errorf("failed to parse uncle parent hash: %w", err)
+			}
+			miner, err := hexToFixedBytes(uncle.Miner, 20)
+			if err != nil {
+				return fmt.Errorf("failed to parse uncle miner: %w", err)
+			}
+			difficulty, err := hexToBigInt(uncle.Difficulty)
+			if err != nil {
+				return fmt.Errorf("failed to parse uncle difficulty: %w", err)
+			}
+			gasLimit, err := hexToUint32(uncle.GasLimit)
+			if err != nil {
+				return fmt.Errorf("failed to parse uncle gas limit: %w", err)
+			}
+			gasUsed, err := hexToUint32(uncle.GasUsed)
+			if err != nil {
+				return fmt.Errorf("failed to parse uncle gas used: %w", err)
+			}
+
+			err = batch.Append(
+				chainID,
+				blockNumber,
+				blockTime,
+				uint8(i),
+				hash,
+				uncleNumber,
+				uncleTime,
+				parentHash,
+				miner,
+				difficulty,
+				gasLimit,
+				gasUsed,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to append uncle: %w", err)
+			}
+		}
+	}
+
+	return batch.Send()
+}
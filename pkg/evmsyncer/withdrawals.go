@@ -0,0 +1,73 @@
+package evmsyncer
+
+import (
+	"icicle/pkg/evmrpc"
+	"context"
+	"fmt"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// InsertWithdrawals inserts the EIP-4895 withdrawals of post-Shanghai blocks into raw_withdrawals
+func InsertWithdrawals(ctx context.Context, conn clickhouse.Conn, chainID uint32, blocks []*evmrpc.NormalizedBlock, maxBlock uint32) error {
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO raw_withdrawals (
+		chain_id, block_number, block_time, withdrawal_index, validator_index, address, amount
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	for _, normalizedBlock := range blocks {
+		block := normalizedBlock.Block
+		if len(block.Withdrawals) == 0 {
+			continue
+		}
+
+		blockNumber, err := hexToUint32(block.Number)
+		if err != nil {
+			return fmt.Errorf("failed to parse block number: %w", err)
+		}
+		if blockNumber <= maxBlock {
+			continue // Already in the table
+		}
+
+		blockTime, err := parseBlockTime(block)
+		if err != nil {
+			return err
+		}
+
+		for _, withdrawal := range block.Withdrawals {
+			index, err := hexToUint64(withdrawal.Index)
+			if err != nil {
+				return fmt.Errorf("failed to parse withdrawal index: %w", err)
+			}
+			validatorIndex, err := hexToUint64(withdrawal.ValidatorIndex)
+			if err != nil {
+				return fmt.Errorf("failed to parse withdrawal validator index: %w", err)
+			}
+			address, err := hexToFixedBytes(withdrawal.Address, 20)
+			if err != nil {
+				return fmt.Errorf("failed to parse withdrawal address: %w", err)
+			}
+			amount, err := hexToUint64(withdrawal.Amount)
+			if err != nil {
+				return fmt.Errorf("failed to parse withdrawal amount: %w", err)
+			}
+
+			err = batch.Append(
+				chainID,
+				blockNumber,
+				blockTime,
+				index,
+				validatorIndex,
+				address,
+				amount,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to append withdrawal: %w", err)
+			}
+		}
+	}
+
+	return batch.Send()
+}
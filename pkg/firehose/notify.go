@@ -0,0 +1,31 @@
+package firehose
+
+import "sync"
+
+var (
+	notifyMu sync.Mutex
+	waiters  = make(map[uint32]chan struct{})
+)
+
+// Notify wakes the streams of a chain after its sync watermark moved. Syncers call it after every
+// watermark update, streams fall back to polling when ingest runs in another process.
+func Notify(chainID uint32) {
+	notifyMu.Lock()
+	defer notifyMu.Unlock()
+	if ch, ok := waiters[chainID]; ok {
+		close(ch)
+		delete(waiters, chainID)
+	}
+}
+
+// watermarkMoved returns a channel closed on the next Notify for chainID
+func watermarkMoved(chainID uint32) <-chan struct{} {
+	notifyMu.Lock()
+	defer notifyMu.Unlock()
+	ch, ok := waiters[chainID]
+	if !ok {
+		ch = make(chan struct{})
+		waiters[chainID] = ch
+	}
+	return ch
+}
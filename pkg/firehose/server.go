@@ -0,0 +1,336 @@
+package firehose
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"time"
+
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/txblob"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// ReadBatchSize is the number of blocks read from ClickHouse per query
+	ReadBatchSize = 100
+	// PollInterval is how often a caught-up stream checks the watermark without a Notify
+	PollInterval = 5 * time.Second
+)
+
+// firehoseService is implemented by Server, for grpc.ServiceDesc.HandlerType
+type firehoseService interface {
+	subscribe(req *structpb.Struct, stream grpc.ServerStream) error
+}
+
+// serviceDesc describes icicle.v1.Firehose (firehose.proto) without generated code
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "icicle.v1.Firehose",
+	HandlerType: (*firehoseService)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			ServerStreams: true,
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				req := new(structpb.Struct)
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(firehoseService).subscribe(req, stream)
+			},
+		},
+	},
+	Metadata: "firehose.proto",
+}
+
+// Server streams the blocks of EVM chains and the P-Chain over gRPC once they are below the sync
+// watermark, so every table already has them. Streams replay from ClickHouse and then follow ingest.
+//
+// Each message is one block:
+//
+//	EVM:     chain_id, height, hash, parent_hash, time, gas_used, proposer,
+//	         txs [{hash, index, from, to (empty for creations), value (decimal string), type, success, gas_used}]
+//	P-Chain: chain_id, height, hash, parent_hash, time, block_type, proposer,
+//	         txs [{tx_id, tx_type, memo_text, tx_data}]
+type Server struct {
+	conn   driver.Conn
+	vms    map[uint32]string // VM of each streamable chain, "evm" or "p"
+	token  string
+	logger *slog.Logger
+}
+
+// NewServer returns a server for the chains in vms. Requests must carry token as a bearer token
+// in the authorization metadata unless it is empty.
+func NewServer(conn driver.Conn, vms map[uint32]string, token string) *Server {
+	return &Server{
+		conn:   conn,
+		vms:    vms,
+		token:  token,
+		logger: slog.With("component", "firehose"),
+	}
+}
+
+// Serve accepts gRPC connections on listener until it fails
+func (s *Server) Serve(listener net.Listener) error {
+	server := grpc.NewServer(grpc.StreamInterceptor(s.authorize))
+	server.RegisterService(&serviceDesc, s)
+	return server.Serve(listener)
+}
+
+// authorize rejects streams without the server's token
+func (s *Server) authorize(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if s.token != "" {
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		auth := md.Get("authorization")
+		if len(auth) == 0 || subtle.ConstantTimeCompare([]byte(auth[0]), []byte("Bearer "+s.token)) != 1 {
+			return status.Error(codes.Unauthenticated, "missing or invalid token")
+		}
+	}
+	return handler(srv, stream)
+}
+
+func (s *Server) subscribe(req *structpb.Struct, stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	id, ok, err := uintField(req, "chain_id", math.MaxUint32)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return status.Error(codes.InvalidArgument, "chain_id is required")
+	}
+	chainID := uint32(id)
+	next, fromHeight, err := uintField(req, "from_height", math.MaxUint32)
+	if err != nil {
+		return err
+	}
+	vm, ok := s.vms[chainID]
+	if !ok {
+		return status.Errorf(codes.NotFound, "chain %d is not ingested by this instance", chainID)
+	}
+
+	watermark, err := chwrapper.GetWatermark(s.conn, chainID)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to read watermark: %v", err)
+	}
+	if !fromHeight {
+		next = uint64(watermark) + 1
+	}
+	s.logger.Info("Stream started", "chain_id", chainID, "from", next)
+
+	for {
+		// Registered before reading the watermark, so a move in between isn't missed
+		moved := watermarkMoved(chainID)
+		watermark, err := chwrapper.GetWatermark(s.conn, chainID)
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to read watermark: %v", err)
+		}
+
+		if next > uint64(watermark) {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-moved:
+			case <-time.After(PollInterval):
+			}
+			continue
+		}
+
+		to := min(next+ReadBatchSize-1, uint64(watermark))
+		var blocks []map[string]any
+		if vm == "p" {
+			blocks, err = s.readPChainBlocks(ctx, chainID, next, to)
+		} else {
+			blocks, err = s.readEVMBlocks(ctx, chainID, next, to)
+		}
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to read blocks %d-%d: %v", next, to, err)
+		}
+
+		for _, block := range blocks {
+			msg, err := structpb.NewStruct(block)
+			if err != nil {
+				return status.Errorf(codes.Internal, "failed to encode block: %v", err)
+			}
+			if err := stream.SendMsg(msg); err != nil {
+				return err
+			}
+		}
+		next = to + 1
+	}
+}
+
+// uintField returns the whole number in field name of req, ok false if the field is absent. Other
+// values than whole numbers up to limit are rejected, rather than truncated to another chain or height.
+func uintField(req *structpb.Struct, name string, limit uint64) (value uint64, ok bool, err error) {
+	field, ok := req.GetFields()[name]
+	if !ok {
+		return 0, false, nil
+	}
+	number, isNumber := field.GetKind().(*structpb.Value_NumberValue)
+	if !isNumber || number.NumberValue < 0 || number.NumberValue != math.Trunc(number.NumberValue) ||
+		number.NumberValue > float64(limit) {
+		return 0, false, status.Errorf(codes.InvalidArgument, "%s must be a whole number from 0 to %d", name, limit)
+	}
+	return uint64(number.NumberValue), true, nil
+}
+
+// readEVMBlocks returns blocks [from, to] of an EVM chain with their transactions
+func (s *Server) readEVMBlocks(ctx context.Context, chainID uint32, from, to uint64) ([]map[string]any, error) {
+	rows, err := s.conn.Query(ctx, `
+		SELECT block_number, concat('0x', lower(hex(hash))), concat('0x', lower(hex(parent_hash))),
+		       block_time, gas_used, proposer
+		FROM raw_blocks
+		WHERE chain_id = ? AND block_number BETWEEN ? AND ?
+		ORDER BY block_number
+		LIMIT 1 BY block_number`, chainID, uint32(from), uint32(to))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocks: %w", err)
+	}
+	defer rows.Close()
+
+	var blocks []map[string]any
+	byHeight := make(map[uint32]map[string]any)
+	for rows.Next() {
+		var height, gasUsed uint32
+		var hash, parentHash, proposer string
+		var blockTime time.Time
+		if err := rows.Scan(&height, &hash, &parentHash, &blockTime, &gasUsed, &proposer); err != nil {
+			return nil, fmt.Errorf("failed to scan block: %w", err)
+		}
+		block := map[string]any{
+			"chain_id":    chainID,
+			"height":      height,
+			"hash":        hash,
+			"parent_hash": parentHash,
+			"time":        blockTime.Format(time.RFC3339Nano),
+			"gas_used":    gasUsed,
+			"proposer":    proposer,
+			"txs":         []any{},
+		}
+		blocks = append(blocks, block)
+		byHeight[height] = block
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	txRows, err := s.conn.Query(ctx, `
+		SELECT block_number, concat('0x', lower(hex(hash))), transaction_index, concat('0x', lower(hex(from))),
+		       if(isNull(to), '', concat('0x', lower(hex(assumeNotNull(to))))), toString(value), type, success, gas_used
+		FROM raw_txs
+		WHERE chain_id = ? AND block_number BETWEEN ? AND ?
+		ORDER BY block_number, transaction_index
+		LIMIT 1 BY block_number, transaction_index`, chainID, uint32(from), uint32(to))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query txs: %w", err)
+	}
+	defer txRows.Close()
+
+	for txRows.Next() {
+		var height, gasUsed uint32
+		var index uint16
+		var hash, fromAddr, toAddr, value string
+		var txType uint8
+		var success bool
+		if err := txRows.Scan(&height, &hash, &index, &fromAddr, &toAddr, &value, &txType, &success, &gasUsed); err != nil {
+			return nil, fmt.Errorf("failed to scan tx: %w", err)
+		}
+		if block, ok := byHeight[height]; ok {
+			block["txs"] = append(block["txs"].([]any), map[string]any{
+				"hash":     hash,
+				"index":    uint32(index),
+				"from":     fromAddr,
+				"to":       toAddr,
+				"value":    value,
+				"type":     uint32(txType),
+				"success":  success,
+				"gas_used": gasUsed,
+			})
+		}
+	}
+	return blocks, txRows.Err()
+}
+
+// readPChainBlocks returns blocks [from, to] of the P-Chain with their transactions
+func (s *Server) readPChainBlocks(ctx context.Context, pChainID uint32, from, to uint64) ([]map[string]any, error) {
+	rows, err := s.conn.Query(ctx, `
+		SELECT height, block_id, parent_id, block_time, block_type, proposer
+		FROM p_chain_blocks FINAL
+		WHERE p_chain_id = ? AND height BETWEEN ? AND ?
+		ORDER BY height`, pChainID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocks: %w", err)
+	}
+	defer rows.Close()
+
+	var blocks []map[string]any
+	byHeight := make(map[uint64]map[string]any)
+	for rows.Next() {
+		var height uint64
+		var blockID, parentID, blockType, proposer string
+		var blockTime time.Time
+		if err := rows.Scan(&height, &blockID, &parentID, &blockTime, &blockType, &proposer); err != nil {
+			return nil, fmt.Errorf("failed to scan block: %w", err)
+		}
+		block := map[string]any{
+			"chain_id":    pChainID,
+			"height":      height,
+			"hash":        blockID,
+			"parent_hash": parentID,
+			"time":        blockTime.Format(time.RFC3339Nano),
+			"block_type":  blockType,
+			"proposer":    proposer,
+			"txs":         []any{},
+		}
+		blocks = append(blocks, block)
+		byHeight[height] = block
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	txRows, err := s.conn.Query(ctx, `
+		SELECT block_number, tx_id, tx_type, memo_text, toJSONString(tx_data), tx_blobs
+		FROM p_chain_txs FINAL
+		WHERE p_chain_id = ? AND block_number BETWEEN ? AND ?
+		ORDER BY block_number, tx_id`, pChainID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query txs: %w", err)
+	}
+	defer txRows.Close()
+
+	for txRows.Next() {
+		var height uint64
+		var txID, txType, memoText, txData, txBlob string
+		if err := txRows.Scan(&height, &txID, &txType, &memoText, &txData, &txBlob); err != nil {
+			return nil, fmt.Errorf("failed to scan tx: %w", err)
+		}
+		expanded, err := txblob.Expand([]byte(txData), []byte(txBlob))
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand tx %s: %w", txID, err)
+		}
+		var data any
+		if err := json.Unmarshal(expanded, &data); err != nil {
+			return nil, fmt.Errorf("failed to decode tx %s: %w", txID, err)
+		}
+		if block, ok := byHeight[height]; ok {
+			block["txs"] = append(block["txs"].([]any), map[string]any{
+				"tx_id":     txID,
+				"tx_type":   txType,
+				"memo_text": memoText,
+				"tx_data":   data,
+			})
+		}
+	}
+	return blocks, txRows.Err()
+}
@@ -0,0 +1,401 @@
+package hypersdkrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"icicle/pkg/breaker"
+	"icicle/pkg/cache"
+	"icicle/pkg/httpcompress"
+	"icicle/pkg/logging"
+	"icicle/pkg/metrics"
+	"icicle/pkg/tracing"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/hashing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("hypersdkrpc")
+
+// FetcherOptions configures the hypersdk fetcher
+type FetcherOptions struct {
+	RpcURL         string            // Chain base URL, e.g. http://127.0.0.1:9650/ext/bc/<blockchainID>
+	RpcHeaders     map[string]string // Extra HTTP headers sent with every request, e.g. API keys
+	NoCompression  bool              // Don't ask for gzip/deflate compressed responses
+	ChainID        uint32            // Chain ID, tags log records and spans
+	ChainName      string            // Chain name, tags log records
+	MaxConcurrency int               // Maximum concurrent RPC requests
+	MaxRetries     int               // Maximum number of retries per request
+	RetryDelay     time.Duration     // Initial retry delay
+	Cache          cache.Cache       // Optional cache for getBlockByHeight replies
+}
+
+// Fetcher fetches blocks from a hypersdk chain's core and indexer JSON-RPC APIs
+type Fetcher struct {
+	coreURL    string
+	indexerURL string
+	headers    map[string]string
+	httpClient *http.Client
+	maxRetries int
+	retryDelay time.Duration
+	cache      cache.Cache
+	chainID    uint32
+	logger     *slog.Logger
+
+	// Concurrency control
+	rpcLimit       chan struct{}
+	maxConcurrency int
+	limitMu        sync.Mutex
+	reserved       int // Slots of rpcLimit held back by SetConcurrency
+}
+
+func NewFetcher(opts FetcherOptions) *Fetcher {
+	if opts.MaxConcurrency == 0 {
+		opts.MaxConcurrency = 20
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.RetryDelay == 0 {
+		opts.RetryDelay = 500 * time.Millisecond
+	}
+
+	transport := &http.Transport{
+		MaxIdleConns:        1000,
+		MaxIdleConnsPerHost: 1000,
+		IdleConnTimeout:     90 * time.Second,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+	}
+
+	baseURL := strings.TrimSuffix(opts.RpcURL, "/")
+	return &Fetcher{
+		coreURL:    baseURL + "/coreapi",
+		indexerURL: baseURL + "/indexer",
+		headers:    opts.RpcHeaders,
+		httpClient: &http.Client{
+			Timeout:   time.Minute,
+			Transport: httpcompress.Wrap(transport, !opts.NoCompression),
+		},
+		maxRetries:     opts.MaxRetries,
+		retryDelay:     opts.RetryDelay,
+		cache:          opts.Cache,
+		chainID:        opts.ChainID,
+		logger:         logging.Chain("hypersdkrpc", opts.ChainID, opts.ChainName),
+		rpcLimit:       make(chan struct{}, opts.MaxConcurrency),
+		maxConcurrency: opts.MaxConcurrency,
+	}
+}
+
+// SetConcurrency lowers the number of concurrent RPC requests below MaxConcurrency by
+// holding back slots. Waits for in-flight requests to free the slots it takes.
+func (f *Fetcher) SetConcurrency(n int) {
+	n = max(1, min(n, f.maxConcurrency))
+
+	f.limitMu.Lock()
+	defer f.limitMu.Unlock()
+
+	for target := f.maxConcurrency - n; f.reserved < target; f.reserved++ {
+		f.rpcLimit <- struct{}{}
+	}
+	for target := f.maxConcurrency - n; f.reserved > target; f.reserved-- {
+		<-f.rpcLimit
+	}
+}
+
+// call sends a JSON-RPC request and returns the raw result, retrying with exponential backoff
+func (f *Fetcher) call(url, method string, params any) (json.RawMessage, error) {
+	reqBody, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= f.maxRetries; attempt++ {
+		if attempt > 0 {
+			delay := f.retryDelay * time.Duration(1<<uint(attempt-1))
+			if delay > 10*time.Second {
+				delay = 10 * time.Second
+			}
+			time.Sleep(delay)
+		}
+
+		f.rpcLimit <- struct{}{}
+		result, err := f.post(url, method, reqBody)
+		<-f.rpcLimit
+		if err == nil {
+			return result, nil
+		}
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("%s failed after %d retries: %w", method, f.maxRetries, lastErr)
+}
+
+func (f *Fetcher) post(url, method string, reqBody []byte) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(context.Background(), "POST", url, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range f.headers {
+		req.Header.Set(key, value)
+	}
+
+	b := breaker.For(url)
+	b.Wait(context.Background())
+	start := time.Now()
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		metrics.ObserveRPC(b.Endpoint(), method, start, metrics.ErrTransport)
+		err = fmt.Errorf("failed to send request: %w", err)
+		b.Failure(err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		metrics.ObserveRPC(b.Endpoint(), method, start, metrics.ErrStatus)
+		err := fmt.Errorf("HTTP %d", resp.StatusCode)
+		b.Failure(err)
+		return nil, err
+	}
+
+	var rpcResp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		metrics.ObserveRPC(b.Endpoint(), method, start, metrics.ErrDecode)
+		err = fmt.Errorf("failed to decode response: %w", err)
+		b.Failure(err)
+		return nil, err
+	}
+	b.Success()
+	if rpcResp.Error != nil {
+		metrics.ObserveRPC(b.Endpoint(), method, start, metrics.ErrReply)
+		return nil, fmt.Errorf("rpc error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+	}
+	metrics.ObserveRPC(b.Endpoint(), method, start, "")
+	return rpcResp.Result, nil
+}
+
+// GetLatestBlock returns the height of the last accepted block
+func (f *Fetcher) GetLatestBlock() (int64, error) {
+	result, err := f.call(f.coreURL, "hypersdk.lastAccepted", struct{}{})
+	if err != nil {
+		return 0, err
+	}
+
+	var reply LastAcceptedResponse
+	if err := json.Unmarshal(result, &reply); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal lastAccepted: %w", err)
+	}
+	return int64(reply.Height), nil
+}
+
+// FetchBlockRange fetches and normalizes all blocks in the range [from, to] inclusive
+func (f *Fetcher) FetchBlockRange(from, to int64) ([]*NormalizedBlock, error) {
+	if from > to {
+		return nil, fmt.Errorf("invalid range: from %d > to %d", from, to)
+	}
+
+	_, span := tracer.Start(context.Background(), "hypersdkrpc.FetchBlockRange", trace.WithAttributes(tracing.Range(f.chainID, from, to)...))
+	defer span.End()
+
+	blocks := make([]*NormalizedBlock, to-from+1)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var fetchErr error
+
+	for i := range blocks {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+
+			height := from + int64(idx)
+			block, err := f.fetchBlock(height)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if fetchErr == nil {
+					fetchErr = fmt.Errorf("failed to fetch block %d: %w", height, err)
+				}
+				return
+			}
+			blocks[idx] = block
+		}(i)
+	}
+
+	wg.Wait()
+
+	if fetchErr != nil {
+		tracing.RecordError(span, fetchErr)
+		return nil, fetchErr
+	}
+	return blocks, nil
+}
+
+// fetchBlock fetches a block from the indexer API, or the cache
+func (f *Fetcher) fetchBlock(height int64) (*NormalizedBlock, error) {
+	fetch := func() ([]byte, error) {
+		return f.call(f.indexerURL, "indexer.getBlockByHeight", map[string]uint64{"height": uint64(height)})
+	}
+
+	var raw []byte
+	var err error
+	if f.cache != nil {
+		raw, err = f.cache.GetCompleteBlock(height, fetch)
+	} else {
+		raw, err = fetch()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reply GetBlockResponse
+	if err := json.Unmarshal(raw, &reply); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal block: %w", err)
+	}
+
+	block, err := NormalizeBlock(&reply)
+	if err != nil {
+		return nil, err
+	}
+	if logging.DebugBlock(block.Height) {
+		f.logger.Info("Normalized debug block", "block", block.Height, "block_id", block.BlockID, "parent_id", block.ParentID,
+			"timestamp", block.Timestamp, "txs", len(block.Txs), "unit_prices", block.UnitPrices)
+	}
+	return block, nil
+}
+
+// NormalizeBlock flattens an indexer block and its results for storage
+func NormalizeBlock(reply *GetBlockResponse) (*NormalizedBlock, error) {
+	blk := reply.Block.Block
+	if len(reply.Block.Results) != len(blk.Txs) {
+		return nil, fmt.Errorf("block %d has %d txs but %d results", blk.Height, len(blk.Txs), len(reply.Block.Results))
+	}
+
+	// Block IDs are the hash of the block bytes
+	blockID := ""
+	if len(reply.BlockBytes) > 0 {
+		blockID = ids.ID(hashing.ComputeHash256Array(reply.BlockBytes)).String()
+	}
+
+	normalized := &NormalizedBlock{
+		BlockID:    blockID,
+		ParentID:   blk.Parent,
+		Height:     blk.Height,
+		Timestamp:  time.UnixMilli(blk.Timestamp).UTC(),
+		StateRoot:  blk.StateRoot,
+		UnitPrices: string(reply.Block.UnitPrices),
+		Txs:        make([]NormalizedTx, 0, len(blk.Txs)),
+	}
+
+	for i, tx := range blk.Txs {
+		result := reply.Block.Results[i]
+		actions, err := normalizeActions(tx.Actions, result.Outputs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to normalize actions of tx %s: %w", tx.ID, err)
+		}
+
+		normalized.Txs = append(normalized.Txs, NormalizedTx{
+			TxID:    tx.ID,
+			Index:   uint32(i),
+			Success: result.Success,
+			Error:   string(result.Error),
+			Fee:     result.Fee,
+			Units:   string(result.Units),
+			Actions: actions,
+		})
+	}
+
+	return normalized, nil
+}
+
+// normalizeActions splits a tx's actions, pairing each with its output. Packed actions can't be
+// split without the VM's codec, so they're kept as a single row.
+func normalizeActions(raw json.RawMessage, outputs []HexBytes) ([]NormalizedAction, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return nil, nil
+	}
+
+	if trimmed[0] != '[' {
+		var packed HexBytes
+		if err := json.Unmarshal(trimmed, &packed); err != nil {
+			return nil, fmt.Errorf("failed to decode packed actions: %w", err)
+		}
+		output := ""
+		if len(outputs) == 1 {
+			output = outputs[0].String()
+		}
+		return []NormalizedAction{{Type: ActionTypePacked, Data: packed.String(), Output: output}}, nil
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal(trimmed, &elements); err != nil {
+		return nil, fmt.Errorf("failed to decode actions: %w", err)
+	}
+
+	actions := make([]NormalizedAction, 0, len(elements))
+	for i, element := range elements {
+		action := NormalizedAction{
+			Index: uint16(i),
+			Type:  actionType(element),
+			Data:  string(element),
+		}
+		if i < len(outputs) {
+			action.Output = outputs[i].String()
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// actionType returns the type an action's JSON declares, if any
+func actionType(action json.RawMessage) string {
+	var typed struct {
+		Type   json.RawMessage `json:"type"`
+		TypeID json.RawMessage `json:"typeID"`
+	}
+	if err := json.Unmarshal(action, &typed); err != nil {
+		return ""
+	}
+
+	for _, field := range []json.RawMessage{typed.Type, typed.TypeID} {
+		if len(field) == 0 {
+			continue
+		}
+		var name string
+		if err := json.Unmarshal(field, &name); err == nil {
+			return name
+		}
+		return string(field)
+	}
+	return ""
+}
+
+// Close releases idle connections
+func (f *Fetcher) Close() {
+	f.httpClient.CloseIdleConnections()
+}
@@ -0,0 +1,113 @@
+package hypersdkrpc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// HexBytes is a hypersdk codec.Bytes, JSON-encoded as a 0x-prefixed hex string
+type HexBytes []byte
+
+func (b *HexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid hex bytes: %w", err)
+	}
+	*b = decoded
+	return nil
+}
+
+// String returns the bytes 0x-prefixed hex encoded, or empty for no bytes
+func (b HexBytes) String() string {
+	if len(b) == 0 {
+		return ""
+	}
+	return "0x" + hex.EncodeToString(b)
+}
+
+// LastAcceptedResponse is the reply of hypersdk.lastAccepted
+type LastAcceptedResponse struct {
+	Height    uint64 `json:"height"`
+	BlockID   string `json:"blockId"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// GetBlockResponse is the reply of indexer.getBlockByHeight
+type GetBlockResponse struct {
+	Block      ExecutedBlock `json:"block"`
+	BlockBytes HexBytes      `json:"blockBytes"`
+}
+
+// ExecutedBlock is a block with the results of its txs
+type ExecutedBlock struct {
+	Block      StatelessBlock  `json:"block"`
+	Results    []Result        `json:"results"`
+	UnitPrices json.RawMessage `json:"unitPrices"`
+}
+
+// StatelessBlock is a hypersdk block as included in the chain
+type StatelessBlock struct {
+	Parent    string        `json:"parent"`
+	Timestamp int64         `json:"timestamp"` // Unix milliseconds
+	Height    uint64        `json:"height"`
+	Txs       []Transaction `json:"txs"`
+	StateRoot string        `json:"stateRoot"`
+}
+
+// Transaction is a hypersdk tx. Actions are a JSON array when the VM's actions are JSON
+// marshalable, and their packed hex bytes otherwise.
+type Transaction struct {
+	ID      string          `json:"id"`
+	Base    json.RawMessage `json:"base"`
+	Actions json.RawMessage `json:"actions"`
+	Auth    json.RawMessage `json:"auth"`
+}
+
+// Result is the outcome of a tx, with one output per action
+type Result struct {
+	Success bool            `json:"success"`
+	Error   HexBytes        `json:"error"`
+	Outputs []HexBytes      `json:"outputs"`
+	Units   json.RawMessage `json:"units"`
+	Fee     uint64          `json:"fee"`
+}
+
+// NormalizedBlock is a block flattened for storage
+type NormalizedBlock struct {
+	BlockID    string
+	ParentID   string
+	Height     uint64
+	Timestamp  time.Time
+	StateRoot  string
+	UnitPrices string // JSON array of fee dimension prices
+	Txs        []NormalizedTx
+}
+
+// NormalizedTx is a tx with its result
+type NormalizedTx struct {
+	TxID    string
+	Index   uint32
+	Success bool
+	Error   string
+	Fee     uint64
+	Units   string // JSON array of consumed fee dimensions
+	Actions []NormalizedAction
+}
+
+// NormalizedAction is one action of a tx
+type NormalizedAction struct {
+	Index  uint16
+	Type   string // Action type name or ID when the action JSON carries one, ActionTypePacked for packed actions
+	Data   string // Action JSON, or hex bytes of all packed actions
+	Output string // Hex encoded action output, empty when the tx failed
+}
+
+// ActionTypePacked marks a row holding a tx's packed action bytes, for VMs whose actions aren't JSON
+const ActionTypePacked = "packed"
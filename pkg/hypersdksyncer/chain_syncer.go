@@ -0,0 +1,361 @@
+package hypersdksyncer
+
+import (
+	"context"
+	"fmt"
+	"icicle/pkg/cache"
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/hypersdkrpc"
+	"icicle/pkg/loadshed"
+	"icicle/pkg/logging"
+	"icicle/pkg/tracing"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("hypersdksyncer")
+
+const (
+	// BufferSize is the maximum number of batches that can be buffered in the channel
+	BufferSize = 1000
+	// FlushInterval is how often to flush blocks to ClickHouse
+	FlushInterval = 1 * time.Second
+	// DegradedDivisor divides fetch batch size and RPC concurrency in degraded mode
+	DegradedDivisor = 4
+)
+
+// Config holds configuration for HyperSDKSyncer
+type Config struct {
+	ChainID        uint32
+	RpcURL         string            // Chain base URL, e.g. http://127.0.0.1:9650/ext/bc/<blockchainID>
+	RpcHeaders     map[string]string // Extra HTTP headers sent with every RPC request, e.g. API keys
+	NoCompression  bool              // Don't ask for compressed RPC responses
+	StartBlock     int64             // Starting block number when no watermark exists
+	MaxConcurrency int               // Maximum concurrent RPC requests
+	FetchBatchSize int               // Blocks per fetch
+	CHConn         driver.Conn       // ClickHouse connection
+	Cache          cache.Cache       // Cache for indexer replies
+	Name           string            // Chain name for display
+
+	// Load shedding
+	LoadShedder *loadshed.Monitor // Resource budget monitor, nil disables load shedding
+}
+
+// HyperSDKSyncer manages sync of a hypersdk-based chain
+type HyperSDKSyncer struct {
+	chainID        uint32
+	chainName      string
+	fetcher        *hypersdkrpc.Fetcher
+	conn           driver.Conn
+	blockChan      chan []*hypersdkrpc.NormalizedBlock // Bounded channel for backpressure
+	watermark      uint64                              // Current sync position
+	startBlock     int64                               // Starting block when no watermark
+	fetchBatchSize int
+	flushInterval  time.Duration
+	maxConcurrency int
+	logger         *slog.Logger
+	loadShedder    *loadshed.Monitor
+	degradedReason string // Current degraded mode reason, only used by the fetcher goroutine
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// Progress tracking
+	mu            sync.Mutex
+	blocksFetched int64
+	blocksWritten int64
+	startTime     time.Time
+}
+
+// NewHyperSDKSyncer creates a new hypersdk chain syncer
+func NewHyperSDKSyncer(cfg Config) (*HyperSDKSyncer, error) {
+	if cfg.FetchBatchSize == 0 {
+		cfg.FetchBatchSize = 100
+	}
+	if cfg.MaxConcurrency == 0 {
+		cfg.MaxConcurrency = 20
+	}
+	if cfg.Name == "" {
+		cfg.Name = fmt.Sprintf("HyperSDK-%d", cfg.ChainID)
+	}
+
+	fetcher := hypersdkrpc.NewFetcher(hypersdkrpc.FetcherOptions{
+		RpcURL:         cfg.RpcURL,
+		RpcHeaders:     cfg.RpcHeaders,
+		NoCompression:  cfg.NoCompression,
+		ChainID:        cfg.ChainID,
+		ChainName:      cfg.Name,
+		MaxConcurrency: cfg.MaxConcurrency,
+		MaxRetries:     10,
+		RetryDelay:     100 * time.Millisecond,
+		Cache:          cfg.Cache,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &HyperSDKSyncer{
+		chainID:        cfg.ChainID,
+		chainName:      cfg.Name,
+		fetcher:        fetcher,
+		conn:           cfg.CHConn,
+		blockChan:      make(chan []*hypersdkrpc.NormalizedBlock, BufferSize),
+		startBlock:     cfg.StartBlock,
+		fetchBatchSize: cfg.FetchBatchSize,
+		flushInterval:  FlushInterval,
+		maxConcurrency: cfg.MaxConcurrency,
+		logger:         logging.Chain("hypersdksyncer", cfg.ChainID, cfg.Name),
+		loadShedder:    cfg.LoadShedder,
+		ctx:            ctx,
+		cancel:         cancel,
+		startTime:      time.Now(),
+	}, nil
+}
+
+// Start begins syncing
+func (hs *HyperSDKSyncer) Start() error {
+	hs.logger.Info("Starting syncer")
+
+	startBlock, err := hs.getStartingBlock()
+	if err != nil {
+		return fmt.Errorf("failed to determine starting block: %w", err)
+	}
+	hs.logger.Info("Starting from block", "block", startBlock)
+
+	latestBlock, err := hs.fetcher.GetLatestBlock()
+	if err != nil {
+		return fmt.Errorf("failed to get latest block: %w", err)
+	}
+	hs.logger.Info("Latest block on chain", "block", latestBlock)
+
+	if err := chwrapper.UpsertChainStatus(hs.conn, hs.chainID, hs.chainName, uint64(latestBlock), ""); err != nil {
+		return fmt.Errorf("failed to upsert chain status: %w", err)
+	}
+
+	hs.wg.Add(3)
+	go hs.fetcherLoop(startBlock, latestBlock)
+	go hs.writerLoop()
+	go hs.printProgress()
+
+	return nil
+}
+
+// Stop gracefully shuts down the syncer
+func (hs *HyperSDKSyncer) Stop() {
+	hs.logger.Info("Stopping syncer")
+	hs.cancel()
+	close(hs.blockChan)
+	hs.wg.Wait()
+	hs.fetcher.Close()
+	hs.logger.Info("Syncer stopped")
+}
+
+// Wait blocks until syncer completes
+func (hs *HyperSDKSyncer) Wait() {
+	hs.wg.Wait()
+}
+
+// getStartingBlock determines where to start syncing from
+func (hs *HyperSDKSyncer) getStartingBlock() (int64, error) {
+	watermark, err := chwrapper.GetWatermark(hs.conn, hs.chainID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get watermark: %w", err)
+	}
+	hs.watermark = uint64(watermark)
+
+	if watermark == 0 {
+		return hs.startBlock, nil
+	}
+	return int64(watermark + 1), nil
+}
+
+// fetcherLoop is the producer goroutine that fetches blocks
+func (hs *HyperSDKSyncer) fetcherLoop(startBlock, latestBlock int64) {
+	defer hs.wg.Done()
+
+	currentBlock := startBlock
+
+	for {
+		select {
+		case <-hs.ctx.Done():
+			return
+		default:
+		}
+
+		if currentBlock > latestBlock {
+			// hypersdk chains produce blocks quickly, poll more often than the P-Chain
+			time.Sleep(500 * time.Millisecond)
+
+			newLatest, err := hs.fetcher.GetLatestBlock()
+			if err != nil {
+				hs.logger.Error("Error getting latest block", "error", err)
+				continue
+			}
+			if err := chwrapper.UpdateLatestBlock(hs.conn, hs.chainID, hs.chainName, uint64(newLatest), hs.degradedReason); err != nil {
+				hs.logger.Error("Error updating chain status", "error", err)
+			}
+			if newLatest <= latestBlock {
+				continue
+			}
+			latestBlock = newLatest
+		}
+
+		batchSize := hs.applyLoadShedding(latestBlock)
+		endBlock := min(currentBlock+int64(batchSize)-1, latestBlock)
+
+		blocks, err := hs.fetcher.FetchBlockRange(currentBlock, endBlock)
+		if err != nil {
+			hs.logger.Error("Error fetching blocks", "from", currentBlock, "to", endBlock, "error", err)
+			time.Sleep(1 * time.Second)
+			continue
+		}
+
+		hs.mu.Lock()
+		hs.blocksFetched += int64(len(blocks))
+		hs.mu.Unlock()
+
+		select {
+		case hs.blockChan <- blocks:
+			currentBlock = endBlock + 1
+		case <-hs.ctx.Done():
+			return
+		}
+	}
+}
+
+// applyLoadShedding switches the fetcher between normal and degraded mode when the load
+// shedder's state changes, and returns the fetch batch size to use
+func (hs *HyperSDKSyncer) applyLoadShedding(latestBlock int64) int {
+	reason := hs.loadShedder.Reason()
+	if reason != hs.degradedReason {
+		if reason != "" {
+			hs.logger.Warn("Degraded mode: reducing concurrency and batch size", "reason", reason)
+			hs.fetcher.SetConcurrency(hs.maxConcurrency / DegradedDivisor)
+		} else {
+			hs.logger.Info("Leaving degraded mode")
+			hs.fetcher.SetConcurrency(hs.maxConcurrency)
+		}
+		hs.degradedReason = reason
+
+		if err := chwrapper.UpdateLatestBlock(hs.conn, hs.chainID, hs.chainName, uint64(latestBlock), reason); err != nil {
+			hs.logger.Error("Error updating chain status", "error", err)
+		}
+	}
+
+	if reason != "" {
+		return max(1, hs.fetchBatchSize/DegradedDivisor)
+	}
+	return hs.fetchBatchSize
+}
+
+// writerLoop is the consumer goroutine that writes to ClickHouse
+func (hs *HyperSDKSyncer) writerLoop() {
+	defer hs.wg.Done()
+
+	var buffer []*hypersdkrpc.NormalizedBlock
+	ticker := time.NewTicker(hs.flushInterval)
+	defer ticker.Stop()
+
+	flush := func() {
+		if len(buffer) == 0 {
+			return
+		}
+		if err := hs.writeBlocks(buffer); err != nil {
+			hs.logger.Error("Error writing blocks", "error", err)
+			return
+		}
+
+		hs.mu.Lock()
+		hs.blocksWritten += int64(len(buffer))
+		hs.mu.Unlock()
+		buffer = nil
+	}
+
+	for {
+		select {
+		case <-hs.ctx.Done():
+			flush()
+			return
+		case blocks, ok := <-hs.blockChan:
+			if !ok {
+				flush()
+				return
+			}
+			buffer = append(buffer, blocks...)
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// writeBlocks writes blocks to ClickHouse and updates watermark
+func (hs *HyperSDKSyncer) writeBlocks(blocks []*hypersdkrpc.NormalizedBlock) (err error) {
+	ctx, span := tracer.Start(hs.ctx, "hypersdksyncer.writeBlocks",
+		trace.WithAttributes(tracing.Range(hs.chainID, int64(blocks[0].Height), int64(blocks[len(blocks)-1].Height))...))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	start := time.Now()
+
+	err = tracing.Run(ctx, tracer, "hypersdksyncer.InsertActions", func(ctx context.Context) error {
+		return InsertActions(ctx, hs.conn, hs.chainID, blocks)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to insert hypersdk actions: %w", err)
+	}
+	err = tracing.Run(ctx, tracer, "hypersdksyncer.InsertBlocks", func(ctx context.Context) error {
+		return InsertBlocks(ctx, hs.conn, hs.chainID, blocks)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to insert hypersdk blocks: %w", err)
+	}
+
+	txCount := 0
+	maxBlock := uint64(0)
+	for _, b := range blocks {
+		txCount += len(b.Txs)
+		maxBlock = max(maxBlock, b.Height)
+	}
+	hs.logger.Info("Inserted blocks", "blocks", len(blocks), "txs", txCount, "elapsed", time.Since(start))
+
+	if maxBlock > hs.watermark {
+		if err := chwrapper.SetWatermark(hs.conn, hs.chainID, uint32(maxBlock)); err != nil {
+			return fmt.Errorf("failed to update watermark: %w", err)
+		}
+		hs.watermark = maxBlock
+	}
+
+	return nil
+}
+
+// printProgress prints sync progress periodically
+func (hs *HyperSDKSyncer) printProgress() {
+	defer hs.wg.Done()
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-hs.ctx.Done():
+			return
+		case <-ticker.C:
+			hs.mu.Lock()
+			fetched := hs.blocksFetched
+			written := hs.blocksWritten
+			hs.mu.Unlock()
+
+			elapsed := time.Since(hs.startTime)
+			hs.logger.Info("Progress",
+				"fetched", fetched, "fetch_rate", fmt.Sprintf("%.1f/s", float64(fetched)/elapsed.Seconds()),
+				"written", written, "write_rate", fmt.Sprintf("%.1f/s", float64(written)/elapsed.Seconds()),
+				"lag", fetched-written, "watermark", hs.watermark)
+		}
+	}
+}
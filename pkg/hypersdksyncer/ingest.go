@@ -0,0 +1,92 @@
+package hypersdksyncer
+
+import (
+	"context"
+	"fmt"
+	"icicle/pkg/hypersdkrpc"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// InsertBlocks inserts one hypersdk_blocks row per block
+func InsertBlocks(ctx context.Context, conn clickhouse.Conn, chainID uint32, blocks []*hypersdkrpc.NormalizedBlock) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO hypersdk_blocks (
+		chain_id, block_number, block_id, parent_id, block_time, state_root, unit_prices, tx_count
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare block batch: %w", err)
+	}
+
+	for _, block := range blocks {
+		err = batch.Append(
+			chainID,
+			block.Height,
+			block.BlockID,
+			block.ParentID,
+			block.Timestamp,
+			block.StateRoot,
+			block.UnitPrices,
+			uint32(len(block.Txs)),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to append block %d: %w", block.Height, err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to send block batch: %w", err)
+	}
+
+	return nil
+}
+
+// InsertActions inserts one hypersdk_actions row per action, carrying its tx's result
+func InsertActions(ctx context.Context, conn clickhouse.Conn, chainID uint32, blocks []*hypersdkrpc.NormalizedBlock) error {
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO hypersdk_actions (
+		chain_id, block_number, block_time, tx_id, tx_index, action_index, action_type,
+		action_data, output, success, error, fee, units
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare action batch: %w", err)
+	}
+
+	count := 0
+	for _, block := range blocks {
+		for _, tx := range block.Txs {
+			for _, action := range tx.Actions {
+				err = batch.Append(
+					chainID,
+					block.Height,
+					block.Timestamp,
+					tx.TxID,
+					tx.Index,
+					action.Index,
+					action.Type,
+					action.Data,
+					action.Output,
+					tx.Success,
+					tx.Error,
+					tx.Fee,
+					tx.Units,
+				)
+				if err != nil {
+					return fmt.Errorf("failed to append action %d of tx %s: %w", action.Index, tx.TxID, err)
+				}
+				count++
+			}
+		}
+	}
+
+	if count == 0 {
+		return batch.Abort()
+	}
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to send action batch: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,228 @@
+package lakeexport
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"icicle/pkg/chwrapper"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+const (
+	// MaxBlocksPerFile caps the blocks of one Parquet file, so a backfill is split into many files
+	MaxBlocksPerFile = 50_000
+	// MetricsSettleDelay keeps metrics rows younger than this for the next run, since inserts in
+	// flight may still land with an earlier computed_at
+	MetricsSettleDelay = time.Minute
+)
+
+// blockTable is a table exported by block range, deduplicated by key within each file
+type blockTable struct {
+	name string
+	key  string
+}
+
+// blockTables are the raw and decoded tables of EVM chains, written before the sync watermark moves
+var blockTables = []blockTable{
+	{name: "raw_blocks", key: "block_number"},
+	{name: "raw_txs", key: "block_number, hash"},
+	{name: "raw_logs", key: "block_number, transaction_index, log_index"},
+	{name: "raw_traces", key: "block_number, transaction_index, trace_address"},
+	{name: "raw_withdrawals", key: "block_number, withdrawal_index"},
+	{name: "erc20_transfers", key: "block_number, transaction_hash, log_index"},
+	{name: "nft_transfers", key: "block_number, transaction_hash, log_index, batch_index"},
+	{name: "contracts", key: "block_number, address"},
+	{name: "icm_messages", key: "block_number, transaction_hash, log_index"},
+	{name: "internal_txs", key: "block_number, transaction_index, trace_address"},
+}
+
+// Exporter writes incremental Parquet files of raw tables and calculated metrics to S3 with the
+// s3 table function, so ClickHouse uploads them directly. Files are laid out Hive-style for
+// Athena and other lakehouse engines:
+//
+//	<url>/<table>/chain_id=<id>/<first block>.parquet   block tables, one file per block range
+//	<url>/metrics/chain_id=<id>/<computed_at ms>.parquet rows computed since the previous file
+//
+// chain_id is only in the path, as engines reject partition keys that are also file columns.
+// Progress is kept per destination in export_watermarks. A file is named after its first block
+// (or computed_at), so a run interrupted before its watermark was saved overwrites it on retry.
+// Metrics rows are replaced when recomputed, readers keep the row with the latest computed_at.
+type Exporter struct {
+	conn     driver.Conn
+	url      string
+	chainIDs []uint32
+	interval time.Duration
+	logger   *slog.Logger
+
+	accessKey string
+	secretKey string
+}
+
+// NewExporter returns an exporter of chainIDs to url, an S3 prefix such as
+// https://bucket.s3.us-east-1.amazonaws.com/icicle. Credentials come from AWS_ACCESS_KEY_ID and
+// AWS_SECRET_ACCESS_KEY, without them ClickHouse uses its own S3 configuration.
+func NewExporter(conn driver.Conn, url string, chainIDs []uint32, interval time.Duration) *Exporter {
+	return &Exporter{
+		conn:      conn,
+		url:       strings.TrimSuffix(url, "/"),
+		chainIDs:  chainIDs,
+		interval:  interval,
+		logger:    slog.With("component", "lakeexport"),
+		accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+	}
+}
+
+// Run exports every interval until ctx is cancelled
+func (e *Exporter) Run(ctx context.Context) {
+	e.logger.Info("Data-lake export enabled", "url", e.url, "chains", len(e.chainIDs), "interval", e.interval)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		e.export(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// export writes everything ingested since the previous run. A failing table is logged and
+// retried on the next run without holding back the others.
+func (e *Exporter) export(ctx context.Context) {
+	ctx = clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{
+		"s3_truncate_on_insert": 1,
+	}))
+
+	for _, chainID := range e.chainIDs {
+		watermark, err := chwrapper.GetWatermark(e.conn, chainID)
+		if err != nil {
+			e.logger.Error("Failed to read sync watermark", "chain_id", chainID, "error", err)
+			continue
+		}
+		for _, table := range blockTables {
+			if err := e.exportBlocks(ctx, table, chainID, uint64(watermark)); err != nil {
+				e.logger.Error("Failed to export table", "table", table.name, "chain_id", chainID, "error", err)
+			}
+		}
+		if err := e.exportMetrics(ctx, chainID); err != nil {
+			e.logger.Error("Failed to export table", "table", "metrics", "chain_id", chainID, "error", err)
+		}
+	}
+}
+
+// exportBlocks writes the blocks of table after its export watermark up to the sync watermark
+func (e *Exporter) exportBlocks(ctx context.Context, table blockTable, chainID uint32, watermark uint64) error {
+	position, err := e.position(ctx, table.name, chainID)
+	if err != nil {
+		return err
+	}
+
+	for from := position + 1; from <= watermark; {
+		to := min(from+MaxBlocksPerFile-1, watermark)
+		start := time.Now()
+
+		s3, args := e.s3Function(fmt.Sprintf("%s/chain_id=%d/%010d.parquet", table.name, chainID, from))
+		query := fmt.Sprintf(`
+			INSERT INTO FUNCTION %s
+			SELECT * EXCEPT chain_id FROM %s
+			WHERE chain_id = ? AND block_number BETWEEN ? AND ?
+			ORDER BY %s
+			LIMIT 1 BY %s`, s3, table.name, table.key, table.key)
+		if err := e.conn.Exec(ctx, query, append(args, chainID, from, to)...); err != nil {
+			return fmt.Errorf("failed to write blocks %d-%d: %w", from, to, err)
+		}
+		if err := e.setPosition(ctx, table.name, chainID, to); err != nil {
+			return err
+		}
+
+		e.logger.Info("Exported blocks", "table", table.name, "chain_id", chainID, "from", from, "to", to, "duration", time.Since(start))
+		from = to + 1
+	}
+	return nil
+}
+
+// exportMetrics writes the metrics rows computed after the export watermark. The metrics table
+// only exists once indexers have run, so it is skipped until then.
+func (e *Exporter) exportMetrics(ctx context.Context, chainID uint32) error {
+	var exists uint8
+	if err := e.conn.QueryRow(ctx, "EXISTS TABLE metrics").Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check metrics table: %w", err)
+	}
+	if exists == 0 {
+		return nil
+	}
+
+	position, err := e.position(ctx, "metrics", chainID)
+	if err != nil {
+		return err
+	}
+
+	var latest time.Time
+	if err := e.conn.QueryRow(ctx, `
+		SELECT max(computed_at) FROM metrics
+		WHERE chain_id = ? AND computed_at > fromUnixTimestamp64Milli(?) AND computed_at <= now64(3) - INTERVAL ? SECOND`,
+		chainID, int64(position), int64(MetricsSettleDelay/time.Second)).Scan(&latest); err != nil {
+		return fmt.Errorf("failed to query metrics: %w", err)
+	}
+	to := uint64(latest.UnixMilli())
+	if to <= position {
+		return nil
+	}
+
+	s3, args := e.s3Function(fmt.Sprintf("metrics/chain_id=%d/%013d.parquet", chainID, position+1))
+	query := fmt.Sprintf(`
+		INSERT INTO FUNCTION %s
+		SELECT * EXCEPT chain_id FROM metrics FINAL
+		WHERE chain_id = ? AND computed_at BETWEEN fromUnixTimestamp64Milli(?) AND fromUnixTimestamp64Milli(?)
+		ORDER BY metric_name, granularity, period`, s3)
+	if err := e.conn.Exec(ctx, query, append(args, chainID, int64(position+1), int64(to))...); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	if err := e.setPosition(ctx, "metrics", chainID, to); err != nil {
+		return err
+	}
+
+	e.logger.Info("Exported metrics", "chain_id", chainID, "computed_until", latest)
+	return nil
+}
+
+// s3Function returns the s3 table function writing Parquet to path under the export URL, and its arguments
+func (e *Exporter) s3Function(path string) (string, []any) {
+	url := e.url + "/" + path
+	if e.accessKey == "" {
+		return "s3(?, 'Parquet')", []any{url}
+	}
+	return "s3(?, ?, ?, 'Parquet')", []any{url, e.accessKey, e.secretKey}
+}
+
+// position returns the last block (or computed_at in ms) of table exported for chainID, 0 if none
+func (e *Exporter) position(ctx context.Context, table string, chainID uint32) (uint64, error) {
+	var position uint64
+	err := e.conn.QueryRow(ctx, `
+		SELECT max(position) FROM export_watermarks FINAL
+		WHERE destination = ? AND table_name = ? AND chain_id = ?`, e.url, table, chainID).Scan(&position)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read export watermark: %w", err)
+	}
+	return position, nil
+}
+
+// setPosition saves the export watermark of table for chainID
+func (e *Exporter) setPosition(ctx context.Context, table string, chainID uint32, position uint64) error {
+	err := e.conn.Exec(ctx, `
+		INSERT INTO export_watermarks (destination, table_name, chain_id, position)
+		VALUES (?, ?, ?, ?)`, e.url, table, chainID, position)
+	if err != nil {
+		return fmt.Errorf("failed to save export watermark: %w", err)
+	}
+	return nil
+}
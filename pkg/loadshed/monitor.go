@@ -0,0 +1,176 @@
+package loadshed
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+const (
+	// SampleInterval is how often memory and CPU usage are sampled
+	SampleInterval = 5 * time.Second
+	// DegradeThreshold is the fraction of the budget at which degraded mode starts
+	DegradeThreshold = 0.9
+	// RecoverThreshold is the fraction of the budget usage must stay under to leave degraded mode
+	RecoverThreshold = 0.7
+	// RecoverSamples is how many consecutive samples under RecoverThreshold end degraded mode
+	RecoverSamples = 6
+)
+
+// Monitor samples the process's memory and CPU usage against a budget and reports
+// degraded mode while usage is near it. A nil Monitor is never degraded.
+type Monitor struct {
+	maxMemory uint64  // Resident memory budget in bytes (0 = unlimited)
+	maxCPU    float64 // CPU budget in cores (0 = unlimited)
+
+	mu      sync.Mutex
+	reason  string // Why the process is degraded, empty when it isn't
+	healthy int    // Consecutive samples under RecoverThreshold
+
+	lastCPU    time.Duration
+	lastSample time.Time
+}
+
+// NewMonitor creates a monitor for the given budget. Returns nil if neither limit is set.
+func NewMonitor(maxMemory uint64, maxCPU float64) *Monitor {
+	if maxMemory == 0 && maxCPU == 0 {
+		return nil
+	}
+	return &Monitor{
+		maxMemory: maxMemory,
+		maxCPU:    maxCPU,
+	}
+}
+
+// Start samples usage until ctx is cancelled
+func (m *Monitor) Start(ctx context.Context) {
+	if m == nil {
+		return
+	}
+
+	slog.Info("Load shedding enabled", "component", "loadshed", "max_memory", m.memoryString(), "max_cpu", m.cpuString())
+	m.lastCPU, m.lastSample = processCPUTime(), time.Now()
+
+	go func() {
+		ticker := time.NewTicker(SampleInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.sample()
+			}
+		}
+	}()
+}
+
+// Reason returns why the process is in degraded mode, or an empty string if it isn't
+func (m *Monitor) Reason() string {
+	if m == nil {
+		return ""
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reason
+}
+
+// Degraded reports whether the process is in degraded mode
+func (m *Monitor) Degraded() bool {
+	return m.Reason() != ""
+}
+
+// sample measures usage and enters or leaves degraded mode
+func (m *Monitor) sample() {
+	now := time.Now()
+	cpuTime := processCPUTime()
+	cores := (cpuTime - m.lastCPU).Seconds() / now.Sub(m.lastSample).Seconds()
+	m.lastCPU, m.lastSample = cpuTime, now
+
+	memory := residentMemory()
+
+	var memoryUsage, cpuUsage float64
+	if m.maxMemory > 0 {
+		memoryUsage = float64(memory) / float64(m.maxMemory)
+	}
+	if m.maxCPU > 0 {
+		cpuUsage = cores / m.maxCPU
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch {
+	case memoryUsage >= DegradeThreshold:
+		m.healthy = 0
+		if m.reason == "" {
+			m.reason = fmt.Sprintf("memory %s of %s", humanize.IBytes(memory), m.memoryString())
+			slog.Warn("Entering degraded mode", "component", "loadshed", "reason", m.reason)
+		}
+	case cpuUsage >= DegradeThreshold:
+		m.healthy = 0
+		if m.reason == "" {
+			m.reason = fmt.Sprintf("CPU %.2f of %s cores", cores, m.cpuString())
+			slog.Warn("Entering degraded mode", "component", "loadshed", "reason", m.reason)
+		}
+	case m.reason != "" && memoryUsage < RecoverThreshold && cpuUsage < RecoverThreshold:
+		m.healthy++
+		if m.healthy >= RecoverSamples {
+			slog.Info("Leaving degraded mode", "component", "loadshed", "memory", humanize.IBytes(memory), "cpu_cores", cores)
+			m.reason = ""
+			m.healthy = 0
+		}
+	default:
+		m.healthy = 0
+	}
+}
+
+func (m *Monitor) memoryString() string {
+	if m.maxMemory == 0 {
+		return "unlimited"
+	}
+	return humanize.IBytes(m.maxMemory)
+}
+
+func (m *Monitor) cpuString() string {
+	if m.maxCPU == 0 {
+		return "unlimited"
+	}
+	return strconv.FormatFloat(m.maxCPU, 'f', -1, 64)
+}
+
+// residentMemory returns the process's resident set size. Falls back to memory obtained
+// by the Go runtime where /proc is unavailable (this misses cgo allocations).
+func residentMemory() uint64 {
+	if data, err := os.ReadFile("/proc/self/statm"); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) >= 2 {
+			if pages, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+				return pages * uint64(os.Getpagesize())
+			}
+		}
+	}
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.Sys - stats.HeapReleased
+}
+
+// processCPUTime returns the user and system CPU time used by the process
+func processCPUTime() time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Formats accepted by Setup
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Setup makes a handler of the given level and format the default slog logger. The standard
+// log package is routed through it too, at info level.
+func Setup(level, format string) error {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	handler, err := NewHandler(os.Stderr, lvl, format)
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// NewHandler creates a text or JSON handler writing records of at least level to w
+func NewHandler(w io.Writer, level slog.Level, format string) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		return slog.NewTextHandler(w, opts), nil
+	case FormatJSON:
+		return slog.NewJSONHandler(w, opts), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want text or json)", format)
+	}
+}
+
+// ParseLevel parses debug, info, warn or error. An empty level is info.
+func ParseLevel(level string) (slog.Level, error) {
+	if level == "" {
+		return slog.LevelInfo, nil
+	}
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", level)
+	}
+	return lvl, nil
+}
+
+// Chain returns a logger tagged with a chain and the component logging for it
+func Chain(component string, chainID uint32, chainName string) *slog.Logger {
+	logger := slog.With("component", component, "chain_id", chainID)
+	if chainName != "" {
+		logger = logger.With("chain_name", chainName)
+	}
+	return logger
+}
+
+// debugBlocks holds the block heights with verbose normalization logging
+var debugBlocks atomic.Pointer[map[uint64]bool]
+
+// ParseDebugBlocks parses a comma-separated list of block heights, e.g. "1570934,200000"
+func ParseDebugBlocks(list string) ([]uint64, error) {
+	var heights []uint64
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		height, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid debug block %q: %w", field, err)
+		}
+		heights = append(heights, height)
+	}
+	return heights, nil
+}
+
+// SetDebugBlocks turns on verbose normalization logging for heights on every chain
+func SetDebugBlocks(heights []uint64) {
+	set := make(map[uint64]bool, len(heights))
+	for _, height := range heights {
+		set[height] = true
+	}
+	debugBlocks.Store(&set)
+}
+
+// DebugBlock reports whether normalization of a block at height should be logged verbosely
+func DebugBlock(height uint64) bool {
+	set := debugBlocks.Load()
+	return set != nil && (*set)[height]
+}
+
+// Fatal logs msg at error level and exits, like log.Fatalf
+func Fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,164 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// CheckInterval is how often the optimizer checks whether the quiet hours have started
+const CheckInterval = 10 * time.Minute
+
+// DefaultTables are the ReplacingMergeTree tables read with FINAL that collect many small parts,
+// since every indexer run or validator sync replaces a few rows
+var DefaultTables = []string{"indexer_watermarks", "l1_validator_state", "l1_subnets"}
+
+// QuietHours is a daily window of UTC hours, from Start up to End. A window with End below Start
+// spans midnight. The zero value disables the optimizer.
+type QuietHours struct {
+	Start int
+	End   int
+}
+
+// ParseQuietHours parses "start-end" UTC hours, e.g. "2-5" or "22-4". An empty string returns the
+// zero QuietHours.
+func ParseQuietHours(s string) (QuietHours, error) {
+	if strings.TrimSpace(s) == "" {
+		return QuietHours{}, nil
+	}
+	startStr, endStr, ok := strings.Cut(s, "-")
+	start, startErr := strconv.Atoi(strings.TrimSpace(startStr))
+	end, endErr := strconv.Atoi(strings.TrimSpace(endStr))
+	if !ok || startErr != nil || endErr != nil || start < 0 || start > 23 || end < 0 || end > 24 || start == end%24 {
+		return QuietHours{}, fmt.Errorf("invalid quiet hours %q, expected start-end UTC hours, e.g. 2-5", s)
+	}
+	return QuietHours{Start: start, End: end % 24}, nil
+}
+
+// IsZero reports whether no quiet hours are set
+func (q QuietHours) IsZero() bool {
+	return q == QuietHours{}
+}
+
+// windowStart returns the start of the window t is in, false if t is outside the quiet hours
+func (q QuietHours) windowStart(t time.Time) (time.Time, bool) {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), t.Day(), q.Start, 0, 0, 0, time.UTC)
+	if start.After(t) {
+		start = start.AddDate(0, 0, -1)
+	}
+	length := time.Duration((q.End-q.Start+24)%24) * time.Hour
+	return start, t.Before(start.Add(length))
+}
+
+// Optimizer merges the parts of ReplacingMergeTree tables with OPTIMIZE FINAL once during each day's
+// quiet hours, so queries reading them with FINAL don't have to collapse rows across many parts.
+// Only partitions with more than one active part are optimized, one at a time, and none are
+// started once the quiet hours are over.
+type Optimizer struct {
+	conn   driver.Conn
+	tables []string
+	hours  QuietHours
+	logger *slog.Logger
+
+	lastWindow time.Time
+}
+
+// NewOptimizer returns an optimizer of tables during hours
+func NewOptimizer(conn driver.Conn, tables []string, hours QuietHours) *Optimizer {
+	return &Optimizer{
+		conn:   conn,
+		tables: tables,
+		hours:  hours,
+		logger: slog.With("component", "optimizer"),
+	}
+}
+
+// Run optimizes the tables during each day's quiet hours until ctx is cancelled
+func (o *Optimizer) Run(ctx context.Context) {
+	o.logger.Info("Scheduled table optimization enabled", "tables", o.tables, "quiet_hours_utc", fmt.Sprintf("%d-%d", o.hours.Start, o.hours.End))
+	ticker := time.NewTicker(CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if start, ok := o.hours.windowStart(time.Now()); ok && !start.Equal(o.lastWindow) {
+			o.lastWindow = start
+			o.optimize(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// optimize runs OPTIMIZE FINAL on the partitions of each table with more than one part. A failing
+// table is logged and optimized again in the next quiet hours without holding back the others.
+func (o *Optimizer) optimize(ctx context.Context) {
+	start := time.Now()
+	var optimized int
+	for _, table := range o.tables {
+		partitions, err := o.partitions(ctx, table)
+		if err != nil {
+			o.logger.Error("Failed to list partitions", "table", table, "error", err)
+			continue
+		}
+		for _, partition := range partitions {
+			if _, ok := o.hours.windowStart(time.Now()); !ok || ctx.Err() != nil {
+				o.logger.Info("Quiet hours over, optimizing the rest tomorrow", "optimized_partitions", optimized)
+				return
+			}
+			partStart := time.Now()
+			query := fmt.Sprintf("OPTIMIZE TABLE %s PARTITION ID '%s' FINAL", table, partition)
+			if err := o.conn.Exec(ctx, query); err != nil {
+				o.logger.Error("Failed to optimize partition", "table", table, "partition", partition, "error", err)
+				break
+			}
+			optimized++
+			o.logger.Debug("Optimized partition", "table", table, "partition", partition, "duration", time.Since(partStart))
+		}
+	}
+	o.logger.Info("Optimized tables", "optimized_partitions", optimized, "duration", time.Since(start))
+}
+
+// partitions returns the IDs of the partitions of table with more than one active part. Tables that
+// aren't ReplacingMergeTrees return an error, since OPTIMIZE FINAL would only rewrite their parts.
+func (o *Optimizer) partitions(ctx context.Context, table string) ([]string, error) {
+	var engine string
+	err := o.conn.QueryRow(ctx, `
+		SELECT engine FROM system.tables
+		WHERE database = currentDatabase() AND name = ?`, table).Scan(&engine)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up table: %w", err)
+	}
+	if !strings.HasSuffix(engine, "ReplacingMergeTree") {
+		return nil, fmt.Errorf("engine %s isn't a ReplacingMergeTree", engine)
+	}
+
+	rows, err := o.conn.Query(ctx, `
+		SELECT partition_id FROM system.parts
+		WHERE database = currentDatabase() AND table = ? AND active
+		GROUP BY partition_id
+		HAVING count() > 1
+		ORDER BY partition_id`, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query parts: %w", err)
+	}
+	defer rows.Close()
+
+	var partitions []string
+	for rows.Next() {
+		var partition string
+		if err := rows.Scan(&partition); err != nil {
+			return nil, fmt.Errorf("failed to scan partition: %w", err)
+		}
+		partitions = append(partitions, partition)
+	}
+	return partitions, rows.Err()
+}
@@ -0,0 +1,70 @@
+package membudget
+
+import (
+	"context"
+	"sync"
+)
+
+// Budget bounds the bytes of data held between a producer and a consumer. The producer adds
+// what it hands over and waits for room before producing more, the consumer releases what it
+// is done with. A nil Budget is unlimited.
+type Budget struct {
+	limit int64
+
+	mu    sync.Mutex
+	used  int64
+	freed chan struct{} // Closed and replaced whenever bytes are released
+}
+
+// New creates a budget of limit bytes. Returns nil if limit is not positive.
+func New(limit int64) *Budget {
+	if limit <= 0 {
+		return nil
+	}
+	return &Budget{limit: limit, freed: make(chan struct{})}
+}
+
+// Add records n bytes handed to the consumer. Adding may go over the limit, Wait then blocks
+// until enough is released.
+func (b *Budget) Add(n int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.used += n
+	b.mu.Unlock()
+}
+
+// Release returns n bytes the consumer is done with
+func (b *Budget) Release(n int64) {
+	if b == nil || n == 0 {
+		return
+	}
+	b.mu.Lock()
+	b.used = max(0, b.used-n)
+	close(b.freed)
+	b.freed = make(chan struct{})
+	b.mu.Unlock()
+}
+
+// Wait blocks while the budget is used up. Returns false if ctx is cancelled first.
+func (b *Budget) Wait(ctx context.Context) bool {
+	if b == nil {
+		return true
+	}
+	for {
+		b.mu.Lock()
+		if b.used < b.limit {
+			b.mu.Unlock()
+			return true
+		}
+		freed := b.freed
+		b.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
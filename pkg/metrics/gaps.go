@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	missingBlocks = Factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "missing_blocks",
+		Help:      "Blocks below the sync watermark missing from the raw tables at the last gap check, per chain",
+	}, []string{"chain"})
+	blocksHealed = Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "healed_blocks_total",
+		Help:      "Missing blocks re-ingested by the gap check, per chain",
+	}, []string{"chain"})
+)
+
+// SetMissingBlocks records how many blocks the last gap check of a chain found missing
+func SetMissingBlocks(chainID uint32, missing uint64) {
+	missingBlocks.WithLabelValues(strconv.FormatUint(uint64(chainID), 10)).Set(float64(missing))
+}
+
+// BlocksHealed counts missing blocks of a chain that were ingested again
+func BlocksHealed(chainID uint32, n uint64) {
+	blocksHealed.WithLabelValues(strconv.FormatUint(uint64(chainID), 10)).Add(float64(n))
+}
@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	indexerFailures = Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "indexer_failures_total",
+		Help:      "Failed runs of an indexer, per chain and indexer",
+	}, []string{"chain", "indexer"})
+	indexerQuarantined = Factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "indexer_quarantined",
+		Help:      "1 while an indexer is quarantined after failing repeatedly, per chain and indexer",
+	}, []string{"chain", "indexer"})
+)
+
+// IndexerFailed counts a failed run of an indexer of a chain
+func IndexerFailed(chainID uint32, indexer string) {
+	indexerFailures.WithLabelValues(strconv.FormatUint(uint64(chainID), 10), indexer).Inc()
+}
+
+// SetIndexerQuarantined records whether an indexer of a chain is quarantined
+func SetIndexerQuarantined(chainID uint32, indexer string, quarantined bool) {
+	value := 0.0
+	if quarantined {
+		value = 1
+	}
+	indexerQuarantined.WithLabelValues(strconv.FormatUint(uint64(chainID), 10), indexer).Set(value)
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InsertSummaryInterval is how often LogInsertSummaries logs the inserts of each table
+const InsertSummaryInterval = 5 * time.Minute
+
+var (
+	insertRows = Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "insert_rows_total",
+		Help:      "Rows of successfully sent ClickHouse insert batches per table",
+	}, []string{"table"})
+	insertBytes = Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "insert_bytes_total",
+		Help:      "Uncompressed bytes ClickHouse reported writing for insert batches per table",
+	}, []string{"table"})
+	insertDuration = Factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "insert_batch_duration_seconds",
+		Help:      "Latency of sending ClickHouse insert batches per table, until the server acknowledged them",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 15), // 5ms to ~80s
+	}, []string{"table"})
+	insertFailures = Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "insert_batch_failures_total",
+		Help:      "ClickHouse insert batches per table that failed to send",
+	}, []string{"table"})
+)
+
+// insertTotals are the inserts of a table since the last summary
+type insertTotals struct {
+	batches  int
+	failures int
+	rows     uint64
+	bytes    uint64
+	duration time.Duration
+}
+
+var (
+	insertTotalsMu sync.Mutex
+	insertTotalsBy = map[string]*insertTotals{}
+)
+
+// ObserveInsert records an insert batch of table sent at start, counting it as failed if err is set
+func ObserveInsert(table string, rows int, bytes uint64, start time.Time, err error) {
+	elapsed := time.Since(start)
+	insertDuration.WithLabelValues(table).Observe(elapsed.Seconds())
+	if err != nil {
+		insertFailures.WithLabelValues(table).Inc()
+	} else {
+		insertRows.WithLabelValues(table).Add(float64(rows))
+		insertBytes.WithLabelValues(table).Add(float64(bytes))
+	}
+
+	insertTotalsMu.Lock()
+	defer insertTotalsMu.Unlock()
+	totals := insertTotalsBy[table]
+	if totals == nil {
+		totals = &insertTotals{}
+		insertTotalsBy[table] = totals
+	}
+	totals.batches++
+	totals.duration += elapsed
+	if err != nil {
+		totals.failures++
+	} else {
+		totals.rows += uint64(rows)
+		totals.bytes += bytes
+	}
+}
+
+// LogInsertSummaries logs the insert throughput, average batch latency and failed batches of each
+// table every InsertSummaryInterval until ctx is cancelled
+func LogInsertSummaries(ctx context.Context) {
+	ticker := time.NewTicker(InsertSummaryInterval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			logInsertSummary(now.Sub(last))
+			last = now
+		}
+	}
+}
+
+// logInsertSummary logs and resets the inserts of each table over the last period
+func logInsertSummary(period time.Duration) {
+	insertTotalsMu.Lock()
+	totalsBy := insertTotalsBy
+	insertTotalsBy = map[string]*insertTotals{}
+	insertTotalsMu.Unlock()
+
+	tables := make([]string, 0, len(totalsBy))
+	for table := range totalsBy {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	seconds := period.Seconds()
+	for _, table := range tables {
+		totals := totalsBy[table]
+		slog.Info("Insert summary",
+			"table", table,
+			"batches", totals.batches,
+			"failed_batches", totals.failures,
+			"rows_per_sec", int64(float64(totals.rows)/seconds),
+			"mb_per_sec", float64(int64(float64(totals.bytes)/seconds/1024/1024*100))/100,
+			"avg_batch_ms", (totals.duration / time.Duration(totals.batches)).Milliseconds())
+	}
+}
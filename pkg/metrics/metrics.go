@@ -0,0 +1,30 @@
+// Package metrics holds the Prometheus registry Icicle's metrics are registered in and serves
+// it on /metrics when --metrics is set
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes every metric name
+const Namespace = "icicle"
+
+// Registry holds every Icicle metric plus the Go runtime and process collectors
+var Registry = prometheus.NewRegistry()
+
+// Factory registers new metrics in Registry
+var Factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+// Handler serves Registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RPC error kinds, the kind label of the RPC error counter
+const (
+	ErrTransport = "transport" // Connection failed or timed out
+	ErrStatus    = "status"    // Non-200 HTTP status
+	ErrDecode    = "decode"    // Unreadable response body
+	ErrReply     = "reply"     // JSON-RPC error reply, counted per call of a batch
+)
+
+var (
+	rpcDuration = Factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "rpc_request_duration_seconds",
+		Help:      "Latency of RPC HTTP requests per endpoint and method, until the response is decoded",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 15), // 5ms to ~80s
+	}, []string{"endpoint", "method"})
+	rpcErrors = Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "rpc_errors_total",
+		Help:      "Failed RPC requests and JSON-RPC error replies per endpoint, method and kind",
+	}, []string{"endpoint", "method", "kind"})
+)
+
+// ObserveRPC records the latency of an RPC request sent at start and, if kind is set, counts it
+// as failed. Batches are recorded once, under the method of their first call.
+func ObserveRPC(endpoint, method string, start time.Time, kind string) {
+	rpcDuration.WithLabelValues(endpoint, method).Observe(time.Since(start).Seconds())
+	if kind != "" {
+		rpcErrors.WithLabelValues(endpoint, method, kind).Inc()
+	}
+}
+
+// RPCError counts an error reply to one call of a request whose latency was already recorded
+func RPCError(endpoint, method, kind string) {
+	rpcErrors.WithLabelValues(endpoint, method, kind).Inc()
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	spooledBatches = Factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "spooled_batches",
+		Help:      "Batches spooled to disk while ClickHouse was unreachable and not yet replayed, per chain",
+	}, []string{"chain"})
+	spooledBytes = Factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "spooled_bytes",
+		Help:      "Compressed bytes of spooled batches not yet replayed, per chain",
+	}, []string{"chain"})
+)
+
+// SetSpooled records the batches and bytes a chain has spooled to disk
+func SetSpooled(chainID uint32, batches int, bytes int64) {
+	chain := strconv.FormatUint(uint64(chainID), 10)
+	spooledBatches.WithLabelValues(chain).Set(float64(batches))
+	spooledBytes.WithLabelValues(chain).Set(float64(bytes))
+}
@@ -0,0 +1,20 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Webhook delivery results
+const (
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed"
+)
+
+var webhookDeliveries = Factory.NewCounterVec(prometheus.CounterOpts{
+	Namespace: Namespace,
+	Name:      "webhook_deliveries_total",
+	Help:      "Webhook events by rule and result, failed once every retry was used up",
+}, []string{"rule", "result"})
+
+// WebhookDelivery counts an event of a webhook rule that was delivered or given up on
+func WebhookDelivery(rule, result string) {
+	webhookDeliveries.WithLabelValues(rule, result).Inc()
+}
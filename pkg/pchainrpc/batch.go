@@ -0,0 +1,244 @@
+package pchainrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"icicle/pkg/metrics"
+	"icicle/pkg/rpcerr"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/avalanchego/api"
+	"github.com/ava-labs/avalanchego/utils/formatting"
+	avajson "github.com/ava-labs/avalanchego/utils/json"
+	"golang.org/x/sync/errgroup"
+)
+
+// errBatchUnsupported is returned by SendBatch when the node answers a batch with a single
+// invalid request or method not found error
+var errBatchUnsupported = errors.New("JSON-RPC batches not supported")
+
+// batchReply is one reply of a JSON-RPC batch
+type batchReply struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// SendBatch sends one method call per params in a single HTTP request and unmarshals each result
+// into the reply at the same index. Fails if any call fails.
+func (r *pooledRequester) SendBatch(ctx context.Context, method string, params []interface{}, replies []interface{}) error {
+	requests := make([]map[string]interface{}, len(params))
+	for i := range params {
+		requests[i] = map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      i,
+			"method":  method,
+			"params":  params[i],
+		}
+	}
+
+	jsonData, err := json.Marshal(requests)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", r.uri+"/ext/P", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range r.headers {
+		req.Header.Set(key, value)
+	}
+
+	if err := r.breaker.Wait(ctx); err != nil {
+		return err
+	}
+	start := time.Now()
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		metrics.ObserveRPC(r.breaker.Endpoint(), method, start, metrics.ErrTransport)
+		err = fmt.Errorf("failed to issue batch request: %w", err)
+		r.breaker.Failure(err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		metrics.ObserveRPC(r.breaker.Endpoint(), method, start, metrics.ErrStatus)
+		err := rpcerr.FromStatus(resp.StatusCode)
+		if rpcerr.IsPermanent(err) {
+			r.breaker.Success() // The endpoint answered, the request is wrong
+		} else {
+			r.breaker.Failure(err)
+		}
+		return err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		metrics.ObserveRPC(r.breaker.Endpoint(), method, start, metrics.ErrDecode)
+		err = fmt.Errorf("failed to read batch response: %w", err)
+		r.breaker.Failure(err)
+		return err
+	}
+	r.breaker.Success()
+
+	// Nodes without batch support answer the whole array with one error object. Other single
+	// replies, e.g. an overloaded node's, are returned as retryable errors.
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+		metrics.ObserveRPC(r.breaker.Endpoint(), method, start, metrics.ErrReply)
+		var reply batchReply
+		if err := json.Unmarshal(trimmed, &reply); err != nil || reply.Error == nil {
+			return fmt.Errorf("unexpected single reply to batch request: %s", trimmed)
+		}
+		switch reply.Error.Code {
+		case rpcerr.CodeInvalidRequest, rpcerr.CodeMethodNotFound:
+			return fmt.Errorf("%w: %s", errBatchUnsupported, trimmed)
+		}
+		return fmt.Errorf("batch request failed: rpc error %d: %s", reply.Error.Code, reply.Error.Message)
+	}
+
+	var batch []batchReply
+	if err := json.Unmarshal(body, &batch); err != nil {
+		metrics.ObserveRPC(r.breaker.Endpoint(), method, start, metrics.ErrDecode)
+		return fmt.Errorf("failed to decode batch response: %w", err)
+	}
+	metrics.ObserveRPC(r.breaker.Endpoint(), method, start, "")
+	if len(batch) != len(params) {
+		return fmt.Errorf("batch response count mismatch: sent %d, got %d", len(params), len(batch))
+	}
+
+	for _, reply := range batch {
+		if reply.ID < 0 || reply.ID >= len(replies) {
+			return fmt.Errorf("unexpected batch response ID %d", reply.ID)
+		}
+		if reply.Error != nil {
+			metrics.RPCError(r.breaker.Endpoint(), method, metrics.ErrReply)
+			return fmt.Errorf("%s call %d failed: %w", method, reply.ID, rpcerr.FromCode(reply.Error.Code, reply.Error.Message))
+		}
+		if err := json.Unmarshal(reply.Result, replies[reply.ID]); err != nil {
+			return fmt.Errorf("failed to unmarshal result %d: %w", reply.ID, err)
+		}
+	}
+	return nil
+}
+
+// fetchBlockBytes fetches the raw bytes of blocks at heights, rpcBatchSize getBlockByHeight calls
+// per HTTP request, and caches them. Nodes that don't accept JSON-RPC batches get one request per
+// block instead.
+func (f *Fetcher) fetchBlockBytes(ctx context.Context, heights []int64) (map[int64][]byte, error) {
+	batchSize := f.rpcBatchSize
+	if f.noBatch.Load() {
+		batchSize = 1
+	}
+
+	result := make(map[int64][]byte, len(heights))
+	var mu sync.Mutex
+	g, ctx := errgroup.WithContext(ctx)
+	for start := 0; start < len(heights); start += batchSize {
+		chunk := heights[start:min(start+batchSize, len(heights))]
+		g.Go(func() error {
+			blocks, err := f.fetchBlockBatch(ctx, chunk)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for i, height := range chunk {
+				result[height] = blocks[i]
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	if f.cache != nil {
+		for height, blockBytes := range result {
+			_, _ = f.cache.GetCompleteBlock(height, func() ([]byte, error) {
+				return blockBytes, nil
+			})
+		}
+	}
+	return result, nil
+}
+
+// fetchBlockBatch fetches one batch of blocks with retry logic, failing fast on permanent errors
+func (f *Fetcher) fetchBlockBatch(ctx context.Context, heights []int64) ([][]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt <= f.maxRetries; attempt++ {
+		if attempt > 0 {
+			delay := f.retryDelay * time.Duration(1<<uint(attempt-1))
+			if delay > 10*time.Second {
+				delay = 10 * time.Second
+			}
+			time.Sleep(delay)
+		}
+
+		f.rpcLimit <- struct{}{}
+		blocks, err := f.getBlocksByHeight(ctx, heights)
+		<-f.rpcLimit
+		if err == nil {
+			return blocks, nil
+		}
+		if rpcerr.IsPermanent(err) || ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to fetch blocks %d-%d: %w", heights[0], heights[len(heights)-1], err)
+		}
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("failed to fetch blocks %d-%d after %d retries: %w", heights[0], heights[len(heights)-1], f.maxRetries, lastErr)
+}
+
+// getBlocksByHeight sends the getBlockByHeight calls of heights as one batch, or one by one when
+// batching is off. Switches batching off for good the first time the node rejects a batch.
+func (f *Fetcher) getBlocksByHeight(ctx context.Context, heights []int64) ([][]byte, error) {
+	blocks := make([][]byte, len(heights))
+
+	if len(heights) > 1 && !f.noBatch.Load() {
+		params := make([]interface{}, len(heights))
+		replies := make([]interface{}, len(heights))
+		formatted := make([]api.FormattedBlock, len(heights))
+		for i, height := range heights {
+			params[i] = &api.GetBlockByHeightArgs{Height: avajson.Uint64(height), Encoding: formatting.HexNC}
+			replies[i] = &formatted[i]
+		}
+
+		err := f.requester.SendBatch(ctx, "platform.getBlockByHeight", params, replies)
+		if err == nil {
+			for i := range formatted {
+				blockBytes, err := formatting.Decode(formatted[i].Encoding, formatted[i].Block)
+				if err != nil {
+					return nil, fmt.Errorf("failed to decode block %d: %w", heights[i], err)
+				}
+				blocks[i] = blockBytes
+			}
+			return blocks, nil
+		}
+		if !errors.Is(err, errBatchUnsupported) {
+			return nil, blockByHeightError(err)
+		}
+		f.logger.Info("Node doesn't accept JSON-RPC batches, fetching blocks one request at a time", "error", err)
+		f.noBatch.Store(true)
+	}
+
+	for i, height := range heights {
+		blockBytes, err := f.client.GetBlockByHeight(ctx, uint64(height))
+		if err != nil {
+			return nil, blockByHeightError(err)
+		}
+		blocks[i] = blockBytes
+	}
+	return blocks, nil
+}
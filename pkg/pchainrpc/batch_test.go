@@ -0,0 +1,39 @@
+package pchainrpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendBatchSingleReply(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		unsupported bool
+	}{
+		{"method not found", `{"jsonrpc":"2.0","id":null,"error":{"code":-32601,"message":"method not found"}}`, true},
+		{"invalid request", `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"invalid request"}}`, true},
+		{"server error", `{"jsonrpc":"2.0","id":null,"error":{"code":-32000,"message":"too many requests"}}`, false},
+		{"no error", `{"jsonrpc":"2.0","id":0,"result":{}}`, false},
+		{"not json", `{oops`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			var reply struct{}
+			err := newPooledRequester(server.URL, nil, false).SendBatch(context.Background(), "platform.getHeight",
+				[]interface{}{struct{}{}}, []interface{}{&reply})
+			require.Error(t, err)
+			require.Equal(t, tt.unsupported, errors.Is(err, errBatchUnsupported), err.Error())
+		})
+	}
+}
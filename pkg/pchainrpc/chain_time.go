@@ -0,0 +1,200 @@
+package pchainrpc
+
+import (
+	"context"
+	"fmt"
+	"icicle/pkg/logging"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/avalanchego/vms/platformvm/block"
+	"github.com/ava-labs/avalanchego/vms/platformvm/txs"
+)
+
+// maxChainTimeLookback bounds the backward search used to bootstrap the chain time tracker
+const maxChainTimeLookback = 10000
+
+// blockTimeInfo captures what a block contributes to the P-Chain's chain time
+type blockTimeInfo struct {
+	apricot     bool      // Pre-Banff block without a timestamp of its own
+	commit      bool      // ApricotCommitBlock, accepts the parent proposal
+	advanceTime time.Time // Time proposed by an AdvanceTimeTx in an ApricotProposalBlock
+}
+
+// getBlockTimeInfo classifies a block for chain time tracking
+func getBlockTimeInfo(blk block.Block) blockTimeInfo {
+	switch blk.(type) {
+	case *block.ApricotCommitBlock:
+		return blockTimeInfo{apricot: true, commit: true}
+	case *block.ApricotProposalBlock:
+		info := blockTimeInfo{apricot: true}
+		for _, tx := range blk.Txs() {
+			if advTimeTx, ok := tx.Unsigned.(*txs.AdvanceTimeTx); ok {
+				info.advanceTime = advTimeTx.Timestamp()
+			}
+		}
+		return info
+	case *block.ApricotAbortBlock, *block.ApricotStandardBlock, *block.ApricotAtomicBlock:
+		return blockTimeInfo{apricot: true}
+	default:
+		return blockTimeInfo{}
+	}
+}
+
+// chainTimeTracker carries the P-Chain time forward as blocks are processed in order.
+// Apricot blocks have no timestamp; chain time only moves when a proposal block with an
+// AdvanceTimeTx is followed by a commit block.
+type chainTimeTracker struct {
+	mu        sync.Mutex
+	valid     bool
+	height    uint64    // Last applied block height
+	chainTime time.Time // Chain time after the last applied block
+	pending   time.Time // AdvanceTimeTx time proposed by the last applied block, if any
+}
+
+// apply advances the tracker by one block and returns that block's timestamp
+func (t *chainTimeTracker) apply(height uint64, info blockTimeInfo, timestamp time.Time) time.Time {
+	switch {
+	case !info.apricot:
+		t.chainTime = timestamp
+		t.pending = time.Time{}
+	case info.commit && !t.pending.IsZero():
+		t.chainTime = t.pending
+		t.pending = time.Time{}
+	default:
+		t.pending = info.advanceTime
+	}
+	t.height = height
+	t.valid = true
+	return t.chainTime
+}
+
+// resolveBlockTimes fills in timestamps for a contiguous, ordered range of blocks.
+// The tracker is bootstrapped over RPC only when the range doesn't continue from the last one.
+func (f *Fetcher) resolveBlockTimes(heights []uint64, infos []blockTimeInfo, timestamps []time.Time) ([]time.Time, error) {
+	if len(heights) == 0 {
+		return nil, nil
+	}
+
+	f.chainTime.mu.Lock()
+	defer f.chainTime.mu.Unlock()
+
+	contiguous := f.chainTime.valid && f.chainTime.height+1 == heights[0]
+	if !contiguous && infos[0].apricot && heights[0] > 0 {
+		if err := f.bootstrapChainTime(heights[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	resolved := make([]time.Time, len(heights))
+	for i := range heights {
+		resolved[i] = f.chainTime.apply(heights[i], infos[i], timestamps[i])
+		if logging.DebugBlock(heights[i]) {
+			f.logger.Info("Resolved debug block timestamp", "block", heights[i], "apricot", infos[i].apricot, "block_timestamp", timestamps[i], "resolved", resolved[i])
+		}
+	}
+	return resolved, nil
+}
+
+// bootstrapChainTime recovers the chain time in effect right before height by walking back
+// to the most recent committed AdvanceTimeTx (or Banff block). Caller must hold the lock.
+func (f *Fetcher) bootstrapChainTime(height uint64) error {
+	var pending time.Time
+	var child blockTimeInfo
+
+	for h := height - 1; h+maxChainTimeLookback >= height; h-- {
+		blockBytes, err := f.client.GetBlockByHeight(context.Background(), h)
+		if err != nil {
+			return fmt.Errorf("failed to fetch block %d for chain time: %w", h, err)
+		}
+
+		blk, err := block.Parse(block.Codec, blockBytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse block %d for chain time: %w", h, err)
+		}
+
+		info := getBlockTimeInfo(blk)
+		switch {
+		case !info.apricot:
+			extractor := &timestampExtractor{}
+			if err := blk.Visit(extractor); err != nil {
+				return fmt.Errorf("failed to extract timestamp of block %d: %w", h, err)
+			}
+			f.setChainTime(height-1, extractor.timestamp, pending)
+			return nil
+		case !info.advanceTime.IsZero() && h == height-1:
+			// The proposal right before the range is still waiting on its commit/abort
+			pending = info.advanceTime
+		case !info.advanceTime.IsZero() && child.commit:
+			f.setChainTime(height-1, info.advanceTime, pending)
+			return nil
+		}
+
+		if h == 0 {
+			break
+		}
+		child = info
+	}
+
+	// No committed AdvanceTimeTx in range, fall back to a height-based estimate
+	mainnetLaunch := time.Date(2020, 9, 21, 0, 0, 0, 0, time.UTC)
+	estimated := mainnetLaunch.Add(time.Duration(int64(height)*2) * time.Second)
+	f.logger.Warn("No AdvanceTimeTx found, estimating chain time",
+		"lookback", maxChainTimeLookback, "height", height, "estimated", estimated)
+	f.setChainTime(height-1, estimated, pending)
+	return nil
+}
+
+// setChainTime resets the tracker to a known state. Caller must hold the lock.
+func (f *Fetcher) setChainTime(height uint64, chainTime, pending time.Time) {
+	f.chainTime.valid = true
+	f.chainTime.height = height
+	f.chainTime.chainTime = chainTime
+	f.chainTime.pending = pending
+}
+
+// applyChainTime sets timestamps on an ordered range of normalized blocks and their txs
+func (f *Fetcher) applyChainTime(blocks []*NormalizedBlock) error {
+	heights := make([]uint64, len(blocks))
+	infos := make([]blockTimeInfo, len(blocks))
+	timestamps := make([]time.Time, len(blocks))
+	for i, b := range blocks {
+		heights[i], infos[i], timestamps[i] = b.Height, b.timeInfo, b.Timestamp
+	}
+
+	resolved, err := f.resolveBlockTimes(heights, infos, timestamps)
+	if err != nil {
+		return err
+	}
+
+	for i, b := range blocks {
+		b.Timestamp = resolved[i]
+		for j := range b.Transactions {
+			b.Transactions[j].BlockTime = resolved[i]
+		}
+	}
+	return nil
+}
+
+// applyChainTimeJSON sets timestamps on an ordered range of JSON blocks and their txs
+func (f *Fetcher) applyChainTimeJSON(blocks []*JSONBlock) error {
+	heights := make([]uint64, len(blocks))
+	infos := make([]blockTimeInfo, len(blocks))
+	timestamps := make([]time.Time, len(blocks))
+	for i, b := range blocks {
+		heights[i], infos[i], timestamps[i] = b.Height, b.timeInfo, b.Timestamp
+	}
+
+	resolved, err := f.resolveBlockTimes(heights, infos, timestamps)
+	if err != nil {
+		return err
+	}
+
+	for i, b := range blocks {
+		b.Timestamp = resolved[i]
+		for j := range b.Transactions {
+			b.Transactions[j].BlockTime = resolved[i]
+		}
+	}
+	return nil
+}
@@ -2,28 +2,47 @@ package pchainrpc
 
 import (
 	"bytes"
-	"icicle/pkg/cache"
 	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"log"
+	"icicle/pkg/breaker"
+	"icicle/pkg/cache"
+	"icicle/pkg/httpcompress"
+	"icicle/pkg/logging"
+	"icicle/pkg/metrics"
+	"icicle/pkg/rpcerr"
+	"icicle/pkg/tracing"
+	"log/slog"
 	"net"
 	"net/http"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/utils/cb58"
+	"github.com/ava-labs/avalanchego/utils/crypto/bls"
+	"github.com/ava-labs/avalanchego/utils/formatting"
 	"github.com/ava-labs/avalanchego/utils/formatting/address"
+	avajson "github.com/ava-labs/avalanchego/utils/json"
 	"github.com/ava-labs/avalanchego/utils/rpc"
+	"github.com/ava-labs/avalanchego/vms/components/avax"
 	"github.com/ava-labs/avalanchego/vms/platformvm"
+	platformapi "github.com/ava-labs/avalanchego/vms/platformvm/api"
 	"github.com/ava-labs/avalanchego/vms/platformvm/block"
+	"github.com/ava-labs/avalanchego/vms/platformvm/stakeable"
 	"github.com/ava-labs/avalanchego/vms/platformvm/txs"
+	"github.com/ava-labs/avalanchego/vms/secp256k1fx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("pchainrpc")
+
 // ConvertCB58ToPChainAddress converts a short CB58 address to P-Chain bech32 format
 // e.g., "AhRtxbQdas3HyjjTXjL49CgZpF7eaSYCp" -> "P-avax1..."
 func ConvertCB58ToPChainAddress(shortAddr string) (string, error) {
@@ -69,21 +88,33 @@ func ConvertCB58ToPChainAddressFuji(shortAddr string) (string, error) {
 
 type FetcherOptions struct {
 	RpcURL         string
-	MaxConcurrency int           // Maximum concurrent RPC requests
-	BatchSize      int           // Number of blocks per batch
-	MaxRetries     int           // Maximum number of retries per request
-	RetryDelay     time.Duration // Initial retry delay
-	Cache          *cache.Cache  // Optional cache for complete blocks
+	RpcHeaders     map[string]string // Extra HTTP headers sent with every request, e.g. API keys
+	NoCompression  bool              // Don't ask for gzip/deflate compressed responses
+	ChainID        uint32            // Chain ID, tags log records and spans
+	ChainName      string            // Chain name, tags log records
+	MaxConcurrency int               // Maximum concurrent RPC requests
+	BatchSize      int               // Number of blocks per batch
+	RpcBatchSize   int               // getBlockByHeight calls per HTTP request (default: 100)
+	MaxRetries     int               // Maximum number of retries per request
+	RetryDelay     time.Duration     // Initial retry delay
+	Cache          cache.Cache       // Optional cache for complete blocks
+	CacheTTLs      cache.TTLs        // Cache validator responses in their own namespaces
+
+	// Block parsing
+	ParseWorkers    int  // Workers parsing and normalizing blocks (default: GOMAXPROCS)
+	PinParseWorkers bool // Pin each parse worker to its own CPU (Linux only)
+	TypedTxs        bool // Also extract the typed fields of every JSON tx into JSONTx.Fields
 }
 
-
 // pooledRequester implements EndpointRequester with proper connection pooling
 type pooledRequester struct {
 	uri        string
+	headers    map[string]string
 	httpClient *http.Client
+	breaker    *breaker.Breaker
 }
 
-func newPooledRequester(uri string) *pooledRequester {
+func newPooledRequester(uri string, headers map[string]string, compression bool) *pooledRequester {
 	transport := &http.Transport{
 		MaxIdleConns:        10000,
 		MaxIdleConnsPerHost: 10000,
@@ -96,15 +127,22 @@ func newPooledRequester(uri string) *pooledRequester {
 	}
 
 	return &pooledRequester{
-		uri: uri,
+		uri:     uri,
+		headers: headers,
+		breaker: breaker.For(uri),
 		httpClient: &http.Client{
 			Timeout:   5 * time.Minute,
-			Transport: transport,
+			Transport: httpcompress.Wrap(transport, compression),
 		},
 	}
 }
 
 func (r *pooledRequester) SendRequest(ctx context.Context, method string, params interface{}, reply interface{}, options ...rpc.Option) error {
+	return r.sendTo(ctx, "/ext/P", method, params, reply)
+}
+
+// sendTo sends a request to the API at path of the node, e.g. /ext/info
+func (r *pooledRequester) sendTo(ctx context.Context, path, method string, params interface{}, reply interface{}) error {
 	reqBody := map[string]interface{}{
 		"jsonrpc": "2.0",
 		"id":      1,
@@ -117,18 +155,39 @@ func (r *pooledRequester) SendRequest(ctx context.Context, method string, params
 		return fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", r.uri+"/ext/P", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", r.uri+path, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	for key, value := range r.headers {
+		req.Header.Set(key, value)
+	}
 
+	if err := r.breaker.Wait(ctx); err != nil {
+		return err
+	}
+	start := time.Now()
 	resp, err := r.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to issue request: %w", err)
+		metrics.ObserveRPC(r.breaker.Endpoint(), method, start, metrics.ErrTransport)
+		err = fmt.Errorf("failed to issue request: %w", err)
+		r.breaker.Failure(err)
+		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		metrics.ObserveRPC(r.breaker.Endpoint(), method, start, metrics.ErrStatus)
+		err := rpcerr.FromStatus(resp.StatusCode)
+		if rpcerr.IsPermanent(err) {
+			r.breaker.Success() // The endpoint answered, the request is wrong
+		} else {
+			r.breaker.Failure(err)
+		}
+		return err
+	}
+
 	var rpcResp struct {
 		Result json.RawMessage `json:"result"`
 		Error  *struct {
@@ -138,13 +197,20 @@ func (r *pooledRequester) SendRequest(ctx context.Context, method string, params
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
-		return fmt.Errorf("failed to decode response: %w", err)
+		metrics.ObserveRPC(r.breaker.Endpoint(), method, start, metrics.ErrDecode)
+		err = fmt.Errorf("failed to decode response: %w", err)
+		r.breaker.Failure(err)
+		return err
 	}
+	r.breaker.Success()
 
 	if rpcResp.Error != nil {
-		return fmt.Errorf("rpc error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+		metrics.ObserveRPC(r.breaker.Endpoint(), method, start, metrics.ErrReply)
+		return rpcerr.FromCode(rpcResp.Error.Code, rpcResp.Error.Message)
 	}
 
+	metrics.ObserveRPC(r.breaker.Endpoint(), method, start, "")
+
 	if err := json.Unmarshal(rpcResp.Result, reply); err != nil {
 		return fmt.Errorf("failed to unmarshal result: %w", err)
 	}
@@ -154,14 +220,35 @@ func (r *pooledRequester) SendRequest(ctx context.Context, method string, params
 
 type Fetcher struct {
 	client     *platformvm.Client
+	requester  *pooledRequester
 	rpcURL     string
 	batchSize  int
 	maxRetries int
 	retryDelay time.Duration
-	cache      *cache.Cache
+	cache      cache.Cache
+	chainID    uint32
+
+	// Validator responses cached with their own TTLs, nil unless configured
+	validatorsCache   *cache.Entries
+	l1ValidatorsCache *cache.Entries
+	logger            *slog.Logger
 
 	// Concurrency control
-	rpcLimit chan struct{}
+	rpcLimit       chan struct{}
+	maxConcurrency int
+	limitMu        sync.Mutex
+	reserved       int // Slots of rpcLimit held back by SetConcurrency
+
+	// JSON-RPC batching of block fetches
+	rpcBatchSize int
+	noBatch      atomic.Bool // Set once the node turns out not to accept batches
+
+	// CPU-bound block parsing, separate from RPC concurrency
+	parsePool *parsePool
+	typedTxs  bool
+
+	// Chain time tracking for Apricot blocks
+	chainTime chainTimeTracker
 }
 
 func NewFetcher(opts FetcherOptions) *Fetcher {
@@ -171,32 +258,65 @@ func NewFetcher(opts FetcherOptions) *Fetcher {
 	if opts.BatchSize == 0 {
 		opts.BatchSize = 100
 	}
+	if opts.RpcBatchSize == 0 {
+		opts.RpcBatchSize = 100
+	}
 	if opts.MaxRetries == 0 {
 		opts.MaxRetries = 3
 	}
 	if opts.RetryDelay == 0 {
 		opts.RetryDelay = 500 * time.Millisecond
 	}
+	if opts.ParseWorkers == 0 {
+		opts.ParseWorkers = runtime.GOMAXPROCS(0)
+	}
 
 	// Create client with custom HTTP connection pooling
-	requester := newPooledRequester(opts.RpcURL)
+	requester := newPooledRequester(opts.RpcURL, opts.RpcHeaders, !opts.NoCompression)
 	client := &platformvm.Client{
 		Requester: requester,
 	}
 
+	logger := logging.Chain("pchainrpc", opts.ChainID, opts.ChainName)
 	f := &Fetcher{
-		client:     client,
-		rpcURL:     opts.RpcURL,
-		batchSize:  opts.BatchSize,
-		maxRetries: opts.MaxRetries,
-		retryDelay: opts.RetryDelay,
-		cache:      opts.Cache,
-		rpcLimit:   make(chan struct{}, opts.MaxConcurrency),
+		client:         client,
+		requester:      requester,
+		rpcURL:         opts.RpcURL,
+		batchSize:      opts.BatchSize,
+		maxRetries:     opts.MaxRetries,
+		retryDelay:     opts.RetryDelay,
+		cache:          opts.Cache,
+		chainID:        opts.ChainID,
+		logger:         logger,
+		rpcLimit:       make(chan struct{}, opts.MaxConcurrency),
+		maxConcurrency: opts.MaxConcurrency,
+		rpcBatchSize:   opts.RpcBatchSize,
+		parsePool:      newParsePool(opts.ParseWorkers, opts.PinParseWorkers, logger),
+		typedTxs:       opts.TypedTxs,
+
+		validatorsCache:   opts.CacheTTLs.Entries(opts.Cache, cache.NamespaceValidators),
+		l1ValidatorsCache: opts.CacheTTLs.Entries(opts.Cache, cache.NamespaceL1Validators),
 	}
 
 	return f
 }
 
+// SetConcurrency lowers the number of concurrent RPC requests below MaxConcurrency by
+// holding back slots. Waits for in-flight requests to free the slots it takes.
+func (f *Fetcher) SetConcurrency(n int) {
+	n = max(1, min(n, f.maxConcurrency))
+
+	f.limitMu.Lock()
+	defer f.limitMu.Unlock()
+
+	for target := f.maxConcurrency - n; f.reserved < target; f.reserved++ {
+		f.rpcLimit <- struct{}{}
+	}
+	for target := f.maxConcurrency - n; f.reserved > target; f.reserved-- {
+		<-f.rpcLimit
+	}
+}
+
 // GetLatestBlock returns the latest block height from the P-chain
 func (f *Fetcher) GetLatestBlock() (int64, error) {
 	var lastErr error
@@ -206,12 +326,15 @@ func (f *Fetcher) GetLatestBlock() (int64, error) {
 			if delay > 10*time.Second {
 				delay = 10 * time.Second
 			}
-			log.Printf("WARNING: GetHeight failed: %v. Retrying (attempt %d/%d) after %v", lastErr, attempt, f.maxRetries, delay)
+			f.logger.Warn("GetHeight failed, retrying", "error", lastErr, "attempt", attempt, "max_retries", f.maxRetries, "delay", delay)
 			time.Sleep(delay)
 		}
 
 		height, err := f.client.GetHeight(context.Background())
 		if err != nil {
+			if rpcerr.IsPermanent(err) {
+				return 0, fmt.Errorf("failed to get latest block: %w", err)
+			}
 			lastErr = err
 			continue
 		}
@@ -221,8 +344,47 @@ func (f *Fetcher) GetLatestBlock() (int64, error) {
 	return 0, fmt.Errorf("failed to get latest block after %d retries: %w", f.maxRetries, lastErr)
 }
 
+// GetNetworkID returns the network ID of the node, e.g. 1 on Mainnet and 5 on Fuji
+func (f *Fetcher) GetNetworkID() (uint32, error) {
+	var lastErr error
+	for attempt := 0; attempt <= f.maxRetries; attempt++ {
+		if attempt > 0 {
+			delay := min(f.retryDelay*time.Duration(1<<uint(attempt-1)), 10*time.Second)
+			f.logger.Warn("getNetworkID failed, retrying", "error", lastErr, "attempt", attempt, "max_retries", f.maxRetries, "delay", delay)
+			time.Sleep(delay)
+		}
+
+		var reply struct {
+			NetworkID avajson.Uint32 `json:"networkID"`
+		}
+		err := f.requester.sendTo(context.Background(), "/ext/info", "info.getNetworkID", struct{}{}, &reply)
+		if err != nil {
+			if rpcerr.IsPermanent(err) {
+				return 0, fmt.Errorf("failed to get network ID: %w", err)
+			}
+			lastErr = err
+			continue
+		}
+		return uint32(reply.NetworkID), nil
+	}
+
+	return 0, fmt.Errorf("failed to get network ID after %d retries: %w", f.maxRetries, lastErr)
+}
+
 // FetchBlockRange fetches all blocks in the range [from, to] inclusive
 func (f *Fetcher) FetchBlockRange(from, to int64) ([]*NormalizedBlock, error) {
+	blocks, err := f.fetchBlockRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.applyChainTime(blocks); err != nil {
+		return nil, fmt.Errorf("failed to resolve block timestamps: %w", err)
+	}
+	return blocks, nil
+}
+
+// fetchBlockRange fetches blocks in [from, to] without resolving Apricot timestamps
+func (f *Fetcher) fetchBlockRange(from, to int64) ([]*NormalizedBlock, error) {
 	if from > to {
 		return nil, fmt.Errorf("invalid range: from %d > to %d", from, to)
 	}
@@ -241,23 +403,19 @@ func (f *Fetcher) FetchBlockRange(from, to int64) ([]*NormalizedBlock, error) {
 		return nil, fmt.Errorf("failed to query cache range: %w", err)
 	}
 
-	// Step 2: Process cache hits and identify misses
+	// Step 2: Parse cache hits on the parse pool and identify misses
+	parsed, failed := parseBlocks(cachedData, f.parseAndNormalize)
 	var missingBlocks []int64
 	for i := int64(0); i < int64(numBlocks); i++ {
 		blockNum := from + i
-		if rawBytes, ok := cachedData[blockNum]; ok && rawBytes != nil {
-			// Cache hit - parse and normalize raw bytes
-			normalized, err := f.parseAndNormalize(rawBytes)
-			if err != nil {
-				log.Printf("Warning: failed to parse cached block %d: %v", blockNum, err)
-				missingBlocks = append(missingBlocks, blockNum)
-			} else {
-				result[int(i)] = normalized
-			}
-		} else {
-			// Cache miss
-			missingBlocks = append(missingBlocks, blockNum)
+		if normalized, ok := parsed[blockNum]; ok && normalized != nil {
+			result[int(i)] = normalized
+			continue
+		}
+		if err, ok := failed[blockNum]; ok {
+			f.logger.Warn("Failed to parse cached block", "block", blockNum, "error", err)
 		}
+		missingBlocks = append(missingBlocks, blockNum)
 	}
 
 	// Step 3: If all cached, return
@@ -286,46 +444,20 @@ func (f *Fetcher) FetchBlockRange(from, to int64) ([]*NormalizedBlock, error) {
 
 // fetchBlockRangeUncached fetches blocks without using cache
 func (f *Fetcher) fetchBlockRangeUncached(from, to int64) ([]*NormalizedBlock, error) {
-	numBlocks := int(to - from + 1)
-	blocks := make([]*NormalizedBlock, numBlocks)
-
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-	var fetchErr error
-
-	// Fetch blocks concurrently
-	for i := int64(0); i < int64(numBlocks); i++ {
-		wg.Add(1)
-		go func(idx int64) {
-			defer wg.Done()
-
-			blockHeight := from + idx
-
-			f.rpcLimit <- struct{}{}
-			defer func() { <-f.rpcLimit }()
-
-			block, err := f.fetchSingleBlock(blockHeight)
-			if err != nil {
-				mu.Lock()
-				if fetchErr == nil {
-					fetchErr = fmt.Errorf("failed to fetch block %d: %w", blockHeight, err)
-				}
-				mu.Unlock()
-				return
-			}
-
-			mu.Lock()
-			blocks[idx] = block
-			mu.Unlock()
-		}(i)
+	heights := make([]int64, 0, to-from+1)
+	for height := from; height <= to; height++ {
+		heights = append(heights, height)
 	}
 
-	wg.Wait()
-
-	if fetchErr != nil {
-		return nil, fetchErr
+	fetched, err := f.fetchAndCacheMissingBlocks(heights)
+	if err != nil {
+		return nil, err
 	}
 
+	blocks := make([]*NormalizedBlock, len(heights))
+	for i, height := range heights {
+		blocks[i] = fetched[height]
+	}
 	return blocks, nil
 }
 
@@ -335,110 +467,39 @@ func (f *Fetcher) fetchAndCacheMissingBlocks(missingBlocks []int64) (map[int64]*
 		return make(map[int64]*NormalizedBlock), nil
 	}
 
-	result := make(map[int64]*NormalizedBlock)
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-	var fetchErr error
-
-	for _, blockNum := range missingBlocks {
-		wg.Add(1)
-		go func(height int64) {
-			defer wg.Done()
-
-			f.rpcLimit <- struct{}{}
-			defer func() { <-f.rpcLimit }()
-
-			// Fetch raw block bytes
-			blockBytes, err := f.client.GetBlockByHeight(context.Background(), uint64(height))
-			if err != nil {
-				mu.Lock()
-				if fetchErr == nil {
-					fetchErr = fmt.Errorf("GetBlockByHeight failed for block %d: %w", height, err)
-				}
-				mu.Unlock()
-				return
-			}
-
-			// Cache raw bytes immediately
-			if f.cache != nil {
-				_, _ = f.cache.GetCompleteBlock(height, func() ([]byte, error) {
-					return blockBytes, nil
-				})
-			}
-
-			// Parse and normalize
-			normalized, err := f.parseAndNormalize(blockBytes)
-			if err != nil {
-				mu.Lock()
-				if fetchErr == nil {
-					fetchErr = fmt.Errorf("parseAndNormalize failed for block %d: %w", height, err)
-				}
-				mu.Unlock()
-				return
-			}
-
-			mu.Lock()
-			result[height] = normalized
-			mu.Unlock()
-		}(blockNum)
+	blockBytes, err := f.fetchBlockBytes(context.Background(), missingBlocks)
+	if err != nil {
+		return nil, err
 	}
 
-	wg.Wait()
-
-	if fetchErr != nil {
-		return nil, fetchErr
+	result, failed := parseBlocks(blockBytes, f.parseAndNormalize)
+	for height, err := range failed {
+		return nil, fmt.Errorf("parseAndNormalize failed for block %d: %w", height, err)
 	}
-
 	return result, nil
 }
 
-// parseAndNormalize parses raw block bytes and normalizes them
-func (f *Fetcher) parseAndNormalize(blockBytes []byte) (*NormalizedBlock, error) {
-	blk, err := block.Parse(block.Codec, blockBytes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse block: %w", err)
-	}
-	return f.normalizeBlock(blk)
-}
-
-// fetchSingleBlock fetches a single block by height with retry logic
-func (f *Fetcher) fetchSingleBlock(height int64) (*NormalizedBlock, error) {
-	var lastErr error
-
-	for attempt := 0; attempt <= f.maxRetries; attempt++ {
-		if attempt > 0 {
-			delay := f.retryDelay * time.Duration(1<<uint(attempt-1))
-			if delay > 10*time.Second {
-				delay = 10 * time.Second
-			}
-			time.Sleep(delay)
-		}
-
-		// Fetch block bytes
-		blockBytes, err := f.client.GetBlockByHeight(context.Background(), uint64(height))
+// parseAndNormalize parses raw block bytes and normalizes them on the parse pool
+func (f *Fetcher) parseAndNormalize(blockBytes []byte) (normalized *NormalizedBlock, err error) {
+	f.parsePool.run(func() {
+		var blk block.Block
+		blk, err = block.Parse(block.Codec, blockBytes)
 		if err != nil {
-			lastErr = fmt.Errorf("GetBlockByHeight failed: %w", err)
-			continue
-		}
-
-		// Cache raw bytes immediately if cache is enabled
-		if f.cache != nil {
-			_, _ = f.cache.GetCompleteBlock(height, func() ([]byte, error) {
-				return blockBytes, nil
-			})
-		}
-
-		// Parse and normalize
-		normalized, err := f.parseAndNormalize(blockBytes)
-		if err != nil {
-			lastErr = fmt.Errorf("parseAndNormalize failed: %w", err)
-			continue
+			err = fmt.Errorf("failed to parse block: %w", err)
+			return
 		}
+		normalized, err = f.normalizeBlock(blk)
+	})
+	return normalized, err
+}
 
-		return normalized, nil
+// blockByHeightError marks a "not found" reply to getBlockByHeight permanent: the height is
+// beyond the tip (or pruned), which retrying won't change
+func blockByHeightError(err error) error {
+	if !rpcerr.IsPermanent(err) && strings.Contains(strings.ToLower(err.Error()), "not found") {
+		return rpcerr.Permanent(err)
 	}
-
-	return nil, fmt.Errorf("failed to fetch block %d after %d retries: %w", height, f.maxRetries, lastErr)
+	return err
 }
 
 // timestampExtractor implements block.Visitor to extract timestamps
@@ -496,45 +557,6 @@ func (te *timestampExtractor) ApricotAtomicBlock(b *block.ApricotAtomicBlock) er
 	return nil
 }
 
-// findTimestampForApricotBlock searches backwards to find the most recent AdvanceTimeTx
-// that established the chain timestamp for this block
-func (f *Fetcher) findTimestampForApricotBlock(currentHeight uint64) (time.Time, error) {
-	// Search backwards up to 100 blocks or until we find an AdvanceTimeTx
-	// AdvanceTimeTx typically appear every few blocks in Apricot era
-	maxLookback := uint64(100)
-	startHeight := uint64(0)
-	if currentHeight > maxLookback {
-		startHeight = currentHeight - maxLookback
-	}
-
-	for height := currentHeight; height >= startHeight && height > 0; height-- {
-		blockBytes, err := f.client.GetBlockByHeight(context.Background(), height)
-		if err != nil {
-			continue // Skip errors and keep searching
-		}
-
-		blk, err := block.Parse(block.Codec, blockBytes)
-		if err != nil {
-			continue
-		}
-
-		// Check all transactions in this block for AdvanceTimeTx
-		for _, tx := range blk.Txs() {
-			if tx.Unsigned == nil {
-				continue
-			}
-			if advTimeTx, ok := tx.Unsigned.(*txs.AdvanceTimeTx); ok {
-				return advTimeTx.Timestamp(), nil
-			}
-		}
-	}
-
-	// If no AdvanceTimeTx found in lookback window, estimate based on height
-	mainnetLaunch := time.Date(2020, 9, 21, 0, 0, 0, 0, time.UTC)
-	estimatedSeconds := int64(currentHeight) * 2
-	return mainnetLaunch.Add(time.Duration(estimatedSeconds) * time.Second), nil
-}
-
 // normalizeBlock converts a platform block to normalized structure
 func (f *Fetcher) normalizeBlock(blk block.Block) (*NormalizedBlock, error) {
 	// Extract timestamp using visitor pattern
@@ -543,51 +565,17 @@ func (f *Fetcher) normalizeBlock(blk block.Block) (*NormalizedBlock, error) {
 		return nil, fmt.Errorf("failed to extract timestamp: %w", err)
 	}
 	blockTime := extractor.timestamp
+	f.logDebugBlock(blk, blockTime)
 
-	// Debug logging for specific block
-	if blk.Height() == 1570934 {
-		log.Printf("[DEBUG] Block 1570934 - Block type: %T", blk)
-		log.Printf("[DEBUG] Block 1570934 - Initial timestamp from visitor: %v (IsZero=%v)", blockTime, blockTime.IsZero())
-	}
-
-	// For Apricot blocks (pre-Banff), find exact timestamp from AdvanceTimeTx
-	// The P-Chain maintains a chain-level timestamp that's updated via AdvanceTimeTx
-	if blockTime.IsZero() {
-		// First check if this block itself contains an AdvanceTimeTx
-		for _, tx := range blk.Txs() {
-			if tx.Unsigned == nil {
-				continue
-			}
-			if advTimeTx, ok := tx.Unsigned.(*txs.AdvanceTimeTx); ok {
-				blockTime = advTimeTx.Timestamp()
-				if blk.Height() == 1570934 {
-					log.Printf("[DEBUG] Block 1570934 - Found AdvanceTimeTx in block: %v", blockTime)
-				}
-				break
-			}
-		}
-
-		// If not found, search backwards for the most recent AdvanceTimeTx
-		if blockTime.IsZero() && blk.Height() > 0 {
-			var err error
-			blockTime, err = f.findTimestampForApricotBlock(blk.Height())
-			if err != nil {
-				return nil, fmt.Errorf("failed to find timestamp for Apricot block: %w", err)
-			}
-			if blk.Height() == 1570934 {
-				log.Printf("[DEBUG] Block 1570934 - Timestamp from backward search: %v", blockTime)
-			}
-		}
-	} else if blk.Height() == 1570934 {
-		log.Printf("[DEBUG] Block 1570934 - Using Banff timestamp: %v", blockTime)
-	}
-
+	// Apricot blocks (pre-Banff) have no timestamp, FetchBlockRange resolves them from chain time
 	normalized := &NormalizedBlock{
 		BlockID:      blk.ID(),
 		Height:       blk.Height(),
 		ParentID:     blk.Parent(),
 		Timestamp:    blockTime,
+		BlockType:    BlockTypeString(blk),
 		Transactions: make([]NormalizedTx, 0, len(blk.Txs())),
+		timeInfo:     getBlockTimeInfo(blk),
 	}
 
 	// Parse each transaction
@@ -602,6 +590,24 @@ func (f *Fetcher) normalizeBlock(blk block.Block) (*NormalizedBlock, error) {
 	return normalized, nil
 }
 
+// logDebugBlock logs a block and its txs before normalization if its height is a --debug-blocks height
+func (f *Fetcher) logDebugBlock(blk block.Block, blockTime time.Time) {
+	if !logging.DebugBlock(blk.Height()) {
+		return
+	}
+	f.logger.Info("Normalizing debug block",
+		"block", blk.Height(),
+		"block_id", blk.ID(),
+		"parent_id", blk.Parent(),
+		"type", fmt.Sprintf("%T", blk),
+		"timestamp", blockTime,
+		"apricot", blockTime.IsZero(),
+		"txs", len(blk.Txs()))
+	for i, tx := range blk.Txs() {
+		f.logger.Info("Debug block tx", "block", blk.Height(), "index", i, "tx", tx.ID(), "type", TxTypeString(tx), "unsigned_type", fmt.Sprintf("%T", tx.Unsigned))
+	}
+}
+
 // normalizeTx normalizes a transaction into storage format
 func (f *Fetcher) normalizeTx(tx *txs.Tx, blockHeight uint64, blockTime time.Time) (*NormalizedTx, error) {
 	if tx == nil || tx.Unsigned == nil {
@@ -746,22 +752,17 @@ func (f *Fetcher) normalizeBlockToJSON(blk block.Block) (*JSONBlock, error) {
 		return nil, fmt.Errorf("failed to extract timestamp: %w", err)
 	}
 	blockTime := extractor.timestamp
+	f.logDebugBlock(blk, blockTime)
 
-	// Estimate timestamp for old Apricot blocks
-	// Note: Apricot blocks use AdvanceTimeTx for timestamps, which requires complex tracking
-	// For simplicity, we estimate based on block height (~2 sec/block average)
-	if blockTime.IsZero() && blk.Height() > 0 {
-		mainnetLaunch := time.Date(2020, 9, 21, 0, 0, 0, 0, time.UTC)
-		estimatedSeconds := int64(blk.Height()) * 2
-		blockTime = mainnetLaunch.Add(time.Duration(estimatedSeconds) * time.Second)
-	}
-
+	// Apricot blocks (pre-Banff) have no timestamp, FetchBlockRangeJSON resolves them from chain time
 	jsonBlock := &JSONBlock{
 		BlockID:      blk.ID(),
 		Height:       blk.Height(),
 		ParentID:     blk.Parent(),
 		Timestamp:    blockTime,
+		BlockType:    BlockTypeString(blk),
 		Transactions: make([]JSONTx, 0, len(blk.Txs())),
+		timeInfo:     getBlockTimeInfo(blk),
 	}
 
 	// Parse each transaction
@@ -794,6 +795,12 @@ func (f *Fetcher) normalizeTxToJSON(tx *txs.Tx, blockHeight uint64, blockTime ti
 		BlockHeight: blockHeight,
 		BlockTime:   blockTime,
 		TxData:      txDataJSON,
+		Memo:        TxMemo(tx.Unsigned),
+	}
+	if f.typedTxs {
+		if jsonTx.Fields, err = f.normalizeTx(tx, blockHeight, blockTime); err != nil {
+			return nil, err
+		}
 	}
 
 	return jsonTx, nil
@@ -801,6 +808,68 @@ func (f *Fetcher) normalizeTxToJSON(tx *txs.Tx, blockHeight uint64, blockTime ti
 
 // FetchBlockRangeJSON fetches a range of blocks and returns them as JSON blocks
 func (f *Fetcher) FetchBlockRangeJSON(from, to int64) ([]*JSONBlock, error) {
+	ctx, span := tracer.Start(context.Background(), "pchainrpc.FetchBlockRangeJSON", trace.WithAttributes(tracing.Range(f.chainID, from, to)...))
+	defer span.End()
+
+	blocks, err := f.fetchBlockRangeJSON(ctx, from, to)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	if err := f.applyChainTimeJSON(blocks); err != nil {
+		tracing.RecordError(span, err)
+		return nil, fmt.Errorf("failed to resolve block timestamps: %w", err)
+	}
+	return blocks, nil
+}
+
+// StreamBlockRangeJSON fetches the JSON blocks in [from, to] chunkSize blocks at a time and hands
+// each chunk [chunkFrom, chunkTo] to send in order, so a large range is never held in memory at
+// once. The next chunk is fetched while send runs, so RPC keeps working while the writer inserts.
+// Stops at the first error of a fetch or of send, the chunks before it have been sent.
+func (f *Fetcher) StreamBlockRangeJSON(from, to int64, chunkSize int, send func(chunkFrom, chunkTo int64, blocks []*JSONBlock) error) error {
+	if chunkSize <= 0 {
+		return fmt.Errorf("chunk size must be positive")
+	}
+	type chunk struct {
+		blocks []*JSONBlock
+		err    error
+	}
+	prefetch := func(chunkFrom int64) chan chunk {
+		ch := make(chan chunk, 1)
+		go func() {
+			blocks, err := f.FetchBlockRangeJSON(chunkFrom, min(chunkFrom+int64(chunkSize)-1, to))
+			ch <- chunk{blocks, err}
+		}()
+		return ch
+	}
+
+	next := prefetch(from)
+	// A prefetch left running on error must finish before the fetcher can be closed
+	defer func() {
+		if next != nil {
+			<-next
+		}
+	}()
+	for chunkFrom := from; chunkFrom <= to; chunkFrom += int64(chunkSize) {
+		chunkTo := min(chunkFrom+int64(chunkSize)-1, to)
+		fetched := <-next
+		next = nil
+		if fetched.err != nil {
+			return fetched.err
+		}
+		if chunkTo < to {
+			next = prefetch(chunkTo + 1)
+		}
+		if err := send(chunkFrom, chunkTo, fetched.blocks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchBlockRangeJSON fetches JSON blocks in [from, to] without resolving Apricot timestamps
+func (f *Fetcher) fetchBlockRangeJSON(ctx context.Context, from, to int64) ([]*JSONBlock, error) {
 	if from > to {
 		return nil, fmt.Errorf("invalid range: from (%d) > to (%d)", from, to)
 	}
@@ -812,7 +881,7 @@ func (f *Fetcher) FetchBlockRangeJSON(from, to int64) ([]*JSONBlock, error) {
 
 	// Try to get blocks from cache first
 	if f.cache != nil {
-		cachedBlocks, missingBlocks := f.getCachedJSONBlocks(from, to)
+		cachedBlocks, missingBlocks := f.getCachedJSONBlocks(ctx, from, to)
 
 		// If we have all blocks cached, return them
 		if len(missingBlocks) == 0 {
@@ -824,7 +893,7 @@ func (f *Fetcher) FetchBlockRangeJSON(from, to int64) ([]*JSONBlock, error) {
 		}
 
 		// Fetch missing blocks
-		fetchedBlocks, err := f.fetchAndCacheMissingJSONBlocks(missingBlocks)
+		fetchedBlocks, err := f.fetchAndCacheMissingJSONBlocks(ctx, missingBlocks)
 		if err != nil {
 			return nil, err
 		}
@@ -843,50 +912,25 @@ func (f *Fetcher) FetchBlockRangeJSON(from, to int64) ([]*JSONBlock, error) {
 	}
 
 	// No cache - fetch all blocks
-	blocks := make([]*JSONBlock, numBlocks)
-
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-	var fetchErr error
-
-	// Fetch blocks concurrently
-	for i := int64(0); i < int64(numBlocks); i++ {
-		wg.Add(1)
-		go func(idx int64) {
-			defer wg.Done()
-
-			blockHeight := from + idx
-
-			f.rpcLimit <- struct{}{}
-			defer func() { <-f.rpcLimit }()
-
-			block, err := f.fetchSingleJSONBlock(blockHeight)
-			if err != nil {
-				mu.Lock()
-				if fetchErr == nil {
-					fetchErr = fmt.Errorf("failed to fetch block %d: %w", blockHeight, err)
-				}
-				mu.Unlock()
-				return
-			}
-
-			mu.Lock()
-			blocks[idx] = block
-			mu.Unlock()
-		}(i)
+	heights := make([]int64, 0, numBlocks)
+	for height := from; height <= to; height++ {
+		heights = append(heights, height)
 	}
 
-	wg.Wait()
-
-	if fetchErr != nil {
-		return nil, fetchErr
+	fetched, err := f.fetchAndCacheMissingJSONBlocks(ctx, heights)
+	if err != nil {
+		return nil, err
 	}
 
+	blocks := make([]*JSONBlock, numBlocks)
+	for i, height := range heights {
+		blocks[i] = fetched[height]
+	}
 	return blocks, nil
 }
 
 // getCachedJSONBlocks attempts to get blocks from cache, returning cached blocks and list of missing block numbers
-func (f *Fetcher) getCachedJSONBlocks(from, to int64) (map[int64]*JSONBlock, []int64) {
+func (f *Fetcher) getCachedJSONBlocks(ctx context.Context, from, to int64) (map[int64]*JSONBlock, []int64) {
 	cached := make(map[int64]*JSONBlock)
 	var missing []int64
 
@@ -900,15 +944,12 @@ func (f *Fetcher) getCachedJSONBlocks(from, to int64) (map[int64]*JSONBlock, []i
 		return cached, missing
 	}
 
-	// Process cached blocks and identify missing ones
+	// Parse cached blocks on the parse pool and identify missing ones
+	parsed, _ := parseBlocks(cachedData, func(blockBytes []byte) (*JSONBlock, error) {
+		return f.parseAndNormalizeToJSON(ctx, blockBytes)
+	})
 	for height := from; height <= to; height++ {
-		if rawBytes, ok := cachedData[height]; ok && rawBytes != nil {
-			// Parse and convert to JSON
-			jsonBlock, err := f.parseAndNormalizeToJSON(rawBytes)
-			if err != nil {
-				missing = append(missing, height)
-				continue
-			}
+		if jsonBlock, ok := parsed[height]; ok && jsonBlock != nil {
 			cached[height] = jsonBlock
 		} else {
 			missing = append(missing, height)
@@ -919,119 +960,58 @@ func (f *Fetcher) getCachedJSONBlocks(from, to int64) (map[int64]*JSONBlock, []i
 }
 
 // fetchAndCacheMissingJSONBlocks fetches missing blocks, caches raw bytes, and returns JSON blocks
-func (f *Fetcher) fetchAndCacheMissingJSONBlocks(missingBlocks []int64) (map[int64]*JSONBlock, error) {
+func (f *Fetcher) fetchAndCacheMissingJSONBlocks(ctx context.Context, missingBlocks []int64) (map[int64]*JSONBlock, error) {
 	if len(missingBlocks) == 0 {
 		return make(map[int64]*JSONBlock), nil
 	}
 
-	result := make(map[int64]*JSONBlock)
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-	var fetchErr error
-
-	for _, blockNum := range missingBlocks {
-		wg.Add(1)
-		go func(height int64) {
-			defer wg.Done()
-
-			f.rpcLimit <- struct{}{}
-			defer func() { <-f.rpcLimit }()
-
-			// Fetch raw block bytes
-			blockBytes, err := f.client.GetBlockByHeight(context.Background(), uint64(height))
-			if err != nil {
-				mu.Lock()
-				if fetchErr == nil {
-					fetchErr = fmt.Errorf("GetBlockByHeight failed for block %d: %w", height, err)
-				}
-				mu.Unlock()
-				return
-			}
-
-			// Cache raw bytes immediately
-			if f.cache != nil {
-				_, _ = f.cache.GetCompleteBlock(height, func() ([]byte, error) {
-					return blockBytes, nil
-				})
-			}
-
-			// Parse and normalize to JSON
-			jsonBlock, err := f.parseAndNormalizeToJSON(blockBytes)
-			if err != nil {
-				mu.Lock()
-				if fetchErr == nil {
-					fetchErr = fmt.Errorf("parseAndNormalizeToJSON failed for block %d: %w", height, err)
-				}
-				mu.Unlock()
-				return
-			}
-
-			mu.Lock()
-			result[height] = jsonBlock
-			mu.Unlock()
-		}(blockNum)
+	blockBytes, err := f.fetchBlockBytes(ctx, missingBlocks)
+	if err != nil {
+		return nil, err
 	}
 
-	wg.Wait()
-
-	if fetchErr != nil {
-		return nil, fetchErr
+	result, failed := parseBlocks(blockBytes, func(blockBytes []byte) (*JSONBlock, error) {
+		return f.parseAndNormalizeToJSON(ctx, blockBytes)
+	})
+	for height, err := range failed {
+		return nil, fmt.Errorf("parseAndNormalizeToJSON failed for block %d: %w", height, err)
 	}
-
 	return result, nil
 }
 
-// parseAndNormalizeToJSON parses raw block bytes and normalizes to JSON format
-func (f *Fetcher) parseAndNormalizeToJSON(blockBytes []byte) (*JSONBlock, error) {
-	blk, err := block.Parse(block.Codec, blockBytes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse block: %w", err)
-	}
-	return f.normalizeBlockToJSON(blk)
-}
-
-// fetchSingleJSONBlock fetches a single block by height with retry logic and returns JSON format
-func (f *Fetcher) fetchSingleJSONBlock(height int64) (*JSONBlock, error) {
-	var lastErr error
-
-	for attempt := 0; attempt <= f.maxRetries; attempt++ {
-		if attempt > 0 {
-			delay := f.retryDelay * time.Duration(1<<uint(attempt-1))
-			if delay > 10*time.Second {
-				delay = 10 * time.Second
-			}
-			time.Sleep(delay)
-		}
+// parseAndNormalizeToJSON parses raw block bytes and normalizes to JSON format on the parse pool
+func (f *Fetcher) parseAndNormalizeToJSON(ctx context.Context, blockBytes []byte) (jsonBlock *JSONBlock, err error) {
+	f.parsePool.run(func() {
+		// The span starts on the worker so it measures parsing, not waiting for a worker
+		_, span := tracer.Start(ctx, "pchainrpc.parseBlock", trace.WithAttributes(attribute.Int("block.bytes", len(blockBytes))))
+		defer span.End()
 
-		// Fetch block bytes
-		blockBytes, err := f.client.GetBlockByHeight(context.Background(), uint64(height))
+		var blk block.Block
+		blk, err = block.Parse(block.Codec, blockBytes)
 		if err != nil {
-			lastErr = fmt.Errorf("GetBlockByHeight failed: %w", err)
-			continue
+			err = fmt.Errorf("failed to parse block: %w", err)
+			tracing.RecordError(span, err)
+			return
 		}
-
-		// Cache raw bytes immediately if cache is enabled
-		if f.cache != nil {
-			_, _ = f.cache.GetCompleteBlock(height, func() ([]byte, error) {
-				return blockBytes, nil
-			})
+		span.SetAttributes(attribute.Int64("block.height", int64(blk.Height())))
+		jsonBlock, err = f.normalizeBlockToJSON(blk)
+		if err == nil {
+			jsonBlock.raw = blockBytes
 		}
-
-		// Parse and normalize to JSON
-		jsonBlock, err := f.parseAndNormalizeToJSON(blockBytes)
-		if err != nil {
-			lastErr = fmt.Errorf("parseAndNormalizeToJSON failed: %w", err)
-			continue
-		}
-
-		return jsonBlock, nil
-	}
-
-	return nil, fmt.Errorf("failed to fetch block %d after %d retries: %w", height, f.maxRetries, lastErr)
+		tracing.RecordError(span, err)
+	})
+	return jsonBlock, err
 }
 
 // GetCurrentValidators fetches current validators for a given subnet with retry logic
 func (f *Fetcher) GetCurrentValidators(ctx context.Context, subnetID string) (*GetCurrentValidatorsResponse, error) {
+	if data := f.validatorsCache.Get(subnetID); data != nil {
+		var response GetCurrentValidatorsResponse
+		if err := json.Unmarshal(data, &response); err == nil {
+			return &response, nil
+		}
+	}
+
 	params := map[string]interface{}{
 		"subnetID": subnetID,
 	}
@@ -1043,8 +1023,8 @@ func (f *Fetcher) GetCurrentValidators(ctx context.Context, subnetID string) (*G
 			if delay > 10*time.Second {
 				delay = 10 * time.Second
 			}
-			log.Printf("WARNING: GetCurrentValidators failed for subnet %s: %v. Retrying (attempt %d/%d) after %v",
-				subnetID, lastErr, attempt, f.maxRetries, delay)
+			f.logger.Warn("GetCurrentValidators failed, retrying",
+				"subnet_id", subnetID, "error", lastErr, "attempt", attempt, "max_retries", f.maxRetries, "delay", delay)
 			time.Sleep(delay)
 		}
 
@@ -1056,15 +1036,59 @@ func (f *Fetcher) GetCurrentValidators(ctx context.Context, subnetID string) (*G
 			&response,
 		)
 		if err != nil {
+			if rpcerr.IsPermanent(err) {
+				return nil, fmt.Errorf("failed to get current validators for subnet %s: %w", subnetID, err)
+			}
 			lastErr = err
 			continue
 		}
+		if data, err := json.Marshal(response); err == nil {
+			f.validatorsCache.Set(subnetID, data)
+		}
 		return &response, nil
 	}
 
 	return nil, fmt.Errorf("failed to get current validators for subnet %s after %d retries: %w", subnetID, f.maxRetries, lastErr)
 }
 
+// GetValidatorsAt fetches the validator set of a subnet at a historical P-Chain height with retry logic
+func (f *Fetcher) GetValidatorsAt(ctx context.Context, height uint64, subnetID ids.ID) ([]ValidatorAtHeight, error) {
+	var lastErr error
+	for attempt := 0; attempt <= f.maxRetries; attempt++ {
+		if attempt > 0 {
+			delay := f.retryDelay * time.Duration(1<<uint(attempt-1))
+			if delay > 10*time.Second {
+				delay = 10 * time.Second
+			}
+			time.Sleep(delay)
+		}
+
+		vdrs, err := f.client.GetValidatorsAt(ctx, subnetID, platformapi.Height(height))
+		if err != nil {
+			if rpcerr.IsPermanent(err) {
+				return nil, fmt.Errorf("failed to get validators at height %d for subnet %s: %w", height, subnetID, err)
+			}
+			lastErr = err
+			continue
+		}
+
+		result := make([]ValidatorAtHeight, 0, len(vdrs))
+		for nodeID, vdr := range vdrs {
+			v := ValidatorAtHeight{
+				NodeID: nodeID.String(),
+				Weight: vdr.Weight,
+			}
+			if vdr.PublicKey != nil {
+				v.PublicKey = "0x" + hex.EncodeToString(bls.PublicKeyToCompressedBytes(vdr.PublicKey))
+			}
+			result = append(result, v)
+		}
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("failed to get validators at height %d for subnet %s after %d retries: %w", height, subnetID, f.maxRetries, lastErr)
+}
+
 // ParseValidatorInfo converts RPC ValidatorInfo to normalized ValidatorState
 func ParseValidatorInfo(info ValidatorInfo, subnetID ids.ID) (*ValidatorState, error) {
 	// Parse NodeID - handle both CB58 and hex formats
@@ -1086,7 +1110,7 @@ func ParseValidatorInfo(info ValidatorInfo, subnetID ids.ID) (*ValidatorState, e
 			return nil, fmt.Errorf("invalid hex node ID length %d (expected 20): %s", len(nodeBytes), nodeIDStr)
 		}
 		copy(nodeID[:], nodeBytes)
-		log.Printf("DEBUG: Converted hex NodeID %s to CB58 %s", nodeIDStr, nodeID.String())
+		slog.Debug("Converted hex NodeID to CB58", "hex", nodeIDStr, "node_id", nodeID.String())
 	} else if strings.HasPrefix(nodeIDStr, "NodeID-") {
 		// Check if the suffix looks like hex (40 hex chars = 20 bytes)
 		suffix := strings.TrimPrefix(nodeIDStr, "NodeID-")
@@ -1095,7 +1119,7 @@ func ParseValidatorInfo(info ValidatorInfo, subnetID ids.ID) (*ValidatorState, e
 			nodeBytes, hexErr := hex.DecodeString(suffix)
 			if hexErr == nil && len(nodeBytes) == 20 {
 				copy(nodeID[:], nodeBytes)
-				log.Printf("DEBUG: Converted hex NodeID %s to CB58 %s", nodeIDStr, nodeID.String())
+				slog.Debug("Converted hex NodeID to CB58", "hex", nodeIDStr, "node_id", nodeID.String())
 			} else {
 				// Fall back to standard parsing
 				nodeID, err = ids.NodeIDFromString(nodeIDStr)
@@ -1237,8 +1261,7 @@ func isHexString(s string) bool {
 
 // Close stops all background goroutines and cleans up resources
 func (f *Fetcher) Close() {
-	// P-Chain fetcher doesn't have background goroutines currently,
-	// but this method is provided for consistency and future-proofing
+	f.parsePool.close()
 }
 
 // GetUTXOsResponse represents the response from platform.getUTXOs
@@ -1278,6 +1301,9 @@ func (f *Fetcher) GetUTXOs(ctx context.Context, addresses []string) (*GetUTXOsRe
 			&response,
 		)
 		if err != nil {
+			if rpcerr.IsPermanent(err) {
+				return nil, fmt.Errorf("failed to get UTXOs: %w", err)
+			}
 			lastErr = err
 			continue
 		}
@@ -1357,11 +1383,11 @@ func (f *Fetcher) FindRefundUTXO(ctx context.Context, addresses []string, target
 
 // GetL1ValidatorResponse represents the response from platform.getL1Validator
 type GetL1ValidatorResponse struct {
-	NodeID               string `json:"nodeID"`
-	Weight               string `json:"weight"`
-	StartTime            string `json:"startTime"`
-	ValidationID         string `json:"validationID"`
-	PublicKey            string `json:"publicKey"`
+	NodeID                string `json:"nodeID"`
+	Weight                string `json:"weight"`
+	StartTime             string `json:"startTime"`
+	ValidationID          string `json:"validationID"`
+	PublicKey             string `json:"publicKey"`
 	RemainingBalanceOwner struct {
 		Locktime  string   `json:"locktime"`
 		Threshold string   `json:"threshold"`
@@ -1372,14 +1398,21 @@ type GetL1ValidatorResponse struct {
 		Threshold string   `json:"threshold"`
 		Addresses []string `json:"addresses"`
 	} `json:"deactivationOwner"`
-	MinNonce  string `json:"minNonce"`
-	Balance   string `json:"balance"`
-	SubnetID  string `json:"subnetID"`
-	Height    string `json:"height"`
+	MinNonce string `json:"minNonce"`
+	Balance  string `json:"balance"`
+	SubnetID string `json:"subnetID"`
+	Height   string `json:"height"`
 }
 
 // GetL1Validator fetches L1 validator info including remainingBalanceOwner
 func (f *Fetcher) GetL1Validator(ctx context.Context, validationID string) (*GetL1ValidatorResponse, error) {
+	if data := f.l1ValidatorsCache.Get(validationID); data != nil {
+		var response GetL1ValidatorResponse
+		if err := json.Unmarshal(data, &response); err == nil {
+			return &response, nil
+		}
+	}
+
 	params := map[string]interface{}{
 		"validationID": validationID,
 	}
@@ -1405,8 +1438,102 @@ func (f *Fetcher) GetL1Validator(ctx context.Context, validationID string) (*Get
 			lastErr = err
 			continue
 		}
+		if data, err := json.Marshal(response); err == nil {
+			f.l1ValidatorsCache.Set(validationID, data)
+		}
 		return &response, nil
 	}
 
 	return nil, fmt.Errorf("failed to get L1 validator %s after %d retries: %w", validationID, f.maxRetries, lastErr)
 }
+
+// GetRewardUTXOs fetches the reward UTXOs paid out for a staker tx (AddValidator, AddDelegator, ...)
+func (f *Fetcher) GetRewardUTXOs(ctx context.Context, stakerTxID string) (*GetUTXOsResponse, error) {
+	params := map[string]interface{}{
+		"txID":     stakerTxID,
+		"encoding": "hex",
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= f.maxRetries; attempt++ {
+		if attempt > 0 {
+			delay := f.retryDelay * time.Duration(1<<uint(attempt-1))
+			if delay > 10*time.Second {
+				delay = 10 * time.Second
+			}
+			time.Sleep(delay)
+		}
+
+		var response GetUTXOsResponse
+		err := f.client.Requester.SendRequest(
+			ctx,
+			"platform.getRewardUTXOs",
+			params,
+			&response,
+		)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return &response, nil
+	}
+
+	return nil, fmt.Errorf("failed to get reward UTXOs for %s after %d retries: %w", stakerTxID, f.maxRetries, lastErr)
+}
+
+// RewardUTXO represents a decoded reward UTXO with its amount and recipients
+type RewardUTXO struct {
+	UTXOID            string
+	TxID              string
+	OutputIndex       uint32
+	AssetID           string
+	Amount            uint64
+	Addresses         []string // Recipient addresses (CB58 short IDs)
+	Threshold         uint32
+	Locktime          uint64 // Spend locktime of the inner secp256k1 output
+	StakeableLocktime uint64 // Stakeable lock locktime, 0 if the output is not stakeable-locked
+}
+
+// ParseRewardUTXOHex decodes a hex-encoded reward UTXO as returned by platform.getRewardUTXOs
+func ParseRewardUTXOHex(utxoHex string) (*RewardUTXO, error) {
+	// The API encodes with a trailing checksum that formatting.Decode verifies and strips
+	utxoBytes, err := formatting.Decode(formatting.Hex, utxoHex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode UTXO hex: %w", err)
+	}
+
+	var utxo avax.UTXO
+	if _, err := txs.GenesisCodec.Unmarshal(utxoBytes, &utxo); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal UTXO: %w", err)
+	}
+
+	out := utxo.Out
+	var stakeableLocktime uint64
+	// Rewards for locked stake are wrapped in a stakeable lock
+	if lockOut, ok := out.(*stakeable.LockOut); ok {
+		stakeableLocktime = lockOut.Locktime
+		out = lockOut.TransferableOut
+	}
+
+	transferOut, ok := out.(*secp256k1fx.TransferOutput)
+	if !ok {
+		return nil, fmt.Errorf("unexpected reward output type %T", out)
+	}
+
+	addresses := make([]string, len(transferOut.Addrs))
+	for i, addr := range transferOut.Addrs {
+		addresses[i] = addr.String()
+	}
+
+	return &RewardUTXO{
+		UTXOID:            utxo.InputID().String(),
+		TxID:              utxo.TxID.String(),
+		OutputIndex:       utxo.OutputIndex,
+		AssetID:           utxo.AssetID().String(),
+		Amount:            transferOut.Amt,
+		Addresses:         addresses,
+		Threshold:         transferOut.Threshold,
+		Locktime:          transferOut.Locktime,
+		StakeableLocktime: stakeableLocktime,
+	}, nil
+}
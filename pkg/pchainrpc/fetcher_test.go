@@ -0,0 +1,58 @@
+package pchainrpc
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/stretchr/testify/require"
+)
+
+// Example response payload from the platform.getRewardUTXOs API docs
+var rewardUTXOsPayload = []string{
+	"0x0000a195046108a85e60f7a864bb567745a37f50c6af282103e47cc62f036cee404700000000345aa98e8a990f4101e2268fab4c4e1f731c8dfbcffa3a77978686e6390d624f000000070000000000000001000000000000000000000001000000018ba98dabaebcd83056799841cfbc567d8b10f216c1f01765",
+	"0x0000ae8b1b94444eed8de9a81b1222f00f1b4133330add23d8ac288bffa98b85271100000000345aa98e8a990f4101e2268fab4c4e1f731c8dfbcffa3a77978686e6390d624f000000070000000000000001000000000000000000000001000000018ba98dabaebcd83056799841cfbc567d8b10f216473d042a",
+}
+
+func mustID(t *testing.T, hexStr string) ids.ID {
+	b, err := hex.DecodeString(hexStr)
+	require.NoError(t, err)
+	id, err := ids.ToID(b)
+	require.NoError(t, err)
+	return id
+}
+
+func TestParseRewardUTXOHex(t *testing.T) {
+	assetID := mustID(t, "345aa98e8a990f4101e2268fab4c4e1f731c8dfbcffa3a77978686e6390d624f")
+	addrBytes, err := hex.DecodeString("8ba98dabaebcd83056799841cfbc567d8b10f216")
+	require.NoError(t, err)
+	addr, err := ids.ToShortID(addrBytes)
+	require.NoError(t, err)
+
+	txIDs := []ids.ID{
+		mustID(t, "a195046108a85e60f7a864bb567745a37f50c6af282103e47cc62f036cee4047"),
+		mustID(t, "ae8b1b94444eed8de9a81b1222f00f1b4133330add23d8ac288bffa98b852711"),
+	}
+
+	for i, utxoHex := range rewardUTXOsPayload {
+		parsed, err := ParseRewardUTXOHex(utxoHex)
+		require.NoError(t, err)
+
+		require.Equal(t, txIDs[i].String(), parsed.TxID)
+		require.Equal(t, uint32(0), parsed.OutputIndex)
+		require.Equal(t, txIDs[i].Prefix(0).String(), parsed.UTXOID)
+		require.Equal(t, assetID.String(), parsed.AssetID)
+		require.Equal(t, uint64(1), parsed.Amount)
+		require.Equal(t, []string{addr.String()}, parsed.Addresses)
+		require.Equal(t, uint32(1), parsed.Threshold)
+		require.Equal(t, uint64(0), parsed.Locktime)
+		require.Equal(t, uint64(0), parsed.StakeableLocktime)
+	}
+}
+
+func TestParseRewardUTXOHexBadChecksum(t *testing.T) {
+	// Flip the last checksum byte
+	bad := rewardUTXOsPayload[0][:len(rewardUTXOsPayload[0])-2] + "00"
+	_, err := ParseRewardUTXOHex(bad)
+	require.Error(t, err)
+}
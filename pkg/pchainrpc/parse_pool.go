@@ -0,0 +1,86 @@
+package pchainrpc
+
+import (
+	"log/slog"
+	"runtime"
+	"sync"
+)
+
+// parsePool runs CPU-bound block parsing and normalization on a fixed set of workers, so parse
+// concurrency is bounded by CPUs rather than by the RPC concurrency limit
+type parsePool struct {
+	jobs      chan func()
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// newParsePool starts workers, each optionally pinned to its own CPU
+func newParsePool(workers int, pin bool, logger *slog.Logger) *parsePool {
+	p := &parsePool{jobs: make(chan func())}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go func(worker int) {
+			defer p.wg.Done()
+			if pin {
+				// The thread is never unlocked, so it exits with the worker and the affinity goes with it
+				runtime.LockOSThread()
+				if err := pinToCPU(worker); err != nil {
+					logger.Warn("Failed to pin parse worker", "worker", worker, "error", err)
+				}
+			}
+			for job := range p.jobs {
+				job()
+			}
+		}(i)
+	}
+	return p
+}
+
+// run executes fn on a worker and waits for it to finish
+func (p *parsePool) run(fn func()) {
+	done := make(chan struct{})
+	p.jobs <- func() {
+		defer close(done)
+		fn()
+	}
+	<-done
+}
+
+// close stops the workers once in-flight jobs finish
+func (p *parsePool) close() {
+	p.closeOnce.Do(func() {
+		close(p.jobs)
+		p.wg.Wait()
+	})
+}
+
+// parseBlocks parses raw blocks concurrently, returning the parsed blocks and the errors of those
+// that failed, both keyed by height
+func parseBlocks[T any](raw map[int64][]byte, parse func([]byte) (T, error)) (map[int64]T, map[int64]error) {
+	parsed := make(map[int64]T, len(raw))
+	failed := make(map[int64]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for height, blockBytes := range raw {
+		if blockBytes == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(height int64, blockBytes []byte) {
+			defer wg.Done()
+			blk, err := parse(blockBytes)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed[height] = err
+				return
+			}
+			parsed[height] = blk
+		}(height, blockBytes)
+	}
+
+	wg.Wait()
+	return parsed, failed
+}
@@ -0,0 +1,44 @@
+//go:build linux
+
+package pchainrpc
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxCPUs is the number of CPUs a unix.CPUSet can hold
+const maxCPUs = 1024
+
+// pinToCPU restricts the calling OS thread to the n-th CPU (modulo their count) the process is
+// allowed to run on
+func pinToCPU(n int) error {
+	var allowed unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &allowed); err != nil {
+		return fmt.Errorf("failed to get CPU affinity: %w", err)
+	}
+	count := allowed.Count()
+	if count == 0 {
+		return nil
+	}
+
+	n %= count
+	for cpu := 0; cpu < maxCPUs; cpu++ {
+		if !allowed.IsSet(cpu) {
+			continue
+		}
+		if n > 0 {
+			n--
+			continue
+		}
+
+		var set unix.CPUSet
+		set.Set(cpu)
+		if err := unix.SchedSetaffinity(0, &set); err != nil {
+			return fmt.Errorf("failed to set CPU affinity to %d: %w", cpu, err)
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package pchainrpc
+
+// pinToCPU is a no-op where thread CPU affinity isn't supported; the worker stays locked to its
+// OS thread only
+func pinToCPU(n int) error {
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/vms/platformvm/block"
 	"github.com/ava-labs/avalanchego/vms/platformvm/txs"
 )
 
@@ -16,7 +17,11 @@ type NormalizedBlock struct {
 	Height       uint64
 	ParentID     ids.ID
 	Timestamp    time.Time
+	BlockType    string
+	Proposer     string // ProposerVM NodeID, set by the syncer when an index URL is configured
 	Transactions []NormalizedTx
+
+	timeInfo blockTimeInfo // Used to resolve Apricot timestamps
 }
 
 // JSONBlock represents a P-chain block with JSON-serialized transactions
@@ -25,7 +30,29 @@ type JSONBlock struct {
 	Height       uint64
 	ParentID     ids.ID
 	Timestamp    time.Time
+	BlockType    string
+	Proposer     string // ProposerVM NodeID, set by the syncer when an index URL is configured
 	Transactions []JSONTx
+
+	timeInfo blockTimeInfo // Used to resolve Apricot timestamps
+	raw      []byte        // Block bytes the block was parsed from
+}
+
+// Raw returns the block bytes the block was parsed from, as the cache stores them
+func (b *JSONBlock) Raw() []byte {
+	return b.raw
+}
+
+// MemorySize estimates the bytes a block takes in memory, its raw bytes plus the JSON of its txs
+func (b *JSONBlock) MemorySize() int64 {
+	size := int64(256 + len(b.raw))
+	for _, tx := range b.Transactions {
+		size += int64(128 + len(tx.TxData) + len(tx.Memo))
+		if tx.Fields != nil {
+			size += int64(512 + len(tx.Fields.Validators) + len(tx.Fields.Owner) + len(tx.Fields.GenesisData))
+		}
+	}
+	return size
 }
 
 // NormalizedTx represents a normalized P-chain transaction for storage
@@ -110,6 +137,9 @@ type JSONTx struct {
 	BlockHeight uint64
 	BlockTime   time.Time
 	TxData      []byte // JSON-serialized tx.Unsigned
+	Memo        []byte // BaseTx memo bytes, nil for txs without a BaseTx
+
+	Fields *NormalizedTx // Typed fields of the tx, nil unless FetcherOptions.TypedTxs is set
 }
 
 // Input represents a transaction input
@@ -151,6 +181,39 @@ func TxTypeString(tx *txs.Tx) string {
 	return typeName
 }
 
+// BlockTypeString returns the block type name without the "Block" suffix (e.g. "BanffStandard")
+func BlockTypeString(blk block.Block) string {
+	typeName := reflect.TypeOf(blk).Elem().Name()
+	return strings.TrimSuffix(typeName, "Block")
+}
+
+// PlatformBlockHeight returns the height of a serialized platformvm block
+func PlatformBlockHeight(blockBytes []byte) (uint64, error) {
+	blk, err := block.Parse(block.Codec, blockBytes)
+	if err != nil {
+		return 0, err
+	}
+	return blk.Height(), nil
+}
+
+// TxMemo returns the BaseTx memo of an unsigned tx, or nil if the tx type has no BaseTx
+func TxMemo(unsigned txs.UnsignedTx) []byte {
+	v := reflect.ValueOf(unsigned)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	// Memo is promoted from the embedded avax.BaseTx
+	memo := v.FieldByName("Memo")
+	if !memo.IsValid() || memo.Kind() != reflect.Slice || memo.Type().Elem().Kind() != reflect.Uint8 {
+		return nil
+	}
+	return memo.Bytes()
+}
+
 // ValidatorState represents the current state of a validator
 type ValidatorState struct {
 	ValidationID ids.ID
@@ -164,6 +227,13 @@ type ValidatorState struct {
 	Active       bool
 }
 
+// ValidatorAtHeight represents a validator in a historical validator set from platform.getValidatorsAt
+type ValidatorAtHeight struct {
+	NodeID    string
+	Weight    uint64
+	PublicKey string // Compressed BLS public key (hex), empty if none
+}
+
 // GetCurrentValidatorsResponse represents the response from platform.getCurrentValidators
 type GetCurrentValidatorsResponse struct {
 	Validators []ValidatorInfo `json:"validators"`
@@ -1,39 +1,98 @@
 package pchainsyncer
 
 import (
+	"context"
+	"fmt"
+	"icicle/pkg/batchsize"
 	"icicle/pkg/cache"
 	"icicle/pkg/chwrapper"
+	"icicle/pkg/firehose"
+	"icicle/pkg/loadshed"
+	"icicle/pkg/logging"
+	"icicle/pkg/membudget"
 	"icicle/pkg/pchainrpc"
-	"context"
-	"fmt"
-	"log"
+	"icicle/pkg/proposervm"
+	"icicle/pkg/tracing"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("pchainsyncer")
+
 const (
 	// BufferSize is the maximum number of batches that can be buffered in the channel
 	BufferSize = 10000
 	// FlushInterval is how often to flush blocks to ClickHouse
 	FlushInterval = 1 * time.Second
+	// ControlPollInterval is how often to check chain_control for pause/resume requests
+	ControlPollInterval = 2 * time.Second
+	// DegradedDivisor divides fetch batch size and RPC concurrency in degraded mode
+	DegradedDivisor = 4
+	// DefaultStreamBatchSize is how many blocks are handed to the writer at once
+	DefaultStreamBatchSize = 100
+	// DefaultMemoryBudget is how many bytes of fetched blocks may wait to be written
+	DefaultMemoryBudget = 1 << 30
+	// DefaultFetchBatchBytes is how many bytes of blocks a tuned fetch batch aims for
+	DefaultFetchBatchBytes = 64 << 20
 )
 
 // Config holds configuration for PChainSyncer
 type Config struct {
 	ChainID        uint32
 	RpcURL         string
-	StartBlock     int64        // Starting block number when no watermark exists
-	MaxConcurrency int          // Maximum concurrent RPC requests
-	FetchBatchSize int          // Blocks per fetch
-	CHConn         driver.Conn  // ClickHouse connection
-	Cache          *cache.Cache // Cache for RPC calls
-	Name           string       // Chain name for display
+	RpcHeaders     map[string]string // Extra HTTP headers sent with every RPC request, e.g. API keys
+	NoCompression  bool              // Don't ask for compressed RPC responses
+	StartBlock     int64             // Starting block number when no watermark exists
+	MaxConcurrency int               // Maximum concurrent RPC requests
+	FetchBatchSize int               // Blocks per fetch
+	RpcBatchSize   int               // getBlockByHeight calls per HTTP request (default: 100)
+	CHConn         driver.Conn       // ClickHouse connection
+	Cache          cache.Cache       // Cache for RPC calls
+	CacheTTLs      cache.TTLs        // Cache namespaces to use besides complete blocks
+	Name           string            // Chain name for display
+	TxBlobMinSize  int               // Compress large tx_data fields of at least this many bytes into tx_blobs (0 disables)
+	TxStorage      string            // TxStorageJSON, TxStorageTyped or TxStorageBoth (default: TxStorageJSON)
+	IndexURL       string            // Index API endpoint for block proposer attribution (empty disables)
+	StorePayloads  bool              // Also write each block's bytes to raw_payloads
+	InsertSettings map[string]string // ClickHouse settings of block inserts, e.g. async_insert
+
+	// Streaming, fetched blocks go to the writer in sub-batches
+	StreamBatchSize int   // Blocks of a fetch handed to the writer at once (default: DefaultStreamBatchSize)
+	MemoryBudget    int64 // Bytes of fetched blocks not yet written, fetching waits above it (default: DefaultMemoryBudget, negative disables)
+
+	// Block parsing
+	ParseWorkers    int  // Workers parsing and normalizing blocks (default: GOMAXPROCS)
+	PinParseWorkers bool // Pin each parse worker to its own CPU
 
 	// Validator syncer config
-	EnableValidatorSync   bool          // Enable L1 validator state syncing
-	ValidatorSyncInterval time.Duration // How often to sync validator state (default: 5min)
+	EnableValidatorSync       bool          // Enable L1 validator state syncing
+	ValidatorSyncInterval     time.Duration // How often to sync validator state (default: 5min)
+	ValidatorSnapshotInterval time.Duration // Historical validator set snapshot interval (0 disables)
+	ValidatorDiscoveryMode    string        // "auto", "manual" or "hybrid" (default: auto)
+	ValidatorSyncSubnets      []string      // L1 subnet IDs to sync validators for
+	ValidatorSyncExclude      []string      // L1 subnet IDs never to sync validators for
+	ValidatorPrioritySubnets  []string      // Subnet IDs synced every ValidatorPriorityInterval
+	ValidatorPriorityInterval time.Duration // How often to sync priority subnets' validators (default: 1min)
+	ValidatorSubnetInterval   time.Duration // How often to sync other subnets' validators (default: ValidatorSyncInterval)
+
+	// Gap healing
+	GapCheckInterval time.Duration // How often to look for and re-ingest blocks missing below the watermark (0 disables)
+
+	// Startup
+	SkipIntegrityCheck bool   // Only check blocks written after startup for gaps, and don't backfill subnet owner history
+	NetworkID          uint32 // Network ID the node must report, e.g. 1 on Mainnet and 5 on Fuji, required unless SkipChainIDCheck
+	SkipChainIDCheck   bool   // Ingest even if the node reports another network ID, or none is configured
+
+	// Adaptive batch sizing, from the size of the blocks fetched so far
+	MaxFetchBatchSize int   // Most blocks per fetch, 0 keeps FetchBatchSize fixed
+	FetchBatchBytes   int64 // Bytes of blocks a fetch aims for (default: DefaultFetchBatchBytes)
+
+	// Load shedding
+	LoadShedder *loadshed.Monitor // Resource budget monitor, nil disables load shedding
 }
 
 // PChainSyncer manages P-chain sync
@@ -43,10 +102,33 @@ type PChainSyncer struct {
 	fetcher        *pchainrpc.Fetcher
 	conn           driver.Conn
 	blockChan      chan []*pchainrpc.JSONBlock // Bounded channel for backpressure
+	pauseChan      chan chan struct{}          // Asks the writer to drop buffered blocks and go idle
 	watermark      uint64                      // Current sync position
 	startBlock     int64                       // Starting block when no watermark
 	fetchBatchSize int
 	flushInterval  time.Duration
+	txBlobMinSize  int
+	txStorage      string
+	storePayloads  bool               // Block bytes are written to raw_payloads for cache hydrate
+	insertSettings map[string]string  // ClickHouse settings of block inserts
+	proposers      *proposervm.Client // nil when proposer attribution is disabled
+	maxConcurrency int
+	logger         *slog.Logger
+	loadShedder    *loadshed.Monitor
+	degradedReason string // Current degraded mode reason, only used by the fetcher goroutine
+
+	// Streaming, only used by the fetcher goroutine except for releasing budget
+	streamBatchSize int
+	budget          *membudget.Budget // nil when unlimited
+	batchTuner      *batchsize.Tuner  // nil when batch sizes are fixed
+
+	// Gap healing, only used by the writer goroutine
+	gapCheckInterval time.Duration
+	gapsCheckedTo    uint64 // p_chain_blocks has no gaps up to this height
+	skipHistory      bool   // Gap checks start at the watermark loaded on startup, subnet owner history isn't backfilled
+
+	networkID      uint32 // Start fails if the node reports another network ID
+	checkNetworkID bool
 
 	// Validator syncer
 	validatorSyncer *ValidatorSyncer
@@ -77,15 +159,34 @@ func NewPChainSyncer(cfg Config) (*PChainSyncer, error) {
 	if cfg.Name == "" {
 		cfg.Name = fmt.Sprintf("P-Chain-%d", cfg.ChainID)
 	}
+	if cfg.StreamBatchSize == 0 {
+		cfg.StreamBatchSize = DefaultStreamBatchSize
+	}
+	if cfg.MemoryBudget == 0 {
+		cfg.MemoryBudget = DefaultMemoryBudget
+	}
+	if cfg.FetchBatchBytes == 0 {
+		cfg.FetchBatchBytes = DefaultFetchBatchBytes
+	}
 
 	// Create fetcher
 	fetcher := pchainrpc.NewFetcher(pchainrpc.FetcherOptions{
 		RpcURL:         cfg.RpcURL,
+		RpcHeaders:     cfg.RpcHeaders,
+		NoCompression:  cfg.NoCompression,
+		ChainID:        cfg.ChainID,
+		ChainName:      cfg.Name,
 		MaxConcurrency: cfg.MaxConcurrency,
 		MaxRetries:     10,
 		RetryDelay:     100 * time.Millisecond,
 		BatchSize:      cfg.FetchBatchSize,
+		RpcBatchSize:   cfg.RpcBatchSize,
 		Cache:          cfg.Cache,
+		CacheTTLs:      cfg.CacheTTLs,
+
+		ParseWorkers:    cfg.ParseWorkers,
+		PinParseWorkers: cfg.PinParseWorkers,
+		TypedTxs:        cfg.TxStorage == TxStorageTyped || cfg.TxStorage == TxStorageBoth,
 	})
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -96,22 +197,62 @@ func NewPChainSyncer(cfg Config) (*PChainSyncer, error) {
 		fetcher:        fetcher,
 		conn:           cfg.CHConn,
 		blockChan:      make(chan []*pchainrpc.JSONBlock, BufferSize),
+		pauseChan:      make(chan chan struct{}),
 		startBlock:     cfg.StartBlock,
 		fetchBatchSize: cfg.FetchBatchSize,
 		flushInterval:  FlushInterval,
-		ctx:            ctx,
-		cancel:         cancel,
-		lastPrintTime:  time.Now(),
-		startTime:      time.Now(),
+		txBlobMinSize:  cfg.TxBlobMinSize,
+		txStorage:      cfg.TxStorage,
+		storePayloads:  cfg.StorePayloads,
+		insertSettings: cfg.InsertSettings,
+		maxConcurrency: cfg.MaxConcurrency,
+		logger:         logging.Chain("pchainsyncer", cfg.ChainID, cfg.Name),
+		loadShedder:    cfg.LoadShedder,
+
+		streamBatchSize: cfg.StreamBatchSize,
+		budget:          membudget.New(cfg.MemoryBudget),
+		batchTuner:      batchsize.New(cfg.FetchBatchBytes, cfg.MaxFetchBatchSize),
+
+		gapCheckInterval: cfg.GapCheckInterval,
+		skipHistory:      cfg.SkipIntegrityCheck,
+		networkID:        cfg.NetworkID,
+		checkNetworkID:   !cfg.SkipChainIDCheck,
+		ctx:              ctx,
+		cancel:           cancel,
+		lastPrintTime:    time.Now(),
+		startTime:        time.Now(),
+	}
+
+	if cfg.IndexURL != "" {
+		ps.proposers = proposervm.NewClient(cfg.IndexURL, pchainrpc.PlatformBlockHeight)
 	}
 
 	// Create validator syncer if enabled
 	if cfg.EnableValidatorSync {
+		subnets, err := parseSubnetIDs(cfg.ValidatorSyncSubnets)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validatorSyncSubnets: %w", err)
+		}
+		excludeSubnets, err := parseSubnetIDs(cfg.ValidatorSyncExclude)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validatorSyncExcludeSubnets: %w", err)
+		}
+		prioritySubnets, err := parseSubnetIDs(cfg.ValidatorPrioritySubnets)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validatorPrioritySubnets: %w", err)
+		}
+
 		ps.validatorSyncer = NewValidatorSyncer(
 			ValidatorSyncerConfig{
-				PChainID:      cfg.ChainID,
-				SyncInterval:  cfg.ValidatorSyncInterval,
-				DiscoveryMode: "auto",
+				PChainID:         cfg.ChainID,
+				SyncInterval:     cfg.ValidatorSyncInterval,
+				DiscoveryMode:    cfg.ValidatorDiscoveryMode,
+				SnapshotInterval: cfg.ValidatorSnapshotInterval,
+				Subnets:          subnets,
+				ExcludeSubnets:   excludeSubnets,
+				PrioritySubnets:  prioritySubnets,
+				PriorityInterval: cfg.ValidatorPriorityInterval,
+				SubnetInterval:   cfg.ValidatorSubnetInterval,
 			},
 			fetcher,
 			cfg.CHConn,
@@ -123,7 +264,30 @@ func NewPChainSyncer(cfg Config) (*PChainSyncer, error) {
 
 // Start begins syncing
 func (ps *PChainSyncer) Start() error {
-	log.Printf("[Chain %d - %s] Starting syncer...", ps.chainID, ps.chainName)
+	ps.logger.Info("Starting syncer")
+	if ps.txStorage == TxStorageTyped {
+		ps.logger.Warn("tx_data is not stored, subnet, chain and L1 validator discovery read it and find nothing")
+	}
+	if err := chwrapper.CheckInsertSettings(ps.conn, ps.insertSettings); err != nil {
+		return err
+	}
+	if chwrapper.UnconfirmedAsyncInserts(ps.insertSettings) {
+		ps.logger.Warn("Async inserts aren't waited for, blocks lost in a failed flush stay missing until the gap check")
+	}
+
+	// A config pointing at another network's node would mix its blocks into this chain's tables
+	if ps.checkNetworkID {
+		if ps.networkID == 0 {
+			return fmt.Errorf("networkID is required to check the node's network: set it (1 on Mainnet, 5 on Fuji), or set skipChainIDCheck")
+		}
+		networkID, err := ps.fetcher.GetNetworkID()
+		if err != nil {
+			return fmt.Errorf("failed to get network ID: %w", err)
+		}
+		if networkID != ps.networkID {
+			return fmt.Errorf("node reports network ID %d, not %d: check rpcURL, or set skipChainIDCheck to ingest anyway", networkID, ps.networkID)
+		}
+	}
 
 	// Get starting position
 	startBlock, err := ps.getStartingBlock()
@@ -131,7 +295,11 @@ func (ps *PChainSyncer) Start() error {
 		return fmt.Errorf("failed to determine starting block: %w", err)
 	}
 
-	log.Printf("[Chain %d - %s] Starting from block %d", ps.chainID, ps.chainName, startBlock)
+	ps.logger.Info("Starting from block", "block", startBlock)
+	if ps.skipHistory {
+		ps.gapsCheckedTo = ps.watermark
+		ps.logger.Info("Skipping gap checks below the watermark", "watermark", ps.watermark)
+	}
 
 	// Get latest block from RPC
 	latestBlock, err := ps.fetcher.GetLatestBlock()
@@ -139,10 +307,10 @@ func (ps *PChainSyncer) Start() error {
 		return fmt.Errorf("failed to get latest block: %w", err)
 	}
 
-	log.Printf("[Chain %d - %s] Latest block on chain: %d", ps.chainID, ps.chainName, latestBlock)
+	ps.logger.Info("Latest block on chain", "block", latestBlock)
 
 	// Initialize chain status in database
-	if err := chwrapper.UpsertChainStatus(ps.conn, ps.chainID, ps.chainName, uint64(latestBlock)); err != nil {
+	if err := chwrapper.UpsertChainStatus(ps.conn, ps.chainID, ps.chainName, uint64(latestBlock), ""); err != nil {
 		return fmt.Errorf("failed to upsert chain status: %w", err)
 	}
 
@@ -170,9 +338,21 @@ func (ps *PChainSyncer) Start() error {
 	return nil
 }
 
+// backfillSubnetOwners records the subnet owner versions of p_chain_txs missing from dimension_history,
+// for txs ingested before it existed
+func (ps *PChainSyncer) backfillSubnetOwners() {
+	if versions, err := DiscoverSubnetOwnerVersions(ps.ctx, ps.conn, ps.chainID); err != nil {
+		ps.logger.Warn("Failed to discover subnet owner versions", "error", err)
+	} else if n, err := chwrapper.RecordDimensionVersions(ps.conn, ps.chainID, chwrapper.DimensionSubnetOwner, versions); err != nil {
+		ps.logger.Warn("Failed to backfill subnet owner versions", "error", err)
+	} else if n > 0 {
+		ps.logger.Info("Backfilled subnet owner versions", "versions", n)
+	}
+}
+
 // Stop gracefully shuts down the syncer
 func (ps *PChainSyncer) Stop() {
-	log.Printf("[Chain %d - %s] Stopping syncer...", ps.chainID, ps.chainName)
+	ps.logger.Info("Stopping syncer")
 
 	// Stop validator syncer first
 	if ps.validatorSyncer != nil {
@@ -182,7 +362,8 @@ func (ps *PChainSyncer) Stop() {
 	ps.cancel()
 	close(ps.blockChan)
 	ps.wg.Wait()
-	log.Printf("[Chain %d - %s] Syncer stopped", ps.chainID, ps.chainName)
+	ps.fetcher.Close()
+	ps.logger.Info("Syncer stopped")
 }
 
 // Wait blocks until syncer completes
@@ -213,12 +394,29 @@ func (ps *PChainSyncer) fetcherLoop(startBlock, latestBlock int64) {
 	defer ps.wg.Done()
 
 	currentBlock := startBlock
+	var lastControlCheck time.Time
 
 	for {
 		select {
 		case <-ps.ctx.Done():
 			return
 		default:
+			// Check for pause requests, e.g. during RPC maintenance
+			if time.Since(lastControlCheck) >= ControlPollInterval {
+				lastControlCheck = time.Now()
+				ctrl, err := chwrapper.GetChainControl(ps.conn, ps.chainID)
+				if err != nil {
+					ps.logger.Error("Error checking chain control", "error", err)
+				} else if ctrl.Paused {
+					resumeBlock, ok := ps.pauseUntilResumed(ctrl.Version)
+					if !ok {
+						return
+					}
+					currentBlock = resumeBlock
+					continue
+				}
+			}
+
 			// Check if we're caught up
 			if currentBlock > latestBlock {
 				// Poll for new blocks
@@ -226,13 +424,13 @@ func (ps *PChainSyncer) fetcherLoop(startBlock, latestBlock int64) {
 
 				newLatest, err := ps.fetcher.GetLatestBlock()
 				if err != nil {
-					log.Printf("[Chain %d - %s] Error getting latest block: %v", ps.chainID, ps.chainName, err)
+					ps.logger.Error("Error getting latest block", "error", err)
 					continue
 				}
 
 				// Update chain status with latest block from RPC
-				if err := chwrapper.UpdateLatestBlock(ps.conn, ps.chainID, ps.chainName, uint64(newLatest)); err != nil {
-					log.Printf("[Chain %d - %s] Error updating chain status: %v", ps.chainID, ps.chainName, err)
+				if err := chwrapper.UpdateLatestBlock(ps.conn, ps.chainID, ps.chainName, uint64(newLatest), ps.degradedReason); err != nil {
+					ps.logger.Error("Error updating chain status", "error", err)
 				}
 
 				if newLatest > latestBlock {
@@ -243,33 +441,183 @@ func (ps *PChainSyncer) fetcherLoop(startBlock, latestBlock int64) {
 			}
 
 			// Calculate batch range
-			endBlock := currentBlock + int64(ps.fetchBatchSize) - 1
+			batchSize, streamBatchSize := ps.batchSizes(ps.applyLoadShedding(latestBlock))
+			endBlock := currentBlock + int64(batchSize) - 1
 			if endBlock > latestBlock {
 				endBlock = latestBlock
 			}
 
-			// Fetch blocks
-			blocks, err := ps.fetcher.FetchBlockRangeJSON(currentBlock, endBlock)
+			// Fetch blocks, handing each sub-batch to the writer as soon as it arrives. Sub-batches
+			// sent before an error are not fetched again.
+			err := ps.fetcher.StreamBlockRangeJSON(currentBlock, endBlock, streamBatchSize, func(from, to int64, blocks []*pchainrpc.JSONBlock) error {
+				if err := ps.sendBlocks(from, to, blocks); err != nil {
+					return err
+				}
+				currentBlock = to + 1
+				return nil
+			})
+			if ps.ctx.Err() != nil {
+				return
+			}
 			if err != nil {
-				log.Printf("[Chain %d - %s] Error fetching blocks %d-%d: %v",
-					ps.chainID, ps.chainName, currentBlock, endBlock, err)
+				ps.logger.Error("Error fetching blocks", "from", currentBlock, "to", endBlock, "error", err)
 				time.Sleep(1 * time.Second)
-				continue
 			}
+		}
+	}
+}
 
-			// Update fetched counter
-			ps.mu.Lock()
-			ps.blocksFetched += int64(len(blocks))
-			ps.mu.Unlock()
+// pauseUntilResumed stops writing and validator syncs, acknowledges the pause, and blocks until the
+// chain is resumed. Returns the block after the watermark to continue fetching from, or false if the
+// syncer is shutting down.
+func (ps *PChainSyncer) pauseUntilResumed(version uint64) (int64, bool) {
+	ps.logger.Info("Pause requested, stopping writer and validator syncs")
+
+	// Drop everything fetched but not yet written - it is refetched from the watermark on resume
+	done := make(chan struct{})
+	select {
+	case ps.pauseChan <- done:
+		<-done
+	case <-ps.ctx.Done():
+		return 0, false
+	}
 
-			// Send to channel (will block if buffer is full - backpressure)
-			select {
-			case ps.blockChan <- blocks:
-				currentBlock = endBlock + 1
-			case <-ps.ctx.Done():
-				return
+	if ps.validatorSyncer != nil {
+		ps.validatorSyncer.SetPaused(true)
+	}
+
+	if err := chwrapper.AckChainControl(ps.conn, ps.chainID, version, true); err != nil {
+		ps.logger.Error("Error acknowledging pause", "error", err)
+	}
+	ps.logger.Info("Paused")
+
+	for {
+		select {
+		case <-ps.ctx.Done():
+			return 0, false
+		case <-time.After(ControlPollInterval):
+		}
+
+		ctrl, err := chwrapper.GetChainControl(ps.conn, ps.chainID)
+		if err != nil {
+			ps.logger.Error("Error checking chain control", "error", err)
+			continue
+		}
+
+		if ctrl.Version == version {
+			continue
+		}
+		version = ctrl.Version
+
+		if ctrl.Paused {
+			// A newer pause request while already paused
+			if err := chwrapper.AckChainControl(ps.conn, ps.chainID, version, true); err != nil {
+				ps.logger.Error("Error acknowledging pause", "error", err)
 			}
+			continue
+		}
+
+		startBlock, err := ps.getStartingBlock()
+		if err != nil {
+			ps.logger.Error("Error reloading sync state, staying paused", "error", err)
+			continue
+		}
+
+		if ps.validatorSyncer != nil {
+			ps.validatorSyncer.SetPaused(false)
 		}
+
+		if err := chwrapper.AckChainControl(ps.conn, ps.chainID, version, false); err != nil {
+			ps.logger.Error("Error acknowledging resume", "error", err)
+		}
+		ps.logger.Info("Resumed", "block", startBlock)
+		return startBlock, true
+	}
+}
+
+// sendBlocks hands a fetched sub-batch [from, to] to the writer, then waits while the memory
+// budget is used up so fetching never runs far ahead of writing
+func (ps *PChainSyncer) sendBlocks(from, to int64, blocks []*pchainrpc.JSONBlock) error {
+	ps.setProposers(blocks, from, to)
+
+	// Update fetched counter
+	ps.mu.Lock()
+	ps.blocksFetched += int64(len(blocks))
+	ps.mu.Unlock()
+
+	// Send to channel (will block if buffer is full - backpressure)
+	size := blocksSize(blocks)
+	ps.batchTuner.Observe(len(blocks), size)
+	ps.budget.Add(size)
+	select {
+	case ps.blockChan <- blocks:
+	case <-ps.ctx.Done():
+		return ps.ctx.Err()
+	}
+
+	if !ps.budget.Wait(ps.ctx) {
+		return ps.ctx.Err()
+	}
+	return nil
+}
+
+// blocksSize estimates the bytes blocks take in memory
+func blocksSize(blocks []*pchainrpc.JSONBlock) int64 {
+	var size int64
+	for _, b := range blocks {
+		size += b.MemorySize()
+	}
+	return size
+}
+
+// batchSizes returns the fetch and stream batch sizes to use, tuned to the size of recent blocks
+// unless load shedding cut the fetch batch size
+func (ps *PChainSyncer) batchSizes(fetchBatchSize int) (int, int) {
+	if fetchBatchSize != ps.fetchBatchSize {
+		return fetchBatchSize, min(ps.streamBatchSize, fetchBatchSize)
+	}
+	return ps.batchTuner.Sizes(ps.fetchBatchSize, ps.streamBatchSize)
+}
+
+// applyLoadShedding switches the fetcher between normal and degraded mode when the load
+// shedder's state changes, and returns the fetch batch size to use
+func (ps *PChainSyncer) applyLoadShedding(latestBlock int64) int {
+	reason := ps.loadShedder.Reason()
+	if reason != ps.degradedReason {
+		if reason != "" {
+			ps.logger.Warn("Degraded mode: reducing concurrency and batch size", "reason", reason)
+			ps.fetcher.SetConcurrency(ps.maxConcurrency / DegradedDivisor)
+		} else {
+			ps.logger.Info("Leaving degraded mode")
+			ps.fetcher.SetConcurrency(ps.maxConcurrency)
+		}
+		ps.degradedReason = reason
+
+		if err := chwrapper.UpdateLatestBlock(ps.conn, ps.chainID, ps.chainName, uint64(latestBlock), reason); err != nil {
+			ps.logger.Error("Error updating chain status", "error", err)
+		}
+	}
+
+	if reason != "" {
+		return max(1, ps.fetchBatchSize/DegradedDivisor)
+	}
+	return ps.fetchBatchSize
+}
+
+// setProposers attributes blocks to their proposers. Failures only leave proposers empty.
+func (ps *PChainSyncer) setProposers(blocks []*pchainrpc.JSONBlock, from, to int64) {
+	if ps.proposers == nil {
+		return
+	}
+
+	proposers, err := ps.proposers.GetProposers(ps.ctx, uint64(from), uint64(to))
+	if err != nil {
+		ps.logger.Warn("Failed to get proposers", "from", from, "to", to, "error", err)
+		return
+	}
+
+	for _, b := range blocks {
+		b.Proposer = proposers[b.Height]
 	}
 }
 
@@ -277,11 +625,27 @@ func (ps *PChainSyncer) fetcherLoop(startBlock, latestBlock int64) {
 func (ps *PChainSyncer) writerLoop() {
 	defer ps.wg.Done()
 
+	// Subnet owner versions of new txs are recorded on writes, after the history. The backfill
+	// scans p_chain_txs, fetching goes on meanwhile.
+	if !ps.skipHistory {
+		ps.backfillSubnetOwners()
+	}
+
 	var buffer []*pchainrpc.JSONBlock
+	var bufferSize int64 // Bytes of buffer counted against the memory budget
 	var lastFlushTime time.Time
 	flushTimer := time.NewTimer(ps.flushInterval)
 	defer flushTimer.Stop()
 
+	// Gaps are healed here so they never race the writes of the blocks around them
+	var gapTicker <-chan time.Time
+	if ps.gapCheckInterval > 0 {
+		ticker := time.NewTicker(ps.gapCheckInterval)
+		defer ticker.Stop()
+		gapTicker = ticker.C
+	}
+	paused := false
+
 	// flush writes buffered blocks and ensures minimum interval between writes
 	flush := func() time.Duration {
 		if len(buffer) == 0 {
@@ -290,13 +654,13 @@ func (ps *PChainSyncer) writerLoop() {
 
 		start := time.Now()
 		if err := ps.writeBlocks(buffer); err != nil {
-			log.Printf("[P-Chain] Error writing blocks: %v", err)
+			ps.logger.Error("Error writing blocks", "error", err)
 			return ps.flushInterval
 		}
 
 		elapsed := time.Since(start)
 		if elapsed > 10*time.Second {
-			log.Printf("[P-Chain] WARNING: Write took %v, exceeds 10 second threshold", elapsed)
+			ps.logger.Warn("Write exceeded 10 second threshold", "elapsed", elapsed)
 		}
 
 		// Update counters and clear buffer
@@ -304,6 +668,8 @@ func (ps *PChainSyncer) writerLoop() {
 		ps.blocksWritten += int64(len(buffer))
 		ps.mu.Unlock()
 		buffer = nil
+		ps.budget.Release(bufferSize)
+		bufferSize = 0
 
 		// Calculate next flush time to maintain minimum interval
 		lastFlushTime = start
@@ -327,6 +693,8 @@ func (ps *PChainSyncer) writerLoop() {
 			}
 
 			buffer = append(buffer, blocks...)
+			bufferSize += blocksSize(blocks)
+			paused = false
 
 			// Flush immediately if interval has passed
 			if !lastFlushTime.IsZero() && time.Since(lastFlushTime) >= ps.flushInterval {
@@ -338,29 +706,99 @@ func (ps *PChainSyncer) writerLoop() {
 		case <-flushTimer.C:
 			nextInterval := flush()
 			flushTimer.Reset(nextInterval)
+
+		case <-gapTicker:
+			// Gap healing fetches from the RPC, which may be down while the chain is paused
+			if !paused {
+				ps.healGaps()
+			}
+
+		case done := <-ps.pauseChan:
+			// The fetcher has stopped sending, so draining the channel empties it
+			for drained := false; !drained; {
+				select {
+				case blocks, ok := <-ps.blockChan:
+					bufferSize += blocksSize(blocks)
+					drained = !ok
+				default:
+					drained = true
+				}
+			}
+			buffer = nil
+			ps.budget.Release(bufferSize)
+			bufferSize = 0
+			paused = true
+			close(done)
 		}
 	}
 }
 
 // writeBlocks writes blocks to ClickHouse and updates watermark
-func (ps *PChainSyncer) writeBlocks(blocks []*pchainrpc.JSONBlock) error {
+func (ps *PChainSyncer) writeBlocks(blocks []*pchainrpc.JSONBlock) (err error) {
 	if len(blocks) == 0 {
 		return nil
 	}
 
+	ctx, span := tracer.Start(ps.ctx, "pchainsyncer.writeBlocks",
+		trace.WithAttributes(tracing.Range(ps.chainID, int64(blocks[0].Height), int64(blocks[len(blocks)-1].Height))...))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
 	start := time.Now()
+	ctx = chwrapper.WithInsertSettings(ctx, ps.insertSettings)
 
 	// Insert transactions
-	if err := InsertPChainTxs(ps.ctx, ps.conn, ps.chainID, blocks); err != nil {
+	err = tracing.Run(ctx, tracer, "pchainsyncer.InsertPChainTxs", func(ctx context.Context) error {
+		return InsertPChainTxs(ctx, ps.conn, ps.chainID, blocks, ps.txBlobMinSize, ps.txStorage)
+	})
+	if err != nil {
 		return fmt.Errorf("failed to insert P-chain txs: %w", err)
 	}
 
+	// Insert block rows
+	err = tracing.Run(ctx, tracer, "pchainsyncer.InsertPChainBlocks", func(ctx context.Context) error {
+		return InsertPChainBlocks(ctx, ps.conn, ps.chainID, blocks)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to insert P-chain blocks: %w", err)
+	}
+
+	// Insert block bytes for cache hydrate
+	if ps.storePayloads {
+		err = tracing.Run(ctx, tracer, "pchainsyncer.InsertPChainPayloads", func(ctx context.Context) error {
+			return InsertPChainPayloads(ctx, ps.conn, ps.chainID, blocks)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to insert P-chain payloads: %w", err)
+		}
+	}
+
+	// Map subnets to the chains created in this batch
+	chains, err := CreateChainSubnetChains(ps.chainID, blocks)
+	if err != nil {
+		return err
+	}
+	if err := InsertSubnetChains(ctx, ps.conn, chains); err != nil {
+		return fmt.Errorf("failed to insert subnet chains: %w", err)
+	}
+
+	// Version subnet owners so historical queries see the owner at the time
+	versions, err := SubnetOwnerVersions(blocks)
+	if err != nil {
+		return err
+	}
+	if _, err := chwrapper.RecordDimensionVersions(ps.conn, ps.chainID, chwrapper.DimensionSubnetOwner, versions); err != nil {
+		return fmt.Errorf("failed to record subnet owner versions: %w", err)
+	}
+
 	elapsed := time.Since(start)
 	txCount := 0
 	for _, b := range blocks {
 		txCount += len(b.Transactions)
 	}
-	log.Printf("[Chain %d - %s] Inserted %d blocks and %d txs in %v", ps.chainID, ps.chainName, len(blocks), txCount, elapsed)
+	ps.logger.Info("Inserted blocks", "blocks", len(blocks), "txs", txCount, "elapsed", elapsed)
 
 	// Update watermark to the highest block number in this batch
 	maxBlock := uint64(0)
@@ -375,6 +813,7 @@ func (ps *PChainSyncer) writeBlocks(blocks []*pchainrpc.JSONBlock) error {
 			return fmt.Errorf("failed to update watermark: %w", err)
 		}
 		ps.watermark = maxBlock
+		firehose.Notify(ps.chainID)
 	}
 
 	return nil
@@ -402,8 +841,10 @@ func (ps *PChainSyncer) printProgress() {
 			writeRate := float64(written) / elapsed.Seconds()
 			lag := fetched - written
 
-			log.Printf("[Chain %d - %s] Fetched: %d (%.1f/s) | Written: %d (%.1f/s) | Lag: %d | Watermark: %d",
-				ps.chainID, ps.chainName, fetched, fetchRate, written, writeRate, lag, ps.watermark)
+			ps.logger.Info("Progress",
+				"fetched", fetched, "fetch_rate", fmt.Sprintf("%.1f/s", fetchRate),
+				"written", written, "write_rate", fmt.Sprintf("%.1f/s", writeRate),
+				"lag", lag, "watermark", ps.watermark)
 		}
 	}
 }
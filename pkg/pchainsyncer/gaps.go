@@ -0,0 +1,75 @@
+package pchainsyncer
+
+import (
+	"fmt"
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/metrics"
+)
+
+// GapHealMaxBlocks caps the missing blocks re-ingested per gap check, so healing never holds up
+// the writer for long
+const GapHealMaxBlocks = 1000
+
+// GapScanMaxBlocks caps the heights scanned per gap check, so the first check after a restart
+// works through the chain's history over many checks instead of holding up the writer
+const GapScanMaxBlocks = 1_000_000
+
+// healGaps looks for heights missing from p_chain_blocks below the watermark and ingests them
+// again, at most GapHealMaxBlocks per call. Only ranges checked since the last call are scanned, at
+// most GapScanMaxBlocks of them.
+func (ps *PChainSyncer) healGaps() {
+	from := max(ps.gapsCheckedTo, 1)
+	to := min(ps.watermark, from+GapScanMaxBlocks-1)
+	if to <= from {
+		return
+	}
+
+	gaps, err := chwrapper.FindBlockGaps(ps.conn, "p_chain_blocks", "p_chain_id", "height", ps.chainID, from, to)
+	if err != nil {
+		ps.logger.Error("Failed to check for missing blocks", "error", err)
+		return
+	}
+
+	var missing uint64
+	for _, gap := range gaps {
+		missing += gap.Size()
+	}
+	metrics.SetMissingBlocks(ps.chainID, missing)
+	if len(gaps) == 0 {
+		ps.gapsCheckedTo = to
+		return
+	}
+	ps.logger.Warn("Found missing blocks", "gaps", len(gaps), "blocks", missing, "first", gaps[0].From, "last", gaps[len(gaps)-1].To)
+
+	budget := uint64(GapHealMaxBlocks)
+	for _, gap := range gaps {
+		end := min(gap.To, gap.From+budget-1)
+		if err := ps.healRange(gap.From, end); err != nil {
+			ps.logger.Error("Failed to re-ingest missing blocks", "from", gap.From, "to", end, "error", err)
+			return
+		}
+		healed := end - gap.From + 1
+		metrics.BlocksHealed(ps.chainID, healed)
+		budget -= healed
+		if end < gap.To || budget == 0 {
+			return // The rest is healed on the next checks
+		}
+	}
+	ps.gapsCheckedTo = to
+}
+
+// healRange fetches blocks [from, to] and writes them again. The P-chain tables are
+// ReplacingMergeTrees, so rows the tables already have of them collapse on merge.
+func (ps *PChainSyncer) healRange(from, to uint64) error {
+	blocks, err := ps.fetcher.FetchBlockRangeJSON(int64(from), int64(to))
+	if err != nil {
+		return fmt.Errorf("failed to fetch blocks: %w", err)
+	}
+	ps.setProposers(blocks, int64(from), int64(to))
+
+	if err := ps.writeBlocks(blocks); err != nil {
+		return err
+	}
+	ps.logger.Info("Re-ingested missing blocks", "from", from, "to", to)
+	return nil
+}
@@ -2,13 +2,19 @@ package pchainsyncer
 
 import (
 	"context"
+	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/logging"
 	"icicle/pkg/pchainrpc"
-	"log"
+	"icicle/pkg/txblob"
+	"log/slog"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
@@ -17,6 +23,11 @@ import (
 	"github.com/ava-labs/avalanchego/vms/platformvm/warp/payload"
 )
 
+// chainLogger returns the logger for package-level P-Chain sync functions
+func chainLogger(pchainID uint32) *slog.Logger {
+	return logging.Chain("pchainsyncer", pchainID, "")
+}
+
 // convertClickHouseArrayToJSON converts ClickHouse array string format to JSON array
 // ClickHouse toString() on JSON arrays produces: ['{"key":"value"}', '{"key":"value"}']
 // We need to convert this to: [{"key":"value"}, {"key":"value"}]
@@ -121,7 +132,9 @@ const MaxTxsPerInsertBatch = 5000
 
 // InsertPChainTxs inserts P-chain transaction data into the p_chain_txs table
 // It automatically splits large batches to avoid ClickHouse memory limits
-func InsertPChainTxs(ctx context.Context, conn clickhouse.Conn, pchainID uint32, blocks []*pchainrpc.JSONBlock) error {
+// Large fields of at least blobMinSize bytes are compressed into tx_blobs (0 disables)
+// storage picks tx_data, the typed columns or both, see TxStorageJSON
+func InsertPChainTxs(ctx context.Context, conn clickhouse.Conn, pchainID uint32, blocks []*pchainrpc.JSONBlock, blobMinSize int, storage string) error {
 	if len(blocks) == 0 {
 		return nil
 	}
@@ -133,17 +146,37 @@ func InsertPChainTxs(ctx context.Context, conn clickhouse.Conn, pchainID uint32,
 		blockHeight uint64
 		blockTime   time.Time
 		txDataJSON  string
+		txBlob      string
+		memo        string
+		memoText    string
+		memoIsUTF8  bool
+		fields      *pchainrpc.NormalizedTx
 	}
 	var allTxs []txData
+	withJSON := storage != TxStorageTyped
+	withTyped := storage == TxStorageTyped || storage == TxStorageBoth
 
 	for _, block := range blocks {
 		for _, tx := range block.Transactions {
+			memoText, isUTF8 := memoToText(tx.Memo)
+			txDataJSON, txBlob := []byte("{}"), []byte(nil)
+			if withJSON {
+				var err error
+				if txDataJSON, txBlob, err = txblob.Compress(tx.TxData, blobMinSize); err != nil {
+					return fmt.Errorf("failed to compress tx %s: %w", tx.TxID, err)
+				}
+			}
 			allTxs = append(allTxs, txData{
 				txID:        tx.TxID.String(),
 				txType:      tx.TxType,
 				blockHeight: tx.BlockHeight,
 				blockTime:   tx.BlockTime,
-				txDataJSON:  string(tx.TxData),
+				txDataJSON:  string(txDataJSON),
+				txBlob:      string(txBlob),
+				memo:        string(tx.Memo),
+				memoText:    memoText,
+				memoIsUTF8:  isUTF8,
+				fields:      tx.Fields,
 			})
 		}
 	}
@@ -156,35 +189,199 @@ func InsertPChainTxs(ctx context.Context, conn clickhouse.Conn, pchainID uint32,
 		}
 		chunk := allTxs[i:end]
 
-		batch, err := conn.PrepareBatch(ctx, `INSERT INTO p_chain_txs (
-			tx_id, tx_type, block_number, block_time, p_chain_id, tx_data
-		)`)
+		query := `INSERT INTO p_chain_txs (
+			tx_id, tx_type, block_number, block_time, p_chain_id, memo, memo_text, tx_data, tx_blobs`
+		if withTyped {
+			query += ",\n\t" + typedTxColumnNames
+		}
+		batch, err := conn.PrepareBatch(ctx, query+"\n)")
 		if err != nil {
 			return fmt.Errorf("failed to prepare batch: %w", err)
 		}
 
-		for _, tx := range chunk {
-			err = batch.Append(
-				tx.txID,
-				tx.txType,
-				tx.blockHeight,
-				tx.blockTime,
-				pchainID,
-				tx.txDataJSON,
-			)
-			if err != nil {
-				return fmt.Errorf("failed to append tx %s: %w", tx.txID, err)
+		txIDs := make([]string, len(chunk))
+		txTypes := make([]string, len(chunk))
+		blockHeights := make([]uint64, len(chunk))
+		blockTimes := make([]time.Time, len(chunk))
+		pchainIDs := make([]uint32, len(chunk))
+		memos := make([]string, len(chunk))
+		memoTexts := make([]string, len(chunk))
+		txDataJSONs := make([]string, len(chunk))
+		txBlobs := make([]string, len(chunk))
+		var typed *typedTxColumns
+		if withTyped {
+			typed = newTypedTxColumns(len(chunk))
+		}
+		for j, tx := range chunk {
+			txIDs[j] = tx.txID
+			txTypes[j] = tx.txType
+			blockHeights[j] = tx.blockHeight
+			blockTimes[j] = tx.blockTime
+			pchainIDs[j] = pchainID
+			memos[j] = tx.memo
+			memoTexts[j] = tx.memoText
+			txDataJSONs[j] = tx.txDataJSON
+			txBlobs[j] = tx.txBlob
+			if typed != nil {
+				typed.add(tx.fields)
 			}
 		}
+		columns := []any{txIDs, txTypes, blockHeights, blockTimes, pchainIDs, memos, memoTexts, txDataJSONs, txBlobs}
+		if typed != nil {
+			columns = append(columns, typed.columns()...)
+		}
+		err = chwrapper.AppendColumns(batch, columns...)
+		if err != nil {
+			return fmt.Errorf("failed to append txs: %w", err)
+		}
 
 		if err := batch.Send(); err != nil {
 			return fmt.Errorf("failed to send batch: %w", err)
 		}
 	}
 
+	// Index transactions with a non-empty memo
+	var memoTxs []txData
+	for _, tx := range allTxs {
+		if tx.memo != "" {
+			memoTxs = append(memoTxs, tx)
+		}
+	}
+	if len(memoTxs) == 0 {
+		return nil
+	}
+
+	memoBatch, err := conn.PrepareBatch(ctx, `INSERT INTO p_chain_memos (
+		tx_id, tx_type, block_number, block_time, memo, memo_text, is_utf8, p_chain_id
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare memo batch: %w", err)
+	}
+
+	for _, tx := range memoTxs {
+		err = memoBatch.Append(
+			tx.txID,
+			tx.txType,
+			tx.blockHeight,
+			tx.blockTime,
+			tx.memo,
+			tx.memoText,
+			tx.memoIsUTF8,
+			pchainID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to append memo for tx %s: %w", tx.txID, err)
+		}
+	}
+
+	if err := memoBatch.Send(); err != nil {
+		return fmt.Errorf("failed to send memo batch: %w", err)
+	}
+
 	return nil
 }
 
+// InsertPChainBlocks inserts one row per block into the p_chain_blocks table
+func InsertPChainBlocks(ctx context.Context, conn clickhouse.Conn, pchainID uint32, blocks []*pchainrpc.JSONBlock) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO p_chain_blocks (
+		block_id, height, parent_id, block_time, tx_count, block_type, proposer, p_chain_id
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare block batch: %w", err)
+	}
+
+	for _, block := range blocks {
+		err = batch.Append(
+			block.BlockID.String(),
+			block.Height,
+			block.ParentID.String(),
+			block.Timestamp,
+			uint32(len(block.Transactions)),
+			block.BlockType,
+			block.Proposer,
+			pchainID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to append block %d: %w", block.Height, err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to send block batch: %w", err)
+	}
+
+	return nil
+}
+
+// InsertPChainPayloads inserts the bytes of each block into raw_payloads, for cache hydrate
+func InsertPChainPayloads(ctx context.Context, conn clickhouse.Conn, pchainID uint32, blocks []*pchainrpc.JSONBlock) error {
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO raw_payloads (chain_id, block_number, data)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare payload batch: %w", err)
+	}
+
+	for _, block := range blocks {
+		if block.Raw() == nil {
+			continue
+		}
+		if err := batch.Append(pchainID, uint32(block.Height), block.Raw()); err != nil {
+			return fmt.Errorf("failed to append payload %d: %w", block.Height, err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to send payload batch: %w", err)
+	}
+
+	return nil
+}
+
+// CreateChainSubnetChains returns the subnet_chains rows for the CreateChain txs in blocks.
+// The new chain's ID is the CreateChain tx ID.
+func CreateChainSubnetChains(pchainID uint32, blocks []*pchainrpc.JSONBlock) ([]SubnetChain, error) {
+	var chains []SubnetChain
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			if tx.TxType != "CreateChain" {
+				continue
+			}
+
+			var createChain struct {
+				SubnetID  ids.ID `json:"subnetID"`
+				ChainName string `json:"chainName"`
+				VMID      ids.ID `json:"vmID"`
+			}
+			if err := json.Unmarshal(tx.TxData, &createChain); err != nil {
+				return nil, fmt.Errorf("failed to parse CreateChain tx %s: %w", tx.TxID, err)
+			}
+
+			chains = append(chains, SubnetChain{
+				ChainID:      tx.TxID,
+				SubnetID:     createChain.SubnetID,
+				ChainName:    createChain.ChainName,
+				VMID:         createChain.VMID,
+				CreatedBlock: tx.BlockHeight,
+				CreatedTime:  tx.BlockTime,
+				PChainID:     pchainID,
+			})
+		}
+	}
+	return chains, nil
+}
+
+// memoToText returns the memo as text if it is valid UTF-8
+// Trailing NUL padding is trimmed since some wallets pad memos to a fixed size
+func memoToText(memo []byte) (string, bool) {
+	if len(memo) == 0 || !utf8.Valid(memo) {
+		return "", false
+	}
+	return strings.TrimRight(string(memo), "\x00"), true
+}
+
 // L1Subnet represents an L1 subnet to be tracked
 type L1Subnet struct {
 	SubnetID        ids.ID
@@ -352,7 +549,7 @@ func MarkInactiveValidators(ctx context.Context, conn clickhouse.Conn, pchainID
 		return fmt.Errorf("failed to send inactive validators batch: %w", err)
 	}
 
-	log.Printf("Marked %d validators as inactive for subnet %s", len(toDeactivate), subnetID)
+	chainLogger(pchainID).Info("Marked validators as inactive", "count", len(toDeactivate), "subnet_id", subnetID)
 	return nil
 }
 
@@ -396,19 +593,33 @@ func GetL1Subnets(ctx context.Context, conn clickhouse.Conn, pchainID uint32) ([
 func DiscoverL1SubnetsFromTransactions(ctx context.Context, conn clickhouse.Conn, pchainID uint32) ([]L1Subnet, error) {
 	// We also look for TransformSubnet (which creates elastic subnets) as they are effectively L1s
 	// or at least have validators we want to track.
+	// TransformSubnet txs carry no chainID, so fall back to the subnet's first chain from subnet_chains
 	query := `
 		SELECT
-			tx_data.subnetID as subnet_id,
-			coalesce(tx_data.chainID, '') as chain_id,
-			block_number,
-			block_time
-		FROM p_chain_txs
-		WHERE p_chain_id = ?
-		  AND (tx_type = 'ConvertSubnetToL1' OR tx_type = 'TransformSubnet')
-		ORDER BY block_number DESC
+			t.subnet_id,
+			if(t.chain_id != '', t.chain_id, c.chain_id) as chain_id,
+			t.block_number,
+			t.block_time
+		FROM (
+			SELECT
+				CAST(tx_data.subnetID AS String) as subnet_id,
+				CAST(coalesce(tx_data.chainID, '') AS String) as chain_id,
+				block_number,
+				block_time
+			FROM p_chain_txs
+			WHERE p_chain_id = ?
+			  AND (tx_type = 'ConvertSubnetToL1' OR tx_type = 'TransformSubnet')
+		) t
+		LEFT JOIN (
+			SELECT subnet_id, argMin(chain_id, created_block) as chain_id
+			FROM subnet_chains FINAL
+			WHERE p_chain_id = ?
+			GROUP BY subnet_id
+		) c ON t.subnet_id = c.subnet_id
+		ORDER BY t.block_number DESC
 	`
 
-	rows, err := conn.Query(ctx, query, pchainID)
+	rows, err := conn.Query(ctx, query, pchainID, pchainID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query subnet transactions: %w", err)
 	}
@@ -523,7 +734,7 @@ func DiscoverAllSubnets(ctx context.Context, conn clickhouse.Conn, pchainID uint
 		SELECT COALESCE(max(created_block), 0) FROM subnets FINAL WHERE p_chain_id = ?
 	`, pchainID).Scan(&lastProcessedBlock)
 	if err != nil {
-		log.Printf("WARNING: Could not get last processed block, will scan from start: %v", err)
+		chainLogger(pchainID).Warn("Could not get last processed block, will scan from start", "error", err)
 		lastProcessedBlock = 0
 	}
 
@@ -607,7 +818,7 @@ func DiscoverAllSubnets(ctx context.Context, conn clickhouse.Conn, pchainID uint
 		subnetID, err := ids.FromString(subnetIDStr)
 		if err != nil {
 			// Skip invalid subnet IDs (likely CB58 encoding issues)
-			// log.Printf("WARNING: Skipping invalid subnet ID %s: %v", subnetIDStr, err)
+			// slog.Warn("Skipping invalid subnet ID", "subnet_id", subnetIDStr, "error", err)
 			continue
 		}
 
@@ -657,47 +868,25 @@ func DiscoverAllSubnets(ctx context.Context, conn clickhouse.Conn, pchainID uint
 	return subnets, nil
 }
 
-// DiscoverSubnetChains scans p_chain_txs for CreateChain transactions
-// and matches them with ConvertSubnetToL1 chain IDs
+// DiscoverSubnetChains scans p_chain_txs for CreateChain transactions. A chain's ID is the ID of
+// the CreateChain tx that created it. Ingestion already writes these rows; this backfills
+// subnet_chains for transactions ingested before it did.
 func DiscoverSubnetChains(ctx context.Context, conn clickhouse.Conn, pchainID uint32) ([]SubnetChain, error) {
-	// Get chain IDs from ConvertSubnetToL1 transactions (which have proper CB58-encoded chainID)
-	// Then find the corresponding CreateChain transaction to get name and VM ID
 	query := `
-		WITH l1_chains AS (
-			SELECT DISTINCT
-				CAST(tx_data.chainID AS String) as chain_id,
-				CAST(tx_data.subnetID AS String) as subnet_id
-			FROM p_chain_txs
-			WHERE p_chain_id = ?
-			  AND tx_type = 'ConvertSubnetToL1'
-			  AND tx_data.chainID != ''
-			  AND tx_data.subnetID != ''
-		),
-		create_chain_info AS (
-			SELECT
-				CAST(tx_data.subnetID AS String) as subnet_id,
-				CAST(coalesce(tx_data.chainName, '') AS String) as chain_name,
-				CAST(coalesce(tx_data.vmID, '') AS String) as vm_id,
-				block_number,
-				block_time,
-				ROW_NUMBER() OVER (PARTITION BY tx_data.subnetID ORDER BY block_number ASC) as rn
-			FROM p_chain_txs
-			WHERE p_chain_id = ?
-			  AND tx_type = 'CreateChain'
-			  AND tx_data.subnetID != ''
-		)
 		SELECT
-			l.chain_id,
-			l.subnet_id,
-			COALESCE(c.chain_name, '') as chain_name,
-			COALESCE(c.vm_id, '') as vm_id,
-			COALESCE(c.block_number, 0) as created_block,
-			COALESCE(c.block_time, toDateTime('1970-01-01 00:00:00')) as created_time
-		FROM l1_chains l
-		LEFT JOIN create_chain_info c ON l.subnet_id = c.subnet_id AND c.rn = 1
+			tx_id as chain_id,
+			CAST(tx_data.subnetID AS String) as subnet_id,
+			CAST(coalesce(tx_data.chainName, '') AS String) as chain_name,
+			CAST(coalesce(tx_data.vmID, '') AS String) as vm_id,
+			block_number as created_block,
+			block_time as created_time
+		FROM p_chain_txs
+		WHERE p_chain_id = ?
+		  AND tx_type = 'CreateChain'
+		  AND tx_data.subnetID != ''
 	`
 
-	rows, err := conn.Query(ctx, query, pchainID, pchainID)
+	rows, err := conn.Query(ctx, query, pchainID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query chain info: %w", err)
 	}
@@ -1128,7 +1317,7 @@ func DiscoverL1ValidatorHistory(ctx context.Context, conn clickhouse.Conn, pchai
 		SELECT COALESCE(max(created_block), 0) FROM l1_validator_history FINAL WHERE p_chain_id = ?
 	`, pchainID).Scan(&lastProcessedBlock)
 	if err != nil {
-		log.Printf("WARNING: Could not get last processed block for validator history: %v", err)
+		chainLogger(pchainID).Warn("Could not get last processed block for validator history", "error", err)
 		lastProcessedBlock = 0
 	}
 
@@ -1140,7 +1329,8 @@ func DiscoverL1ValidatorHistory(ctx context.Context, conn clickhouse.Conn, pchai
 			block_number,
 			block_time,
 			toString(tx_data.subnetID) as subnet_id,
-			toString(tx_data.validators) as validators_json
+			toString(tx_data.validators) as validators_json,
+			tx_blobs
 		FROM p_chain_txs
 		WHERE p_chain_id = ?
 		  AND tx_type = 'ConvertSubnetToL1'
@@ -1156,29 +1346,40 @@ func DiscoverL1ValidatorHistory(ctx context.Context, conn clickhouse.Conn, pchai
 
 	var validators []L1ValidatorHistory
 	for rows.Next() {
-		var txID, subnetID, validatorsJSON string
+		var txID, subnetID, validatorsJSON, txBlob string
 		var blockNumber uint64
 		var blockTime time.Time
 
-		if err := rows.Scan(&txID, &blockNumber, &blockTime, &subnetID, &validatorsJSON); err != nil {
+		if err := rows.Scan(&txID, &blockNumber, &blockTime, &subnetID, &validatorsJSON, &txBlob); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
-		// Parse the validators JSON array in Go
-		// ClickHouse toString() on JSON array produces ['{"json":...}', '{"json":...}'] format
-		// We need to convert this to proper JSON array format
-		validatorsJSON = convertClickHouseArrayToJSON(validatorsJSON)
+		// Large validator sets are stored compressed in tx_blobs as plain JSON
+		blobFields, err := txblob.Decompress([]byte(txBlob))
+		if err != nil {
+			chainLogger(pchainID).Warn("Failed to decompress tx blob", "tx", txID, "error", err)
+			continue
+		}
+
+		if blobValidators, ok := blobFields["validators"]; ok {
+			validatorsJSON = string(blobValidators)
+		} else {
+			// Parse the validators JSON array in Go
+			// ClickHouse toString() on JSON array produces ['{"json":...}', '{"json":...}'] format
+			// We need to convert this to proper JSON array format
+			validatorsJSON = convertClickHouseArrayToJSON(validatorsJSON)
+		}
 
 		var txValidators []ConvertSubnetValidator
 		if err := json.Unmarshal([]byte(validatorsJSON), &txValidators); err != nil {
-			log.Printf("WARNING: Failed to parse validators JSON for tx %s: %v (json: %s)", txID, err, validatorsJSON[:min(200, len(validatorsJSON))])
+			chainLogger(pchainID).Warn("Failed to parse validators JSON", "tx", txID, "error", err, "json", validatorsJSON[:min(200, len(validatorsJSON))])
 			continue
 		}
 
 		// Parse subnet ID for computing validation_id
 		subnetIDParsed, err := ids.FromString(subnetID)
 		if err != nil {
-			log.Printf("WARNING: Failed to parse subnet ID %s for tx %s: %v", subnetID, txID, err)
+			chainLogger(pchainID).Warn("Failed to parse subnet ID", "subnet_id", subnetID, "tx", txID, "error", err)
 			continue
 		}
 
@@ -1187,7 +1388,7 @@ func DiscoverL1ValidatorHistory(ctx context.Context, conn clickhouse.Conn, pchai
 			// Convert hex node ID to CB58 format
 			nodeIDCB58, err := hexToNodeID(v.NodeID)
 			if err != nil {
-				log.Printf("WARNING: Failed to convert node ID %s to CB58 for tx %s: %v", v.NodeID, txID, err)
+				chainLogger(pchainID).Warn("Failed to convert node ID to CB58", "node_id", v.NodeID, "tx", txID, "error", err)
 				continue
 			}
 
@@ -1233,7 +1434,7 @@ func DiscoverL1ValidatorHistory(ctx context.Context, conn clickhouse.Conn, pchai
 	var registerQuery string
 	var registerRows driver.Rows
 	if registerCount == 0 {
-		log.Printf("No RegisterL1Validator records found, performing full backfill...")
+		chainLogger(pchainID).Info("No RegisterL1Validator records found, performing full backfill")
 		registerQuery = `
 			SELECT
 				tx_id,
@@ -1274,7 +1475,7 @@ func DiscoverL1ValidatorHistory(ctx context.Context, conn clickhouse.Conn, pchai
 		var blockTime time.Time
 
 		if err := registerRows.Scan(&txID, &blockNumber, &blockTime, &messageHex, &balance); err != nil {
-			log.Printf("WARNING: Failed to scan RegisterL1Validator row: %v", err)
+			chainLogger(pchainID).Warn("Failed to scan RegisterL1Validator row", "error", err)
 			continue
 		}
 
@@ -1283,7 +1484,7 @@ func DiscoverL1ValidatorHistory(ctx context.Context, conn clickhouse.Conn, pchai
 		messageHex = strings.TrimPrefix(messageHex, "0x")
 		messageBytes, err := hex.DecodeString(messageHex)
 		if err != nil {
-			log.Printf("WARNING: Failed to decode message hex for tx %s: %v", txID, err)
+			chainLogger(pchainID).Warn("Failed to decode message hex", "tx", txID, "error", err)
 			continue
 		}
 
@@ -1296,14 +1497,14 @@ func DiscoverL1ValidatorHistory(ctx context.Context, conn clickhouse.Conn, pchai
 		// - 4 bytes: payload length
 		// - N bytes: payload
 		if len(messageBytes) < 42 { // 2 + 4 + 32 + 4 minimum
-			log.Printf("WARNING: Message too short for tx %s: %d bytes", txID, len(messageBytes))
+			chainLogger(pchainID).Warn("Message too short", "tx", txID, "bytes", len(messageBytes))
 			continue
 		}
 
 		// Skip codec version (2 bytes), network ID (4 bytes), source chain ID (32 bytes)
 		payloadLenOffset := 2 + 4 + 32
 		if len(messageBytes) < payloadLenOffset+4 {
-			log.Printf("WARNING: Message too short for payload length for tx %s", txID)
+			chainLogger(pchainID).Warn("Message too short for payload length", "tx", txID)
 			continue
 		}
 
@@ -1317,7 +1518,7 @@ func DiscoverL1ValidatorHistory(ctx context.Context, conn clickhouse.Conn, pchai
 		payloadEnd := payloadStart + int(payloadLen)
 
 		if payloadEnd > len(messageBytes) {
-			log.Printf("WARNING: Payload extends beyond message for tx %s: need %d, have %d", txID, payloadEnd, len(messageBytes))
+			chainLogger(pchainID).Warn("Payload extends beyond message", "tx", txID, "need", payloadEnd, "have", len(messageBytes))
 			continue
 		}
 
@@ -1326,27 +1527,27 @@ func DiscoverL1ValidatorHistory(ctx context.Context, conn clickhouse.Conn, pchai
 		// The warp payload is an AddressedCall which wraps the actual message
 		addressedCall, err := payload.ParseAddressedCall(warpPayload)
 		if err != nil {
-			log.Printf("WARNING: Failed to parse AddressedCall for tx %s: %v", txID, err)
+			chainLogger(pchainID).Warn("Failed to parse AddressedCall", "tx", txID, "error", err)
 			continue
 		}
 
 		// Parse the inner payload as RegisterL1Validator message
 		regMsg, err := message.ParseRegisterL1Validator(addressedCall.Payload)
 		if err != nil {
-			log.Printf("WARNING: Failed to parse RegisterL1Validator payload for tx %s: %v", txID, err)
+			chainLogger(pchainID).Warn("Failed to parse RegisterL1Validator payload", "tx", txID, "error", err)
 			continue
 		}
 
 		// Validate NodeID is not empty
 		if len(regMsg.NodeID) == 0 {
-			log.Printf("WARNING: Empty NodeID in RegisterL1Validator tx %s, skipping", txID)
+			chainLogger(pchainID).Warn("Empty NodeID in RegisterL1Validator tx, skipping", "tx", txID)
 			continue
 		}
 
 		// Convert node ID bytes to CB58 format
 		var nodeID ids.NodeID
 		if len(regMsg.NodeID) != len(nodeID) {
-			log.Printf("WARNING: Invalid NodeID length %d (expected %d) in tx %s, skipping", len(regMsg.NodeID), len(nodeID), txID)
+			chainLogger(pchainID).Warn("Invalid NodeID length in RegisterL1Validator tx, skipping", "length", len(regMsg.NodeID), "expected", len(nodeID), "tx", txID)
 			continue
 		}
 		copy(nodeID[:], regMsg.NodeID)
@@ -1354,7 +1555,7 @@ func DiscoverL1ValidatorHistory(ctx context.Context, conn clickhouse.Conn, pchai
 		// Validate the resulting NodeID is not all zeros
 		var zeroNodeID ids.NodeID
 		if nodeID == zeroNodeID {
-			log.Printf("WARNING: All-zero NodeID in RegisterL1Validator tx %s, skipping", txID)
+			chainLogger(pchainID).Warn("All-zero NodeID in RegisterL1Validator tx, skipping", "tx", txID)
 			continue
 		}
 
@@ -1369,8 +1570,8 @@ func DiscoverL1ValidatorHistory(ctx context.Context, conn clickhouse.Conn, pchai
 			remainingBalanceOwner = regMsg.RemainingBalanceOwner.Addresses[0].String()
 		}
 
-		log.Printf("DEBUG: RegisterL1Validator tx %s: NodeID=%s, SubnetID=%s, Weight=%d, ValidationID=%s",
-			txID, nodeIDStr, regMsg.SubnetID.String(), regMsg.Weight, validationID.String())
+		chainLogger(pchainID).Debug("RegisterL1Validator", "tx", txID, "node_id", nodeIDStr, "subnet_id", regMsg.SubnetID.String(),
+			"weight", regMsg.Weight, "validation_id", validationID.String())
 
 		// Extract validator info
 		validators = append(validators, L1ValidatorHistory{
@@ -1457,7 +1658,7 @@ func SyncL1ValidatorBalanceTxs(ctx context.Context, conn clickhouse.Conn, pchain
 		SELECT COALESCE(max(block_number), 0) FROM l1_validator_balance_txs WHERE p_chain_id = ?
 	`, pchainID).Scan(&lastSyncedBlock)
 	if err != nil {
-		log.Printf("WARNING: Could not get last synced block for balance txs: %v", err)
+		chainLogger(pchainID).Warn("Could not get last synced block for balance txs", "error", err)
 		lastSyncedBlock = 0
 	}
 
@@ -1493,7 +1694,7 @@ func SyncL1ValidatorBalanceTxs(ctx context.Context, conn clickhouse.Conn, pchain
 			&tx.ValidationID, &tx.TxID, &tx.TxType, &tx.BlockNumber,
 			&tx.BlockTime, &tx.Amount, &tx.SubnetID, &tx.NodeID,
 		); err != nil {
-			log.Printf("WARNING: Failed to scan top-up tx: %v", err)
+			chainLogger(pchainID).Warn("Failed to scan top-up tx", "error", err)
 			continue
 		}
 		tx.PChainID = pchainID
@@ -1531,7 +1732,7 @@ func SyncL1ValidatorBalanceTxs(ctx context.Context, conn clickhouse.Conn, pchain
 				&tx.ValidationID, &tx.TxID, &tx.TxType, &tx.BlockNumber,
 				&tx.BlockTime, &tx.Amount, &tx.SubnetID, &tx.NodeID,
 			); err != nil {
-				log.Printf("WARNING: Failed to scan initial deposit: %v", err)
+				chainLogger(pchainID).Warn("Failed to scan initial deposit", "error", err)
 				continue
 			}
 			tx.PChainID = pchainID
@@ -1542,7 +1743,7 @@ func SyncL1ValidatorBalanceTxs(ctx context.Context, conn clickhouse.Conn, pchain
 
 	// Insert all transactions
 	if len(txs) > 0 {
-		log.Printf("Syncing %d balance transactions to l1_validator_balance_txs", len(txs))
+		chainLogger(pchainID).Info("Syncing balance transactions to l1_validator_balance_txs", "count", len(txs))
 		if err := InsertL1ValidatorBalanceTxs(ctx, conn, txs); err != nil {
 			return fmt.Errorf("failed to insert balance txs: %w", err)
 		}
@@ -1670,7 +1871,7 @@ func UpdatePerValidatorFeeStats(ctx context.Context, conn clickhouse.Conn, pchai
 			&startTime, &endTime, &uptime, &active,
 			&initialDeposit, &totalTopups, &refundAmount,
 		); err != nil {
-			log.Printf("WARNING: Failed to scan validator row: %v", err)
+			chainLogger(pchainID).Warn("Failed to scan validator row", "error", err)
 			continue
 		}
 
@@ -1687,7 +1888,7 @@ func UpdatePerValidatorFeeStats(ctx context.Context, conn clickhouse.Conn, pchai
 			initialDeposit, totalTopups, refundAmount, feesPaid,
 		)
 		if err != nil {
-			log.Printf("WARNING: Failed to append validator %s: %v", nodeID, err)
+			chainLogger(pchainID).Warn("Failed to append validator", "node_id", nodeID, "error", err)
 			continue
 		}
 		updateCount++
@@ -1701,7 +1902,7 @@ func UpdatePerValidatorFeeStats(ctx context.Context, conn clickhouse.Conn, pchai
 		if err := batch.Send(); err != nil {
 			return fmt.Errorf("failed to send batch: %w", err)
 		}
-		log.Printf("Updated fee stats for %d validators", updateCount)
+		chainLogger(pchainID).Info("Updated validator fee stats", "count", updateCount)
 	}
 
 	return nil
@@ -1728,7 +1929,7 @@ func SyncL1ValidatorRefunds(ctx context.Context, conn clickhouse.Conn, fetcher *
 		SELECT COALESCE(max(block_number), 0) FROM l1_validator_refunds WHERE p_chain_id = ?
 	`, pchainID).Scan(&lastSyncedBlock)
 	if err != nil {
-		log.Printf("WARNING: Could not get last synced block for refunds: %v", err)
+		chainLogger(pchainID).Warn("Could not get last synced block for refunds", "error", err)
 		lastSyncedBlock = 0
 	}
 
@@ -1756,7 +1957,7 @@ func SyncL1ValidatorRefunds(ctx context.Context, conn clickhouse.Conn, fetcher *
 	for rows.Next() {
 		var r L1ValidatorRefund
 		if err := rows.Scan(&r.TxID, &r.ValidationID, &r.BlockNumber, &r.BlockTime); err != nil {
-			log.Printf("WARNING: Failed to scan refund tx: %v", err)
+			chainLogger(pchainID).Warn("Failed to scan refund tx", "error", err)
 			continue
 		}
 		r.PChainID = pchainID
@@ -1788,7 +1989,7 @@ func SyncL1ValidatorRefunds(ctx context.Context, conn clickhouse.Conn, fetcher *
 					WHERE validation_id = ? AND p_chain_id = ?
 				`, refunds[i].ValidationID, pchainID).Scan(&subnetID)
 				if err != nil {
-					log.Printf("WARNING: Could not find subnet for validation_id %s: %v", refunds[i].ValidationID, err)
+					chainLogger(pchainID).Warn("Could not find subnet for validation ID", "validation_id", refunds[i].ValidationID, "error", err)
 					continue
 				}
 			}
@@ -1797,12 +1998,12 @@ func SyncL1ValidatorRefunds(ctx context.Context, conn clickhouse.Conn, fetcher *
 			if refundAddress == "" {
 				validatorInfo, rpcErr := fetcher.GetL1Validator(ctx, refunds[i].ValidationID)
 				if rpcErr != nil {
-					log.Printf("WARNING: Could not get L1 validator info for %s (not in history and RPC failed): %v", refunds[i].ValidationID, rpcErr)
+					chainLogger(pchainID).Warn("Could not get L1 validator info (not in history and RPC failed)", "validation_id", refunds[i].ValidationID, "error", rpcErr)
 					continue
 				}
 
 				if len(validatorInfo.RemainingBalanceOwner.Addresses) == 0 {
-					log.Printf("WARNING: No remainingBalanceOwner address for validator %s", refunds[i].ValidationID)
+					chainLogger(pchainID).Warn("No remainingBalanceOwner address for validator", "validation_id", refunds[i].ValidationID)
 					continue
 				}
 				refundAddress = validatorInfo.RemainingBalanceOwner.Addresses[0]
@@ -1817,7 +2018,7 @@ func SyncL1ValidatorRefunds(ctx context.Context, conn clickhouse.Conn, fetcher *
 		// This is reliable because fee rate has always been 512 nAVAX/sec (network < 10k validators)
 		refundAmount, err := calculateRefundFromDeposits(ctx, conn, refunds[i].ValidationID, pchainID, refunds[i].BlockTime)
 		if err != nil {
-			log.Printf("WARNING: Could not calculate refund for %s: %v", refunds[i].ValidationID, err)
+			chainLogger(pchainID).Warn("Could not calculate refund", "validation_id", refunds[i].ValidationID, "error", err)
 			continue
 		}
 		refunds[i].RefundAmount = refundAmount
@@ -1856,7 +2057,7 @@ func SyncL1ValidatorRefunds(ctx context.Context, conn clickhouse.Conn, fetcher *
 		if err := batch.Send(); err != nil {
 			return fmt.Errorf("failed to send refunds batch: %w", err)
 		}
-		log.Printf("Synced %d validator refunds", insertCount)
+		chainLogger(pchainID).Info("Synced validator refunds", "count", insertCount)
 	}
 
 	return nil
@@ -1949,8 +2150,401 @@ func calculateRefundFromDeposits(ctx context.Context, conn clickhouse.Conn, vali
 	}
 
 	refund := totalDeposits - feesConsumed
-	log.Printf("Calculated refund for %s: deposits=%d, start=%s, active=%ds, fees=%d, refund=%d nAVAX (%.6f AVAX)",
-		validationID, totalDeposits, startTime.Format("2006-01-02 15:04:05"), activeSeconds, feesConsumed, refund, float64(refund)/1e9)
+	chainLogger(pchainID).Info("Calculated refund", "validation_id", validationID, "deposits", totalDeposits,
+		"start", startTime.Format("2006-01-02 15:04:05"), "active_seconds", activeSeconds, "fees", feesConsumed,
+		"refund_navax", refund, "refund_avax", float64(refund)/1e9)
 
 	return refund, nil
 }
+
+const (
+	// RewardTxsPerBatch caps how many RewardValidator txs are backfilled per batch
+	RewardTxsPerBatch = 100
+	// MaxRewardFetchAttempts is how many times a tx is retried before it is given up as failed
+	MaxRewardFetchAttempts = 5
+)
+
+// PChainReward represents a single reward UTXO paid out by a RewardValidator tx
+type PChainReward struct {
+	TxID              string
+	StakerTxID        string
+	UTXOID            string
+	OutputIndex       uint32
+	AssetID           string
+	Amount            uint64
+	Addresses         []string
+	Threshold         uint32
+	Locktime          uint64
+	StakeableLocktime uint64
+	BlockNumber       uint64
+	BlockTime         time.Time
+	PChainID          uint32
+}
+
+// PChainRewardSync records the backfill status of a single RewardValidator tx
+type PChainRewardSync struct {
+	TxID        string
+	StakerTxID  string
+	BlockNumber uint64
+	Status      string // "rewarded", "not_rewarded" or "failed"
+	Attempts    uint32
+	LastError   string
+	PChainID    uint32
+}
+
+// SyncPChainRewards backfills reward amounts and recipients for one batch of RewardValidator txs
+// It fetches the reward UTXOs of the rewarded staker tx via platform.getRewardUTXOs and
+// returns the number of txs processed
+func SyncPChainRewards(ctx context.Context, conn clickhouse.Conn, fetcher *pchainrpc.Fetcher, pchainID uint32) (int, error) {
+	// Pick RewardValidator txs that were never synced, or failed fewer than MaxRewardFetchAttempts times
+	query := `
+		SELECT
+			t.tx_id,
+			toString(t.tx_data.txID) as staker_tx_id,
+			t.block_number,
+			t.block_time,
+			s.attempts
+		FROM p_chain_txs AS t FINAL
+		LEFT JOIN (
+			SELECT tx_id, status, attempts FROM p_chain_reward_sync FINAL WHERE p_chain_id = ?
+		) AS s ON t.tx_id = s.tx_id
+		WHERE t.p_chain_id = ?
+		  AND t.tx_type = 'RewardValidator'
+		  AND (s.status = '' OR (s.status = 'failed' AND s.attempts < ?))
+		ORDER BY t.block_number ASC
+		LIMIT ?
+	`
+
+	rows, err := conn.Query(ctx, query, pchainID, pchainID, MaxRewardFetchAttempts, RewardTxsPerBatch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query RewardValidator txs: %w", err)
+	}
+	defer rows.Close()
+
+	type rewardTx struct {
+		txID        string
+		stakerTxID  string
+		blockNumber uint64
+		blockTime   time.Time
+		attempts    uint32
+	}
+
+	var rewardTxs []rewardTx
+	for rows.Next() {
+		var t rewardTx
+		if err := rows.Scan(&t.txID, &t.stakerTxID, &t.blockNumber, &t.blockTime, &t.attempts); err != nil {
+			chainLogger(pchainID).Warn("Failed to scan reward tx", "error", err)
+			continue
+		}
+		rewardTxs = append(rewardTxs, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("reward rows error: %w", err)
+	}
+
+	if len(rewardTxs) == 0 {
+		return 0, nil
+	}
+
+	var rewards []PChainReward
+	var statuses []PChainRewardSync
+	for _, t := range rewardTxs {
+		status := PChainRewardSync{
+			TxID:        t.txID,
+			StakerTxID:  t.stakerTxID,
+			BlockNumber: t.blockNumber,
+			Attempts:    t.attempts,
+			PChainID:    pchainID,
+		}
+
+		txRewards, err := fetchRewardUTXOs(ctx, fetcher, t.stakerTxID)
+		if err != nil {
+			// RPC and parse failures are retried on later batches until MaxRewardFetchAttempts
+			status.Status = "failed"
+			status.Attempts++
+			status.LastError = err.Error()
+			if status.Attempts >= MaxRewardFetchAttempts {
+				chainLogger(pchainID).Warn("Giving up on reward UTXOs", "staker_tx", t.stakerTxID, "attempts", status.Attempts, "error", err)
+			} else {
+				chainLogger(pchainID).Warn("Could not get reward UTXOs", "staker_tx", t.stakerTxID, "attempt", status.Attempts, "max_attempts", MaxRewardFetchAttempts, "error", err)
+			}
+			statuses = append(statuses, status)
+			continue
+		}
+
+		// Stakers that were not rewarded (e.g. insufficient uptime) have no reward UTXOs
+		if len(txRewards) == 0 {
+			status.Status = "not_rewarded"
+			statuses = append(statuses, status)
+			continue
+		}
+
+		for _, parsed := range txRewards {
+			rewards = append(rewards, PChainReward{
+				TxID:              t.txID,
+				StakerTxID:        t.stakerTxID,
+				UTXOID:            parsed.UTXOID,
+				OutputIndex:       parsed.OutputIndex,
+				AssetID:           parsed.AssetID,
+				Amount:            parsed.Amount,
+				Addresses:         parsed.Addresses,
+				Threshold:         parsed.Threshold,
+				Locktime:          parsed.Locktime,
+				StakeableLocktime: parsed.StakeableLocktime,
+				BlockNumber:       t.blockNumber,
+				BlockTime:         t.blockTime,
+				PChainID:          pchainID,
+			})
+		}
+		status.Status = "rewarded"
+		statuses = append(statuses, status)
+	}
+
+	// Rewards go in before their status so a tx is never marked synced without its rows
+	if len(rewards) > 0 {
+		if err := InsertPChainRewards(ctx, conn, rewards); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := InsertPChainRewardSync(ctx, conn, statuses); err != nil {
+		return 0, err
+	}
+
+	return len(rewardTxs), nil
+}
+
+// fetchRewardUTXOs fetches and decodes all reward UTXOs for a staker tx
+// Any UTXO that fails to parse fails the whole tx so it is retried rather than recorded incomplete
+func fetchRewardUTXOs(ctx context.Context, fetcher *pchainrpc.Fetcher, stakerTxID string) ([]*pchainrpc.RewardUTXO, error) {
+	response, err := fetcher.GetRewardUTXOs(ctx, stakerTxID)
+	if err != nil {
+		return nil, err
+	}
+
+	rewards := make([]*pchainrpc.RewardUTXO, 0, len(response.UTXOs))
+	for _, utxoHex := range response.UTXOs {
+		parsed, err := pchainrpc.ParseRewardUTXOHex(utxoHex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse reward UTXO: %w", err)
+		}
+		rewards = append(rewards, parsed)
+	}
+
+	return rewards, nil
+}
+
+// InsertPChainRewards inserts reward UTXOs into p_chain_rewards
+func InsertPChainRewards(ctx context.Context, conn clickhouse.Conn, rewards []PChainReward) error {
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO p_chain_rewards (
+		tx_id, staker_tx_id, utxo_id, output_index, asset_id, amount, addresses, threshold, locktime,
+		stakeable_locktime, block_number, block_time, p_chain_id
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	for _, r := range rewards {
+		err = batch.Append(
+			r.TxID,
+			r.StakerTxID,
+			r.UTXOID,
+			r.OutputIndex,
+			r.AssetID,
+			r.Amount,
+			r.Addresses,
+			r.Threshold,
+			r.Locktime,
+			r.StakeableLocktime,
+			r.BlockNumber,
+			r.BlockTime,
+			r.PChainID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to append reward %s: %w", r.UTXOID, err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to send rewards batch: %w", err)
+	}
+	slog.Info("Synced reward UTXOs", "count", len(rewards))
+
+	return nil
+}
+
+// InsertPChainRewardSync records the backfill status of RewardValidator txs
+func InsertPChainRewardSync(ctx context.Context, conn clickhouse.Conn, statuses []PChainRewardSync) error {
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO p_chain_reward_sync (
+		tx_id, staker_tx_id, block_number, status, attempts, last_error, p_chain_id
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	for _, s := range statuses {
+		err = batch.Append(
+			s.TxID,
+			s.StakerTxID,
+			s.BlockNumber,
+			s.Status,
+			s.Attempts,
+			s.LastError,
+			s.PChainID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to append reward sync status %s: %w", s.TxID, err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to send reward sync batch: %w", err)
+	}
+
+	return nil
+}
+
+// MaxSnapshotsPerBatch caps how many (subnet, height) validator sets are fetched per backfill batch
+const MaxSnapshotsPerBatch = 50
+
+// SnapshotHeight is a period boundary and the first P-Chain block at or after it
+type SnapshotHeight struct {
+	Height       uint64
+	SnapshotTime time.Time
+}
+
+// GetSnapshotHeights returns one snapshot height per interval since the start of the P-Chain
+func GetSnapshotHeights(ctx context.Context, conn clickhouse.Conn, pchainID uint32, interval time.Duration) ([]SnapshotHeight, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			toStartOfInterval(block_time, INTERVAL %d SECOND) as period,
+			min(block_number) as height
+		FROM p_chain_txs
+		WHERE p_chain_id = ?
+		GROUP BY period
+		ORDER BY period ASC
+	`, int64(interval.Seconds()))
+
+	rows, err := conn.Query(ctx, query, pchainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshot heights: %w", err)
+	}
+	defer rows.Close()
+
+	var heights []SnapshotHeight
+	for rows.Next() {
+		var h SnapshotHeight
+		if err := rows.Scan(&h.SnapshotTime, &h.Height); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot height: %w", err)
+		}
+		heights = append(heights, h)
+	}
+
+	return heights, rows.Err()
+}
+
+// SyncValidatorSetSnapshots reconstructs missing validator sets at the given heights for each subnet
+// Returns the number of snapshots written (at most MaxSnapshotsPerBatch)
+func SyncValidatorSetSnapshots(ctx context.Context, conn clickhouse.Conn, fetcher *pchainrpc.Fetcher, pchainID uint32, subnetIDs []ids.ID, heights []SnapshotHeight) (int, error) {
+	written := 0
+	for _, subnetID := range subnetIDs {
+		if written >= MaxSnapshotsPerBatch {
+			break
+		}
+
+		// Skip heights before the subnet existed (Primary Network is not in the subnets table)
+		var createdBlock uint64
+		err := conn.QueryRow(ctx, `
+			SELECT created_block FROM subnets FINAL WHERE p_chain_id = ? AND subnet_id = ?
+		`, pchainID, subnetID.String()).Scan(&createdBlock)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return written, fmt.Errorf("failed to get creation block of subnet %s: %w", subnetID, err)
+		}
+
+		done, err := getSnapshottedHeights(ctx, conn, pchainID, subnetID.String())
+		if err != nil {
+			return written, err
+		}
+
+		for _, h := range heights {
+			if written >= MaxSnapshotsPerBatch {
+				break
+			}
+			if h.Height < createdBlock || done[h.Height] {
+				continue
+			}
+
+			validators, err := fetcher.GetValidatorsAt(ctx, h.Height, subnetID)
+			if err != nil {
+				return written, err
+			}
+
+			if err := InsertValidatorSetSnapshot(ctx, conn, pchainID, subnetID.String(), h, validators); err != nil {
+				return written, err
+			}
+			written++
+		}
+	}
+
+	return written, nil
+}
+
+// getSnapshottedHeights returns the heights already captured for a subnet
+func getSnapshottedHeights(ctx context.Context, conn clickhouse.Conn, pchainID uint32, subnetID string) (map[uint64]bool, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT height FROM validator_set_snapshot_heights FINAL
+		WHERE p_chain_id = ? AND subnet_id = ?
+	`, pchainID, subnetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshotted heights: %w", err)
+	}
+	defer rows.Close()
+
+	done := make(map[uint64]bool)
+	for rows.Next() {
+		var height uint64
+		if err := rows.Scan(&height); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshotted height: %w", err)
+		}
+		done[height] = true
+	}
+
+	return done, rows.Err()
+}
+
+// InsertValidatorSetSnapshot writes one validator set and its summary row
+// The summary row goes last so a height is only marked done once its validators are stored
+func InsertValidatorSetSnapshot(ctx context.Context, conn clickhouse.Conn, pchainID uint32, subnetID string, h SnapshotHeight, validators []pchainrpc.ValidatorAtHeight) error {
+	var totalWeight uint64
+	if len(validators) > 0 {
+		batch, err := conn.PrepareBatch(ctx, `INSERT INTO validator_set_snapshots (
+			subnet_id, height, snapshot_time, node_id, weight, public_key, p_chain_id
+		)`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare batch: %w", err)
+		}
+
+		for _, v := range validators {
+			if err := batch.Append(subnetID, h.Height, h.SnapshotTime, v.NodeID, v.Weight, v.PublicKey, pchainID); err != nil {
+				return fmt.Errorf("failed to append validator %s: %w", v.NodeID, err)
+			}
+			totalWeight += v.Weight
+		}
+
+		if err := batch.Send(); err != nil {
+			return fmt.Errorf("failed to send validator set snapshot batch: %w", err)
+		}
+	}
+
+	err := conn.Exec(ctx, `
+		INSERT INTO validator_set_snapshot_heights (
+			subnet_id, height, snapshot_time, validator_count, total_weight, p_chain_id
+		) VALUES (?, ?, ?, ?, ?, ?)
+	`, subnetID, h.Height, h.SnapshotTime, uint32(len(validators)), totalWeight, pchainID)
+	if err != nil {
+		return fmt.Errorf("failed to insert snapshot height: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package pchainsyncer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/pchainrpc"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// subnetOwner is the canonical JSON value of a subnet_owner version. Before conversion to an L1
+// a subnet is controlled by an owner (addresses and threshold), afterwards by a manager contract.
+type subnetOwner struct {
+	Addresses      []string    `json:"addresses,omitempty"`
+	Threshold      json.Number `json:"threshold,omitempty"`
+	Locktime       json.Number `json:"locktime,omitempty"`
+	ManagerChainID string      `json:"managerChainID,omitempty"`
+	ManagerAddress string      `json:"managerAddress,omitempty"`
+}
+
+// subnetOwnerValue canonicalizes an owner object from tx JSON, which ClickHouse may return with
+// quoted numbers, so values read back from p_chain_txs compare equal to freshly parsed ones
+func subnetOwnerValue(ownerJSON []byte) (string, error) {
+	var owner subnetOwner
+	if err := json.Unmarshal(ownerJSON, &owner); err != nil {
+		return "", err
+	}
+	value, err := json.Marshal(subnetOwner{Addresses: owner.Addresses, Threshold: owner.Threshold, Locktime: owner.Locktime})
+	return string(value), err
+}
+
+// subnetManagerValue returns the subnet_owner value of a subnet converted to an L1
+func subnetManagerValue(chainID, address string) string {
+	value, _ := json.Marshal(subnetOwner{ManagerChainID: chainID, ManagerAddress: address})
+	return string(value)
+}
+
+// SubnetOwnerVersions returns the subnet_owner versions set by CreateSubnet, TransferSubnetOwnership
+// and ConvertSubnetToL1 txs in blocks. A CreateSubnet tx ID is the new subnet's ID.
+func SubnetOwnerVersions(blocks []*pchainrpc.JSONBlock) ([]chwrapper.DimensionVersion, error) {
+	var versions []chwrapper.DimensionVersion
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			var subnetID, value string
+			var err error
+
+			switch tx.TxType {
+			case "CreateSubnet":
+				var createSubnet struct {
+					Owner json.RawMessage `json:"owner"`
+				}
+				if err = json.Unmarshal(tx.TxData, &createSubnet); err == nil {
+					subnetID = tx.TxID.String()
+					value, err = subnetOwnerValue(createSubnet.Owner)
+				}
+			case "TransferSubnetOwnership":
+				var transfer struct {
+					SubnetID string          `json:"subnetID"`
+					NewOwner json.RawMessage `json:"newOwner"`
+				}
+				if err = json.Unmarshal(tx.TxData, &transfer); err == nil {
+					subnetID = transfer.SubnetID
+					value, err = subnetOwnerValue(transfer.NewOwner)
+				}
+			case "ConvertSubnetToL1":
+				var convert struct {
+					SubnetID string `json:"subnetID"`
+					ChainID  string `json:"chainID"`
+					Address  string `json:"address"`
+				}
+				if err = json.Unmarshal(tx.TxData, &convert); err == nil {
+					subnetID = convert.SubnetID
+					value = subnetManagerValue(convert.ChainID, convert.Address)
+				}
+			default:
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s tx %s: %w", tx.TxType, tx.TxID, err)
+			}
+
+			versions = append(versions, chwrapper.DimensionVersion{
+				Key:       subnetID,
+				Value:     value,
+				FromBlock: tx.BlockHeight,
+				FromTime:  tx.BlockTime,
+			})
+		}
+	}
+	return versions, nil
+}
+
+// DiscoverSubnetOwnerVersions returns the subnet_owner versions of every ingested tx, to backfill
+// dimension_history for txs ingested before it existed
+func DiscoverSubnetOwnerVersions(ctx context.Context, conn clickhouse.Conn, pchainID uint32) ([]chwrapper.DimensionVersion, error) {
+	query := `
+		SELECT
+			tx_type,
+			if(tx_type = 'CreateSubnet', tx_id, toString(tx_data.subnetID)) as subnet_id,
+			toString(if(tx_type = 'CreateSubnet', tx_data.owner, tx_data.newOwner)) as owner,
+			toString(tx_data.chainID) as manager_chain_id,
+			toString(tx_data.address) as manager_address,
+			block_number,
+			block_time
+		FROM p_chain_txs FINAL
+		WHERE p_chain_id = ?
+		  AND tx_type IN ('CreateSubnet', 'TransferSubnetOwnership', 'ConvertSubnetToL1')
+		ORDER BY block_number
+	`
+
+	rows, err := conn.Query(ctx, query, pchainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subnet owner txs: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []chwrapper.DimensionVersion
+	for rows.Next() {
+		var txType, subnetID, owner, managerChainID, managerAddress string
+		var blockNumber uint64
+		var blockTime time.Time
+		if err := rows.Scan(&txType, &subnetID, &owner, &managerChainID, &managerAddress, &blockNumber, &blockTime); err != nil {
+			return nil, fmt.Errorf("failed to scan subnet owner row: %w", err)
+		}
+
+		var value string
+		if txType == "ConvertSubnetToL1" {
+			value = subnetManagerValue(managerChainID, managerAddress)
+		} else if value, err = subnetOwnerValue([]byte(owner)); err != nil {
+			chainLogger(pchainID).Warn("Failed to parse subnet owner", "subnet_id", subnetID, "block", blockNumber, "error", err)
+			continue
+		}
+
+		versions = append(versions, chwrapper.DimensionVersion{
+			Key:       subnetID,
+			Value:     value,
+			FromBlock: blockNumber,
+			FromTime:  blockTime,
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("subnet owner rows error: %w", err)
+	}
+	return versions, nil
+}
@@ -0,0 +1,123 @@
+package pchainsyncer
+
+import (
+	"fmt"
+	"icicle/pkg/pchainrpc"
+
+	"github.com/ava-labs/avalanchego/ids"
+)
+
+// Storage modes of p_chain_txs
+const (
+	TxStorageJSON  = "json"  // Whole unsigned tx as JSON in tx_data
+	TxStorageTyped = "typed" // Extracted fields in typed columns, tx_data left empty
+	TxStorageBoth  = "both"  // Both of the above
+)
+
+// CheckTxStorage returns an error if storage is not a storage mode, empty meaning TxStorageJSON
+func CheckTxStorage(storage string) error {
+	switch storage {
+	case "", TxStorageJSON, TxStorageTyped, TxStorageBoth:
+		return nil
+	}
+	return fmt.Errorf("unknown tx storage %q, expected %s, %s or %s", storage, TxStorageJSON, TxStorageTyped, TxStorageBoth)
+}
+
+// typedTxColumnNames are the typed columns of p_chain_txs, in the order of typedTxColumns.columns
+const typedTxColumnNames = `node_id, start_time, end_time, weight, subnet_id, blockchain_id, chain_name, vm_id,
+	source_chain, destination_chain, reward_tx_id, asset_id, delegation_shares, validation_id, balance,
+	manager_address, advance_time`
+
+// typedTxColumns holds the typed columns of p_chain_txs for a batch, nil entries are NULL
+type typedTxColumns struct {
+	nodeIDs           [][]byte
+	startTimes        []*uint64
+	endTimes          []*uint64
+	weights           []*uint64
+	subnetIDs         [][]byte
+	blockchainIDs     [][]byte
+	chainNames        []*string
+	vmIDs             [][]byte
+	sourceChains      [][]byte
+	destinationChains [][]byte
+	rewardTxIDs       [][]byte
+	assetIDs          [][]byte
+	delegationShares  []*uint32
+	validationIDs     [][]byte
+	balances          []*uint64
+	managerAddresses  []*string
+	advanceTimes      []*uint64
+}
+
+func newTypedTxColumns(rows int) *typedTxColumns {
+	return &typedTxColumns{
+		nodeIDs:           make([][]byte, 0, rows),
+		startTimes:        make([]*uint64, 0, rows),
+		endTimes:          make([]*uint64, 0, rows),
+		weights:           make([]*uint64, 0, rows),
+		subnetIDs:         make([][]byte, 0, rows),
+		blockchainIDs:     make([][]byte, 0, rows),
+		chainNames:        make([]*string, 0, rows),
+		vmIDs:             make([][]byte, 0, rows),
+		sourceChains:      make([][]byte, 0, rows),
+		destinationChains: make([][]byte, 0, rows),
+		rewardTxIDs:       make([][]byte, 0, rows),
+		assetIDs:          make([][]byte, 0, rows),
+		delegationShares:  make([]*uint32, 0, rows),
+		validationIDs:     make([][]byte, 0, rows),
+		balances:          make([]*uint64, 0, rows),
+		managerAddresses:  make([]*string, 0, rows),
+		advanceTimes:      make([]*uint64, 0, rows),
+	}
+}
+
+// add appends the fields of a tx, all NULL when fields is nil
+func (c *typedTxColumns) add(fields *pchainrpc.NormalizedTx) {
+	if fields == nil {
+		fields = &pchainrpc.NormalizedTx{}
+	}
+	var nodeID []byte
+	if fields.NodeID != nil {
+		nodeID = fields.NodeID.Bytes()
+	}
+	var managerAddress *string
+	if fields.Address != nil {
+		address := string(*fields.Address)
+		managerAddress = &address
+	}
+
+	c.nodeIDs = append(c.nodeIDs, nodeID)
+	c.startTimes = append(c.startTimes, fields.StartTime)
+	c.endTimes = append(c.endTimes, fields.EndTime)
+	c.weights = append(c.weights, fields.Weight)
+	c.subnetIDs = append(c.subnetIDs, nullableID(fields.SubnetID))
+	c.blockchainIDs = append(c.blockchainIDs, nullableID(fields.ChainID))
+	c.chainNames = append(c.chainNames, fields.ChainName)
+	c.vmIDs = append(c.vmIDs, nullableID(fields.VMID))
+	c.sourceChains = append(c.sourceChains, nullableID(fields.SourceChain))
+	c.destinationChains = append(c.destinationChains, nullableID(fields.DestinationChain))
+	c.rewardTxIDs = append(c.rewardTxIDs, nullableID(fields.RewardTxID))
+	c.assetIDs = append(c.assetIDs, nullableID(fields.AssetID))
+	c.delegationShares = append(c.delegationShares, fields.DelegationShares)
+	c.validationIDs = append(c.validationIDs, nullableID(fields.ValidationID))
+	c.balances = append(c.balances, fields.Balance)
+	c.managerAddresses = append(c.managerAddresses, managerAddress)
+	c.advanceTimes = append(c.advanceTimes, fields.Time)
+}
+
+// columns returns the slices in the order of typedTxColumnNames
+func (c *typedTxColumns) columns() []any {
+	return []any{
+		c.nodeIDs, c.startTimes, c.endTimes, c.weights, c.subnetIDs, c.blockchainIDs, c.chainNames, c.vmIDs,
+		c.sourceChains, c.destinationChains, c.rewardTxIDs, c.assetIDs, c.delegationShares, c.validationIDs, c.balances,
+		c.managerAddresses, c.advanceTimes,
+	}
+}
+
+// nullableID returns the bytes of id, nil (NULL) when id is nil
+func nullableID(id *ids.ID) []byte {
+	if id == nil {
+		return nil
+	}
+	return idToBytes(*id)
+}
@@ -1,11 +1,16 @@
 package pchainsyncer
 
 import (
-	"icicle/pkg/pchainrpc"
 	"context"
 	"fmt"
-	"log"
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/logging"
+	"icicle/pkg/pchainrpc"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
@@ -16,42 +21,98 @@ import (
 type ValidatorSyncerConfig struct {
 	PChainID      uint32
 	SyncInterval  time.Duration // How often to sync validator state
-	DiscoveryMode string        // "auto" or "manual"
+	DiscoveryMode string        // "auto", "manual" or "hybrid"
+
+	// SnapshotInterval enables backfilling historical validator sets every interval (0 disables)
+	SnapshotInterval time.Duration
+
+	// Subnets restricts auto discovery to these L1 subnets, is the subnet list in manual mode
+	// and is merged with discovered subnets in hybrid mode
+	Subnets []ids.ID
+	// ExcludeSubnets are L1 subnets never synced, in any mode
+	ExcludeSubnets []ids.ID
+
+	// PrioritySubnets have their validators synced every PriorityInterval (default 1 minute),
+	// all other L1 and regular subnets every SubnetInterval (default SyncInterval)
+	PrioritySubnets  []ids.ID
+	PriorityInterval time.Duration
+	SubnetInterval   time.Duration
 }
 
+const (
+	// SubnetSyncTick is how often the subnet scheduler looks for subnets that are due
+	SubnetSyncTick = time.Second
+	// SubnetSyncJitter is the fraction of a subnet's interval its next sync is randomly moved by
+	SubnetSyncJitter = 0.1
+)
+
 // ValidatorSyncer periodically syncs L1 validator state
 type ValidatorSyncer struct {
 	config   ValidatorSyncerConfig
 	fetcher  *pchainrpc.Fetcher
 	conn     clickhouse.Conn
+	logger   *slog.Logger
 	stopCh   chan struct{}
 	stopOnce sync.Once
+	paused   atomic.Bool // No syncs are started while the chain is paused
+
+	// Subnets found by the last sync cycle, synced on their own schedule by runSubnetSync
+	subnetsMu      sync.Mutex
+	l1Subnets      []ids.ID
+	regularSubnets []ids.ID
+
+	// Latest SetL1ValidatorWeight weights, only used by runSubnetSync
+	weights *weightTracker
 }
 
 // NewValidatorSyncer creates a new validator state syncer
 func NewValidatorSyncer(config ValidatorSyncerConfig, fetcher *pchainrpc.Fetcher, conn clickhouse.Conn) *ValidatorSyncer {
-	if config.SyncInterval == 0 {
+	// Non-positive intervals fall back to the defaults, a subnet's first sync is drawn from
+	// rand.N(interval), which panics on them
+	if config.SyncInterval <= 0 {
 		config.SyncInterval = 5 * time.Minute // Default: sync every 5 minutes
 	}
 	if config.DiscoveryMode == "" {
 		config.DiscoveryMode = "auto"
 	}
+	if config.PriorityInterval <= 0 {
+		config.PriorityInterval = time.Minute
+	}
+	if config.SubnetInterval <= 0 {
+		config.SubnetInterval = config.SyncInterval
+	}
 
 	return &ValidatorSyncer{
 		config:  config,
 		fetcher: fetcher,
 		conn:    conn,
+		logger:  logging.Chain("validator_syncer", config.PChainID, ""),
 		stopCh:  make(chan struct{}),
+		weights: newWeightTracker(config.PChainID),
 	}
 }
 
 // Start begins the periodic sync process
 func (vs *ValidatorSyncer) Start(ctx context.Context) {
-	log.Printf("Starting L1 validator state syncer (interval: %v, discovery: %s)", vs.config.SyncInterval, vs.config.DiscoveryMode)
+	vs.logger.Info("Starting L1 validator state syncer", "interval", vs.config.SyncInterval,
+		"discovery", vs.config.DiscoveryMode, "subnets", len(vs.config.Subnets), "excluded", len(vs.config.ExcludeSubnets))
+	vs.logger.Info("Subnet validator sync schedule", "interval", vs.config.SubnetInterval,
+		"priority_subnets", len(vs.config.PrioritySubnets), "priority_interval", vs.config.PriorityInterval)
+
+	// Reward backfill does one RPC per staker tx, so it runs in its own loop
+	// instead of holding up the sync cycle
+	go vs.runRewardBackfill(ctx)
+
+	if vs.config.SnapshotInterval > 0 {
+		go vs.runSnapshotBackfill(ctx)
+	}
+
+	// Subnet validators are synced on per-subnet schedules, spread over their intervals
+	go vs.runSubnetSync(ctx)
 
 	// Do initial sync immediately
 	if err := vs.syncOnce(ctx); err != nil {
-		log.Printf("ERROR: Initial validator state sync failed: %v", err)
+		vs.logger.Error("Initial validator state sync failed", "error", err)
 	}
 
 	// Start periodic sync
@@ -61,14 +122,17 @@ func (vs *ValidatorSyncer) Start(ctx context.Context) {
 	for {
 		select {
 		case <-ticker.C:
+			if vs.paused.Load() {
+				continue
+			}
 			if err := vs.syncOnce(ctx); err != nil {
-				log.Printf("ERROR: Validator state sync failed: %v", err)
+				vs.logger.Error("Validator state sync failed", "error", err)
 			}
 		case <-vs.stopCh:
-			log.Println("Stopping L1 validator state syncer")
+			vs.logger.Info("Stopping L1 validator state syncer")
 			return
 		case <-ctx.Done():
-			log.Println("Context cancelled, stopping validator state syncer")
+			vs.logger.Info("Context cancelled, stopping validator state syncer")
 			return
 		}
 	}
@@ -81,18 +145,128 @@ func (vs *ValidatorSyncer) Stop() {
 	})
 }
 
+// SetPaused stops or resumes starting syncs, e.g. while the chain's RPC is down for maintenance.
+// Syncs already running finish.
+func (vs *ValidatorSyncer) SetPaused(paused bool) {
+	vs.paused.Store(paused)
+}
+
+// waitWhilePaused blocks while the syncer is paused, returning false once it is stopped
+func (vs *ValidatorSyncer) waitWhilePaused(ctx context.Context) bool {
+	for vs.paused.Load() {
+		select {
+		case <-time.After(SubnetSyncTick):
+		case <-vs.stopCh:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// runRewardBackfill fetches reward UTXOs batch by batch, sleeping for the sync interval once caught up
+func (vs *ValidatorSyncer) runRewardBackfill(ctx context.Context) {
+	for {
+		if !vs.waitWhilePaused(ctx) {
+			return
+		}
+		processed, err := SyncPChainRewards(ctx, vs.conn, vs.fetcher, vs.config.PChainID)
+		if err != nil {
+			vs.logger.Warn("Failed to sync P-Chain rewards", "error", err)
+		}
+
+		// Keep going without waiting while there is a backlog
+		if err == nil && processed == RewardTxsPerBatch {
+			select {
+			case <-vs.stopCh:
+				return
+			case <-ctx.Done():
+				return
+			default:
+				continue
+			}
+		}
+
+		select {
+		case <-time.After(vs.config.SyncInterval):
+		case <-vs.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runSnapshotBackfill reconstructs validator sets at every SnapshotInterval boundary for the
+// Primary Network and L1 subnets, sleeping for the sync interval once caught up
+func (vs *ValidatorSyncer) runSnapshotBackfill(ctx context.Context) {
+	vs.logger.Info("Starting validator set snapshot backfill", "interval", vs.config.SnapshotInterval)
+	primarySubnetID, _ := ids.FromString("11111111111111111111111111111111LpoYY")
+
+	for {
+		if !vs.waitWhilePaused(ctx) {
+			return
+		}
+		written, err := vs.syncSnapshotsOnce(ctx, primarySubnetID)
+		if err != nil {
+			vs.logger.Warn("Failed to sync validator set snapshots", "error", err)
+		}
+
+		// Keep going without waiting while there is a backlog
+		if err == nil && written == MaxSnapshotsPerBatch {
+			select {
+			case <-vs.stopCh:
+				return
+			case <-ctx.Done():
+				return
+			default:
+				continue
+			}
+		}
+
+		select {
+		case <-time.After(vs.config.SyncInterval):
+		case <-vs.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// syncSnapshotsOnce writes up to MaxSnapshotsPerBatch missing validator set snapshots
+func (vs *ValidatorSyncer) syncSnapshotsOnce(ctx context.Context, primarySubnetID ids.ID) (int, error) {
+	heights, err := GetSnapshotHeights(ctx, vs.conn, vs.config.PChainID, vs.config.SnapshotInterval)
+	if err != nil {
+		return 0, err
+	}
+
+	l1Subnets, err := GetL1Subnets(ctx, vs.conn, vs.config.PChainID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get L1 subnets: %w", err)
+	}
+
+	subnets := append([]ids.ID{primarySubnetID}, l1Subnets...)
+	written, err := SyncValidatorSetSnapshots(ctx, vs.conn, vs.fetcher, vs.config.PChainID, subnets, heights)
+	if written > 0 {
+		vs.logger.Info("Wrote validator set snapshots", "count", written)
+	}
+	return written, err
+}
+
 // syncOnce performs a single sync cycle
 func (vs *ValidatorSyncer) syncOnce(ctx context.Context) error {
 	startTime := time.Now()
-	log.Println("Starting validator state sync cycle...")
+	vs.logger.Info("Starting validator state sync cycle")
 
 	// Step 0: Insert Primary Network (genesis subnet) if first run
 	if err := InsertPrimaryNetwork(ctx, vs.conn, vs.config.PChainID); err != nil {
 		// Ignore duplicate key errors (already exists)
-		log.Printf("Primary Network already exists or error: %v", err)
+		vs.logger.Debug("Primary Network already exists or error", "error", err)
 	}
 	if err := InsertPrimaryNetworkChains(ctx, vs.conn, vs.config.PChainID); err != nil {
-		log.Printf("Primary Network chains already exist or error: %v", err)
+		vs.logger.Debug("Primary Network chains already exist or error", "error", err)
 	}
 
 	// Step 1: Discover and populate all subnets
@@ -105,7 +279,7 @@ func (vs *ValidatorSyncer) syncOnce(ctx context.Context) error {
 		if err := InsertSubnets(ctx, vs.conn, allSubnets); err != nil {
 			return fmt.Errorf("failed to insert subnets: %w", err)
 		}
-		log.Printf("Discovered and updated %d total subnet(s)", len(allSubnets))
+		vs.logger.Info("Discovered and updated subnets", "count", len(allSubnets))
 	}
 
 	// Step 2: Discover and populate subnet chains
@@ -118,18 +292,18 @@ func (vs *ValidatorSyncer) syncOnce(ctx context.Context) error {
 		if err := InsertSubnetChains(ctx, vs.conn, chains); err != nil {
 			return fmt.Errorf("failed to insert subnet chains: %w", err)
 		}
-		log.Printf("Discovered and updated %d subnet chain(s)", len(chains))
+		vs.logger.Info("Discovered and updated subnet chains", "count", len(chains))
 	}
 
 	// Step 2.5: Discover and populate historical L1 validators from transactions
 	historicalValidators, err := DiscoverL1ValidatorHistory(ctx, vs.conn, vs.config.PChainID)
 	if err != nil {
-		log.Printf("WARNING: Failed to discover historical L1 validators: %v", err)
+		vs.logger.Warn("Failed to discover historical L1 validators", "error", err)
 	} else if len(historicalValidators) > 0 {
 		if err := InsertL1ValidatorHistory(ctx, vs.conn, historicalValidators); err != nil {
-			log.Printf("WARNING: Failed to insert historical L1 validators: %v", err)
+			vs.logger.Warn("Failed to insert historical L1 validators", "error", err)
 		} else {
-			log.Printf("Discovered %d historical L1 validators from transactions", len(historicalValidators))
+			vs.logger.Info("Discovered historical L1 validators from transactions", "count", len(historicalValidators))
 		}
 	}
 
@@ -137,9 +311,9 @@ func (vs *ValidatorSyncer) syncOnce(ctx context.Context) error {
 	primarySubnetID, _ := ids.FromString("11111111111111111111111111111111LpoYY")
 	primaryValidatorCount, err := vs.syncSubnetValidators(ctx, primarySubnetID)
 	if err != nil {
-		log.Printf("WARNING: Failed to sync Primary Network validators: %v", err)
+		vs.logger.Warn("Failed to sync Primary Network validators", "error", err)
 	} else {
-		log.Printf("Synced %d Primary Network validators", primaryValidatorCount)
+		vs.logger.Info("Synced Primary Network validators", "count", primaryValidatorCount)
 	}
 
 	// Step 4: Discover L1 subnets for validator syncing
@@ -148,7 +322,7 @@ func (vs *ValidatorSyncer) syncOnce(ctx context.Context) error {
 		return fmt.Errorf("failed to discover L1 subnets: %w", err)
 	}
 
-	log.Printf("Found %d L1 subnet(s) to sync validators", len(l1Subnets))
+	vs.logger.Info("Found L1 subnets to sync validators", "count", len(l1Subnets))
 
 	// Step 5: Discover regular/elastic subnets for validator syncing
 	regularSubnets, err := vs.discoverRegularSubnets(ctx)
@@ -156,98 +330,263 @@ func (vs *ValidatorSyncer) syncOnce(ctx context.Context) error {
 		return fmt.Errorf("failed to discover regular subnets: %w", err)
 	}
 
-	log.Printf("Found %d regular/elastic subnet(s) to sync validators", len(regularSubnets))
+	vs.logger.Info("Found regular/elastic subnets to sync validators", "count", len(regularSubnets))
 
-	// Step 6: For each L1 subnet, fetch and update validator state
-	totalValidators := primaryValidatorCount
-	l1ValidatorCount := 0
-	for _, subnet := range l1Subnets {
-		validatorCount, err := vs.syncSubnetValidators(ctx, subnet)
-		if err != nil {
-			log.Printf("WARNING: Failed to sync validators for subnet %s: %v", subnet, err)
-			continue
-		}
-		l1ValidatorCount += validatorCount
-		totalValidators += validatorCount
-	}
+	// Step 6: Hand the subnets to runSubnetSync, which syncs their validators on its own schedule
+	vs.setScheduledSubnets(l1Subnets, regularSubnets)
 
-	// Step 7: For each regular subnet, fetch and update validator state
-	regularValidatorCount := 0
-	for _, subnet := range regularSubnets {
-		validatorCount, err := vs.syncSubnetValidators(ctx, subnet)
-		if err != nil {
-			log.Printf("WARNING: Failed to sync validators for subnet %s: %v", subnet, err)
-			continue
-		}
-		regularValidatorCount += validatorCount
-		totalValidators += validatorCount
-	}
-
-	// Step 8: Sync balance transactions for L1 validators
+	// Step 7: Sync balance transactions for L1 validators
 	if err := SyncL1ValidatorBalanceTxs(ctx, vs.conn, vs.config.PChainID); err != nil {
-		log.Printf("WARNING: Failed to sync L1 validator balance transactions: %v", err)
+		vs.logger.Warn("Failed to sync L1 validator balance transactions", "error", err)
 	}
 
-	// Step 9: Sync validator refunds (from DisableL1Validator transactions)
+	// Step 8: Sync validator refunds (from DisableL1Validator transactions)
 	if err := SyncL1ValidatorRefunds(ctx, vs.conn, vs.fetcher, vs.config.PChainID); err != nil {
-		log.Printf("WARNING: Failed to sync L1 validator refunds: %v", err)
+		vs.logger.Warn("Failed to sync L1 validator refunds", "error", err)
 	}
 
-	// Step 10: Calculate and update L1 fee statistics
+	// Step 9: Calculate and update L1 fee statistics
 	feeStats, err := CalculateL1FeeStats(ctx, vs.conn, vs.config.PChainID)
 	if err != nil {
-		log.Printf("WARNING: Failed to calculate L1 fee stats: %v", err)
+		vs.logger.Warn("Failed to calculate L1 fee stats", "error", err)
 	} else if len(feeStats) > 0 {
 		if err := InsertL1FeeStats(ctx, vs.conn, feeStats); err != nil {
-			log.Printf("WARNING: Failed to insert L1 fee stats: %v", err)
+			vs.logger.Warn("Failed to insert L1 fee stats", "error", err)
 		} else {
-			log.Printf("Updated fee stats for %d L1 subnet(s)", len(feeStats))
+			vs.logger.Info("Updated L1 fee stats", "subnets", len(feeStats))
 		}
 	}
 
-	// Step 11: Update per-validator fee statistics
+	// Step 10: Update per-validator fee statistics
 	if err := UpdatePerValidatorFeeStats(ctx, vs.conn, vs.config.PChainID); err != nil {
-		log.Printf("WARNING: Failed to update per-validator fee stats: %v", err)
+		vs.logger.Warn("Failed to update per-validator fee stats", "error", err)
 	}
 
 	duration := time.Since(startTime)
-	log.Printf("Validator state sync completed: %d validators (%d Primary Network, %d across %d L1 subnets, %d across %d regular subnets) in %v",
-		totalValidators, primaryValidatorCount, l1ValidatorCount, len(l1Subnets), regularValidatorCount, len(regularSubnets), duration)
+	vs.logger.Info("Validator state sync completed", "primary_validators", primaryValidatorCount,
+		"l1_subnets", len(l1Subnets), "regular_subnets", len(regularSubnets), "elapsed", duration)
 
 	return nil
 }
 
-// discoverL1Subnets discovers L1 subnets based on the configured discovery mode
+// setScheduledSubnets replaces the subnets runSubnetSync syncs
+func (vs *ValidatorSyncer) setScheduledSubnets(l1Subnets, regularSubnets []ids.ID) {
+	vs.subnetsMu.Lock()
+	defer vs.subnetsMu.Unlock()
+	vs.l1Subnets = l1Subnets
+	vs.regularSubnets = regularSubnets
+}
+
+// scheduledSubnets returns the subnets runSubnetSync syncs
+func (vs *ValidatorSyncer) scheduledSubnets() ([]ids.ID, []ids.ID) {
+	vs.subnetsMu.Lock()
+	defer vs.subnetsMu.Unlock()
+	return vs.l1Subnets, vs.regularSubnets
+}
+
+// subnetInterval returns how often a subnet's validators are synced
+func (vs *ValidatorSyncer) subnetInterval(subnetID ids.ID) time.Duration {
+	if slices.Contains(vs.config.PrioritySubnets, subnetID) {
+		return vs.config.PriorityInterval
+	}
+	return vs.config.SubnetInterval
+}
+
+// runSubnetSync syncs each subnet's validators whenever it is due. A newly scheduled subnet is
+// first due at a random point within its interval, and each sync is moved by up to
+// SubnetSyncJitter of the interval, so getCurrentValidators calls are spread out instead of
+// bursting for every subnet at once.
+func (vs *ValidatorSyncer) runSubnetSync(ctx context.Context) {
+	nextSync := make(map[ids.ID]time.Time)
+
+	ticker := time.NewTicker(SubnetSyncTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !vs.paused.Load() {
+				vs.syncDueSubnets(ctx, nextSync)
+			}
+		case <-vs.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// syncDueSubnets syncs the validators of every subnet whose next sync time has passed,
+// cross-checking L1 weights against the tx history when ingestion is close to the tip
+func (vs *ValidatorSyncer) syncDueSubnets(ctx context.Context, nextSync map[ids.ID]time.Time) {
+	l1Subnets, regularSubnets := vs.scheduledSubnets()
+	isL1 := toSubnetSet(l1Subnets)
+	scheduled := toSubnetSet(regularSubnets)
+	for subnetID := range isL1 {
+		scheduled[subnetID] = true
+	}
+
+	now := time.Now()
+	var due []ids.ID
+	for subnetID := range scheduled {
+		next, ok := nextSync[subnetID]
+		if !ok {
+			nextSync[subnetID] = now.Add(rand.N(vs.subnetInterval(subnetID)))
+			continue
+		}
+		if !now.Before(next) {
+			due = append(due, subnetID)
+		}
+	}
+
+	// Forget subnets that are no longer scheduled, e.g. newly excluded ones
+	for subnetID := range nextSync {
+		if !scheduled[subnetID] {
+			delete(nextSync, subnetID)
+		}
+	}
+
+	if len(due) == 0 {
+		return
+	}
+
+	var checkHeight uint64
+	var checkWeights bool
+	if slices.ContainsFunc(due, func(subnetID ids.ID) bool { return isL1[subnetID] }) {
+		checkHeight, checkWeights = vs.prepareWeightCheck(ctx)
+	}
+
+	divergenceCount := 0
+	for _, subnetID := range due {
+		interval := vs.subnetInterval(subnetID)
+		jitter := time.Duration((rand.Float64()*2 - 1) * SubnetSyncJitter * float64(interval))
+		nextSync[subnetID] = time.Now().Add(interval + jitter)
+
+		states, err := vs.syncSubnetValidatorStates(ctx, subnetID)
+		if err != nil {
+			vs.logger.Warn("Failed to sync subnet validators", "subnet_id", subnetID, "error", err)
+			continue
+		}
+
+		if isL1[subnetID] && checkWeights {
+			divergenceCount += vs.checkSubnetWeights(ctx, subnetID, states, checkHeight)
+		}
+	}
+	if divergenceCount > 0 {
+		vs.logger.Warn("Found L1 validator weight divergences, see l1_validator_weight_divergences", "count", divergenceCount)
+	}
+}
+
+// discoverL1Subnets discovers L1 subnets based on the configured discovery mode,
+// then drops excluded subnets
 func (vs *ValidatorSyncer) discoverL1Subnets(ctx context.Context) ([]ids.ID, error) {
+	var subnetIDs []ids.ID
+
 	switch vs.config.DiscoveryMode {
 	case "auto":
-		// Discover from transactions and update l1_subnets table
-		subnets, err := DiscoverL1SubnetsFromTransactions(ctx, vs.conn, vs.config.PChainID)
+		discovered, err := vs.discoverL1SubnetsFromTransactions(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to discover subnets from transactions: %w", err)
+			return nil, err
 		}
 
-		// Update l1_subnets table
-		if len(subnets) > 0 {
-			if err := InsertL1Subnets(ctx, vs.conn, subnets); err != nil {
-				return nil, fmt.Errorf("failed to insert L1 subnets: %w", err)
+		// An allow list narrows auto discovery down to the listed subnets
+		if len(vs.config.Subnets) > 0 {
+			allowed := toSubnetSet(vs.config.Subnets)
+			for _, subnetID := range discovered {
+				if allowed[subnetID] {
+					subnetIDs = append(subnetIDs, subnetID)
+				}
 			}
+		} else {
+			subnetIDs = discovered
 		}
 
-		// Return subnet IDs
-		subnetIDs := make([]ids.ID, len(subnets))
-		for i, subnet := range subnets {
-			subnetIDs[i] = subnet.SubnetID
+	case "manual":
+		// Use the configured list, falling back to the l1_subnets table (manually configured)
+		if len(vs.config.Subnets) > 0 {
+			subnetIDs = vs.config.Subnets
+		} else {
+			var err error
+			subnetIDs, err = GetL1Subnets(ctx, vs.conn, vs.config.PChainID)
+			if err != nil {
+				return nil, err
+			}
 		}
-		return subnetIDs, nil
 
-	case "manual":
-		// Read from l1_subnets table (manually configured)
-		return GetL1Subnets(ctx, vs.conn, vs.config.PChainID)
+	case "hybrid":
+		// Discovered subnets plus configured ones that discovery doesn't find
+		discovered, err := vs.discoverL1SubnetsFromTransactions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		subnetIDs = discovered
+		seen := toSubnetSet(discovered)
+		for _, subnetID := range vs.config.Subnets {
+			if !seen[subnetID] {
+				subnetIDs = append(subnetIDs, subnetID)
+			}
+		}
 
 	default:
 		return nil, fmt.Errorf("unknown discovery mode: %s", vs.config.DiscoveryMode)
 	}
+
+	if len(vs.config.ExcludeSubnets) == 0 {
+		return subnetIDs, nil
+	}
+
+	excluded := toSubnetSet(vs.config.ExcludeSubnets)
+	filtered := make([]ids.ID, 0, len(subnetIDs))
+	for _, subnetID := range subnetIDs {
+		if !excluded[subnetID] {
+			filtered = append(filtered, subnetID)
+		}
+	}
+	return filtered, nil
+}
+
+// discoverL1SubnetsFromTransactions discovers L1 subnets from transactions and updates the l1_subnets table
+func (vs *ValidatorSyncer) discoverL1SubnetsFromTransactions(ctx context.Context) ([]ids.ID, error) {
+	subnets, err := DiscoverL1SubnetsFromTransactions(ctx, vs.conn, vs.config.PChainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover subnets from transactions: %w", err)
+	}
+
+	// Update l1_subnets table
+	if len(subnets) > 0 {
+		if err := InsertL1Subnets(ctx, vs.conn, subnets); err != nil {
+			return nil, fmt.Errorf("failed to insert L1 subnets: %w", err)
+		}
+	}
+
+	// Return subnet IDs
+	subnetIDs := make([]ids.ID, len(subnets))
+	for i, subnet := range subnets {
+		subnetIDs[i] = subnet.SubnetID
+	}
+	return subnetIDs, nil
+}
+
+// toSubnetSet builds a lookup set from a list of subnet IDs
+func toSubnetSet(subnetIDs []ids.ID) map[ids.ID]bool {
+	set := make(map[ids.ID]bool, len(subnetIDs))
+	for _, subnetID := range subnetIDs {
+		set[subnetID] = true
+	}
+	return set
+}
+
+// parseSubnetIDs parses CB58 subnet IDs from config
+func parseSubnetIDs(subnetIDStrs []string) ([]ids.ID, error) {
+	subnetIDs := make([]ids.ID, 0, len(subnetIDStrs))
+	for _, subnetIDStr := range subnetIDStrs {
+		subnetID, err := ids.FromString(subnetIDStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse subnet ID %s: %w", subnetIDStr, err)
+		}
+		subnetIDs = append(subnetIDs, subnetID)
+	}
+	return subnetIDs, nil
 }
 
 // discoverRegularSubnets discovers regular and elastic subnets from the subnets table
@@ -274,7 +613,7 @@ func (vs *ValidatorSyncer) discoverRegularSubnets(ctx context.Context) ([]ids.ID
 
 		subnetID, err := ids.FromString(subnetIDStr)
 		if err != nil {
-			log.Printf("WARNING: Failed to parse subnet ID %s: %v", subnetIDStr, err)
+			vs.logger.Warn("Failed to parse subnet ID", "subnet_id", subnetIDStr, "error", err)
 			continue
 		}
 
@@ -290,15 +629,21 @@ func (vs *ValidatorSyncer) discoverRegularSubnets(ctx context.Context) ([]ids.ID
 
 // syncSubnetValidators fetches and syncs validator state for a specific subnet
 func (vs *ValidatorSyncer) syncSubnetValidators(ctx context.Context, subnetID ids.ID) (int, error) {
+	states, err := vs.syncSubnetValidatorStates(ctx, subnetID)
+	return len(states), err
+}
+
+// syncSubnetValidatorStates fetches and syncs validator state for a specific subnet and returns it
+func (vs *ValidatorSyncer) syncSubnetValidatorStates(ctx context.Context, subnetID ids.ID) ([]*pchainrpc.ValidatorState, error) {
 	// Fetch current validators from RPC
 	response, err := vs.fetcher.GetCurrentValidators(ctx, subnetID.String())
 	if err != nil {
-		return 0, fmt.Errorf("failed to fetch validators: %w", err)
+		return nil, fmt.Errorf("failed to fetch validators: %w", err)
 	}
 
 	if len(response.Validators) == 0 {
-		log.Printf("No validators found for subnet %s", subnetID)
-		return 0, nil
+		vs.logger.Info("No validators found for subnet", "subnet_id", subnetID)
+		return nil, nil
 	}
 
 	// Parse validator info into ValidatorState
@@ -307,7 +652,7 @@ func (vs *ValidatorSyncer) syncSubnetValidators(ctx context.Context, subnetID id
 	for _, validatorInfo := range response.Validators {
 		state, err := pchainrpc.ParseValidatorInfo(validatorInfo, subnetID)
 		if err != nil {
-			log.Printf("WARNING: Failed to parse validator info for %s: %v", validatorInfo.NodeID, err)
+			vs.logger.Warn("Failed to parse validator info", "node_id", validatorInfo.NodeID, "error", err)
 			continue
 		}
 		states = append(states, state)
@@ -317,17 +662,55 @@ func (vs *ValidatorSyncer) syncSubnetValidators(ctx context.Context, subnetID id
 	// Insert into database
 	if len(states) > 0 {
 		if err := InsertValidatorStates(ctx, vs.conn, vs.config.PChainID, states); err != nil {
-			return 0, fmt.Errorf("failed to insert validator states: %w", err)
+			return nil, fmt.Errorf("failed to insert validator states: %w", err)
 		}
 	}
 
 	// Mark validators that are no longer in the RPC response as inactive
 	// This handles validators whose staking period has ended
 	if err := MarkInactiveValidators(ctx, vs.conn, vs.config.PChainID, subnetID.String(), activeValidationIDs); err != nil {
-		log.Printf("WARNING: Failed to mark inactive validators for subnet %s: %v", subnetID, err)
+		vs.logger.Warn("Failed to mark inactive validators", "subnet_id", subnetID, "error", err)
 		// Don't return error - this is not critical, just log it
 	}
 
-	log.Printf("Synced %d validators for subnet %s", len(states), subnetID)
-	return len(states), nil
+	vs.logger.Info("Synced subnet validators", "count", len(states), "subnet_id", subnetID)
+	return states, nil
+}
+
+// prepareWeightCheck brings the SetL1ValidatorWeight weights up to the ingested P-Chain height
+// and returns that height, or false if the check should be skipped
+func (vs *ValidatorSyncer) prepareWeightCheck(ctx context.Context) (uint64, bool) {
+	watermark, err := chwrapper.GetWatermark(vs.conn, vs.config.PChainID)
+	if err != nil {
+		vs.logger.Warn("Skipping validator weight check, failed to get watermark", "error", err)
+		return 0, false
+	}
+	latest, err := vs.fetcher.GetLatestBlock()
+	if err != nil {
+		vs.logger.Warn("Skipping validator weight check, failed to get latest block", "error", err)
+		return 0, false
+	}
+	if latest-int64(watermark) > WeightCheckMaxLag {
+		return 0, false
+	}
+
+	if err := vs.weights.update(ctx, vs.conn, uint64(watermark)); err != nil {
+		vs.logger.Warn("Skipping validator weight check", "error", err)
+		return 0, false
+	}
+	return uint64(watermark), true
+}
+
+// checkSubnetWeights records divergences between a subnet's live validator weights and
+// its tx history, and returns how many were found
+func (vs *ValidatorSyncer) checkSubnetWeights(ctx context.Context, subnetID ids.ID, states []*pchainrpc.ValidatorState, height uint64) int {
+	divergences, err := CheckL1ValidatorWeights(ctx, vs.conn, vs.config.PChainID, subnetID, states, vs.weights.weights)
+	if err != nil {
+		vs.logger.Warn("Failed to check validator weights", "subnet_id", subnetID, "error", err)
+		return 0
+	}
+	if err := InsertWeightDivergences(ctx, vs.conn, vs.config.PChainID, height, divergences); err != nil {
+		vs.logger.Warn("Failed to record validator weight divergences", "subnet_id", subnetID, "error", err)
+	}
+	return len(divergences)
 }
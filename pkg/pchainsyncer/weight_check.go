@@ -0,0 +1,266 @@
+package pchainsyncer
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"icicle/pkg/pchainrpc"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp/message"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp/payload"
+)
+
+// WeightCheckMaxLag is how far ingestion may trail the chain tip for the weight check to run.
+// Further behind, recent SetL1ValidatorWeight txs are missing and every change would diverge.
+const WeightCheckMaxLag = 10
+
+// Weight divergence issues
+const (
+	IssueWeightMismatch = "weight_mismatch" // Live weight differs from the tx history
+	IssueMissingLive    = "missing_live"    // Tx history has the validator, getCurrentValidators doesn't
+	IssueMissingHistory = "missing_history" // getCurrentValidators has the validator, no creation tx was ingested
+)
+
+// WeightDivergence is an L1 validator whose live weight doesn't match its tx history
+type WeightDivergence struct {
+	SubnetID       string
+	ValidationID   string
+	NodeID         string
+	Issue          string
+	ExpectedWeight uint64
+	LiveWeight     uint64
+}
+
+// validatorWeight is a validator's weight at a given SetL1ValidatorWeight nonce
+type validatorWeight struct {
+	nodeID string
+	nonce  uint64
+	weight uint64
+}
+
+// weightTracker keeps the latest weight set by SetL1ValidatorWeight txs per validation ID,
+// reading only the txs ingested since its last update
+type weightTracker struct {
+	pchainID uint32
+	height   uint64 // Highest block number whose txs have been read
+	latest   map[string]validatorWeight
+	weights  map[string]uint64
+}
+
+func newWeightTracker(pchainID uint32) *weightTracker {
+	return &weightTracker{
+		pchainID: pchainID,
+		latest:   make(map[string]validatorWeight),
+		weights:  make(map[string]uint64),
+	}
+}
+
+// update reads the SetL1ValidatorWeight txs in blocks (height, toHeight]
+func (t *weightTracker) update(ctx context.Context, conn clickhouse.Conn, toHeight uint64) error {
+	if toHeight <= t.height {
+		return nil
+	}
+
+	query := `
+		SELECT tx_id, toString(tx_data.message) as message
+		FROM p_chain_txs
+		WHERE p_chain_id = ? AND tx_type = 'SetL1ValidatorWeight'
+		  AND block_number > ? AND block_number <= ?
+		ORDER BY block_number ASC
+	`
+
+	rows, err := conn.Query(ctx, query, t.pchainID, t.height, toHeight)
+	if err != nil {
+		return fmt.Errorf("failed to query SetL1ValidatorWeight txs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var txID, messageHex string
+		if err := rows.Scan(&txID, &messageHex); err != nil {
+			return fmt.Errorf("failed to scan SetL1ValidatorWeight row: %w", err)
+		}
+
+		msg, err := parseL1ValidatorWeight(messageHex)
+		if err != nil {
+			chainLogger(t.pchainID).Warn("Failed to parse SetL1ValidatorWeight tx", "tx", txID, "error", err)
+			continue
+		}
+
+		// The P-Chain only accepts increasing nonces, so the highest one is the current weight
+		validationID := msg.ValidationID.String()
+		if current, ok := t.latest[validationID]; ok && current.nonce > msg.Nonce {
+			continue
+		}
+		t.latest[validationID] = validatorWeight{nonce: msg.Nonce, weight: msg.Weight}
+		t.weights[validationID] = msg.Weight
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("SetL1ValidatorWeight rows error: %w", err)
+	}
+
+	t.height = toHeight
+	return nil
+}
+
+// parseL1ValidatorWeight parses the signed Warp message of a SetL1ValidatorWeight tx
+func parseL1ValidatorWeight(messageHex string) (*message.L1ValidatorWeight, error) {
+	messageBytes, err := hex.DecodeString(strings.TrimPrefix(messageHex, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode message hex: %w", err)
+	}
+
+	warpMsg, err := warp.ParseMessage(messageBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse warp message: %w", err)
+	}
+
+	addressedCall, err := payload.ParseAddressedCall(warpMsg.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse AddressedCall: %w", err)
+	}
+
+	return message.ParseL1ValidatorWeight(addressedCall.Payload)
+}
+
+// expectedL1ValidatorWeights returns each validator's expected weight from its creation tx and the
+// latest SetL1ValidatorWeight update, keyed by validation ID. Removed validators have weight 0.
+func expectedL1ValidatorWeights(ctx context.Context, conn clickhouse.Conn, pchainID uint32, subnetID string, updates map[string]uint64) (map[string]validatorWeight, error) {
+	query := `
+		SELECT validation_id, node_id, initial_weight
+		FROM l1_validator_history FINAL
+		WHERE p_chain_id = ? AND subnet_id = ?
+	`
+
+	rows, err := conn.Query(ctx, query, pchainID, subnetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query validator history: %w", err)
+	}
+	defer rows.Close()
+
+	expected := make(map[string]validatorWeight)
+	for rows.Next() {
+		var validationID, nodeID string
+		var weight uint64
+		if err := rows.Scan(&validationID, &nodeID, &weight); err != nil {
+			return nil, fmt.Errorf("failed to scan validator history row: %w", err)
+		}
+		if updated, ok := updates[validationID]; ok {
+			weight = updated
+		}
+		expected[validationID] = validatorWeight{nodeID: nodeID, weight: weight}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("validator history rows error: %w", err)
+	}
+
+	return expected, nil
+}
+
+// compareL1ValidatorWeights compares expected weights (keyed by validation ID) with a live validator set
+func compareL1ValidatorWeights(subnetID string, expected map[string]validatorWeight, live []*pchainrpc.ValidatorState) []WeightDivergence {
+	var divergences []WeightDivergence
+
+	seen := make(map[string]bool, len(live))
+	for _, state := range live {
+		validationID := state.ValidationID.String()
+		seen[validationID] = true
+
+		exp, ok := expected[validationID]
+		switch {
+		case !ok:
+			divergences = append(divergences, WeightDivergence{
+				SubnetID:     subnetID,
+				ValidationID: validationID,
+				NodeID:       state.NodeID.String(),
+				Issue:        IssueMissingHistory,
+				LiveWeight:   state.Weight,
+			})
+		case exp.weight != state.Weight:
+			divergences = append(divergences, WeightDivergence{
+				SubnetID:       subnetID,
+				ValidationID:   validationID,
+				NodeID:         state.NodeID.String(),
+				Issue:          IssueWeightMismatch,
+				ExpectedWeight: exp.weight,
+				LiveWeight:     state.Weight,
+			})
+		}
+	}
+
+	for validationID, exp := range expected {
+		if seen[validationID] || exp.weight == 0 {
+			continue
+		}
+		divergences = append(divergences, WeightDivergence{
+			SubnetID:       subnetID,
+			ValidationID:   validationID,
+			NodeID:         exp.nodeID,
+			Issue:          IssueMissingLive,
+			ExpectedWeight: exp.weight,
+		})
+	}
+
+	sort.Slice(divergences, func(i, j int) bool {
+		return divergences[i].ValidationID < divergences[j].ValidationID
+	})
+	return divergences
+}
+
+// CheckL1ValidatorWeights compares an L1's live validator set with the weights its tx history implies
+func CheckL1ValidatorWeights(ctx context.Context, conn clickhouse.Conn, pchainID uint32, subnetID ids.ID, live []*pchainrpc.ValidatorState, updates map[string]uint64) ([]WeightDivergence, error) {
+	expected, err := expectedL1ValidatorWeights(ctx, conn, pchainID, subnetID.String(), updates)
+	if err != nil {
+		return nil, err
+	}
+
+	// Subnets that aren't L1s have no validator history and nothing to compare against
+	if len(expected) == 0 {
+		return nil, nil
+	}
+
+	return compareL1ValidatorWeights(subnetID.String(), expected, live), nil
+}
+
+// InsertWeightDivergences records weight divergences found at a P-Chain height
+func InsertWeightDivergences(ctx context.Context, conn clickhouse.Conn, pchainID uint32, height uint64, divergences []WeightDivergence) error {
+	if len(divergences) == 0 {
+		return nil
+	}
+
+	batch, err := conn.PrepareBatch(ctx, `INSERT INTO l1_validator_weight_divergences (
+		check_time, subnet_id, validation_id, node_id, issue,
+		expected_weight, live_weight, p_chain_height, p_chain_id
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	now := time.Now()
+	for _, d := range divergences {
+		err = batch.Append(
+			now,
+			d.SubnetID,
+			d.ValidationID,
+			d.NodeID,
+			d.Issue,
+			d.ExpectedWeight,
+			d.LiveWeight,
+			height,
+			pchainID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to append divergence for %s: %w", d.ValidationID, err)
+		}
+	}
+
+	return batch.Send()
+}
@@ -0,0 +1,110 @@
+package proposervm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/indexer"
+	"github.com/ava-labs/avalanchego/vms/proposervm/block"
+)
+
+// MaxContainersPerRequest is the index API's limit for index.getContainerRange
+const MaxContainersPerRequest = 1024
+
+// HeightFunc returns the height of an inner (VM-level) block
+type HeightFunc func(innerBlock []byte) (uint64, error)
+
+// Client reads Snowman++ (proposervm) wrapped blocks from a node's index API to attribute block producers
+type Client struct {
+	index  *indexer.Client
+	height HeightFunc
+
+	// The index API is ordered by acceptance, not height. Index i normally holds height i+1,
+	// but nodes that enabled indexing late start further along; offset is learned on first use.
+	mu     sync.Mutex
+	offset int64
+}
+
+// NewClient creates a client for an index API endpoint, e.g. http://127.0.0.1:9650/ext/index/P/block
+func NewClient(indexURL string, height HeightFunc) *Client {
+	return &Client{
+		index:  indexer.NewClient(indexURL),
+		height: height,
+	}
+}
+
+// GetProposers returns the proposer NodeID of each block in [from, to]. Blocks without a signed
+// proposer (pre-fork blocks, option blocks, blocks any validator could build) are omitted.
+func (c *Client) GetProposers(ctx context.Context, from, to uint64) (map[uint64]string, error) {
+	proposers := make(map[uint64]string)
+
+	for start := from; start <= to; start += MaxContainersPerRequest {
+		end := min(to, start+MaxContainersPerRequest-1)
+		if err := c.getProposerRange(ctx, start, end, proposers); err != nil {
+			return nil, err
+		}
+	}
+
+	return proposers, nil
+}
+
+// getProposerRange fills proposers for [from, to], re-learning the index offset once on mismatch
+func (c *Client) getProposerRange(ctx context.Context, from, to uint64, proposers map[uint64]string) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		c.mu.Lock()
+		offset := c.offset
+		c.mu.Unlock()
+
+		startIndex := int64(from) - 1 + offset
+		if startIndex < 0 {
+			return fmt.Errorf("block %d is before the first indexed block", from)
+		}
+
+		containers, err := c.index.GetContainerRange(ctx, uint64(startIndex), int(to-from+1))
+		if err != nil {
+			return fmt.Errorf("failed to get containers at index %d: %w", startIndex, err)
+		}
+		if len(containers) == 0 {
+			return fmt.Errorf("no containers at index %d", startIndex)
+		}
+
+		// Check the first container is the block we expect before trusting the rest
+		inner, _ := unwrap(containers[0].Bytes)
+		height, err := c.height(inner)
+		if err != nil {
+			return fmt.Errorf("failed to get height of container at index %d: %w", startIndex, err)
+		}
+		if height != from {
+			c.mu.Lock()
+			c.offset = startIndex - (int64(height) - 1)
+			c.mu.Unlock()
+			continue
+		}
+
+		for i, container := range containers {
+			if _, proposer := unwrap(container.Bytes); proposer != "" {
+				proposers[from+uint64(i)] = proposer
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("index API heights don't line up with block %d", from)
+}
+
+// unwrap returns the inner block bytes and proposer NodeID of a container.
+// Pre-fork containers are not wrapped and are returned as-is with no proposer.
+func unwrap(container []byte) ([]byte, string) {
+	blk, err := block.ParseWithoutVerification(container)
+	if err != nil {
+		return container, ""
+	}
+
+	signed, ok := blk.(block.SignedBlock)
+	if !ok || signed.Proposer() == ids.EmptyNodeID {
+		return blk.Block(), ""
+	}
+	return blk.Block(), signed.Proposer().String()
+}
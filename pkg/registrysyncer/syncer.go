@@ -4,8 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"icicle/pkg/chwrapper"
 	"io/fs"
-	"log"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -21,7 +22,8 @@ const (
 
 // SyncRegistry clones the L1 registry and ingests metadata into ClickHouse
 func SyncRegistry(ctx context.Context, conn clickhouse.Conn) error {
-	log.Println("[Registry] Starting L1 registry sync...")
+	logger := slog.With("component", "registry")
+	logger.Info("Starting L1 registry sync")
 
 	// Create temp dir
 	tempDir, err := os.MkdirTemp("", TempDirPrefix)
@@ -31,7 +33,7 @@ func SyncRegistry(ctx context.Context, conn clickhouse.Conn) error {
 	defer os.RemoveAll(tempDir)
 
 	// Clone repo
-	log.Printf("[Registry] Cloning %s to %s", RegistryRepoURL, tempDir)
+	logger.Info("Cloning registry", "repo", RegistryRepoURL, "dir", tempDir)
 	cmd := exec.CommandContext(ctx, "git", "clone", "--depth=1", RegistryRepoURL, tempDir)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("git clone failed: %s: %w", string(output), err)
@@ -55,13 +57,13 @@ func SyncRegistry(ctx context.Context, conn clickhouse.Conn) error {
 		// Read and parse chain.json
 		content, err := os.ReadFile(path)
 		if err != nil {
-			log.Printf("[Registry] Failed to read %s: %v", path, err)
+			logger.Warn("Failed to read chain metadata", "path", path, "error", err)
 			return nil
 		}
 
 		var chain ChainRegistry
 		if err := json.Unmarshal(content, &chain); err != nil {
-			log.Printf("[Registry] Failed to parse %s: %v", path, err)
+			logger.Warn("Failed to parse chain metadata", "path", path, "error", err)
 			return nil
 		}
 
@@ -76,16 +78,27 @@ func SyncRegistry(ctx context.Context, conn clickhouse.Conn) error {
 		return fmt.Errorf("failed to walk data dir: %w", err)
 	}
 
-	log.Printf("[Registry] Found %d chains metadata", len(chains))
+	logger.Info("Found chain metadata", "chains", len(chains))
 
 	// Insert into ClickHouse
 	if len(chains) > 0 {
 		if err := insertRegistryData(ctx, conn, chains); err != nil {
 			return fmt.Errorf("failed to insert registry data: %w", err)
 		}
+
+		// Version metadata by sync time so past reports keep the names they were built with
+		versions, err := registryVersions(chains)
+		if err != nil {
+			return err
+		}
+		n, err := chwrapper.RecordDimensionVersions(conn, 0, chwrapper.DimensionL1Registry, versions)
+		if err != nil {
+			return fmt.Errorf("failed to record registry versions: %w", err)
+		}
+		logger.Info("Recorded registry versions", "changed", n)
 	}
 
-	log.Println("[Registry] Sync completed successfully")
+	logger.Info("Sync completed successfully")
 	return nil
 }
 
@@ -115,3 +128,26 @@ func insertRegistryData(ctx context.Context, conn clickhouse.Conn, chains []Chai
 	return batch.Send()
 }
 
+// registryVersions returns each chain's metadata as an l1_registry version starting now
+func registryVersions(chains []ChainRegistry) ([]chwrapper.DimensionVersion, error) {
+	now := time.Now().UTC()
+	versions := make([]chwrapper.DimensionVersion, 0, len(chains))
+	for _, chain := range chains {
+		value, err := json.Marshal(map[string]string{
+			"name":        chain.Name,
+			"description": chain.Description,
+			"logo_url":    chain.Logo,
+			"website_url": chain.Website,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode registry version for %s: %w", chain.SubnetID, err)
+		}
+		versions = append(versions, chwrapper.DimensionVersion{
+			Key:      chain.SubnetID,
+			Value:    string(value),
+			FromTime: now,
+		})
+	}
+	return versions, nil
+}
+
@@ -0,0 +1,74 @@
+// Package rpcerr tells permanent RPC errors, which retrying the same request won't fix (a method
+// the node doesn't serve, invalid params, a block beyond the tip), from transient ones, so retry
+// loops fail fast on configuration mistakes instead of backing off for minutes
+package rpcerr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// JSON-RPC 2.0 error codes for requests the node will never accept
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+)
+
+// PermanentError is an error retrying the same request won't fix
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// Permanent marks err as permanent
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent reports whether err, or an error it wraps, is permanent
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// FromCode returns the error for a JSON-RPC error reply, marked permanent when its code or
+// message says the request can't succeed
+func FromCode(code int, message string) error {
+	err := fmt.Errorf("rpc error %d: %s", code, message)
+	if IsPermanentCode(code, message) {
+		return Permanent(err)
+	}
+	return err
+}
+
+// IsPermanentCode reports whether a JSON-RPC error reply means the request can't succeed.
+// Nodes don't agree on codes for unsupported methods, so the message is checked too.
+func IsPermanentCode(code int, message string) bool {
+	switch code {
+	case CodeParseError, CodeInvalidRequest, CodeMethodNotFound, CodeInvalidParams:
+		return true
+	}
+	msg := strings.ToLower(message)
+	return strings.Contains(msg, "method not found") || strings.Contains(msg, "does not exist") ||
+		strings.Contains(msg, "not supported")
+}
+
+// FromStatus returns an error for a non-200 HTTP status, marked permanent for client errors other
+// than timeouts and rate limiting, e.g. a wrong URL path or a missing API key
+func FromStatus(status int) error {
+	err := fmt.Errorf("HTTP %d %s", status, http.StatusText(status))
+	if status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,188 @@
+package spool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrFull is returned by Push when a batch would take the spool over its limit
+var ErrFull = errors.New("spool is full")
+
+// fileSuffix names complete batch files, partly written ones end in .tmp until renamed
+const fileSuffix = ".batch"
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// Spool is a disk-backed FIFO queue of batches, holding the writes a syncer couldn't make while
+// its database was unreachable until they can be replayed in order. Batches are zstd-compressed,
+// one file each, and survive restarts. A nil Spool is disabled and always empty.
+type Spool struct {
+	dir   string
+	limit int64 // Most bytes on disk, 0 is unlimited
+
+	mu    sync.Mutex
+	files []batchFile // Oldest first
+	size  int64
+	next  uint64 // Sequence number of the next pushed batch
+}
+
+// batchFile is a batch on disk
+type batchFile struct {
+	seq  uint64
+	size int64
+}
+
+// Open opens the spool in dir, creating dir if needed and picking up batches left by an earlier
+// run. limit caps the compressed bytes held, 0 for no cap. Returns nil if dir is empty.
+func Open(dir string, limit int64) (*Spool, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+	s := &Spool{dir: dir, limit: max(limit, 0)}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			// Left by a crash during Push, the batch was never queued
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, fileSuffix), 10, 64)
+		if err != nil || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat spooled batch %s: %w", name, err)
+		}
+		s.files = append(s.files, batchFile{seq: seq, size: info.Size()})
+		s.size += info.Size()
+		s.next = max(s.next, seq+1)
+	}
+	sort.Slice(s.files, func(i, j int) bool { return s.files[i].seq < s.files[j].seq })
+	return s, nil
+}
+
+// Push appends a batch to the end of the queue. Returns ErrFull if it doesn't fit.
+func (s *Spool) Push(data []byte) error {
+	if s == nil {
+		return ErrFull
+	}
+	compressed := zstdEncoder.EncodeAll(data, nil)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.limit > 0 && s.size+int64(len(compressed)) > s.limit {
+		return ErrFull
+	}
+
+	// Written under a temporary name and renamed, so a crash never leaves half a batch queued
+	path := s.path(s.next)
+	if err := os.WriteFile(path+".tmp", compressed, 0644); err != nil {
+		os.Remove(path + ".tmp")
+		return fmt.Errorf("failed to write spooled batch: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		os.Remove(path + ".tmp")
+		return fmt.Errorf("failed to write spooled batch: %w", err)
+	}
+
+	s.files = append(s.files, batchFile{seq: s.next, size: int64(len(compressed))})
+	s.size += int64(len(compressed))
+	s.next++
+	return nil
+}
+
+// Peek returns the oldest batch without removing it, nil if the spool is empty
+func (s *Spool) Peek() ([]byte, error) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.files) == 0 {
+		return nil, nil
+	}
+
+	compressed, err := os.ReadFile(s.path(s.files[0].seq))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spooled batch: %w", err)
+	}
+	data, err := zstdDecoder.DecodeAll(compressed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress spooled batch %d: %w", s.files[0].seq, err)
+	}
+	return data, nil
+}
+
+// Pop removes the oldest batch, once it has been replayed
+func (s *Spool) Pop() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.files) == 0 {
+		return nil
+	}
+
+	if err := os.Remove(s.path(s.files[0].seq)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove spooled batch: %w", err)
+	}
+	s.size -= s.files[0].size
+	s.files = s.files[1:]
+	return nil
+}
+
+// Clear removes every batch, e.g. when the data they were written over is rewound
+func (s *Spool) Clear() error {
+	for s.Len() > 0 {
+		if err := s.Pop(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len returns the number of batches queued
+func (s *Spool) Len() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.files)
+}
+
+// Size returns the compressed bytes queued
+func (s *Spool) Size() int64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// path returns the file of the batch with sequence number seq, zero-padded so names sort in order
+func (s *Spool) path(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, fileSuffix))
+}
@@ -0,0 +1,104 @@
+package spool
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushReplaysInOrder(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 0)
+	require.NoError(t, err)
+
+	for i := 0; i < 12; i++ {
+		require.NoError(t, s.Push([]byte(fmt.Sprintf("batch %d", i))))
+	}
+	require.Equal(t, 12, s.Len())
+
+	// Batches pushed before a restart are replayed first, in order
+	s, err = Open(dir, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Push([]byte("batch 12")))
+	for i := 0; i < 13; i++ {
+		data, err := s.Peek()
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("batch %d", i), string(data))
+		require.NoError(t, s.Pop())
+	}
+
+	data, err := s.Peek()
+	require.NoError(t, err)
+	require.Nil(t, data)
+	require.Zero(t, s.Len())
+	require.Zero(t, s.Size())
+}
+
+func TestPushFull(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  int64
+		pushes int
+		queued int
+	}{
+		{"unlimited", 0, 5, 5},
+		{"fits", 1 << 20, 5, 5},
+		{"too small for one", 1, 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Open(t.TempDir(), tt.limit)
+			require.NoError(t, err)
+			for i := 0; i < tt.pushes; i++ {
+				err := s.Push(bytes.Repeat([]byte{byte(i)}, 100))
+				if i < tt.queued {
+					require.NoError(t, err)
+				} else {
+					require.ErrorIs(t, err, ErrFull)
+				}
+			}
+			require.Equal(t, tt.queued, s.Len())
+		})
+	}
+}
+
+func TestPushFullFreedByPop(t *testing.T) {
+	s, err := Open(t.TempDir(), 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Push([]byte("batch 1")))
+	s.limit = s.Size()
+
+	require.ErrorIs(t, s.Push([]byte("batch 2")), ErrFull)
+	require.NoError(t, s.Pop())
+	require.NoError(t, s.Push([]byte("batch 2")))
+}
+
+func TestOpenDropsPartialBatches(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Push([]byte("complete")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("%020d%s.tmp", 1, fileSuffix)), []byte("partial"), 0644))
+
+	s, err = Open(dir, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, s.Len())
+	_, err = os.Stat(filepath.Join(dir, fmt.Sprintf("%020d%s.tmp", 1, fileSuffix)))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestNilSpool(t *testing.T) {
+	s, err := Open("", 0)
+	require.NoError(t, err)
+	require.Nil(t, s)
+	require.ErrorIs(t, s.Push([]byte("batch")), ErrFull)
+	data, err := s.Peek()
+	require.NoError(t, err)
+	require.Nil(t, data)
+	require.NoError(t, s.Pop())
+	require.Zero(t, s.Len())
+}
@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is the service.name of exported spans
+const ServiceName = "icicle"
+
+var provider *sdktrace.TracerProvider
+
+// Setup exports spans over OTLP/gRPC to endpoint, either host:port (TLS) or a URL such as
+// http://localhost:4317 (plaintext). sampleRatio is the fraction of traces kept. Without Setup
+// the global tracer is a no-op, so instrumented code costs next to nothing.
+func Setup(ctx context.Context, endpoint string, sampleRatio float64) error {
+	if endpoint == "" {
+		return nil
+	}
+
+	var opts []otlptracegrpc.Option
+	if strings.Contains(endpoint, "://") {
+		opts = append(opts, otlptracegrpc.WithEndpointURL(endpoint))
+	} else {
+		opts = append(opts, otlptracegrpc.WithEndpoint(endpoint))
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return nil
+}
+
+// Shutdown flushes buffered spans and stops the exporter
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	return provider.Shutdown(ctx)
+}
+
+// Tracer returns the tracer of a component, e.g. "evmrpc"
+func Tracer(component string) trace.Tracer {
+	return otel.Tracer(ServiceName + "/" + component)
+}
+
+// Run runs fn in a span, recording the error it returns
+func Run(ctx context.Context, tracer trace.Tracer, name string, fn func(context.Context) error, attrs ...attribute.KeyValue) error {
+	ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+	defer span.End()
+
+	err := fn(ctx)
+	RecordError(span, err)
+	return err
+}
+
+// RecordError marks span failed with err, if err is not nil
+func RecordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// Range returns the attributes of a block range
+func Range(chainID uint32, from, to int64) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int64("chain.id", int64(chainID)),
+		attribute.Int64("block.from", from),
+		attribute.Int64("block.to", to),
+	}
+}
@@ -0,0 +1,95 @@
+// Package txblob moves large fields of P-Chain tx JSON into the zstd-compressed tx_blobs column of
+// p_chain_txs and merges them back for readers of whole txs
+package txblob
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Fields are the tx_data fields that can grow large enough to dominate p_chain_txs storage
+var Fields = []string{
+	"genesisData", // CreateChainTx
+	"validators",  // ConvertSubnetToL1Tx
+}
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// Compress moves Fields of at least minSize bytes out of a tx's JSON into a
+// zstd-compressed JSON object. Returns the JSON unchanged and a nil blob if nothing was moved.
+func Compress(txData []byte, minSize int) ([]byte, []byte, error) {
+	if minSize <= 0 {
+		return txData, nil, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(txData, &fields); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse tx data: %w", err)
+	}
+
+	moved := make(map[string]json.RawMessage)
+	for _, name := range Fields {
+		if value, ok := fields[name]; ok && len(value) >= minSize {
+			moved[name] = value
+			delete(fields, name)
+		}
+	}
+	if len(moved) == 0 {
+		return txData, nil, nil
+	}
+
+	stripped, err := json.Marshal(fields)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal stripped tx data: %w", err)
+	}
+
+	blobJSON, err := json.Marshal(moved)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal tx blob: %w", err)
+	}
+
+	return stripped, zstdEncoder.EncodeAll(blobJSON, nil), nil
+}
+
+// Decompress returns the fields stored in a tx_blobs value, or nil for an empty blob
+func Decompress(blob []byte) (map[string]json.RawMessage, error) {
+	if len(blob) == 0 {
+		return nil, nil
+	}
+
+	blobJSON, err := zstdDecoder.DecodeAll(blob, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress tx blob: %w", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(blobJSON, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse tx blob: %w", err)
+	}
+
+	return fields, nil
+}
+
+// Expand merges the fields of a tx_blobs value back into the tx's JSON, returning it unchanged for
+// an empty blob
+func Expand(txData []byte, blob []byte) ([]byte, error) {
+	moved, err := Decompress(blob)
+	if err != nil || len(moved) == 0 {
+		return txData, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(txData, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse tx data: %w", err)
+	}
+	for name, value := range moved {
+		fields[name] = value
+	}
+
+	return json.Marshal(fields)
+}
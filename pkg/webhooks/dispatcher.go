@@ -0,0 +1,375 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"icicle/pkg/chwrapper"
+	"icicle/pkg/metrics"
+	"icicle/pkg/txblob"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+const (
+	// CheckInterval is how often every rule is evaluated
+	CheckInterval = 30 * time.Second
+	// DeliveryRetries is how often a failed POST is retried before the event is dropped
+	DeliveryRetries = 5
+	// DeliveryTimeout bounds one POST
+	DeliveryTimeout = 10 * time.Second
+)
+
+// Event types sent in the payload's event field
+const (
+	EventTx                  = "p_chain_tx"
+	EventValidatorBalanceLow = "validator_balance_low"
+	EventSyncStalled         = "sync_stalled"
+)
+
+// Payload is the JSON body POSTed for every event
+type Payload struct {
+	Rule  string         `json:"rule"`
+	Event string         `json:"event"`
+	Time  time.Time      `json:"time"`
+	Data  map[string]any `json:"data"`
+}
+
+// Dispatcher evaluates webhook rules against ClickHouse and POSTs the events they match.
+// Transaction rules resume from a cursor in webhook_cursors, so each transaction is sent once
+// across restarts. Balance and stall rules fire again after a restart if the condition still holds.
+type Dispatcher struct {
+	conn   driver.Conn
+	rules  []Rule
+	client *http.Client
+	logger *slog.Logger
+
+	lowBalance map[string]map[string]bool // Rule to validation IDs already reported below the threshold
+	stalls     map[string]map[uint32]bool // Rule to chains already reported stalled
+	watermarks map[uint32]watermarkSeen
+}
+
+// watermarkSeen is a chain's sync watermark and when it last moved
+type watermarkSeen struct {
+	block uint32
+	since time.Time
+}
+
+// NewDispatcher returns a dispatcher for rules
+func NewDispatcher(conn driver.Conn, rules []Rule) *Dispatcher {
+	return &Dispatcher{
+		conn:       conn,
+		rules:      rules,
+		client:     &http.Client{Timeout: DeliveryTimeout},
+		logger:     slog.With("component", "webhooks"),
+		lowBalance: make(map[string]map[string]bool),
+		stalls:     make(map[string]map[uint32]bool),
+		watermarks: make(map[uint32]watermarkSeen),
+	}
+}
+
+// Run evaluates the rules every CheckInterval until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	d.logger.Info("Webhooks enabled", "rules", len(d.rules))
+	ticker := time.NewTicker(CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		d.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check evaluates every rule once
+func (d *Dispatcher) check(ctx context.Context) {
+	var stalled []stalledChain
+	if slices.ContainsFunc(d.rules, func(r Rule) bool { return r.SyncStalledMinutes > 0 }) {
+		var err error
+		if stalled, err = d.syncState(ctx); err != nil {
+			d.logger.Error("Failed to read sync state", "error", err)
+		}
+	}
+
+	for _, rule := range d.rules {
+		var err error
+		switch {
+		case len(rule.TxTypes) > 0:
+			err = d.checkTxs(ctx, rule)
+		case rule.ValidatorBalanceBelow > 0:
+			err = d.checkBalances(ctx, rule)
+		case rule.SyncStalledMinutes > 0:
+			d.checkStalls(ctx, rule, stalled)
+		}
+		if err != nil {
+			d.logger.Error("Failed to check rule", "rule", rule.Name, "error", err)
+		}
+	}
+}
+
+// checkTxs sends the matching P-Chain transactions ingested since the rule's cursor. A new rule
+// starts at the current watermark instead of sending the chain's history.
+func (d *Dispatcher) checkTxs(ctx context.Context, rule Rule) error {
+	pChainWatermark, err := chwrapper.GetWatermark(d.conn, rule.PChainID)
+	if err != nil || pChainWatermark == 0 {
+		return err // P-Chain not ingested yet
+	}
+	watermark := uint64(pChainWatermark)
+
+	var cursors, cursor uint64
+	if err := d.conn.QueryRow(ctx, "SELECT count(), max(block_number) FROM webhook_cursors FINAL WHERE rule = ?", rule.Name).Scan(&cursors, &cursor); err != nil {
+		return fmt.Errorf("failed to read cursor: %w", err)
+	}
+	if cursors == 0 {
+		return d.setCursor(ctx, rule, watermark)
+	}
+	if watermark <= cursor {
+		return nil
+	}
+
+	rows, err := d.conn.Query(ctx, `
+		SELECT tx_id, tx_type, block_number, block_time, memo_text, toJSONString(tx_data), tx_blobs
+		FROM p_chain_txs FINAL
+		WHERE p_chain_id = ? AND tx_type IN (?) AND block_number > ? AND block_number <= ?
+		ORDER BY block_number, tx_id`, rule.PChainID, rule.TxTypes, cursor, watermark)
+	if err != nil {
+		return fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer rows.Close()
+
+	// Read before delivering, so slow endpoints don't hold the query open
+	var events []map[string]any
+	for rows.Next() {
+		var txID, txType, memoText, txData, txBlob string
+		var blockNumber uint64
+		var blockTime time.Time
+		if err := rows.Scan(&txID, &txType, &blockNumber, &blockTime, &memoText, &txData, &txBlob); err != nil {
+			return fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		expanded, err := txblob.Expand([]byte(txData), []byte(txBlob))
+		if err != nil {
+			return fmt.Errorf("failed to expand transaction %s: %w", txID, err)
+		}
+		var data any
+		if err := json.Unmarshal(expanded, &data); err != nil {
+			return fmt.Errorf("failed to decode transaction %s: %w", txID, err)
+		}
+		events = append(events, map[string]any{
+			"p_chain_id":   rule.PChainID,
+			"tx_id":        txID,
+			"tx_type":      txType,
+			"block_number": blockNumber,
+			"block_time":   blockTime,
+			"memo_text":    memoText,
+			"tx_data":      data,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		d.deliver(ctx, rule, EventTx, event)
+	}
+
+	return d.setCursor(ctx, rule, watermark)
+}
+
+// setCursor records that a transaction rule has been checked up to block
+func (d *Dispatcher) setCursor(ctx context.Context, rule Rule, block uint64) error {
+	if err := d.conn.Exec(ctx, "INSERT INTO webhook_cursors (rule, block_number) VALUES (?, ?)", rule.Name, block); err != nil {
+		return fmt.Errorf("failed to save cursor: %w", err)
+	}
+	return nil
+}
+
+// checkBalances sends active L1 validators that dropped below the rule's balance, once until
+// they are topped up again
+func (d *Dispatcher) checkBalances(ctx context.Context, rule Rule) error {
+	query := `
+		SELECT subnet_id, validation_id, node_id, balance
+		FROM l1_validator_state FINAL
+		WHERE p_chain_id = ? AND active AND balance < ?`
+	args := []any{rule.PChainID, rule.ValidatorBalanceBelow}
+	if rule.SubnetID != "" {
+		query += " AND subnet_id = ?"
+		args = append(args, rule.SubnetID)
+	}
+
+	rows, err := d.conn.Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query validators: %w", err)
+	}
+	defer rows.Close()
+
+	reported := d.lowBalance[rule.Name]
+	low := make(map[string]bool)
+	var events []map[string]any
+	for rows.Next() {
+		var subnetID, validationID, nodeID string
+		var balance uint64
+		if err := rows.Scan(&subnetID, &validationID, &nodeID, &balance); err != nil {
+			return fmt.Errorf("failed to scan validator: %w", err)
+		}
+		low[validationID] = true
+		if reported[validationID] {
+			continue
+		}
+		events = append(events, map[string]any{
+			"p_chain_id":    rule.PChainID,
+			"subnet_id":     subnetID,
+			"validation_id": validationID,
+			"node_id":       nodeID,
+			"balance":       balance,
+			"threshold":     rule.ValidatorBalanceBelow,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		d.deliver(ctx, rule, EventValidatorBalanceLow, event)
+	}
+
+	d.lowBalance[rule.Name] = low
+	return nil
+}
+
+// stalledChain is a chain behind its RPC tip and how long its watermark hasn't moved
+type stalledChain struct {
+	chainID   uint32
+	name      string
+	watermark uint32
+	tip       uint64
+	since     time.Time
+}
+
+// syncState returns the chains behind their RPC tip, tracking when each watermark last moved
+func (d *Dispatcher) syncState(ctx context.Context) ([]stalledChain, error) {
+	rows, err := d.conn.Query(ctx, `
+		SELECT c.chain_id, c.name, c.last_block_on_chain, w.block_number
+		FROM chain_status AS c FINAL
+		LEFT JOIN sync_watermark AS w ON w.chain_id = c.chain_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chain status: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	var behind []stalledChain
+	for rows.Next() {
+		var chain stalledChain
+		if err := rows.Scan(&chain.chainID, &chain.name, &chain.tip, &chain.watermark); err != nil {
+			return nil, fmt.Errorf("failed to scan chain status: %w", err)
+		}
+		seen, ok := d.watermarks[chain.chainID]
+		if !ok || seen.block != chain.watermark {
+			seen = watermarkSeen{block: chain.watermark, since: now}
+			d.watermarks[chain.chainID] = seen
+		}
+		if uint64(chain.watermark) < chain.tip {
+			chain.since = seen.since
+			behind = append(behind, chain)
+		}
+	}
+	return behind, rows.Err()
+}
+
+// checkStalls sends chains whose watermark hasn't moved for the rule's minutes, once per stall
+func (d *Dispatcher) checkStalls(ctx context.Context, rule Rule, behind []stalledChain) {
+	reported := d.stalls[rule.Name]
+	stalled := make(map[uint32]bool)
+	for _, chain := range behind {
+		if len(rule.ChainIDs) > 0 && !slices.Contains(rule.ChainIDs, chain.chainID) {
+			continue
+		}
+		if time.Since(chain.since) < time.Duration(rule.SyncStalledMinutes)*time.Minute {
+			continue
+		}
+		stalled[chain.chainID] = true
+		if reported[chain.chainID] {
+			continue
+		}
+		d.deliver(ctx, rule, EventSyncStalled, map[string]any{
+			"chain_id":      chain.chainID,
+			"name":          chain.name,
+			"watermark":     chain.watermark,
+			"tip":           chain.tip,
+			"stalled_since": chain.since.UTC(),
+		})
+	}
+	d.stalls[rule.Name] = stalled
+}
+
+// deliver POSTs an event to the rule's URL, retrying with backoff on network errors, 429 and 5xx
+func (d *Dispatcher) deliver(ctx context.Context, rule Rule, event string, data map[string]any) {
+	body, err := json.Marshal(Payload{Rule: rule.Name, Event: event, Time: time.Now().UTC(), Data: data})
+	if err != nil {
+		d.logger.Error("Failed to encode webhook payload", "rule", rule.Name, "error", err)
+		return
+	}
+
+	for attempt := 0; ; attempt++ {
+		retry, err := d.post(ctx, rule, event, body)
+		if err == nil {
+			metrics.WebhookDelivery(rule.Name, metrics.WebhookDelivered)
+			return
+		}
+		if !retry || attempt >= DeliveryRetries {
+			d.logger.Error("Failed to deliver webhook", "rule", rule.Name, "event", event, "attempts", attempt+1, "error", err)
+			metrics.WebhookDelivery(rule.Name, metrics.WebhookFailed)
+			return
+		}
+
+		backoff := min(time.Second<<attempt, 30*time.Second)
+		d.logger.Warn("Webhook delivery failed, retrying", "rule", rule.Name, "attempt", attempt+1, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// post sends one delivery attempt and reports whether a failure is worth retrying
+func (d *Dispatcher) post(ctx context.Context, rule Rule, event string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rule.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Icicle-Event", event)
+	if rule.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(rule.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Icicle-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("HTTP %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+}
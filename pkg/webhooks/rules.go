@@ -0,0 +1,73 @@
+package webhooks
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule sends the events of one matcher to a URL. Exactly one of TxTypes, ValidatorBalanceBelow
+// and SyncStalledMinutes is set.
+type Rule struct {
+	Name   string `yaml:"name"`
+	URL    string `yaml:"url"`
+	Secret string `yaml:"secret"` // Signs payloads in X-Icicle-Signature when set
+
+	PChainID uint32 `yaml:"pChainID"` // P-Chain instance of txTypes and validatorBalanceBelow (default: 0)
+
+	// P-Chain transactions of these types, e.g. ConvertSubnetToL1, RegisterL1Validator
+	TxTypes []string `yaml:"txTypes"`
+
+	// Active L1 validators whose balance drops below this many nAVAX, optionally of one subnet
+	ValidatorBalanceBelow uint64 `yaml:"validatorBalanceBelow"`
+	SubnetID              string `yaml:"subnetID"`
+
+	// Chains whose sync watermark hasn't moved for this many minutes while behind the RPC tip,
+	// optionally only the chains in ChainIDs
+	SyncStalledMinutes int      `yaml:"syncStalledMinutes"`
+	ChainIDs           []uint32 `yaml:"chainIDs"`
+}
+
+// LoadRules reads webhook rules from a YAML file
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook rules: %w", err)
+	}
+
+	var rules []Rule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook rules: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for i, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule at index %d: name is required", i)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("rule %s: duplicate name", rule.Name)
+		}
+		names[rule.Name] = true
+		if rule.URL == "" {
+			return nil, fmt.Errorf("rule %s: url is required", rule.Name)
+		}
+
+		matchers := 0
+		if len(rule.TxTypes) > 0 {
+			matchers++
+		}
+		if rule.ValidatorBalanceBelow > 0 {
+			matchers++
+		}
+		if rule.SyncStalledMinutes > 0 {
+			matchers++
+		}
+		if matchers != 1 {
+			return nil, fmt.Errorf("rule %s: set exactly one of txTypes, validatorBalanceBelow and syncStalledMinutes", rule.Name)
+		}
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,7 @@
+-- Macros shared by the indexers, expanded with template actions naming them and an optional argument
+
+-- Start of the period a time falls in, in the chain's time zone
+{{ define "period" }}toStartOf{granularityCamelCase}(toTimeZone({{ . }}, '{timezone}')){{ end }}
+
+-- The zero address, used as from or to of mints, burns and system transactions
+{{ define "zero_address" }}unhex('0000000000000000000000000000000000000000'){{ end }}
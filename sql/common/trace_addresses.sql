@@ -0,0 +1,16 @@
+-- Addresses calling or called by the chain's traces, one row per occurrence with its block_time,
+-- except the zero address. The argument filters the traces, e.g. "block_time < @first_period"
+SELECT from as address, block_time
+FROM raw_traces
+WHERE chain_id = @chain_id
+  AND {{ . }}
+  AND from != {{ template "zero_address" }}
+
+UNION ALL
+
+SELECT to as address, block_time
+FROM raw_traces
+WHERE chain_id = @chain_id
+  AND {{ . }}
+  AND to IS NOT NULL
+  AND to != {{ template "zero_address" }}
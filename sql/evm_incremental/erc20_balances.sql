@@ -122,7 +122,7 @@ FROM (
       AND length(data) = 32
       AND topic1 IS NOT NULL
 ) transfers
-WHERE wallet != unhex('0000000000000000000000000000000000000000')  -- Skip zero address as wallet
+WHERE wallet != {{ template "zero_address" }}  -- Skip zero address as wallet
 GROUP BY wallet, token
 HAVING deposits > 0 OR withdrawals > 0;  -- Only insert if there were changes
 
@@ -1,13 +1,14 @@
 -- Average gas price metric
--- Parameters: chain_id, first_period, last_period, granularity
+-- Parameters: chain_id, first_period, last_period, granularity, fee_asset
 
-INSERT INTO metrics (chain_id, metric_name, granularity, period, value)
+INSERT INTO metrics (chain_id, metric_name, granularity, period, value, asset)
 SELECT
     {chain_id} as chain_id,
     'avg_gas_price' as metric_name,
     '{granularity}' as granularity,
-    toStartOf{granularityCamelCase}(block_time) as period,
-    CAST(avg(gas_price) AS UInt64) as value
+    {{ template "period" "block_time" }} as period,
+    CAST(avg(gas_price) AS UInt64) as value,
+    '{fee_asset}' as asset
 FROM raw_txs
 WHERE chain_id = @chain_id
   AND block_time >= @first_period
@@ -4,7 +4,7 @@
 INSERT INTO metrics (chain_id, metric_name, granularity, period, value)
 WITH period_data AS (
     SELECT
-        toStartOf{granularityCamelCase}(block_time) as period,
+        {{ template "period" "block_time" }} as period,
         count(*) as tx_count
     FROM raw_txs
     WHERE chain_id = @chain_id
@@ -17,6 +17,6 @@ SELECT
     'avg_tps' as metric_name,
     '{granularity}' as granularity,
     period,
-    CAST(tx_count / (toUnixTimestamp(period + INTERVAL 1 {granularity}) - toUnixTimestamp(period)) AS UInt64) as value
+    CAST(tx_count / (toUnixTimestamp(period + INTERVAL {interval}) - toUnixTimestamp(period)) AS UInt64) as value
 FROM period_data
 ORDER BY period;
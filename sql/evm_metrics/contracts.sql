@@ -6,7 +6,7 @@ SELECT
     {chain_id} as chain_id,
     'contracts' as metric_name,
     '{granularity}' as granularity,
-    toStartOf{granularityCamelCase}(block_time) as period,
+    {{ template "period" "block_time" }} as period,
     count(*) as value
 FROM raw_traces
 WHERE chain_id = @chain_id
@@ -7,25 +7,10 @@ WITH
 -- Find the first period each address appeared in (within our range)
 first_appearances AS (
     SELECT 
-        toStartOf{granularityCamelCase}(min(block_time)) as first_period,
+        {{ template "period" "min(block_time)" }} as first_period,
         address
     FROM (
-        SELECT from as address, block_time
-        FROM raw_traces
-        WHERE chain_id = @chain_id
-          AND block_time >= @first_period
-          AND block_time < @last_period
-          AND from != unhex('0000000000000000000000000000000000000000')
-        
-        UNION ALL
-        
-        SELECT to as address, block_time
-        FROM raw_traces
-        WHERE chain_id = @chain_id
-          AND block_time >= @first_period
-          AND block_time < @last_period
-          AND to IS NOT NULL
-          AND to != unhex('0000000000000000000000000000000000000000')
+        {{ include "common/trace_addresses.sql" "block_time >= @first_period AND block_time < @last_period" }}
     ) AS all_occurrences
     GROUP BY address
 ),
@@ -41,20 +26,7 @@ new_per_period AS (
 baseline AS (
     SELECT countDistinct(address) as prev_cumulative
     FROM (
-        SELECT from as address
-        FROM raw_traces
-        WHERE chain_id = @chain_id
-          AND block_time < @first_period
-          AND from != unhex('0000000000000000000000000000000000000000')
-        
-        UNION ALL
-        
-        SELECT to as address
-        FROM raw_traces
-        WHERE chain_id = @chain_id
-          AND block_time < @first_period
-          AND to IS NOT NULL
-          AND to != unhex('0000000000000000000000000000000000000000')
+        {{ include "common/trace_addresses.sql" "block_time < @first_period" }}
     ) AS historical_addresses
 )
 -- Running sum of new addresses + baseline
@@ -7,7 +7,7 @@ WITH
 -- Count contracts created per period
 contracts_per_period AS (
     SELECT
-        toStartOf{granularityCamelCase}(block_time) as period,
+        {{ template "period" "block_time" }} as period,
         count(*) as period_count
     FROM raw_traces
     WHERE chain_id = @chain_id
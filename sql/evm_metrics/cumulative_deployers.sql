@@ -7,7 +7,7 @@ WITH
 -- Find the first period each deployer deployed in (within our range)
 first_deployments AS (
     SELECT 
-        toStartOf{granularityCamelCase}(min(block_time)) as first_period,
+        {{ template "period" "min(block_time)" }} as first_period,
         from as deployer
     FROM raw_traces
     WHERE chain_id = @chain_id
@@ -15,7 +15,7 @@ first_deployments AS (
       AND block_time < @last_period
       AND call_type IN ('CREATE', 'CREATE2', 'CREATE3')
       AND tx_success = true
-      AND from != unhex('0000000000000000000000000000000000000000')
+      AND from != {{ template "zero_address" }}
     GROUP BY deployer
 ),
 -- Count new deployers per period
@@ -34,7 +34,7 @@ baseline AS (
       AND block_time < @first_period
       AND call_type IN ('CREATE', 'CREATE2', 'CREATE3')
       AND tx_success = true
-      AND from != unhex('0000000000000000000000000000000000000000')
+      AND from != {{ template "zero_address" }}
 )
 -- Running sum of new deployers + baseline
 SELECT
@@ -7,7 +7,7 @@ WITH
 -- Count transactions per period
 txs_per_period AS (
     SELECT
-        toStartOf{granularityCamelCase}(block_time) as period,
+        {{ template "period" "block_time" }} as period,
         count(*) as period_count
     FROM raw_txs
     WHERE chain_id = @chain_id
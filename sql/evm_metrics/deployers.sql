@@ -6,7 +6,7 @@ SELECT
     {chain_id} as chain_id,
     'deployers' as metric_name,
     '{granularity}' as granularity,
-    toStartOf{granularityCamelCase}(block_time) as period,
+    {{ template "period" "block_time" }} as period,
     uniq(from) as value
 FROM raw_traces
 WHERE chain_id = @chain_id
@@ -14,6 +14,6 @@ WHERE chain_id = @chain_id
   AND block_time < @last_period
   AND call_type IN ('CREATE', 'CREATE2', 'CREATE3')
   AND tx_success = true
-  AND from != unhex('0000000000000000000000000000000000000000')
+  AND from != {{ template "zero_address" }}
 GROUP BY period
 ORDER BY period;
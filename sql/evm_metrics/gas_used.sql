@@ -6,7 +6,7 @@ SELECT
     {chain_id} as chain_id,
     'gas_used' as metric_name,
     '{granularity}' as granularity,
-    toStartOf{granularityCamelCase}(block_time) as period,
+    {{ template "period" "block_time" }} as period,
     sum(gas_used) as value
 FROM raw_txs
 WHERE chain_id = @chain_id
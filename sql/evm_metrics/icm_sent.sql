@@ -6,7 +6,7 @@ SELECT
     {chain_id} as chain_id,-- chains are indexed separately
     'icm_sent' as metric_name,-- all in one table
     '{granularity}' as granularity,--hour, day, week, month
-    toStartOf{granularityCamelCase}(block_time) as period,--toStartOfHour, toStartOfDay, toStartOfWeek, toStartOfMonth
+    {{ template "period" "block_time" }} as period,--toStartOfHour, toStartOfDay, toStartOfWeek, toStartOfMonth
     count(*) as value
 FROM raw_logs -- a raw table with all transaction receipts' logs flattened
 WHERE chain_id = @chain_id
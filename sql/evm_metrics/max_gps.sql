@@ -4,7 +4,7 @@
 INSERT INTO metrics (chain_id, metric_name, granularity, period, value)
 WITH gas_per_second AS (
     SELECT 
-        toStartOf{granularityCamelCase}(block_time) as period,
+        {{ template "period" "block_time" }} as period,
         toStartOfSecond(block_time) as second,
         sum(gas_used) as gas_used
     FROM raw_blocks
@@ -4,7 +4,7 @@
 INSERT INTO metrics (chain_id, metric_name, granularity, period, value)
 WITH txs_per_second AS (
     SELECT 
-        toStartOf{granularityCamelCase}(block_time) as period,
+        {{ template "period" "block_time" }} as period,
         toStartOfSecond(block_time) as second,
         count(*) as tx_count
     FROM raw_txs
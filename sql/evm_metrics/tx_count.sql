@@ -6,7 +6,7 @@ SELECT
     {chain_id} as chain_id,
     'tx_count' as metric_name,
     '{granularity}' as granularity,
-    toStartOf{granularityCamelCase}(block_time) as period,
+    {{ template "period" "block_time" }} as period,
     count(*) as value
 FROM raw_txs
 WHERE chain_id = @chain_id
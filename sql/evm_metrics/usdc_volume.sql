@@ -8,7 +8,7 @@ SELECT
     {chain_id} as chain_id,
     'usdc_volume' as metric_name,
     '{granularity}' as granularity,
-    toStartOf{granularityCamelCase}(block_time) as period,
+    {{ template "period" "block_time" }} as period,
     -- Sum USDC amounts (decode from data field, divide by 1e6 for USDC decimals)
     CAST(sum(reinterpretAsUInt256(reverse(data))) / 1000000 AS UInt64) as value
 FROM raw_logs
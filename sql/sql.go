@@ -0,0 +1,10 @@
+// Package sql embeds the indexer SQL files, so a deployed binary runs its indexers without a copy
+// of this directory next to it
+package sql
+
+import "embed"
+
+// Files holds the evm_metrics and evm_incremental indexers, and the common files they include
+//
+//go:embed evm_metrics/*.sql evm_incremental/*.sql common/*.sql
+var Files embed.FS